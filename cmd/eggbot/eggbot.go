@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os"
+	_ "time/tzdata" // display.timezone must resolve on hosts without a zoneinfo database
 
 	"github.com/buildtall-systems/eggbot/internal/cli"
 )
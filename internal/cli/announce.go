@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	gonostr "github.com/nbd-wtf/go-nostr"
+)
+
+// Replaceable announcements are NIP-78 application data, one per bot under
+// this d tag, so each replaces the last.
+const (
+	kindApplicationData = 30078
+	announcementDTag    = "eggbot-inventory"
+)
+
+// announcements remembers when the inventory was last announced, across
+// events and config reloads, to throttle announcements.
+type announcements struct {
+	mu   sync.Mutex
+	last time.Time
+	now  func() time.Time
+}
+
+// inventoryAnnouncer announces with one config snapshot.
+type inventoryAnnouncer struct {
+	state   *announcements
+	cfg     config.AnnounceConfig
+	kr      gonostr.Keyer
+	publish func(ctx context.Context, event *gonostr.Event) error
+}
+
+var _ commands.Announcer = inventoryAnnouncer{}
+
+// Announce signs and publishes an announcement of available eggs, unless one
+// went out within the configured interval.
+func (a inventoryAnnouncer) Announce(ctx context.Context, available int) error {
+	a.state.mu.Lock()
+	defer a.state.mu.Unlock()
+
+	now := a.state.now()
+	if !a.state.last.IsZero() {
+		if wait := a.state.last.Add(a.cfg.MinInterval).Sub(now); wait > 0 {
+			return fmt.Errorf("%w, the next one can go out in %s", commands.ErrAnnounceThrottled, wait.Round(time.Second))
+		}
+	}
+
+	event, err := announcementEvent(a.cfg.Mode, available, now)
+	if err != nil {
+		return err
+	}
+	if err := a.kr.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("signing announcement: %w", err)
+	}
+	if err := a.publish(ctx, event); err != nil {
+		return err
+	}
+	a.state.last = now
+	return nil
+}
+
+// announcementEvent builds the unsigned announcement of available eggs for
+// mode: a kind:1 note, or a replaceable event with the count as JSON.
+func announcementEvent(mode string, available int, now time.Time) (*gonostr.Event, error) {
+	text := fmt.Sprintf("🥚 %d eggs available — DM to order", available)
+	switch mode {
+	case config.AnnounceNote:
+		return &gonostr.Event{
+			Kind:      gonostr.KindTextNote,
+			CreatedAt: gonostr.Timestamp(now.Unix()),
+			Tags:      gonostr.Tags{},
+			Content:   text,
+		}, nil
+	case config.AnnounceReplaceable:
+		content, err := json.Marshal(map[string]int{"available": available})
+		if err != nil {
+			return nil, fmt.Errorf("encoding announcement: %w", err)
+		}
+		return &gonostr.Event{
+			Kind:      kindApplicationData,
+			CreatedAt: gonostr.Timestamp(now.Unix()),
+			Tags: gonostr.Tags{
+				{"d", announcementDTag},
+				{"alt", text}, // NIP-31 summary for clients that don't know the kind
+			},
+			Content: string(content),
+		}, nil
+	default:
+		return nil, fmt.Errorf("announcements are off (announce.mode %q)", mode)
+	}
+}
+
+// autoAnnounceAmount returns how many eggs an "inventory add" command added,
+// or 0 for any other command.
+func autoAnnounceAmount(cmd *commands.Command) int {
+	if cmd.Name != commands.CmdInventory || len(cmd.Args) != 2 || cmd.Args[0] != "add" {
+		return 0
+	}
+	n, err := strconv.Atoi(cmd.Args[1])
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	gonostr "github.com/nbd-wtf/go-nostr"
+)
+
+func TestAnnouncementEvent(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	note, err := announcementEvent(config.AnnounceNote, 18, now)
+	if err != nil {
+		t.Fatalf("note: %v", err)
+	}
+	if note.Kind != gonostr.KindTextNote || note.Content != "🥚 18 eggs available — DM to order" || note.CreatedAt != 1700000000 {
+		t.Errorf("note = %+v", note)
+	}
+
+	event, err := announcementEvent(config.AnnounceReplaceable, 18, now)
+	if err != nil {
+		t.Fatalf("replaceable: %v", err)
+	}
+	if event.Kind != kindApplicationData || event.Content != `{"available":18}` {
+		t.Errorf("replaceable = %+v", event)
+	}
+	if d := event.Tags.GetD(); d != announcementDTag {
+		t.Errorf("d tag = %q, want %q", d, announcementDTag)
+	}
+
+	if _, err := announcementEvent(config.AnnounceOff, 18, now); err == nil {
+		t.Error("expected an error with announcements off")
+	}
+}
+
+func TestInventoryAnnouncer_Throttles(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	h, published := newTestHandler(t, newTestDB(t, ":memory:"), cfg)
+	now := time.Unix(1700000000, 0)
+	h.announcements.now = func() time.Time { return now }
+	cfg.Announce = config.AnnounceConfig{Mode: config.AnnounceNote, MinInterval: time.Hour}
+	announcer := h.announcer(cfg)
+
+	if err := announcer.Announce(ctx, 12); err != nil {
+		t.Fatalf("first announcement: %v", err)
+	}
+	now = now.Add(59 * time.Minute)
+	err := announcer.Announce(ctx, 24)
+	if err == nil || !strings.Contains(err.Error(), "next one can go out in 1m0s") {
+		t.Errorf("expected a throttled announcement, got %v", err)
+	}
+	now = now.Add(time.Minute)
+	if err := announcer.Announce(ctx, 24); err != nil {
+		t.Fatalf("announcement after the interval: %v", err)
+	}
+
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want 2", len(published.events))
+	}
+	for _, event := range published.events {
+		if ok, _ := event.CheckSignature(); !ok || event.PubKey != cfg.Nostr.BotPubkeyHex {
+			t.Errorf("announcement not signed by the bot: %+v", event)
+		}
+	}
+
+	cfg.Announce.Mode = config.AnnounceOff
+	if h.announcer(cfg) != nil {
+		t.Error("announcer should be nil with announcements off")
+	}
+}
+
+func TestEventHandler_AutoAnnouncesLargeRestock(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Announce = config.AnnounceConfig{Mode: config.AnnounceReplaceable, MinInterval: time.Hour, AutoThreshold: 12}
+	admin := newTestSender(t)
+	h, published := newTestHandler(t, newTestDB(t, ":memory:", admin.npub), cfg)
+
+	for _, command := range []string{"inventory add 6", "inventory add 12"} {
+		event, err := dm.WrapLegacyResponse(ctx, admin.kr, admin.secretHex, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, command, "")
+		if err != nil {
+			t.Fatalf("wrapping DM: %v", err)
+		}
+		h.HandleDM(ctx, event)
+	}
+
+	// Two replies and one announcement, after the second add
+	var announcements []*gonostr.Event
+	for _, event := range published.events {
+		if event.Kind == kindApplicationData {
+			announcements = append(announcements, event)
+		}
+	}
+	if len(published.events) != 3 || len(announcements) != 1 || published.events[2] != announcements[0] {
+		t.Fatalf("published %d events with %d announcements, want the second add announced", len(published.events), len(announcements))
+	}
+	if announcements[0].Content != `{"available":18}` {
+		t.Errorf("announcement = %s", announcements[0].Content)
+	}
+}
+
+func TestAutoAnnounceAmount(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int
+	}{
+		{"inventory add 24", 24},
+		{"inventory set 24", 0},
+		{"inventory add lots", 0},
+		{"inventory", 0},
+		{"order 12", 0},
+	}
+	for _, tt := range tests {
+		if got := autoAnnounceAmount(commands.Parse(tt.input)); got != tt.want {
+			t.Errorf("autoAnnounceAmount(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
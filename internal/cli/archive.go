@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var archiveCmd = &cobra.Command{
+	Use:   "archive",
+	Short: "Move old fulfilled orders to the archive table",
+	Long: `Move fulfilled orders placed more than --days ago from the orders table to
+orders_archive, in a single transaction. Their invoices are deleted. Archived
+orders no longer show in "history" or "orders", but still count toward
+balances, sales and revenue.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		days, _ := cmd.Flags().GetInt("days")
+		if days < 0 {
+			return errors.New("--days must not be negative")
+		}
+
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		return runArchive(cmd.Context(), database, days, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	archiveCmd.Flags().Int("days", 90, "archive fulfilled orders placed more than this many days ago")
+	rootCmd.AddCommand(archiveCmd)
+}
+
+// runArchive archives fulfilled orders older than days and reports how many moved.
+func runArchive(ctx context.Context, database *db.DB, days int, out io.Writer) error {
+	moved, err := database.ArchiveOldFulfilledOrders(ctx, days)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(out, "Archived %d fulfilled orders older than %d days\n", moved, days)
+	return nil
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestRunArchive(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+
+	customer, _ := database.CreateCustomer(ctx, newTestSender(t).npub, "")
+	_ = database.AddEggs(ctx, 6)
+	order, _ := database.CreateOrder(ctx, customer.ID, 6, 3200)
+	_ = database.UpdateOrderStatus(ctx, order.ID, "paid")
+	_ = database.FulfillOrder(ctx, order.ID)
+	if _, err := database.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', '-91 days')`); err != nil {
+		t.Fatalf("backdating order: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runArchive(ctx, database, 90, &out); err != nil {
+		t.Fatalf("runArchive: %v", err)
+	}
+	if out.String() != "Archived 1 fulfilled orders older than 90 days\n" {
+		t.Errorf("output = %q", out.String())
+	}
+	if archived, _ := database.GetArchivedOrders(ctx, customer.ID, 10); len(archived) != 1 {
+		t.Errorf("expected the order archived, got %+v", archived)
+	}
+}
@@ -0,0 +1,169 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// checkTimeout bounds each network check.
+const checkTimeout = 5 * time.Second
+
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check the config, database, relays and lightning address",
+	Long: `Run the checks an operator would before "run", without starting the bot:
+
+  config     load the config and EGGBOT_NSEC and validate every setting
+  database   run PRAGMA quick_check on the database file
+  relay      fetch each relay's NIP-11 document over HTTP
+  lightning  fetch the LNURL-pay metadata of each lightning address
+
+Each check prints PASS or FAIL; the command exits non-zero if any fails.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runCheck(cmd.Context(), cmd.OutOrStdout(), newChecker())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+}
+
+// checker runs the pre-flight checks. Its clients are replaced in tests.
+type checker struct {
+	http      *http.Client      // Relay probes
+	lightning *lightning.Client // Lightning address metadata
+}
+
+func newChecker() checker {
+	client := &http.Client{Timeout: checkTimeout}
+	return checker{http: client, lightning: lightning.NewClientWithHTTP(client)}
+}
+
+// runCheck runs every check, printing a line for each, and returns an error
+// listing the failures.
+func runCheck(ctx context.Context, out io.Writer, c checker) error {
+	var failures []error
+	report := func(name string, err error, detail string) {
+		switch {
+		case err != nil:
+			_, _ = fmt.Fprintf(out, "[FAIL] %s: %v\n", name, err)
+			failures = append(failures, fmt.Errorf("%s: %w", name, err))
+		case detail != "":
+			_, _ = fmt.Fprintf(out, "[PASS] %s: %s\n", name, detail)
+		default:
+			_, _ = fmt.Fprintf(out, "[PASS] %s\n", name)
+		}
+	}
+
+	cfg, err := config.LoadWithSecrets()
+	if err != nil {
+		report("config", fmt.Errorf("config check failed: %w", err), "")
+		// The remaining checks only need settings, not the bot key
+		if cfg, err = config.Load(); err != nil {
+			return checkFailed(failures)
+		}
+	} else {
+		if used := viper.ConfigFileUsed(); used != "" {
+			_, _ = fmt.Fprintf(out, "Config file: %s\n", used)
+		}
+		report("config", nil, fmt.Sprintf("bot %s, %d relays, %d admins",
+			cfg.Nostr.BotNpub, len(cfg.Nostr.Relays), len(cfg.Admins)))
+		for _, warning := range cfg.Warnings {
+			_, _ = fmt.Fprintf(out, "[WARN] config: %s\n", warning)
+		}
+	}
+
+	detail, err := checkDatabase(ctx, cfg.Database.Path)
+	report("database", err, detail)
+
+	for _, relay := range cfg.Nostr.Relays {
+		report("relay "+relay, c.checkRelay(ctx, relay), "")
+	}
+
+	addresses := append([]string{cfg.Lightning.LightningAddress}, cfg.Lightning.FallbackAddresses...)
+	if addresses[0] == "" {
+		_, _ = fmt.Fprintln(out, "[SKIP] lightning: no lightning address configured")
+	} else {
+		for _, address := range addresses {
+			report("lightning "+address, c.checkLightning(ctx, address), "")
+		}
+	}
+
+	if len(failures) > 0 {
+		return checkFailed(failures)
+	}
+	_, _ = fmt.Fprintln(out, "All checks passed")
+	return nil
+}
+
+// checkFailed combines check failures into one error.
+func checkFailed(failures []error) error {
+	return fmt.Errorf("%d check(s) failed:\n%w", len(failures), errors.Join(failures...))
+}
+
+// checkDatabase runs a quick integrity check. A missing file passes, since
+// run creates it.
+func checkDatabase(ctx context.Context, path string) (string, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return path + " does not exist yet; run will create it", nil
+	} else if err != nil {
+		return "", err
+	}
+	if err := db.QuickCheck(ctx, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// checkRelay fetches the relay's NIP-11 information document. Any response
+// short of a server error shows the relay is reachable.
+func (c checker) checkRelay(ctx context.Context, relayURL string) error {
+	var httpURL string
+	switch {
+	case strings.HasPrefix(relayURL, "wss://"):
+		httpURL = "https://" + strings.TrimPrefix(relayURL, "wss://")
+	case strings.HasPrefix(relayURL, "ws://"):
+		httpURL = "http://" + strings.TrimPrefix(relayURL, "ws://")
+	default:
+		return fmt.Errorf("relay URL must start with wss:// or ws://")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/nostr+json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// checkLightning fetches the LNURL-pay metadata for a lightning address.
+func (c checker) checkLightning(ctx context.Context, address string) error {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	_, err := c.lightning.FetchMetadata(ctx, address)
+	return err
+}
@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/spf13/viper"
+)
+
+// checkFixture holds mock relay and lightning servers for check tests.
+type checkFixture struct {
+	checker   checker
+	relay     string // Answers NIP-11 requests
+	deadRelay string // Nothing listening
+	address   string // Lightning address served by the mock LNURL server
+}
+
+func newCheckFixture(t *testing.T) checkFixture {
+	t.Helper()
+
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != "application/nostr+json" {
+			http.Error(w, "use a websocket", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/nostr+json")
+		_, _ = w.Write([]byte(`{"name":"mock relay","supported_nips":[1,11]}`))
+	}))
+	t.Cleanup(relay.Close)
+
+	dead := httptest.NewServer(http.NotFoundHandler())
+	deadURL := dead.URL
+	dead.Close()
+
+	lnurl := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/lnurlp/eggs" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(`{"callback":"https://example.com/cb","minSendable":1000,"maxSendable":100000000,"tag":"payRequest"}`))
+	}))
+	t.Cleanup(lnurl.Close)
+
+	return checkFixture{
+		checker: checker{
+			http:      relay.Client(),
+			lightning: lightning.NewClientWithHTTP(lnurl.Client()),
+		},
+		relay:     "ws://" + strings.TrimPrefix(relay.URL, "http://"),
+		deadRelay: "ws://" + strings.TrimPrefix(deadURL, "http://"),
+		address:   "eggs@" + strings.TrimPrefix(lnurl.URL, "https://"),
+	}
+}
+
+// writeCheckConfig writes a config with the given relays and lightning
+// address and loads it into viper.
+func writeCheckConfig(t *testing.T, relays []string, address string) string {
+	t.Helper()
+	path := testConfigPath(t)
+	err := runInit(initOptions{path: path, relays: relays, admins: []string{testExpectedNpub}, nonInteractive: true},
+		strings.NewReader(""), &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("runInit: %v", err)
+	}
+	loadWritten(t, path)
+
+	dbPath := filepath.Join(filepath.Dir(path), "eggbot.db")
+	viper.Set("database.path", dbPath)
+	if address != "" {
+		viper.Set("lightning.address", address)
+	}
+	return dbPath
+}
+
+func TestRunCheck_AllPass(t *testing.T) {
+	fx := newCheckFixture(t)
+	dbPath := writeCheckConfig(t, []string{fx.relay}, fx.address)
+	database, err := db.Open(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(); err != nil {
+		t.Fatal(err)
+	}
+	_ = database.Close()
+	t.Setenv("EGGBOT_NSEC", testNsec)
+
+	var out bytes.Buffer
+	if err := runCheck(context.Background(), &out, fx.checker); err != nil {
+		t.Fatalf("runCheck: %v\n%s", err, out.String())
+	}
+	for _, want := range []string{
+		"[PASS] config: bot " + testBotNpub,
+		"[PASS] database: " + dbPath,
+		"[PASS] relay " + fx.relay,
+		"[PASS] lightning " + fx.address,
+		"All checks passed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunCheck_ReportsEachFailure(t *testing.T) {
+	fx := newCheckFixture(t)
+	missingAddress := "nobody@" + strings.SplitN(fx.address, "@", 2)[1]
+	dbPath := writeCheckConfig(t, []string{fx.relay, fx.deadRelay}, missingAddress)
+	if err := os.WriteFile(dbPath, []byte("this is not a database, just some text"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("EGGBOT_NSEC", testNsec)
+
+	var out bytes.Buffer
+	err := runCheck(context.Background(), &out, fx.checker)
+	if err == nil {
+		t.Fatalf("expected failures, got none:\n%s", out.String())
+	}
+	if !strings.Contains(err.Error(), "3 check(s) failed") {
+		t.Errorf("error = %v, want 3 failures", err)
+	}
+	for _, want := range []string{
+		"[PASS] config",
+		"[FAIL] database",
+		"[PASS] relay " + fx.relay,
+		"[FAIL] relay " + fx.deadRelay,
+		"[FAIL] lightning " + missingAddress,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunCheck_ConfigErrors(t *testing.T) {
+	fx := newCheckFixture(t)
+	writeCheckConfig(t, []string{fx.relay}, "")
+
+	t.Setenv("EGGBOT_NSEC", "")
+	var out bytes.Buffer
+	err := runCheck(context.Background(), &out, fx.checker)
+	if err == nil || !strings.Contains(err.Error(), "EGGBOT_NSEC") {
+		t.Errorf("expected missing EGGBOT_NSEC error, got %v", err)
+	}
+	// Checks that don't need the key still run
+	for _, want := range []string{"[FAIL] config", "does not exist yet", "[PASS] relay", "[SKIP] lightning"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	viper.Set("admins", []string{"bogus"})
+	viper.Set("pricing.sats_per_half_dozen", -5)
+	t.Setenv("EGGBOT_NSEC", testNsec)
+	err = runCheck(context.Background(), &bytes.Buffer{}, fx.checker)
+	if err == nil {
+		t.Fatal("expected validation error")
+	}
+	for _, want := range []string{"admins[0]", "pricing.sats_per_half_dozen"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q should report %q", err, want)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// redactedValue replaces sensitive values in dumped config.
+const redactedValue = "***"
+
+// sensitiveKeyParts mark config keys whose values are never printed.
+var sensitiveKeyParts = []string{"nsec", "secret", "password", "token", "private"}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the eggbot configuration",
+}
+
+var configDumpCmd = &cobra.Command{
+	Use:   "dump",
+	Short: "Print the loaded config as YAML with secrets redacted",
+	Long:  `Print the config file and EGGBOT_ environment settings as YAML. Secret values such as nsecs are replaced with "***".`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Fail on the same errors run would, without requiring EGGBOT_NSEC
+		if _, err := config.Load(); err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		return dumpConfig(cmd.OutOrStdout(), viper.AllSettings())
+	},
+}
+
+var configFeaturesCmd = &cobra.Command{
+	Use:   "features",
+	Short: "Show which experimental features are enabled",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		printFeatures(cmd.OutOrStdout(), cfg.Features)
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configDumpCmd)
+	configCmd.AddCommand(configFeaturesCmd)
+	rootCmd.AddCommand(configCmd)
+}
+
+// dumpConfig writes settings as YAML with sensitive values redacted.
+func dumpConfig(w io.Writer, settings map[string]any) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(redactSettings(settings)); err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	return enc.Close()
+}
+
+// redactSettings returns a copy of settings with sensitive values replaced.
+// A value is sensitive if its key looks secret or it is an nsec itself.
+func redactSettings(settings map[string]any) map[string]any {
+	out := make(map[string]any, len(settings))
+	for key, value := range settings {
+		if isSensitiveKey(key) {
+			out[key] = redactedValue
+			continue
+		}
+		out[key] = redactValue(value)
+	}
+	return out
+}
+
+// redactValue redacts nsec strings and recurses into maps and lists.
+func redactValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		return redactSettings(v)
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item)
+		}
+		return out
+	case []string:
+		out := make([]string, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item).(string)
+		}
+		return out
+	case string:
+		if strings.HasPrefix(strings.TrimSpace(v), "nsec1") {
+			return redactedValue
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// isSensitiveKey reports whether a config key names a secret.
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// printFeatures writes one line per feature flag with its config key and state.
+func printFeatures(w io.Writer, features config.Features) {
+	flags := []struct {
+		key     string
+		enabled bool
+	}{
+		{"features.enable_waitlist", features.EnableWaitlist},
+		{"features.enable_nip44", features.EnableNIP44},
+		{"features.enable_order_expiry", features.EnableOrderExpiry},
+		{"features.enable_mentions", features.EnableMentions},
+		{"features.enable_nip05", features.EnableNIP05},
+	}
+	for _, f := range flags {
+		state := "off"
+		if f.enabled {
+			state = "on"
+		}
+		_, _ = fmt.Fprintf(w, "%-31s %s\n", f.key, state)
+	}
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"gopkg.in/yaml.v3"
+)
+
+func TestDumpConfig_RedactsSecrets(t *testing.T) {
+	settings := map[string]any{
+		"verbose": true,
+		"nostr": map[string]any{
+			"relays":     []any{"wss://relay.damus.io"},
+			"bot_npub":   testExpectedNpub,
+			"nsec":       testNsec,
+			"bot_secret": testSecretHex,
+		},
+		"lightning": map[string]any{
+			"address":      "eggs@getalby.com",
+			"api_token":    "hunter2",
+			"stray_values": []string{"ok", testNsec},
+		},
+		"admins": []any{testExpectedNpub},
+	}
+
+	var buf bytes.Buffer
+	if err := dumpConfig(&buf, settings); err != nil {
+		t.Fatalf("dumpConfig: %v", err)
+	}
+	output := buf.String()
+
+	for _, secret := range []string{testNsec, testSecretHex, "hunter2"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("dump leaked %q:\n%s", secret, output)
+		}
+	}
+
+	var got map[string]any
+	if err := yaml.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("dump is not valid YAML: %v\n%s", err, output)
+	}
+	nostr := got["nostr"].(map[string]any)
+	if nostr["nsec"] != redactedValue || nostr["bot_secret"] != redactedValue {
+		t.Errorf("secret keys not redacted: %v", nostr)
+	}
+	if nostr["bot_npub"] != testExpectedNpub {
+		t.Errorf("bot_npub = %v, want it kept", nostr["bot_npub"])
+	}
+	lightning := got["lightning"].(map[string]any)
+	if lightning["address"] != "eggs@getalby.com" {
+		t.Errorf("address = %v, want it kept", lightning["address"])
+	}
+	if strays := lightning["stray_values"].([]any); strays[0] != "ok" || strays[1] != redactedValue {
+		t.Errorf("stray_values = %v, want nsec redacted", strays)
+	}
+}
+
+func TestDumpConfig_DoesNotModifySettings(t *testing.T) {
+	nostr := map[string]any{"nsec": testNsec}
+	settings := map[string]any{"nostr": nostr}
+
+	var buf bytes.Buffer
+	if err := dumpConfig(&buf, settings); err != nil {
+		t.Fatalf("dumpConfig: %v", err)
+	}
+	if nostr["nsec"] != testNsec {
+		t.Errorf("input settings were modified: %v", nostr)
+	}
+}
+
+func TestPrintFeatures(t *testing.T) {
+	var buf bytes.Buffer
+	printFeatures(&buf, config.Features{EnableNIP44: true})
+
+	want := []string{
+		"features.enable_waitlist        off",
+		"features.enable_nip44           on",
+		"features.enable_order_expiry    off",
+		"features.enable_mentions        off",
+		"features.enable_nip05           off",
+	}
+	if got := strings.Split(strings.TrimSpace(buf.String()), "\n"); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("printFeatures output:\n%s\nwant:\n%s", buf.String(), strings.Join(want, "\n"))
+	}
+}
@@ -0,0 +1,115 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// customerTimeFormat is how registration times are printed.
+const customerTimeFormat = "2006-01-02 15:04:05"
+
+var customersCmd = &cobra.Command{
+	Use:   "customers",
+	Short: "List and inspect registered customers without the bot running",
+}
+
+var customersListCmd = &cobra.Command{
+	Use:          "list",
+	Short:        "List registered customers a page at a time",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		page, _ := cmd.Flags().GetInt("page")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if page < 1 || limit < 1 {
+			return errors.New("--page and --limit must be at least 1")
+		}
+
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		return runCustomersList(cmd.Context(), database, page, limit, cmd.OutOrStdout())
+	},
+}
+
+var customersShowCmd = &cobra.Command{
+	Use:          "show <npub>",
+	Short:        "Show a customer's order history totals",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		return runCustomersShow(cmd.Context(), database, args[0], cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	customersListCmd.Flags().Int("page", 1, "page to print, starting at 1")
+	customersListCmd.Flags().Int("limit", 20, "customers per page")
+	customersCmd.AddCommand(customersListCmd, customersShowCmd)
+	rootCmd.AddCommand(customersCmd)
+}
+
+// runCustomersList prints one page of customers as a table.
+func runCustomersList(ctx context.Context, database *db.DB, page, limit int, out io.Writer) error {
+	customers, err := database.GetCustomersByOffset(ctx, limit, (page-1)*limit)
+	if err != nil {
+		return err
+	}
+	if len(customers) == 0 {
+		_, _ = fmt.Fprintf(out, "No customers on page %d.\n", page)
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "ID\tNPUB\tNAME\tCREATED")
+	for _, c := range customers {
+		_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", c.ID, c.Npub, c.Name.String, c.CreatedAt.Local().Format(customerTimeFormat))
+	}
+	return tw.Flush()
+}
+
+// runCustomersShow prints a customer's details and order totals.
+func runCustomersShow(ctx context.Context, database *db.DB, npub string, out io.Writer) error {
+	customer, err := database.GetCustomerByNpub(ctx, npub)
+	if errors.Is(err, db.ErrCustomerNotFound) {
+		return fmt.Errorf("%s is not a registered customer", npub)
+	}
+	if err != nil {
+		return err
+	}
+	stats, err := database.GetCustomerStats(ctx, customer.ID)
+	if err != nil {
+		return err
+	}
+
+	name := customer.Name.String
+	if name == "" {
+		name = "-"
+	}
+	_, _ = fmt.Fprintf(out, "ID:         %d\n", customer.ID)
+	_, _ = fmt.Fprintf(out, "npub:       %s\n", customer.Npub)
+	_, _ = fmt.Fprintf(out, "Name:       %s\n", name)
+	_, _ = fmt.Fprintf(out, "Registered: %s\n", customer.CreatedAt.Local().Format(customerTimeFormat))
+	_, _ = fmt.Fprintf(out, "Orders:     %d (%d pending, %d fulfilled)\n", stats.Orders, stats.Pending, stats.Fulfilled)
+	_, _ = fmt.Fprintf(out, "Delivered:  %d eggs for %d sats\n", stats.EggsDelivered, stats.SatsSpent)
+	return nil
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// seedCustomers registers three customers, naming the first, and returns them.
+func seedCustomers(t *testing.T, database *db.DB) []testSender {
+	t.Helper()
+	ctx := context.Background()
+	var customers []testSender
+	for range 3 {
+		customer := newTestSender(t)
+		if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+			t.Fatalf("creating customer: %v", err)
+		}
+		customers = append(customers, customer)
+	}
+	if err := database.UpdateCustomerName(ctx, customers[0].npub, "Alice"); err != nil {
+		t.Fatalf("naming customer: %v", err)
+	}
+	return customers
+}
+
+func TestRunCustomersList(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	customers := seedCustomers(t, database)
+
+	var out bytes.Buffer
+	if err := runCustomersList(ctx, database, 1, 20, &out); err != nil {
+		t.Fatalf("runCustomersList: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "ID") {
+		t.Fatalf("expected a header and three rows:\n%s", out.String())
+	}
+	for i, customer := range customers {
+		if !strings.Contains(lines[i+1], customer.npub) {
+			t.Errorf("row %d = %q, want %s", i+1, lines[i+1], customer.npub)
+		}
+	}
+	if !strings.Contains(lines[1], "Alice") {
+		t.Errorf("first row should include the name: %q", lines[1])
+	}
+}
+
+func TestRunCustomersList_Paging(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	customers := seedCustomers(t, database)
+
+	var out bytes.Buffer
+	if err := runCustomersList(ctx, database, 2, 2, &out); err != nil {
+		t.Fatalf("runCustomersList: %v", err)
+	}
+	if !strings.Contains(out.String(), customers[2].npub) || strings.Contains(out.String(), customers[0].npub) {
+		t.Errorf("page 2 should hold only the third customer:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runCustomersList(ctx, database, 3, 2, &out); err != nil {
+		t.Fatalf("runCustomersList: %v", err)
+	}
+	if out.String() != "No customers on page 3.\n" {
+		t.Errorf("output = %q", out.String())
+	}
+}
+
+func TestRunCustomersShow(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	customer := seedCustomers(t, database)[0]
+
+	registered, _ := database.GetCustomerByNpub(ctx, customer.npub)
+	_ = database.AddEggs(ctx, 12)
+	if _, err := database.CreateOrder(ctx, registered.ID, 6, 3200); err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runCustomersShow(ctx, database, customer.npub, &out); err != nil {
+		t.Fatalf("runCustomersShow: %v", err)
+	}
+	for _, want := range []string{"Name:       Alice", "Orders:     1 (1 pending, 0 fulfilled)", "Delivered:  0 eggs for 0 sats"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output missing %q:\n%s", want, out.String())
+		}
+	}
+
+	stranger := newTestSender(t)
+	if err := runCustomersShow(ctx, database, stranger.npub, &out); err == nil || !strings.Contains(err.Error(), "not a registered customer") {
+		t.Errorf("expected an unregistered error, got %v", err)
+	}
+}
@@ -0,0 +1,200 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// backupTimestampFormat names default backup files, e.g. eggbot-backup-20250601-030000.db.
+const backupTimestampFormat = "20060102-150405"
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Manage the database: backups and schema migrations",
+}
+
+var dbBackupCmd = &cobra.Command{
+	Use:   "backup [path]",
+	Short: "Write a consistent snapshot of the database",
+	Long: `Write a consistent snapshot of the database to path with VACUUM INTO. This is
+safe while the bot is running, unlike copying the file in WAL mode. Without a
+path, the copy is written to eggbot-backup-<timestamp>.db in the current
+directory. Missing directories are created; an existing file is only replaced
+with --force. --online copies pages as they are with SQLite's online backup API
+instead of rebuilding the file, and --verify runs an integrity check on the
+copy.`,
+	Args:         cobra.MaximumNArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		backupPath := defaultBackupPath(time.Now())
+		if len(args) == 1 {
+			backupPath = args[0]
+		}
+		force, _ := cmd.Flags().GetBool("force")
+		online, _ := cmd.Flags().GetBool("online")
+		verify, _ := cmd.Flags().GetBool("verify")
+
+		database, path, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+
+		if online {
+			pages, err := database.OnlineBackup(cmd.Context(), backupPath, force)
+			if err != nil {
+				return err
+			}
+			slog.Info("database backed up", "path", backupPath, "pages", pages)
+		} else if err := database.Backup(cmd.Context(), backupPath, force); err != nil {
+			return err
+		}
+
+		if verify {
+			if err := db.VerifyIntegrity(cmd.Context(), backupPath); err != nil {
+				return fmt.Errorf("verifying %s: %w", backupPath, err)
+			}
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Backed up %s to %s\n", path, backupPath)
+		return nil
+	},
+}
+
+var dbRestoreCmd = &cobra.Command{
+	Use:   "restore <path>",
+	Short: "Replace the database with a snapshot",
+	Long: `Check that the snapshot at path is a fully migrated eggbot database, then put it
+in place of the configured database. The current database is kept with a .bak
+suffix. Stop the bot before restoring.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		if err := db.Restore(cmd.Context(), args[0], cfg.Database.Path); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Restored %s from %s (previous database kept as %s.bak)\n",
+			cfg.Database.Path, args[0], cfg.Database.Path)
+		return nil
+	},
+}
+
+var dbStatusCmd = &cobra.Command{
+	Use:          "status",
+	Short:        "List applied and pending schema migrations",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+
+		migrations, err := database.MigrationStatus(cmd.Context())
+		if err != nil {
+			return err
+		}
+		printMigrationStatus(cmd.OutOrStdout(), migrations)
+		return nil
+	},
+}
+
+var dbUpCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Apply pending schema migrations",
+	Long: `Apply pending schema migrations. "run" does this automatically at startup.
+With --dry-run, print their SQL without changing the database.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withConfiguredDB(cmd, runMigrateUp)
+	},
+}
+
+var dbDownToCmd = &cobra.Command{
+	Use:   "down-to <version>",
+	Short: "Roll back schema migrations newer than version",
+	Long: `Roll back every migration newer than version, newest first. Rolling back drops
+the tables and columns those migrations added, with their data. Stop the bot and
+take a backup first; "run" re-applies the migrations on its next start.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("version must be a migration number, got %q", args[0])
+		}
+
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+
+		rolledBack, err := database.MigrateDownTo(cmd.Context(), version)
+		if err != nil {
+			return err
+		}
+		printMigrations(cmd.OutOrStdout(), "Rolled back", rolledBack)
+		return nil
+	},
+}
+
+func init() {
+	dbBackupCmd.Flags().Bool("force", false, "overwrite an existing backup file")
+	dbBackupCmd.Flags().Bool("online", false, "copy with SQLite's online backup API instead of VACUUM INTO")
+	dbBackupCmd.Flags().Bool("verify", false, "run an integrity check on the backup")
+	dbCmd.AddCommand(dbBackupCmd, dbRestoreCmd, dbStatusCmd, dbUpCmd, dbDownToCmd)
+	rootCmd.AddCommand(dbCmd)
+}
+
+// defaultBackupPath returns the backup file name used when no path is given.
+func defaultBackupPath(now time.Time) string {
+	return "eggbot-backup-" + now.Format(backupTimestampFormat) + ".db"
+}
+
+// openConfiguredDB opens the database named in the config, returning its path.
+func openConfiguredDB() (*db.DB, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, "", fmt.Errorf("loading config: %w", err)
+	}
+	database, err := db.Open(cfg.Database.Path)
+	if err != nil {
+		return nil, "", err
+	}
+	return database, cfg.Database.Path, nil
+}
+
+// printMigrationStatus writes one line per migration with its state.
+func printMigrationStatus(w io.Writer, migrations []db.Migration) {
+	for _, m := range migrations {
+		if m.Applied {
+			_, _ = fmt.Fprintf(w, "applied  %s  %s\n", m.Name, m.AppliedAt.Local().Format("2006-01-02 15:04:05"))
+		} else {
+			_, _ = fmt.Fprintf(w, "pending  %s\n", m.Name)
+		}
+	}
+}
+
+// printMigrations writes the migrations an up or down-to run changed.
+func printMigrations(w io.Writer, verb string, migrations []db.Migration) {
+	if len(migrations) == 0 {
+		_, _ = fmt.Fprintln(w, "No migrations to run")
+		return
+	}
+	for _, m := range migrations {
+		_, _ = fmt.Fprintf(w, "%s %s\n", verb, m.Name)
+	}
+}
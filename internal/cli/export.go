@@ -0,0 +1,222 @@
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// exportDateLayout is the format of --from and --to.
+const exportDateLayout = "2006-01-02"
+
+// exportStatuses are the accepted --status values; "all" exports every order.
+var exportStatuses = []string{"all", "pending", "paid", "fulfilled", "cancelled"}
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Write orders or transactions as CSV for bookkeeping",
+	Long: `Write orders or transactions as CSV, oldest first. --from and --to pick a
+range of creation dates in UTC, both inclusive, and either may be left out.
+Times in the output are UTC (RFC 3339). --out - (the default) writes to
+standard output; otherwise an existing file is replaced.`,
+}
+
+var exportOrdersCmd = &cobra.Command{
+	Use:   "orders",
+	Short: "Write orders, archived ones included, as CSV",
+	Long: `Write orders, archived ones included, as CSV with the columns
+id,customer_npub,customer_name,quantity,total_sats,status,created_at,paid_at,fulfilled_at.
+paid_at and fulfilled_at are empty until the order gets there, and paid_at is
+also empty for orders fulfilled before payment times were recorded. --status
+limits the export to orders in one status.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		status, _ := cmd.Flags().GetString("status")
+		if !slices.Contains(exportStatuses, status) {
+			return fmt.Errorf("--status must be one of %v, got %q", exportStatuses, status)
+		}
+		if status == "all" {
+			status = ""
+		}
+		return runExportCommand(cmd, "orders", func(ctx context.Context, database *db.DB, from, to *time.Time, w io.Writer) (int, error) {
+			return runExportOrders(ctx, database, db.OrderFilter{Status: status, FromTime: from, ToTime: to}, w)
+		})
+	},
+}
+
+var exportTransactionsCmd = &cobra.Command{
+	Use:   "transactions",
+	Short: "Write the payment ledger as CSV",
+	Long: `Write the payment ledger as CSV with the columns
+id,zap_event_id,amount_sats,sender_npub,order_id,created_at. order_id is empty
+for payments not matched to an order.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runExportCommand(cmd, "transactions", runExportTransactions)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{exportOrdersCmd, exportTransactionsCmd} {
+		c.Flags().String("from", "", "first creation date to export (YYYY-MM-DD, UTC)")
+		c.Flags().String("to", "", "last creation date to export (YYYY-MM-DD, UTC)")
+		c.Flags().StringP("out", "o", "-", `CSV file to write, or "-" for standard output`)
+		exportCmd.AddCommand(c)
+	}
+	exportOrdersCmd.Flags().String("status", "all", "only export orders in this status: all, pending, paid, fulfilled or cancelled")
+	rootCmd.AddCommand(exportCmd)
+}
+
+// exportFunc writes the rows created in [from, to) as CSV and returns how
+// many were written.
+type exportFunc func(ctx context.Context, database *db.DB, from, to *time.Time, w io.Writer) (int, error)
+
+// runExportCommand parses the shared export flags, opens the output and the
+// database and runs export. The row count goes to stderr when the CSV is on
+// stdout so the two don't mix.
+func runExportCommand(cmd *cobra.Command, what string, export exportFunc) error {
+	fromFlag, _ := cmd.Flags().GetString("from")
+	toFlag, _ := cmd.Flags().GetString("to")
+	out, _ := cmd.Flags().GetString("out")
+	from, to, err := parseExportRange(fromFlag, toFlag)
+	if err != nil {
+		return err
+	}
+
+	database, _, err := openConfiguredDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = database.Close() }()
+	if err := database.Migrate(); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+
+	if out == "-" {
+		n, err := export(cmd.Context(), database, from, to, cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d %s\n", n, what)
+		return nil
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", out, err)
+	}
+	n, err := export(cmd.Context(), database, from, to, f)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing %s: %w", out, closeErr)
+	}
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.OutOrStdout(), "Exported %d %s to %s\n", n, what, out)
+	return nil
+}
+
+// parseExportRange turns the inclusive --from and --to dates into a half-open
+// time range. Empty flags leave that end open.
+func parseExportRange(fromFlag, toFlag string) (from, to *time.Time, err error) {
+	if fromFlag != "" {
+		t, err := time.Parse(exportDateLayout, fromFlag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--from must be a date like 2024-01-31, got %q", fromFlag)
+		}
+		from = &t
+	}
+	if toFlag != "" {
+		t, err := time.Parse(exportDateLayout, toFlag)
+		if err != nil {
+			return nil, nil, fmt.Errorf("--to must be a date like 2024-12-31, got %q", toFlag)
+		}
+		t = t.AddDate(0, 0, 1)
+		to = &t
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("--from %s is after --to %s", fromFlag, toFlag)
+	}
+	return from, to, nil
+}
+
+// runExportOrders writes the orders matching f as CSV.
+func runExportOrders(ctx context.Context, database *db.DB, f db.OrderFilter, w io.Writer) (int, error) {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "customer_npub", "customer_name", "quantity", "total_sats", "status",
+		"created_at", "paid_at", "fulfilled_at"})
+	n := 0
+	err := database.ExportOrders(ctx, f, func(o db.OrderExport) error {
+		n++
+		return cw.Write([]string{
+			strconv.FormatInt(o.ID, 10),
+			o.CustomerNpub,
+			o.CustomerName.String,
+			strconv.Itoa(o.Quantity),
+			strconv.FormatInt(o.TotalSats, 10),
+			o.Status,
+			formatExportTime(o.CreatedAt),
+			formatExportNullTime(o.PaidAt),
+			formatExportNullTime(o.FulfilledAt),
+		})
+	})
+	return finishExport(cw, n, err)
+}
+
+// runExportTransactions writes the transactions created in [from, to) as CSV.
+func runExportTransactions(ctx context.Context, database *db.DB, from, to *time.Time, w io.Writer) (int, error) {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "zap_event_id", "amount_sats", "sender_npub", "order_id", "created_at"})
+	n := 0
+	err := database.ExportTransactions(ctx, from, to, func(t db.Transaction) error {
+		n++
+		orderID := ""
+		if t.OrderID.Valid {
+			orderID = strconv.FormatInt(t.OrderID.Int64, 10)
+		}
+		return cw.Write([]string{
+			strconv.FormatInt(t.ID, 10),
+			t.ZapEventID,
+			strconv.FormatInt(t.AmountSats, 10),
+			t.SenderNpub,
+			orderID,
+			formatExportTime(t.CreatedAt),
+		})
+	})
+	return finishExport(cw, n, err)
+}
+
+// finishExport flushes cw and reports the first error from the export or
+// the writer.
+func finishExport(cw *csv.Writer, n int, err error) (int, error) {
+	cw.Flush()
+	if err != nil {
+		return 0, err
+	}
+	if err := cw.Error(); err != nil {
+		return 0, fmt.Errorf("writing CSV: %w", err)
+	}
+	return n, nil
+}
+
+func formatExportTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatExportNullTime(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return formatExportTime(t.Time)
+}
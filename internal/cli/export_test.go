@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+const (
+	exportAliceNpub = "npub1alice"
+	exportBobNpub   = "npub1bob"
+	exportZapID     = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+)
+
+// seedExportDB builds a fixture with one order per status, an archived order
+// and two transactions, all at fixed times.
+func seedExportDB(t *testing.T) *db.DB {
+	t.Helper()
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+
+	alice, err := database.CreateCustomer(ctx, exportAliceNpub, "")
+	if err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	bob, err := database.CreateCustomer(ctx, exportBobNpub, "")
+	if err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.UpdateCustomerName(ctx, exportAliceNpub, `Alice "Hen" Smith, Esq.`); err != nil {
+		t.Fatalf("naming customer: %v", err)
+	}
+	_ = database.AddEggs(ctx, 30)
+	for _, customerID := range []int64{alice.ID, bob.ID, alice.ID, bob.ID} {
+		if _, err := database.CreateOrder(ctx, customerID, 6, 1000); err != nil {
+			t.Fatalf("creating order: %v", err)
+		}
+	}
+	if _, err := database.RecordTransaction(ctx, nil, exportZapID, db.TransactionSourceZap, 1000, exportAliceNpub); err != nil {
+		t.Fatalf("recording transaction: %v", err)
+	}
+	orderID := int64(2)
+	if _, err := database.RecordTransaction(ctx, &orderID, "adjust-2", db.TransactionSourceAdjustment, 1000, exportBobNpub); err != nil {
+		t.Fatalf("recording transaction: %v", err)
+	}
+
+	// Order 1 fulfilled in January, 2 paid in June, 3 cancelled in December,
+	// 4 pending on New Year's Day; order 5 was fulfilled and archived in 2023
+	for _, stmt := range []string{
+		`UPDATE orders SET status = 'fulfilled', created_at = '2024-01-15 09:30:00',
+			paid_at = '2024-01-15 10:00:00', fulfilled_at = '2024-01-16 08:00:00' WHERE id = 1`,
+		`UPDATE orders SET status = 'paid', created_at = '2024-06-01 12:00:00',
+			paid_at = '2024-06-01 12:05:00' WHERE id = 2`,
+		`UPDATE orders SET status = 'cancelled', created_at = '2024-12-31 23:59:59' WHERE id = 3`,
+		`UPDATE orders SET created_at = '2025-01-01 00:00:00' WHERE id = 4`,
+		`INSERT INTO orders_archive (id, customer_id, quantity, total_sats, status, created_at, updated_at, fulfilled_at)
+			VALUES (5, 2, 12, 2000, 'fulfilled', '2023-11-05 07:00:00', '2023-11-06 07:00:00', '2023-11-06 07:00:00')`,
+		`UPDATE transactions SET created_at = '2024-01-15 10:00:00' WHERE id = 1`,
+		`UPDATE transactions SET created_at = '2025-02-01 00:00:00' WHERE id = 2`,
+	} {
+		if _, err := database.ExecContext(ctx, stmt); err != nil {
+			t.Fatalf("seeding fixture: %v", err)
+		}
+	}
+	return database
+}
+
+func TestRunExportOrders(t *testing.T) {
+	database := seedExportDB(t)
+	const header = "id,customer_npub,customer_name,quantity,total_sats,status,created_at,paid_at,fulfilled_at\n"
+	rows := map[int]string{
+		1: `1,npub1alice,"Alice ""Hen"" Smith, Esq.",6,1000,fulfilled,2024-01-15T09:30:00Z,2024-01-15T10:00:00Z,2024-01-16T08:00:00Z` + "\n",
+		2: "2,npub1bob,,6,1000,paid,2024-06-01T12:00:00Z,2024-06-01T12:05:00Z,\n",
+		3: `3,npub1alice,"Alice ""Hen"" Smith, Esq.",6,1000,cancelled,2024-12-31T23:59:59Z,,` + "\n",
+		4: "4,npub1bob,,6,1000,pending,2025-01-01T00:00:00Z,,\n",
+		5: "5,npub1bob,,12,2000,fulfilled,2023-11-05T07:00:00Z,,2023-11-06T07:00:00Z\n",
+	}
+
+	tests := []struct {
+		name     string
+		from, to string
+		status   string
+		wantIDs  []int
+	}{
+		{"everything", "", "", "", []int{1, 2, 3, 4, 5}},
+		{"one year, last day inclusive", "2024-01-01", "2024-12-31", "", []int{1, 2, 3}},
+		{"from only", "2024-06-01", "", "", []int{2, 3, 4}},
+		{"to only", "", "2024-01-15", "", []int{1, 5}},
+		{"nothing in range", "2022-01-01", "2022-12-31", "", nil},
+		{"fulfilled, archived included", "", "", "fulfilled", []int{1, 5}},
+		{"pending in range", "2024-01-01", "2024-12-31", "pending", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to, err := parseExportRange(tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("parseExportRange: %v", err)
+			}
+			var out bytes.Buffer
+			n, err := runExportOrders(context.Background(), database, db.OrderFilter{Status: tt.status, FromTime: from, ToTime: to}, &out)
+			if err != nil {
+				t.Fatalf("runExportOrders: %v", err)
+			}
+			want := header
+			for _, id := range tt.wantIDs {
+				want += rows[id]
+			}
+			if n != len(tt.wantIDs) {
+				t.Errorf("exported %d orders, want %d", n, len(tt.wantIDs))
+			}
+			if out.String() != want {
+				t.Errorf("CSV mismatch\ngot:\n%s\nwant:\n%s", out.String(), want)
+			}
+		})
+	}
+}
+
+func TestRunExportTransactions(t *testing.T) {
+	database := seedExportDB(t)
+	from, to, err := parseExportRange("2024-01-01", "2024-12-31")
+	if err != nil {
+		t.Fatalf("parseExportRange: %v", err)
+	}
+
+	var out bytes.Buffer
+	n, err := runExportTransactions(context.Background(), database, from, to, &out)
+	if err != nil {
+		t.Fatalf("runExportTransactions: %v", err)
+	}
+	want := "id,zap_event_id,amount_sats,sender_npub,order_id,created_at\n" +
+		"1," + exportZapID + ",1000,npub1alice,,2024-01-15T10:00:00Z\n"
+	if n != 1 || out.String() != want {
+		t.Errorf("exported %d\ngot:\n%s\nwant:\n%s", n, out.String(), want)
+	}
+
+	out.Reset()
+	if _, err := runExportTransactions(context.Background(), database, nil, nil, &out); err != nil {
+		t.Fatalf("runExportTransactions: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "2,adjust-2,1000,npub1bob,2,2025-02-01T00:00:00Z\n") {
+		t.Errorf("expected the order-linked transaction last:\n%s", out.String())
+	}
+}
+
+func TestParseExportRange_Invalid(t *testing.T) {
+	tests := []struct {
+		from, to, wantErr string
+	}{
+		{"2024/01/01", "", "--from must be a date"},
+		{"", "tomorrow", "--to must be a date"},
+		{"2024-02-01", "2024-01-01", "is after --to"},
+	}
+	for _, tt := range tests {
+		if _, _, err := parseExportRange(tt.from, tt.to); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("parseExportRange(%q, %q) = %v, want %q", tt.from, tt.to, err, tt.wantErr)
+		}
+	}
+}
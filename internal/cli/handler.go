@@ -0,0 +1,612 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/buildtall-systems/eggbot/internal/fsm"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+	"github.com/buildtall-systems/eggbot/internal/nostr"
+	"github.com/buildtall-systems/eggbot/internal/zaps"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// replySender delivers a DM to a hex pubkey over protocol, threaded under
+// replyTo when it is not empty.
+type replySender func(recipientPubkeyHex, message, replyTo string, protocol dm.DMProtocol)
+
+// EventHandler takes a new DM or zap receipt through decryption or
+// validation, permission checks and commands, and publishes the replies.
+// Each event is handled with the config current when it arrives, so a
+// reload never changes settings part way through an event.
+type EventHandler struct {
+	database  *db.DB
+	kr        gonostr.Keyer
+	config    func() *config.Config
+	publisher nostr.Publisher
+	roles     *commands.RoleCache
+	processor *fsm.EventProcessorFSM
+
+	announcements *announcements // When the inventory was last announced
+
+	relays func() []nostr.RelayStatus // Shown by the config command; nil when unknown
+
+	send  replySender               // Replaces publishing when set, as in replay and simulate
+	trace func(step, detail string) // Reports each intermediate result; nil in run
+}
+
+// NewEventHandler returns a handler that reads the current config from cfg
+// and publishes replies signed by kr through publisher.
+func NewEventHandler(database *db.DB, kr gonostr.Keyer, cfg func() *config.Config, publisher nostr.Publisher, roles *commands.RoleCache) *EventHandler {
+	return &EventHandler{
+		database:  database,
+		kr:        kr,
+		config:    cfg,
+		publisher: publisher,
+		roles:     roles,
+		processor: fsm.NewEventProcessorFSM(),
+
+		announcements: &announcements{now: time.Now},
+	}
+}
+
+// note reports an intermediate result to the trace, if any.
+func (h *EventHandler) note(step, detail string) {
+	if h.trace != nil {
+		h.trace(step, detail)
+	}
+}
+
+// reply sends a DM with the event's config snapshot. subject titles the
+// conversation for NIP-17 clients; pass "" for none.
+func (h *EventHandler) reply(ctx context.Context, cfg *config.Config, recipientPubkeyHex, message, replyTo, subject string, protocol dm.DMProtocol) {
+	if h.send != nil {
+		h.send(recipientPubkeyHex, message, replyTo, protocol)
+		return
+	}
+	h.publish(ctx, cfg, recipientPubkeyHex, message, replyTo, subject, protocol)
+}
+
+// announcer returns the inventory announcer for the config snapshot, or nil
+// when announce.mode is off.
+func (h *EventHandler) announcer(cfg *config.Config) commands.Announcer {
+	if cfg.Announce.Mode == config.AnnounceOff {
+		return nil
+	}
+	return inventoryAnnouncer{
+		state: h.announcements,
+		cfg:   cfg.Announce,
+		kr:    h.kr,
+		publish: func(ctx context.Context, event *gonostr.Event) error {
+			// Replays report the announcement instead of publishing it
+			if h.send != nil {
+				h.note("announce", event.Content)
+				return nil
+			}
+			return h.publisher.Publish(ctx, event)
+		},
+	}
+}
+
+// notifier returns a dmSender for unsolicited NIP-04 DMs, as used for admin
+// and customer notifications.
+func (h *EventHandler) notifier(ctx context.Context, cfg *config.Config) dmSender {
+	return func(recipientPubkeyHex, message string) {
+		h.reply(ctx, cfg, recipientPubkeyHex, message, "", "", dm.ProtocolNIP04)
+	}
+}
+
+// HandleDM decrypts a kind:4 or kind:1059 DM and runs the command in it.
+func (h *EventHandler) HandleDM(ctx context.Context, event *gonostr.Event) {
+	cfg := h.config()
+
+	// Transition FSM to processing DM state
+	if err := h.processor.Event(ctx, fsm.ProcessorEventDMReceived); err != nil {
+		slog.Error("FSM error on DM received", "event_id", event.ID, "error", err)
+		h.processor.Reset()
+		return
+	}
+	// Every path below leaves the FSM idle for the next event
+	defer h.processor.Reset()
+
+	// Decrypt DM based on kind
+	var senderPubkey, messageContent, replyTo string
+	var incomingProtocol dm.DMProtocol
+
+	switch event.Kind {
+	case gonostr.KindEncryptedDirectMessage: // Legacy kind:4 DM (NIP-04, or NIP-44 from newer clients)
+		var err error
+		messageContent, incomingProtocol, err = dm.DecryptLegacy(ctx, h.kr, cfg.Nostr.BotSecretHex, event, cfg.Features.EnableNIP44)
+		if err != nil {
+			slog.Warn("failed to decrypt kind:4 DM", "event_id", event.ID, "error", err)
+			h.note("decrypt", err.Error())
+			return
+		}
+		senderPubkey = event.PubKey
+		replyTo = event.ID
+
+	case gonostr.KindGiftWrap: // NIP-17 gift-wrapped DM
+		incomingProtocol = dm.ProtocolNIP17
+		rumor, err := nip59.GiftUnwrap(*event, func(pubkey, ciphertext string) (string, error) {
+			return h.kr.Decrypt(ctx, ciphertext, pubkey)
+		})
+		if err != nil {
+			slog.Warn("failed to unwrap DM", "event_id", event.ID, "error", err)
+			h.note("decrypt", err.Error())
+			return
+		}
+		senderPubkey = rumor.PubKey
+		messageContent = rumor.Content
+		replyTo = rumor.ID
+
+	default:
+		slog.Warn("unexpected DM kind", "event_id", event.ID, "kind", event.Kind)
+		h.note("decrypt", fmt.Sprintf("unexpected DM kind %d", event.Kind))
+		return
+	}
+
+	h.handleDecryptedDM(ctx, cfg, event, senderPubkey, messageContent, replyTo, incomingProtocol)
+}
+
+// handleDecryptedDM runs the command in a DM's plain text from senderPubkey,
+// answering over protocol under replyTo. The FSM must have received the DM.
+func (h *EventHandler) handleDecryptedDM(ctx context.Context, cfg *config.Config, event *gonostr.Event, senderPubkey, messageContent, replyTo string, incomingProtocol dm.DMProtocol) {
+	// Convert sender hex pubkey to npub for display
+	senderNpub, _ := nip19.EncodePublicKey(senderPubkey)
+	h.note("decrypted", fmt.Sprintf("%s from %s: %q", protocolName(incomingProtocol), senderNpub, messageContent))
+
+	send := func(message, subject string) {
+		h.reply(ctx, cfg, senderPubkey, message, replyTo, subject, incomingProtocol)
+	}
+
+	if h.isBlocked(ctx, event, senderNpub) {
+		return
+	}
+
+	// Decrypted content is private: only the parsed command is logged by default
+	slog.Info("DM received", "event_id", event.ID, "sender", senderNpub)
+	logContent(cfg, "DM content", "event_id", event.ID, "sender", senderNpub, "content", messageContent)
+
+	msgs := commands.MessagesFor(ctx, h.database, senderNpub, cfg.Messages.Catalog)
+	h.handleMessage(ctx, cfg, event, senderNpub, messageContent, msgs, send)
+}
+
+// HandleMention answers a public note that tags the bot with a customer
+// command. The command is never answered publicly: the author is told the
+// conversation continues by DM, then the command runs as if it had been sent
+// privately. Notes without a customer command are ignored.
+func (h *EventHandler) HandleMention(ctx context.Context, event *gonostr.Event) {
+	cfg := h.config()
+	if !cfg.Features.EnableMentions {
+		h.note("mention", "features.enable_mentions is off; ignored")
+		return
+	}
+	if event.PubKey == cfg.Nostr.BotPubkeyHex {
+		return
+	}
+
+	content := mentionCommand(event.Content)
+	if parsedCmd := commands.Parse(content); parsedCmd == nil || !parsedCmd.IsValid() || parsedCmd.IsAdminCommand() {
+		slog.Debug("mention without a customer command, ignoring", "event_id", event.ID)
+		h.note("command", "none (not a customer command)")
+		return
+	}
+
+	// A mention carrying a command is handled as a DM from its author
+	if err := h.processor.Event(ctx, fsm.ProcessorEventDMReceived); err != nil {
+		slog.Error("FSM error on mention received", "event_id", event.ID, "error", err)
+		h.processor.Reset()
+		return
+	}
+	defer h.processor.Reset()
+
+	senderNpub, _ := nip19.EncodePublicKey(event.PubKey)
+	h.note("mention", fmt.Sprintf("from %s: %q", senderNpub, event.Content))
+	if h.isBlocked(ctx, event, senderNpub) {
+		return
+	}
+	slog.Info("mention received", "event_id", event.ID, "sender", senderNpub)
+
+	// Replies go out as unsolicited DMs, not threaded under the public note
+	send := func(message, subject string) {
+		h.reply(ctx, cfg, event.PubKey, message, "", subject, dm.ProtocolNIP04)
+	}
+	msgs := commands.MessagesFor(ctx, h.database, senderNpub, cfg.Messages.Catalog)
+	send(msgs.Render(messages.MentionAck, nil), "")
+	h.handleMessage(ctx, cfg, event, senderNpub, content, msgs, send)
+}
+
+// mentionCommand returns the text of a note without references to profiles
+// ("nostr:npub1...", "nostr:nprofile1...") and without the "@name" handles
+// clients put before it when tagging someone.
+func mentionCommand(content string) string {
+	var kept []string
+	for _, field := range strings.Fields(content) {
+		lower := strings.ToLower(field)
+		if strings.HasPrefix(lower, "nostr:npub1") || strings.HasPrefix(lower, "nostr:nprofile1") {
+			continue
+		}
+		if len(kept) == 0 && strings.HasPrefix(field, "@") {
+			continue
+		}
+		kept = append(kept, field)
+	}
+	return strings.Join(kept, " ")
+}
+
+// isBlocked reports whether an event's sender is blocked, in which case the
+// event is dropped without a reply. A failed check lets the event through.
+func (h *EventHandler) isBlocked(ctx context.Context, event *gonostr.Event, senderNpub string) bool {
+	blocked, err := h.database.IsBlocked(ctx, senderNpub)
+	if err != nil {
+		slog.Error("blocklist check failed", "event_id", event.ID, "sender", senderNpub, "error", err)
+		return false
+	}
+	if blocked {
+		slog.Info("dropping event from blocked sender", "event_id", event.ID, "sender", senderNpub)
+		h.note("blocked", senderNpub+" is blocked; no reply")
+	}
+	return blocked
+}
+
+// handleMessage runs the command in a message from senderNpub, answering
+// through send with the reply and its conversation subject. msgs is the
+// catalog in the sender's language.
+func (h *EventHandler) handleMessage(ctx context.Context, cfg *config.Config, event *gonostr.Event, senderNpub, messageContent string, msgs *messages.Catalog, send func(message, subject string)) {
+	notify := h.notifier(ctx, cfg)
+	reply := func(message string) { send(message, "") }
+
+	// Check for admin broadcast command (special syntax, handled before normal parsing)
+	if broadcastMsg, isBroadcast := parseBroadcast(messageContent); isBroadcast {
+		h.note("command", "broadcast")
+		if !commands.IsAdmin(ctx, h.database, senderNpub) {
+			reply("Permission denied: broadcast requires admin privileges")
+			return
+		}
+		if broadcastMsg == "" {
+			reply("Usage: message customers: <your message>")
+			return
+		}
+
+		slog.Info("admin broadcasting", "event_id", event.ID, "sender", senderNpub)
+		logContent(cfg, "broadcast content", "event_id", event.ID, "content", broadcastMsg)
+		sent, failed := broadcastToCustomers(ctx, h.database, notify, broadcastMessage(cfg, broadcastMsg))
+
+		summary := fmt.Sprintf("Broadcast sent to %d customers", sent)
+		if failed > 0 {
+			summary += fmt.Sprintf(" (%d failed)", failed)
+		}
+		reply(summary)
+		return
+	}
+
+	// Parse command from message
+	parsedCmd := commands.Parse(messageContent)
+	if parsedCmd == nil {
+		slog.Debug("empty message, ignoring", "event_id", event.ID, "sender", senderNpub)
+		h.note("command", "none (empty message)")
+		return
+	}
+	h.note("command", strings.TrimSpace(parsedCmd.Name+" "+strings.Join(parsedCmd.Args, " ")))
+
+	execCfg := newExecuteConfig(cfg, h.roles, h.announcer(cfg), h.relays)
+	execCfg.Replies = msgs
+
+	// Commands whose feature is off are unknown
+	if !parsedCmd.IsValid() || !execCfg.Enabled(parsedCmd.Name) {
+		slog.Info("unknown command", "event_id", event.ID, "sender", senderNpub, "command", parsedCmd.Name)
+		// Only private messages are forwarded, never public mentions
+		if cfg.Commands.ForwardUnrecognized && event.Kind != gonostr.KindTextNote {
+			result, ok := commands.ForwardUnrecognized(ctx, h.database, h.roles, senderNpub, parsedCmd.Text, msgs)
+			switch {
+			case ok && result.Error == nil:
+				h.note("result", "forwarded to admins")
+				reply(result.Message)
+				notifyAdmins(ctx, h.database, notify, notifyAlways, result.AdminNotice)
+				return
+			case ok:
+				// Over the limit: a typo gets the usual reply, not an error
+				slog.Warn("forwarding unrecognized message failed", "event_id", event.ID, "sender", senderNpub, "error", result.Error)
+				h.note("forward", "error: "+result.Error.Error())
+			}
+		}
+		reply(msgs.Render(messages.UnknownCommand, messages.CommandData{Name: parsedCmd.Name}))
+		return
+	}
+
+	// Check permissions
+	if err := commands.CanExecute(ctx, h.database, parsedCmd, senderNpub, h.roles); err != nil {
+		slog.Info("permission denied", "event_id", event.ID, "sender", senderNpub, "command", parsedCmd.Name, "error", err)
+		h.note("permission", err.Error())
+		reply(permissionDeniedReply(msgs, err))
+		return
+	}
+
+	slog.Info("executing command", "event_id", event.ID, "sender", senderNpub,
+		"command", parsedCmd.Name, "arg_count", len(parsedCmd.Args))
+	logContent(cfg, "command args", "event_id", event.ID, "command", parsedCmd.Name, "args", parsedCmd.Args)
+
+	// Transition FSM to command processed state
+	if err := h.processor.Event(ctx, fsm.ProcessorEventCommandProcessed); err != nil {
+		slog.Error("FSM error on command processed", "event_id", event.ID, "error", err)
+		return
+	}
+
+	// Execute the command
+	result := commands.Execute(ctx, h.database, parsedCmd, senderNpub, execCfg)
+
+	// Check for errors and transition FSM if needed
+	if result.Error != nil {
+		if err := h.processor.Event(ctx, fsm.ProcessorEventError); err != nil {
+			slog.Error("FSM error on command error", "event_id", event.ID, "error", err)
+		}
+		slog.Warn("command error", "event_id", event.ID, "sender", senderNpub, "command", parsedCmd.Name, "error", result.Error)
+		h.note("result", "error: "+result.Error.Error())
+		reply(msgs.Render(messages.ErrorReply, messages.ErrorData{Error: result.Error.Error()}))
+		return
+	}
+	h.note("result", "ok")
+
+	// Transition FSM to sending response state
+	if err := h.processor.Event(ctx, fsm.ProcessorEventResponseSent); err != nil {
+		slog.Error("FSM error on response sent", "event_id", event.ID, "error", err)
+		return
+	}
+
+	logContent(cfg, "command result", "event_id", event.ID, "command", parsedCmd.Name, "result", result.Message)
+	send(result.Message, result.Subject)
+
+	// Notify admins of new orders (not the customer's payment details)
+	if result.Order != nil {
+		notifyAdmins(ctx, h.database, notify, db.PrefOrders, orderAdminNotice(ctx, h.database, senderNpub, result.Order))
+	}
+
+	if result.AdminNotice != "" {
+		notifyAdmins(ctx, h.database, notify, notifyAlways, result.AdminNotice)
+	}
+
+	if result.LowInventoryWarning {
+		notifyLowInventory(ctx, h.database, notify, result.EggsRemaining)
+	}
+
+	// Check for inventory notifications after commands that may increase inventory
+	if result.TriggerNotifications {
+		checkInventoryNotifications(ctx, h.database, notify, cfg.Messages.Catalog)
+	}
+
+	// Announce large restocks publicly
+	if added := autoAnnounceAmount(parsedCmd); execCfg.Announcer != nil && cfg.Announce.AutoThreshold > 0 && added >= cfg.Announce.AutoThreshold {
+		available, err := h.database.GetInventory(ctx)
+		if err == nil {
+			err = execCfg.Announcer.Announce(ctx, available)
+		}
+		switch {
+		case errors.Is(err, commands.ErrAnnounceThrottled):
+			slog.Info("skipping inventory announcement", "event_id", event.ID, "reason", err)
+		case err != nil:
+			slog.Error("failed to announce inventory", "event_id", event.ID, "error", err)
+		default:
+			slog.Info("announced inventory", "event_id", event.ID, "available", available)
+		}
+	}
+}
+
+// permissionDeniedReply is the reply to a command CanExecute refused,
+// rendered from msgs.
+func permissionDeniedReply(msgs *messages.Catalog, err error) string {
+	switch {
+	case errors.Is(err, commands.ErrNotRegistered):
+		return msgs.Render(messages.NotRegistered, nil)
+	case errors.Is(err, commands.ErrAdminRequired):
+		return msgs.Render(messages.AdminRequired, nil)
+	default:
+		return msgs.Render(messages.PermissionsUnchecked, nil)
+	}
+}
+
+// HandleZap validates a zap receipt and credits the payment.
+func (h *EventHandler) HandleZap(ctx context.Context, event *gonostr.Event) {
+	cfg := h.config()
+
+	// Transition FSM to processing zap state
+	if err := h.processor.Event(ctx, fsm.ProcessorEventZapReceived); err != nil {
+		slog.Error("FSM error on zap received", "event_id", event.ID, "error", err)
+		h.processor.Reset()
+		return
+	}
+	defer h.processor.Reset()
+
+	// Validate the zap receipt
+	validatedZap, err := zaps.ValidateZapReceipt(event, cfg.Lightning.LnurlPubkeyHex)
+	if err != nil {
+		if errors.Is(err, zaps.ErrUnauthorizedZapProvider) {
+			slog.Warn("zap from unauthorized provider", "event_id", event.ID, "error", err)
+		} else {
+			slog.Warn("invalid zap receipt", "event_id", event.ID, "error", err)
+		}
+		h.note("validate", err.Error())
+		return
+	}
+	h.creditZap(ctx, cfg, event, validatedZap)
+}
+
+// creditZap credits a validated zap, confirms it to the sender and tells the
+// admins. The FSM must have received the zap.
+func (h *EventHandler) creditZap(ctx context.Context, cfg *config.Config, event *gonostr.Event, validatedZap *zaps.ValidatedZap) {
+	notify := h.notifier(ctx, cfg)
+	h.note("validated", fmt.Sprintf("%d sats from %s", validatedZap.AmountSats, validatedZap.SenderNpub))
+
+	// Blocked zaps are not credited, but money arrived so leave a trace
+	if blocked, err := h.database.IsBlocked(ctx, validatedZap.SenderNpub); err != nil {
+		slog.Error("blocklist check failed", "event_id", validatedZap.ZapEventID, "sender", validatedZap.SenderNpub, "error", err)
+	} else if blocked {
+		slog.Warn("ignoring zap from blocked sender", "event_id", validatedZap.ZapEventID,
+			"sender", validatedZap.SenderNpub, "amount_sats", validatedZap.AmountSats)
+		h.note("blocked", validatedZap.SenderNpub+" is blocked; zap not credited")
+		return
+	}
+
+	slog.Info("valid zap", "event_id", validatedZap.ZapEventID, "sender", validatedZap.SenderNpub, "amount_sats", validatedZap.AmountSats)
+
+	// Process the zap
+	pickup := commands.PickupInstructions(ctx, h.database, cfg.Pickup.Instructions)
+	msgs := commands.MessagesFor(ctx, h.database, validatedZap.SenderNpub, cfg.Messages.Catalog)
+	processResult, err := zaps.ProcessZap(ctx, h.database, validatedZap, pickup, msgs)
+	if err != nil {
+		if errors.Is(err, zaps.ErrDuplicateZap) {
+			slog.Info("duplicate zap event, ignoring", "event_id", validatedZap.ZapEventID)
+		} else {
+			slog.Error("failed to process zap", "event_id", validatedZap.ZapEventID, "sender", validatedZap.SenderNpub, "error", err)
+			if err := h.processor.Event(ctx, fsm.ProcessorEventError); err != nil {
+				slog.Error("FSM error on zap process error", "event_id", event.ID, "error", err)
+			}
+		}
+		h.note("result", "error: "+err.Error())
+		return
+	}
+	h.note("result", fmt.Sprintf("credited %d sats, customer found: %t", processResult.AmountSats, processResult.CustomerFound))
+
+	// Transition FSM to sending response state
+	if err := h.processor.Event(ctx, fsm.ProcessorEventResponseSent); err != nil {
+		slog.Error("FSM error on response sent (zap)", "event_id", event.ID, "error", err)
+		return
+	}
+
+	slog.Info("zap processed", "event_id", validatedZap.ZapEventID, "sender", validatedZap.SenderNpub,
+		"amount_sats", processResult.AmountSats, "customer_found", processResult.CustomerFound)
+	logContent(cfg, "zap result", "event_id", validatedZap.ZapEventID, "result", processResult.Message)
+
+	// Send DM confirmation to zapper
+	_, senderPubkeyHex, err := nip19.Decode(validatedZap.SenderNpub)
+	if err != nil {
+		slog.Error("failed to decode sender npub", "sender", validatedZap.SenderNpub, "error", err)
+	} else {
+		h.reply(ctx, cfg, senderPubkeyHex.(string), processResult.Message, validatedZap.ZappedNote, "", dm.ProtocolNIP04)
+	}
+
+	// Notify admins of payment received (not the pickup instructions)
+	notifyAdmins(ctx, h.database, notify, db.PrefPayments, paymentAdminNotice(ctx, h.database, validatedZap.SenderNpub, processResult))
+}
+
+// publish wraps a message in the appropriate protocol (NIP-04, NIP-44 or NIP-17) and publishes it to relays.
+// replyTo is the ID of the event being answered so clients can thread the reply; pass "" for unsolicited DMs.
+// Messages over the configured byte budget are split into numbered parts and sent in order.
+// subject is only sent with NIP-17 DMs, whose clients use it as the conversation title.
+func (h *EventHandler) publish(ctx context.Context, cfg *config.Config, recipientPubkeyHex, message, replyTo, subject string, protocol dm.DMProtocol) {
+	botSecretHex, botPubkeyHex := cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex
+
+	protocol = replyProtocol(cfg, protocol)
+
+	parts := dm.SplitMessage(message, cfg.Nostr.MaxMessageBytes)
+	for i, part := range parts {
+		var wrapped *gonostr.Event
+		var err error
+
+		switch protocol {
+		case dm.ProtocolNIP04:
+			wrapped, err = dm.WrapLegacyResponse(ctx, h.kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, part, replyTo)
+		case dm.ProtocolNIP44:
+			wrapped, err = dm.WrapNIP44Response(ctx, h.kr, botPubkeyHex, recipientPubkeyHex, part, replyTo)
+		case dm.ProtocolNIP17:
+			wrapped, err = dm.WrapResponse(ctx, h.kr, botPubkeyHex, recipientPubkeyHex, part, replyTo, subject)
+		default:
+			// Default to NIP-17 for safety
+			wrapped, err = dm.WrapResponse(ctx, h.kr, botPubkeyHex, recipientPubkeyHex, part, replyTo, subject)
+		}
+
+		if err != nil {
+			slog.Error("failed to wrap response", "part", i+1, "parts", len(parts), "error", err)
+			return
+		}
+
+		// Stop on the first failure so the recipient never sees parts out of order
+		if err := h.publisher.Publish(ctx, wrapped); err != nil {
+			slog.Error("failed to publish response", "part", i+1, "parts", len(parts), "error", err)
+			return
+		}
+	}
+
+	// Convert hex to npub for display
+	recipientNpub, _ := nip19.EncodePublicKey(recipientPubkeyHex)
+	if len(parts) > 1 {
+		slog.Info("sent response", "recipient", recipientNpub, "parts", len(parts))
+		return
+	}
+	slog.Info("sent response", "recipient", recipientNpub)
+}
+
+// protocolName returns the NIP a DM protocol refers to.
+func protocolName(protocol dm.DMProtocol) string {
+	switch protocol {
+	case dm.ProtocolNIP04:
+		return "NIP-04"
+	case dm.ProtocolNIP44:
+		return "NIP-44"
+	case dm.ProtocolNIP17:
+		return "NIP-17"
+	default:
+		return fmt.Sprintf("protocol %d", protocol)
+	}
+}
+
+// recentCancellationsWindow is how far back the new order notice counts the
+// customer's cancelled and expired orders.
+const recentCancellationsWindow = 30 * 24 * time.Hour
+
+// customerLabel returns "name (npub)" for a customer with a name, or else
+// the npub.
+func customerLabel(ctx context.Context, database *db.DB, npub string) string {
+	if customer, err := database.GetCustomerByNpub(ctx, npub); err == nil && customer.Name.Valid {
+		return fmt.Sprintf("%s (%s)", customer.Name.String, npub)
+	}
+	return npub
+}
+
+// orderAdminNotice tells the admins who placed order and what for, whether
+// it is the customer's first, and how many of their orders were cancelled
+// or expired unpaid lately. Details that can't be looked up are left out.
+func orderAdminNotice(ctx context.Context, database *db.DB, senderNpub string, order *db.Order) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "📥 New order from %s:\nOrder #%d: %d eggs for %d sats",
+		customerLabel(ctx, database, senderNpub), order.ID, order.Quantity, order.TotalSats)
+	if stats, err := database.GetCustomerStats(ctx, order.CustomerID); err != nil {
+		slog.Error("failed to get customer stats for order notice", "order_id", order.ID, "error", err)
+	} else if stats.Orders == 1 {
+		b.WriteString("\n⭐ First order")
+	}
+	since := time.Now().Add(-recentCancellationsWindow)
+	if n, err := database.CountRecentCancelledOrders(ctx, order.CustomerID, since); err != nil {
+		slog.Error("failed to count cancelled orders for order notice", "order_id", order.ID, "error", err)
+	} else if n > 0 {
+		fmt.Fprintf(&b, "\n⚠️ %d unpaid order(s) cancelled or expired in the last %d days", n, int(recentCancellationsWindow.Hours()/24))
+	}
+	return b.String()
+}
+
+// paymentAdminNotice tells the admins who paid, which order the payment
+// settled and how many of the customer's orders still await payment.
+func paymentAdminNotice(ctx context.Context, database *db.DB, senderNpub string, result *zaps.ProcessResult) string {
+	msg := fmt.Sprintf("💰 Payment received from %s: %d sats", customerLabel(ctx, database, senderNpub), result.AmountSats)
+	switch {
+	case !result.CustomerFound:
+		return msg + "\nNot credited: not a registered customer"
+	case result.PaidOrderID != 0:
+		msg += fmt.Sprintf("\nSettled order #%d", result.PaidOrderID)
+	default:
+		msg += "\nCredited to balance: " + result.Reason
+		if result.NeededSats > 0 {
+			msg += fmt.Sprintf(" (balance %d sats, order needs %d)", result.Balance, result.NeededSats)
+		}
+	}
+	return msg + fmt.Sprintf("\nOrders awaiting payment: %d", result.PendingOrders)
+}
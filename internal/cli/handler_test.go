@@ -0,0 +1,850 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/buildtall-systems/eggbot/internal/zaps"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// capturePublisher records published events instead of sending them.
+type capturePublisher struct {
+	mu     sync.Mutex
+	events []*gonostr.Event
+}
+
+func (p *capturePublisher) Publish(_ context.Context, event *gonostr.Event) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.events = append(p.events, event)
+	return nil
+}
+
+// testSender is a DM author with a fresh key.
+type testSender struct {
+	secretHex string
+	pubkeyHex string
+	npub      string
+	kr        gonostr.Keyer
+}
+
+func newTestSender(t *testing.T) testSender {
+	t.Helper()
+	secretHex := gonostr.GeneratePrivateKey()
+	pubkeyHex, _ := gonostr.GetPublicKey(secretHex)
+	npub, _ := nip19.EncodePublicKey(pubkeyHex)
+	kr, err := keyer.NewPlainKeySigner(secretHex)
+	if err != nil {
+		t.Fatalf("creating sender keyer: %v", err)
+	}
+	return testSender{secretHex: secretHex, pubkeyHex: pubkeyHex, npub: npub, kr: kr}
+}
+
+// testHandlerConfig returns a config for the bot key testSecretHex.
+func testHandlerConfig(t *testing.T) *config.Config {
+	t.Helper()
+	botPubkeyHex, _ := gonostr.GetPublicKey(testSecretHex)
+	cfg := &config.Config{}
+	cfg.Nostr.BotSecretHex = testSecretHex
+	cfg.Nostr.BotPubkeyHex = botPubkeyHex
+	cfg.Nostr.BotNpub = testBotNpub
+	return cfg
+}
+
+func newTestDB(t *testing.T, path string, admins ...string) *db.DB {
+	t.Helper()
+	database, err := db.Open(path)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	if err := database.SeedAdmins(context.Background(), admins); err != nil {
+		t.Fatalf("seeding admins: %v", err)
+	}
+	return database
+}
+
+func newTestHandler(t *testing.T, database *db.DB, cfg *config.Config) (*EventHandler, *capturePublisher) {
+	t.Helper()
+	kr, err := keyer.NewPlainKeySigner(cfg.Nostr.BotSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	publisher := &capturePublisher{}
+	h := NewEventHandler(database, kr, func() *config.Config { return cfg }, publisher,
+		commands.NewRoleCache(config.DefaultRoleCacheTTL))
+	return h, publisher
+}
+
+// decryptLegacy decrypts a kind:4 reply as its recipient.
+func decryptLegacy(t *testing.T, recipient testSender, event *gonostr.Event) string {
+	t.Helper()
+	shared, err := nip04.ComputeSharedSecret(event.PubKey, recipient.secretHex)
+	if err != nil {
+		t.Fatalf("computing shared secret: %v", err)
+	}
+	plaintext, err := nip04.Decrypt(event.Content, shared)
+	if err != nil {
+		t.Fatalf("decrypting reply: %v", err)
+	}
+	return plaintext
+}
+
+func TestEventHandler_NIP04RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	h, published := newTestHandler(t, newTestDB(t, ":memory:", admin.npub), cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, admin.kr, admin.secretHex, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory add 12", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	if len(published.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(published.events))
+	}
+	reply := published.events[0]
+	if reply.Kind != gonostr.KindEncryptedDirectMessage || reply.PubKey != cfg.Nostr.BotPubkeyHex {
+		t.Errorf("reply kind %d from %s, want kind:4 from the bot", reply.Kind, reply.PubKey)
+	}
+	if tag := reply.Tags.Find("e"); len(tag) < 2 || tag[1] != event.ID {
+		t.Errorf("reply should thread under %s, tags %v", event.ID, reply.Tags)
+	}
+	if got := decryptLegacy(t, admin, reply); got != "Added 12 eggs. Total: 12" {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestEventHandler_NIP17RoundTrip(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	if err := database.AddEggs(ctx, 12); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	wrap, err := dm.WrapResponse(ctx, admin.kr, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory", "", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, wrap)
+
+	if len(published.events) != 1 || published.events[0].Kind != gonostr.KindGiftWrap {
+		t.Fatalf("published %v, want one gift wrap", published.events)
+	}
+	rumor, err := nip59.GiftUnwrap(*published.events[0], func(pubkey, ciphertext string) (string, error) {
+		return admin.kr.Decrypt(ctx, ciphertext, pubkey)
+	})
+	if err != nil {
+		t.Fatalf("unwrapping reply: %v", err)
+	}
+	if rumor.PubKey != cfg.Nostr.BotPubkeyHex || !strings.Contains(rumor.Content, "Available:  12 eggs") {
+		t.Errorf("reply from %s: %q", rumor.PubKey, rumor.Content)
+	}
+}
+
+func TestEventHandler_OrderSubject(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Pricing.SatsPerHalfDozen = 3200
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.AddEggs(ctx, 12); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	wrap, err := dm.WrapResponse(ctx, customer.kr, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "order 6", "", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, wrap)
+
+	if len(published.events) == 0 || published.events[0].Kind != gonostr.KindGiftWrap {
+		t.Fatalf("published %v, want a gift-wrapped confirmation first", published.events)
+	}
+	rumor, err := nip59.GiftUnwrap(*published.events[0], func(pubkey, ciphertext string) (string, error) {
+		return customer.kr.Decrypt(ctx, ciphertext, pubkey)
+	})
+	if err != nil {
+		t.Fatalf("unwrapping reply: %v", err)
+	}
+	if !strings.HasPrefix(rumor.Content, "Order 1:") {
+		t.Errorf("confirmation = %q", rumor.Content)
+	}
+	if tag := rumor.Tags.Find("subject"); len(tag) < 2 || tag[1] != "Order #1" {
+		t.Errorf("subject tag = %v, want Order #1", tag)
+	}
+}
+
+func TestEventHandler_CustomerLanguage(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Pricing.SatsPerHalfDozen = 500
+	provider := newTestSender(t)
+	cfg.Lightning.LnurlPubkeyHex = provider.pubkeyHex
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.UpdateCustomerLang(ctx, customer.npub, "de"); err != nil {
+		t.Fatalf("setting language: %v", err)
+	}
+	if err := database.AddEggs(ctx, 12); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	wrap, err := dm.WrapResponse(ctx, customer.kr, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "order 12", "", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, wrap)
+
+	if len(published.events) == 0 {
+		t.Fatal("no confirmation published")
+	}
+	rumor, err := nip59.GiftUnwrap(*published.events[0], func(pubkey, ciphertext string) (string, error) {
+		return customer.kr.Decrypt(ctx, ciphertext, pubkey)
+	})
+	if err != nil {
+		t.Fatalf("unwrapping reply: %v", err)
+	}
+	if !strings.HasPrefix(rumor.Content, "Bestellung 1: 12 Eier für 1.000 Sats reserviert.") {
+		t.Errorf("confirmation = %q, want it in German", rumor.Content)
+	}
+	if tag := rumor.Tags.Find("subject"); len(tag) < 2 || tag[1] != "Bestellung #1" {
+		t.Errorf("subject tag = %v, want Bestellung #1", tag)
+	}
+
+	// The payment confirmation follows the customer's language too
+	published.events = nil
+	h.HandleZap(ctx, zapReceipt(t, cfg, provider, customer))
+	if len(published.events) == 0 {
+		t.Fatal("no payment confirmation published")
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); !strings.HasPrefix(got, "1.000 Sats gutgeschrieben - Bestellung #1 ist bezahlt!") {
+		t.Errorf("payment confirmation = %q, want it in German", got)
+	}
+
+	// So do errors
+	published.events = nil
+	event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "cancel", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+	if len(published.events) == 0 {
+		t.Fatal("no error reply published")
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); got != "Fehler: Verwendung: cancel <order_id>" {
+		t.Errorf("error reply = %q, want it in German", got)
+	}
+}
+
+func TestEventHandler_CustomerLanguageReplies(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	provider := newTestSender(t)
+	cfg.Lightning.LnurlPubkeyHex = provider.pubkeyHex
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.UpdateCustomerLang(ctx, customer.npub, "de"); err != nil {
+		t.Fatalf("setting language: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	// A zap with no order to pay is credited to the balance
+	h.HandleZap(ctx, zapReceipt(t, cfg, provider, customer))
+	if len(published.events) == 0 {
+		t.Fatal("no zap confirmation published")
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); got != "1.000 Sats gutgeschrieben" {
+		t.Errorf("zap confirmation = %q, want it in German", got)
+	}
+
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"cancel 99", "Fehler: Bestellung 99 nicht gefunden"},
+		{"hallo", "Unbekannter Befehl: hallo. Sende 'help' für die verfügbaren Befehle."},
+		{"help", "Verfügbare Befehle:\n• inventory - Verfügbare Eier anzeigen"},
+	}
+	for _, tt := range tests {
+		published.events = nil
+		event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, tt.message, "")
+		if err != nil {
+			t.Fatalf("wrapping DM: %v", err)
+		}
+		h.HandleDM(ctx, event)
+		if len(published.events) == 0 {
+			t.Fatalf("%s: no reply published", tt.message)
+		}
+		if got := decryptLegacy(t, customer, published.events[0]); !strings.HasPrefix(got, tt.want) {
+			t.Errorf("%s: reply = %q, want it to start with %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestEventHandler_RestockNotifies(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	send := func(sender testSender, message string) {
+		t.Helper()
+		event, err := dm.WrapLegacyResponse(ctx, sender.kr, sender.secretHex, sender.pubkeyHex, cfg.Nostr.BotPubkeyHex, message, "")
+		if err != nil {
+			t.Fatalf("wrapping DM: %v", err)
+		}
+		h.HandleDM(ctx, event)
+	}
+	send(customer, "notify 6")
+	send(admin, "inventory add 12")
+
+	// The subscription reply, the admin's confirmation, then the alert
+	if len(published.events) != 3 {
+		t.Fatalf("published %d events, want 3", len(published.events))
+	}
+	if got := decryptLegacy(t, customer, published.events[2]); got != "🥚 Inventory alert: 12 eggs are now available!" {
+		t.Errorf("alert = %q", got)
+	}
+
+	// The subscription is used up by the alert
+	send(admin, "inventory add 6")
+	if len(published.events) != 4 {
+		t.Errorf("published %d events, want only the admin's confirmation", len(published.events))
+	}
+}
+
+func TestEventHandler_CancelNotifies(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	buyer := newTestSender(t)
+	waiter := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	for _, c := range []testSender{buyer, waiter} {
+		if _, err := database.CreateCustomer(ctx, c.npub, ""); err != nil {
+			t.Fatalf("creating customer: %v", err)
+		}
+	}
+	if err := database.AddEggs(ctx, 6); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	send := func(sender testSender, message string) {
+		t.Helper()
+		event, err := dm.WrapLegacyResponse(ctx, sender.kr, sender.secretHex, sender.pubkeyHex, cfg.Nostr.BotPubkeyHex, message, "")
+		if err != nil {
+			t.Fatalf("wrapping DM: %v", err)
+		}
+		h.HandleDM(ctx, event)
+	}
+	send(buyer, "order 6")
+	send(waiter, "notify 6")
+	before := len(published.events)
+
+	// Cancelling returns the eggs, which is what the waiting customer asked for
+	send(buyer, "cancel 1")
+	if len(published.events) != before+2 {
+		t.Fatalf("published %d events after cancelling, want the reply and an alert", len(published.events)-before)
+	}
+	if got := decryptLegacy(t, buyer, published.events[before]); got != "Order 1 cancelled." {
+		t.Errorf("reply = %q", got)
+	}
+	if got := decryptLegacy(t, waiter, published.events[before+1]); got != "🥚 Inventory alert: 6 eggs are now available!" {
+		t.Errorf("alert = %q", got)
+	}
+
+	// A failed cancel returns nothing, so checks nothing
+	send(waiter, "notify 6")
+	before = len(published.events)
+	send(buyer, "cancel 1")
+	if len(published.events) != before+1 {
+		t.Errorf("published %d events after a failed cancel, want only the error", len(published.events)-before)
+	}
+}
+
+func TestEventHandler_ContactRelaysToAdmins(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	if _, err := database.CreateCustomer(ctx, customer.npub, "Jane"); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "contact I'll be late Saturday", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	// The confirmation to the customer, then the message to the admin
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want 2", len(published.events))
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); got != "Your message was passed on to the farmer." {
+		t.Errorf("confirmation = %q", got)
+	}
+	if got, want := decryptLegacy(t, admin, published.events[1]), "✉️ Message from Jane ("+customer.npub+"):\nI'll be late Saturday"; got != want {
+		t.Errorf("admin message = %q, want %q", got, want)
+	}
+}
+
+func TestEventHandler_ForwardUnrecognized(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Commands.ForwardUnrecognized = true
+	admin := newTestSender(t)
+	customer := newTestSender(t)
+	stranger := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	if _, err := database.CreateCustomer(ctx, customer.npub, "Jane"); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "are the brown ones free range?", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want 2", len(published.events))
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); got != "I didn't understand that — forwarding to the farmer." {
+		t.Errorf("reply = %q", got)
+	}
+	if got, want := decryptLegacy(t, admin, published.events[1]), "✉️ Message from Jane ("+customer.npub+"):\nare the brown ones free range?"; got != want {
+		t.Errorf("admin message = %q, want %q", got, want)
+	}
+
+	// Someone who isn't a customer just hears the command is unknown
+	event, err = dm.WrapLegacyResponse(ctx, stranger.kr, stranger.secretHex, stranger.pubkeyHex, cfg.Nostr.BotPubkeyHex, "hello there", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	if len(published.events) != 3 {
+		t.Fatalf("published %d events, want 3", len(published.events))
+	}
+	if got := decryptLegacy(t, stranger, published.events[2]); !strings.HasPrefix(got, "Unknown command: hello") {
+		t.Errorf("reply = %q, want the unknown command reply", got)
+	}
+
+	// Client metadata isn't forwarded, and over the contact limit the
+	// customer hears the command is unknown rather than an error
+	quoted := "> an old note\nwhat time on Saturday?\n---\nPosted from Example"
+	for i := 0; i < 3; i++ {
+		event, err = dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, quoted, "")
+		if err != nil {
+			t.Fatalf("wrapping DM: %v", err)
+		}
+		h.HandleDM(ctx, event)
+	}
+	if len(published.events) != 8 {
+		t.Fatalf("published %d events, want 8", len(published.events))
+	}
+	if got, want := decryptLegacy(t, admin, published.events[4]), "✉️ Message from Jane ("+customer.npub+"):\nwhat time on Saturday?"; got != want {
+		t.Errorf("admin message = %q, want %q", got, want)
+	}
+	if got := decryptLegacy(t, customer, published.events[7]); !strings.HasPrefix(got, "Unknown command: what") {
+		t.Errorf("reply over the limit = %q, want the unknown command reply", got)
+	}
+}
+
+func TestEventHandler_WaitlistOff(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "waitlist 6", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	if len(published.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(published.events))
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); !strings.HasPrefix(got, "Unknown command: waitlist") {
+		t.Errorf("reply = %q, want the unknown command reply", got)
+	}
+}
+
+func TestEventHandler_LowInventoryWarning(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Orders.LowInventoryThreshold = 6
+	admin := newTestSender(t)
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	_ = database.AddEggs(ctx, 10)
+	h, published := newTestHandler(t, database, cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, customer.kr, customer.secretHex, customer.pubkeyHex, cfg.Nostr.BotPubkeyHex, "order 6", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	// The reply, the new order notice, then the warning
+	if len(published.events) != 3 {
+		t.Fatalf("published %d events, want 3", len(published.events))
+	}
+	if got := decryptLegacy(t, admin, published.events[2]); got != "⚠️ Low inventory: 4 eggs left" {
+		t.Errorf("admin message = %q, want the low inventory warning", got)
+	}
+}
+
+func TestEventHandler_PermissionDenied(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	stranger := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	h, published := newTestHandler(t, database, cfg)
+
+	event, err := dm.WrapLegacyResponse(ctx, stranger.kr, stranger.secretHex, stranger.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory add 12", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+
+	if len(published.events) != 1 {
+		t.Fatalf("published %d events, want 1", len(published.events))
+	}
+	if got := decryptLegacy(t, stranger, published.events[0]); !strings.HasPrefix(got, "Permission denied: you are not a registered customer") {
+		t.Errorf("reply = %q, want a not-registered error", got)
+	}
+	if n, _ := database.GetInventory(ctx); n != 0 {
+		t.Errorf("inventory = %d, want the command not run", n)
+	}
+
+	// A registered customer is told the command is for admins instead
+	if _, err := database.CreateCustomer(ctx, stranger.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	h.roles.Invalidate(stranger.npub)
+	event, err = dm.WrapLegacyResponse(ctx, stranger.kr, stranger.secretHex, stranger.pubkeyHex, cfg.Nostr.BotPubkeyHex, "sales", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	h.HandleDM(ctx, event)
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want 2", len(published.events))
+	}
+	if got := decryptLegacy(t, stranger, published.events[1]); !strings.HasPrefix(got, "Permission denied: that command is for admins") {
+		t.Errorf("reply = %q, want an admin-required error", got)
+	}
+}
+
+// mentionNote returns a kind:1 note by sender tagging the bot.
+func mentionNote(t *testing.T, sender testSender, cfg *config.Config, content string) *gonostr.Event {
+	t.Helper()
+	note := &gonostr.Event{
+		Kind:      gonostr.KindTextNote,
+		CreatedAt: gonostr.Now(),
+		Tags:      gonostr.Tags{{"p", cfg.Nostr.BotPubkeyHex}},
+		Content:   content,
+	}
+	if err := note.Sign(sender.secretHex); err != nil {
+		t.Fatalf("signing note: %v", err)
+	}
+	return note
+}
+
+func TestEventHandler_Mention(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Features.EnableMentions = true
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+	if _, err := database.CreateCustomer(ctx, customer.npub, ""); err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.AddEggs(ctx, 12); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	h, published := newTestHandler(t, database, cfg)
+
+	h.HandleMention(ctx, mentionNote(t, customer, cfg, "nostr:"+cfg.Nostr.BotNpub+" inventory"))
+
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want an acknowledgement and a reply", len(published.events))
+	}
+	for _, event := range published.events {
+		if event.Kind != gonostr.KindEncryptedDirectMessage {
+			t.Errorf("published kind %d, want only DMs", event.Kind)
+		}
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); got != "Got your request — continuing in DM" {
+		t.Errorf("acknowledgement = %q", got)
+	}
+	if got := decryptLegacy(t, customer, published.events[1]); got != "12 eggs available." {
+		t.Errorf("reply = %q", got)
+	}
+}
+
+func TestEventHandler_MentionIgnored(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	cfg.Features.EnableMentions = true
+	admin := newTestSender(t)
+	h, published := newTestHandler(t, newTestDB(t, ":memory:", admin.npub), cfg)
+
+	for _, content := range []string{
+		"@eggbot I'd like a dozen",
+		"nostr:" + cfg.Nostr.BotNpub + " great eggs!",
+		"@eggbot sales", // Admin commands are never taken from public notes
+	} {
+		h.HandleMention(ctx, mentionNote(t, admin, cfg, content))
+	}
+
+	cfg.Features.EnableMentions = false
+	h.HandleMention(ctx, mentionNote(t, admin, cfg, "@eggbot inventory"))
+
+	if len(published.events) != 0 {
+		t.Errorf("published %d events, want none", len(published.events))
+	}
+}
+
+func TestMentionCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{"@eggbot order 12", "order 12"},
+		{"nostr:npub1abc order 12 please", "order 12 please"},
+		{"order 12 nostr:nprofile1xyz", "order 12"},
+		{"@Eggbot  @farm\ninventory", "inventory"},
+		{"ask @eggbot", "ask @eggbot"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := mentionCommand(tt.content); got != tt.want {
+			t.Errorf("mentionCommand(%q) = %q, want %q", tt.content, got, tt.want)
+		}
+	}
+}
+
+// testInvoice1000Sats builds a checksum-valid lnbc10u (1000 sats) invoice created
+// now; the payment hash and signature are zeroed.
+func testInvoice1000Sats(t *testing.T) string {
+	t.Helper()
+	words := make([]byte, 0, 7+3+52+104)
+	ts := time.Now().Unix()
+	for i := 6; i >= 0; i-- {
+		words = append(words, byte(ts>>(5*i))&31)
+	}
+	words = append(words, 1, 1, 20) // p field, 52 words
+	words = append(words, make([]byte, 52+104)...)
+	invoice, err := bech32.Encode("lnbc10u", words)
+	if err != nil {
+		t.Fatalf("encoding invoice: %v", err)
+	}
+	return invoice
+}
+
+// zapReceipt returns a 1000 sat profile zap from sender to the bot, signed by
+// the LNURL provider.
+func zapReceipt(t *testing.T, cfg *config.Config, provider, sender testSender) *gonostr.Event {
+	t.Helper()
+	request := gonostr.Event{
+		Kind:      gonostr.KindZapRequest,
+		PubKey:    sender.pubkeyHex,
+		CreatedAt: gonostr.Now(),
+		Tags:      gonostr.Tags{{"p", cfg.Nostr.BotPubkeyHex}},
+	}
+	requestJSON, _ := json.Marshal(request)
+	receipt := &gonostr.Event{
+		Kind:      gonostr.KindZap,
+		CreatedAt: gonostr.Now(),
+		Tags: gonostr.Tags{
+			{"description", string(requestJSON)},
+			{"bolt11", testInvoice1000Sats(t)},
+			{"p", cfg.Nostr.BotPubkeyHex},
+		},
+	}
+	if err := receipt.Sign(provider.secretHex); err != nil {
+		t.Fatalf("signing receipt: %v", err)
+	}
+	return receipt
+}
+
+func TestEventHandler_ZapPaysOrder(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	provider := newTestSender(t)
+	cfg.Lightning.LnurlPubkeyHex = provider.pubkeyHex
+	admin := newTestSender(t)
+	customer := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+	h, published := newTestHandler(t, database, cfg)
+
+	registered, err := database.CreateCustomer(ctx, customer.npub, "")
+	if err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	if err := database.AddEggs(ctx, 6); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+	order, err := database.CreateOrder(ctx, registered.ID, 6, 1000)
+	if err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+
+	h.HandleZap(ctx, zapReceipt(t, cfg, provider, customer))
+
+	paid, err := database.GetOrderByID(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("GetOrderByID: %v", err)
+	}
+	if paid.Status != "paid" {
+		t.Errorf("order status = %s, want paid", paid.Status)
+	}
+
+	// A confirmation to the customer, then a payment notice to the admin
+	if len(published.events) != 2 {
+		t.Fatalf("published %d events, want 2", len(published.events))
+	}
+	if got := decryptLegacy(t, customer, published.events[0]); !strings.Contains(got, "1000 sats") {
+		t.Errorf("confirmation = %q", got)
+	}
+	got := decryptLegacy(t, admin, published.events[1])
+	if !strings.HasPrefix(got, "💰 Payment received from "+customer.npub) || !strings.Contains(got, fmt.Sprintf("Settled order #%d", order.ID)) {
+		t.Errorf("admin notice = %q", got)
+	}
+}
+
+func TestOrderAdminNotice(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	c, _ := database.CreateCustomer(ctx, testExpectedNpub, "Alice")
+	_ = database.AddEggs(ctx, 30)
+
+	first, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
+	got := orderAdminNotice(ctx, database, testExpectedNpub, first)
+	want := fmt.Sprintf("📥 New order from Alice (%s):\nOrder #%d: 6 eggs for 3200 sats\n⭐ First order", testExpectedNpub, first.ID)
+	if got != want {
+		t.Errorf("first order notice = %q, want %q", got, want)
+	}
+
+	// A later order after two cancellations
+	_ = database.CancelOrder(ctx, first.ID)
+	second, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
+	_ = database.CancelOrder(ctx, second.ID)
+	third, _ := database.CreateOrder(ctx, c.ID, 12, 6400)
+	got = orderAdminNotice(ctx, database, testExpectedNpub, third)
+	if strings.Contains(got, "First order") || !strings.Contains(got, "⚠️ 2 unpaid order(s) cancelled or expired in the last 30 days") {
+		t.Errorf("repeat order notice = %q", got)
+	}
+}
+
+func TestPaymentAdminNotice(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	_, _ = database.CreateCustomer(ctx, testExpectedNpub, "")
+
+	tests := []struct {
+		name   string
+		result zaps.ProcessResult
+		want   string
+	}{
+		{
+			name:   "settled an order",
+			result: zaps.ProcessResult{CustomerFound: true, AmountSats: 3200, PaidOrderID: 7, Message: "Paid\n\nPickup"},
+			want:   "💰 Payment received from " + testExpectedNpub + ": 3200 sats\nSettled order #7\nOrders awaiting payment: 0",
+		},
+		{
+			name: "short of the order",
+			result: zaps.ProcessResult{CustomerFound: true, AmountSats: 1000, PendingOrders: 1, Balance: 1000, NeededSats: 3200,
+				Reason: "not enough to pay order #7", Message: "1.000 Sats gutgeschrieben (Guthaben: 1.000, die Bestellung kostet 3.200)"},
+			want: "💰 Payment received from " + testExpectedNpub + ": 1000 sats\nCredited to balance: not enough to pay order #7 (balance 1000 sats, order needs 3200)\nOrders awaiting payment: 1",
+		},
+		{
+			name:   "no pending orders",
+			result: zaps.ProcessResult{CustomerFound: true, AmountSats: 500, Reason: "no pending orders", Message: "Credited 500 sats"},
+			want:   "💰 Payment received from " + testExpectedNpub + ": 500 sats\nCredited to balance: no pending orders\nOrders awaiting payment: 0",
+		},
+		{
+			name:   "not a customer",
+			result: zaps.ProcessResult{AmountSats: 21},
+			want:   "💰 Payment received from " + testExpectedNpub + ": 21 sats\nNot credited: not a registered customer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paymentAdminNotice(ctx, database, testExpectedNpub, &tt.result); got != tt.want {
+				t.Errorf("paymentAdminNotice = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// fakeNIP05Lookup answers with fixed identifiers and records the pubkeys
+// asked about.
+type fakeNIP05Lookup struct {
+	identifiers map[string]string
+	asked       []string
+}
+
+func (f *fakeNIP05Lookup) NIP05s(_ context.Context, pubkeys []string) map[string]string {
+	f.asked = append(f.asked, pubkeys...)
+	found := make(map[string]string)
+	for _, pk := range pubkeys {
+		if nip05, ok := f.identifiers[pk]; ok {
+			found[pk] = nip05
+		}
+	}
+	return found
+}
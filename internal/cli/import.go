@@ -0,0 +1,93 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Bring existing records into the bot's database",
+}
+
+var importCustomersCmd = &cobra.Command{
+	Use:   "customers <file>",
+	Short: "Register customers from a CSV or plain list of npubs",
+	Long: `Register customers from a file with one "npub[,name]" entry per line, either
+CSV or plain lines. A header line starting with "npub" is skipped, as are blank
+lines and lines starting with "#".
+
+Every npub is checked before anything is written. Invalid entries are reported
+and skipped, or with --strict abort the import. Already registered npubs are
+left unchanged. All valid entries are imported in one transaction, so a
+database error imports nothing. --dry-run reports what would happen without
+writing.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		strict, _ := cmd.Flags().GetBool("strict")
+
+		f, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("opening %s: %w", args[0], err)
+		}
+		defer func() { _ = f.Close() }()
+
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+
+		return runImportCustomers(cmd.Context(), database, f, importOptions{DryRun: dryRun, Strict: strict}, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	importCustomersCmd.Flags().Bool("dry-run", false, "report what would be imported without writing")
+	importCustomersCmd.Flags().Bool("strict", false, "import nothing if any entry is invalid")
+	importCmd.AddCommand(importCustomersCmd)
+	rootCmd.AddCommand(importCmd)
+}
+
+// importOptions are the import customers flags.
+type importOptions struct {
+	DryRun bool // Roll back instead of committing
+	Strict bool // Abort on any invalid entry
+}
+
+// runImportCustomers reads a customer list, imports the valid entries and
+// prints a summary.
+func runImportCustomers(ctx context.Context, database *db.DB, r io.Reader, opts importOptions, out io.Writer) error {
+	customers, failures, err := commands.ParseCustomerList(r)
+	if err != nil {
+		return err
+	}
+	for _, failure := range failures {
+		_, _ = fmt.Fprintln(out, failure)
+	}
+	if opts.Strict && len(failures) > 0 {
+		return fmt.Errorf("%d invalid entries, nothing imported", len(failures))
+	}
+
+	created, existing, err := database.ImportCustomers(ctx, customers, opts.DryRun)
+	if err != nil {
+		return err
+	}
+	summary := "Created %d, already registered %d, invalid %d\n"
+	if opts.DryRun {
+		summary = "Dry run: would create %d, already registered %d, invalid %d\n"
+	}
+	_, _ = fmt.Fprintf(out, summary, created, existing, len(failures))
+	return nil
+}
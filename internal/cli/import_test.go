@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+func TestRunImportCustomers(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	if _, err := database.CreateCustomer(ctx, testStrangerNpub, ""); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+
+	csv := strings.Join([]string{
+		"npub,name",
+		testExpectedNpub + ", Alice",
+		testBotNpub,
+		testStrangerNpub + ",Already Here",
+		testExpectedNpub + ",Alice Again",
+		"npub1bogus,Mallory",
+		testNsec + ",Leaked",
+		"",
+	}, "\n")
+
+	var out bytes.Buffer
+	if err := runImportCustomers(ctx, database, strings.NewReader(csv), importOptions{}, &out); err != nil {
+		t.Fatalf("runImportCustomers: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Created 2, already registered 2, invalid 2") {
+		t.Errorf("unexpected summary:\n%s", out.String())
+	}
+	for _, want := range []string{"line 6:", "line 7:"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output should report %q:\n%s", want, out.String())
+		}
+	}
+
+	alice, err := database.GetCustomerByNpub(ctx, testExpectedNpub)
+	if err != nil {
+		t.Fatalf("imported customer missing: %v", err)
+	}
+	if alice.Name.String != "Alice" {
+		t.Errorf("name = %q, want first row's name kept", alice.Name.String)
+	}
+	existing, _ := database.GetCustomerByNpub(ctx, testStrangerNpub)
+	if existing.Name.Valid {
+		t.Errorf("existing customer's name changed to %q", existing.Name.String)
+	}
+	if customers, _ := database.ListCustomers(ctx); len(customers) != 3 {
+		t.Errorf("customers = %d, want 3", len(customers))
+	}
+}
+
+func TestRunImportCustomers_DatabaseErrorImportsNothing(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+	// Reject the second insert so the transaction fails part way through
+	_, err = database.Exec(`CREATE TRIGGER reject_bot BEFORE INSERT ON customers
+		WHEN NEW.npub = '` + testBotNpub + `' BEGIN SELECT RAISE(ABORT, 'rejected'); END`)
+	if err != nil {
+		t.Fatalf("creating trigger: %v", err)
+	}
+
+	csv := testExpectedNpub + "\n" + testBotNpub + "\n"
+	if err := runImportCustomers(ctx, database, strings.NewReader(csv), importOptions{}, &bytes.Buffer{}); err == nil {
+		t.Fatal("expected import error")
+	}
+	if customers, _ := database.ListCustomers(ctx); len(customers) != 0 {
+		t.Errorf("customers = %d, want none after a failed import", len(customers))
+	}
+}
+
+func TestRunImportCustomers_DryRunAndStrict(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	list := testExpectedNpub + "\n" + testBotNpub + " , Bot\n" + "npub1bogus\n"
+
+	var out bytes.Buffer
+	if err := runImportCustomers(ctx, database, strings.NewReader(list), importOptions{DryRun: true}, &out); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !strings.Contains(out.String(), "Dry run: would create 2, already registered 0, invalid 1") {
+		t.Errorf("unexpected dry run summary:\n%s", out.String())
+	}
+	if n, _ := database.CountCustomers(ctx); n != 0 {
+		t.Errorf("dry run registered %d customers", n)
+	}
+
+	out.Reset()
+	err := runImportCustomers(ctx, database, strings.NewReader(list), importOptions{Strict: true}, &out)
+	if err == nil || !strings.Contains(err.Error(), "1 invalid entries") {
+		t.Fatalf("expected a strict import to fail, got %v", err)
+	}
+	if !strings.Contains(out.String(), `line 3: "npub1bogus" is not a valid npub`) {
+		t.Errorf("strict import should still report the bad line:\n%s", out.String())
+	}
+	if n, _ := database.CountCustomers(ctx); n != 0 {
+		t.Errorf("strict import registered %d customers", n)
+	}
+}
@@ -0,0 +1,256 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// Starter config defaults offered by init.
+const (
+	defaultInitRelay  = "wss://relay.damus.io"
+	defaultInitDBPath = "eggbot.db"
+	defaultInitPrice  = 3200
+)
+
+// starterConfig is the config file written by init.
+type starterConfig struct {
+	Database starterDatabase `yaml:"database"`
+	Nostr    starterNostr    `yaml:"nostr"`
+	Pricing  starterPricing  `yaml:"pricing"`
+	Admins   []string        `yaml:"admins"`
+}
+
+type starterDatabase struct {
+	Path string `yaml:"path"`
+}
+
+type starterNostr struct {
+	Relays  []string `yaml:"relays"`
+	BotNpub string   `yaml:"bot_npub,omitempty"`
+}
+
+type starterPricing struct {
+	SatsPerHalfDozen int `yaml:"sats_per_half_dozen"`
+}
+
+// initOptions are the values init writes. Empty fields are prompted for
+// unless nonInteractive is set, in which case defaults are used.
+type initOptions struct {
+	path           string
+	relays         []string
+	dbPath         string
+	price          int
+	admins         []string
+	botNpub        string
+	nsec           string // From EGGBOT_NSEC; only used to derive and check the bot npub
+	force          bool
+	nonInteractive bool
+}
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a starter config file",
+	Long: `Write a starter config file with relays, pricing, database path and admin npubs.
+
+Values not given as flags are prompted for. If EGGBOT_NSEC is set, the bot npub
+is derived from it and written to the config. An existing config file is only
+replaced with --force.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := initOptions{nsec: os.Getenv("EGGBOT_NSEC")}
+		path, err := configFilePath()
+		if err != nil {
+			return err
+		}
+		opts.path = path
+
+		flags := cmd.Flags()
+		opts.relays, _ = flags.GetStringSlice("relay")
+		opts.dbPath, _ = flags.GetString("db")
+		opts.price, _ = flags.GetInt("price")
+		opts.admins, _ = flags.GetStringSlice("admin")
+		opts.botNpub, _ = flags.GetString("bot-npub")
+		opts.force, _ = flags.GetBool("force")
+		opts.nonInteractive, _ = flags.GetBool("non-interactive")
+
+		return runInit(opts, cmd.InOrStdin(), cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	initCmd.Flags().StringSlice("relay", nil, "relay URL (repeatable)")
+	initCmd.Flags().String("db", "", "database path (default "+defaultInitDBPath+")")
+	initCmd.Flags().Int("price", 0, "price of 6 eggs in sats (default "+strconv.Itoa(defaultInitPrice)+")")
+	initCmd.Flags().StringSlice("admin", nil, "admin npub (repeatable)")
+	initCmd.Flags().String("bot-npub", "", "bot npub, if EGGBOT_NSEC is not set")
+	initCmd.Flags().Bool("force", false, "overwrite an existing config file")
+	initCmd.Flags().Bool("non-interactive", false, "use defaults instead of prompting")
+	rootCmd.AddCommand(initCmd)
+}
+
+// runInit validates the options, prompting for missing ones, and writes the config.
+func runInit(opts initOptions, in io.Reader, out io.Writer) error {
+	if _, err := os.Stat(opts.path); err == nil && !opts.force {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", opts.path)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", opts.path, err)
+	}
+
+	p := &prompter{in: bufio.NewReader(in), out: out, skip: opts.nonInteractive}
+
+	if len(opts.relays) == 0 {
+		opts.relays = p.list("Relays (comma separated)", defaultInitRelay)
+	}
+	for _, relay := range opts.relays {
+		if !strings.HasPrefix(relay, "wss://") && !strings.HasPrefix(relay, "ws://") {
+			return fmt.Errorf("relay %q must start with wss:// or ws://", relay)
+		}
+	}
+
+	if opts.dbPath == "" {
+		opts.dbPath = p.ask("Database path", defaultInitDBPath)
+	}
+
+	if opts.price == 0 {
+		answer := p.ask("Price of 6 eggs in sats", strconv.Itoa(defaultInitPrice))
+		price, err := strconv.Atoi(answer)
+		if err != nil {
+			return fmt.Errorf("price %q is not a number", answer)
+		}
+		opts.price = price
+	}
+	if opts.price <= 0 {
+		return fmt.Errorf("price must be greater than 0, got %d", opts.price)
+	}
+
+	if len(opts.admins) == 0 {
+		opts.admins = p.list("Admin npubs (comma separated)", "")
+	}
+	if len(opts.admins) == 0 {
+		return errors.New("at least one admin npub is required")
+	}
+	for _, admin := range opts.admins {
+		if err := checkNpub(admin); err != nil {
+			return fmt.Errorf("admin %q: %w", admin, err)
+		}
+	}
+
+	if opts.botNpub != "" {
+		if err := checkNpub(opts.botNpub); err != nil {
+			return fmt.Errorf("bot npub %q: %w", opts.botNpub, err)
+		}
+	}
+	if opts.nsec != "" {
+		derived, err := npubFromNsec(opts.nsec)
+		if err != nil {
+			return fmt.Errorf("EGGBOT_NSEC: %w", err)
+		}
+		if opts.botNpub != "" && opts.botNpub != derived {
+			return fmt.Errorf("EGGBOT_NSEC belongs to %s, not --bot-npub %s", derived, opts.botNpub)
+		}
+		opts.botNpub = derived
+		_, _ = fmt.Fprintf(out, "Bot npub (from EGGBOT_NSEC): %s\n", derived)
+	}
+
+	data, err := yaml.Marshal(starterConfig{
+		Database: starterDatabase{Path: opts.dbPath},
+		Nostr:    starterNostr{Relays: opts.relays, BotNpub: opts.botNpub},
+		Pricing:  starterPricing{SatsPerHalfDozen: opts.price},
+		Admins:   opts.admins,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding config: %w", err)
+	}
+	if err := os.WriteFile(opts.path, data, 0o600); err != nil {
+		return fmt.Errorf("writing config: %w", err)
+	}
+
+	_, _ = fmt.Fprintf(out, "Wrote %s\n", opts.path)
+	if opts.nsec == "" {
+		_, _ = fmt.Fprintln(out, "Set EGGBOT_NSEC and run \"eggbot check\" before starting the bot.")
+	}
+	return nil
+}
+
+// checkNpub returns an error unless s is a valid npub.
+func checkNpub(s string) error {
+	prefix, _, err := nip19.Decode(s)
+	if err != nil {
+		return fmt.Errorf("not a valid npub: %w", err)
+	}
+	if prefix != "npub" {
+		return fmt.Errorf("expected an npub, got %s", prefix)
+	}
+	return nil
+}
+
+// npubFromNsec derives the npub for an nsec.
+func npubFromNsec(nsec string) (string, error) {
+	secretHex, err := decodeNsec(nsec)
+	if err != nil {
+		return "", err
+	}
+	pubkeyHex, err := nostr.GetPublicKey(secretHex)
+	if err != nil {
+		return "", fmt.Errorf("deriving public key: %w", err)
+	}
+	return nip19.EncodePublicKey(pubkeyHex)
+}
+
+// decodeNsec returns the hex secret key for an nsec.
+func decodeNsec(nsec string) (string, error) {
+	prefix, value, err := nip19.Decode(nsec)
+	if err != nil {
+		return "", fmt.Errorf("not a valid nsec: %w", err)
+	}
+	if prefix != "nsec" {
+		return "", fmt.Errorf("expected an nsec, got %s", prefix)
+	}
+	return value.(string), nil
+}
+
+// prompter asks for values on out and reads answers from in.
+// When skip is set, or input runs out, defaults are used.
+type prompter struct {
+	in   *bufio.Reader
+	out  io.Writer
+	skip bool
+}
+
+// ask prompts for a single value, returning def for an empty answer.
+func (p *prompter) ask(question, def string) string {
+	if p.skip {
+		return def
+	}
+	if def != "" {
+		_, _ = fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		_, _ = fmt.Fprintf(p.out, "%s: ", question)
+	}
+	// A read error (e.g. closed stdin) leaves an empty answer and the default
+	line, _ := p.in.ReadString('\n')
+	if line = strings.TrimSpace(line); line == "" {
+		return def
+	}
+	return line
+}
+
+// list prompts for comma separated values.
+func (p *prompter) list(question, def string) []string {
+	var values []string
+	for _, v := range strings.Split(p.ask(question, def), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,190 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/spf13/viper"
+)
+
+// testBotNpub is the npub for testNsec.
+const testBotNpub = "npub1zutzeysacnf9rru6zqwmxd54mud0k44tst6l70ja5mhv8jjumytsd2x7nu"
+
+// testConfigPath returns a config path in a fresh temp dir.
+func testConfigPath(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), "config.yaml")
+}
+
+// loadWritten reads a config written by init the way run does.
+func loadWritten(t *testing.T, path string) *config.Config {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("reading written config: %v", err)
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("loading written config: %v", err)
+	}
+	return cfg
+}
+
+func TestRunInit_FromFlags(t *testing.T) {
+	path := testConfigPath(t)
+	var out bytes.Buffer
+
+	err := runInit(initOptions{
+		path:   path,
+		relays: []string{"wss://relay.example.com", "wss://nos.lol"},
+		dbPath: "/var/lib/eggbot/eggbot.db",
+		price:  4000,
+		admins: []string{testExpectedNpub},
+		nsec:   testNsec,
+	}, strings.NewReader(""), &out)
+	if err != nil {
+		t.Fatalf("runInit: %v", err)
+	}
+
+	if !strings.Contains(out.String(), testBotNpub) {
+		t.Errorf("output should print the derived bot npub, got: %s", out.String())
+	}
+
+	cfg := loadWritten(t, path)
+	if len(cfg.Nostr.Relays) != 2 || cfg.Nostr.Relays[1] != "wss://nos.lol" {
+		t.Errorf("Relays = %v", cfg.Nostr.Relays)
+	}
+	if cfg.Database.Path != "/var/lib/eggbot/eggbot.db" {
+		t.Errorf("Database.Path = %q", cfg.Database.Path)
+	}
+	if cfg.Pricing.SatsPerHalfDozen != 4000 {
+		t.Errorf("SatsPerHalfDozen = %d, want 4000", cfg.Pricing.SatsPerHalfDozen)
+	}
+	if len(cfg.Admins) != 1 || cfg.Admins[0] != testExpectedNpub {
+		t.Errorf("Admins = %v", cfg.Admins)
+	}
+	if cfg.Nostr.BotNpub != testBotNpub {
+		t.Errorf("BotNpub = %q, want %q", cfg.Nostr.BotNpub, testBotNpub)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), testNsec) {
+		t.Error("config file must not contain the nsec")
+	}
+}
+
+func TestRunInit_Prompts(t *testing.T) {
+	path := testConfigPath(t)
+	var out bytes.Buffer
+
+	// relays, db path (default), price, admins
+	input := "wss://relay.example.com\n\n2500\n" + testExpectedNpub + "\n"
+	if err := runInit(initOptions{path: path}, strings.NewReader(input), &out); err != nil {
+		t.Fatalf("runInit: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Database path [eggbot.db]") {
+		t.Errorf("expected database prompt with default, got: %s", out.String())
+	}
+
+	cfg := loadWritten(t, path)
+	if len(cfg.Nostr.Relays) != 1 || cfg.Nostr.Relays[0] != "wss://relay.example.com" {
+		t.Errorf("Relays = %v", cfg.Nostr.Relays)
+	}
+	if cfg.Database.Path != defaultInitDBPath {
+		t.Errorf("Database.Path = %q, want default", cfg.Database.Path)
+	}
+	if cfg.Pricing.SatsPerHalfDozen != 2500 {
+		t.Errorf("SatsPerHalfDozen = %d, want 2500", cfg.Pricing.SatsPerHalfDozen)
+	}
+}
+
+func TestRunInit_RefusesOverwrite(t *testing.T) {
+	path := testConfigPath(t)
+	if err := os.WriteFile(path, []byte("existing: true\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := initOptions{path: path, admins: []string{testExpectedNpub}, nonInteractive: true}
+
+	err := runInit(opts, strings.NewReader(""), &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("expected refusal mentioning --force, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "existing: true\n" {
+		t.Errorf("existing config was modified: %q", data)
+	}
+
+	opts.force = true
+	if err := runInit(opts, strings.NewReader(""), &bytes.Buffer{}); err != nil {
+		t.Fatalf("runInit with force: %v", err)
+	}
+	if cfg := loadWritten(t, path); cfg.Pricing.SatsPerHalfDozen != defaultInitPrice {
+		t.Errorf("SatsPerHalfDozen = %d, want default after overwrite", cfg.Pricing.SatsPerHalfDozen)
+	}
+}
+
+func TestRunInit_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    initOptions
+		wantErr string
+	}{
+		{
+			name:    "invalid admin",
+			opts:    initOptions{admins: []string{"npub1bogus"}},
+			wantErr: "admin",
+		},
+		{
+			name:    "nsec as admin",
+			opts:    initOptions{admins: []string{testNsec}},
+			wantErr: "expected an npub",
+		},
+		{
+			name:    "no admins",
+			opts:    initOptions{},
+			wantErr: "at least one admin",
+		},
+		{
+			name:    "invalid nsec",
+			opts:    initOptions{admins: []string{testExpectedNpub}, nsec: "nsec1bogus"},
+			wantErr: "EGGBOT_NSEC",
+		},
+		{
+			name:    "nsec does not match bot npub",
+			opts:    initOptions{admins: []string{testExpectedNpub}, nsec: testNsec, botNpub: testExpectedNpub},
+			wantErr: "not --bot-npub",
+		},
+		{
+			name:    "relay without scheme",
+			opts:    initOptions{relays: []string{"relay.damus.io"}, admins: []string{testExpectedNpub}},
+			wantErr: "wss://",
+		},
+		{
+			name:    "negative price",
+			opts:    initOptions{price: -1, admins: []string{testExpectedNpub}},
+			wantErr: "price",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := testConfigPath(t)
+			tt.opts.path = path
+			tt.opts.nonInteractive = true
+
+			err := runInit(tt.opts, strings.NewReader(""), &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if _, statErr := os.Stat(path); statErr == nil {
+				t.Error("config file should not be written on error")
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var inventoryCmd = &cobra.Command{
+	Use:   "inventory",
+	Short: "Show or change the egg inventory without the bot running",
+	Long: `Show or change the egg inventory directly in the configured database. The
+output matches the admin replies to the inventory DM command. EGGBOT_NSEC is
+not needed.`,
+}
+
+var inventoryShowCmd = &cobra.Command{
+	Use:          "show",
+	Short:        "Show available, reserved and sold eggs",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return inventoryRunE(cmd, nil)
+	},
+}
+
+var inventoryAddCmd = &cobra.Command{
+	Use:          "add <n>",
+	Short:        "Add n eggs to the inventory",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return inventoryRunE(cmd, []string{"add", args[0]})
+	},
+}
+
+var inventorySetCmd = &cobra.Command{
+	Use:          "set <n>",
+	Short:        "Set the inventory to exactly n eggs",
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return inventoryRunE(cmd, []string{"set", args[0]})
+	},
+}
+
+func init() {
+	inventoryCmd.AddCommand(inventoryShowCmd, inventoryAddCmd, inventorySetCmd)
+	rootCmd.AddCommand(inventoryCmd)
+}
+
+// inventoryRunE opens the configured database and runs an inventory subcommand.
+func inventoryRunE(cmd *cobra.Command, args []string) error {
+	database, _, err := openConfiguredDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = database.Close() }()
+	if err := database.Migrate(); err != nil {
+		return fmt.Errorf("running migrations: %w", err)
+	}
+	return runInventory(cmd.Context(), database, args, cmd.OutOrStdout())
+}
+
+// runInventory runs the admin inventory command with args and prints its reply.
+func runInventory(ctx context.Context, database *db.DB, args []string, out io.Writer) error {
+	result := commands.InventoryCmd(ctx, database, args, true, nil)
+	if result.Error != nil {
+		return result.Error
+	}
+	_, _ = fmt.Fprintln(out, result.Message)
+	return nil
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestRunInventory(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+
+	steps := []struct {
+		args  []string
+		want  string
+		total int
+	}{
+		{nil, "Available:   0 eggs", 0},
+		{[]string{"add", "12"}, "Added 12 eggs. Total: 12", 12},
+		{[]string{"set", "5"}, "Inventory set to 5 eggs.", 5},
+		{nil, "Available:   5 eggs", 5},
+	}
+	for _, step := range steps {
+		var out bytes.Buffer
+		if err := runInventory(ctx, database, step.args, &out); err != nil {
+			t.Fatalf("runInventory(%v): %v", step.args, err)
+		}
+		if !strings.Contains(out.String(), step.want) {
+			t.Errorf("runInventory(%v) output %q, want %q", step.args, out.String(), step.want)
+		}
+		if n, _ := database.GetInventory(ctx); n != step.total {
+			t.Errorf("after %v inventory = %d, want %d", step.args, n, step.total)
+		}
+	}
+}
+
+func TestRunInventory_InvalidQuantity(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	if err := database.SetInventory(ctx, 3); err != nil {
+		t.Fatalf("SetInventory: %v", err)
+	}
+
+	for _, args := range [][]string{{"add", "0"}, {"add", "x"}, {"set", "-1"}} {
+		if err := runInventory(ctx, database, args, &bytes.Buffer{}); err == nil {
+			t.Errorf("runInventory(%v) succeeded, want an error", args)
+		}
+	}
+	if n, _ := database.GetInventory(ctx); n != 3 {
+		t.Errorf("inventory = %d, want unchanged 3", n)
+	}
+}
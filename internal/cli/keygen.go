@@ -0,0 +1,218 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// keygenOptions control what keygen derives and prints.
+type keygenOptions struct {
+	fromNsec   string // Existing key to inspect instead of generating one
+	quiet      bool   // Print only the nsec
+	configPath string // If set, write the npub to nostr.bot_npub in this file
+	passphrase string // If set, print a NIP-49 ncryptsec encrypted with it instead of the nsec
+}
+
+// keygenLogN is the NIP-49 scrypt cost (log2 N) of keys keygen encrypts.
+const keygenLogN = 16
+
+var keygenCmd = &cobra.Command{
+	Use:   "keygen",
+	Short: "Generate a bot keypair",
+	Long: `Generate a new Nostr keypair for the bot. The nsec is printed to stderr and
+the npub and hex pubkey to stdout. Put the nsec in EGGBOT_NSEC.
+
+With --encrypt, a NIP-49 ncryptsec is printed instead of the nsec, encrypted
+with EGGBOT_PASSPHRASE or a passphrase typed twice at the terminal. Put it in
+EGGBOT_NCRYPTSEC; the bot then needs the passphrase to start.
+
+With --from-nsec, the npub and hex pubkey of an existing key are shown instead,
+which helps track down "does not match bot_npub" errors; with --encrypt, its
+ncryptsec is printed too. --write-config stores the npub as nostr.bot_npub in
+the config file.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var opts keygenOptions
+		flags := cmd.Flags()
+		opts.fromNsec, _ = flags.GetString("from-nsec")
+		opts.quiet, _ = flags.GetBool("quiet")
+		if encrypt, _ := flags.GetBool("encrypt"); encrypt {
+			passphrase, err := config.Passphrase("Passphrase: ", true)
+			if errors.Is(err, config.ErrNoTerminal) {
+				return errors.New("--encrypt needs EGGBOT_PASSPHRASE or a terminal to type the passphrase in")
+			}
+			if err != nil {
+				return err
+			}
+			opts.passphrase = passphrase
+		}
+		if write, _ := flags.GetBool("write-config"); write {
+			path, err := configFilePath()
+			if err != nil {
+				return err
+			}
+			opts.configPath = path
+		}
+		return runKeygen(opts, cmd.OutOrStdout(), cmd.ErrOrStderr())
+	},
+}
+
+func init() {
+	keygenCmd.Flags().String("from-nsec", "", "show the npub and hex pubkey for an existing nsec")
+	keygenCmd.Flags().Bool("quiet", false, "print only the nsec, for scripting")
+	keygenCmd.Flags().Bool("encrypt", false, "print a passphrase-encrypted ncryptsec (NIP-49) instead of the nsec")
+	keygenCmd.Flags().Bool("write-config", false, "write the npub to nostr.bot_npub in the config file")
+	rootCmd.AddCommand(keygenCmd)
+}
+
+// runKeygen generates or decodes a key and prints its encodings.
+func runKeygen(opts keygenOptions, out, errOut io.Writer) error {
+	secretHex := nostr.GeneratePrivateKey()
+	if opts.fromNsec != "" {
+		var err error
+		if secretHex, err = decodeNsec(opts.fromNsec); err != nil {
+			return fmt.Errorf("--from-nsec: %w", err)
+		}
+	}
+
+	pubkeyHex, err := nostr.GetPublicKey(secretHex)
+	if err != nil {
+		return fmt.Errorf("deriving public key: %w", err)
+	}
+	npub, err := nip19.EncodePublicKey(pubkeyHex)
+	if err != nil {
+		return fmt.Errorf("encoding npub: %w", err)
+	}
+	nsec, err := nip19.EncodePrivateKey(secretHex)
+	if err != nil {
+		return fmt.Errorf("encoding nsec: %w", err)
+	}
+	secret := nsec
+	if opts.passphrase != "" {
+		// A key passed on the command line has been exposed in plain text
+		security := nip49.NotKnownToHaveBeenHandledInsecurely
+		if opts.fromNsec != "" {
+			security = nip49.KnownToHaveBeenHandledInsecurely
+		}
+		if secret, err = nip49.Encrypt(secretHex, opts.passphrase, keygenLogN, security); err != nil {
+			return fmt.Errorf("encrypting key: %w", err)
+		}
+	}
+
+	if opts.configPath != "" {
+		if err := setConfigValue(opts.configPath, "nostr", "bot_npub", npub); err != nil {
+			return err
+		}
+	}
+
+	if opts.quiet {
+		_, _ = fmt.Fprintln(out, secret)
+		return nil
+	}
+
+	switch {
+	case opts.passphrase != "":
+		_, _ = fmt.Fprintln(errOut, "Anyone with this ncryptsec and its passphrase controls the bot. Store it in EGGBOT_NCRYPTSEC.")
+		_, _ = fmt.Fprintf(errOut, "ncryptsec: %s\n", secret)
+	case opts.fromNsec == "":
+		_, _ = fmt.Fprintln(errOut, "WARNING: anyone with this nsec controls the bot. Store it only in EGGBOT_NSEC.")
+		_, _ = fmt.Fprintf(errOut, "nsec: %s\n", nsec)
+	}
+	_, _ = fmt.Fprintf(out, "npub: %s\n", npub)
+	_, _ = fmt.Fprintf(out, "hex:  %s\n", pubkeyHex)
+	if opts.configPath != "" {
+		_, _ = fmt.Fprintf(out, "Wrote nostr.bot_npub to %s\n", opts.configPath)
+	}
+	return nil
+}
+
+// configFilePath returns the --config path or the default $HOME/.eggbot.yaml.
+func configFilePath() (string, error) {
+	if cfgFile != "" {
+		return cfgFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("finding home directory: %w", err)
+	}
+	return filepath.Join(home, ".eggbot.yaml"), nil
+}
+
+// setConfigValue sets section.key in a YAML config file, keeping the rest of
+// the file, including comments, as it was.
+func setConfigValue(path, section, key, value string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%s does not exist; run \"eggbot init\" first", path)
+	} else if err != nil {
+		return fmt.Errorf("checking %s: %w", path, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: expected a mapping at the top level", path)
+	}
+
+	sectionNode := mappingValue(root, section)
+	if sectionNode == nil {
+		sectionNode = &yaml.Node{Kind: yaml.MappingNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, sectionNode)
+	} else if sectionNode.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s: %s is not a mapping", path, section)
+	}
+
+	if valueNode := mappingValue(sectionNode, key); valueNode != nil {
+		valueNode.Kind, valueNode.Tag, valueNode.Value = yaml.ScalarNode, "", value
+	} else {
+		sectionNode.Content = append(sectionNode.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value})
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	enc := yaml.NewEncoder(f)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	if err := enc.Close(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// mappingValue returns the value node for key in a YAML mapping, or nil.
+func mappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
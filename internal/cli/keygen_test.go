@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestRunKeygen_Generates(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := runKeygen(keygenOptions{}, &out, &errOut); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+
+	if !strings.Contains(errOut.String(), "WARNING") {
+		t.Errorf("stderr should warn about the nsec, got: %s", errOut.String())
+	}
+	nsec := strings.TrimSpace(strings.TrimPrefix(strings.Split(errOut.String(), "\n")[1], "nsec:"))
+	npub, err := npubFromNsec(nsec)
+	if err != nil {
+		t.Fatalf("generated nsec %q is invalid: %v", nsec, err)
+	}
+	if strings.Contains(out.String(), "nsec1") {
+		t.Error("stdout must not contain the nsec")
+	}
+	if !strings.Contains(out.String(), "npub: "+npub) {
+		t.Errorf("stdout should contain the matching npub %s, got: %s", npub, out.String())
+	}
+}
+
+func TestRunKeygen_FromNsec(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := runKeygen(keygenOptions{fromNsec: testNsec}, &out, &errOut); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+
+	_, pubkeyHex, _ := nip19.Decode(testBotNpub)
+	for _, want := range []string{"npub: " + testBotNpub, "hex:  " + pubkeyHex.(string)} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("stdout missing %q, got: %s", want, out.String())
+		}
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("an existing nsec should not be printed again, got: %s", errOut.String())
+	}
+
+	if err := runKeygen(keygenOptions{fromNsec: testBotNpub}, &out, &errOut); err == nil {
+		t.Error("expected error for an npub passed as --from-nsec")
+	}
+}
+
+func TestRunKeygen_Quiet(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := runKeygen(keygenOptions{fromNsec: testNsec, quiet: true}, &out, &errOut); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+	if out.String() != testNsec+"\n" {
+		t.Errorf("quiet output = %q, want only the nsec", out.String())
+	}
+	if errOut.Len() != 0 {
+		t.Errorf("quiet should not write to stderr, got: %s", errOut.String())
+	}
+}
+
+func TestRunKeygen_Encrypt(t *testing.T) {
+	var out, errOut bytes.Buffer
+	if err := runKeygen(keygenOptions{fromNsec: testNsec, passphrase: "correct horse"}, &out, &errOut); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+	if strings.Contains(errOut.String(), "nsec1") || strings.Contains(out.String(), "nsec1") {
+		t.Errorf("the plain nsec must not be printed:\n%s%s", errOut.String(), out.String())
+	}
+	ncryptsec := strings.TrimSpace(strings.TrimPrefix(strings.Split(errOut.String(), "\n")[1], "ncryptsec:"))
+	secretHex, err := config.DecryptNcryptsec(ncryptsec, "correct horse")
+	if err != nil {
+		t.Fatalf("decrypting %q: %v", ncryptsec, err)
+	}
+	if want, _ := decodeNsec(testNsec); secretHex != want {
+		t.Errorf("ncryptsec holds %s, want the --from-nsec key", secretHex)
+	}
+
+	// Quiet prints only the ncryptsec
+	out.Reset()
+	if err := runKeygen(keygenOptions{quiet: true, passphrase: "correct horse"}, &out, &bytes.Buffer{}); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "ncryptsec1") {
+		t.Errorf("quiet output = %q, want an ncryptsec", out.String())
+	}
+}
+
+func TestRunKeygen_WriteConfig(t *testing.T) {
+	path := testConfigPath(t)
+	original := "# eggbot config\nnostr:\n  relays:\n    - wss://relay.example.com\n  bot_npub: npub1old\nadmins:\n  - " + testExpectedNpub + "\n"
+	if err := os.WriteFile(path, []byte(original), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	opts := keygenOptions{fromNsec: testNsec, configPath: path}
+	if err := runKeygen(opts, &bytes.Buffer{}, &bytes.Buffer{}); err != nil {
+		t.Fatalf("runKeygen: %v", err)
+	}
+
+	cfg := loadWritten(t, path)
+	if cfg.Nostr.BotNpub != testBotNpub {
+		t.Errorf("BotNpub = %q, want %q", cfg.Nostr.BotNpub, testBotNpub)
+	}
+	if len(cfg.Nostr.Relays) != 1 || len(cfg.Admins) != 1 {
+		t.Errorf("other settings lost: relays %v, admins %v", cfg.Nostr.Relays, cfg.Admins)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# eggbot config") {
+		t.Errorf("comments should be kept:\n%s", data)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	missing := keygenOptions{configPath: path + ".missing"}
+	if err := runKeygen(missing, &bytes.Buffer{}, &bytes.Buffer{}); err == nil || !strings.Contains(err.Error(), "eggbot init") {
+		t.Errorf("expected missing config error, got %v", err)
+	}
+}
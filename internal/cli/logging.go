@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"io"
+	"log/slog"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+)
+
+// newLogger builds the process logger from the config. Verbose forces debug
+// level. Every record passes through a redactor, so the bot secret, nsecs and
+// full invoices never reach the output.
+func newLogger(w io.Writer, cfg *config.Config) *slog.Logger {
+	opts := &slog.HandlerOptions{
+		Level:       cfg.Log.Level,
+		ReplaceAttr: newRedactor(cfg.Nostr.BotSecretHex).replaceAttr,
+	}
+	if cfg.Verbose {
+		opts.Level = slog.LevelDebug
+	}
+
+	var handler slog.Handler
+	if cfg.Log.Format == config.LogFormatJSON {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(handler)
+}
+
+// logContent logs private message content (decrypted DMs, command args and
+// replies) at debug level. Content is only logged when verbose is set, never
+// from log.level alone.
+func logContent(cfg *config.Config, msg string, args ...any) {
+	if cfg.Verbose {
+		slog.Debug(msg, args...)
+	}
+}
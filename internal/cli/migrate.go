@@ -0,0 +1,172 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+var dbVersionCmd = &cobra.Command{
+	Use:          "version",
+	Short:        "Print the newest applied migration version",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+
+		version, err := database.MigrationVersion(cmd.Context())
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintln(cmd.OutOrStdout(), version)
+		return nil
+	},
+}
+
+var dbUpByOneCmd = &cobra.Command{
+	Use:          "up-by-one",
+	Short:        "Apply the next pending schema migration",
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withConfiguredDB(cmd, runMigrateUpByOne)
+	},
+}
+
+var dbDownCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Roll back the newest applied schema migration",
+	Long: `Roll back the newest applied migration. Rolling back drops the tables and
+columns it added, with their data.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return withConfiguredDB(cmd, runMigrateDown)
+	},
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{dbUpCmd, dbUpByOneCmd, dbDownCmd} {
+		cmd.Flags().Bool("dry-run", false, "print the SQL without running it")
+	}
+	dbCmd.AddCommand(dbVersionCmd, dbUpByOneCmd, dbDownCmd)
+}
+
+// withConfiguredDB opens the configured database and runs a migration step,
+// honouring --dry-run where the command has it.
+func withConfiguredDB(cmd *cobra.Command, step func(ctx context.Context, database *db.DB, out io.Writer, dryRun bool) error) error {
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	database, _, err := openConfiguredDB()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = database.Close() }()
+	return step(cmd.Context(), database, cmd.OutOrStdout(), dryRun)
+}
+
+// runMigrateUp applies every pending migration, or prints their SQL.
+func runMigrateUp(ctx context.Context, database *db.DB, out io.Writer, dryRun bool) error {
+	if dryRun {
+		pending, err := pendingMigrations(ctx, database)
+		if err != nil {
+			return err
+		}
+		return printMigrationSQL(out, pending, true)
+	}
+	applied, err := database.MigrateUp(ctx)
+	if err != nil {
+		return err
+	}
+	printMigrations(out, "Applied", applied)
+	return nil
+}
+
+// runMigrateUpByOne applies the next pending migration, or prints its SQL.
+func runMigrateUpByOne(ctx context.Context, database *db.DB, out io.Writer, dryRun bool) error {
+	if dryRun {
+		pending, err := pendingMigrations(ctx, database)
+		if err != nil {
+			return err
+		}
+		return printMigrationSQL(out, pending[:min(len(pending), 1)], true)
+	}
+	applied, err := database.MigrateUpByOne(ctx)
+	if err != nil {
+		return err
+	}
+	printMigrations(out, "Applied", optionalMigration(applied))
+	return nil
+}
+
+// runMigrateDown rolls back the newest applied migration, or prints its SQL.
+func runMigrateDown(ctx context.Context, database *db.DB, out io.Writer, dryRun bool) error {
+	if dryRun {
+		migrations, err := database.MigrationStatus(ctx)
+		if err != nil {
+			return err
+		}
+		var newest []db.Migration
+		for _, m := range migrations {
+			if m.Applied {
+				newest = []db.Migration{m}
+			}
+		}
+		return printMigrationSQL(out, newest, false)
+	}
+	rolledBack, err := database.MigrateDown(ctx)
+	if err != nil {
+		return err
+	}
+	printMigrations(out, "Rolled back", optionalMigration(rolledBack))
+	return nil
+}
+
+// pendingMigrations returns the migrations not yet applied, oldest first.
+func pendingMigrations(ctx context.Context, database *db.DB) ([]db.Migration, error) {
+	migrations, err := database.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var pending []db.Migration
+	for _, m := range migrations {
+		if !m.Applied {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+func optionalMigration(m *db.Migration) []db.Migration {
+	if m == nil {
+		return nil
+	}
+	return []db.Migration{*m}
+}
+
+// printMigrationSQL writes the up or down SQL of each migration under a
+// comment naming it.
+func printMigrationSQL(w io.Writer, migrations []db.Migration, up bool) error {
+	if len(migrations) == 0 {
+		_, _ = fmt.Fprintln(w, "No migrations to run")
+		return nil
+	}
+	direction := "down"
+	if up {
+		direction = "up"
+	}
+	for _, m := range migrations {
+		sql, err := db.MigrationSQL(m.Name, up)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "-- %s (%s)\n%s\n\n", m.Name, direction, sql)
+	}
+	return nil
+}
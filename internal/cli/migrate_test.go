@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintMigrationStatus(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, filepath.Join(t.TempDir(), "eggbot.db"))
+	if _, err := database.MigrateDownTo(ctx, 8); err != nil {
+		t.Fatalf("MigrateDownTo: %v", err)
+	}
+	migrations, err := database.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+
+	var out bytes.Buffer
+	printMigrationStatus(&out, migrations)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != len(migrations) {
+		t.Fatalf("got %d lines for %d migrations:\n%s", len(lines), len(migrations), out.String())
+	}
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		wantState := "applied"
+		if i >= 8 {
+			wantState = "pending"
+		}
+		if len(fields) < 2 || fields[0] != wantState || fields[1] != migrations[i].Name {
+			t.Errorf("line %d = %q, want %s %s", i+1, line, wantState, migrations[i].Name)
+		}
+		// Applied lines end with the date and time they were applied
+		if wantState == "applied" && len(fields) != 4 {
+			t.Errorf("line %d = %q, want an applied time", i+1, line)
+		}
+	}
+}
+
+func TestRunMigrateSteps(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, filepath.Join(t.TempDir(), "eggbot.db"))
+
+	var out bytes.Buffer
+	if err := runMigrateDown(ctx, database, &out, false); err != nil {
+		t.Fatalf("runMigrateDown: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Rolled back 019_") {
+		t.Errorf("down output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrateUpByOne(ctx, database, &out, false); err != nil {
+		t.Fatalf("runMigrateUpByOne: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Applied 019_") {
+		t.Errorf("up-by-one output = %q", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrateUp(ctx, database, &out, false); err != nil {
+		t.Fatalf("runMigrateUp: %v", err)
+	}
+	if out.String() != "No migrations to run\n" {
+		t.Errorf("up output = %q", out.String())
+	}
+}
+
+func TestRunMigrate_DryRun(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, filepath.Join(t.TempDir(), "eggbot.db"))
+	if _, err := database.MigrateDownTo(ctx, 8); err != nil {
+		t.Fatalf("MigrateDownTo: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runMigrateUpByOne(ctx, database, &out, true); err != nil {
+		t.Fatalf("runMigrateUpByOne: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "-- 009_admins.sql (up)\n") || !strings.Contains(out.String(), "CREATE TABLE IF NOT EXISTS admins") {
+		t.Errorf("up-by-one dry run = %q", out.String())
+	}
+	if strings.Contains(out.String(), "010_") {
+		t.Errorf("up-by-one dry run should only show the next migration:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrateUp(ctx, database, &out, true); err != nil {
+		t.Fatalf("runMigrateUp: %v", err)
+	}
+	if !strings.Contains(out.String(), "-- 009_admins.sql (up)") || !strings.Contains(out.String(), "-- 010_high_water_marks_per_kind.sql (up)") {
+		t.Errorf("up dry run = %q", out.String())
+	}
+
+	out.Reset()
+	if err := runMigrateDown(ctx, database, &out, true); err != nil {
+		t.Fatalf("runMigrateDown: %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "-- 008_invoices.sql (down)\n") {
+		t.Errorf("down dry run = %q", out.String())
+	}
+
+	// Nothing was applied or rolled back
+	if version, err := database.MigrationVersion(ctx); err != nil || version != 8 {
+		t.Errorf("version after dry runs = %d, %v; want 8", version, err)
+	}
+}
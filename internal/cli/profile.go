@@ -0,0 +1,101 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/nostr"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/spf13/cobra"
+)
+
+// profilePublishTimeout bounds fetching the current profile and publishing.
+const profilePublishTimeout = 30 * time.Second
+
+var publishProfileCmd = &cobra.Command{
+	Use:   "publish-profile",
+	Short: "Publish the bot's Nostr profile (kind 0) from the config",
+	Long: `Publish the bot's profile metadata (kind 0) to the configured relays, signed
+with EGGBOT_NSEC. The name, about and picture come from profile.name,
+profile.about and profile.picture, and lud16 from lightning.address, which
+Nostr clients need to zap the bot's profile.
+
+The current profile is fetched first. Fields set elsewhere, like a banner,
+are kept, and nothing is published if the relays already have the same
+profile. If no relay answers, nothing is published, so fields set elsewhere
+are never dropped. Prints the published event ID.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.LoadWithSecrets()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		kr, err := keyer.NewPlainKeySigner(cfg.Nostr.BotSecretHex)
+		if err != nil {
+			return fmt.Errorf("creating keyer: %w", err)
+		}
+		if cfg.Lightning.LightningAddress == "" {
+			_, _ = fmt.Fprintln(cmd.ErrOrStderr(), "Warning: lightning.address is not set, so the profile has no lud16 and zaps to it won't resolve")
+		}
+
+		ctx, cancel := context.WithTimeout(cmd.Context(), profilePublishTimeout)
+		defer cancel()
+		relayMgr := nostr.NewRelayManager(cfg.Nostr.PrimaryRelays, cfg.Nostr.FallbackRelays, cfg.Nostr.BotPubkeyHex, nil)
+		relayMgr.Open(ctx)
+		defer relayMgr.Close()
+
+		event, err := relayMgr.PublishProfile(ctx, kr, profileFromConfig(cfg))
+		if err != nil {
+			return fmt.Errorf("publishing profile: %w", err)
+		}
+		printProfileResult(cmd.OutOrStdout(), event)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(publishProfileCmd)
+}
+
+// profileFromConfig returns the profile to publish for cfg.
+func profileFromConfig(cfg *config.Config) nostr.Profile {
+	return nostr.Profile{
+		Name:    cfg.Profile.Name,
+		About:   cfg.Profile.About,
+		Picture: cfg.Profile.Picture,
+		Lud16:   cfg.Lightning.LightningAddress,
+	}
+}
+
+// printProfileResult reports the published profile event, or that there was
+// nothing to publish.
+func printProfileResult(out io.Writer, event *gonostr.Event) {
+	if event == nil {
+		_, _ = fmt.Fprintln(out, "Profile unchanged; nothing published.")
+		return
+	}
+	_, _ = fmt.Fprintf(out, "Published profile %s\n", event.ID)
+}
+
+// publishProfileOnStart publishes the configured profile when
+// profile.publish_on_start is set. Failures are logged; the bot keeps running.
+func publishProfileOnStart(ctx context.Context, relayMgr *nostr.RelayManager, kr gonostr.Keyer, cfg *config.Config) {
+	ctx, cancel := context.WithTimeout(ctx, profilePublishTimeout)
+	defer cancel()
+	event, err := relayMgr.PublishProfile(ctx, kr, profileFromConfig(cfg))
+	if err != nil {
+		slog.Error("failed to publish profile", "error", err)
+		return
+	}
+	if event == nil {
+		slog.Info("profile up to date")
+		return
+	}
+	slog.Info("published profile", "event_id", event.ID)
+}
@@ -0,0 +1,68 @@
+package cli
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// bolt11KeepChars is how much of an invoice survives redaction: enough to see
+// the network and amount, not enough to pay it.
+const bolt11KeepChars = 16
+
+var (
+	// bolt11Pattern matches BOLT11 invoices on mainnet, testnet, signet and regtest.
+	bolt11Pattern = regexp.MustCompile(`(?i)\bln(?:bcrt|bc|tbs|tb)[0-9a-z]{20,}`)
+	// nsecPattern matches bech32-encoded secret keys.
+	nsecPattern = regexp.MustCompile(`\bnsec1[02-9ac-hj-np-z]{58}\b`)
+)
+
+// redactor scrubs secrets and payable invoices from log output. NIP-04 shared
+// secrets are derived inside the dm package and never handed to the logger.
+type redactor struct {
+	secrets []string
+}
+
+// newRedactor creates a redactor that masks the given literal secrets, such as
+// the bot's secret key hex, in addition to any nsec or bolt11 it sees.
+func newRedactor(secrets ...string) *redactor {
+	r := &redactor{}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	return r
+}
+
+// Redact returns s with secrets replaced and invoices cut to a short prefix.
+func (r *redactor) Redact(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, "[REDACTED]")
+	}
+	s = nsecPattern.ReplaceAllString(s, "nsec1[REDACTED]")
+	return bolt11Pattern.ReplaceAllStringFunc(s, func(invoice string) string {
+		return invoice[:bolt11KeepChars] + "..."
+	})
+}
+
+// replaceAttr is a slog.HandlerOptions.ReplaceAttr hook that redacts the
+// message, string attributes, errors and string slices.
+func (r *redactor) replaceAttr(_ []string, a slog.Attr) slog.Attr {
+	switch a.Value.Kind() {
+	case slog.KindString:
+		a.Value = slog.StringValue(r.Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			a.Value = slog.StringValue(r.Redact(v.Error()))
+		case []string:
+			redacted := make([]string, len(v))
+			for i, s := range v {
+				redacted[i] = r.Redact(s)
+			}
+			a.Value = slog.AnyValue(redacted)
+		}
+	}
+	return a
+}
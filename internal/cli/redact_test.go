@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+)
+
+const (
+	testSecretHex = "7f7ff03d123792d6ac594bfa67bf6d0c0ab55b6b1fdb6249303fe861f1ccba9a"
+	testNsec      = "nsec10allq0gjx7fddtzef0ax00mdps9t2kmtrldkyjfs8l5xruwvh2dq0lhhkp"
+	testInvoice   = "lnbc10u1pjq8f2spp5qyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqszqgpqyqsdqqcqzzsxqyz5vqsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygs9qyyssq"
+)
+
+func TestRedactor_Redact(t *testing.T) {
+	r := newRedactor(testSecretHex, "")
+
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		notWant string
+	}{
+		{
+			name:    "secret hex",
+			input:   "keyer failed for " + testSecretHex,
+			want:    "keyer failed for [REDACTED]",
+			notWant: testSecretHex,
+		},
+		{
+			name:    "nsec",
+			input:   "bad key " + testNsec,
+			want:    "bad key nsec1[REDACTED]",
+			notWant: testNsec,
+		},
+		{
+			name:    "invoice keeps prefix",
+			input:   "Pay invoice:\n" + testInvoice + "\n\nOr zap",
+			want:    "Pay invoice:\n" + testInvoice[:bolt11KeepChars] + "...\n\nOr zap",
+			notWant: testInvoice,
+		},
+		{
+			name:    "uppercase invoice",
+			input:   strings.ToUpper(testInvoice),
+			want:    strings.ToUpper(testInvoice[:bolt11KeepChars]) + "...",
+			notWant: strings.ToUpper(testInvoice),
+		},
+		{
+			name:  "plain text untouched",
+			input: "order 12 for npub1abc",
+			want:  "order 12 for npub1abc",
+		},
+		{
+			name:  "word starting with ln untouched",
+			input: "lnbc is the mainnet prefix",
+			want:  "lnbc is the mainnet prefix",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := r.Redact(tt.input)
+			if got != tt.want {
+				t.Errorf("Redact() = %q, want %q", got, tt.want)
+			}
+			if tt.notWant != "" && strings.Contains(got, tt.notWant) {
+				t.Errorf("Redact() leaked %q", tt.notWant)
+			}
+		})
+	}
+}
+
+func TestNewLogger_RedactsSecrets(t *testing.T) {
+	for _, format := range []string{config.LogFormatText, config.LogFormatJSON} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			cfg := &config.Config{Log: config.LogConfig{Format: format}}
+			cfg.Nostr.BotSecretHex = testSecretHex
+			logger := newLogger(&buf, cfg)
+
+			logger.Info("signing with "+testSecretHex,
+				"result", "Pay invoice: "+testInvoice,
+				"error", errors.New("decrypt with "+testSecretHex+" failed"),
+				"args", []string{"key", testNsec})
+
+			output := buf.String()
+			for _, leak := range []string{testSecretHex, testNsec, testInvoice} {
+				if strings.Contains(output, leak) {
+					t.Errorf("log output leaked %q: %s", leak, output)
+				}
+			}
+			if !strings.Contains(output, testInvoice[:bolt11KeepChars]) {
+				t.Errorf("log output should keep invoice prefix, got: %s", output)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+// relayTestTimeout bounds the connection and EOSE wait for each relay.
+const relayTestTimeout = 5 * time.Second
+
+var relayTestCmd = &cobra.Command{
+	Use:   "relay-test",
+	Short: "Connect to each configured relay and time a subscription",
+	Long: `Connect to each configured relay (primary and fallback) over WebSocket,
+subscribe to the bot's latest event and wait up to 5 seconds for the relay to
+signal the end of stored events (EOSE). Prints the round trip time or the
+failure for each relay and exits non-zero if any relay fails.
+
+The bot's pubkey is taken from nostr.bot_npub, or derived from EGGBOT_NSEC
+when that is not set.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		pubkeyHex, err := botPubkeyHex(cfg)
+		if err != nil {
+			return err
+		}
+		return runRelayTest(cmd.Context(), cmd.OutOrStdout(), cfg.Nostr.Relays, pubkeyHex, relayTestTimeout)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(relayTestCmd)
+}
+
+// botPubkeyHex returns the bot's hex pubkey from the config npub or EGGBOT_NSEC.
+func botPubkeyHex(cfg *config.Config) (string, error) {
+	if cfg.Nostr.BotNpub != "" {
+		prefix, value, err := nip19.Decode(cfg.Nostr.BotNpub)
+		if err != nil || prefix != "npub" {
+			return "", fmt.Errorf("invalid nostr.bot_npub %q", cfg.Nostr.BotNpub)
+		}
+		return value.(string), nil
+	}
+	nsec := os.Getenv("EGGBOT_NSEC")
+	if nsec == "" {
+		return "", errors.New("set nostr.bot_npub or EGGBOT_NSEC to identify the bot")
+	}
+	secretHex, err := decodeNsec(nsec)
+	if err != nil {
+		return "", fmt.Errorf("EGGBOT_NSEC: %w", err)
+	}
+	return gonostr.GetPublicKey(secretHex)
+}
+
+// runRelayTest tests every relay, printing a line for each, and returns an
+// error if any failed.
+func runRelayTest(ctx context.Context, out io.Writer, relays []string, pubkeyHex string, timeout time.Duration) error {
+	failed := 0
+	for _, url := range relays {
+		latency, err := testRelay(ctx, url, pubkeyHex, timeout)
+		if err != nil {
+			failed++
+			_, _ = fmt.Fprintf(out, "[FAIL] %s: %v\n", url, err)
+			continue
+		}
+		_, _ = fmt.Fprintf(out, "[PASS] %s: EOSE in %s\n", url, latency.Round(time.Millisecond))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d relays failed", failed, len(relays))
+	}
+	return nil
+}
+
+// testRelay connects to a relay and subscribes to the bot's latest event,
+// returning the time from dialing to EOSE.
+func testRelay(ctx context.Context, url, pubkeyHex string, timeout time.Duration) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	start := time.Now()
+
+	relay, err := gonostr.RelayConnect(ctx, url)
+	if err != nil {
+		return 0, fmt.Errorf("connecting: %w", err)
+	}
+	defer func() { _ = relay.Close() }()
+
+	sub, err := relay.Subscribe(ctx, gonostr.Filters{{Authors: []string{pubkeyHex}, Limit: 1}})
+	if err != nil {
+		return 0, fmt.Errorf("subscribing: %w", err)
+	}
+	defer sub.Unsub()
+
+	events := sub.Events
+	for {
+		select {
+		case _, ok := <-events:
+			// Stored events must be drained before EOSE is delivered
+			if !ok {
+				events = nil
+			}
+		case <-sub.EndOfStoredEvents:
+			return time.Since(start), nil
+		case reason := <-sub.ClosedReason:
+			return 0, fmt.Errorf("subscription closed: %s", reason)
+		case <-ctx.Done():
+			return 0, fmt.Errorf("no EOSE within %s", timeout)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// newMockRelay starts a WebSocket relay that answers each REQ with EOSE when
+// eose is set, and otherwise never answers.
+func newMockRelay(t *testing.T, eose bool) string {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.CloseNow() }()
+
+		for {
+			_, data, err := conn.Read(r.Context())
+			if err != nil {
+				return
+			}
+			var msg []json.RawMessage
+			if json.Unmarshal(data, &msg) != nil || len(msg) < 2 || string(msg[0]) != `"REQ"` || !eose {
+				continue
+			}
+			reply, _ := json.Marshal([]json.RawMessage{json.RawMessage(`"EOSE"`), msg[1]})
+			if err := conn.Write(r.Context(), websocket.MessageText, reply); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http")
+}
+
+func TestRunRelayTest(t *testing.T) {
+	good := newMockRelay(t, true)
+	silent := newMockRelay(t, false)
+
+	var out bytes.Buffer
+	err := runRelayTest(context.Background(), &out, []string{good, silent}, testPubkeyHex, 500*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 relays failed") {
+		t.Fatalf("expected one failure, got %v", err)
+	}
+
+	output := out.String()
+	if !strings.Contains(output, "[PASS] "+good+": EOSE in ") {
+		t.Errorf("expected %s to pass:\n%s", good, output)
+	}
+	if !strings.Contains(output, "[FAIL] "+silent+": no EOSE within 500ms") {
+		t.Errorf("expected %s to time out:\n%s", silent, output)
+	}
+}
+
+func TestRunRelayTest_AllPass(t *testing.T) {
+	var out bytes.Buffer
+	relays := []string{newMockRelay(t, true), newMockRelay(t, true)}
+	if err := runRelayTest(context.Background(), &out, relays, testPubkeyHex, time.Second); err != nil {
+		t.Fatalf("runRelayTest: %v\n%s", err, out.String())
+	}
+	if strings.Count(out.String(), "[PASS]") != 2 {
+		t.Errorf("expected two passes:\n%s", out.String())
+	}
+}
+
+func TestRunRelayTest_Unreachable(t *testing.T) {
+	var out bytes.Buffer
+	err := runRelayTest(context.Background(), &out, []string{"ws://127.0.0.1:1"}, testPubkeyHex, time.Second)
+	if err == nil {
+		t.Fatal("expected an error for an unreachable relay")
+	}
+	if !strings.Contains(out.String(), "[FAIL] ws://127.0.0.1:1: connecting") {
+		t.Errorf("expected a connection failure:\n%s", out.String())
+	}
+}
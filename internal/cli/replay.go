@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay <file.json>",
+	Short: "Run a raw event through the bot without publishing",
+	Long: `Read a raw Nostr event (kind 4, 1059 or 9735) as JSON and run it through the
+same decryption, zap validation and command handling as "run", against the
+configured database. Each intermediate result is printed, and the DMs the bot
+would send are printed instead of published. Events already seen by the bot
+are replayed anyway.
+
+With --dry-run the event runs against a temporary copy of the database, so
+nothing it writes is kept. A copy is used rather than a rolled-back
+transaction because the commands open transactions of their own, which SQLite
+cannot nest. Like run, replay needs EGGBOT_NSEC.`,
+	Args:         cobra.ExactArgs(1),
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("reading event: %w", err)
+		}
+		cfg, err := config.LoadWithSecrets()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		slog.SetDefault(newLogger(os.Stderr, cfg))
+		ctx := cmd.Context()
+
+		database, err := db.Open(cfg.Database.Path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			scratch, cleanup, err := scratchCopy(ctx, database)
+			if err != nil {
+				return err
+			}
+			defer cleanup()
+			database = scratch
+		}
+
+		return runReplay(ctx, database, cfg, data, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	replayCmd.Flags().Bool("dry-run", false, "discard database changes made by the event")
+	rootCmd.AddCommand(replayCmd)
+}
+
+// scratchCopy snapshots database into a temporary file and opens it. cleanup
+// closes and removes the copy.
+func scratchCopy(ctx context.Context, database *db.DB) (*db.DB, func(), error) {
+	dir, err := os.MkdirTemp("", "eggbot-replay-")
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating scratch directory: %w", err)
+	}
+	path := filepath.Join(dir, "eggbot.db")
+	if err := database.Backup(ctx, path, false); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, fmt.Errorf("copying database: %w", err)
+	}
+	scratch, err := db.Open(path)
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, nil, err
+	}
+	return scratch, func() {
+		_ = scratch.Close()
+		_ = os.RemoveAll(dir)
+	}, nil
+}
+
+// runReplay decodes a raw event and handles it as run would, printing each
+// step and the DMs the bot would send.
+func runReplay(ctx context.Context, database *db.DB, cfg *config.Config, data []byte, out io.Writer) error {
+	var event gonostr.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return fmt.Errorf("decoding event: %w", err)
+	}
+	switch event.Kind {
+	case gonostr.KindEncryptedDirectMessage, gonostr.KindGiftWrap, gonostr.KindZap:
+	default:
+		return fmt.Errorf("event kind %d is not a DM (4, 1059) or zap receipt (9735)", event.Kind)
+	}
+
+	author, _ := nip19.EncodePublicKey(event.PubKey)
+	_, _ = fmt.Fprintf(out, "event %s kind %d from %s\n", event.ID, event.Kind, author)
+	// Relays hand run only events with valid signatures
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return fmt.Errorf("event %s has an invalid signature; run would never see it", event.ID)
+	}
+
+	kr, err := keyer.NewPlainKeySigner(cfg.Nostr.BotSecretHex)
+	if err != nil {
+		return fmt.Errorf("creating keyer: %w", err)
+	}
+	h := NewEventHandler(database, kr, func() *config.Config { return cfg }, nil,
+		commands.NewRoleCache(cfg.Permissions.RoleCacheTTL))
+	// Print replies instead of publishing them
+	h.send = func(recipientPubkeyHex, message, replyTo string, protocol dm.DMProtocol) {
+		recipient, err := nip19.EncodePublicKey(recipientPubkeyHex)
+		if err != nil {
+			recipient = recipientPubkeyHex
+		}
+		_, _ = fmt.Fprintf(out, "-> %s (%s)\n", recipient, protocolName(replyProtocol(cfg, protocol)))
+		for _, line := range strings.Split(message, "\n") {
+			_, _ = fmt.Fprintf(out, "   %s\n", line)
+		}
+	}
+	h.trace = func(step, detail string) {
+		_, _ = fmt.Fprintf(out, "%-10s %s\n", step+":", detail)
+	}
+
+	if event.Kind == gonostr.KindZap {
+		h.HandleZap(ctx, &event)
+	} else {
+		h.HandleDM(ctx, &event)
+	}
+	return nil
+}
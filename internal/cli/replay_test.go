@@ -0,0 +1,180 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	gonostr "github.com/nbd-wtf/go-nostr"
+)
+
+func eventJSON(t *testing.T, event *gonostr.Event) []byte {
+	t.Helper()
+	data, err := json.Marshal(event)
+	if err != nil {
+		t.Fatalf("encoding event: %v", err)
+	}
+	return data
+}
+
+func TestRunReplay_DMs(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	database := newTestDB(t, ":memory:", admin.npub)
+
+	legacy, err := dm.WrapLegacyResponse(ctx, admin.kr, admin.secretHex, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory add 12", "")
+	if err != nil {
+		t.Fatalf("wrapping NIP-04 DM: %v", err)
+	}
+	giftWrap, err := dm.WrapResponse(ctx, admin.kr, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory", "", "")
+	if err != nil {
+		t.Fatalf("wrapping NIP-17 DM: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		event *gonostr.Event
+		want  []string
+	}{
+		{
+			name:  "NIP-04",
+			event: legacy,
+			want: []string{
+				`decrypted: NIP-04 from ` + admin.npub + `: "inventory add 12"`,
+				"command:   inventory add 12",
+				"result:    ok",
+				"-> " + admin.npub + " (NIP-04)",
+			},
+		},
+		{
+			name:  "NIP-17",
+			event: giftWrap,
+			want: []string{
+				`decrypted: NIP-17 from ` + admin.npub + `: "inventory"`,
+				"-> " + admin.npub + " (NIP-17)",
+				"Available:  12 eggs",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := runReplay(ctx, database, cfg, eventJSON(t, tt.event), &out); err != nil {
+				t.Fatalf("runReplay: %v", err)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("output missing %q:\n%s", want, out.String())
+				}
+			}
+		})
+	}
+}
+
+func TestRunReplay_PermissionDenied(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	stranger := newTestSender(t)
+	database := newTestDB(t, ":memory:")
+
+	event, err := dm.WrapLegacyResponse(ctx, stranger.kr, stranger.secretHex, stranger.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory add 12", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := runReplay(ctx, database, cfg, eventJSON(t, event), &out); err != nil {
+		t.Fatalf("runReplay: %v", err)
+	}
+	if !strings.Contains(out.String(), "permission:") || !strings.Contains(out.String(), "Permission denied") {
+		t.Errorf("expected a permission failure and reply:\n%s", out.String())
+	}
+}
+
+func TestRunReplay_InvalidZap(t *testing.T) {
+	provider := newTestSender(t)
+	receipt := &gonostr.Event{
+		Kind:      gonostr.KindZap,
+		CreatedAt: gonostr.Now(),
+		Tags:      gonostr.Tags{{"p", testPubkeyHex}},
+	}
+	if err := receipt.Sign(provider.secretHex); err != nil {
+		t.Fatalf("signing receipt: %v", err)
+	}
+
+	var out bytes.Buffer
+	err := runReplay(context.Background(), newTestDB(t, ":memory:"), testHandlerConfig(t), eventJSON(t, receipt), &out)
+	if err != nil {
+		t.Fatalf("runReplay: %v", err)
+	}
+	if !strings.Contains(out.String(), "validate:  invalid zap receipt: missing description tag") {
+		t.Errorf("expected the validation error:\n%s", out.String())
+	}
+	if strings.Contains(out.String(), "->") {
+		t.Errorf("an invalid zap should not be answered:\n%s", out.String())
+	}
+}
+
+func TestRunReplay_Rejects(t *testing.T) {
+	sender := newTestSender(t)
+	note := &gonostr.Event{Kind: gonostr.KindTextNote, CreatedAt: gonostr.Now(), Content: "hello"}
+	if err := note.Sign(sender.secretHex); err != nil {
+		t.Fatalf("signing note: %v", err)
+	}
+	tampered := &gonostr.Event{Kind: gonostr.KindEncryptedDirectMessage, CreatedAt: gonostr.Now(), Content: "x"}
+	if err := tampered.Sign(sender.secretHex); err != nil {
+		t.Fatalf("signing DM: %v", err)
+	}
+	tampered.Content = "y"
+
+	tests := []struct {
+		name    string
+		data    []byte
+		wantErr string
+	}{
+		{"not JSON", []byte("{"), "decoding event"},
+		{"wrong kind", eventJSON(t, note), "kind 1"},
+		{"bad signature", eventJSON(t, tampered), "invalid signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := runReplay(context.Background(), newTestDB(t, ":memory:"), testHandlerConfig(t), tt.data, &bytes.Buffer{})
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestScratchCopy_DiscardsWrites(t *testing.T) {
+	ctx := context.Background()
+	cfg := testHandlerConfig(t)
+	admin := newTestSender(t)
+	database := newTestDB(t, filepath.Join(t.TempDir(), "eggbot.db"), admin.npub)
+
+	scratch, cleanup, err := scratchCopy(ctx, database)
+	if err != nil {
+		t.Fatalf("scratchCopy: %v", err)
+	}
+	event, err := dm.WrapLegacyResponse(ctx, admin.kr, admin.secretHex, admin.pubkeyHex, cfg.Nostr.BotPubkeyHex, "inventory add 12", "")
+	if err != nil {
+		t.Fatalf("wrapping DM: %v", err)
+	}
+	if err := runReplay(ctx, scratch, cfg, eventJSON(t, event), &bytes.Buffer{}); err != nil {
+		t.Fatalf("runReplay: %v", err)
+	}
+	if n, _ := scratch.GetInventory(ctx); n != 12 {
+		t.Errorf("scratch inventory = %d, want 12", n)
+	}
+	cleanup()
+
+	if n, _ := database.GetInventory(ctx); n != 0 {
+		t.Errorf("inventory = %d after a dry run, want 0", n)
+	}
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/spf13/cobra"
+)
+
+// revenuePeriods are the accepted --period values.
+var revenuePeriods = []db.RevenuePeriod{db.PeriodDaily, db.PeriodWeekly, db.PeriodMonthly}
+
+var revenueCmd = &cobra.Command{
+	Use:   "revenue",
+	Short: "Summarize fulfilled orders by day, week or month",
+	Long: `Print the number of fulfilled orders and the sats they brought in for each
+day, week or month, most recent first. Orders count toward the period in which
+they were placed, in UTC; weeks start on Monday and are labelled by that date.
+Periods without fulfilled orders are left out.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		period, _ := cmd.Flags().GetString("period")
+		limit, _ := cmd.Flags().GetInt("limit")
+		if !slices.Contains(revenuePeriods, db.RevenuePeriod(period)) {
+			return fmt.Errorf("--period must be one of %v, got %q", revenuePeriods, period)
+		}
+		if limit < 1 {
+			return errors.New("--limit must be at least 1")
+		}
+
+		database, _, err := openConfiguredDB()
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+		if err := database.Migrate(); err != nil {
+			return fmt.Errorf("running migrations: %w", err)
+		}
+		return runRevenue(cmd.Context(), database, db.RevenuePeriod(period), limit, cmd.OutOrStdout())
+	},
+}
+
+func init() {
+	revenueCmd.Flags().String("period", string(db.PeriodWeekly), "bucket size: daily, weekly or monthly")
+	revenueCmd.Flags().Int("limit", 12, "most recent periods to print")
+	rootCmd.AddCommand(revenueCmd)
+}
+
+// runRevenue prints fulfilled order totals per period as a table.
+func runRevenue(ctx context.Context, database *db.DB, period db.RevenuePeriod, limit int, out io.Writer) error {
+	revenue, err := database.GetRevenueByPeriod(ctx, period, limit)
+	if err != nil {
+		return err
+	}
+	if len(revenue) == 0 {
+		_, _ = fmt.Fprintln(out, "No fulfilled orders yet.")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "PERIOD\tORDERS\tSATS")
+	for _, r := range revenue {
+		_, _ = fmt.Fprintf(tw, "%s\t%d\t%d\n", r.Period, r.Orders, r.TotalSats)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+func TestRunRevenue(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+
+	var out bytes.Buffer
+	if err := runRevenue(ctx, database, db.PeriodWeekly, 12, &out); err != nil {
+		t.Fatalf("runRevenue: %v", err)
+	}
+	if out.String() != "No fulfilled orders yet.\n" {
+		t.Errorf("empty output = %q", out.String())
+	}
+
+	customer, _ := database.CreateCustomer(ctx, newTestSender(t).npub, "")
+	_ = database.AddEggs(ctx, 12)
+	for _, sats := range []int64{3200, 6400} {
+		order, err := database.CreateOrder(ctx, customer.ID, 6, sats)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		_ = database.UpdateOrderStatus(ctx, order.ID, "paid")
+		if err := database.FulfillOrder(ctx, order.ID); err != nil {
+			t.Fatalf("FulfillOrder: %v", err)
+		}
+	}
+
+	out.Reset()
+	if err := runRevenue(ctx, database, db.PeriodMonthly, 12, &out); err != nil {
+		t.Fatalf("runRevenue: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || strings.Fields(lines[0])[0] != "PERIOD" {
+		t.Fatalf("expected a header and one row:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[1]); len(fields) != 3 || fields[1] != "2" || fields[2] != "9600" {
+		t.Errorf("row = %q, want 2 orders for 9600 sats", lines[1])
+	}
+}
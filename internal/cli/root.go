@@ -26,8 +26,15 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.eggbot.yaml)")
 	rootCmd.PersistentFlags().Bool("verbose", false, "verbose output")
+	rootCmd.PersistentFlags().String("log-format", "", `log format, "text" or "json" (overrides log.format)`)
 
+	bindFlags()
+}
+
+// bindFlags binds the persistent flags to their config keys.
+func bindFlags() {
 	_ = viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
+	_ = viper.BindPFlag("log.format", rootCmd.PersistentFlags().Lookup("log-format"))
 }
 
 func initConfig() {
@@ -49,8 +56,9 @@ func initConfig() {
 	viper.AutomaticEnv()
 
 	if err := viper.ReadInConfig(); err != nil {
-		// If user explicitly specified a config file, fail loudly
-		if cfgFile != "" {
+		// If user explicitly specified a config file, fail loudly - except for
+		// init, which is what creates it
+		if cfgFile != "" && initCmd.CalledAs() == "" {
 			fmt.Fprintf(os.Stderr, "Error reading config file %s: %v\n", cfgFile, err)
 			os.Exit(1)
 		}
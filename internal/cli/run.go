@@ -2,12 +2,15 @@ package cli
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"maps"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -15,15 +18,14 @@ import (
 	"github.com/buildtall-systems/eggbot/internal/config"
 	"github.com/buildtall-systems/eggbot/internal/db"
 	"github.com/buildtall-systems/eggbot/internal/dm"
-	"github.com/buildtall-systems/eggbot/internal/fsm"
+	"github.com/buildtall-systems/eggbot/internal/health"
 	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+	"github.com/buildtall-systems/eggbot/internal/metrics"
 	"github.com/buildtall-systems/eggbot/internal/nostr"
-	"github.com/buildtall-systems/eggbot/internal/zaps"
 	gonostr "github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/keyer"
-	"github.com/nbd-wtf/go-nostr/nip04"
 	"github.com/nbd-wtf/go-nostr/nip19"
-	"github.com/nbd-wtf/go-nostr/nip59"
 	"github.com/spf13/cobra"
 )
 
@@ -35,6 +37,7 @@ var runCmd = &cobra.Command{
 }
 
 func init() {
+	runCmd.Flags().String("metrics-addr", "", "serve Prometheus metrics at /metrics on this address (e.g. :9090)")
 	rootCmd.AddCommand(runCmd)
 }
 
@@ -45,10 +48,19 @@ func runBot(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("loading config: %w", err)
 	}
 
-	log.Printf("eggbot starting...")
-	log.Printf("bot npub: %s", cfg.Nostr.BotNpub)
-	log.Printf("relays: %v", cfg.Nostr.Relays)
-	log.Printf("database: %s", cfg.Database.Path)
+	slog.SetDefault(newLogger(os.Stderr, cfg))
+	for _, warning := range cfg.Warnings {
+		slog.Warn(warning)
+	}
+
+	slog.Info("eggbot starting",
+		"bot_npub", cfg.Nostr.BotNpub,
+		"relays", cfg.Nostr.PrimaryRelays,
+		"fallback_relays", cfg.Nostr.FallbackRelays,
+		"database", cfg.Database.Path)
+	if cfg.Nostr.LegacyEncryption == config.LegacyEncryptionNIP44 && !cfg.Features.EnableNIP44 {
+		slog.Warn("nostr.legacy_encryption is nip44 but features.enable_nip44 is off; kind:4 replies use NIP-04")
+	}
 
 	// Create keyer for cryptographic operations (signing, encrypt/decrypt)
 	kr, err := keyer.NewPlainKeySigner(cfg.Nostr.BotSecretHex)
@@ -56,6 +68,11 @@ func runBot(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("creating keyer: %w", err)
 	}
 
+	// SQLite creates the database file but not the directory it goes in
+	if err := os.MkdirAll(filepath.Dir(cfg.Database.Path), 0o755); err != nil {
+		return fmt.Errorf("creating database directory: %w", err)
+	}
+
 	// Open database and run migrations
 	database, err := db.Open(cfg.Database.Path)
 	if err != nil {
@@ -63,10 +80,19 @@ func runBot(cmd *cobra.Command, args []string) error {
 	}
 	defer func() { _ = database.Close() }()
 
-	if err := database.Migrate(); err != nil {
+	applied, err := database.MigrateUp(context.Background())
+	if err != nil {
 		return fmt.Errorf("running migrations: %w", err)
 	}
-	log.Printf("database ready")
+	for _, m := range applied {
+		slog.Info("applied migration", "version", m.Version, "name", m.Name)
+	}
+
+	// Config admins are seeded so existing setups keep working; more can be added via DM
+	if err := database.SeedAdmins(context.Background(), cfg.Admins); err != nil {
+		return fmt.Errorf("seeding admins: %w", err)
+	}
+	slog.Info("database ready")
 
 	// Create context that cancels on shutdown signals
 	ctx, cancel := context.WithCancel(context.Background())
@@ -77,338 +103,316 @@ func runBot(cmd *cobra.Command, args []string) error {
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	go func() {
 		sig := <-sigCh
-		log.Printf("received signal %v, shutting down...", sig)
+		slog.Info("received signal, shutting down", "signal", sig)
 		cancel()
 	}()
 
-	// Get high water mark from database to filter old events
-	highWaterMark, err := database.GetHighWaterMark()
+	// Sender roles are cached between DMs; role-changing commands invalidate entries
+	roles := commands.NewRoleCache(cfg.Permissions.RoleCacheTTL)
+
+	// Reload relays, admins and pricing on SIGHUP. Each event uses the config
+	// snapshot current when it arrived. Signals are only acted on once the
+	// relays are connected, so a new relay list always reaches them.
+	watcher := config.NewConfigWatcher(cfg)
+	watcher.OnReload(func(oldCfg, newCfg *config.Config) {
+		if err := database.SeedAdmins(ctx, newCfg.Admins); err != nil {
+			slog.Error("failed to apply reloaded admins", "error", err)
+		}
+		roles.Reset()
+	})
+	hupCh := make(chan os.Signal, 1)
+	signal.Notify(hupCh, syscall.SIGHUP)
+
+	// Release eggs held by unpaid orders and mark lapsed invoices
+	go runCleanup(ctx, database, watcher)
+
+	// Get high water marks from database to filter old events
+	marks, err := database.GetHighWaterMarks()
 	if err != nil {
-		return fmt.Errorf("getting high water mark: %w", err)
+		return fmt.Errorf("getting high water marks: %w", err)
 	}
-	if highWaterMark > 0 {
-		hwmTime := time.Unix(highWaterMark, 0)
-		log.Printf("high water mark: %s", hwmTime.Format("2006/01/02 15:04:05"))
+	if cfg.Features.EnableMentions {
+		if err := seedMentionMark(database, marks, time.Now()); err != nil {
+			return err
+		}
+	}
+	for kind, ts := range marks {
+		slog.Info("high water mark", "kind", kind, "time", time.Unix(ts, 0).Format("2006/01/02 15:04:05"))
 	}
 
 	// Create and connect relay manager
-	relayMgr := nostr.NewRelayManager(cfg.Nostr.Relays, cfg.Nostr.BotPubkeyHex)
-	if err := relayMgr.Connect(ctx, highWaterMark); err != nil {
+	// Copies of an event from other relays are dropped in memory before the
+	// database dedup check
+	var dedup *nostr.EventDeduplicator
+	if cfg.Nostr.DedupTTL > 0 {
+		dedup = nostr.NewEventDeduplicator(cfg.Nostr.DedupTTL, cfg.Nostr.DedupMaxEntries)
+		dedup.StartCleanupLoop(ctx, cleanupInterval)
+	}
+	relayMgr := nostr.NewRelayManager(cfg.Nostr.PrimaryRelays, cfg.Nostr.FallbackRelays, cfg.Nostr.BotPubkeyHex, dedup)
+	if cfg.Features.EnableMentions {
+		relayMgr.SubscribeMentions()
+	}
+	if err := relayMgr.Connect(ctx, marks, cfg.Nostr.SinceOverlap); err != nil {
 		return fmt.Errorf("connecting to relays: %w", err)
 	}
 	defer relayMgr.Close()
+	watcher.OnReload(func(oldCfg, newCfg *config.Config) {
+		if slices.Equal(oldCfg.Nostr.PrimaryRelays, newCfg.Nostr.PrimaryRelays) &&
+			slices.Equal(oldCfg.Nostr.FallbackRelays, newCfg.Nostr.FallbackRelays) {
+			return
+		}
+		added, removed := relayMgr.SetRelays(newCfg.Nostr.PrimaryRelays, newCfg.Nostr.FallbackRelays)
+		slog.Info("relays updated", "added", added, "removed", removed)
+	})
+	go watcher.Watch(ctx, hupCh)
+	if cfg.Profile.PublishOnStart {
+		go publishProfileOnStart(ctx, relayMgr, kr, cfg)
+	}
 
-	log.Printf("eggbot running, waiting for events...")
+	// Cache customers' NIP-05 identifiers for admin listings, so commands
+	// never wait on relays or domains
+	go runProfileRefresh(ctx, database, nostr.NewProfileFetcher(relayMgr, nostr.NewNIP05Verifier()), watcher)
+
+	// Unix time of the last new event taken off the relay channels, for /healthz
+	var lastProcessed atomic.Int64
+
+	// Serve /healthz once relays are up; it shuts down before the relays close
+	if cfg.Health.Listen != "" {
+		healthSrv, err := health.Start(cfg.Health.Listen, health.NewHandler(health.Probes{
+			ConnectedRelays: relayMgr.ConnectedRelays,
+			LastRelayEvent:  relayMgr.LastEventAt,
+			DuplicateEvents: relayMgr.DuplicateEvents,
+			LastProcessed: func() time.Time {
+				if ts := lastProcessed.Load(); ts != 0 {
+					return time.Unix(ts, 0)
+				}
+				return time.Time{}
+			},
+			Database: database.HealthCheck,
+		}, cfg.Health.MaxSilence))
+		if err != nil {
+			return fmt.Errorf("starting health endpoint: %w", err)
+		}
+		defer func() { _ = healthSrv.Close() }()
+	}
+
+	// Serve /metrics when --metrics-addr is set; events are counted either way
+	botMetrics := metrics.New(metrics.Probes{
+		OrdersByStatus:     database.CountOrdersByStatus,
+		InventoryAvailable: database.GetInventory,
+	})
+	if addr, _ := cmd.Flags().GetString("metrics-addr"); addr != "" {
+		metricsSrv, err := metrics.Start(addr, botMetrics)
+		if err != nil {
+			return fmt.Errorf("starting metrics endpoint: %w", err)
+		}
+		defer func() { _ = metricsSrv.Close() }()
+	}
+
+	slog.Info("eggbot running, waiting for events")
 
-	// Initialize event processor FSM
-	processorFSM := fsm.NewEventProcessorFSM()
+	// Each event is handled with the config snapshot current when it arrived
+	handler := NewEventHandler(database, kr, watcher.Config, relayMgr, roles)
+	handler.relays = relayMgr.RelayStatuses
 
 	// Main event loop
 	for {
 		select {
 		case <-ctx.Done():
-			log.Printf("shutting down...")
+			slog.Info("shutting down", "duplicate_relay_events", relayMgr.DuplicateEvents())
 			return nil
 
-		case event := <-relayMgr.DMEvents():
+		// Zaps arrive ahead of waiting DMs and mentions
+		case event := <-relayMgr.Events():
 			if event == nil {
 				continue
 			}
-			log.Printf("received DM event: %s (kind:%d)", event.ID, event.Kind)
-			eventTs := int64(event.CreatedAt)
-
-			// Transition FSM to processing DM state
-			if err := processorFSM.Event(ctx, fsm.ProcessorEventDMReceived); err != nil {
-				log.Printf("FSM error on DM received: %v", err)
-				processorFSM.Reset()
-				continue
-			}
-
-			isNew, err := database.TryProcess(event.ID, event.Kind, eventTs)
-			if err != nil {
-				log.Printf("dedup check failed: %v", err)
-				processorFSM.Reset()
-				continue
-			}
-			if !isNew {
-				log.Printf("duplicate event %s, skipping", event.ID)
-				processorFSM.Reset()
-				continue
-			}
-
-			// Decrypt DM based on kind
-			var senderPubkey, messageContent string
-			var incomingProtocol dm.DMProtocol
-
 			switch event.Kind {
-			case gonostr.KindEncryptedDirectMessage: // NIP-04 legacy DM
-				incomingProtocol = dm.ProtocolNIP04
-				// Compute shared secret and decrypt
-				sharedSecret, err := nip04.ComputeSharedSecret(event.PubKey, cfg.Nostr.BotSecretHex)
-				if err != nil {
-					log.Printf("failed to compute shared secret: %v", err)
-					_ = database.SetHighWaterMark(eventTs)
+			case gonostr.KindZap:
+				slog.Info("received zap event", "event_id", event.ID, "kind", event.Kind)
+				if !claimEvent(ctx, database, event, &lastProcessed) {
 					continue
 				}
-				messageContent, err = nip04.Decrypt(event.Content, sharedSecret)
-				if err != nil {
-					log.Printf("failed to decrypt NIP-04 DM: %v", err)
-					_ = database.SetHighWaterMark(eventTs)
-					continue
-				}
-				senderPubkey = event.PubKey
-
-			case gonostr.KindGiftWrap: // NIP-17 gift-wrapped DM
-				incomingProtocol = dm.ProtocolNIP17
-				rumor, err := nip59.GiftUnwrap(*event, func(pubkey, ciphertext string) (string, error) {
-					return kr.Decrypt(ctx, ciphertext, pubkey)
-				})
-				if err != nil {
-					log.Printf("failed to unwrap DM: %v", err)
-					_ = database.SetHighWaterMark(eventTs)
+				handler.HandleZap(ctx, event)
+				botMetrics.EventProcessed(metrics.EventTypeZap)
+
+			case gonostr.KindTextNote:
+				slog.Debug("received mention event", "event_id", event.ID, "kind", event.Kind)
+				if !claimEvent(ctx, database, event, &lastProcessed) {
 					continue
 				}
-				senderPubkey = rumor.PubKey
-				messageContent = rumor.Content
+				handler.HandleMention(ctx, event)
+				botMetrics.EventProcessed(metrics.EventTypeMention)
 
 			default:
-				log.Printf("unexpected DM kind: %d", event.Kind)
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			// Convert sender hex pubkey to npub for display
-			senderNpub, _ := nip19.EncodePublicKey(senderPubkey)
-			log.Printf("DM from %s: %s", senderNpub, messageContent)
-
-			// Check for admin broadcast command (special syntax, handled before normal parsing)
-			if broadcastMsg, isBroadcast := parseBroadcast(messageContent); isBroadcast {
-				if !commands.IsAdmin(senderNpub, cfg.Admins) {
-					sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-						senderPubkey, "Permission denied: broadcast requires admin privileges", incomingProtocol)
-					_ = database.SetHighWaterMark(eventTs)
-					continue
-				}
-				if broadcastMsg == "" {
-					sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-						senderPubkey, "Usage: message customers: <your message>", incomingProtocol)
-					_ = database.SetHighWaterMark(eventTs)
+				slog.Info("received DM event", "event_id", event.ID, "kind", event.Kind)
+				if !claimEvent(ctx, database, event, &lastProcessed) {
 					continue
 				}
-
-				log.Printf("admin %s broadcasting: %s", senderNpub, broadcastMsg)
-				sent, failed := broadcastToCustomers(ctx, kr, relayMgr, cfg, database, broadcastMsg)
-
-				summary := fmt.Sprintf("Broadcast sent to %d customers", sent)
-				if failed > 0 {
-					summary += fmt.Sprintf(" (%d failed)", failed)
-				}
-				sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-					senderPubkey, summary, incomingProtocol)
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			// Parse command from message
-			parsedCmd := commands.Parse(messageContent)
-			if parsedCmd == nil {
-				log.Printf("empty message, ignoring")
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			if !parsedCmd.IsValid() {
-				log.Printf("unknown command: %s", parsedCmd.Name)
-				sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex, senderPubkey,
-					fmt.Sprintf("Unknown command: %s. Send 'help' for available commands.", parsedCmd.Name), incomingProtocol)
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			// Check permissions
-			if err := commands.CanExecute(ctx, database.DB, parsedCmd, senderNpub, cfg.Admins); err != nil {
-				log.Printf("permission denied for %s: %v", senderNpub, err)
-				sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex, senderPubkey,
-					fmt.Sprintf("Permission denied: %v", err), incomingProtocol)
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			log.Printf("executing command: %s %v", parsedCmd.Name, parsedCmd.Args)
-
-			// Transition FSM to command processed state
-			if err := processorFSM.Event(ctx, fsm.ProcessorEventCommandProcessed); err != nil {
-				log.Printf("FSM error on command processed: %v", err)
-				processorFSM.Reset()
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			// Execute the command
-			lnClient := lightning.NewClient()
-			execCfg := commands.ExecuteConfig{
-				SatsPerHalfDozen: cfg.Pricing.SatsPerHalfDozen,
-				Admins:           cfg.Admins,
-				LightningAddress: cfg.Lightning.LightningAddress,
-				BotNpub:          cfg.Nostr.BotNpub,
-				LightningClient:  lnClient,
-			}
-			result := commands.Execute(ctx, database, parsedCmd, senderNpub, execCfg)
-
-			// Check for errors and transition FSM if needed
-			if result.Error != nil {
-				if err := processorFSM.Event(ctx, fsm.ProcessorEventError); err != nil {
-					log.Printf("FSM error on command error: %v", err)
-				}
-				log.Printf("command error: %v", result.Error)
-				responseMsg := fmt.Sprintf("Error: %v", result.Error)
-				sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex, senderPubkey, responseMsg, incomingProtocol)
-				processorFSM.Reset()
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
-
-			// Transition FSM to sending response state
-			if err := processorFSM.Event(ctx, fsm.ProcessorEventResponseSent); err != nil {
-				log.Printf("FSM error on response sent: %v", err)
-				processorFSM.Reset()
-				_ = database.SetHighWaterMark(eventTs)
-				continue
+				handler.HandleDM(ctx, event)
+				botMetrics.EventProcessed(metrics.EventTypeDM)
 			}
+		}
+	}
+}
 
-			log.Printf("command result: %s", result.Message)
-			sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex, senderPubkey, result.Message, incomingProtocol)
+// seedMentionMark starts the mentions high water mark at now when there is
+// none yet, so enabling mentions doesn't answer every old note that tags the
+// bot. marks is updated to match.
+func seedMentionMark(database *db.DB, marks map[int]int64, now time.Time) error {
+	if marks[gonostr.KindTextNote] > 0 {
+		return nil
+	}
+	if err := database.SetHighWaterMark(gonostr.KindTextNote, now.Unix()); err != nil {
+		return fmt.Errorf("seeding mentions high water mark: %w", err)
+	}
+	marks[gonostr.KindTextNote] = now.Unix()
+	return nil
+}
 
-			// Notify admins of new orders (just the summary, not payment details)
-			if parsedCmd.Name == commands.CmdOrder && result.Error == nil {
-				orderSummary := strings.SplitN(result.Message, "\n", 2)[0]
-				adminMsg := fmt.Sprintf("📥 New order from %s:\n%s", senderNpub, orderSummary)
-				notifyAdmins(ctx, kr, relayMgr, cfg, adminMsg)
-			}
+// claimEvent records an event as processed and advances its kind's high
+// water mark, returning false if it was already handled or the dedup check
+// failed.
+func claimEvent(ctx context.Context, database *db.DB, event *gonostr.Event, lastProcessed *atomic.Int64) bool {
+	isNew, err := database.TryProcessAndAdvanceHWM(ctx, event.ID, event.Kind, int64(event.CreatedAt))
+	if err != nil {
+		slog.Error("dedup check failed", "event_id", event.ID, "error", err)
+		return false
+	}
+	if !isNew {
+		slog.Debug("duplicate event, skipping", "event_id", event.ID)
+		return false
+	}
+	lastProcessed.Store(time.Now().Unix())
+	return true
+}
 
-			// Check for inventory notifications after commands that may increase inventory
-			if parsedCmd.Name == commands.CmdInventory || parsedCmd.Name == commands.CmdCancel {
-				checkInventoryNotifications(ctx, kr, relayMgr, cfg, database)
-			}
+// cleanupInterval is how often stale pending orders and invoices are expired.
+const cleanupInterval = time.Minute
 
-			// Reset FSM to idle after DM processing completes
-			processorFSM.Reset()
-			_ = database.SetHighWaterMark(eventTs)
+// runCleanup periodically runs cleanup with the current config until ctx is done.
+func runCleanup(ctx context.Context, database *db.DB, watcher *config.ConfigWatcher) {
+	ticker := time.NewTicker(cleanupInterval)
+	defer ticker.Stop()
 
-		case event := <-relayMgr.ZapEvents():
-			if event == nil {
-				continue
-			}
-			log.Printf("received zap event: %s (kind:%d)", event.ID, event.Kind)
-			eventTs := int64(event.CreatedAt)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cleanup(ctx, database, watcher.Config())
+		}
+	}
+}
 
-			// Transition FSM to processing zap state
-			if err := processorFSM.Event(ctx, fsm.ProcessorEventZapReceived); err != nil {
-				log.Printf("FSM error on zap received: %v", err)
-				processorFSM.Reset()
-				continue
-			}
+// cleanup runs one cleanup pass. Unpaid orders are only expired when
+// features.enable_order_expiry is on.
+func cleanup(ctx context.Context, database *db.DB, cfg *config.Config) {
+	if cfg.Features.EnableOrderExpiry {
+		maxAge := time.Duration(cfg.Orders.ExpiryMinutes) * time.Minute
+		expired, err := database.ExpireOldPendingOrders(ctx, maxAge)
+		if err != nil {
+			slog.Error("failed to expire pending orders", "error", err)
+		}
+		for _, o := range expired {
+			slog.Info("expired unpaid order", "order_id", o.ID, "quantity", o.Quantity)
+		}
+	}
 
-			isNew, err := database.TryProcess(event.ID, event.Kind, eventTs)
-			if err != nil {
-				log.Printf("dedup check failed: %v", err)
-				processorFSM.Reset()
-				continue
-			}
-			if !isNew {
-				log.Printf("duplicate event %s, skipping", event.ID)
-				processorFSM.Reset()
-				continue
-			}
+	if n, err := database.ExpireInvoices(ctx, time.Now()); err != nil {
+		slog.Error("failed to expire invoices", "error", err)
+	} else if n > 0 {
+		slog.Info("expired invoices", "count", n)
+	}
+}
 
-			// Validate the zap receipt
-			validatedZap, err := zaps.ValidateZapReceipt(event, cfg.Lightning.LnurlPubkeyHex)
-			if err != nil {
-				if errors.Is(err, zaps.ErrUnauthorizedZapProvider) {
-					log.Printf("zap from unauthorized provider: %v", err)
-				} else {
-					log.Printf("invalid zap receipt: %v", err)
-				}
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
+// Customer profiles are looked up again once they are profileMaxAge old,
+// profileRefreshBatch at a time every profileRefreshInterval.
+const (
+	profileRefreshInterval = 10 * time.Minute
+	profileRefreshBatch    = 50
+	profileMaxAge          = 24 * time.Hour
+)
 
-			log.Printf("valid zap: %d sats from %s", validatedZap.AmountSats, validatedZap.SenderNpub)
-
-			// Process the zap
-			processResult, err := zaps.ProcessZap(ctx, database, validatedZap)
-			if err != nil {
-				if errors.Is(err, zaps.ErrDuplicateZap) {
-					log.Printf("duplicate zap event %s, ignoring", validatedZap.ZapEventID)
-				} else {
-					log.Printf("failed to process zap: %v", err)
-					if err := processorFSM.Event(ctx, fsm.ProcessorEventError); err != nil {
-						log.Printf("FSM error on zap process error: %v", err)
-					}
-				}
-				processorFSM.Reset()
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
+// nip05Lookup finds pubkeys' verified NIP-05 identifiers, as
+// nostr.ProfileFetcher does.
+type nip05Lookup interface {
+	NIP05s(ctx context.Context, pubkeys []string) map[string]string
+}
 
-			// Transition FSM to sending response state
-			if err := processorFSM.Event(ctx, fsm.ProcessorEventResponseSent); err != nil {
-				log.Printf("FSM error on response sent (zap): %v", err)
-				processorFSM.Reset()
-				_ = database.SetHighWaterMark(eventTs)
-				continue
-			}
+// runProfileRefresh refreshes stale customer profiles while
+// features.enable_nip05 is on, until ctx is done.
+func runProfileRefresh(ctx context.Context, database *db.DB, lookup nip05Lookup, watcher *config.ConfigWatcher) {
+	if watcher.Config().Features.EnableNIP05 {
+		refreshProfiles(ctx, database, lookup)
+	}
 
-			log.Printf("zap processed: %s", processResult.Message)
+	ticker := time.NewTicker(profileRefreshInterval)
+	defer ticker.Stop()
 
-			// Send DM confirmation to zapper
-			_, senderPubkeyHex, err := nip19.Decode(validatedZap.SenderNpub)
-			if err != nil {
-				log.Printf("failed to decode sender npub: %v", err)
-			} else {
-				sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-					senderPubkeyHex.(string), processResult.Message, dm.ProtocolNIP04)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if watcher.Config().Features.EnableNIP05 {
+				refreshProfiles(ctx, database, lookup)
 			}
-
-			// Notify admins of payment received
-			adminMsg := fmt.Sprintf("💰 Payment received from %s:\n%s", validatedZap.SenderNpub, processResult.Message)
-			notifyAdmins(ctx, kr, relayMgr, cfg, adminMsg)
-
-			// Reset FSM to idle after zap processing completes
-			processorFSM.Reset()
-			_ = database.SetHighWaterMark(eventTs)
 		}
 	}
 }
 
-// sendResponse wraps a message in the appropriate protocol (NIP-04 or NIP-17) and publishes it to relays.
-func sendResponse(ctx context.Context, kr gonostr.Keyer, relayMgr *nostr.RelayManager, botSecretHex, botPubkeyHex, recipientPubkeyHex, message string, protocol dm.DMProtocol) {
-	var wrapped *gonostr.Event
-	var err error
-
-	switch protocol {
-	case dm.ProtocolNIP04:
-		wrapped, err = dm.WrapLegacyResponse(ctx, kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, message)
-	case dm.ProtocolNIP17:
-		wrapped, err = dm.WrapResponse(ctx, kr, botPubkeyHex, recipientPubkeyHex, message)
-	default:
-		// Default to NIP-17 for safety
-		wrapped, err = dm.WrapResponse(ctx, kr, botPubkeyHex, recipientPubkeyHex, message)
-	}
-
+// refreshProfiles looks up one batch of stale customer profiles and caches
+// their NIP-05 identifiers. Profiles that couldn't be checked keep what was
+// cached and are tried again once stale.
+func refreshProfiles(ctx context.Context, database *db.DB, lookup nip05Lookup) {
+	npubs, err := database.StaleProfiles(ctx, profileMaxAge, profileRefreshBatch)
 	if err != nil {
-		log.Printf("failed to wrap response: %v", err)
+		slog.Error("failed to list stale profiles", "error", err)
 		return
 	}
-
-	if err := relayMgr.Publish(ctx, wrapped); err != nil {
-		log.Printf("failed to publish response: %v", err)
+	if len(npubs) == 0 {
 		return
 	}
 
-	// Convert hex to npub for display
-	recipientNpub, _ := nip19.EncodePublicKey(recipientPubkeyHex)
-	log.Printf("sent response to %s", recipientNpub)
+	npubsByPubkey := make(map[string]string, len(npubs))
+	for _, npub := range npubs {
+		prefix, value, err := nip19.Decode(npub)
+		if err != nil || prefix != "npub" {
+			_ = database.TouchProfile(ctx, npub)
+			continue
+		}
+		npubsByPubkey[value.(string)] = npub
+	}
+
+	found := lookup.NIP05s(ctx, slices.Collect(maps.Keys(npubsByPubkey)))
+	for pubkey, npub := range npubsByPubkey {
+		if nip05, ok := found[pubkey]; ok {
+			err = database.SaveProfile(ctx, npub, nip05)
+		} else {
+			err = database.TouchProfile(ctx, npub)
+		}
+		if err != nil {
+			slog.Error("failed to cache profile", "npub", npub, "error", err)
+		}
+	}
+	slog.Debug("refreshed profiles", "checked", len(npubs), "found", len(found))
+}
+
+// replyProtocol returns the protocol to answer a DM received over protocol.
+// Plain NIP-04 replies may be upgraded to NIP-44 payloads by config; with
+// NIP-44 disabled, kind:4 replies always use NIP-04.
+func replyProtocol(cfg *config.Config, protocol dm.DMProtocol) dm.DMProtocol {
+	if !cfg.Features.EnableNIP44 {
+		if protocol == dm.ProtocolNIP44 {
+			return dm.ProtocolNIP04
+		}
+		return protocol
+	}
+	if protocol == dm.ProtocolNIP04 && cfg.Nostr.LegacyEncryption == config.LegacyEncryptionNIP44 {
+		return dm.ProtocolNIP44
+	}
+	return protocol
 }
 
 // broadcastPrefix is the command prefix for admin broadcast messages.
@@ -426,6 +430,16 @@ func parseBroadcast(content string) (message string, isBroadcast bool) {
 	return message, true
 }
 
+// broadcastMessage returns an admin broadcast followed by the configured
+// broadcast footer, if any.
+func broadcastMessage(cfg *config.Config, message string) string {
+	footer := cfg.Messages.Catalog.Render(messages.BroadcastFooter, messages.BroadcastData{BotNpub: cfg.Nostr.BotNpub})
+	if footer == "" {
+		return message
+	}
+	return message + "\n\n" + footer
+}
+
 // stripMarkdownComments removes markdown reference-style link definitions
 // that some Nostr clients prepend to messages, e.g. "[//]: # (nip18)"
 func stripMarkdownComments(content string) string {
@@ -441,51 +455,118 @@ func stripMarkdownComments(content string) string {
 	return strings.Join(result, "\n")
 }
 
+// dmSender delivers an unsolicited DM to a hex pubkey.
+type dmSender func(recipientPubkeyHex, message string)
+
+// newExecuteConfig returns the command settings for the current config.
+// relays reports the relay states for the config command; pass nil when
+// there are no relays.
+func newExecuteConfig(cfg *config.Config, roles *commands.RoleCache, announcer commands.Announcer, relays func() []nostr.RelayStatus) commands.ExecuteConfig {
+	return commands.ExecuteConfig{
+		SatsPerHalfDozen:      cfg.Pricing.SatsPerHalfDozen,
+		AllowedQuantities:     cfg.Pricing.AllowedQuantities,
+		LightningAddress:      cfg.Lightning.LightningAddress,
+		FallbackAddresses:     cfg.Lightning.FallbackAddresses,
+		PickupInstructions:    cfg.Pickup.Instructions,
+		BotNpub:               cfg.Nostr.BotNpub,
+		LightningClient:       lightning.NewClient(),
+		Roles:                 roles,
+		Location:              cfg.Display.Location,
+		OrderExpiry:           orderExpiry(cfg),
+		Announcer:             announcer,
+		Messages:              cfg.Messages.Catalog,
+		Waitlist:              cfg.Features.EnableWaitlist,
+		LowInventoryThreshold: cfg.Orders.LowInventoryThreshold,
+
+		Build:        commands.BuildInfo{Version: version, Commit: commit},
+		DatabasePath: cfg.Database.Path,
+		Relays:       relays,
+	}
+}
+
+// orderExpiry returns how long unpaid orders are held, or 0 when
+// features.enable_order_expiry is off.
+func orderExpiry(cfg *config.Config) time.Duration {
+	if !cfg.Features.EnableOrderExpiry {
+		return 0
+	}
+	return time.Duration(cfg.Orders.ExpiryMinutes) * time.Minute
+}
+
 // broadcastToCustomers sends a DM to all registered customers.
-func broadcastToCustomers(ctx context.Context, kr gonostr.Keyer, relayMgr *nostr.RelayManager,
-	cfg *config.Config, database *db.DB, message string) (sent int, failed int) {
+func broadcastToCustomers(ctx context.Context, database *db.DB, send dmSender, message string) (sent int, failed int) {
 
 	customers, err := database.ListCustomers(ctx)
 	if err != nil {
-		log.Printf("failed to list customers for broadcast: %v", err)
+		slog.Error("failed to list customers for broadcast", "error", err)
 		return 0, 0
 	}
 
 	for _, customer := range customers {
+		// Blocked customers are frozen and get no broadcasts
+		if blocked, err := database.IsBlocked(ctx, customer.Npub); err == nil && blocked {
+			continue
+		}
 		_, pubkeyHex, err := nip19.Decode(customer.Npub)
 		if err != nil {
-			log.Printf("failed to decode customer npub %s: %v", customer.Npub, err)
+			slog.Error("failed to decode customer npub", "npub", customer.Npub, "error", err)
 			failed++
 			continue
 		}
-		sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-			pubkeyHex.(string), message, dm.ProtocolNIP04)
+		send(pubkeyHex.(string), message)
 		sent++
 	}
 	return sent, failed
 }
 
-// notifyAdmins sends a DM to all configured admins.
-func notifyAdmins(ctx context.Context, kr gonostr.Keyer, relayMgr *nostr.RelayManager, cfg *config.Config, message string) {
-	for _, adminNpub := range cfg.Admins {
-		_, adminPubkeyHex, err := nip19.Decode(adminNpub)
+// notifyAlways is the pref for admin notifications no preference turns off,
+// such as messages customers send with the contact command.
+const notifyAlways = ""
+
+// notifyAdmins sends a DM to the admins who want notifications of kind pref
+// (one of db.AdminPrefNames, or notifyAlways for every admin). Admins who
+// never set their preferences get everything.
+func notifyAdmins(ctx context.Context, database *db.DB, send dmSender, pref, message string) {
+	admins, err := database.ListAdmins(ctx)
+	if err != nil {
+		slog.Error("failed to list admins for notification", "error", err)
+		return
+	}
+	prefs, err := database.ListAdminPrefs(ctx)
+	if err != nil {
+		// Better a notification too many than a missed order
+		slog.Error("failed to list admin preferences for notification", "error", err)
+	}
+
+	for _, admin := range admins {
+		if p, ok := prefs[admin.Npub]; ok && !p.Wants(pref) {
+			continue
+		}
+		_, adminPubkeyHex, err := nip19.Decode(admin.Npub)
 		if err != nil {
-			log.Printf("failed to decode admin npub %s: %v", adminNpub, err)
+			slog.Error("failed to decode admin npub", "npub", admin.Npub, "error", err)
 			continue
 		}
-		sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-			adminPubkeyHex.(string), message, dm.ProtocolNIP04)
+		send(adminPubkeyHex.(string), message)
 	}
 }
 
+// notifyLowInventory logs a warning and tells the admins how many eggs are
+// left. Called after commands whose result sets LowInventoryWarning.
+func notifyLowInventory(ctx context.Context, database *db.DB, send dmSender, remaining int) {
+	slog.Warn("low inventory", "remaining", remaining)
+	notifyAdmins(ctx, database, send, db.PrefInventory, fmt.Sprintf("⚠️ Low inventory: %d eggs left", remaining))
+}
+
 // checkInventoryNotifications checks for triggered notifications and sends DMs.
-// Called after commands that may increase inventory (inventory add/set, cancel).
-func checkInventoryNotifications(ctx context.Context, kr gonostr.Keyer, relayMgr *nostr.RelayManager,
-	cfg *config.Config, database *db.DB) {
+// Called after commands whose result sets TriggerNotifications.
+// msgs renders the alert, in each customer's chosen language; nil uses the
+// built-in text.
+func checkInventoryNotifications(ctx context.Context, database *db.DB, send dmSender, msgs *messages.Catalog) {
 
 	available, err := database.GetInventory(ctx)
 	if err != nil {
-		log.Printf("failed to get inventory for notifications: %v", err)
+		slog.Error("failed to get inventory for notifications", "error", err)
 		return
 	}
 
@@ -495,25 +576,24 @@ func checkInventoryNotifications(ctx context.Context, kr gonostr.Keyer, relayMgr
 
 	notifications, err := database.GetTriggeredNotifications(ctx, available)
 	if err != nil {
-		log.Printf("failed to get triggered notifications: %v", err)
+		slog.Error("failed to get triggered notifications", "error", err)
 		return
 	}
 
 	for _, n := range notifications {
 		_, pubkeyHex, err := nip19.Decode(n.CustomerNpub)
 		if err != nil {
-			log.Printf("failed to decode customer npub %s: %v", n.CustomerNpub, err)
+			slog.Error("failed to decode customer npub", "npub", n.CustomerNpub, "error", err)
 			continue
 		}
 
-		msg := fmt.Sprintf("🥚 Inventory alert: %d eggs are now available!", available)
-		sendResponse(ctx, kr, relayMgr, cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex,
-			pubkeyHex.(string), msg, dm.ProtocolNIP04)
+		alert := commands.MessagesFor(ctx, database, n.CustomerNpub, msgs).Render(messages.InventoryAlert, messages.InventoryData{Available: available})
+		send(pubkeyHex.(string), alert)
 
 		if err := database.DeleteInventoryNotificationByID(ctx, n.ID); err != nil {
-			log.Printf("failed to delete notification %d: %v", n.ID, err)
+			slog.Error("failed to delete notification", "notification_id", n.ID, "error", err)
 		} else {
-			log.Printf("sent inventory notification to %s (threshold: %d)", n.CustomerNpub, n.ThresholdEggs)
+			slog.Info("sent inventory notification", "recipient", n.CustomerNpub, "threshold", n.ThresholdEggs)
 		}
 	}
 }
@@ -2,11 +2,23 @@ package cli
 
 import (
 	"bytes"
-	"log"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+	gonostr "github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/viper"
 )
 
 // Test hex pubkey for testing
@@ -29,12 +41,11 @@ func TestNpubConversion(t *testing.T) {
 func TestLogOutputShowsNpubNotHex(t *testing.T) {
 	// Capture log output
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(nil) // Reset after test
+	logger := newLogger(&buf, &config.Config{})
 
 	// GOOD: Convert hex to npub before logging
 	senderNpub, _ := nip19.EncodePublicKey(testPubkeyHex)
-	log.Printf("DM from %s: test message", senderNpub)
+	logger.Info("DM received", "sender", senderNpub)
 
 	output := buf.String()
 
@@ -53,27 +64,25 @@ func TestLogOutputShowsNpubNotHex(t *testing.T) {
 func TestLogOutputShowsFullNpub(t *testing.T) {
 	// Capture log output
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(nil)
+	logger := newLogger(&buf, &config.Config{})
 
 	npub, _ := nip19.EncodePublicKey(testPubkeyHex)
-	log.Printf("valid zap: 1000 sats from %s", npub)
+	logger.Info("valid zap", "sender", npub, "amount_sats", 1000)
 
 	output := buf.String()
 
 	// Verify full npub is in output (not truncated)
-	if !strings.Contains(output, testExpectedNpub) {
+	if !strings.Contains(output, "sender="+testExpectedNpub) {
 		t.Errorf("log output should contain full npub %s, got: %s", testExpectedNpub, output)
 	}
 }
 
 func TestLogOutputForPermissionDenied(t *testing.T) {
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(nil)
+	logger := newLogger(&buf, &config.Config{})
 
 	senderNpub, _ := nip19.EncodePublicKey(testPubkeyHex)
-	log.Printf("permission denied for %s: you are not a registered customer", senderNpub)
+	logger.Info("permission denied", "sender", senderNpub, "error", "you are not a registered customer")
 
 	output := buf.String()
 
@@ -87,11 +96,10 @@ func TestLogOutputForPermissionDenied(t *testing.T) {
 
 func TestLogOutputForSentResponse(t *testing.T) {
 	var buf bytes.Buffer
-	log.SetOutput(&buf)
-	defer log.SetOutput(nil)
+	logger := newLogger(&buf, &config.Config{})
 
 	recipientNpub, _ := nip19.EncodePublicKey(testPubkeyHex)
-	log.Printf("sent response to %s", recipientNpub)
+	logger.Info("sent response", "recipient", recipientNpub)
 
 	output := buf.String()
 
@@ -103,6 +111,125 @@ func TestLogOutputForSentResponse(t *testing.T) {
 	}
 }
 
+func TestNewLogger_DMContentOnlyAtDebug(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		wantContent bool
+	}{
+		{name: "default info level", cfg: config.Config{}, wantContent: false},
+		{name: "verbose", cfg: config.Config{Verbose: true}, wantContent: true},
+		{name: "debug level", cfg: config.Config{Log: config.LogConfig{Level: slog.LevelDebug}}, wantContent: true},
+		{name: "warn level", cfg: config.Config{Log: config.LogConfig{Level: slog.LevelWarn}}, wantContent: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := newLogger(&buf, &tt.cfg)
+
+			logger.Debug("DM content", "content", "my address is 12 Main St")
+
+			if got := strings.Contains(buf.String(), "12 Main St"); got != tt.wantContent {
+				t.Errorf("content logged = %v, want %v; output: %s", got, tt.wantContent, buf.String())
+			}
+		})
+	}
+}
+
+func TestLogContent_OnlyWhenVerbose(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.Config
+		wantContent bool
+	}{
+		{name: "default", cfg: config.Config{}, wantContent: false},
+		{name: "debug level without verbose", cfg: config.Config{Log: config.LogConfig{Level: slog.LevelDebug}}, wantContent: false},
+		{name: "verbose", cfg: config.Config{Verbose: true}, wantContent: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			prev := slog.Default()
+			slog.SetDefault(newLogger(&buf, &tt.cfg))
+			t.Cleanup(func() { slog.SetDefault(prev) })
+
+			logContent(&tt.cfg, "DM content", "content", "my phone is 555-0100")
+
+			if got := strings.Contains(buf.String(), "555-0100"); got != tt.wantContent {
+				t.Errorf("content logged = %v, want %v; output: %s", got, tt.wantContent, buf.String())
+			}
+		})
+	}
+}
+
+func TestNewLogger_JSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, &config.Config{Log: config.LogConfig{Format: config.LogFormatJSON}})
+
+	logger.Info("executing command", "event_id", "abc123", "command", "order")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output is not JSON: %v; output: %s", err, buf.String())
+	}
+	if entry["msg"] != "executing command" {
+		t.Errorf("msg = %v, want %q", entry["msg"], "executing command")
+	}
+	if entry["event_id"] != "abc123" {
+		t.Errorf("event_id = %v, want %q", entry["event_id"], "abc123")
+	}
+	if entry["command"] != "order" {
+		t.Errorf("command = %v, want %q", entry["command"], "order")
+	}
+	if entry["level"] != "INFO" {
+		t.Errorf("level = %v, want INFO", entry["level"])
+	}
+	if _, ok := entry["time"]; !ok {
+		t.Errorf("entry has no time: %s", buf.String())
+	}
+}
+
+func TestLogFormatFlag(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	bindFlags()
+
+	flag := rootCmd.PersistentFlags().Lookup("log-format")
+	if err := flag.Value.Set(config.LogFormatJSON); err != nil {
+		t.Fatalf("setting --log-format: %v", err)
+	}
+	flag.Changed = true
+	t.Cleanup(func() {
+		_ = flag.Value.Set("")
+		flag.Changed = false
+	})
+	// The flag wins over the config file
+	viper.SetConfigType("yaml")
+	if err := viper.ReadConfig(strings.NewReader("log:\n  format: text\n")); err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatalf("config.Load: %v", err)
+	}
+	if cfg.Log.Format != config.LogFormatJSON {
+		t.Fatalf("Log.Format = %q, want json from the flag", cfg.Log.Format)
+	}
+
+	var buf bytes.Buffer
+	newLogger(&buf, cfg).Info("received DM event", "event_id", "abc123")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output is not JSON: %v; output: %s", err, buf.String())
+	}
+	if entry["msg"] != "received DM event" || entry["event_id"] != "abc123" {
+		t.Errorf("entry = %v", entry)
+	}
+}
+
 func TestParseBroadcast(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -190,3 +317,173 @@ func TestParseBroadcast(t *testing.T) {
 		})
 	}
 }
+
+func TestBroadcastMessage_Footer(t *testing.T) {
+	cfg := &config.Config{}
+	if got := broadcastMessage(cfg, "Fresh eggs Friday"); got != "Fresh eggs Friday" {
+		t.Errorf("without a footer got %q", got)
+	}
+
+	path := filepath.Join(t.TempDir(), "messages.yaml")
+	if err := os.WriteFile(path, []byte(`broadcast_footer: "-- Hof Sonnenschein"`), 0o600); err != nil {
+		t.Fatalf("writing messages: %v", err)
+	}
+	catalog, err := messages.Load(path)
+	if err != nil {
+		t.Fatalf("loading messages: %v", err)
+	}
+	cfg.Messages.Catalog = catalog
+	if got := broadcastMessage(cfg, "Fresh eggs Friday"); got != "Fresh eggs Friday\n\n-- Hof Sonnenschein" {
+		t.Errorf("with a footer got %q", got)
+	}
+}
+
+func TestCleanup_OrderExpiryFeatureFlag(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	c, _ := database.CreateCustomer(ctx, testExpectedNpub, "")
+	_ = database.AddEggs(ctx, 12)
+	order, err := database.CreateOrder(ctx, c.ID, 6, 3200)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	// Every pending order is past a zero-minute expiry
+	cfg := &config.Config{Orders: config.OrdersConfig{ExpiryMinutes: 0}}
+	cleanup(ctx, database, cfg)
+	if got, _ := database.GetOrderByID(ctx, order.ID); got.Status != "pending" {
+		t.Fatalf("order status = %s, want pending while order expiry is off", got.Status)
+	}
+
+	cfg.Features.EnableOrderExpiry = true
+	cleanup(ctx, database, cfg)
+	if got, _ := database.GetOrderByID(ctx, order.ID); got.Status != "cancelled" {
+		t.Errorf("order status = %s, want cancelled once order expiry is on", got.Status)
+	}
+}
+
+func TestSeedMentionMark(t *testing.T) {
+	database := newTestDB(t, ":memory:")
+	marks, err := database.GetHighWaterMarks()
+	if err != nil {
+		t.Fatalf("GetHighWaterMarks: %v", err)
+	}
+
+	// A fresh database subscribes to mentions from now, not from the start
+	start := time.Unix(1_700_000_000, 0)
+	if err := seedMentionMark(database, marks, start); err != nil {
+		t.Fatalf("seedMentionMark: %v", err)
+	}
+	stored, _ := database.GetHighWaterMarks()
+	if marks[gonostr.KindTextNote] != start.Unix() || stored[gonostr.KindTextNote] != start.Unix() {
+		t.Errorf("mentions mark = %d (stored %d), want %d", marks[gonostr.KindTextNote], stored[gonostr.KindTextNote], start.Unix())
+	}
+
+	// An existing mark is kept
+	if err := seedMentionMark(database, marks, start.Add(time.Hour)); err != nil {
+		t.Fatalf("seedMentionMark: %v", err)
+	}
+	if stored, _ := database.GetHighWaterMarks(); stored[gonostr.KindTextNote] != start.Unix() {
+		t.Errorf("mentions mark moved to %d", stored[gonostr.KindTextNote])
+	}
+}
+
+func TestNotifyAdmins_Prefs(t *testing.T) {
+	ctx := context.Background()
+	alice, bob := newTestSender(t), newTestSender(t)
+	database := newTestDB(t, ":memory:", alice.npub, bob.npub)
+	if err := database.SetAdminPref(ctx, bob.npub, db.PrefOrders, false); err != nil {
+		t.Fatalf("SetAdminPref: %v", err)
+	}
+
+	notified := func(pref string) []string {
+		var recipients []string
+		notifyAdmins(ctx, database, func(pubkeyHex, _ string) {
+			recipients = append(recipients, pubkeyHex)
+		}, pref, "notice")
+		slices.Sort(recipients)
+		return recipients
+	}
+	everyone := []string{alice.pubkeyHex, bob.pubkeyHex}
+	slices.Sort(everyone)
+
+	if got := notified(db.PrefOrders); !slices.Equal(got, []string{alice.pubkeyHex}) {
+		t.Errorf("order notice went to %v, want only alice", got)
+	}
+	if got := notified(db.PrefPayments); !slices.Equal(got, everyone) {
+		t.Errorf("payment notice went to %v, want both admins", got)
+	}
+
+	// Contact messages ignore preferences
+	_ = database.SetAdminPref(ctx, alice.npub, db.PrefOrders, false)
+	if got := notified(notifyAlways); !slices.Equal(got, everyone) {
+		t.Errorf("notifyAlways went to %v, want both admins", got)
+	}
+}
+
+func TestRefreshProfiles(t *testing.T) {
+	ctx := context.Background()
+	database := newTestDB(t, ":memory:")
+	alice, bob, carol := newTestSender(t), newTestSender(t), newTestSender(t)
+	for _, s := range []testSender{alice, bob, carol} {
+		_, _ = database.CreateCustomer(ctx, s.npub, "")
+	}
+	// Carol's identifier was verified before; her domain is down now
+	_ = database.SaveProfile(ctx, carol.npub, "carol@example.com")
+	_, _ = database.ExecContext(ctx, `UPDATE profiles SET checked_at = datetime('now', '-2 days')`)
+
+	lookup := &fakeNIP05Lookup{identifiers: map[string]string{alice.pubkeyHex: "alice@example.com", bob.pubkeyHex: ""}}
+	refreshProfiles(ctx, database, lookup)
+
+	if len(lookup.asked) != 3 {
+		t.Errorf("asked about %d pubkeys, want 3", len(lookup.asked))
+	}
+	nip05s, _ := database.NIP05s(ctx, []string{alice.npub, bob.npub, carol.npub})
+	if len(nip05s) != 2 || nip05s[alice.npub] != "alice@example.com" || nip05s[carol.npub] != "carol@example.com" {
+		t.Errorf("cached identifiers = %v", nip05s)
+	}
+
+	// Everyone was just checked
+	lookup.asked = nil
+	refreshProfiles(ctx, database, lookup)
+	if len(lookup.asked) != 0 {
+		t.Errorf("asked about %v again, want fresh profiles skipped", lookup.asked)
+	}
+}
+
+func TestReplyProtocol_NIP44FeatureFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		enabled    bool
+		legacy     string
+		incoming   dm.DMProtocol
+		wantOutput dm.DMProtocol
+	}{
+		{"off keeps NIP-04", false, config.LegacyEncryptionNIP44, dm.ProtocolNIP04, dm.ProtocolNIP04},
+		{"off downgrades NIP-44", false, config.LegacyEncryptionNIP04, dm.ProtocolNIP44, dm.ProtocolNIP04},
+		{"off keeps NIP-17", false, config.LegacyEncryptionNIP04, dm.ProtocolNIP17, dm.ProtocolNIP17},
+		{"on upgrades by config", true, config.LegacyEncryptionNIP44, dm.ProtocolNIP04, dm.ProtocolNIP44},
+		{"on mirrors NIP-44", true, config.LegacyEncryptionNIP04, dm.ProtocolNIP44, dm.ProtocolNIP44},
+		{"on keeps NIP-04", true, config.LegacyEncryptionNIP04, dm.ProtocolNIP04, dm.ProtocolNIP04},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{
+				Nostr:    config.NostrConfig{LegacyEncryption: tt.legacy},
+				Features: config.Features{EnableNIP44: tt.enabled},
+			}
+			if got := replyProtocol(cfg, tt.incoming); got != tt.wantOutput {
+				t.Errorf("replyProtocol() = %d, want %d", got, tt.wantOutput)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/commands"
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/buildtall-systems/eggbot/internal/fsm"
+	"github.com/buildtall-systems/eggbot/internal/zaps"
+	gonostr "github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/cobra"
+)
+
+var simulateCmd = &cobra.Command{
+	Use:   "simulate",
+	Short: "Run commands from stdin without relays",
+	Long: `Read messages from stdin and run them through the same parsing, permission
+checks and commands as "run", printing the DMs the bot would send instead of
+publishing them. Each line is one of:
+
+  <npub>: <message>     a DM from npub
+  zap <npub> <sats>     a valid zap receipt from npub
+  # comment             ignored, as are blank lines
+
+The configured database is used unless --memory is given. No EGGBOT_NSEC is
+needed; orders still request invoices from the configured lightning address.`,
+	Args:         cobra.NoArgs,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("loading config: %w", err)
+		}
+		path := cfg.Database.Path
+		if memory, _ := cmd.Flags().GetBool("memory"); memory {
+			path = ":memory:"
+		}
+
+		database, err := db.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = database.Close() }()
+
+		sim, err := newSimulator(cmd.Context(), database, cfg, cmd.OutOrStdout())
+		if err != nil {
+			return err
+		}
+		return sim.run(cmd.InOrStdin())
+	},
+}
+
+func init() {
+	simulateCmd.Flags().Bool("memory", false, "use a fresh in-memory database instead of the configured one")
+	rootCmd.AddCommand(simulateCmd)
+}
+
+// simulator feeds scripted DMs and zaps through the bot's event handler and
+// prints the DMs it would send.
+type simulator struct {
+	ctx      context.Context
+	database *db.DB
+	handler  *EventHandler
+	out      io.Writer
+	events   int // Events simulated so far, for unique event IDs
+}
+
+// newSimulator migrates the database and seeds the config admins, as run does.
+func newSimulator(ctx context.Context, database *db.DB, cfg *config.Config, out io.Writer) (*simulator, error) {
+	if err := database.Migrate(); err != nil {
+		return nil, fmt.Errorf("running migrations: %w", err)
+	}
+	if err := database.SeedAdmins(ctx, cfg.Admins); err != nil {
+		return nil, fmt.Errorf("seeding admins: %w", err)
+	}
+
+	// Announcements are signed before they are printed; without the bot's
+	// key a throwaway one does
+	kr, err := keyer.NewPlainKeySigner(gonostr.GeneratePrivateKey())
+	if err != nil {
+		return nil, fmt.Errorf("creating keyer: %w", err)
+	}
+
+	s := &simulator{ctx: ctx, database: database, out: out}
+	s.handler = NewEventHandler(database, kr, func() *config.Config { return cfg }, nil,
+		commands.NewRoleCache(cfg.Permissions.RoleCacheTTL))
+	// Print replies instead of publishing them, and the steps that send none
+	s.handler.send = func(recipientPubkeyHex, message, _ string, _ dm.DMProtocol) {
+		s.send(recipientPubkeyHex, message)
+	}
+	s.handler.trace = func(step, detail string) {
+		switch step {
+		case "blocked", "announce":
+			_, _ = fmt.Fprintf(s.out, "(%s: %s)\n", step, detail)
+		}
+	}
+	return s, nil
+}
+
+// run handles each input line until EOF. Bad lines are reported and skipped.
+func (s *simulator) run(in io.Reader) error {
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := s.handleLine(line); err != nil {
+			_, _ = fmt.Fprintf(s.out, "! %v\n", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// handleLine runs one DM or zap line.
+func (s *simulator) handleLine(line string) error {
+	if rest, ok := strings.CutPrefix(line, "zap "); ok {
+		fields := strings.Fields(rest)
+		if len(fields) != 2 {
+			return errors.New("usage: zap <npub> <sats>")
+		}
+		sats, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || sats <= 0 {
+			return fmt.Errorf("invalid zap amount %q", fields[1])
+		}
+		if err := checkNpub(fields[0]); err != nil {
+			return err
+		}
+		return s.zap(fields[0], sats)
+	}
+
+	sender, message, ok := strings.Cut(line, ":")
+	if !ok {
+		return errors.New("expected \"<npub>: <message>\" or \"zap <npub> <sats>\"")
+	}
+	sender = strings.TrimSpace(sender)
+	if err := checkNpub(sender); err != nil {
+		return err
+	}
+	s.dm(sender, strings.TrimSpace(message))
+	return nil
+}
+
+// dm handles a message from senderNpub as run does once the DM is decrypted.
+func (s *simulator) dm(senderNpub, message string) {
+	_, pubkeyHex, _ := nip19.Decode(senderNpub)
+	event := &gonostr.Event{ID: s.eventID(), PubKey: pubkeyHex.(string), Kind: gonostr.KindEncryptedDirectMessage, CreatedAt: gonostr.Now()}
+
+	h := s.handler
+	if err := h.processor.Event(s.ctx, fsm.ProcessorEventDMReceived); err != nil {
+		_, _ = fmt.Fprintf(s.out, "! %v\n", err)
+		h.processor.Reset()
+		return
+	}
+	defer h.processor.Reset()
+	h.handleDecryptedDM(s.ctx, h.config(), event, event.PubKey, message, event.ID, dm.ProtocolNIP04)
+}
+
+// zap credits a synthetic zap from senderNpub as run does once the receipt
+// is validated.
+func (s *simulator) zap(senderNpub string, sats int64) error {
+	zap := &zaps.ValidatedZap{
+		SenderNpub: senderNpub,
+		AmountSats: sats,
+		ZapEventID: s.eventID(),
+		Simulated:  true,
+	}
+	event := &gonostr.Event{ID: zap.ZapEventID, Kind: gonostr.KindZap, CreatedAt: gonostr.Now()}
+
+	h := s.handler
+	if err := h.processor.Event(s.ctx, fsm.ProcessorEventZapReceived); err != nil {
+		h.processor.Reset()
+		return err
+	}
+	defer h.processor.Reset()
+	h.creditZap(s.ctx, h.config(), event, zap)
+	return nil
+}
+
+// eventID returns a new simulated event ID, unique across runs since a
+// persistent database remembers zap IDs.
+func (s *simulator) eventID() string {
+	s.events++
+	return fmt.Sprintf("simulated-%d-%d", time.Now().UnixNano(), s.events)
+}
+
+// send prints an unsolicited DM to a hex pubkey.
+func (s *simulator) send(recipientPubkeyHex, message string) {
+	npub, err := nip19.EncodePublicKey(recipientPubkeyHex)
+	if err != nil {
+		npub = recipientPubkeyHex
+	}
+	s.print(npub, message)
+}
+
+// print writes a DM the bot would have sent, indenting continuation lines.
+func (s *simulator) print(recipientNpub, message string) {
+	_, _ = fmt.Fprintf(s.out, "-> %s\n", recipientNpub)
+	for _, line := range strings.Split(message, "\n") {
+		_, _ = fmt.Fprintf(s.out, "   %s\n", line)
+	}
+}
@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// testStrangerNpub is an npub that is neither admin nor customer.
+const testStrangerNpub = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqshp52w2"
+
+func newTestSimulator(t *testing.T) (*simulator, *bytes.Buffer) {
+	t.Helper()
+	database, err := db.Open(":memory:")
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	cfg := &config.Config{
+		Pricing: config.PricingConfig{
+			SatsPerHalfDozen:  3200,
+			AllowedQuantities: config.DefaultAllowedQuantities,
+		},
+		Admins: []string{testExpectedNpub},
+	}
+	var out bytes.Buffer
+	sim, err := newSimulator(context.Background(), database, cfg, &out)
+	if err != nil {
+		t.Fatalf("newSimulator: %v", err)
+	}
+	return sim, &out
+}
+
+func TestSimulator_OrderAndZap(t *testing.T) {
+	sim, out := newTestSimulator(t)
+
+	script := strings.Join([]string{
+		"# an admin stocks up and registers a customer",
+		testExpectedNpub + ": inventory add 12",
+		testExpectedNpub + ": addcustomer " + testBotNpub,
+		"",
+		testBotNpub + ": order 6",
+		"zap " + testBotNpub + " 3200",
+		testStrangerNpub + ": inventory",
+		testExpectedNpub + ": block " + testStrangerNpub,
+		testStrangerNpub + ": help",
+		"not a valid line",
+	}, "\n")
+	if err := sim.run(strings.NewReader(script)); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	output := out.String()
+	for _, want := range []string{
+		"-> " + testBotNpub,
+		"📥 New order from " + testBotNpub,
+		"💰 Payment received from " + testBotNpub,
+		"Permission denied",
+		"(blocked: " + testStrangerNpub + " is blocked; no reply)",
+		"! expected \"<npub>: <message>\"",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing %q:\n%s", want, output)
+		}
+	}
+
+	ctx := context.Background()
+	customer, err := sim.database.GetCustomerByNpub(ctx, testBotNpub)
+	if err != nil {
+		t.Fatalf("customer not registered: %v", err)
+	}
+	orders, _ := sim.database.GetCustomerOrdersByOffset(ctx, customer.ID, 10, 0)
+	if len(orders) != 1 || orders[0].Status != "paid" {
+		t.Errorf("orders = %+v, want one paid order", orders)
+	}
+}
+
+func TestSimulator_RejectsBadLines(t *testing.T) {
+	sim, out := newTestSimulator(t)
+
+	for _, line := range []string{"npub1bogus: help", "zap " + testBotNpub, "zap " + testBotNpub + " lots"} {
+		if err := sim.handleLine(line); err == nil {
+			t.Errorf("handleLine(%q) should fail", line)
+		}
+	}
+	if out.Len() != 0 {
+		t.Errorf("rejected lines should not produce DMs, got:\n%s", out.String())
+	}
+}
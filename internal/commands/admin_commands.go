@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/buildtall-systems/eggbot/internal/db"
 	"github.com/nbd-wtf/go-nostr/nip19"
@@ -49,11 +52,7 @@ func DeliverCmd(ctx context.Context, database *db.DB, args []string) Result {
 		return Result{Error: fmt.Errorf("fulfilling order: %w", err)}
 	}
 
-	// Truncate npub for display: npub1abc...xyz
-	npubShort := customer.Npub
-	if len(npubShort) > 20 {
-		npubShort = npubShort[:12] + "..." + npubShort[len(npubShort)-4:]
-	}
+	npubShort := shortNpub(customer.Npub)
 
 	return Result{Message: fmt.Sprintf("Delivered order %d: %d eggs to %s", orderID, order.Quantity, npubShort)}
 }
@@ -126,7 +125,7 @@ func AdjustCmd(ctx context.Context, database *db.DB, args []string) Result {
 
 	// Record adjustment transaction
 	eventID := fmt.Sprintf("adjust-%d", amount)
-	_, err = database.RecordTransaction(ctx, nil, eventID, amount, npub)
+	_, err = database.RecordTransaction(ctx, nil, eventID, db.TransactionSourceAdjustment, amount, npub)
 	if err != nil {
 		return Result{Error: fmt.Errorf("recording adjustment: %w", err)}
 	}
@@ -137,57 +136,237 @@ func AdjustCmd(ctx context.Context, database *db.DB, args []string) Result {
 	return Result{Message: fmt.Sprintf("Deducted %d sats from %s", -amount, npub)}
 }
 
-// OrdersCmd lists all orders across all customers for admin visibility.
-func OrdersCmd(ctx context.Context, database *db.DB) Result {
-	orders, err := database.GetAllOrders(ctx, 50)
+// ordersPageSize is how many orders one page of "orders" shows.
+const ordersPageSize = 50
+
+// orderStatuses are the statuses "orders" can filter by.
+var orderStatuses = []string{"pending", "paid", "fulfilled", "cancelled"}
+
+// OrdersCmd lists orders across all customers for admin visibility, most
+// recent first.
+// Args: optional status to filter by, then optional page number, starting at 1
+func OrdersCmd(ctx context.Context, database *db.DB, args []string, td TimeDisplay) Result {
+	usage := Result{Error: errors.New("usage: orders [pending|paid|fulfilled|cancelled] [page]")}
+	var filter db.OrderFilter
+	page := 1
+	if len(args) > 0 && slices.Contains(orderStatuses, strings.ToLower(args[0])) {
+		filter.Status = strings.ToLower(args[0])
+		args = args[1:]
+	}
+	if len(args) > 1 {
+		return usage
+	}
+	if len(args) == 1 {
+		n, ok := parsePage(args[0])
+		if !ok {
+			return usage
+		}
+		page = n
+	}
+
+	// Status prefixes the totals, e.g. "3 pending orders"
+	label := "orders"
+	if filter.Status != "" {
+		label = filter.Status + " orders"
+	}
+
+	total, err := database.CountOrdersWithFilters(ctx, filter)
+	if err != nil {
+		return Result{Error: fmt.Errorf("counting orders: %w", err)}
+	}
+	if total == 0 {
+		return Result{Message: fmt.Sprintf("No %s found.", label)}
+	}
+	pages := pageCount(total, ordersPageSize)
+	if page > pages {
+		return pageOutOfRange(page, pages, total, "orders")
+	}
+
+	orders, err := database.GetOrdersPage(ctx, filter, ordersPageSize, (page-1)*ordersPageSize)
 	if err != nil {
 		return Result{Error: fmt.Errorf("listing orders: %w", err)}
 	}
 
-	if len(orders) == 0 {
-		return Result{Message: "No orders found."}
+	// Identifiers only help tell customers apart; without them npubs are shown
+	npubs := make([]string, len(orders))
+	for i, o := range orders {
+		npubs[i] = o.CustomerNpub
+	}
+	nip05s, err := database.NIP05s(ctx, npubs)
+	if err != nil {
+		slog.Warn("looking up NIP-05 identifiers failed", "error", err)
 	}
 
-	msg := fmt.Sprintf("%d orders (most recent first):\n", len(orders))
+	msg := fmt.Sprintf("%d %s (most recent first):\n", total, label)
+	if pages > 1 {
+		msg = pageHeader(page, pages, total) + ", most recent first:\n"
+	}
 	for _, o := range orders {
-		// Truncate npub for display: npub1abc...xyz
-		npubShort := o.CustomerNpub
-		if len(npubShort) > 20 {
-			npubShort = npubShort[:12] + "..." + npubShort[len(npubShort)-4:]
+		msg += ordersLine(o, nip05s[o.CustomerNpub], td) + "\n"
+	}
+	if page < pages {
+		next := fmt.Sprint(page + 1)
+		if filter.Status != "" {
+			next = filter.Status + " " + next
 		}
-		msg += fmt.Sprintf("• #%d: %s | %d eggs | %d sats | %s\n",
-			o.ID, npubShort, o.Quantity, o.TotalSats, o.Status)
+		msg += fmt.Sprintf("Send \"orders %s\" for older orders.", next)
 	}
 	return Result{Message: msg}
 }
 
-// CustomersCmd lists all registered customers.
-func CustomersCmd(ctx context.Context, database *db.DB) Result {
-	customers, err := database.ListCustomers(ctx)
+// ordersLine renders one order for OrdersCmd. The customer is shown by
+// their NIP-05 identifier when they have one, else by truncated npub.
+func ordersLine(o db.OrderWithCustomer, nip05 string, td TimeDisplay) string {
+	who := nip05
+	if who == "" {
+		who = shortNpub(o.CustomerNpub)
+	}
+	return fmt.Sprintf("• #%d: %s | %d eggs | %d sats | %s | %s",
+		o.ID, who, o.Quantity, o.TotalSats, o.Status, td.orderWhen(o.CreatedAt, o.Status))
+}
+
+// Limits for the transactions command.
+const (
+	defaultTransactionsLimit = 20
+	maxTransactionsLimit     = 100
+)
+
+// TransactionsCmd lists the most recent payment ledger entries (admin only).
+// Args: optional npub to show one sender's entries and/or a number of entries
+func TransactionsCmd(ctx context.Context, database *db.DB, args []string, loc *time.Location) Result {
+	usage := Result{Error: fmt.Errorf("usage: transactions [npub] [n], where n is at most %d", maxTransactionsLimit)}
+	if len(args) > 2 {
+		return usage
+	}
+	npub := ""
+	limit := defaultTransactionsLimit
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "npub1") {
+			if prefix, _, err := nip19.Decode(arg); err != nil || prefix != "npub" {
+				return Result{Error: errors.New("invalid npub")}
+			}
+			npub = arg
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxTransactionsLimit {
+			return usage
+		}
+		limit = n
+	}
+
+	entries, err := database.GetTransactions(ctx, npub, limit, 0)
 	if err != nil {
-		return Result{Error: fmt.Errorf("listing customers: %w", err)}
+		return Result{Error: fmt.Errorf("listing transactions: %w", err)}
+	}
+	if len(entries) == 0 {
+		if npub != "" {
+			return Result{Message: fmt.Sprintf("No transactions from %s.", npub)}
+		}
+		return Result{Message: "No transactions yet."}
+	}
+
+	msg := fmt.Sprintf("%d most recent transactions:\n", len(entries))
+	if npub != "" {
+		msg = fmt.Sprintf("%d most recent transactions from %s:\n", len(entries), npub)
+	}
+	for _, e := range entries {
+		msg += transactionLine(e, loc) + "\n"
+	}
+	return Result{Message: msg}
+}
+
+// transactionLine renders one ledger entry for TransactionsCmd, e.g.
+// "• #12 zap: +3200 sats | npub1abc...wxyz | order #5 | Jul 5 14:30".
+func transactionLine(e db.LedgerEntry, loc *time.Location) string {
+	sender := shortNpub(e.SenderNpub)
+	order := "no order"
+	if e.OrderID.Valid {
+		order = fmt.Sprintf("order #%d", e.OrderID.Int64)
+		if e.OrderStatus.Valid {
+			order += fmt.Sprintf(" (%d eggs, %s)", e.OrderQuantity.Int64, e.OrderStatus.String)
+		}
+	}
+	return fmt.Sprintf("• #%d %s: %+d sats | %s | %s | %s",
+		e.ID, e.Source, e.AmountSats, sender, order, FormatTime(e.CreatedAt, loc))
+}
+
+// customersPageSize is how many customers one page of "customers" shows.
+const customersPageSize = 25
+
+// CustomersCmd lists registered customers a page at a time, in registration
+// order.
+// Args: optional page number, starting at 1
+func CustomersCmd(ctx context.Context, database *db.DB, args []string) Result {
+	page := 1
+	if len(args) > 1 {
+		return Result{Error: errors.New("usage: customers [page]")}
+	}
+	if len(args) == 1 {
+		n, ok := parsePage(args[0])
+		if !ok {
+			return Result{Error: errors.New("usage: customers [page]")}
+		}
+		page = n
 	}
 
-	if len(customers) == 0 {
+	total, err := database.CountCustomers(ctx)
+	if err != nil {
+		return Result{Error: err}
+	}
+	if total == 0 {
 		return Result{Message: "No registered customers."}
 	}
+	pages := pageCount(total, customersPageSize)
+	if page > pages {
+		return pageOutOfRange(page, pages, total, "customers")
+	}
+
+	customers, err := database.GetCustomersByOffset(ctx, customersPageSize, (page-1)*customersPageSize)
+	if err != nil {
+		return Result{Error: fmt.Errorf("listing customers: %w", err)}
+	}
+
+	msg := fmt.Sprintf("%d registered customers:\n", total)
+	switch {
+	case page < pages:
+		msg = fmt.Sprintf("%d registered customers (page %d of %d, use \"customers %d\" for next page):\n", total, page, pages, page+1)
+	case pages > 1:
+		msg = fmt.Sprintf("%d registered customers (page %d of %d):\n", total, page, pages)
+	}
+	npubs := make([]string, len(customers))
+	for i, c := range customers {
+		npubs[i] = c.Npub
+	}
+	nip05s, err := database.NIP05s(ctx, npubs)
+	if err != nil {
+		slog.Warn("looking up NIP-05 identifiers failed", "error", err)
+	}
 
-	msg := fmt.Sprintf("%d registered customers:\n", len(customers))
 	for _, c := range customers {
-		name := ""
+		// Full npubs, so they can be pasted into other commands
+		var labels []string
 		if c.Name.Valid && c.Name.String != "" {
-			name = fmt.Sprintf(" (%s)", c.Name.String)
+			labels = append(labels, c.Name.String)
+		}
+		if nip05 := nip05s[c.Npub]; nip05 != "" {
+			labels = append(labels, nip05)
+		}
+		name := ""
+		if len(labels) > 0 {
+			name = fmt.Sprintf(" (%s)", strings.Join(labels, ", "))
 		}
 		msg += fmt.Sprintf("• %s%s\n", c.Npub, name)
 	}
 	return Result{Message: msg}
 }
 
-// AddCustomerCmd registers a new customer.
-// Args: [npub]
+// AddCustomerCmd registers a new customer, or sets the name of one already
+// registered.
+// Args: [npub] [name...] - the rest of the arguments are joined as the name
 func AddCustomerCmd(ctx context.Context, database *db.DB, args []string) Result {
 	if len(args) < 1 {
-		return Result{Error: errors.New("usage: addcustomer <npub>")}
+		return Result{Error: errors.New("usage: addcustomer <npub> [name]")}
 	}
 
 	npub := args[0]
@@ -201,14 +380,24 @@ func AddCustomerCmd(ctx context.Context, database *db.DB, args []string) Result
 		return Result{Error: errors.New("invalid npub")}
 	}
 
-	_, err = database.CreateCustomer(ctx, npub)
+	name := strings.TrimSpace(strings.Join(args[1:], " "))
+	_, err = database.CreateCustomer(ctx, npub, name)
 	if errors.Is(err, db.ErrCustomerExists) {
-		return Result{Message: "Customer already registered."}
+		if name == "" {
+			return Result{Message: "Customer already registered."}
+		}
+		if err := database.UpdateCustomerName(ctx, npub, name); err != nil {
+			return Result{Error: fmt.Errorf("updating customer name: %w", err)}
+		}
+		return Result{Message: fmt.Sprintf("Customer %s already registered, name updated to %s", npub, name)}
 	}
 	if err != nil {
 		return Result{Error: fmt.Errorf("adding customer: %w", err)}
 	}
 
+	if name != "" {
+		return Result{Message: fmt.Sprintf("Registered customer %s (%s)", npub, name)}
+	}
 	return Result{Message: fmt.Sprintf("Registered customer %s", npub)}
 }
 
@@ -241,8 +430,9 @@ func RemoveCustomerCmd(ctx context.Context, database *db.DB, args []string) Resu
 	return Result{Message: fmt.Sprintf("Removed customer %s", npub)}
 }
 
-// SalesCmd returns total sales from fulfilled orders.
-func SalesCmd(ctx context.Context, database *db.DB) Result {
+// SalesCmd returns sales from fulfilled orders placed today, this week
+// (from Monday), this month and in total, with days in the display zone.
+func SalesCmd(ctx context.Context, database *db.DB, td TimeDisplay) Result {
 	total, err := database.GetTotalSales(ctx)
 	if err != nil {
 		return Result{Error: fmt.Errorf("getting total sales: %w", err)}
@@ -252,14 +442,123 @@ func SalesCmd(ctx context.Context, database *db.DB) Result {
 		return Result{Message: "No sales yet."}
 	}
 
-	return Result{Message: fmt.Sprintf("Total sales: %d sats", total)}
+	loc := td.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	now := td.now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	week := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	periods := []struct {
+		label    string
+		from, to time.Time
+	}{
+		{"Today", today, today.AddDate(0, 0, 1)},
+		{"This week", week, week.AddDate(0, 0, 7)},
+		{"This month", month, month.AddDate(0, 1, 0)},
+	}
+
+	// Amounts are right-aligned to the widest, which is the total
+	width := len(strconv.FormatInt(total, 10))
+	msg := "Sales from fulfilled orders:\n"
+	for _, p := range periods {
+		sats, err := database.GetSalesByDateRange(ctx, p.from, p.to)
+		if err != nil {
+			return Result{Error: fmt.Errorf("getting sales for %s: %w", strings.ToLower(p.label), err)}
+		}
+		msg += fmt.Sprintf("%-10s  %*d sats\n", p.label, width, sats)
+	}
+	msg += fmt.Sprintf("%-10s  %*d sats", "All time", width, total)
+	return Result{Message: msg}
+}
+
+// Limits for the topcustomers command; the cap keeps the reply to one DM.
+const (
+	defaultTopCustomers = 10
+	maxTopCustomers     = 25
+)
+
+// TopCustomersCmd ranks customers by sats spent on fulfilled orders (admin only).
+// Args: optional number of customers and/or a period in days, e.g. "30d"
+func TopCustomersCmd(ctx context.Context, database *db.DB, args []string) Result {
+	usage := Result{Error: fmt.Errorf("usage: topcustomers [n] [days]d, where n is at most %d, e.g. topcustomers 5 30d", maxTopCustomers)}
+	if len(args) > 2 {
+		return usage
+	}
+	limit := defaultTopCustomers
+	sinceDays := 0
+	for _, arg := range args {
+		if days, ok := strings.CutSuffix(strings.ToLower(arg), "d"); ok {
+			n, err := strconv.Atoi(days)
+			if err != nil || n < 1 {
+				return usage
+			}
+			sinceDays = n
+			continue
+		}
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxTopCustomers {
+			return usage
+		}
+		limit = n
+	}
+
+	rankings, err := database.GetTopCustomers(ctx, sinceDays, limit)
+	if err != nil {
+		return Result{Error: fmt.Errorf("ranking customers: %w", err)}
+	}
+
+	period := "all time"
+	if sinceDays > 0 {
+		period = fmt.Sprintf("last %d days", sinceDays)
+	}
+	if len(rankings) == 0 {
+		return Result{Message: fmt.Sprintf("No fulfilled orders (%s).", period)}
+	}
+
+	msg := fmt.Sprintf("Top %d customers by fulfilled sats (%s):\n", len(rankings), period)
+	for i, r := range rankings {
+		who := shortNpub(r.Npub)
+		if r.Name.Valid && r.Name.String != "" {
+			who += fmt.Sprintf(" (%s)", r.Name.String)
+		}
+		msg += fmt.Sprintf("%d. %s: %d sats, %d eggs in %d orders\n", i+1, who, r.Sats, r.Eggs, r.Orders)
+	}
+	return Result{Message: msg}
+}
+
+// conversionDays is the period the conversion command reports on.
+const conversionDays = 30
+
+// ConversionCmd shows how far the orders placed in the last conversionDays
+// days got, to spot customers dropping off at payment (admin only).
+func ConversionCmd(ctx context.Context, database *db.DB) Result {
+	f, err := database.GetOrderFunnel(ctx, conversionDays)
+	if err != nil {
+		return Result{Error: fmt.Errorf("getting order funnel: %w", err)}
+	}
+	if f.Placed == 0 {
+		return Result{Message: fmt.Sprintf("No orders in the last %d days.", conversionDays)}
+	}
+
+	percent := func(n int) int { return n * 100 / f.Placed }
+	msg := fmt.Sprintf("Orders in the last %d days:\n", conversionDays)
+	msg += fmt.Sprintf("Placed: %d\n", f.Placed)
+	msg += fmt.Sprintf("Paid: %d (%d%%)\n", f.Paid, percent(f.Paid))
+	msg += fmt.Sprintf("Fulfilled: %d (%d%%)\n", f.Fulfilled, percent(f.Fulfilled))
+	msg += fmt.Sprintf("Cancelled/expired: %d (%d%%)\n", f.Cancelled, percent(f.Cancelled))
+	msg += fmt.Sprintf("Awaiting payment: %d (%d%%)", f.Pending, percent(f.Pending))
+	return Result{Message: msg}
 }
 
 // SellCmd creates an order on behalf of a customer.
-// Args: [npub] [quantity]
-func SellCmd(ctx context.Context, database *db.DB, args []string, satsPerHalfDozen int) Result {
+// Args: [npub] [quantity] - quantity must be one of allowedQuantities
+// An order that leaves fewer than lowInventoryThreshold eggs sets
+// LowInventoryWarning.
+func SellCmd(ctx context.Context, database *db.DB, args []string, satsPerHalfDozen int, allowedQuantities []int, lowInventoryThreshold int) Result {
 	if len(args) < 2 {
-		return Result{Error: errors.New("usage: sell <npub> <quantity> (6 or 12)")}
+		return Result{Error: fmt.Errorf("usage: sell <npub> <quantity> (%s)", formatQuantities(allowedQuantities))}
 	}
 
 	npub := args[0]
@@ -273,13 +572,9 @@ func SellCmd(ctx context.Context, database *db.DB, args []string, satsPerHalfDoz
 		return Result{Error: errors.New("invalid npub")}
 	}
 
-	quantity, err := strconv.Atoi(args[1])
+	quantity, err := parseQuantity(args[1], allowedQuantities)
 	if err != nil {
-		return Result{Error: errors.New("quantity must be 6 or 12")}
-	}
-
-	if quantity != 6 && quantity != 12 {
-		return Result{Error: errors.New("quantity must be 6 or 12")}
+		return Result{Error: err}
 	}
 
 	// Get customer
@@ -291,12 +586,19 @@ func SellCmd(ctx context.Context, database *db.DB, args []string, satsPerHalfDoz
 		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
 	}
 
-	// Calculate price
-	halfDozens := quantity / 6
-	totalSats := int64(halfDozens * satsPerHalfDozen)
+	// Blocked customers are frozen
+	blocked, err := database.IsBlocked(ctx, npub)
+	if err != nil {
+		return Result{Error: fmt.Errorf("checking blocklist: %w", err)}
+	}
+	if blocked {
+		return Result{Error: errors.New("customer is blocked")}
+	}
+
+	totalSats := orderPrice(quantity, satsPerHalfDozen)
 
 	// Create order (reserves inventory atomically)
-	order, err := database.CreateOrder(ctx, customer.ID, quantity, totalSats)
+	order, deduction, err := database.CreateOrderWithCheck(ctx, customer.ID, quantity, totalSats, lowInventoryThreshold)
 	if err != nil {
 		if errors.Is(err, db.ErrInsufficientInventory) {
 			available, _ := database.GetInventory(ctx)
@@ -305,12 +607,11 @@ func SellCmd(ctx context.Context, database *db.DB, args []string, satsPerHalfDoz
 		return Result{Error: fmt.Errorf("creating order: %w", err)}
 	}
 
-	// Truncate npub for display
-	npubShort := npub
-	if len(npubShort) > 20 {
-		npubShort = npubShort[:12] + "..." + npubShort[len(npubShort)-4:]
-	}
+	npubShort := shortNpub(npub)
 
-	return Result{Message: fmt.Sprintf("Created order #%d: %d eggs for %s (%d sats, pending)", order.ID, quantity, npubShort, totalSats)}
+	return Result{
+		Message:             fmt.Sprintf("Created order #%d: %d eggs for %s (%d sats, pending)", order.ID, quantity, npubShort, totalSats),
+		LowInventoryWarning: deduction.LowInventoryWarning,
+		EggsRemaining:       deduction.Remaining,
+	}
 }
-
@@ -5,6 +5,11 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 // Test keypairs are defined in customer_commands_test.go:
@@ -17,7 +22,7 @@ func TestDeliverCmd(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Setup customer and inventory
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 30)
 
 	// Create orders in different states for testing
@@ -91,7 +96,7 @@ func TestDeliverCmd_VerifiesOrderStateChange(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Setup customer and inventory
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 30)
 
 	// Create a paid order
@@ -132,7 +137,7 @@ func TestMarkpaidCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	customer, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	customer, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 12)
 	pendingOrder, _ := database.CreateOrder(ctx, customer.ID, 6, 3200)
 
@@ -195,7 +200,7 @@ func TestAdjustCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	tests := []struct {
 		name        string
@@ -251,7 +256,7 @@ func TestCustomersCmd(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Empty list
-	result := CustomersCmd(ctx, database)
+	result := CustomersCmd(ctx, database, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -260,15 +265,70 @@ func TestCustomersCmd(t *testing.T) {
 	}
 
 	// Add customer
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
-	result = CustomersCmd(ctx, database)
+	result = CustomersCmd(ctx, database, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
 	if !strings.Contains(result.Message, "1 registered customers") {
 		t.Errorf("expected 1 customer, got %q", result.Message)
 	}
+	if strings.Contains(result.Message, "page") {
+		t.Errorf("a single page should not be numbered: %q", result.Message)
+	}
+}
+
+func TestCustomersCmd_Pages(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	// 60 customers make three pages of 25, 25 and 10
+	var npubs []string
+	for i := range 60 {
+		pubkeyHex := fmt.Sprintf("%064x", i+1)
+		npub, _ := nip19.EncodePublicKey(pubkeyHex)
+		if _, err := database.CreateCustomer(ctx, npub, ""); err != nil {
+			t.Fatalf("creating customer: %v", err)
+		}
+		npubs = append(npubs, npub)
+	}
+
+	tests := []struct {
+		args       []string
+		header     string
+		first      string
+		wantListed int
+	}{
+		{nil, `60 registered customers (page 1 of 3, use "customers 2" for next page):`, npubs[0], 25},
+		{[]string{"2"}, `60 registered customers (page 2 of 3, use "customers 3" for next page):`, npubs[25], 25},
+		{[]string{"3"}, "60 registered customers (page 3 of 3):", npubs[50], 10},
+	}
+	for _, tt := range tests {
+		result := CustomersCmd(ctx, database, tt.args)
+		if result.Error != nil {
+			t.Fatalf("CustomersCmd(%v): %v", tt.args, result.Error)
+		}
+		lines := strings.Split(strings.TrimSpace(result.Message), "\n")
+		if lines[0] != tt.header {
+			t.Errorf("CustomersCmd(%v) header = %q, want %q", tt.args, lines[0], tt.header)
+		}
+		if len(lines)-1 != tt.wantListed {
+			t.Errorf("CustomersCmd(%v) listed %d customers, want %d", tt.args, len(lines)-1, tt.wantListed)
+		}
+		if lines[1] != "• "+tt.first {
+			t.Errorf("CustomersCmd(%v) first line = %q, want %s", tt.args, lines[1], tt.first)
+		}
+	}
+
+	if result := CustomersCmd(ctx, database, []string{"4"}); result.Message != "Page 4 is out of range: there are 3 pages (60 customers)." {
+		t.Errorf("page 4 = %q", result.Message)
+	}
+	for _, args := range [][]string{{"0"}, {"two"}, {"1", "2"}} {
+		if result := CustomersCmd(ctx, database, args); result.Error == nil {
+			t.Errorf("CustomersCmd(%v) should fail", args)
+		}
+	}
 }
 
 func TestAddCustomerCmd(t *testing.T) {
@@ -306,6 +366,24 @@ func TestAddCustomerCmd(t *testing.T) {
 			wantErr:     false,
 			msgContains: "already registered",
 		},
+		{
+			name:        "add with quoted name",
+			args:        Parse(`addcustomer ` + testAdminNpub + ` "Jane from the co-op"`).Args,
+			wantErr:     false,
+			msgContains: "(Jane from the co-op)",
+		},
+		{
+			name:        "add with unquoted name",
+			args:        []string{testUnknownNpub, "Jane", "Doe"},
+			wantErr:     false,
+			msgContains: "(Jane Doe)",
+		},
+		{
+			name:        "name for registered customer",
+			args:        []string{testCustomerNpub, "Jane", "Doe"},
+			wantErr:     false,
+			msgContains: "name updated to Jane Doe",
+		},
 	}
 
 	for _, tt := range tests {
@@ -330,12 +408,59 @@ func TestAddCustomerCmd(t *testing.T) {
 	}
 }
 
+func TestAddCustomerCmd_StoresName(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	cmd := Parse(`addcustomer ` + testCustomerNpub + ` 'Jane "JJ" Doe'`)
+	result := AddCustomerCmd(ctx, database, cmd.Args)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+
+	c, err := database.GetCustomerByNpub(ctx, testCustomerNpub)
+	if err != nil {
+		t.Fatalf("GetCustomerByNpub: %v", err)
+	}
+	if !c.Name.Valid || c.Name.String != `Jane "JJ" Doe` {
+		t.Errorf("expected name %q, got %+v", `Jane "JJ" Doe`, c.Name)
+	}
+}
+
+func TestAddCustomerCmd_UpdatesName(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	if result := AddCustomerCmd(ctx, database, []string{testCustomerNpub}); result.Error != nil {
+		t.Fatalf("adding without a name: %v", result.Error)
+	}
+	if c, _ := database.GetCustomerByNpub(ctx, testCustomerNpub); c == nil || c.Name.Valid {
+		t.Fatalf("expected no name, got %+v", c)
+	}
+
+	result := AddCustomerCmd(ctx, database, []string{testCustomerNpub, "Jane", "from", "the", "co-op"})
+	if result.Error != nil {
+		t.Fatalf("setting the name: %v", result.Error)
+	}
+	if c, _ := database.GetCustomerByNpub(ctx, testCustomerNpub); c == nil || c.Name.String != "Jane from the co-op" {
+		t.Errorf("expected the name set, got %+v", c)
+	}
+
+	// Adding again without a name keeps it
+	if result := AddCustomerCmd(ctx, database, []string{testCustomerNpub}); result.Message != "Customer already registered." {
+		t.Errorf("re-adding = %q, %v", result.Message, result.Error)
+	}
+	if c, _ := database.GetCustomerByNpub(ctx, testCustomerNpub); c == nil || c.Name.String != "Jane from the co-op" {
+		t.Errorf("expected the name kept, got %+v", c)
+	}
+}
+
 func TestOrdersCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
 	// Empty orders list
-	result := OrdersCmd(ctx, database)
+	result := OrdersCmd(ctx, database, nil, TimeDisplay{})
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -344,8 +469,8 @@ func TestOrdersCmd(t *testing.T) {
 	}
 
 	// Setup: create customers and inventory
-	c1, _ := database.CreateCustomer(ctx, testCustomerNpub)
-	c2, _ := database.CreateCustomer(ctx, testAdminNpub)
+	c1, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	c2, _ := database.CreateCustomer(ctx, testAdminNpub, "")
 	_ = database.AddEggs(ctx, 50)
 
 	// Create orders for different customers in different states
@@ -354,7 +479,7 @@ func TestOrdersCmd(t *testing.T) {
 	_ = database.UpdateOrderStatus(ctx, order2.ID, "paid")
 
 	// List orders
-	result = OrdersCmd(ctx, database)
+	result = OrdersCmd(ctx, database, nil, TimeDisplay{})
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -386,11 +511,234 @@ func TestOrdersCmd(t *testing.T) {
 	_ = order1 // Ensure we created both orders
 }
 
+func TestListings_ShowNIP05(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c1, _ := database.CreateCustomer(ctx, testCustomerNpub, "Jane")
+	c2, _ := database.CreateCustomer(ctx, testAdminNpub, "")
+	_ = database.AddEggs(ctx, 12)
+	_, _ = database.CreateOrder(ctx, c1.ID, 6, 3200)
+	_, _ = database.CreateOrder(ctx, c2.ID, 6, 3200)
+	_ = database.SaveProfile(ctx, testCustomerNpub, "jane@example.com")
+	_ = database.SaveProfile(ctx, testAdminNpub, "")
+
+	result := OrdersCmd(ctx, database, nil, TimeDisplay{})
+	if !strings.Contains(result.Message, ": jane@example.com | 6 eggs") {
+		t.Errorf("orders should show the identifier: %q", result.Message)
+	}
+	if short := testAdminNpub[:12] + "..." + testAdminNpub[len(testAdminNpub)-4:]; !strings.Contains(result.Message, short) {
+		t.Errorf("orders should fall back to %s: %q", short, result.Message)
+	}
+
+	result = CustomersCmd(ctx, database, nil)
+	if !strings.Contains(result.Message, testCustomerNpub+" (Jane, jane@example.com)") {
+		t.Errorf("customers should show the identifier after the name: %q", result.Message)
+	}
+	if !strings.Contains(result.Message, testAdminNpub+"\n") {
+		t.Errorf("customers without a name or identifier: %q", result.Message)
+	}
+}
+
+func TestOrdersCmd_FilterAndPages(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 6*(ordersPageSize+3))
+	// ordersPageSize+1 pending orders, then two paid ones
+	for range ordersPageSize + 3 {
+		if _, err := database.CreateOrder(ctx, c.ID, 6, 3200); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+	_ = database.UpdateOrderStatus(ctx, ordersPageSize+2, "paid")
+	_ = database.UpdateOrderStatus(ctx, ordersPageSize+3, "paid")
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantPrefix string
+		wantLines  int
+		wantNext   string
+	}{
+		{"default first page", nil, "Page 1 of 2 (53 orders), most recent first:", ordersPageSize, `Send "orders 2" for older orders.`},
+		{"second page", []string{"2"}, "Page 2 of 2 (53 orders), most recent first:", 3, ""},
+		{"status filter", []string{"paid"}, "2 paid orders (most recent first):", 2, ""},
+		{"status is case-insensitive", []string{"PAID"}, "2 paid orders (most recent first):", 2, ""},
+		{"status and page", []string{"pending", "2"}, "Page 2 of 2 (51 orders), most recent first:", 1, ""},
+		{"status first page", []string{"pending"}, "Page 1 of 2 (51 orders)", ordersPageSize, `Send "orders pending 2" for older orders.`},
+		{"out of range", []string{"3"}, "Page 3 is out of range: there are 2 pages (53 orders).", 0, ""},
+		{"out of range filtered", []string{"paid", "2"}, "Page 2 is out of range: there is only 1 page (2 orders).", 0, ""},
+		{"no matches", []string{"fulfilled"}, "No fulfilled orders found.", 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := OrdersCmd(ctx, database, tt.args, TimeDisplay{})
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if !strings.HasPrefix(result.Message, tt.wantPrefix) {
+				t.Errorf("expected prefix %q, got %q", tt.wantPrefix, result.Message)
+			}
+			if n := strings.Count(result.Message, "• #"); n != tt.wantLines {
+				t.Errorf("got %d orders, want %d", n, tt.wantLines)
+			}
+			if tt.wantNext != "" && !strings.HasSuffix(result.Message, tt.wantNext) {
+				t.Errorf("expected next page hint %q, got %q", tt.wantNext, result.Message)
+			}
+			if tt.wantNext == "" && strings.Contains(result.Message, "Send ") {
+				t.Errorf("unexpected next page hint: %q", result.Message)
+			}
+		})
+	}
+
+	for _, args := range [][]string{{"0"}, {"shipped"}, {"paid", "x"}, {"1", "2"}} {
+		if result := OrdersCmd(ctx, database, args, TimeDisplay{}); result.Error == nil {
+			t.Errorf("orders %v: expected a usage error", args)
+		}
+	}
+}
+
+func TestTransactionsCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	result := TransactionsCmd(ctx, database, nil, nil)
+	if result.Error != nil || result.Message != "No transactions yet." {
+		t.Fatalf("empty ledger = %q, %v", result.Message, result.Error)
+	}
+
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 6)
+	order, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
+	zapID := strings.Repeat("ab", 32)
+	_, _ = database.RecordTransaction(ctx, &order.ID, zapID, db.TransactionSourceZap, 3200, testCustomerNpub)
+	if adjust := AdjustCmd(ctx, database, []string{testCustomerNpub, "-500"}); adjust.Error != nil {
+		t.Fatalf("adjust: %v", adjust.Error)
+	}
+	for i := range 25 {
+		_, _ = database.RecordTransaction(ctx, nil, fmt.Sprintf("simulated-%d", i), db.TransactionSourceSynthetic, 100, testAdminNpub)
+	}
+
+	result = TransactionsCmd(ctx, database, nil, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if n := strings.Count(result.Message, "• #"); n != defaultTransactionsLimit {
+		t.Errorf("got %d transactions, want %d", n, defaultTransactionsLimit)
+	}
+	if !strings.Contains(result.Message, "synthetic: +100 sats") {
+		t.Errorf("expected synthetic entries labelled, got %q", result.Message)
+	}
+
+	result = TransactionsCmd(ctx, database, []string{testCustomerNpub}, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	lines := strings.Split(strings.TrimSpace(result.Message), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected a header and two entries, got %q", result.Message)
+	}
+	if !strings.Contains(lines[1], "adjustment: -500 sats | npub1rm9q804...8ejt | no order |") {
+		t.Errorf("adjustment line = %q", lines[1])
+	}
+	if !strings.Contains(lines[2], fmt.Sprintf("zap: +3200 sats | npub1rm9q804...8ejt | order #%d (6 eggs, pending) |", order.ID)) {
+		t.Errorf("zap line = %q", lines[2])
+	}
+
+	if result := TransactionsCmd(ctx, database, []string{"5"}, nil); strings.Count(result.Message, "• #") != 5 {
+		t.Errorf("limit 5 = %q", result.Message)
+	}
+	for _, args := range [][]string{{"0"}, {"101"}, {"x"}, {"npub1bad"}} {
+		if result := TransactionsCmd(ctx, database, args, nil); result.Error == nil {
+			t.Errorf("transactions %v: expected an error", args)
+		}
+	}
+}
+
+func TestTopCustomersCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	if result := TopCustomersCmd(ctx, database, nil); result.Message != "No fulfilled orders (all time)." {
+		t.Errorf("empty = %q", result.Message)
+	}
+
+	customer, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	admin, _ := database.CreateCustomer(ctx, testAdminNpub, "")
+	_ = database.UpdateCustomerName(ctx, testAdminNpub, "Ada")
+	_ = database.AddEggs(ctx, 30)
+	for _, o := range []struct {
+		customerID int64
+		quantity   int
+		sats       int64
+	}{{customer.ID, 6, 3200}, {admin.ID, 12, 6400}, {admin.ID, 6, 3200}} {
+		order, _ := database.CreateOrder(ctx, o.customerID, o.quantity, o.sats)
+		_ = database.UpdateOrderStatus(ctx, order.ID, "paid")
+		_ = database.FulfillOrder(ctx, order.ID)
+	}
+
+	result := TopCustomersCmd(ctx, database, []string{"30d"})
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	want := "Top 2 customers by fulfilled sats (last 30 days):\n" +
+		"1. " + testAdminNpub[:12] + "..." + testAdminNpub[len(testAdminNpub)-4:] + " (Ada): 9600 sats, 18 eggs in 2 orders\n" +
+		"2. npub1rm9q804...8ejt: 3200 sats, 6 eggs in 1 orders\n"
+	if result.Message != want {
+		t.Errorf("got %q, want %q", result.Message, want)
+	}
+
+	if result := TopCustomersCmd(ctx, database, []string{"1"}); strings.Count(result.Message, "\n") != 2 {
+		t.Errorf("limit 1 = %q", result.Message)
+	}
+	for _, args := range [][]string{{"0"}, {"26"}, {"xd"}, {"0d"}, {"1", "2", "3"}} {
+		if result := TopCustomersCmd(ctx, database, args); result.Error == nil {
+			t.Errorf("topcustomers %v: expected a usage error", args)
+		}
+	}
+}
+
+func TestConversionCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	if result := ConversionCmd(ctx, database); result.Message != "No orders in the last 30 days." {
+		t.Errorf("empty = %q", result.Message)
+	}
+
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 24)
+	var ids []int64
+	for range 4 {
+		order, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
+		ids = append(ids, order.ID)
+	}
+	_ = database.UpdateOrderStatus(ctx, ids[0], "paid")
+	_ = database.FulfillOrder(ctx, ids[0])
+	_ = database.UpdateOrderStatus(ctx, ids[1], "paid")
+	_ = database.CancelOrder(ctx, ids[2])
+
+	result := ConversionCmd(ctx, database)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	want := "Orders in the last 30 days:\n" +
+		"Placed: 4\n" +
+		"Paid: 2 (50%)\n" +
+		"Fulfilled: 1 (25%)\n" +
+		"Cancelled/expired: 1 (25%)\n" +
+		"Awaiting payment: 1 (25%)"
+	if result.Message != want {
+		t.Errorf("got %q, want %q", result.Message, want)
+	}
+}
+
 func TestRemoveCustomerCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	tests := []struct {
 		name        string
@@ -441,12 +789,51 @@ func TestRemoveCustomerCmd(t *testing.T) {
 	}
 }
 
+func TestSellCmd_AllowedQuantities(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 30)
+
+	result := SellCmd(ctx, database, []string{testCustomerNpub, "6"}, 3200, []int{10}, 0)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "quantity must be 10") {
+		t.Errorf("expected quantity error, got %v", result.Error)
+	}
+
+	result = SellCmd(ctx, database, []string{testCustomerNpub, "10"}, 3200, []int{10}, 0)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "10 eggs") {
+		t.Errorf("expected 10 eggs sold, got %q", result.Message)
+	}
+}
+
+func TestSellCmd_LowInventoryWarning(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 18)
+
+	// Only the sale that takes inventory below 6 eggs warns
+	for i, want := range []bool{false, true, false} {
+		result := SellCmd(ctx, database, []string{testCustomerNpub, "6"}, 3200, config.DefaultAllowedQuantities, 7)
+		if result.Error != nil {
+			t.Fatalf("sale %d: unexpected error: %v", i+1, result.Error)
+		}
+		if result.LowInventoryWarning != want {
+			t.Errorf("sale %d: LowInventoryWarning = %v, want %v", i+1, result.LowInventoryWarning, want)
+		}
+	}
+}
+
 func TestSalesCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
 	// No sales yet
-	result := SalesCmd(ctx, database)
+	result := SalesCmd(ctx, database, TimeDisplay{})
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -455,12 +842,12 @@ func TestSalesCmd(t *testing.T) {
 	}
 
 	// Create customer and inventory
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 50)
 
 	// Pending order should not count
 	_, _ = database.CreateOrder(ctx, c.ID, 6, 3200)
-	result = SalesCmd(ctx, database)
+	result = SalesCmd(ctx, database, TimeDisplay{})
 	if !strings.Contains(result.Message, "No sales yet") {
 		t.Errorf("pending order should not count as sale, got %q", result.Message)
 	}
@@ -470,12 +857,12 @@ func TestSalesCmd(t *testing.T) {
 	_ = database.UpdateOrderStatus(ctx, order2.ID, "paid")
 	_ = database.FulfillOrder(ctx, order2.ID)
 
-	result = SalesCmd(ctx, database)
+	result = SalesCmd(ctx, database, TimeDisplay{})
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
-	if !strings.Contains(result.Message, "Total sales:") {
-		t.Errorf("expected total sales message, got %q", result.Message)
+	if !strings.Contains(result.Message, "All time") {
+		t.Errorf("expected all-time sales, got %q", result.Message)
 	}
 	if !strings.Contains(result.Message, "3200 sats") {
 		t.Errorf("expected 3200 sats, got %q", result.Message)
@@ -486,9 +873,63 @@ func TestSalesCmd(t *testing.T) {
 	_ = database.UpdateOrderStatus(ctx, order3.ID, "paid")
 	_ = database.FulfillOrder(ctx, order3.ID)
 
-	result = SalesCmd(ctx, database)
+	result = SalesCmd(ctx, database, TimeDisplay{})
 	if !strings.Contains(result.Message, "9600 sats") {
 		t.Errorf("expected 9600 sats (3200+6400), got %q", result.Message)
 	}
 }
 
+func TestSalesCmd_Periods(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 100)
+
+	// A Wednesday in the middle of the month, so each bucket holds different orders
+	now := time.Date(2025, 7, 16, 15, 0, 0, 0, time.UTC)
+	placeAt := func(createdAt time.Time, sats int64) {
+		t.Helper()
+		order, _ := database.CreateOrder(ctx, c.ID, 6, sats)
+		_ = database.UpdateOrderStatus(ctx, order.ID, "paid")
+		if err := database.FulfillOrder(ctx, order.ID); err != nil {
+			t.Fatalf("FulfillOrder: %v", err)
+		}
+		if _, err := database.ExecContext(ctx, `UPDATE orders SET created_at = ? WHERE id = ?`,
+			createdAt.UTC().Format("2006-01-02 15:04:05"), order.ID); err != nil {
+			t.Fatalf("backdating order: %v", err)
+		}
+	}
+	placeAt(now.Add(-time.Hour), 1)       // Today
+	placeAt(now.AddDate(0, 0, -2), 20)    // Monday
+	placeAt(now.AddDate(0, 0, -3), 300)   // Last Sunday, still this month
+	placeAt(now.AddDate(0, -1, 0), 4000)  // Last month
+	placeAt(now.AddDate(-1, 0, 0), 50000) // Last year
+	total, _ := database.GetTotalSales(ctx)
+
+	td := TimeDisplay{Now: func() time.Time { return now }}
+	result := SalesCmd(ctx, database, td)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	for _, want := range []string{
+		"Today           1 sats",
+		"This week      21 sats",
+		"This month    321 sats",
+		fmt.Sprintf("All time    %5d sats", total),
+	} {
+		if !strings.Contains(result.Message, want) {
+			t.Errorf("expected %q in:\n%s", want, result.Message)
+		}
+	}
+	if total != 54321 {
+		t.Errorf("GetTotalSales = %d, want 54321", total)
+	}
+
+	// Days follow the display zone: at 01:00 on Thursday in UTC+10, the
+	// order placed at 14:00 UTC on Wednesday is from today
+	td.Location = time.FixedZone("UTC+10", 10*60*60)
+	result = SalesCmd(ctx, database, td)
+	if !strings.Contains(result.Message, "Today           1 sats") {
+		t.Errorf("expected today's sales in UTC+10, got:\n%s", result.Message)
+	}
+}
@@ -0,0 +1,110 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// AddAdminCmd grants admin privileges to an npub (admin only).
+// Args: [npub]
+func AddAdminCmd(ctx context.Context, database *db.DB, args []string, senderNpub string) Result {
+	if len(args) < 1 {
+		return Result{Error: errors.New("usage: addadmin <npub>")}
+	}
+
+	npub := args[0]
+	if !strings.HasPrefix(npub, "npub1") {
+		return Result{Error: errors.New("invalid npub format")}
+	}
+
+	// Validate npub
+	prefix, _, err := nip19.Decode(npub)
+	if err != nil || prefix != "npub" {
+		return Result{Error: errors.New("invalid npub")}
+	}
+
+	err = database.AddAdmin(ctx, npub, senderNpub)
+	if errors.Is(err, db.ErrAdminExists) {
+		return Result{Message: "Already an admin."}
+	}
+	if err != nil {
+		return Result{Error: fmt.Errorf("adding admin: %w", err)}
+	}
+
+	return Result{Message: fmt.Sprintf("Added admin %s", npub)}
+}
+
+// RemoveAdminCmd revokes admin privileges from an npub (admin only).
+// Args: [npub]
+// Admins listed in the config file can only be removed by editing it.
+func RemoveAdminCmd(ctx context.Context, database *db.DB, args []string) Result {
+	if len(args) < 1 {
+		return Result{Error: errors.New("usage: removeadmin <npub>")}
+	}
+
+	npub := args[0]
+	if !strings.HasPrefix(npub, "npub1") {
+		return Result{Error: errors.New("invalid npub format")}
+	}
+
+	err := database.RemoveAdmin(ctx, npub)
+	switch {
+	case errors.Is(err, db.ErrAdminNotFound):
+		return Result{Error: errors.New("admin not found")}
+	case errors.Is(err, db.ErrConfigAdmin):
+		return Result{Error: errors.New("admin is listed in the config file; remove them there")}
+	case errors.Is(err, db.ErrLastAdmin):
+		return Result{Error: errors.New("cannot remove the last admin")}
+	case err != nil:
+		return Result{Error: fmt.Errorf("removing admin: %w", err)}
+	}
+
+	return Result{Message: fmt.Sprintf("Removed admin %s", npub)}
+}
+
+// AdminPrefsCmd shows the sender's notification preferences, or turns one on
+// or off (admin only).
+// Args: [] or [orders|payments|inventory, on|off]
+func AdminPrefsCmd(ctx context.Context, database *db.DB, args []string, senderNpub string) Result {
+	usage := "usage: adminprefs [" + strings.Join(db.AdminPrefNames, "|") + " on|off]"
+	if len(args) != 0 {
+		if len(args) != 2 {
+			return Result{Error: errors.New(usage)}
+		}
+		var on bool
+		switch strings.ToLower(args[1]) {
+		case "on":
+			on = true
+		case "off":
+		default:
+			return Result{Error: errors.New(usage)}
+		}
+		err := database.SetAdminPref(ctx, senderNpub, strings.ToLower(args[0]), on)
+		if errors.Is(err, db.ErrUnknownAdminPref) {
+			return Result{Error: errors.New(usage)}
+		}
+		if err != nil {
+			return Result{Error: fmt.Errorf("setting notification preference: %w", err)}
+		}
+	}
+
+	prefs, err := database.GetAdminPrefs(ctx, senderNpub)
+	if err != nil {
+		return Result{Error: fmt.Errorf("getting notification preferences: %w", err)}
+	}
+	var b strings.Builder
+	b.WriteString("Your notifications:")
+	for _, name := range db.AdminPrefNames {
+		state := "off"
+		if prefs.Wants(name) {
+			state = "on"
+		}
+		fmt.Fprintf(&b, "\n• %s: %s", name, state)
+	}
+	return Result{Message: b.String()}
+}
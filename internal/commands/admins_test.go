@@ -0,0 +1,143 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAddAdminCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+
+	if IsAdmin(ctx, database, testCustomerNpub) {
+		t.Fatal("expected customer not admin before addadmin")
+	}
+
+	result := AddAdminCmd(ctx, database, []string{testCustomerNpub}, testAdminNpub)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "Added admin") {
+		t.Errorf("message = %q, want containing %q", result.Message, "Added admin")
+	}
+	if !IsAdmin(ctx, database, testCustomerNpub) {
+		t.Error("expected customer to be admin after addadmin")
+	}
+
+	result = AddAdminCmd(ctx, database, []string{testCustomerNpub}, testAdminNpub)
+	if result.Error != nil || !strings.Contains(result.Message, "Already") {
+		t.Errorf("expected already-admin message, got %q (err %v)", result.Message, result.Error)
+	}
+
+	for _, args := range [][]string{{}, {"notanpub"}} {
+		if result := AddAdminCmd(ctx, database, args, testAdminNpub); result.Error == nil {
+			t.Errorf("AddAdminCmd(%v): expected error", args)
+		}
+	}
+}
+
+func TestRemoveAdminCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	_ = database.AddAdmin(ctx, testCustomerNpub, testAdminNpub)
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name:        "no args",
+			args:        []string{},
+			wantErr:     true,
+			errContains: "usage",
+		},
+		{
+			name:        "config admin refused",
+			args:        []string{testAdminNpub},
+			wantErr:     true,
+			errContains: "config",
+		},
+		{
+			name:        "unknown admin",
+			args:        []string{strangerNpub},
+			wantErr:     true,
+			errContains: "not found",
+		},
+		{
+			name:    "remove DM-added admin",
+			args:    []string{testCustomerNpub},
+			wantErr: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RemoveAdminCmd(ctx, database, tt.args)
+			if tt.wantErr {
+				if result.Error == nil {
+					t.Fatalf("expected error containing %q, got nil", tt.errContains)
+				}
+				if !strings.Contains(result.Error.Error(), tt.errContains) {
+					t.Errorf("error = %q, want containing %q", result.Error.Error(), tt.errContains)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+		})
+	}
+
+	if IsAdmin(ctx, database, testCustomerNpub) {
+		t.Error("expected removed admin to lose privileges")
+	}
+}
+
+func TestRemoveAdminCmd_LastAdmin(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.AddAdmin(ctx, testAdminNpub, testAdminNpub)
+
+	result := RemoveAdminCmd(ctx, database, []string{testAdminNpub})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "last admin") {
+		t.Errorf("expected last admin error, got %v", result.Error)
+	}
+}
+
+func TestAdminPrefsCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+
+	result := AdminPrefsCmd(ctx, database, nil, testAdminNpub)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	want := "Your notifications:\n• orders: on\n• payments: on\n• inventory: on"
+	if result.Message != want {
+		t.Errorf("message = %q, want %q", result.Message, want)
+	}
+
+	result = AdminPrefsCmd(ctx, database, []string{"Orders", "OFF"}, testAdminNpub)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "• orders: off") || !strings.Contains(result.Message, "• payments: on") {
+		t.Errorf("message = %q, want orders off and payments on", result.Message)
+	}
+	if prefs, _ := database.GetAdminPrefs(ctx, testAdminNpub); prefs.Orders {
+		t.Error("expected orders turned off")
+	}
+
+	for _, args := range [][]string{{"orders"}, {"orders", "maybe"}, {"sms", "off"}, {"orders", "off", "now"}} {
+		result := AdminPrefsCmd(ctx, database, args, testAdminNpub)
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "usage") {
+			t.Errorf("AdminPrefsCmd(%v): expected usage error, got %v", args, result.Error)
+		}
+	}
+}
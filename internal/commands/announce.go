@@ -0,0 +1,39 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// ErrAnnounceThrottled is returned by an Announcer asked to announce again
+// within the configured interval.
+var ErrAnnounceThrottled = errors.New("inventory was announced too recently")
+
+// Announcer publishes the available egg count where the bot's followers can
+// see it.
+type Announcer interface {
+	Announce(ctx context.Context, available int) error
+}
+
+// AnnounceCmd publishes the current available egg count. Reservations, sales
+// and customers are never part of an announcement.
+func AnnounceCmd(ctx context.Context, database *db.DB, announcer Announcer) Result {
+	if announcer == nil {
+		return Result{Error: errors.New("announcements are off; set announce.mode to note or replaceable")}
+	}
+
+	available, err := database.GetInventory(ctx)
+	if err != nil {
+		return Result{Error: fmt.Errorf("getting inventory: %w", err)}
+	}
+	if err := announcer.Announce(ctx, available); err != nil {
+		if errors.Is(err, ErrAnnounceThrottled) {
+			return Result{Message: fmt.Sprintf("Not announced: %v.", err)}
+		}
+		return Result{Error: fmt.Errorf("announcing inventory: %w", err)}
+	}
+	return Result{Message: fmt.Sprintf("Announced %d eggs available.", available)}
+}
@@ -0,0 +1,51 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fakeAnnouncer records announced counts, or fails with err.
+type fakeAnnouncer struct {
+	announced []int
+	err       error
+}
+
+func (a *fakeAnnouncer) Announce(_ context.Context, available int) error {
+	if a.err != nil {
+		return a.err
+	}
+	a.announced = append(a.announced, available)
+	return nil
+}
+
+func TestAnnounceCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.AddEggs(ctx, 18)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	// Reserved eggs are not announced
+	if _, err := database.CreateOrder(ctx, c.ID, 6, 3200); err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+
+	if result := AnnounceCmd(ctx, database, nil); result.Error == nil {
+		t.Error("expected an error when announcements are off")
+	}
+
+	announcer := &fakeAnnouncer{}
+	result := AnnounceCmd(ctx, database, announcer)
+	if result.Error != nil {
+		t.Fatalf("AnnounceCmd: %v", result.Error)
+	}
+	if result.Message != "Announced 12 eggs available." || len(announcer.announced) != 1 || announcer.announced[0] != 12 {
+		t.Errorf("message %q, announced %v; want 12 available", result.Message, announcer.announced)
+	}
+
+	announcer.err = fmt.Errorf("%w, the next one can go out in 40m0s", ErrAnnounceThrottled)
+	result = AnnounceCmd(ctx, database, announcer)
+	if result.Error != nil || result.Message != "Not announced: inventory was announced too recently, the next one can go out in 40m0s." {
+		t.Errorf("throttled announce = %q, %v", result.Message, result.Error)
+	}
+}
@@ -0,0 +1,97 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// BlockCmd adds an npub to the blocklist (admin only).
+// Args: [npub]
+// DMs and zaps from blocked npubs are dropped. A registered customer's account
+// is frozen: it stays in the database but can't order or receive notifications.
+func BlockCmd(ctx context.Context, database *db.DB, args []string, adminNpub string) Result {
+	if len(args) < 1 {
+		return Result{Error: errors.New("usage: block <npub>")}
+	}
+
+	npub := args[0]
+	if !strings.HasPrefix(npub, "npub1") {
+		return Result{Error: errors.New("invalid npub format")}
+	}
+
+	// Validate npub
+	prefix, _, err := nip19.Decode(npub)
+	if err != nil || prefix != "npub" {
+		return Result{Error: errors.New("invalid npub")}
+	}
+
+	if IsAdmin(ctx, database, npub) {
+		return Result{Error: errors.New("cannot block an admin")}
+	}
+
+	err = database.BlockNpub(ctx, npub, adminNpub)
+	if errors.Is(err, db.ErrAlreadyBlocked) {
+		return Result{Message: "Already blocked."}
+	}
+	if err != nil {
+		return Result{Error: fmt.Errorf("blocking npub: %w", err)}
+	}
+
+	_, err = database.GetCustomerByNpub(ctx, npub)
+	if err == nil {
+		return Result{Message: fmt.Sprintf("Blocked %s (customer account frozen)", npub)}
+	}
+	if !errors.Is(err, db.ErrCustomerNotFound) {
+		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
+	}
+
+	return Result{Message: fmt.Sprintf("Blocked %s", npub)}
+}
+
+// UnblockCmd removes an npub from the blocklist (admin only).
+// Args: [npub]
+func UnblockCmd(ctx context.Context, database *db.DB, args []string) Result {
+	if len(args) < 1 {
+		return Result{Error: errors.New("usage: unblock <npub>")}
+	}
+
+	npub := args[0]
+	if !strings.HasPrefix(npub, "npub1") {
+		return Result{Error: errors.New("invalid npub format")}
+	}
+
+	err := database.UnblockNpub(ctx, npub)
+	if errors.Is(err, db.ErrNotBlocked) {
+		return Result{Error: errors.New("npub is not blocked")}
+	}
+	if err != nil {
+		return Result{Error: fmt.Errorf("unblocking npub: %w", err)}
+	}
+
+	return Result{Message: fmt.Sprintf("Unblocked %s", npub)}
+}
+
+// BlockedCmd lists blocked npubs with when and by whom they were blocked (admin only).
+func BlockedCmd(ctx context.Context, database *db.DB, loc *time.Location) Result {
+	entries, err := database.ListBlocked(ctx)
+	if err != nil {
+		return Result{Error: fmt.Errorf("listing blocked npubs: %w", err)}
+	}
+
+	if len(entries) == 0 {
+		return Result{Message: "No blocked npubs."}
+	}
+
+	msg := fmt.Sprintf("%d blocked npubs:\n", len(entries))
+	for _, b := range entries {
+		by := shortNpub(b.BlockedBy)
+		msg += fmt.Sprintf("• %s - %s by %s\n", b.Npub, FormatTime(b.CreatedAt, loc), by)
+	}
+	return Result{Message: msg}
+}
@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/config"
+)
+
+// strangerNpub is a valid npub that is neither a customer nor an admin.
+const strangerNpub = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqzqunz0d4"
+
+func TestBlockCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantErr     bool
+		errContains string
+		msgContains string
+	}{
+		{
+			name:        "no args",
+			args:        []string{},
+			wantErr:     true,
+			errContains: "usage",
+		},
+		{
+			name:        "invalid npub",
+			args:        []string{"notanpub"},
+			wantErr:     true,
+			errContains: "invalid npub",
+		},
+		{
+			name:        "cannot block admin",
+			args:        []string{testAdminNpub},
+			wantErr:     true,
+			errContains: "admin",
+		},
+		{
+			name:        "block customer freezes account",
+			args:        []string{testCustomerNpub},
+			wantErr:     false,
+			msgContains: "account frozen",
+		},
+		{
+			name:        "already blocked",
+			args:        []string{testCustomerNpub},
+			wantErr:     false,
+			msgContains: "Already blocked",
+		},
+		{
+			name:        "block unknown npub",
+			args:        []string{strangerNpub},
+			wantErr:     false,
+			msgContains: "Blocked " + strangerNpub,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := BlockCmd(ctx, database, tt.args, testAdminNpub)
+
+			if tt.wantErr {
+				if result.Error == nil {
+					t.Errorf("expected error containing %q, got nil", tt.errContains)
+					return
+				}
+				if !strings.Contains(result.Error.Error(), tt.errContains) {
+					t.Errorf("error = %q, want containing %q", result.Error.Error(), tt.errContains)
+				}
+				return
+			}
+
+			if result.Error != nil {
+				t.Errorf("unexpected error: %v", result.Error)
+				return
+			}
+			if !strings.Contains(result.Message, tt.msgContains) {
+				t.Errorf("message = %q, want containing %q", result.Message, tt.msgContains)
+			}
+		})
+	}
+
+	// Frozen customer keeps their record but loses customer access
+	if _, err := database.GetCustomerByNpub(ctx, testCustomerNpub); err != nil {
+		t.Errorf("expected blocked customer to remain registered: %v", err)
+	}
+	isCustomer, _ := IsCustomer(ctx, database, testCustomerNpub)
+	if isCustomer {
+		t.Error("expected blocked customer to lose customer access")
+	}
+}
+
+func TestUnblockCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_ = database.BlockNpub(ctx, testCustomerNpub, testAdminNpub)
+
+	result := UnblockCmd(ctx, database, []string{testCustomerNpub})
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "Unblocked") {
+		t.Errorf("message = %q, want containing %q", result.Message, "Unblocked")
+	}
+
+	result = UnblockCmd(ctx, database, []string{testCustomerNpub})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "not blocked") {
+		t.Errorf("expected not blocked error, got %v", result.Error)
+	}
+
+	result = UnblockCmd(ctx, database, []string{})
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "usage") {
+		t.Errorf("expected usage error, got %v", result.Error)
+	}
+}
+
+func TestBlockedCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	result := BlockedCmd(ctx, database, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "No blocked npubs") {
+		t.Errorf("message = %q, want empty list", result.Message)
+	}
+
+	_ = database.BlockNpub(ctx, testCustomerNpub, testAdminNpub)
+
+	result = BlockedCmd(ctx, database, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "1 blocked npubs") || !strings.Contains(result.Message, testCustomerNpub) {
+		t.Errorf("message = %q, want blocked customer listed", result.Message)
+	}
+	if !strings.Contains(result.Message, "by "+testAdminNpub[:12]) {
+		t.Errorf("message = %q, want blocking admin listed", result.Message)
+	}
+}
+
+func TestSellCmd_RefusesBlockedCustomer(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 12)
+	_ = database.BlockNpub(ctx, testCustomerNpub, testAdminNpub)
+
+	result := SellCmd(ctx, database, []string{testCustomerNpub, "6"}, 3200, config.DefaultAllowedQuantities, 0)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "blocked") {
+		t.Errorf("expected blocked error, got %v", result.Error)
+	}
+}
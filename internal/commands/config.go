@@ -0,0 +1,87 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// BuildInfo identifies the running eggbot build.
+type BuildInfo struct {
+	Version string
+	Commit  string
+}
+
+// String returns the version with the short commit, e.g. "v1.2.0 (3f9c2ab)".
+func (b BuildInfo) String() string {
+	version := b.Version
+	if version == "" {
+		version = "dev"
+	}
+	commit := b.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	if commit == "" || commit == "none" {
+		return version
+	}
+	return fmt.Sprintf("%s (%s)", version, commit)
+}
+
+// ConfigCmd shows the running configuration that is safe to send in a DM:
+// the build, bot npub, relays, pricing, lightning addresses, admin count and
+// database path. No secret is part of cfg, so none can be shown.
+func ConfigCmd(ctx context.Context, database *db.DB, cfg ExecuteConfig) Result {
+	admins, err := database.ListAdmins(ctx)
+	if err != nil {
+		return Result{Error: fmt.Errorf("listing admins: %w", err)}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "eggbot %s\n", cfg.Build)
+	fmt.Fprintf(&b, "Bot: %s\n", cfg.BotNpub)
+
+	if cfg.Relays == nil {
+		b.WriteString("Relays: unknown\n")
+	} else {
+		relays := cfg.Relays()
+		connected := 0
+		for _, r := range relays {
+			if r.Connected {
+				connected++
+			}
+		}
+		fmt.Fprintf(&b, "Relays (%d/%d connected):\n", connected, len(relays))
+		for _, r := range relays {
+			state := "down"
+			if r.Connected {
+				state = "up"
+			}
+			if r.Quarantined {
+				state += ", quarantined"
+			}
+			if r.Fallback {
+				state += ", fallback"
+			}
+			fmt.Fprintf(&b, "• %s (%s)\n", r.URL, state)
+		}
+	}
+
+	sizes := make([]string, 0, len(cfg.allowedQuantities()))
+	for _, q := range cfg.allowedQuantities() {
+		sizes = append(sizes, fmt.Sprint(q))
+	}
+	fmt.Fprintf(&b, "Pricing: %d sats per 6 eggs; sizes %s\n", cfg.SatsPerHalfDozen, strings.Join(sizes, ", "))
+
+	if addresses := cfg.lightningAddresses(); len(addresses) > 0 {
+		fmt.Fprintf(&b, "Lightning: %s\n", strings.Join(addresses, ", "))
+	} else {
+		b.WriteString("Lightning: none, zaps only\n")
+	}
+
+	fmt.Fprintf(&b, "Admins: %d\n", len(admins))
+	fmt.Fprintf(&b, "Database: %s", cfg.DatabasePath)
+	return Result{Message: b.String()}
+}
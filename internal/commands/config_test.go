@@ -0,0 +1,78 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/nostr"
+)
+
+func TestBuildInfo_String(t *testing.T) {
+	tests := []struct {
+		build BuildInfo
+		want  string
+	}{
+		{BuildInfo{Version: "v1.2.0", Commit: "3f9c2ab8d1e4"}, "v1.2.0 (3f9c2ab)"},
+		{BuildInfo{Version: "v1.2.0", Commit: "3f9c2ab"}, "v1.2.0 (3f9c2ab)"},
+		{BuildInfo{Version: "dev", Commit: "none"}, "dev"},
+		{BuildInfo{}, "dev"},
+	}
+	for _, tt := range tests {
+		if got := tt.build.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.build, got, tt.want)
+		}
+	}
+}
+
+func TestConfigCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	if err := database.SeedAdmins(ctx, []string{testAdminNpub}); err != nil {
+		t.Fatalf("seeding admins: %v", err)
+	}
+
+	cfg := ExecuteConfig{
+		SatsPerHalfDozen:  3200,
+		AllowedQuantities: []int{6, 12},
+		LightningAddress:  "eggs@getalby.com",
+		FallbackAddresses: []string{"eggs@walletofsatoshi.com"},
+		BotNpub:           testCustomerNpub,
+		Build:             BuildInfo{Version: "v1.2.0", Commit: "3f9c2ab8d1e4"},
+		DatabasePath:      "/var/lib/eggbot/eggbot.db",
+		Relays: func() []nostr.RelayStatus {
+			return []nostr.RelayStatus{
+				{URL: "wss://relay-a.example.com", Connected: true},
+				{URL: "wss://relay-b.example.com", Quarantined: true},
+				{URL: "wss://fallback.example.com", Fallback: true, Connected: true},
+			}
+		},
+	}
+	result := ConfigCmd(ctx, database, cfg)
+	if result.Error != nil {
+		t.Fatalf("ConfigCmd: %v", result.Error)
+	}
+	for _, want := range []string{
+		"eggbot v1.2.0 (3f9c2ab)",
+		"Bot: " + testCustomerNpub,
+		"Relays (2/3 connected):",
+		"• wss://relay-a.example.com (up)",
+		"• wss://relay-b.example.com (down, quarantined)",
+		"• wss://fallback.example.com (up, fallback)",
+		"Pricing: 3200 sats per 6 eggs; sizes 6, 12",
+		"Lightning: eggs@getalby.com, eggs@walletofsatoshi.com",
+		"Admins: 1",
+		"Database: /var/lib/eggbot/eggbot.db",
+	} {
+		if !strings.Contains(result.Message, want) {
+			t.Errorf("message missing %q:\n%s", want, result.Message)
+		}
+	}
+
+	// Without relays or a lightning address
+	cfg.Relays, cfg.LightningAddress = nil, ""
+	result = ConfigCmd(ctx, database, cfg)
+	if !strings.Contains(result.Message, "Relays: unknown") || !strings.Contains(result.Message, "Lightning: none, zaps only") {
+		t.Errorf("unexpected message:\n%s", result.Message)
+	}
+}
@@ -0,0 +1,126 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+// Limits for messages customers send the admins.
+const (
+	maxContactLength     = 500       // Characters per message
+	maxContactsPerWindow = 3         // Messages per customer per contactWindow
+	contactWindow        = time.Hour // Window the rate limit applies over
+)
+
+// Limits for the contactlog command.
+const (
+	defaultContactLogLimit = 20
+	maxContactLogLimit     = 100
+)
+
+// ContactCmd relays a free-form message from a customer to the admins.
+// Args: the message, as any number of words
+func ContactCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, msgs *messages.Catalog) Result {
+	text := strings.TrimSpace(strings.Join(args, " "))
+	if text == "" {
+		return usageError(msgs, "contact <message>")
+	}
+	return relayToAdmins(ctx, database, senderNpub, text, msgs)
+}
+
+// ForwardUnrecognized relays a message that isn't a command to the admins,
+// with the same limits as contact. It reports false, relaying nothing, when
+// the sender isn't a registered customer; admins' typos aren't forwarded
+// either. The sender's role comes from roles.
+func ForwardUnrecognized(ctx context.Context, database *db.DB, roles *RoleCache, senderNpub, text string, msgs *messages.Catalog) (Result, bool) {
+	role, err := roles.Role(ctx, database, senderNpub)
+	if err != nil {
+		slog.Error("role check failed", "npub", senderNpub, "error", err)
+		return Result{}, false
+	}
+	if role != RoleCustomer {
+		return Result{}, false
+	}
+
+	result := relayToAdmins(ctx, database, senderNpub, strings.TrimSpace(text), msgs)
+	if result.Error == nil {
+		result.Message = msgs.Render(messages.UnrecognizedForwarded, nil)
+	}
+	return result, true
+}
+
+// relayToAdmins stores text from senderNpub and returns a Result whose
+// AdminNotice carries it to the admins. Overlong messages and senders over
+// the rate limit are refused.
+func relayToAdmins(ctx context.Context, database *db.DB, senderNpub, text string, msgs *messages.Catalog) Result {
+	if n := utf8.RuneCountInString(text); n > maxContactLength {
+		return Result{Error: errors.New(msgs.Render(messages.ContactTooLong, messages.ContactLimitData{Length: n, Limit: maxContactLength}))}
+	}
+
+	recent, err := database.CountRecentAdminMessages(ctx, senderNpub, contactWindow)
+	if err != nil {
+		return Result{Error: fmt.Errorf("checking recent messages: %w", err)}
+	}
+	if recent >= maxContactsPerWindow {
+		return Result{Error: errors.New(msgs.Render(messages.ContactRateLimited, messages.ContactLimitData{Limit: maxContactsPerWindow}))}
+	}
+
+	// Stored first, so the message isn't lost if relaying it fails
+	if err := database.AddAdminMessage(ctx, senderNpub, text); err != nil {
+		return Result{Error: fmt.Errorf("saving message: %w", err)}
+	}
+
+	from := senderNpub
+	if customer, err := database.GetCustomerByNpub(ctx, senderNpub); err == nil && customer.Name.Valid {
+		from = fmt.Sprintf("%s (%s)", customer.Name.String, senderNpub)
+	}
+	return Result{
+		Message:     msgs.Render(messages.ContactSent, nil),
+		AdminNotice: fmt.Sprintf("✉️ Message from %s:\n%s", from, text),
+	}
+}
+
+// ContactLogCmd lists the most recent messages customers sent the admins
+// (admin only).
+// Args: optional number of messages
+func ContactLogCmd(ctx context.Context, database *db.DB, args []string, loc *time.Location) Result {
+	limit := defaultContactLogLimit
+	if len(args) > 1 {
+		return Result{Error: fmt.Errorf("usage: contactlog [n], where n is at most %d", maxContactLogLimit)}
+	}
+	if len(args) == 1 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > maxContactLogLimit {
+			return Result{Error: fmt.Errorf("usage: contactlog [n], where n is at most %d", maxContactLogLimit)}
+		}
+		limit = n
+	}
+
+	entries, err := database.ListAdminMessages(ctx, limit)
+	if err != nil {
+		return Result{Error: fmt.Errorf("listing messages: %w", err)}
+	}
+	if len(entries) == 0 {
+		return Result{Message: "No messages from customers yet."}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d most recent messages:", len(entries))
+	for _, m := range entries {
+		from := m.Npub
+		if m.Name.Valid {
+			from = fmt.Sprintf("%s (%s)", m.Name.String, m.Npub)
+		}
+		fmt.Fprintf(&b, "\n• %s - %s:\n  %s", FormatTime(m.CreatedAt, loc), from, m.Message)
+	}
+	return Result{Message: b.String()}
+}
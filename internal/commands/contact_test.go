@@ -0,0 +1,114 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContactCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "Jane")
+	_, _ = database.CreateCustomer(ctx, customerNpub, "")
+
+	if result := ContactCmd(ctx, database, testCustomerNpub, nil, nil); result.Error == nil || !strings.Contains(result.Error.Error(), "usage: contact") {
+		t.Errorf("no message = %v, want usage", result.Error)
+	}
+
+	result := ContactCmd(ctx, database, testCustomerNpub, []string{"I'll", "be", "late", "Saturday"}, nil)
+	if result.Error != nil || result.Message != "Your message was passed on to the farmer." {
+		t.Fatalf("contact = %q, %v", result.Message, result.Error)
+	}
+	if want := "✉️ Message from Jane (" + testCustomerNpub + "):\nI'll be late Saturday"; result.AdminNotice != want {
+		t.Errorf("admin notice = %q, want %q", result.AdminNotice, want)
+	}
+	if result := ContactCmd(ctx, database, customerNpub, []string{"Hi"}, nil); result.AdminNotice != "✉️ Message from "+customerNpub+":\nHi" {
+		t.Errorf("admin notice without a name = %q", result.AdminNotice)
+	}
+
+	// Every message is kept for contactlog
+	entries, _ := database.ListAdminMessages(ctx, 10)
+	if len(entries) != 2 || entries[1].Message != "I'll be late Saturday" {
+		t.Errorf("stored messages = %+v", entries)
+	}
+
+	// Too long, then over the rate limit
+	if result := ContactCmd(ctx, database, testCustomerNpub, []string{strings.Repeat("a", maxContactLength+1)}, nil); result.Error == nil ||
+		!strings.Contains(result.Error.Error(), "the limit is 500") {
+		t.Errorf("long message = %v", result.Error)
+	}
+	for i := 1; i < maxContactsPerWindow; i++ {
+		if result := ContactCmd(ctx, database, testCustomerNpub, []string{"again"}, nil); result.Error != nil {
+			t.Fatalf("message %d: %v", i+1, result.Error)
+		}
+	}
+	result = ContactCmd(ctx, database, testCustomerNpub, []string{"again"}, nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "3 messages an hour") || result.AdminNotice != "" {
+		t.Errorf("over the limit = %q, %v", result.AdminNotice, result.Error)
+	}
+	if entries, _ := database.ListAdminMessages(ctx, 10); len(entries) != 1+maxContactsPerWindow {
+		t.Errorf("stored %d messages, want refused ones left out", len(entries))
+	}
+}
+
+func TestForwardUnrecognized(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "Jane")
+	_ = database.AddAdmin(ctx, testAdminNpub, "")
+
+	result, ok := ForwardUnrecognized(ctx, database, nil, testCustomerNpub, "do you have duck eggs? ", nil)
+	if !ok || result.Error != nil || result.Message != "I didn't understand that — forwarding to the farmer." {
+		t.Fatalf("forward = %q, %v, %v", result.Message, ok, result.Error)
+	}
+	if want := "✉️ Message from Jane (" + testCustomerNpub + "):\ndo you have duck eggs?"; result.AdminNotice != want {
+		t.Errorf("admin notice = %q, want %q", result.AdminNotice, want)
+	}
+
+	// Forwarded messages count against the contact limit
+	for i := 1; i < maxContactsPerWindow; i++ {
+		_, _ = ForwardUnrecognized(ctx, database, nil, testCustomerNpub, "again", nil)
+	}
+	if result, ok := ForwardUnrecognized(ctx, database, nil, testCustomerNpub, "again", nil); !ok || result.Error == nil || result.AdminNotice != "" {
+		t.Errorf("over the limit = %q, %v", result.AdminNotice, result.Error)
+	}
+
+	// Strangers and admins aren't forwarded
+	for _, npub := range []string{testUnknownNpub, testAdminNpub} {
+		if result, ok := ForwardUnrecognized(ctx, database, nil, npub, "hello", nil); ok || result.AdminNotice != "" {
+			t.Errorf("%s was forwarded", npub)
+		}
+	}
+	if entries, _ := database.ListAdminMessages(ctx, 10); len(entries) != maxContactsPerWindow {
+		t.Errorf("stored %d messages, want %d", len(entries), maxContactsPerWindow)
+	}
+}
+
+func TestContactLogCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "Jane")
+
+	if result := ContactLogCmd(ctx, database, nil, nil); result.Message != "No messages from customers yet." {
+		t.Errorf("empty log = %q, %v", result.Message, result.Error)
+	}
+
+	_ = database.AddAdminMessage(ctx, testCustomerNpub, "Late Saturday")
+	_ = database.AddAdminMessage(ctx, customerNpub, "Do you sell duck eggs?")
+
+	result := ContactLogCmd(ctx, database, nil, nil)
+	if result.Error != nil || !strings.HasPrefix(result.Message, "2 most recent messages:") ||
+		!strings.Contains(result.Message, "Jane ("+testCustomerNpub+"):\n  Late Saturday") ||
+		!strings.Contains(result.Message, customerNpub+":\n  Do you sell duck eggs?") {
+		t.Errorf("log = %q, %v", result.Message, result.Error)
+	}
+	if result := ContactLogCmd(ctx, database, []string{"1"}, nil); !strings.HasPrefix(result.Message, "1 most recent messages:") {
+		t.Errorf("log 1 = %q", result.Message)
+	}
+	for _, args := range [][]string{{"0"}, {"101"}, {"x"}, {"1", "2"}} {
+		if result := ContactLogCmd(ctx, database, args, nil); result.Error == nil {
+			t.Errorf("contactlog %v: expected a usage error", args)
+		}
+	}
+}
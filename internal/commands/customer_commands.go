@@ -4,28 +4,36 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/buildtall-systems/eggbot/internal/db"
 	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/buildtall-systems/eggbot/internal/messages"
 )
 
 // Result holds the response from a command execution.
 type Result struct {
-	Message string
-	Error   error
+	Message              string
+	Error                error
+	Subject              string    // Conversation title for NIP-17 replies; empty for none
+	TriggerNotifications bool      // The command may have made eggs available, so check inventory notifications
+	LowInventoryWarning  bool      // The command's order took inventory below the low-water mark
+	EggsRemaining        int       // Inventory left after the command's order; set with LowInventoryWarning
+	AdminNotice          string    // Sent to every admin after the reply; empty for none
+	Order                *db.Order // The order the command placed, for the admin notification; nil for none
 }
 
 // InventoryCmd handles inventory commands.
 // No args: show inventory (all users)
 // add <n>: add eggs (admin only)
 // set <n>: set inventory (admin only)
-func InventoryCmd(ctx context.Context, database *db.DB, args []string, isAdmin bool) Result {
+func InventoryCmd(ctx context.Context, database *db.DB, args []string, isAdmin bool, msgs *messages.Catalog) Result {
 	// No subcommand: show inventory
 	if len(args) == 0 {
-		return showInventory(ctx, database, isAdmin)
+		return showInventory(ctx, database, isAdmin, msgs)
 	}
 
 	subcommand := args[0]
@@ -33,13 +41,13 @@ func InventoryCmd(ctx context.Context, database *db.DB, args []string, isAdmin b
 	switch subcommand {
 	case "add":
 		if !isAdmin {
-			return Result{Error: errors.New("admin access required")}
+			return Result{Error: errors.New(msgs.Render(messages.AdminAccessRequired, nil))}
 		}
 		return inventoryAdd(ctx, database, args[1:])
 
 	case "set":
 		if !isAdmin {
-			return Result{Error: errors.New("admin access required")}
+			return Result{Error: errors.New(msgs.Render(messages.AdminAccessRequired, nil))}
 		}
 		return inventorySet(ctx, database, args[1:])
 
@@ -48,13 +56,13 @@ func InventoryCmd(ctx context.Context, database *db.DB, args []string, isAdmin b
 		if isAdmin {
 			return Result{Error: fmt.Errorf("unknown subcommand: %s (use add or set)", subcommand)}
 		}
-		return showInventory(ctx, database, false)
+		return showInventory(ctx, database, false, msgs)
 	}
 }
 
 // showInventory returns the current egg count.
 // For admins, shows a breakdown of available, reserved (pending), and sold (paid) eggs.
-func showInventory(ctx context.Context, database *db.DB, isAdmin bool) Result {
+func showInventory(ctx context.Context, database *db.DB, isAdmin bool, msgs *messages.Catalog) Result {
 	available, err := database.GetInventory(ctx)
 	if err != nil {
 		return Result{Error: fmt.Errorf("checking inventory: %w", err)}
@@ -62,13 +70,7 @@ func showInventory(ctx context.Context, database *db.DB, isAdmin bool) Result {
 
 	if !isAdmin {
 		// Customer view: simple count
-		if available == 0 {
-			return Result{Message: "No eggs available. Check back later!"}
-		}
-		if available == 1 {
-			return Result{Message: "1 egg available."}
-		}
-		return Result{Message: fmt.Sprintf("%d eggs available.", available)}
+		return Result{Message: msgs.Render(messages.InventoryAvailable, messages.InventoryData{Available: available})}
 	}
 
 	// Admin view: full breakdown
@@ -109,10 +111,10 @@ func inventoryAdd(ctx context.Context, database *db.DB, args []string) Result {
 
 	total, err := database.GetInventory(ctx)
 	if err != nil {
-		return Result{Message: fmt.Sprintf("Added %d eggs.", quantity)}
+		return Result{Message: fmt.Sprintf("Added %d eggs.", quantity), TriggerNotifications: true}
 	}
 
-	return Result{Message: fmt.Sprintf("Added %d eggs. Total: %d", quantity, total)}
+	return Result{Message: fmt.Sprintf("Added %d eggs. Total: %d", quantity, total), TriggerNotifications: true}
 }
 
 // inventorySet sets inventory to an exact count.
@@ -130,24 +132,58 @@ func inventorySet(ctx context.Context, database *db.DB, args []string) Result {
 		return Result{Error: fmt.Errorf("setting inventory: %w", err)}
 	}
 
-	return Result{Message: fmt.Sprintf("Inventory set to %d eggs.", quantity)}
+	return Result{Message: fmt.Sprintf("Inventory set to %d eggs.", quantity), TriggerNotifications: true}
+}
+
+// parseQuantity parses an order quantity and checks it is one of the allowed sizes.
+func parseQuantity(arg string, allowed []int) (int, error) {
+	quantity, err := strconv.Atoi(arg)
+	if err != nil || !slices.Contains(allowed, quantity) {
+		return 0, fmt.Errorf("quantity must be %s", formatQuantities(allowed))
+	}
+	return quantity, nil
+}
+
+// shortNpub truncates an npub for display, e.g. "npub1abcdefg...wxyz".
+func shortNpub(npub string) string {
+	if len(npub) > 20 {
+		return npub[:12] + "..." + npub[len(npub)-4:]
+	}
+	return npub
+}
+
+// formatQuantities lists the allowed sizes for messages, e.g. "1, 5 or 10".
+func formatQuantities(allowed []int) string {
+	parts := make([]string, len(allowed))
+	for i, q := range allowed {
+		parts[i] = strconv.Itoa(q)
+	}
+	if len(parts) < 2 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " or " + parts[len(parts)-1]
+}
+
+// orderPrice returns the price in sats for quantity eggs. Prices are set per
+// half-dozen, so other sizes are prorated and rounded down.
+func orderPrice(quantity, satsPerHalfDozen int) int64 {
+	return int64(quantity) * int64(satsPerHalfDozen) / 6
 }
 
 // OrderCmd creates a new order for eggs and reserves inventory atomically.
-// Args: [quantity] - must be 6 or 12 (half-dozen or dozen)
-func OrderCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, satsPerHalfDozen int, lightningAddress, botNpub string, lnClient *lightning.Client) Result {
+// Args: [quantity] - must be one of allowedQuantities
+// pickupInstructions, if non-empty, is appended to the confirmation. With
+// waitlist set, an order there aren't enough eggs for offers the waitlist.
+// An order that leaves fewer than lowInventoryThreshold eggs sets
+// LowInventoryWarning.
+func OrderCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, satsPerHalfDozen int, allowedQuantities []int, lightningAddresses []string, botNpub string, lnClient *lightning.Client, pickupInstructions string, waitlist bool, lowInventoryThreshold int, msgs *messages.Catalog) Result {
 	if len(args) < 1 {
-		return Result{Error: errors.New("usage: order <quantity> (6 or 12)")}
+		return usageError(msgs, fmt.Sprintf("order <quantity> (%s)", formatQuantities(allowedQuantities)))
 	}
 
-	quantity, err := strconv.Atoi(args[0])
+	quantity, err := parseQuantity(args[0], allowedQuantities)
 	if err != nil {
-		return Result{Error: errors.New("quantity must be 6 or 12")}
-	}
-
-	// Only allow multiples of 6, max 12
-	if quantity != 6 && quantity != 12 {
-		return Result{Error: errors.New("quantity must be 6 or 12")}
+		return Result{Error: err}
 	}
 
 	// Get customer by npub
@@ -162,60 +198,86 @@ func OrderCmd(ctx context.Context, database *db.DB, senderNpub string, args []st
 		return Result{Error: fmt.Errorf("checking pending orders: %w", err)}
 	}
 	if len(pending) > 0 {
-		return Result{Error: fmt.Errorf("you have %d unpaid order(s) - please pay or cancel before ordering more", len(pending))}
+		return Result{Error: errors.New(msgs.Render(messages.UnpaidOrderPending, messages.PendingOrdersData{Count: len(pending)}))}
 	}
 
-	// Calculate price
-	halfDozens := quantity / 6
-	totalSats := int64(halfDozens * satsPerHalfDozen)
+	totalSats := orderPrice(quantity, satsPerHalfDozen)
 
 	// Create order (reserves inventory atomically)
-	order, err := database.CreateOrder(ctx, customer.ID, quantity, totalSats)
+	order, deduction, err := database.CreateOrderWithCheck(ctx, customer.ID, quantity, totalSats, lowInventoryThreshold)
 	if err != nil {
 		if errors.Is(err, db.ErrInsufficientInventory) {
 			// Get current inventory for helpful error message
 			available, _ := database.GetInventory(ctx)
-			return Result{Error: fmt.Errorf("only %d eggs available, cannot order %d", available, quantity)}
+			msg := msgs.Render(messages.InsufficientInventory, messages.InsufficientInventoryData{Available: available, Requested: quantity})
+			if waitlist {
+				msg = appendWaitlistCTA(msg, quantity, available, msgs)
+			}
+			return Result{Error: errors.New(msg)}
 		}
 		return Result{Error: fmt.Errorf("creating order: %w", err)}
 	}
 
-	msg := fmt.Sprintf("Order %d: %d eggs reserved for %d sats.", order.ID, quantity, totalSats)
+	data := messages.OrderCreatedData{OrderID: order.ID, Quantity: quantity, TotalSats: totalSats, BotNpub: botNpub}
 
 	// Generate bolt11 invoice for clickable payment in Amethyst
-	var hasInvoice bool
-	if lnClient != nil && lightningAddress != "" {
-		invoice, err := lnClient.RequestInvoice(ctx, lightningAddress, totalSats)
+	if lnClient != nil && len(lightningAddresses) > 0 {
+		comment := fmt.Sprintf("Order #%d", order.ID)
+		invoice, err := lnClient.RequestInvoiceWithFallback(ctx, lightningAddresses, totalSats, comment)
 		if err != nil {
-			log.Printf("invoice generation failed: %v", err)
+			slog.Warn("invoice generation failed", "order_id", order.ID, "error", err)
 		} else {
-			msg += fmt.Sprintf("\n\nPay invoice:\n%s", invoice)
-			hasInvoice = true
+			data.Invoice = invoice
+			recordInvoice(ctx, database, order.ID, invoice, totalSats)
 		}
 	}
 
-	// Include zap instructions
-	if botNpub != "" {
-		if hasInvoice {
-			msg += fmt.Sprintf("\n\nOr zap this profile:\nnostr:%s", botNpub)
-		} else {
-			msg += fmt.Sprintf("\n\nZap this profile to pay:\nnostr:%s", botNpub)
-		}
+	// The message includes zap instructions when botNpub is set
+	msg := msgs.Render(messages.OrderCreated, data)
+	return Result{
+		Message:             appendPickupInstructions(msg, pickupInstructions),
+		Subject:             msgs.Render(messages.OrderSubject, messages.OrderData{OrderID: order.ID}),
+		LowInventoryWarning: deduction.LowInventoryWarning,
+		EggsRemaining:       deduction.Remaining,
+		Order:               order,
 	}
+}
 
-	return Result{Message: msg}
+// appendWaitlistCTA adds the offer to join the waitlist for quantity eggs
+// below msg. The wording depends on whether any eggs are available now.
+func appendWaitlistCTA(msg string, quantity, available int, msgs *messages.Catalog) string {
+	return msg + "\n\n" + msgs.Render(messages.WaitlistOffer, messages.WaitlistData{Quantity: quantity, Available: available})
+}
+
+// usageError returns the reply to a customer command used wrongly, e.g.
+// "usage: cancel <order_id>" in msgs' language.
+func usageError(msgs *messages.Catalog, usage string) Result {
+	return Result{Error: errors.New(msgs.Render(messages.Usage, messages.UsageData{Usage: usage}))}
+}
+
+// recordInvoice stores a generated invoice against its order. Failures are only
+// logged - the customer already has a payable invoice.
+func recordInvoice(ctx context.Context, database *db.DB, orderID int64, invoice string, amountSats int64) {
+	decoded, err := lightning.DecodeBolt11(invoice)
+	if err != nil {
+		slog.Warn("not recording invoice", "order_id", orderID, "error", err)
+		return
+	}
+	if _, err := database.RecordInvoice(ctx, orderID, invoice, amountSats, decoded.ExpiresAt()); err != nil {
+		slog.Error("recording invoice failed", "order_id", orderID, "error", err)
+	}
 }
 
 // CancelOrderCmd cancels a pending order.
 // Args: [order_id]
-func CancelOrderCmd(ctx context.Context, database *db.DB, senderNpub string, args []string) Result {
+func CancelOrderCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, msgs *messages.Catalog) Result {
 	if len(args) < 1 {
-		return Result{Error: errors.New("usage: cancel <order_id>")}
+		return usageError(msgs, "cancel <order_id>")
 	}
 
 	orderID, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		return Result{Error: errors.New("order_id must be a number")}
+		return Result{Error: errors.New(msgs.Render(messages.InvalidOrderID, nil))}
 	}
 
 	// Get customer to verify ownership
@@ -228,112 +290,103 @@ func CancelOrderCmd(ctx context.Context, database *db.DB, senderNpub string, arg
 	order, err := database.GetOrderByID(ctx, orderID)
 	if err != nil {
 		if errors.Is(err, db.ErrOrderNotFound) {
-			return Result{Error: fmt.Errorf("order %d not found", orderID)}
+			return Result{Error: errors.New(msgs.Render(messages.OrderNotFound, messages.OrderData{OrderID: orderID}))}
 		}
 		return Result{Error: fmt.Errorf("looking up order: %w", err)}
 	}
 
 	// Verify caller owns this order
 	if order.CustomerID != customer.ID {
-		return Result{Error: errors.New("you can only cancel your own orders")}
+		return Result{Error: errors.New(msgs.Render(messages.NotYourOrder, nil))}
 	}
 
 	// Cancel the order
 	err = database.CancelOrder(ctx, orderID)
 	if err != nil {
 		if errors.Is(err, db.ErrOrderNotPending) {
-			return Result{Error: fmt.Errorf("order %d cannot be cancelled (status: %s)", orderID, order.Status)}
+			return Result{Error: errors.New(msgs.Render(messages.CannotCancel, messages.OrderStatusData{OrderID: orderID, Status: order.Status}))}
 		}
 		return Result{Error: fmt.Errorf("cancelling order: %w", err)}
 	}
 
-	return Result{Message: fmt.Sprintf("Order %d cancelled.", orderID)}
+	// The reserved eggs are back in stock
+	return Result{Message: msgs.Render(messages.OrderCancelled, messages.OrderData{OrderID: orderID}), TriggerNotifications: true}
 }
 
-// BalanceCmd returns the customer's balance (received payments minus spent on fulfilled orders).
-func BalanceCmd(ctx context.Context, database *db.DB, senderNpub string) Result {
-	customer, err := database.GetCustomerByNpub(ctx, senderNpub)
+// BalanceCmd returns the customer's balance (received payments minus spent on
+// fulfilled orders), and what pending orders will cost.
+func BalanceCmd(ctx context.Context, database *db.DB, senderNpub string, msgs *messages.Catalog) Result {
+	detail, err := database.GetCustomerBalanceDetail(ctx, senderNpub)
 	if err != nil {
-		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
+		return Result{Error: fmt.Errorf("getting balance: %w", err)}
+	}
+	return Result{Message: msgs.Render(messages.Balance, messages.BalanceData{
+		Received:        detail.Received,
+		Spent:           detail.Spent,
+		Balance:         detail.Balance,
+		PendingReserved: detail.PendingReserved,
+	})}
+}
+
+// historyPageSize is how many orders one page of "history" shows.
+const historyPageSize = 25
+
+// HistoryCmd returns a page of the customer's order history, most recent first,
+// with when each order was placed.
+// Args: optional page number, starting at 1
+func HistoryCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, td TimeDisplay, msgs *messages.Catalog) Result {
+	page := 1
+	if len(args) > 0 {
+		n, ok := parsePage(args[0])
+		if !ok {
+			return usageError(msgs, "history [page]")
+		}
+		page = n
 	}
 
-	received, err := database.GetCustomerBalance(ctx, senderNpub)
+	customer, err := database.GetCustomerByNpub(ctx, senderNpub)
 	if err != nil {
-		return Result{Error: fmt.Errorf("getting received: %w", err)}
+		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
 	}
 
-	spent, err := database.GetCustomerSpent(ctx, customer.ID)
+	total, err := database.CountOrdersWithFilters(ctx, db.OrderFilter{CustomerID: &customer.ID})
 	if err != nil {
-		return Result{Error: fmt.Errorf("getting spent: %w", err)}
+		return Result{Error: fmt.Errorf("counting orders: %w", err)}
 	}
-
-	balance := received - spent
-
-	if balance == 0 && received == 0 {
-		return Result{Message: "No payments received yet."}
+	if total == 0 {
+		return Result{Message: msgs.Render(messages.HistoryEmpty, nil)}
 	}
-
-	return Result{Message: fmt.Sprintf("Received: %d sats | Spent: %d sats | Balance: %d sats", received, spent, balance)}
-}
-
-// HistoryCmd returns the customer's recent order history.
-func HistoryCmd(ctx context.Context, database *db.DB, senderNpub string) Result {
-	customer, err := database.GetCustomerByNpub(ctx, senderNpub)
-	if err != nil {
-		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
+	pages := pageCount(total, historyPageSize)
+	if page > pages {
+		return Result{Message: msgs.Render(messages.PageOutOfRange, messages.PageData{Page: page, Pages: pages, Total: total})}
 	}
 
-	orders, err := database.GetCustomerOrders(ctx, customer.ID, 25)
+	orders, err := database.GetCustomerOrdersByOffset(ctx, customer.ID, historyPageSize, (page-1)*historyPageSize)
 	if err != nil {
 		return Result{Error: fmt.Errorf("getting orders: %w", err)}
 	}
 
-	if len(orders) == 0 {
-		return Result{Message: "No orders yet."}
+	data := messages.HistoryData{Page: page, Pages: pages, Total: total}
+	if page < pages {
+		data.NextPage = page + 1
 	}
-
-	msg := "Recent orders:\n"
 	for _, o := range orders {
-		msg += fmt.Sprintf("• #%d: %d eggs, %d sats (%s)\n", o.ID, o.Quantity, o.TotalSats, o.Status)
+		data.Lines = append(data.Lines, historyLine(o, td, msgs))
 	}
-	return Result{Message: msg}
+	return Result{Message: msgs.Render(messages.OrderHistory, data)}
 }
 
-// HelpCmd returns available commands for the user.
-func HelpCmd(isAdmin bool) Result {
-	msg := `Available commands:
-• inventory - Check egg availability
-• order <6|12> - Order eggs (half-dozen or dozen)
-• cancel <order_id> - Cancel a pending order
-• balance - Check your payment balance
-• history - View recent orders
-• notify <6|12> - Get notified when inventory reaches quantity
-• notify off - Cancel notification
-• help - Show this message`
-
-	if isAdmin {
-		msg += `
-
-Admin commands:
-• inventory add <qty> - Add eggs to inventory
-• inventory set <qty> - Set inventory to exact count
-• sell <npub> <qty> - Create order for a customer
-• markpaid <order_id> - Mark pending order as paid
-• deliver <order_id> - Fulfill a paid order
-• adjust <npub> <sats> - Adjust customer balance
-• orders - List all orders
-• customers - List registered customers
-• addcustomer <npub> - Register new customer
-• removecustomer <npub> - Remove customer
-• sales - Show total sales`
-	}
-
-	return Result{Message: msg}
+// historyLine renders one order for HistoryCmd.
+func historyLine(o db.Order, td TimeDisplay, msgs *messages.Catalog) string {
+	data := messages.HistoryOrderData{OrderID: o.ID, Quantity: o.Quantity, TotalSats: o.TotalSats, Status: o.Status}
+	data.Placed, data.Age, data.ExpiresIn, data.Expiring = td.orderTimes(o.CreatedAt, o.Status)
+	return msgs.Render(messages.HistoryOrder, data)
 }
 
-// NotifyCmd manages inventory notification subscriptions.
-// Args: <6|12> to subscribe, "off" to unsubscribe
-func NotifyCmd(ctx context.Context, database *db.DB, senderNpub string, args []string) Result {
+// NotifyCmd manages inventory notification subscriptions. name is the command
+// it was called as, notify or waitlist, for the usage text.
+// Args: one of allowedQuantities to subscribe, "off" to unsubscribe
+func NotifyCmd(ctx context.Context, database *db.DB, senderNpub, name string, args []string, allowedQuantities []int, msgs *messages.Catalog) Result {
 	customer, err := database.GetCustomerByNpub(ctx, senderNpub)
 	if err != nil {
 		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
@@ -345,9 +398,9 @@ func NotifyCmd(ctx context.Context, database *db.DB, senderNpub string, args []s
 			return Result{Error: fmt.Errorf("checking notification: %w", err)}
 		}
 		if existing != nil {
-			return Result{Message: fmt.Sprintf("You will be notified when %d eggs are available.\nUse 'notify off' to cancel.", existing.ThresholdEggs)}
+			return Result{Message: msgs.Render(messages.NotifyStatus, messages.NotifyData{Threshold: existing.ThresholdEggs})}
 		}
-		return Result{Error: errors.New("usage: notify <6|12> or notify off")}
+		return usageError(msgs, fmt.Sprintf("%s <quantity> (%s) or %s off", name, formatQuantities(allowedQuantities), name))
 	}
 
 	arg := strings.ToLower(args[0])
@@ -356,17 +409,17 @@ func NotifyCmd(ctx context.Context, database *db.DB, senderNpub string, args []s
 		if err := database.DeleteInventoryNotification(ctx, customer.ID); err != nil {
 			return Result{Error: fmt.Errorf("removing notification: %w", err)}
 		}
-		return Result{Message: "Notification cancelled."}
+		return Result{Message: msgs.Render(messages.NotifyCancelled, nil)}
 	}
 
-	qty, err := strconv.Atoi(arg)
-	if err != nil || (qty != 6 && qty != 12) {
-		return Result{Error: errors.New("quantity must be 6 or 12")}
+	qty, err := parseQuantity(arg, allowedQuantities)
+	if err != nil {
+		return Result{Error: err}
 	}
 
 	if err := database.UpsertInventoryNotification(ctx, customer.ID, qty); err != nil {
 		return Result{Error: fmt.Errorf("setting notification: %w", err)}
 	}
 
-	return Result{Message: fmt.Sprintf("You will be notified when %d eggs are available.", qty)}
+	return Result{Message: msgs.Render(messages.NotifySubscribed, messages.NotifyData{Threshold: qty})}
 }
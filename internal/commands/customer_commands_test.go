@@ -3,11 +3,19 @@ package commands
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/buildtall-systems/eggbot/internal/config"
 	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/nbd-wtf/go-nostr/nip19"
 	_ "modernc.org/sqlite"
 )
 
@@ -42,9 +50,40 @@ const (
 	// Admin keypair
 	testAdminSecretHex = "044d5d4b5961612682ce0749a9ad7f8527b42d95ab9b8cf7a2d7dd6175d8639d"
 	testAdminPubkeyHex = "f28af81d4e2150fdf2366d373a125b22014397460aed537b370a58d116d5a158"
-	testAdminNpub = "npub17290s82wy9g0mu3kd5mn5yjmygq5896xptk4x7ehpfvdz9k459vqywh6q7"
+	testAdminNpub      = "npub17290s82wy9g0mu3kd5mn5yjmygq5896xptk4x7ehpfvdz9k459vqywh6q7"
+
+	// Unknown keypair, never registered as a customer or admin
+	testUnknownSecretHex = "1d4f2494f1e1f7ce279f73c9d57783f27dab9d857757b2ca81e89d826aab5238"
+	testUnknownPubkeyHex = "3171721e7640ec2c397347f491965694ddd14a4f6350af7dbd38dbeb3d15da15"
+	testUnknownNpub      = "npub1x9chy8nkgrkzcwtngl6fr9jkjnwazjj0vdg27lda8rd7k0g4mg2sgt7jre"
 )
 
+func TestNpubConstants(t *testing.T) {
+	tests := []struct {
+		name      string
+		npub      string
+		pubkeyHex string
+	}{
+		{"customer", testCustomerNpub, testCustomerPubkeyHex},
+		{"admin", testAdminNpub, testAdminPubkeyHex},
+		{"unknown", testUnknownNpub, testUnknownPubkeyHex},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefix, value, err := nip19.Decode(tt.npub)
+			if err != nil {
+				t.Fatalf("decoding %s: %v", tt.npub, err)
+			}
+			if prefix != "npub" {
+				t.Errorf("prefix = %q, want npub", prefix)
+			}
+			if value != tt.pubkeyHex {
+				t.Errorf("%s decodes to %v, want %s", tt.npub, value, tt.pubkeyHex)
+			}
+		})
+	}
+}
+
 func TestInventoryCmd_Show(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
@@ -79,7 +118,7 @@ func TestInventoryCmd_Show(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			tt.setup()
 			// Test without args (show inventory) - works for both admin and non-admin
-			result := InventoryCmd(ctx, database, []string{}, false)
+			result := InventoryCmd(ctx, database, []string{}, false, nil)
 			if result.Error != nil {
 				t.Fatalf("unexpected error: %v", result.Error)
 			}
@@ -141,7 +180,7 @@ func TestInventoryCmd_Add(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := InventoryCmd(ctx, database, tt.args, tt.isAdmin)
+			result := InventoryCmd(ctx, database, tt.args, tt.isAdmin, nil)
 			if tt.wantErr {
 				if result.Error == nil {
 					t.Fatal("expected error")
@@ -222,7 +261,7 @@ func TestInventoryCmd_Set(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := InventoryCmd(ctx, database, tt.args, tt.isAdmin)
+			result := InventoryCmd(ctx, database, tt.args, tt.isAdmin, nil)
 			if tt.wantErr {
 				if result.Error == nil {
 					t.Fatal("expected error")
@@ -254,7 +293,7 @@ func TestInventoryCmd_UnknownSubcommand(t *testing.T) {
 	_ = database.AddEggs(ctx, 10)
 
 	// Non-admin with unknown subcommand gets inventory shown
-	result := InventoryCmd(ctx, database, []string{"foobar"}, false)
+	result := InventoryCmd(ctx, database, []string{"foobar"}, false, nil)
 	if result.Error != nil {
 		t.Fatalf("expected no error for non-admin, got %v", result.Error)
 	}
@@ -263,7 +302,7 @@ func TestInventoryCmd_UnknownSubcommand(t *testing.T) {
 	}
 
 	// Admin with unknown subcommand gets error
-	result = InventoryCmd(ctx, database, []string{"foobar"}, true)
+	result = InventoryCmd(ctx, database, []string{"foobar"}, true, nil)
 	if result.Error == nil {
 		t.Fatal("expected error for admin with unknown subcommand")
 	}
@@ -277,7 +316,7 @@ func TestInventoryCmd_AdminView(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Setup: create customer and inventory
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 30)
 
 	// Create orders in different states to test breakdown
@@ -291,7 +330,7 @@ func TestInventoryCmd_AdminView(t *testing.T) {
 	// After orders: available = 30 - 6 - 12 = 12 eggs
 
 	// Test customer view - should only show available
-	result := InventoryCmd(ctx, database, []string{}, false)
+	result := InventoryCmd(ctx, database, []string{}, false, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -304,7 +343,7 @@ func TestInventoryCmd_AdminView(t *testing.T) {
 	}
 
 	// Test admin view - should show full breakdown
-	result = InventoryCmd(ctx, database, []string{}, true)
+	result = InventoryCmd(ctx, database, []string{}, true, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -344,7 +383,7 @@ func TestOrderCmd(t *testing.T) {
 
 	// Setup: add inventory and customer using properly generated keypair
 	_ = database.AddEggs(ctx, 50)
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	tests := []struct {
 		name        string
@@ -407,7 +446,7 @@ func TestOrderCmd(t *testing.T) {
 				_ = database.CancelOrder(ctx, o.ID)
 			}
 
-			result := OrderCmd(ctx, database, testCustomerNpub, tt.args, 3200, "", "", nil)
+			result := OrderCmd(ctx, database, testCustomerNpub, tt.args, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 			if tt.wantErr {
 				if result.Error == nil {
 					t.Fatal("expected error, got nil")
@@ -432,9 +471,9 @@ func TestOrderCmd_ValidDozen(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	_ = database.AddEggs(ctx, 20)
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
-	result := OrderCmd(ctx, database, testCustomerNpub, []string{"12"}, 3200, "", "", nil)
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"12"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -446,21 +485,129 @@ func TestOrderCmd_ValidDozen(t *testing.T) {
 	}
 }
 
+func TestOrderCmd_CustomQuantities(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_ = database.AddEggs(ctx, 50)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	quail := []int{1, 5, 10}
+
+	for _, qty := range []string{"6", "12", "3"} {
+		result := OrderCmd(ctx, database, testCustomerNpub, []string{qty}, 3000, quail, nil, "", nil, "", false, 0, nil)
+		if result.Error == nil || !strings.Contains(result.Error.Error(), "1, 5 or 10") {
+			t.Errorf("order %s: expected quantity error listing 1, 5 or 10, got %v", qty, result.Error)
+		}
+	}
+
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"5"}, 3000, quail, nil, "", nil, "", false, 0, nil)
+	if result.Error != nil {
+		t.Fatalf("order 5: unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, "5 eggs reserved for 2500 sats") {
+		t.Errorf("expected 5 eggs for 2500 sats (prorated), got %q", result.Message)
+	}
+}
+
+func TestOrderCmd_PickupInstructions(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_ = database.AddEggs(ctx, 20)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	pickup := "Pickup: blue cooler at the end of the driveway, Sat 9-12"
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, testAdminNpub, nil, pickup, false, 0, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.HasSuffix(result.Message, "\n\n"+pickup) {
+		t.Errorf("expected message to end with pickup instructions, got %q", result.Message)
+	}
+}
+
+func TestOrderCmd_RecordsInvoice(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	_ = database.AddEggs(ctx, 20)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	// 3200 sat invoice with a zero payment hash and zeroed signature
+	createdAt := time.Now().Unix()
+	words := make([]byte, 0, 7+3+52+104)
+	for i := 6; i >= 0; i-- {
+		words = append(words, byte(createdAt>>(5*i))&31)
+	}
+	words = append(words, 1, 1, 20) // p field, 52 words
+	words = append(words, make([]byte, 52+104)...)
+	invoice, err := bech32.Encode("lnbc32u", words)
+	if err != nil {
+		t.Fatalf("encoding invoice: %v", err)
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, ".well-known/lnurlp") {
+			_ = json.NewEncoder(w).Encode(lightning.LNURLPayMetadata{
+				Callback:    "https://" + r.Host + "/callback",
+				MinSendable: 1000,
+				MaxSendable: 100000000000,
+			})
+			return
+		}
+		_ = json.NewEncoder(w).Encode(lightning.InvoiceResponse{PR: invoice})
+	}))
+	defer server.Close()
+
+	lnClient := lightning.NewClientWithHTTP(server.Client())
+	address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, []string{address}, "", lnClient, "", false, 0, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !strings.Contains(result.Message, invoice) {
+		t.Fatalf("expected invoice in message, got %q", result.Message)
+	}
+
+	customer, _ := database.GetCustomerByNpub(ctx, testCustomerNpub)
+	orders, _ := database.GetPendingOrdersByCustomer(ctx, customer.ID)
+	if len(orders) != 1 {
+		t.Fatalf("expected 1 pending order, got %d", len(orders))
+	}
+
+	invoices, err := database.GetInvoicesByOrder(ctx, orders[0].ID)
+	if err != nil {
+		t.Fatalf("GetInvoicesByOrder: %v", err)
+	}
+	if len(invoices) != 1 {
+		t.Fatalf("expected 1 recorded invoice, got %d", len(invoices))
+	}
+	inv := invoices[0]
+	if inv.Bolt11 != invoice || inv.AmountSats != 3200 || inv.Status != db.InvoiceStatusRequested {
+		t.Errorf("unexpected invoice record: %+v", inv)
+	}
+	wantExpiry := time.Unix(createdAt, 0).Add(time.Hour)
+	if !inv.ExpiresAt.Equal(wantExpiry) {
+		t.Errorf("expires_at = %v, want %v", inv.ExpiresAt, wantExpiry)
+	}
+}
+
 func TestOrderCmd_PendingOrderBlocks(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
 	_ = database.AddEggs(ctx, 50)
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	// First order succeeds
-	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, "", "", nil)
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 	if result.Error != nil {
 		t.Fatalf("first order failed: %v", result.Error)
 	}
 
 	// Second order blocked due to pending
-	result = OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, "", "", nil)
+	result = OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 	if result.Error == nil {
 		t.Fatal("expected error for second order with pending")
 	}
@@ -473,7 +620,7 @@ func TestOrderCmd_PendingOrderBlocks(t *testing.T) {
 	_ = database.CancelOrder(ctx, pending[0].ID)
 
 	// Now ordering works again
-	result = OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, "", "", nil)
+	result = OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 	if result.Error != nil {
 		t.Fatalf("order after cancel failed: %v", result.Error)
 	}
@@ -485,9 +632,9 @@ func TestOrderCmd_InsufficientInventory(t *testing.T) {
 
 	// Setup: only 5 eggs, customer orders 6
 	_ = database.AddEggs(ctx, 5)
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 
-	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, "", "", nil)
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
 	if result.Error == nil {
 		t.Fatal("expected error for insufficient inventory")
 	}
@@ -496,14 +643,95 @@ func TestOrderCmd_InsufficientInventory(t *testing.T) {
 	}
 }
 
+func TestOrderCmd_LowInventoryWarning(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.AddEggs(ctx, 12)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"12"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 6, nil)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	if !result.LowInventoryWarning {
+		t.Error("expected LowInventoryWarning when the order empties inventory")
+	}
+	if result.EggsRemaining != 0 {
+		t.Errorf("EggsRemaining = %d, want 0", result.EggsRemaining)
+	}
+
+	// The warning is off without a threshold
+	_ = database.AddEggs(ctx, 12)
+	_, _ = database.CreateCustomer(ctx, testAdminNpub, "")
+	if result := OrderCmd(ctx, database, testAdminNpub, []string{"12"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil); result.Error != nil || result.LowInventoryWarning {
+		t.Errorf("got %v, LowInventoryWarning %v; want no warning", result.Error, result.LowInventoryWarning)
+	}
+}
+
+func TestOrderCmd_WaitlistCTA(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	const cta = "Reply 'waitlist 12' to be notified"
+
+	// Offered only when the order can't be filled and the waitlist is on
+	result := OrderCmd(ctx, database, testCustomerNpub, []string{"12"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", true, 0, nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "only 0 eggs available, cannot order 12\n\nNo eggs available right now. "+cta) {
+		t.Errorf("expected the waitlist offer below the inventory error, got %v", result.Error)
+	}
+	result = OrderCmd(ctx, database, testCustomerNpub, []string{"12"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", false, 0, nil)
+	if result.Error == nil || strings.Contains(result.Error.Error(), "waitlist") {
+		t.Errorf("expected no waitlist offer with the waitlist off, got %v", result.Error)
+	}
+
+	// Other errors never offer it
+	_ = database.AddEggs(ctx, 12)
+	if result := OrderCmd(ctx, database, testCustomerNpub, []string{"7"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", true, 0, nil); result.Error == nil ||
+		strings.Contains(result.Error.Error(), "waitlist") {
+		t.Errorf("invalid quantity: got %v", result.Error)
+	}
+	if result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", true, 0, nil); result.Error != nil {
+		t.Fatalf("order: %v", result.Error)
+	}
+	if result := OrderCmd(ctx, database, testCustomerNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", true, 0, nil); result.Error == nil ||
+		strings.Contains(result.Error.Error(), "waitlist") {
+		t.Errorf("pending order: got %v", result.Error)
+	}
+	if result := OrderCmd(ctx, database, testUnknownNpub, []string{"6"}, 3200, config.DefaultAllowedQuantities, nil, "", nil, "", true, 0, nil); result.Error == nil ||
+		strings.Contains(result.Error.Error(), "waitlist") {
+		t.Errorf("unknown customer: got %v", result.Error)
+	}
+}
+
+func TestAppendWaitlistCTA(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       string
+		available int
+		want      string
+	}{
+		{"sold out", "only 0 eggs available, cannot order 6", 0,
+			"only 0 eggs available, cannot order 6\n\nNo eggs available right now. Reply 'waitlist 6' to be notified when stock replenishes."},
+		{"some left", "only 4 eggs available, cannot order 6", 4,
+			"only 4 eggs available, cannot order 6\n\nReply 'waitlist 6' to be notified when 6 eggs are in stock."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := appendWaitlistCTA(tt.msg, 6, tt.available, nil); got != tt.want {
+				t.Errorf("appendWaitlistCTA = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestBalanceCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	// No payments
-	result := BalanceCmd(ctx, database, testCustomerNpub)
+	result := BalanceCmd(ctx, database, testCustomerNpub, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -512,9 +740,9 @@ func TestBalanceCmd(t *testing.T) {
 	}
 
 	// Add payment
-	_, _ = database.RecordTransaction(ctx, nil, "zap1", 5000, testCustomerNpub)
+	_, _ = database.RecordTransaction(ctx, nil, "zap1", db.TransactionSourceZap, 5000, testCustomerNpub)
 
-	result = BalanceCmd(ctx, database, testCustomerNpub)
+	result = BalanceCmd(ctx, database, testCustomerNpub, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -528,7 +756,7 @@ func TestBalanceCmd(t *testing.T) {
 	_ = database.UpdateOrderStatus(ctx, order.ID, "paid")
 	_ = database.FulfillOrder(ctx, order.ID)
 
-	result = BalanceCmd(ctx, database, testCustomerNpub)
+	result = BalanceCmd(ctx, database, testCustomerNpub, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -538,16 +766,31 @@ func TestBalanceCmd(t *testing.T) {
 	if !strings.Contains(result.Message, "Balance: 1800 sats") {
 		t.Errorf("expected 1800 sats balance, got %q", result.Message)
 	}
+	if strings.Contains(result.Message, "Reserved") {
+		t.Errorf("nothing is pending, got %q", result.Message)
+	}
+
+	// A pending order shows what it will cost
+	_, _ = database.CreateOrder(ctx, c.ID, 2, 1100)
+	result = BalanceCmd(ctx, database, testCustomerNpub, nil)
+	if !strings.Contains(result.Message, "Reserved: 1100 sats in pending orders") {
+		t.Errorf("expected 1100 sats reserved, got %q", result.Message)
+	}
+
+	// Unknown customers get an error
+	if result := BalanceCmd(ctx, database, testUnknownNpub, nil); result.Error == nil {
+		t.Error("expected an error for an unknown customer")
+	}
 }
 
 func TestHistoryCmd(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 
 	// No orders
-	result := HistoryCmd(ctx, database, testCustomerNpub)
+	result := HistoryCmd(ctx, database, testCustomerNpub, nil, TimeDisplay{}, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -562,7 +805,7 @@ func TestHistoryCmd(t *testing.T) {
 	_, _ = database.CreateOrder(ctx, c.ID, 6, 3200)
 	_, _ = database.CreateOrder(ctx, c.ID, 12, 6400)
 
-	result = HistoryCmd(ctx, database, testCustomerNpub)
+	result = HistoryCmd(ctx, database, testCustomerNpub, nil, TimeDisplay{}, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -575,11 +818,75 @@ func TestHistoryCmd(t *testing.T) {
 	if !strings.Contains(result.Message, "12 eggs") {
 		t.Errorf("expected 12 eggs order, got %q", result.Message)
 	}
+
+	// Order times are rendered in the given zone
+	tokyo := time.FixedZone("JST", 9*60*60)
+	orders, _ := database.GetCustomerOrdersByOffset(ctx, c.ID, 1, 0)
+	result = HistoryCmd(ctx, database, testCustomerNpub, nil, TimeDisplay{Location: tokyo}, nil)
+	if want := FormatTime(orders[0].CreatedAt, tokyo); !strings.Contains(result.Message, want) {
+		t.Errorf("expected order time %q, got %q", want, result.Message)
+	}
+}
+
+func TestHistoryCmd_Pages(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 6*(historyPageSize+2))
+	for range historyPageSize + 2 {
+		if _, err := database.CreateOrder(ctx, c.ID, 6, 3200); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+
+	first := HistoryCmd(ctx, database, testCustomerNpub, nil, TimeDisplay{}, nil)
+	if first.Error != nil {
+		t.Fatalf("unexpected error: %v", first.Error)
+	}
+	if !strings.HasPrefix(first.Message, "Page 1 of 2 (27 orders):") {
+		t.Errorf("unexpected page 1 header: %q", first.Message)
+	}
+	if n := strings.Count(first.Message, "• #"); n != historyPageSize {
+		t.Errorf("page 1 has %d orders, want %d", n, historyPageSize)
+	}
+	if !strings.Contains(first.Message, fmt.Sprintf("• #%d:", historyPageSize+2)) {
+		t.Errorf("page 1 should start with the newest order, got %q", first.Message)
+	}
+	if !strings.Contains(first.Message, `Send "history 2" for older orders.`) {
+		t.Errorf("page 1 should point to page 2, got %q", first.Message)
+	}
+
+	second := HistoryCmd(ctx, database, testCustomerNpub, []string{"2"}, TimeDisplay{}, nil)
+	if second.Error != nil {
+		t.Fatalf("unexpected error: %v", second.Error)
+	}
+	if !strings.HasPrefix(second.Message, "Page 2 of 2 (27 orders):") {
+		t.Errorf("unexpected page 2 header: %q", second.Message)
+	}
+	if !strings.Contains(second.Message, "• #2:") || !strings.Contains(second.Message, "• #1:") {
+		t.Errorf("page 2 should hold the two oldest orders, got %q", second.Message)
+	}
+	if strings.Count(second.Message, "• #") != 2 || strings.Contains(second.Message, "history 3") {
+		t.Errorf("page 2 should be the last, got %q", second.Message)
+	}
+
+	if result := HistoryCmd(ctx, database, testCustomerNpub, []string{"3"}, TimeDisplay{}, nil); result.Message != "Page 3 is out of range: there are 2 pages (27 orders)." {
+		t.Errorf("page 3 = %q", result.Message)
+	}
+	for _, arg := range []string{"0", "x"} {
+		if result := HistoryCmd(ctx, database, testCustomerNpub, []string{arg}, TimeDisplay{}, nil); result.Error == nil {
+			t.Errorf("history %s: expected a usage error", arg)
+		}
+	}
 }
 
 func TestHelpCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
 	// Non-admin help
-	result := HelpCmd(false)
+	result := HelpCmd(ctx, database, false, ExecuteConfig{}, nil)
 	if result.Error != nil {
 		t.Fatalf("unexpected error: %v", result.Error)
 	}
@@ -589,18 +896,24 @@ func TestHelpCmd(t *testing.T) {
 	if !strings.Contains(result.Message, "cancel") {
 		t.Error("expected cancel in help")
 	}
+	if !strings.Contains(result.Message, "info [topic]") || strings.Contains(result.Message, "info set") {
+		t.Error("expected info in help, without its admin subcommands")
+	}
 	if strings.Contains(result.Message, "Admin commands") {
 		t.Error("non-admin should not see admin commands")
 	}
 
 	// Admin help
-	result = HelpCmd(true)
+	result = HelpCmd(ctx, database, true, ExecuteConfig{}, nil)
 	if !strings.Contains(result.Message, "Admin commands") {
 		t.Error("admin should see admin commands")
 	}
 	if !strings.Contains(result.Message, "addcustomer") {
 		t.Error("admin should see addcustomer")
 	}
+	if !strings.Contains(result.Message, "info set <topic> <text>") {
+		t.Error("admin should see info set")
+	}
 }
 
 func TestCancelOrderCmd(t *testing.T) {
@@ -608,7 +921,7 @@ func TestCancelOrderCmd(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Setup: customer, inventory, and order
-	c, _ := database.CreateCustomer(ctx, testCustomerNpub)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 20) // Required for reservation model
 	order, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
 
@@ -641,7 +954,7 @@ func TestCancelOrderCmd(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := CancelOrderCmd(ctx, database, testCustomerNpub, tt.args)
+			result := CancelOrderCmd(ctx, database, testCustomerNpub, tt.args, nil)
 			if tt.wantErr {
 				if result.Error == nil {
 					t.Fatal("expected error, got nil")
@@ -662,7 +975,7 @@ func TestCancelOrderCmd(t *testing.T) {
 
 	// Test successful cancellation
 	t.Run("cancel pending order", func(t *testing.T) {
-		result := CancelOrderCmd(ctx, database, testCustomerNpub, []string{fmt.Sprintf("%d", order.ID)})
+		result := CancelOrderCmd(ctx, database, testCustomerNpub, []string{fmt.Sprintf("%d", order.ID)}, nil)
 		if result.Error != nil {
 			t.Fatalf("unexpected error: %v", result.Error)
 		}
@@ -673,7 +986,7 @@ func TestCancelOrderCmd(t *testing.T) {
 
 	// Test cancelling already cancelled order
 	t.Run("cancel already cancelled", func(t *testing.T) {
-		result := CancelOrderCmd(ctx, database, testCustomerNpub, []string{fmt.Sprintf("%d", order.ID)})
+		result := CancelOrderCmd(ctx, database, testCustomerNpub, []string{fmt.Sprintf("%d", order.ID)}, nil)
 		if result.Error == nil {
 			t.Fatal("expected error for already cancelled order")
 		}
@@ -683,13 +996,39 @@ func TestCancelOrderCmd(t *testing.T) {
 	})
 }
 
+func TestTriggerNotifications(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 6)
+	order, _ := database.CreateOrder(ctx, c.ID, 6, 3200)
+
+	tests := []struct {
+		name   string
+		result Result
+		want   bool
+	}{
+		{"inventory", InventoryCmd(ctx, database, nil, true, nil), false},
+		{"failed cancel", CancelOrderCmd(ctx, database, testCustomerNpub, []string{"99"}, nil), false},
+		{"cancel", CancelOrderCmd(ctx, database, testCustomerNpub, []string{fmt.Sprint(order.ID)}, nil), true},
+		{"inventory add", InventoryCmd(ctx, database, []string{"add", "6"}, true, nil), true},
+		{"inventory set", InventoryCmd(ctx, database, []string{"set", "24"}, true, nil), true},
+		{"failed inventory add", InventoryCmd(ctx, database, []string{"add", "0"}, true, nil), false},
+	}
+	for _, tt := range tests {
+		if tt.result.TriggerNotifications != tt.want {
+			t.Errorf("%s: TriggerNotifications = %v, want %v (%q, %v)", tt.name, tt.result.TriggerNotifications, tt.want, tt.result.Message, tt.result.Error)
+		}
+	}
+}
+
 func TestCancelOrderCmd_OwnershipCheck(t *testing.T) {
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
 	// Setup: two customers
-	c1, _ := database.CreateCustomer(ctx, testCustomerNpub)
-	_, _ = database.CreateCustomer(ctx, testAdminNpub)
+	c1, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_, _ = database.CreateCustomer(ctx, testAdminNpub, "")
 
 	// Add inventory (required for reservation model)
 	_ = database.AddEggs(ctx, 20)
@@ -698,7 +1037,7 @@ func TestCancelOrderCmd_OwnershipCheck(t *testing.T) {
 	order, _ := database.CreateOrder(ctx, c1.ID, 6, 3200)
 
 	// Customer 2 (admin npub) tries to cancel customer 1's order
-	result := CancelOrderCmd(ctx, database, testAdminNpub, []string{fmt.Sprintf("%d", order.ID)})
+	result := CancelOrderCmd(ctx, database, testAdminNpub, []string{fmt.Sprintf("%d", order.ID)}, nil)
 	if result.Error == nil {
 		t.Fatal("expected error when cancelling another's order")
 	}
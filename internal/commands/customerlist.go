@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// ParseCustomerList reads customers to register, one "npub[,name]" entry per
+// line, as CSV or plain lines. A first line of just "npub" or "npub,name" is
+// a header and skipped, as are blank lines and lines starting with "#".
+// Invalid entries are returned as failure messages with their line number
+// rather than errors; only unreadable input is an error.
+func ParseCustomerList(r io.Reader) (customers []db.CustomerImport, failures []string, err error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+	reader.Comment = '#'
+
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading customer list: %w", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		npub := strings.TrimSpace(record[0])
+		if first && strings.EqualFold(npub, "npub") {
+			continue
+		}
+		if len(record) > 2 {
+			failures = append(failures, fmt.Sprintf("line %d: expected npub[,name], got %d columns", line, len(record)))
+			continue
+		}
+		if prefix, _, err := nip19.Decode(npub); err != nil || prefix != "npub" {
+			failures = append(failures, fmt.Sprintf("line %d: %q is not a valid npub", line, npub))
+			continue
+		}
+
+		customer := db.CustomerImport{Npub: npub}
+		if len(record) == 2 {
+			customer.Name = strings.TrimSpace(record[1])
+		}
+		customers = append(customers, customer)
+	}
+	return customers, failures, nil
+}
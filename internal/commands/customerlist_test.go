@@ -0,0 +1,45 @@
+package commands
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCustomerList(t *testing.T) {
+	input := strings.Join([]string{
+		"# exported from the egg spreadsheet",
+		"npub,name",
+		testCustomerNpub + ", O'Brien",
+		"",
+		testAdminNpub,
+		`npub1bogus,"Mallory"`,
+		strangerNpub + `,"Smith, Jane"`,
+		strangerNpub + ",too,many",
+	}, "\n")
+
+	customers, failures, err := ParseCustomerList(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("ParseCustomerList: %v", err)
+	}
+	want := []struct{ npub, name string }{
+		{testCustomerNpub, "O'Brien"},
+		{testAdminNpub, ""},
+		{strangerNpub, "Smith, Jane"},
+	}
+	if len(customers) != len(want) {
+		t.Fatalf("got %d customers, want %d: %v", len(customers), len(want), customers)
+	}
+	for i, w := range want {
+		if customers[i].Npub != w.npub || customers[i].Name != w.name {
+			t.Errorf("customer %d = %+v, want %s %q", i, customers[i], w.npub, w.name)
+		}
+	}
+
+	wantFailures := []string{
+		`line 6: "npub1bogus" is not a valid npub`,
+		"line 8: expected npub[,name], got 3 columns",
+	}
+	if strings.Join(failures, "\n") != strings.Join(wantFailures, "\n") {
+		t.Errorf("failures = %q, want %q", failures, wantFailures)
+	}
+}
@@ -2,47 +2,135 @@ package commands
 
 import (
 	"context"
+	"slices"
+	"time"
 
+	"github.com/buildtall-systems/eggbot/internal/config"
 	"github.com/buildtall-systems/eggbot/internal/db"
 	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+	"github.com/buildtall-systems/eggbot/internal/nostr"
 )
 
 // ExecuteConfig holds configuration needed for command execution.
 type ExecuteConfig struct {
-	SatsPerHalfDozen int
-	Admins           []string
-	LightningAddress string
-	BotNpub          string             // Bot's npub for payment links
-	LightningClient  *lightning.Client  // LNURL-pay client for invoice generation
+	SatsPerHalfDozen      int
+	LightningAddress      string
+	FallbackAddresses     []string          // Tried in order if LightningAddress fails
+	BotNpub               string            // Bot's npub for payment links
+	LightningClient       *lightning.Client // LNURL-pay client for invoice generation
+	PickupInstructions    string            // Configured pickup text; admins can override it at runtime
+	Roles                 *RoleCache        // Invalidated when a command changes someone's role; may be nil
+	AllowedQuantities     []int             // Order sizes for order and sell; nil means config.DefaultAllowedQuantities
+	Location              *time.Location    // Zone for times shown in replies; nil means UTC
+	Now                   func() time.Time  // Clock for order ages; nil means time.Now
+	OrderExpiry           time.Duration     // How long unpaid orders are held; 0 when they don't expire
+	Announcer             Announcer         // Publishes inventory announcements; nil when they are off
+	Messages              *messages.Catalog // Customer-facing message texts; nil means the built-in ones
+	Replies               *messages.Catalog // Messages in the sender's language if already looked up; nil looks them up
+	Waitlist              bool              // Accept the waitlist command and offer it when an order can't be filled
+	LowInventoryThreshold int               // Orders leaving fewer eggs set LowInventoryWarning; 0 disables it
+
+	// Shown by the config command
+	Build        BuildInfo
+	DatabasePath string
+	Relays       func() []nostr.RelayStatus // Current relay states; nil when there are no relays to ask
+}
+
+// lightningAddresses returns the primary lightning address followed by any fallbacks.
+func (cfg ExecuteConfig) lightningAddresses() []string {
+	if cfg.LightningAddress == "" {
+		return nil
+	}
+	return append([]string{cfg.LightningAddress}, cfg.FallbackAddresses...)
+}
+
+// allowedQuantities returns the configured order sizes, or the defaults if unset.
+func (cfg ExecuteConfig) allowedQuantities() []int {
+	if len(cfg.AllowedQuantities) == 0 {
+		return config.DefaultAllowedQuantities
+	}
+	return cfg.AllowedQuantities
+}
+
+// Enabled reports whether the named command is turned on. Commands behind a
+// feature that is off are treated as unknown and left out of help.
+func (cfg ExecuteConfig) Enabled(name string) bool {
+	if name == CmdWaitlist {
+		return cfg.Waitlist
+	}
+	return true
+}
+
+// timeDisplay returns the settings for showing order times, with dates
+// written the way msgs' language writes them.
+func (cfg ExecuteConfig) timeDisplay(msgs *messages.Catalog) TimeDisplay {
+	return TimeDisplay{Location: cfg.Location, Now: cfg.Now, OrderExpiry: cfg.OrderExpiry, Layout: msgs.DateFormat()}
 }
 
 // Execute runs the command and returns a result.
 // senderNpub is the sender's public key in npub format.
 func Execute(ctx context.Context, database *db.DB, cmd *Command, senderNpub string, cfg ExecuteConfig) Result {
-	isAdmin := IsAdmin(senderNpub, cfg.Admins)
+	result := execute(ctx, database, cmd, senderNpub, cfg)
+
+	// Drop the cached role of anyone whose access just changed so it applies to their next DM
+	if result.Error == nil && len(cmd.Args) > 0 && slices.Contains(roleChangingCommands, cmd.Name) {
+		cfg.Roles.Invalidate(cmd.Args[0])
+	}
+
+	return result
+}
+
+// execute dispatches the command to its handler.
+func execute(ctx context.Context, database *db.DB, cmd *Command, senderNpub string, cfg ExecuteConfig) Result {
+	isAdmin := IsAdmin(ctx, database, senderNpub)
+	// Replies are in the sender's chosen language; command names stay English
+	msgs := cfg.Replies
+	if msgs == nil {
+		msgs = MessagesFor(ctx, database, senderNpub, cfg.Messages)
+	}
 
 	switch cmd.Name {
 	// Customer commands (with admin subcommands)
 	case CmdInventory:
-		return InventoryCmd(ctx, database, cmd.Args, isAdmin)
+		return InventoryCmd(ctx, database, cmd.Args, isAdmin, msgs)
 
 	case CmdOrder:
-		return OrderCmd(ctx, database, senderNpub, cmd.Args, cfg.SatsPerHalfDozen, cfg.LightningAddress, cfg.BotNpub, cfg.LightningClient)
+		return OrderCmd(ctx, database, senderNpub, cmd.Args, cfg.SatsPerHalfDozen, cfg.allowedQuantities(), cfg.lightningAddresses(), cfg.BotNpub, cfg.LightningClient,
+			PickupInstructions(ctx, database, cfg.PickupInstructions), cfg.Waitlist, cfg.LowInventoryThreshold, msgs)
 
 	case CmdCancel:
-		return CancelOrderCmd(ctx, database, senderNpub, cmd.Args)
+		return CancelOrderCmd(ctx, database, senderNpub, cmd.Args, msgs)
 
 	case CmdBalance:
-		return BalanceCmd(ctx, database, senderNpub)
+		return BalanceCmd(ctx, database, senderNpub, msgs)
 
 	case CmdHistory:
-		return HistoryCmd(ctx, database, senderNpub)
+		return HistoryCmd(ctx, database, senderNpub, cmd.Args, cfg.timeDisplay(msgs), msgs)
 
 	case CmdHelp:
-		return HelpCmd(isAdmin)
+		if len(cmd.Args) > 0 {
+			return HelpTopicCmd(cmd.Args[0], isAdmin, cfg, msgs)
+		}
+		return HelpCmd(ctx, database, isAdmin, cfg, msgs)
 
 	case CmdNotify:
-		return NotifyCmd(ctx, database, senderNpub, cmd.Args)
+		return NotifyCmd(ctx, database, senderNpub, cmd.Name, cmd.Args, cfg.allowedQuantities(), msgs)
+
+	case CmdWaitlist:
+		if !cfg.Waitlist {
+			return HelpCmd(ctx, database, isAdmin, cfg, msgs)
+		}
+		return NotifyCmd(ctx, database, senderNpub, cmd.Name, cmd.Args, cfg.allowedQuantities(), msgs)
+
+	case CmdInfo:
+		return InfoCmd(ctx, database, cmd.Args, isAdmin, msgs)
+
+	case CmdContact:
+		return ContactCmd(ctx, database, senderNpub, cmd.Args, msgs)
+
+	case CmdLang:
+		return LangCmd(ctx, database, senderNpub, cmd.Args, cfg.Messages)
 
 	// Admin commands
 	case CmdDeliver:
@@ -55,10 +143,13 @@ func Execute(ctx context.Context, database *db.DB, cmd *Command, senderNpub stri
 		return AdjustCmd(ctx, database, cmd.Args)
 
 	case CmdOrders:
-		return OrdersCmd(ctx, database)
+		return OrdersCmd(ctx, database, cmd.Args, cfg.timeDisplay(msgs))
+
+	case CmdTransactions:
+		return TransactionsCmd(ctx, database, cmd.Args, cfg.Location)
 
 	case CmdCustomers:
-		return CustomersCmd(ctx, database)
+		return CustomersCmd(ctx, database, cmd.Args)
 
 	case CmdAddCustomer:
 		return AddCustomerCmd(ctx, database, cmd.Args)
@@ -66,13 +157,49 @@ func Execute(ctx context.Context, database *db.DB, cmd *Command, senderNpub stri
 	case CmdRemoveCustomer:
 		return RemoveCustomerCmd(ctx, database, cmd.Args)
 
+	case CmdContactLog:
+		return ContactLogCmd(ctx, database, cmd.Args, cfg.Location)
+
 	case CmdSales:
-		return SalesCmd(ctx, database)
+		return SalesCmd(ctx, database, cfg.timeDisplay(msgs))
+
+	case CmdTopCustomers:
+		return TopCustomersCmd(ctx, database, cmd.Args)
+
+	case CmdConversion:
+		return ConversionCmd(ctx, database)
+
+	case CmdInstructions:
+		return InstructionsCmd(ctx, database, cmd.Args, cfg.PickupInstructions)
+
+	case CmdAnnounce:
+		return AnnounceCmd(ctx, database, cfg.Announcer)
+
+	case CmdConfig:
+		return ConfigCmd(ctx, database, cfg)
+
+	case CmdBlock:
+		return BlockCmd(ctx, database, cmd.Args, senderNpub)
+
+	case CmdUnblock:
+		return UnblockCmd(ctx, database, cmd.Args)
+
+	case CmdBlocked:
+		return BlockedCmd(ctx, database, cfg.Location)
+
+	case CmdAddAdmin:
+		return AddAdminCmd(ctx, database, cmd.Args, senderNpub)
+
+	case CmdRemoveAdmin:
+		return RemoveAdminCmd(ctx, database, cmd.Args)
+
+	case CmdAdminPrefs:
+		return AdminPrefsCmd(ctx, database, cmd.Args, senderNpub)
 
 	case CmdSell:
-		return SellCmd(ctx, database, cmd.Args, cfg.SatsPerHalfDozen)
+		return SellCmd(ctx, database, cmd.Args, cfg.SatsPerHalfDozen, cfg.allowedQuantities(), cfg.LowInventoryThreshold)
 
 	default:
-		return HelpCmd(isAdmin)
+		return HelpCmd(ctx, database, isAdmin, cfg, msgs)
 	}
 }
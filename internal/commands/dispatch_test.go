@@ -2,6 +2,7 @@ package commands
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"testing"
 )
@@ -16,12 +17,13 @@ func TestExecute(t *testing.T) {
 	database := setupCmdTestDB(t)
 
 	// Setup: create customer and add inventory using properly generated keypairs
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 20)
 
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+
 	cfg := ExecuteConfig{
 		SatsPerHalfDozen: 3200,
-		Admins:           []string{testAdminNpub},
 	}
 
 	tests := []struct {
@@ -122,17 +124,137 @@ func TestExecute(t *testing.T) {
 	}
 }
 
+func TestExecute_Waitlist(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200, Waitlist: true}
+
+	result := Execute(ctx, database, &Command{Name: CmdOrder, Args: []string{"6"}}, testCustomerNpub, cfg)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "Reply 'waitlist 6'") {
+		t.Fatalf("order = %v, want the waitlist offer", result.Error)
+	}
+
+	// Taking up the offer subscribes to the inventory notification
+	result = Execute(ctx, database, &Command{Name: CmdWaitlist, Args: []string{"6"}}, testCustomerNpub, cfg)
+	if result.Error != nil || !strings.Contains(result.Message, "notified when 6 eggs") {
+		t.Errorf("waitlist 6 = %q, %v", result.Message, result.Error)
+	}
+	if n, err := database.GetInventoryNotification(ctx, c.ID); err != nil || n == nil || n.ThresholdEggs != 6 {
+		t.Errorf("notification = %+v, %v; want one for 6 eggs", n, err)
+	}
+	if help := Execute(ctx, database, &Command{Name: CmdHelp}, testCustomerNpub, cfg); !strings.Contains(help.Message, "• waitlist") {
+		t.Errorf("help should list waitlist when it is on, got %q", help.Message)
+	}
+}
+
+func TestExecute_WaitlistConfiguredQuantity(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200, AllowedQuantities: []int{1, 5, 10}, Waitlist: true}
+
+	result := Execute(ctx, database, &Command{Name: CmdOrder, Args: []string{"5"}}, testCustomerNpub, cfg)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "Reply 'waitlist 5'") {
+		t.Fatalf("order = %v, want the waitlist offer for 5", result.Error)
+	}
+
+	// The suggested reply is accepted, though 5 isn't a default size
+	result = Execute(ctx, database, &Command{Name: CmdWaitlist, Args: []string{"5"}}, testCustomerNpub, cfg)
+	if result.Error != nil || !strings.Contains(result.Message, "notified when 5 eggs") {
+		t.Errorf("waitlist 5 = %q, %v", result.Message, result.Error)
+	}
+	if n, err := database.GetInventoryNotification(ctx, c.ID); err != nil || n == nil || n.ThresholdEggs != 5 {
+		t.Errorf("notification = %+v, %v; want one for 5 eggs", n, err)
+	}
+
+	result = Execute(ctx, database, &Command{Name: CmdWaitlist, Args: []string{"6"}}, testCustomerNpub, cfg)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "1, 5 or 10") {
+		t.Errorf("waitlist 6 = %v, want the allowed sizes", result.Error)
+	}
+
+	_ = database.DeleteInventoryNotification(ctx, c.ID)
+	result = Execute(ctx, database, &Command{Name: CmdWaitlist}, testCustomerNpub, cfg)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "waitlist <quantity> (1, 5 or 10) or waitlist off") {
+		t.Errorf("waitlist = %v, want its usage", result.Error)
+	}
+}
+
+func TestExecute_WaitlistOff(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200}
+
+	// The command is unknown while the feature is off
+	result := Execute(ctx, database, &Command{Name: CmdWaitlist, Args: []string{"6"}}, testCustomerNpub, cfg)
+	if result.Error != nil || !strings.HasPrefix(result.Message, "Available commands:") {
+		t.Errorf("waitlist 6 = %q, %v; want the command list", result.Message, result.Error)
+	}
+	if n, err := database.GetInventoryNotification(ctx, c.ID); err != nil || n != nil {
+		t.Errorf("notification = %+v, %v; want none", n, err)
+	}
+	if strings.Contains(result.Message, "• waitlist") {
+		t.Errorf("help should not list waitlist when it is off, got %q", result.Message)
+	}
+	if result := Execute(ctx, database, &Command{Name: CmdHelp, Args: []string{"waitlist"}}, testCustomerNpub, cfg); result.Error == nil {
+		t.Errorf("help waitlist = %q, want no help while it is off", result.Message)
+	}
+}
+
+func TestExecute_MarkpaidThenDeliver(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	c, _ := database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 12)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200}
+
+	order, err := database.CreateOrder(ctx, c.ID, 6, 3200)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	// Each step goes through the parser and permission check like a DM would
+	run := func(content string) Result {
+		t.Helper()
+		cmd := Parse(content)
+		if cmd == nil || !cmd.IsValid() || !cmd.IsAdminCommand() {
+			t.Fatalf("%q did not parse as an admin command: %+v", content, cmd)
+		}
+		if err := CanExecute(ctx, database, cmd, testAdminNpub, nil); err != nil {
+			t.Fatalf("%q: %v", content, err)
+		}
+		return Execute(ctx, database, cmd, testAdminNpub, cfg)
+	}
+
+	if result := run(fmt.Sprintf("markpaid %d", order.ID)); result.Error != nil {
+		t.Fatalf("markpaid: %v", result.Error)
+	}
+	if got, _ := database.GetOrderByID(ctx, order.ID); got.Status != "paid" {
+		t.Fatalf("status after markpaid = %s, want paid", got.Status)
+	}
+
+	if result := run(fmt.Sprintf("deliver %d", order.ID)); result.Error != nil {
+		t.Fatalf("deliver: %v", result.Error)
+	}
+	if got, _ := database.GetOrderByID(ctx, order.ID); got.Status != "fulfilled" {
+		t.Errorf("status after deliver = %s, want fulfilled", got.Status)
+	}
+}
+
 func TestExecute_AllCommands(t *testing.T) {
 	// Test that all command constants are handled
 	ctx := context.Background()
 	database := setupCmdTestDB(t)
 
-	_, _ = database.CreateCustomer(ctx, testCustomerNpub)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
 	_ = database.AddEggs(ctx, 100)
 
+	_ = database.SeedAdmins(ctx, []string{testCustomerNpub}) // Make customer also admin for testing
+
 	cfg := ExecuteConfig{
 		SatsPerHalfDozen: 3200,
-		Admins:           []string{testCustomerNpub}, // Make customer also admin for testing
 	}
 
 	commands := []string{
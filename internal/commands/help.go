@@ -0,0 +1,411 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+// commandHelp is the help text for a single command.
+type commandHelp struct {
+	lines       []string // Entries for the "help" overview, "usage - summary"
+	adminLines  []string // Overview entries for admin-only subcommands of a customer command
+	detail      string   // Long description for "help <command>"
+	adminDetail string   // Appended to detail when an admin asks
+}
+
+// helpRegistry holds help text for every known command, in English; see
+// helpTranslations for the customer commands in other languages.
+// Lines and details may use {quantities} for the allowed order sizes ("6|12"),
+// {prices} for what each size costs and {example} for a size to show in
+// examples, all filled in from the current config.
+var helpRegistry = map[string]commandHelp{
+	CmdInventory: {
+		lines: []string{"inventory - Check egg availability"},
+		adminLines: []string{
+			"inventory add <qty> - Add eggs to inventory",
+			"inventory set <qty> - Set inventory to exact count",
+		},
+		detail: `inventory - Check egg availability
+
+Shows how many eggs are available to order right now.
+
+Example: inventory`,
+		adminDetail: `Admin usage:
+• inventory - Available, reserved (unpaid) and sold counts
+• inventory add <qty> - Add newly collected eggs, e.g. inventory add 24
+• inventory set <qty> - Correct the count after a recount, e.g. inventory set 30`,
+	},
+	CmdOrder: {
+		lines: []string{"order <{quantities}> - Order eggs"},
+		detail: `order <{quantities}> - Order eggs
+
+Quantities: {prices}.
+
+The eggs are reserved for you as soon as you order. The reply includes your order number and how to pay: a Lightning invoice and/or a zap to this profile. Once payment arrives the order is marked paid automatically and you'll get a confirmation.
+
+You can have one unpaid order at a time; pay or cancel it before ordering again.
+
+Example: order {example}`,
+	},
+	CmdCancel: {
+		lines: []string{"cancel <order_id> - Cancel a pending order"},
+		detail: `cancel <order_id> - Cancel a pending order
+
+Releases the eggs reserved by an unpaid order. Paid orders can't be cancelled. Find the order number in your order confirmation or with "history".
+
+Example: cancel 42`,
+	},
+	CmdBalance: {
+		lines: []string{"balance - Check your payment balance"},
+		detail: `balance - Check your payment balance
+
+Shows your sats balance. Zaps add to it and paid orders draw from it, so overpayments carry over to your next order. Orders awaiting payment are listed as reserved.
+
+Example: balance`,
+	},
+	CmdHistory: {
+		lines: []string{"history [page] - View recent orders"},
+		detail: `history [page] - View recent orders
+
+Lists your orders, most recent first, with their number, quantity, price, status and when they were placed. Each page shows 25 orders; add a page number to see older ones.
+
+Example: history 2`,
+	},
+	CmdNotify: {
+		lines: []string{
+			"notify <{quantities}> - Get notified when inventory reaches quantity",
+			"notify off - Cancel notification",
+		},
+		detail: `notify <{quantities}> - Get notified when eggs are available
+
+Sends you a DM once at least that many eggs are in stock. Use "notify" on its own to see your current notification.
+
+Examples:
+• notify {example}
+• notify off`,
+	},
+	CmdWaitlist: {
+		lines: []string{"waitlist <{quantities}> - Join the waitlist when eggs run out"},
+		detail: `waitlist <{quantities}> - Join the waitlist when eggs run out
+
+The same as notify: you get a DM once at least that many eggs are in stock, and "waitlist off" leaves the list. Offered when an order can't be filled.
+
+Example: waitlist {example}`,
+	},
+	CmdInfo: {
+		lines: []string{"info [topic] - Answers to common questions"},
+		adminLines: []string{
+			"info set <topic> <text> - Add or change an info answer",
+			"info delete <topic> - Remove an info answer",
+		},
+		detail: `info [topic] - Answers to common questions
+
+On its own, lists the topics there are answers for, like pickup times or how the eggs are handled. Add a topic to read its answer.
+
+Example: info pickup`,
+		adminDetail: `Admin usage:
+• info set <topic> <text> - Add or replace an answer, e.g. info set washed "No, they keep their natural bloom."
+• info delete <topic> - Remove an answer
+Topics are single words; answers are limited to 1000 characters.`,
+	},
+	CmdContact: {
+		lines: []string{"contact <message> - Send a message to the farmer"},
+		detail: `contact <message> - Send a message to the farmer
+
+For anything that isn't a command, like "I'll pick up late on Saturday". The message is passed on to the farmer, who may reply directly. Up to 3 messages an hour, 500 characters each.
+
+Example: contact I'll pick up late on Saturday`,
+	},
+	CmdLang: {
+		lines: []string{"lang [code|default] - Choose the language of replies"},
+		detail: `lang [code|default] - Choose the language of replies
+
+Replies to your commands, order confirmations and notifications come in the language you choose. Commands themselves stay in English. Use "lang" on its own to see your language and the ones available, and "lang default" to go back to the bot's default.
+
+Examples:
+• lang de
+• lang default`,
+	},
+	CmdHelp: {
+		lines: []string{"help - Show this message"},
+		detail: `help [command] - Show available commands
+
+On its own, lists every command. Add a command name for details and examples.
+
+Example: help order`,
+	},
+
+	CmdSell: {
+		lines: []string{"sell <npub> <qty> - Create order for a customer"},
+		detail: `sell <npub> <{quantities}> - Create an order for a customer
+
+Reserves eggs for a registered customer at the current price, e.g. for an in-person sale. The order starts unpaid; use markpaid once they pay.
+
+Example: sell npub1... {example}`,
+	},
+	CmdMarkpaid: {
+		lines: []string{"markpaid <order_id> - Mark pending order as paid"},
+		detail: `markpaid <order_id> - Mark a pending order as paid
+
+For payments received outside of zaps (cash, direct invoice).
+
+Example: markpaid 42`,
+	},
+	CmdDeliver: {
+		lines: []string{"deliver <order_id> - Fulfill a paid order"},
+		detail: `deliver <order_id> - Fulfill a paid order
+
+Marks a paid order as handed over to the customer.
+
+Example: deliver 42`,
+	},
+	CmdAdjust: {
+		lines: []string{"adjust <npub> <sats> - Adjust customer balance"},
+		detail: `adjust <npub> <sats> - Adjust a customer's balance
+
+Adds sats to a customer's balance, or removes them with a negative amount. Use for refunds and corrections.
+
+Examples:
+• adjust npub1... 3200
+• adjust npub1... -500`,
+	},
+	CmdOrders: {
+		lines: []string{"orders [status] [page] - List all orders"},
+		detail: `orders [status] [page] - List all orders
+
+Shows the most recent orders from every customer with their status, when they were placed and, for unpaid orders that expire, how long they have left. Give a status (pending, paid, fulfilled or cancelled) to list only those orders. Each page shows 50 orders; add a page number to see older ones.
+
+Example: orders pending 2`,
+	},
+	CmdTransactions: {
+		lines: []string{"transactions [npub] [n] - List recent payments and adjustments"},
+		detail: `transactions [npub] [n] - List the payment ledger
+
+Shows the last 20 transactions, most recent first: amount, sender, the order it paid for if any, and when. Each is labelled zap for a real zap, adjustment for an "adjust" entry, or synthetic for anything else the bot recorded. Give an npub to see only that sender's, or a number (up to 100) to see more.
+
+Examples:
+• transactions
+• transactions npub1...
+• transactions 50`,
+	},
+	CmdCustomers: {
+		lines: []string{"customers [page] - List registered customers, 25 per page"},
+		detail: `customers [page] - List registered customers, 25 per page
+
+Customers are listed in the order they registered. Page 1 is the default.
+
+Examples:
+• customers
+• customers 2`,
+	},
+	CmdAddCustomer: {
+		lines: []string{"addcustomer <npub> [name] - Register new customer"},
+		detail: `addcustomer <npub> [name] - Register a new customer
+
+Only registered customers can order. Everything after the npub is the optional display name. Adding a customer who is already registered with a name sets their name.
+
+Examples:
+• addcustomer npub1...
+• addcustomer npub1... Jane from the co-op`,
+	},
+	CmdRemoveCustomer: {
+		lines: []string{"removecustomer <npub> - Remove customer"},
+		detail: `removecustomer <npub> - Remove a customer
+
+Example: removecustomer npub1...`,
+	},
+	CmdContactLog: {
+		lines: []string{"contactlog [n] - Review messages sent with contact"},
+		detail: `contactlog [n] - Review messages from customers
+
+Lists the last 20 (or n, up to 100) messages customers sent with "contact", newest first, with who sent them and when. Every message is kept here even if relaying it to the admins failed.
+
+Example: contactlog 50`,
+	},
+	CmdInstructions: {
+		lines: []string{"instructions [set <text>|reset] - View or change pickup instructions"},
+		detail: `instructions - View or change pickup instructions
+
+The pickup text is appended to order and payment confirmations.
+• instructions - Show the current text
+• instructions set <text> - Replace it until reset; "instructions set" with no text turns it off
+• instructions reset - Go back to the text from the config file
+
+Example: instructions set Pickup: blue cooler at the end of the driveway, Sat 9-12`,
+	},
+	CmdAnnounce: {
+		lines: []string{"announce - Publish the available egg count publicly"},
+		detail: `announce - Publish the available egg count publicly
+
+Posts the number of available eggs for the bot's followers, as a note or a replaceable event depending on announce.mode in the config. Only the available count is shared, never reservations, sales or customers. Announcements are limited to one per announce.min_interval.
+
+Example: announce`,
+	},
+	CmdConfig: {
+		lines: []string{"config - Show the running configuration"},
+		detail: `config - Show the running configuration
+
+Shows the eggbot version, the bot's npub, each relay and whether it is connected, pricing, lightning addresses, how many admins there are and the database path. Keys and other secrets are never shown.
+
+Example: config`,
+	},
+	CmdBlock: {
+		lines: []string{"block <npub> - Ignore all DMs and zaps from an npub"},
+		detail: `block <npub> - Block an npub
+
+DMs from a blocked npub get no reply and zaps are not credited (they are still logged). A registered customer's account is frozen: orders and balance are kept, but they can't order and won't get stock notifications until unblocked.
+
+Example: block npub1...`,
+	},
+	CmdUnblock: {
+		lines: []string{"unblock <npub> - Remove an npub from the blocklist"},
+		detail: `unblock <npub> - Unblock an npub
+
+Example: unblock npub1...`,
+	},
+	CmdBlocked: {
+		lines: []string{"blocked - List blocked npubs"},
+		detail: `blocked - List blocked npubs
+
+Shows each blocked npub with when it was blocked and by which admin.
+
+Example: blocked`,
+	},
+	CmdAddAdmin: {
+		lines: []string{"addadmin <npub> - Grant admin privileges"},
+		detail: `addadmin <npub> - Grant admin privileges
+
+Takes effect immediately, no restart needed.
+
+Example: addadmin npub1...`,
+	},
+	CmdRemoveAdmin: {
+		lines: []string{"removeadmin <npub> - Revoke admin privileges"},
+		detail: `removeadmin <npub> - Revoke admin privileges
+
+Only admins added with "addadmin" can be removed this way; admins listed in the config file must be removed there. The last admin can't be removed.
+
+Example: removeadmin npub1...`,
+	},
+	CmdAdminPrefs: {
+		lines: []string{"adminprefs [kind on|off] - Choose which admin notifications you get"},
+		detail: `adminprefs [kind on|off] - Choose which admin notifications you get
+
+Without arguments, shows your settings. Kinds:
+• orders - New orders
+• payments - Payments received
+• inventory - Low inventory alerts
+
+Everything is on until you turn it off. Messages customers send with "contact" always go to every admin.
+
+Examples:
+• adminprefs
+• adminprefs orders off`,
+	},
+	CmdSales: {
+		lines: []string{"sales - Show sales today, this week, this month and in total"},
+		detail: `sales - Show sales today, this week, this month and in total
+
+Sums the sats for fulfilled orders by when they were placed. Weeks start on Monday, and days follow the bot's display.timezone.
+
+Example: sales`,
+	},
+	CmdTopCustomers: {
+		lines: []string{"topcustomers [n] [days]d - Rank customers by fulfilled sats"},
+		detail: `topcustomers [n] [days]d - Rank customers by fulfilled sats
+
+Lists the top 10 customers (or n, up to 25) by sats spent on fulfilled orders, with their egg and order counts. Add a period such as 30d to count only orders placed in the last 30 days.
+
+Examples:
+• topcustomers
+• topcustomers 5 30d`,
+	},
+	CmdConversion: {
+		lines: []string{"conversion - Show how many recent orders were paid and fulfilled"},
+		detail: `conversion - Show the order funnel for the last 30 days
+
+Counts the orders placed in the last 30 days and what share of them were paid, fulfilled, cancelled or expired, or are still awaiting payment.
+
+Example: conversion`,
+	},
+}
+
+// helpIn returns the help for name in lang: the translated summary and
+// details where helpTranslations has them, and the English admin text.
+func helpIn(lang, name string) (commandHelp, bool) {
+	help, ok := helpRegistry[name]
+	if translated, found := helpTranslations[lang][name]; ok && found {
+		help.lines, help.detail = translated.lines, translated.detail
+	}
+	return help, ok
+}
+
+// helpReplacer fills in the placeholders help text may use from cfg, with
+// prices written the way msgs' language writes them.
+func helpReplacer(cfg ExecuteConfig, msgs *messages.Catalog) *strings.Replacer {
+	allowed := cfg.allowedQuantities()
+	sizes := make([]string, len(allowed))
+	prices := make([]messages.PriceData, len(allowed))
+	for i, q := range allowed {
+		sizes[i] = strconv.Itoa(q)
+		prices[i] = messages.PriceData{Quantity: q, TotalSats: orderPrice(q, cfg.SatsPerHalfDozen), Last: i == len(allowed)-1}
+	}
+	return strings.NewReplacer(
+		"{quantities}", strings.Join(sizes, "|"),
+		"{prices}", msgs.Render(messages.HelpPrices, messages.PricesData{Prices: prices}),
+		"{example}", sizes[len(sizes)-1],
+	)
+}
+
+// HelpCmd returns available commands for the user, followed by the current
+// inventory count, in msgs' language. The count is left out if it can't be
+// read. Commands that cfg doesn't enable are left out.
+func HelpCmd(ctx context.Context, database *db.DB, isAdmin bool, cfg ExecuteConfig, msgs *messages.Catalog) Result {
+	var data messages.HelpData
+	for _, name := range customerCommands {
+		if !cfg.Enabled(name) {
+			continue
+		}
+		help, _ := helpIn(msgs.Language(), name)
+		data.Commands = append(data.Commands, help.lines...)
+	}
+	if available, err := database.GetInventory(ctx); err == nil {
+		data.Available, data.InventoryKnown = available, true
+	}
+
+	if isAdmin {
+		for _, name := range customerCommands {
+			data.AdminCommands = append(data.AdminCommands, helpRegistry[name].adminLines...)
+		}
+		for _, name := range adminCommands {
+			data.AdminCommands = append(data.AdminCommands, helpRegistry[name].lines...)
+		}
+	}
+
+	return Result{Message: helpReplacer(cfg, msgs).Replace(msgs.Render(messages.HelpOverview, data))}
+}
+
+// HelpTopicCmd returns the detailed help for a single command in msgs'
+// language. Admin-only commands are treated as unknown for non-admins, and
+// commands cfg doesn't enable for everyone.
+func HelpTopicCmd(topic string, isAdmin bool, cfg ExecuteConfig, msgs *messages.Catalog) Result {
+	name := strings.ToLower(topic)
+	cmd := &Command{Name: name}
+
+	help, ok := helpIn(msgs.Language(), name)
+	if !ok || (cmd.IsAdminCommand() && !isAdmin) || !cfg.Enabled(name) {
+		return Result{Error: errors.New(msgs.Render(messages.HelpUnknownTopic, messages.CommandData{Name: topic}))}
+	}
+
+	msg := help.detail
+	if isAdmin && help.adminDetail != "" {
+		msg += "\n\n" + help.adminDetail
+	}
+
+	return Result{Message: helpReplacer(cfg, msgs).Replace(msg)}
+}
@@ -0,0 +1,230 @@
+package commands
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+func TestHelpRegistry_CoversAllCommands(t *testing.T) {
+	all := append(append([]string{}, customerCommands...), adminCommands...)
+	for _, name := range all {
+		help, ok := helpRegistry[name]
+		if !ok {
+			t.Errorf("command %q has no help entry", name)
+			continue
+		}
+		if len(help.lines) == 0 {
+			t.Errorf("command %q has no overview line", name)
+		}
+		if help.detail == "" {
+			t.Errorf("command %q has no detailed help", name)
+		}
+	}
+
+	for name := range helpRegistry {
+		cmd := &Command{Name: name}
+		if !cmd.IsValid() {
+			t.Errorf("help entry %q is not a known command", name)
+		}
+	}
+}
+
+func TestHelpTranslations_CoverCustomerCommands(t *testing.T) {
+	for _, lang := range messages.Languages() {
+		if lang == messages.DefaultLanguage {
+			continue
+		}
+		for _, name := range customerCommands {
+			help := helpTranslations[lang][name]
+			if len(help.lines) != len(helpRegistry[name].lines) || help.detail == "" {
+				t.Errorf("command %q has no %s help", name, lang)
+			}
+		}
+		for name := range helpTranslations[lang] {
+			if !slices.Contains(customerCommands, name) {
+				t.Errorf("%s help for %q, which isn't a customer command", lang, name)
+			}
+		}
+	}
+}
+
+func TestHelp_Translated(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	de := messages.Default().In("de")
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200}
+
+	overview := HelpCmd(ctx, database, true, cfg, de).Message
+	for _, want := range []string{"Verfügbare Befehle:\n• inventory - Verfügbare Eier anzeigen", "• order <6|12> - Eier bestellen",
+		"Aktueller Bestand: 0 Eier verfügbar.", "Admin-Befehle:\n• inventory add <qty> - Add eggs to inventory", "Sende \"help <command>\" für Details."} {
+		if !strings.Contains(overview, want) {
+			t.Errorf("German help missing %q:\n%s", want, overview)
+		}
+	}
+
+	if msg := HelpTopicCmd("order", false, cfg, de).Message; !strings.Contains(msg, "Mengen: 6 Eier für 3.200 Sats oder 12 Eier für 6.400 Sats.") {
+		t.Errorf("German order help:\n%s", msg)
+	}
+	if msg := HelpTopicCmd("inventory", true, cfg, de).Message; !strings.HasPrefix(msg, "inventory - Verfügbare Eier anzeigen") || !strings.Contains(msg, "Admin usage:") {
+		t.Errorf("German inventory help for admins:\n%s", msg)
+	}
+	if result := HelpTopicCmd("frobnicate", false, cfg, de); result.Error == nil || !strings.HasPrefix(result.Error.Error(), "keine Hilfe zu \"frobnicate\"") {
+		t.Errorf("unknown topic = %v, want a German error", result.Error)
+	}
+}
+
+func TestHelpCmd_ListsEveryCommand(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	customer := HelpCmd(ctx, database, false, ExecuteConfig{Waitlist: true}, nil).Message
+	for _, name := range customerCommands {
+		if !strings.Contains(customer, "• "+name) {
+			t.Errorf("customer help missing %q", name)
+		}
+	}
+	for _, name := range adminCommands {
+		if strings.Contains(customer, "• "+name+" ") {
+			t.Errorf("customer help should not list admin command %q", name)
+		}
+	}
+
+	admin := HelpCmd(ctx, database, true, ExecuteConfig{}, nil).Message
+	for _, name := range adminCommands {
+		if !strings.Contains(admin, "• "+name) {
+			t.Errorf("admin help missing %q", name)
+		}
+	}
+}
+
+func TestHelpCmd_InventoryCount(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	if err := database.AddEggs(ctx, 6); err != nil {
+		t.Fatalf("adding eggs: %v", err)
+	}
+
+	result := HelpCmd(ctx, database, false, ExecuteConfig{}, nil)
+	if !strings.Contains(result.Message, "Current inventory: 6 eggs available.") {
+		t.Errorf("help missing the inventory count:\n%s", result.Message)
+	}
+
+	// A failed lookup leaves the line out rather than failing the help
+	_ = database.Close()
+	result = HelpCmd(ctx, database, false, ExecuteConfig{}, nil)
+	if result.Error != nil || strings.Contains(result.Message, "Current inventory") {
+		t.Errorf("expected help without the count, got %q, %v", result.Message, result.Error)
+	}
+}
+
+func TestHelp_ConfiguredQuantities(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3000, AllowedQuantities: []int{1, 5, 10}, Waitlist: true}
+
+	overview := HelpCmd(ctx, database, true, cfg, nil).Message
+	for _, want := range []string{"• order <1|5|10>", "• notify <1|5|10>", "• waitlist <1|5|10>"} {
+		if !strings.Contains(overview, want) {
+			t.Errorf("help missing %q:\n%s", want, overview)
+		}
+	}
+
+	tests := []struct {
+		topic string
+		want  []string
+	}{
+		{"order", []string{"order <1|5|10>", "Quantities: 1 egg for 500 sats, 5 eggs for 2500 sats or 10 eggs for 5000 sats.", "Example: order 10"}},
+		{"notify", []string{"notify <1|5|10>", "• notify 10"}},
+		{"waitlist", []string{"waitlist <1|5|10>", "Example: waitlist 10"}},
+		{"sell", []string{"sell <npub> <1|5|10>", "Example: sell npub1... 10"}},
+	}
+	for _, tt := range tests {
+		msg := HelpTopicCmd(tt.topic, true, cfg, nil).Message
+		for _, want := range tt.want {
+			if !strings.Contains(msg, want) {
+				t.Errorf("help %s missing %q:\n%s", tt.topic, want, msg)
+			}
+		}
+		if strings.Contains(msg, "6|12") || strings.Contains(msg, "dozen") {
+			t.Errorf("help %s still shows the default sizes:\n%s", tt.topic, msg)
+		}
+	}
+}
+
+func TestHelpTopicCmd(t *testing.T) {
+	tests := []struct {
+		name        string
+		topic       string
+		isAdmin     bool
+		wantErr     bool
+		msgContains []string
+		msgExcludes []string
+	}{
+		{
+			name:        "order shows quantities and prices",
+			topic:       "order",
+			msgContains: []string{"Quantities: 6 eggs for 3200 sats or 12 eggs for 6400 sats.", "Example: order 12"},
+		},
+		{
+			name:        "case insensitive",
+			topic:       "ORDER",
+			msgContains: []string{"order <6|12>"},
+		},
+		{
+			name:        "customer inventory hides admin usage",
+			topic:       "inventory",
+			msgExcludes: []string{"inventory add"},
+		},
+		{
+			name:        "admin inventory includes admin usage",
+			topic:       "inventory",
+			isAdmin:     true,
+			msgContains: []string{"inventory add <qty>", "inventory set <qty>"},
+		},
+		{
+			name:    "admin command hidden from customers",
+			topic:   "adjust",
+			wantErr: true,
+		},
+		{
+			name:        "admin command shown to admins",
+			topic:       "adjust",
+			isAdmin:     true,
+			msgContains: []string{"adjust npub1... -500"},
+		},
+		{
+			name:    "unknown command",
+			topic:   "scramble",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := HelpTopicCmd(tt.topic, tt.isAdmin, ExecuteConfig{SatsPerHalfDozen: 3200}, nil)
+			if tt.wantErr {
+				if result.Error == nil {
+					t.Errorf("expected error, got message %q", result.Message)
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			for _, want := range tt.msgContains {
+				if !strings.Contains(result.Message, want) {
+					t.Errorf("expected %q in message, got %q", want, result.Message)
+				}
+			}
+			for _, exclude := range tt.msgExcludes {
+				if strings.Contains(result.Message, exclude) {
+					t.Errorf("did not expect %q in message, got %q", exclude, result.Message)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package commands
+
+// helpTranslations holds the customer commands' help in every supported
+// language other than English, by language code and command. Only lines and
+// detail are translated; admins get their extra text in English. The
+// placeholders are those of helpRegistry, and command names and arguments
+// stay English since that is how commands are typed.
+var helpTranslations = map[string]map[string]commandHelp{
+	"de": {
+		CmdInventory: {
+			lines: []string{"inventory - Verfügbare Eier anzeigen"},
+			detail: `inventory - Verfügbare Eier anzeigen
+
+Zeigt, wie viele Eier gerade bestellt werden können.
+
+Beispiel: inventory`,
+		},
+		CmdOrder: {
+			lines: []string{"order <{quantities}> - Eier bestellen"},
+			detail: `order <{quantities}> - Eier bestellen
+
+Mengen: {prices}.
+
+Die Eier werden für dich reserviert, sobald du bestellst. Die Antwort enthält deine Bestellnummer und wie du bezahlst: eine Lightning-Rechnung und/oder einen Zap an dieses Profil. Sobald die Zahlung eingeht, wird die Bestellung automatisch als bezahlt markiert und du bekommst eine Bestätigung.
+
+Du kannst jeweils eine unbezahlte Bestellung haben; bezahle oder storniere sie, bevor du erneut bestellst.
+
+Beispiel: order {example}`,
+		},
+		CmdCancel: {
+			lines: []string{"cancel <order_id> - Offene Bestellung stornieren"},
+			detail: `cancel <order_id> - Offene Bestellung stornieren
+
+Gibt die Eier einer unbezahlten Bestellung wieder frei. Bezahlte Bestellungen können nicht storniert werden. Die Bestellnummer findest du in deiner Bestellbestätigung oder mit "history".
+
+Beispiel: cancel 42`,
+		},
+		CmdBalance: {
+			lines: []string{"balance - Dein Guthaben anzeigen"},
+			detail: `balance - Dein Guthaben anzeigen
+
+Zeigt dein Guthaben in Sats. Zaps erhöhen es und bezahlte Bestellungen werden davon abgezogen, sodass Überzahlungen auf deine nächste Bestellung angerechnet werden. Bestellungen, die auf Zahlung warten, werden als reserviert aufgeführt.
+
+Beispiel: balance`,
+		},
+		CmdHistory: {
+			lines: []string{"history [page] - Letzte Bestellungen anzeigen"},
+			detail: `history [page] - Letzte Bestellungen anzeigen
+
+Listet deine Bestellungen auf, die neuesten zuerst, mit Nummer, Menge, Preis, Status und Bestelldatum. Jede Seite zeigt 25 Bestellungen; gib eine Seitenzahl an, um ältere zu sehen.
+
+Beispiel: history 2`,
+		},
+		CmdNotify: {
+			lines: []string{
+				"notify <{quantities}> - Benachrichtigung, sobald so viele Eier da sind",
+				"notify off - Benachrichtigung abbestellen",
+			},
+			detail: `notify <{quantities}> - Benachrichtigung, sobald Eier verfügbar sind
+
+Schickt dir eine DM, sobald mindestens so viele Eier vorrätig sind. Mit "notify" allein siehst du deine aktuelle Benachrichtigung.
+
+Beispiele:
+• notify {example}
+• notify off`,
+		},
+		CmdWaitlist: {
+			lines: []string{"waitlist <{quantities}> - Auf die Warteliste, wenn die Eier ausgehen"},
+			detail: `waitlist <{quantities}> - Auf die Warteliste, wenn die Eier ausgehen
+
+Wie notify: Du bekommst eine DM, sobald mindestens so viele Eier vorrätig sind, und mit "waitlist off" verlässt du die Liste. Wird angeboten, wenn eine Bestellung nicht erfüllt werden kann.
+
+Beispiel: waitlist {example}`,
+		},
+		CmdInfo: {
+			lines: []string{"info [topic] - Antworten auf häufige Fragen"},
+			detail: `info [topic] - Antworten auf häufige Fragen
+
+Allein listet es die Themen auf, zu denen es Antworten gibt, etwa Abholzeiten oder wie die Eier behandelt werden. Gib ein Thema an, um die Antwort zu lesen.
+
+Beispiel: info pickup`,
+		},
+		CmdContact: {
+			lines: []string{"contact <message> - Dem Hof eine Nachricht schicken"},
+			detail: `contact <message> - Dem Hof eine Nachricht schicken
+
+Für alles, was kein Befehl ist, etwa "Ich hole am Samstag später ab". Die Nachricht wird an den Hof weitergeleitet, der dir direkt antworten kann. Bis zu 3 Nachrichten pro Stunde mit je 500 Zeichen.
+
+Beispiel: contact Ich hole am Samstag später ab`,
+		},
+		CmdLang: {
+			lines: []string{"lang [code|default] - Sprache der Antworten wählen"},
+			detail: `lang [code|default] - Sprache der Antworten wählen
+
+Antworten auf deine Befehle, Bestellbestätigungen und Benachrichtigungen kommen in der Sprache, die du wählst. Die Befehle selbst bleiben englisch. Mit "lang" allein siehst du deine Sprache und die verfügbaren, mit "lang default" kehrst du zur Voreinstellung des Bots zurück.
+
+Beispiele:
+• lang de
+• lang default`,
+		},
+		CmdHelp: {
+			lines: []string{"help - Diese Nachricht anzeigen"},
+			detail: `help [command] - Verfügbare Befehle anzeigen
+
+Allein listet es alle Befehle auf. Gib einen Befehlsnamen an, um Details und Beispiele zu sehen.
+
+Beispiel: help order`,
+		},
+	},
+	"es": {
+		CmdInventory: {
+			lines: []string{"inventory - Ver los huevos disponibles"},
+			detail: `inventory - Ver los huevos disponibles
+
+Muestra cuántos huevos se pueden pedir ahora mismo.
+
+Ejemplo: inventory`,
+		},
+		CmdOrder: {
+			lines: []string{"order <{quantities}> - Pedir huevos"},
+			detail: `order <{quantities}> - Pedir huevos
+
+Cantidades: {prices}.
+
+Los huevos quedan reservados en cuanto haces el pedido. La respuesta incluye tu número de pedido y cómo pagar: una factura Lightning y/o un zap a este perfil. Cuando llega el pago, el pedido se marca como pagado automáticamente y recibes una confirmación.
+
+Solo puedes tener un pedido sin pagar a la vez; págalo o cancélalo antes de volver a pedir.
+
+Ejemplo: order {example}`,
+		},
+		CmdCancel: {
+			lines: []string{"cancel <order_id> - Cancelar un pedido pendiente"},
+			detail: `cancel <order_id> - Cancelar un pedido pendiente
+
+Libera los huevos reservados por un pedido sin pagar. Los pedidos pagados no se pueden cancelar. Encontrarás el número de pedido en la confirmación o con "history".
+
+Ejemplo: cancel 42`,
+		},
+		CmdBalance: {
+			lines: []string{"balance - Ver tu saldo"},
+			detail: `balance - Ver tu saldo
+
+Muestra tu saldo en sats. Los zaps lo aumentan y los pedidos pagados se descuentan de él, así que lo que pagues de más se aplica a tu próximo pedido. Los pedidos pendientes de pago aparecen como reservados.
+
+Ejemplo: balance`,
+		},
+		CmdHistory: {
+			lines: []string{"history [page] - Ver pedidos recientes"},
+			detail: `history [page] - Ver pedidos recientes
+
+Lista tus pedidos, los más recientes primero, con su número, cantidad, precio, estado y fecha. Cada página muestra 25 pedidos; añade un número de página para ver los anteriores.
+
+Ejemplo: history 2`,
+		},
+		CmdNotify: {
+			lines: []string{
+				"notify <{quantities}> - Recibir un aviso cuando haya esa cantidad",
+				"notify off - Cancelar el aviso",
+			},
+			detail: `notify <{quantities}> - Recibir un aviso cuando haya huevos
+
+Te envía un DM cuando haya al menos esa cantidad de huevos. Usa "notify" solo para ver tu aviso actual.
+
+Ejemplos:
+• notify {example}
+• notify off`,
+		},
+		CmdWaitlist: {
+			lines: []string{"waitlist <{quantities}> - Apuntarse a la lista de espera cuando se acaban los huevos"},
+			detail: `waitlist <{quantities}> - Apuntarse a la lista de espera cuando se acaban los huevos
+
+Igual que notify: recibes un DM cuando haya al menos esa cantidad de huevos, y "waitlist off" te saca de la lista. Se ofrece cuando un pedido no se puede completar.
+
+Ejemplo: waitlist {example}`,
+		},
+		CmdInfo: {
+			lines: []string{"info [topic] - Respuestas a preguntas frecuentes"},
+			detail: `info [topic] - Respuestas a preguntas frecuentes
+
+Solo, lista los temas que tienen respuesta, como los horarios de recogida o cómo se tratan los huevos. Añade un tema para leer su respuesta.
+
+Ejemplo: info pickup`,
+		},
+		CmdContact: {
+			lines: []string{"contact <message> - Enviar un mensaje a la granja"},
+			detail: `contact <message> - Enviar un mensaje a la granja
+
+Para todo lo que no sea un comando, como "Recogeré tarde el sábado". El mensaje se reenvía a la granja, que puede responderte directamente. Hasta 3 mensajes por hora, de 500 caracteres cada uno.
+
+Ejemplo: contact Recogeré tarde el sábado`,
+		},
+		CmdLang: {
+			lines: []string{"lang [code|default] - Elegir el idioma de las respuestas"},
+			detail: `lang [code|default] - Elegir el idioma de las respuestas
+
+Las respuestas a tus comandos, las confirmaciones de pedido y los avisos llegan en el idioma que elijas. Los comandos siguen en inglés. Usa "lang" solo para ver tu idioma y los disponibles, y "lang default" para volver al predeterminado del bot.
+
+Ejemplos:
+• lang de
+• lang default`,
+		},
+		CmdHelp: {
+			lines: []string{"help - Mostrar este mensaje"},
+			detail: `help [command] - Ver los comandos disponibles
+
+Solo, lista todos los comandos. Añade el nombre de un comando para ver detalles y ejemplos.
+
+Ejemplo: help order`,
+		},
+	},
+}
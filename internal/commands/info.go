@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+// Limits for FAQ entries. The answer cap keeps a reply to one short DM.
+const (
+	maxInfoTopicLength  = 32
+	maxInfoAnswerLength = 1000
+)
+
+// InfoCmd answers common questions from the FAQ.
+// No args: list topics (all users)
+// <topic>: show the answer (all users)
+// set <topic> <text>: create or replace an answer (admin only)
+// delete <topic>: remove an answer (admin only)
+func InfoCmd(ctx context.Context, database *db.DB, args []string, isAdmin bool, msgs *messages.Catalog) Result {
+	if len(args) == 0 {
+		return listInfoTopics(ctx, database, msgs)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "set":
+		if !isAdmin {
+			return Result{Error: errors.New(msgs.Render(messages.AdminAccessRequired, nil))}
+		}
+		return infoSet(ctx, database, args[1:])
+
+	case "delete":
+		if !isAdmin {
+			return Result{Error: errors.New(msgs.Render(messages.AdminAccessRequired, nil))}
+		}
+		return infoDelete(ctx, database, args[1:])
+	}
+
+	topic := strings.ToLower(args[0])
+	answer, ok, err := database.GetFAQ(ctx, topic)
+	if err != nil {
+		return Result{Error: fmt.Errorf("looking up topic: %w", err)}
+	}
+	if !ok {
+		topics, err := infoTopics(ctx, database)
+		if err != nil {
+			return Result{Error: err}
+		}
+		return Result{Error: errors.New(msgs.Render(messages.InfoUnknownTopic, messages.InfoTopicData{Topic: args[0], Topics: strings.Join(topics, ", ")}))}
+	}
+	return Result{Message: answer}
+}
+
+// listInfoTopics returns the topics customers can ask about.
+func listInfoTopics(ctx context.Context, database *db.DB, msgs *messages.Catalog) Result {
+	topics, err := infoTopics(ctx, database)
+	if err != nil {
+		return Result{Error: err}
+	}
+	return Result{Message: msgs.Render(messages.InfoTopics, messages.InfoTopicsData{Topics: strings.Join(topics, ", ")})}
+}
+
+// infoTopics returns the names of every FAQ entry, sorted.
+func infoTopics(ctx context.Context, database *db.DB) ([]string, error) {
+	entries, err := database.ListFAQ(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("listing topics: %w", err)
+	}
+	topics := make([]string, len(entries))
+	for i, e := range entries {
+		topics[i] = e.Topic
+	}
+	return topics, nil
+}
+
+// normalizeInfoTopic lowercases a topic name and checks it is a single
+// token of letters, digits, "-" or "_" that isn't a subcommand.
+func normalizeInfoTopic(name string) (string, error) {
+	topic := strings.ToLower(name)
+	if topic == "" || utf8.RuneCountInString(topic) > maxInfoTopicLength {
+		return "", fmt.Errorf("topic must be 1 to %d characters", maxInfoTopicLength)
+	}
+	for _, r := range topic {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '_' {
+			return "", errors.New("topic must be a single word of letters, digits, - or _")
+		}
+	}
+	if topic == "set" || topic == "delete" {
+		return "", fmt.Errorf("%q is reserved for the info subcommand", topic)
+	}
+	return topic, nil
+}
+
+// infoSet stores the answer for a topic.
+func infoSet(ctx context.Context, database *db.DB, args []string) Result {
+	if len(args) < 2 {
+		return Result{Error: errors.New("usage: info set <topic> <text>")}
+	}
+
+	topic, err := normalizeInfoTopic(args[0])
+	if err != nil {
+		return Result{Error: err}
+	}
+	answer := strings.TrimSpace(strings.Join(args[1:], " "))
+	if answer == "" {
+		return Result{Error: errors.New("usage: info set <topic> <text>")}
+	}
+	if n := utf8.RuneCountInString(answer); n > maxInfoAnswerLength {
+		return Result{Error: fmt.Errorf("answer is %d characters, the limit is %d", n, maxInfoAnswerLength)}
+	}
+
+	if err := database.SetFAQ(ctx, topic, answer); err != nil {
+		return Result{Error: fmt.Errorf("saving topic: %w", err)}
+	}
+	return Result{Message: fmt.Sprintf("Info topic %q saved:\n%s", topic, answer)}
+}
+
+// infoDelete removes a topic.
+func infoDelete(ctx context.Context, database *db.DB, args []string) Result {
+	if len(args) != 1 {
+		return Result{Error: errors.New("usage: info delete <topic>")}
+	}
+
+	topic := strings.ToLower(args[0])
+	err := database.DeleteFAQ(ctx, topic)
+	if errors.Is(err, db.ErrFAQNotFound) {
+		return Result{Error: fmt.Errorf("unknown topic %q", args[0])}
+	}
+	if err != nil {
+		return Result{Error: fmt.Errorf("deleting topic: %w", err)}
+	}
+	return Result{Message: fmt.Sprintf("Info topic %q deleted.", topic)}
+}
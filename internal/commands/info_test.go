@@ -0,0 +1,85 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInfoCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	if result := InfoCmd(ctx, database, nil, false, nil); result.Message != "No info topics yet." {
+		t.Errorf("empty list = %q, %v", result.Message, result.Error)
+	}
+	result := InfoCmd(ctx, database, []string{"washed"}, false, nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), "no info topics yet") {
+		t.Errorf("unknown topic with none set = %v", result.Error)
+	}
+
+	// Admins keep the answers; topics are stored lowercase
+	result = InfoCmd(ctx, database, []string{"set", "Washed", "No,", "they", "keep", "their", "bloom."}, true, nil)
+	if result.Error != nil || !strings.Contains(result.Message, `"washed" saved`) {
+		t.Fatalf("info set = %q, %v", result.Message, result.Error)
+	}
+	_ = InfoCmd(ctx, database, []string{"set", "pickup", "Blue cooler by the gate."}, true, nil)
+
+	result = InfoCmd(ctx, database, nil, false, nil)
+	if result.Message != "Info topics: pickup, washed\nSend \"info <topic>\" for the answer." {
+		t.Errorf("list = %q", result.Message)
+	}
+	if result := InfoCmd(ctx, database, []string{"WASHED"}, false, nil); result.Message != "No, they keep their bloom." {
+		t.Errorf("answer = %q, %v", result.Message, result.Error)
+	}
+
+	// An unknown topic lists the ones there are
+	result = InfoCmd(ctx, database, []string{"organic"}, false, nil)
+	if result.Error == nil || result.Error.Error() != `unknown topic "organic", available: pickup, washed` {
+		t.Errorf("unknown topic = %v", result.Error)
+	}
+
+	result = InfoCmd(ctx, database, []string{"delete", "pickup"}, true, nil)
+	if result.Error != nil || !strings.Contains(result.Message, `"pickup" deleted`) {
+		t.Errorf("info delete = %q, %v", result.Message, result.Error)
+	}
+	if result := InfoCmd(ctx, database, []string{"delete", "pickup"}, true, nil); result.Error == nil {
+		t.Error("expected an error deleting a missing topic")
+	}
+}
+
+func TestInfoCmd_Rejects(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	tests := []struct {
+		name    string
+		args    []string
+		isAdmin bool
+		wantErr string
+	}{
+		{"customer set", []string{"set", "pickup", "Anywhere"}, false, "admin access required"},
+		{"customer delete", []string{"delete", "pickup"}, false, "admin access required"},
+		{"no answer", []string{"set", "pickup"}, true, "usage: info set"},
+		{"blank answer", []string{"set", "pickup", " "}, true, "usage: info set"},
+		{"two words", []string{"set", "pick up", "Anywhere"}, true, "single word"},
+		{"punctuation", []string{"set", "pickup?", "Anywhere"}, true, "single word"},
+		{"long topic", []string{"set", strings.Repeat("a", maxInfoTopicLength+1), "Anywhere"}, true, "1 to 32 characters"},
+		{"reserved topic", []string{"set", "Delete", "Anywhere"}, true, "reserved"},
+		{"long answer", []string{"set", "pickup", strings.Repeat("é", maxInfoAnswerLength+1)}, true, "the limit is 1000"},
+		{"delete usage", []string{"delete"}, true, "usage: info delete"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := InfoCmd(ctx, database, tt.args, tt.isAdmin, nil)
+			if result.Error == nil || !strings.Contains(result.Error.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, result.Error)
+			}
+		})
+	}
+
+	// A full-length answer is fine
+	if result := InfoCmd(ctx, database, []string{"set", "pickup", strings.Repeat("é", maxInfoAnswerLength)}, true, nil); result.Error != nil {
+		t.Errorf("answer at the limit: %v", result.Error)
+	}
+}
@@ -0,0 +1,71 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// PickupInstructions returns the pickup text appended to order confirmations.
+// An admin override stored with "instructions set" wins over the configured text.
+// Lookup failures fall back to the configured text so confirmations still go out.
+func PickupInstructions(ctx context.Context, database *db.DB, configured string) string {
+	value, ok, err := database.GetSetting(ctx, db.SettingPickupInstructions)
+	if err != nil {
+		slog.Error("loading pickup instructions failed", "error", err)
+		return configured
+	}
+	if ok {
+		return value
+	}
+	return configured
+}
+
+// appendPickupInstructions adds pickup text to a confirmation message, if any.
+func appendPickupInstructions(msg, instructions string) string {
+	if instructions == "" {
+		return msg
+	}
+	return msg + "\n\n" + instructions
+}
+
+// InstructionsCmd views or updates the pickup instructions (admin only).
+// No args: show current text
+// set <text>: override the configured text (empty text disables it)
+// reset: drop the override and use the configured text again
+func InstructionsCmd(ctx context.Context, database *db.DB, args []string, configured string) Result {
+	if len(args) == 0 {
+		current := PickupInstructions(ctx, database, configured)
+		if current == "" {
+			return Result{Message: "No pickup instructions set. Use: instructions set <text>"}
+		}
+		return Result{Message: "Pickup instructions:\n" + current}
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "set":
+		text := strings.TrimSpace(strings.Join(args[1:], " "))
+		if err := database.SetSetting(ctx, db.SettingPickupInstructions, text); err != nil {
+			return Result{Error: fmt.Errorf("saving instructions: %w", err)}
+		}
+		if text == "" {
+			return Result{Message: "Pickup instructions disabled."}
+		}
+		return Result{Message: "Pickup instructions updated:\n" + text}
+
+	case "reset":
+		if err := database.DeleteSetting(ctx, db.SettingPickupInstructions); err != nil {
+			return Result{Error: fmt.Errorf("resetting instructions: %w", err)}
+		}
+		if configured == "" {
+			return Result{Message: "Pickup instructions reset (none configured)."}
+		}
+		return Result{Message: "Pickup instructions reset to configured text:\n" + configured}
+
+	default:
+		return Result{Error: fmt.Errorf("unknown subcommand: %s (use set or reset)", args[0])}
+	}
+}
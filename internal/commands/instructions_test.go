@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestInstructionsCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	configured := "Pickup: porch fridge"
+
+	// Config text applies until overridden
+	if got := PickupInstructions(ctx, database, configured); got != configured {
+		t.Errorf("PickupInstructions() = %q, want configured %q", got, configured)
+	}
+	result := InstructionsCmd(ctx, database, nil, configured)
+	if !strings.Contains(result.Message, configured) {
+		t.Errorf("expected configured text, got %q", result.Message)
+	}
+
+	// Override with quoted text
+	cmd := Parse(`instructions set "Pickup: blue cooler at the end of the driveway, Sat 9-12"`)
+	result = InstructionsCmd(ctx, database, cmd.Args, configured)
+	if result.Error != nil {
+		t.Fatalf("unexpected error: %v", result.Error)
+	}
+	want := "Pickup: blue cooler at the end of the driveway, Sat 9-12"
+	if got := PickupInstructions(ctx, database, configured); got != want {
+		t.Errorf("PickupInstructions() = %q, want %q", got, want)
+	}
+
+	// Unquoted words are joined
+	cmd = Parse("instructions set Porch fridge, any time")
+	_ = InstructionsCmd(ctx, database, cmd.Args, configured)
+	if got := PickupInstructions(ctx, database, configured); got != "Porch fridge, any time" {
+		t.Errorf("PickupInstructions() = %q, want joined text", got)
+	}
+
+	// Empty text disables instructions even though config has some
+	result = InstructionsCmd(ctx, database, []string{"set"}, configured)
+	if !strings.Contains(result.Message, "disabled") {
+		t.Errorf("expected disabled message, got %q", result.Message)
+	}
+	if got := PickupInstructions(ctx, database, configured); got != "" {
+		t.Errorf("PickupInstructions() = %q, want empty", got)
+	}
+	result = InstructionsCmd(ctx, database, nil, configured)
+	if !strings.Contains(result.Message, "No pickup instructions") {
+		t.Errorf("expected no-instructions message, got %q", result.Message)
+	}
+
+	// Reset returns to config text
+	_ = InstructionsCmd(ctx, database, []string{"reset"}, configured)
+	if got := PickupInstructions(ctx, database, configured); got != configured {
+		t.Errorf("PickupInstructions() after reset = %q, want %q", got, configured)
+	}
+
+	// Unknown subcommand
+	result = InstructionsCmd(ctx, database, []string{"delete"}, configured)
+	if result.Error == nil {
+		t.Error("expected error for unknown subcommand")
+	}
+}
+
+func TestInstructionsCmd_AdminOnly(t *testing.T) {
+	cmd := &Command{Name: CmdInstructions}
+	if !cmd.IsAdminCommand() {
+		t.Error("instructions should be an admin command")
+	}
+}
@@ -0,0 +1,72 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+// MessagesFor returns msgs in the language the customer chose with the lang
+// command, or msgs itself if they haven't chosen one or aren't a customer.
+func MessagesFor(ctx context.Context, database *db.DB, npub string, msgs *messages.Catalog) *messages.Catalog {
+	lang, err := database.GetCustomerLang(ctx, npub)
+	if err != nil {
+		if !errors.Is(err, db.ErrCustomerNotFound) {
+			slog.Warn("looking up customer language failed", "npub", npub, "error", err)
+		}
+		return msgs
+	}
+	return msgs.In(lang)
+}
+
+// LangCmd shows or sets the language a customer gets replies in. msgs is
+// the catalog in the configured language, which "lang default" goes back to.
+// Args: [code|default]
+func LangCmd(ctx context.Context, database *db.DB, senderNpub string, args []string, msgs *messages.Catalog) Result {
+	chosen, err := database.GetCustomerLang(ctx, senderNpub)
+	if err != nil {
+		return Result{Error: fmt.Errorf("looking up customer: %w", err)}
+	}
+
+	// Until a new language is set, replies are in the current one
+	current := msgs.In(chosen)
+	if len(args) == 0 {
+		lang := current.Language()
+		return Result{Message: current.Render(messages.LanguageStatus, messages.LanguageStatusData{
+			Code:      lang,
+			Name:      messages.LanguageName(lang),
+			Default:   chosen == "",
+			Available: languageList(),
+		})}
+	}
+
+	lang := strings.ToLower(args[0])
+	if lang == "default" {
+		lang = ""
+	} else if !messages.Supported(lang) {
+		return Result{Error: errors.New(current.Render(messages.LanguageUnknown, messages.LanguageUnknownData{Requested: args[0], Available: languageList()}))}
+	}
+
+	if err := database.UpdateCustomerLang(ctx, senderNpub, lang); err != nil {
+		return Result{Error: fmt.Errorf("setting language: %w", err)}
+	}
+
+	// Confirm in the language the customer will get from now on
+	msgs = msgs.In(lang)
+	lang = msgs.Language()
+	return Result{Message: msgs.Render(messages.LanguageSet, messages.LanguageData{Code: lang, Name: messages.LanguageName(lang)})}
+}
+
+// languageList renders the supported languages, e.g. "de (Deutsch), en (English)".
+func languageList() string {
+	langs := messages.Languages()
+	for i, lang := range langs {
+		langs[i] = fmt.Sprintf("%s (%s)", lang, messages.LanguageName(lang))
+	}
+	return strings.Join(langs, ", ")
+}
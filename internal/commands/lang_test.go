@@ -0,0 +1,120 @@
+package commands
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/buildtall-systems/eggbot/internal/messages"
+)
+
+func TestLangCmd(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	result := LangCmd(ctx, database, testCustomerNpub, nil, nil)
+	if result.Error != nil || !strings.Contains(result.Message, "Language: English (en), the default") ||
+		!strings.Contains(result.Message, "de (Deutsch)") {
+		t.Errorf("lang = %q, %v", result.Message, result.Error)
+	}
+
+	result = LangCmd(ctx, database, testCustomerNpub, []string{"DE"}, nil)
+	if result.Error != nil || result.Message != "Antworten kommen jetzt auf Deutsch." {
+		t.Errorf("lang de = %q, %v; want the German confirmation", result.Message, result.Error)
+	}
+	if lang, _ := database.GetCustomerLang(ctx, testCustomerNpub); lang != "de" {
+		t.Errorf("stored language = %q, want de", lang)
+	}
+	// Replies are now German
+	if result := LangCmd(ctx, database, testCustomerNpub, nil, nil); !strings.HasPrefix(result.Message, "Sprache: Deutsch (de)\nVerfügbar: ") {
+		t.Errorf("lang = %q, want the chosen language in German", result.Message)
+	}
+
+	result = LangCmd(ctx, database, testCustomerNpub, []string{"fr"}, nil)
+	if result.Error == nil || !strings.Contains(result.Error.Error(), `unbekannte Sprache "fr"`) {
+		t.Errorf("lang fr = %v, want an unknown language error", result.Error)
+	}
+
+	// Going back to the default confirms in the configured language
+	result = LangCmd(ctx, database, testCustomerNpub, []string{"default"}, messages.Default().In("es"))
+	if result.Error != nil || result.Message != "Las respuestas serán ahora en Español." {
+		t.Errorf("lang default = %q, %v", result.Message, result.Error)
+	}
+	if lang, _ := database.GetCustomerLang(ctx, testCustomerNpub); lang != "" {
+		t.Errorf("stored language = %q, want it cleared", lang)
+	}
+
+	if result := LangCmd(ctx, database, testUnknownNpub, []string{"de"}, nil); result.Error == nil {
+		t.Error("expected an error for someone who isn't a customer")
+	}
+}
+
+func TestExecute_CustomerLanguage(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 24)
+	if err := database.UpdateCustomerLang(ctx, testCustomerNpub, "de"); err != nil {
+		t.Fatalf("UpdateCustomerLang: %v", err)
+	}
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200, BotNpub: "npub1bot"}
+
+	// Command names stay English, the reply is German
+	result := Execute(ctx, database, &Command{Name: CmdOrder, Args: []string{"12"}}, testCustomerNpub, cfg)
+	if result.Error != nil {
+		t.Fatalf("order: %v", result.Error)
+	}
+	if want := "Bestellung 1: 12 Eier für 6.400 Sats reserviert.\n\nZum Bezahlen dieses Profil zappen:\nnostr:npub1bot"; !strings.HasPrefix(result.Message, want) {
+		t.Errorf("order confirmation = %q, want it to start with %q", result.Message, want)
+	}
+
+	// Dates in the history are written the German way
+	orders, _ := database.GetCustomerOrdersByOffset(ctx, 1, 1, 0)
+	result = Execute(ctx, database, &Command{Name: CmdHistory}, testCustomerNpub, cfg)
+	if want := orders[0].CreatedAt.UTC().Format("2.1. 15:04"); !strings.Contains(result.Message, want) {
+		t.Errorf("history = %q, want the date as %q", result.Message, want)
+	}
+	if want := "Letzte Bestellungen:\n• #1: 12 Eier, 6.400 Sats (pending) - "; !strings.HasPrefix(result.Message, want) {
+		t.Errorf("history = %q, want it to start with %q", result.Message, want)
+	}
+
+	// So are balances, usage errors and notification status
+	result = Execute(ctx, database, &Command{Name: CmdBalance}, testCustomerNpub, cfg)
+	if want := "Noch keine Zahlungen erhalten.\nReserviert: 6.400 Sats in offenen Bestellungen"; result.Message != want {
+		t.Errorf("balance = %q, want %q", result.Message, want)
+	}
+	result = Execute(ctx, database, &Command{Name: CmdCancel}, testCustomerNpub, cfg)
+	if result.Error == nil || result.Error.Error() != "Verwendung: cancel <order_id>" {
+		t.Errorf("cancel = %v, want the German usage", result.Error)
+	}
+	_ = Execute(ctx, database, &Command{Name: CmdNotify, Args: []string{"6"}}, testCustomerNpub, cfg)
+	result = Execute(ctx, database, &Command{Name: CmdNotify}, testCustomerNpub, cfg)
+	if want := "Du wirst benachrichtigt, sobald 6 Eier verfügbar sind.\nAntworte mit 'notify off', um das abzubestellen."; result.Message != want {
+		t.Errorf("notify = %q, want %q", result.Message, want)
+	}
+
+	// Someone who hasn't chosen a language gets the configured one
+	_, _ = database.CreateCustomer(ctx, customerNpub, "")
+	cfg.Messages = messages.Default().In("es")
+	result = Execute(ctx, database, &Command{Name: CmdInventory}, customerNpub, cfg)
+	if result.Message != "12 huevos disponibles." {
+		t.Errorf("inventory = %q, want the configured Spanish", result.Message)
+	}
+}
+
+func TestExecute_RepliesAlreadyLookedUp(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+	_ = database.AddEggs(ctx, 12)
+	if err := database.UpdateCustomerLang(ctx, testCustomerNpub, "de"); err != nil {
+		t.Fatalf("UpdateCustomerLang: %v", err)
+	}
+
+	// The caller's catalog is used as is, without looking the language up again
+	cfg := ExecuteConfig{Replies: messages.Default().In("es")}
+	if result := Execute(ctx, database, &Command{Name: CmdInventory}, testCustomerNpub, cfg); result.Message != "12 huevos disponibles." {
+		t.Errorf("inventory = %q, want the caller's Spanish", result.Message)
+	}
+}
@@ -0,0 +1,35 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// pageCount returns how many pages of size are needed for total items.
+func pageCount(total, size int) int {
+	return (total + size - 1) / size
+}
+
+// parsePage parses a page number argument; pages start at 1.
+func parsePage(arg string) (int, bool) {
+	page, err := strconv.Atoi(arg)
+	if err != nil || page < 1 {
+		return 0, false
+	}
+	return page, true
+}
+
+// pageHeader introduces one page of a listing of total orders, e.g.
+// "Page 2 of 5 (112 orders)".
+func pageHeader(page, pages, total int) string {
+	return fmt.Sprintf("Page %d of %d (%d orders)", page, pages, total)
+}
+
+// pageOutOfRange answers a request for a page past the last one of a listing
+// of total items, e.g. "orders".
+func pageOutOfRange(page, pages, total int, items string) Result {
+	if pages == 1 {
+		return Result{Message: fmt.Sprintf("Page %d is out of range: there is only 1 page (%d %s).", page, total, items)}
+	}
+	return Result{Message: fmt.Sprintf("Page %d is out of range: there are %d pages (%d %s).", page, pages, total, items)}
+}
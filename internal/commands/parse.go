@@ -1,13 +1,16 @@
 package commands
 
 import (
+	"slices"
 	"strings"
+	"unicode"
 )
 
 // Command represents a parsed user command.
 type Command struct {
 	Name string   // Command name (lowercase)
 	Args []string // Arguments after the command name
+	Text string   // The whole message without client metadata, as written
 }
 
 // Known command names
@@ -20,6 +23,10 @@ const (
 	CmdHistory   = "history"
 	CmdHelp      = "help"
 	CmdNotify    = "notify"
+	CmdLang      = "lang"
+	CmdWaitlist  = "waitlist"
+	CmdInfo      = "info"
+	CmdContact   = "contact"
 
 	// Admin commands
 	CmdDeliver        = "deliver"
@@ -31,11 +38,36 @@ const (
 	CmdRemoveCustomer = "removecustomer"
 	CmdSales          = "sales"
 	CmdSell           = "sell"
+	CmdInstructions   = "instructions"
+	CmdBlock          = "block"
+	CmdUnblock        = "unblock"
+	CmdBlocked        = "blocked"
+	CmdAddAdmin       = "addadmin"
+	CmdRemoveAdmin    = "removeadmin"
+	CmdTransactions   = "transactions"
+	CmdTopCustomers   = "topcustomers"
+	CmdConversion     = "conversion"
+	CmdAnnounce       = "announce"
+	CmdContactLog     = "contactlog"
+	CmdConfig         = "config"
+	CmdAdminPrefs     = "adminprefs"
 )
 
+// customerCommands are available to every registered customer, in help order.
+var customerCommands = []string{CmdInventory, CmdOrder, CmdCancel, CmdBalance, CmdHistory, CmdNotify, CmdWaitlist, CmdInfo, CmdContact, CmdLang, CmdHelp}
+
+// adminCommands require admin privileges, in help order.
+var adminCommands = []string{CmdSell, CmdMarkpaid, CmdDeliver, CmdAdjust, CmdOrders, CmdTransactions, CmdCustomers, CmdAddCustomer, CmdRemoveCustomer, CmdContactLog, CmdSales, CmdTopCustomers, CmdConversion, CmdInstructions, CmdAnnounce, CmdBlock, CmdUnblock, CmdBlocked, CmdAddAdmin, CmdRemoveAdmin, CmdAdminPrefs, CmdConfig}
+
+// botName is the name users may address the bot by, e.g. "eggbot order 6".
+const botName = "eggbot"
+
 // Parse extracts a command from message content.
 // Returns nil if the message is empty or contains only whitespace.
-// Strips markdown comment prefixes that some clients (e.g. Amethyst) add.
+// Arguments are split shell-style, so quoted spans stay together.
+// Strips markdown comment prefixes that some clients (e.g. Amethyst) add,
+// leading mentions of the bot ("eggbot", "@npub1...", "nostr:npub1...")
+// and a slash before the command name ("/order 6").
 func Parse(content string) *Command {
 	content = stripMarkdownComments(content)
 	content = strings.TrimSpace(content)
@@ -43,51 +75,153 @@ func Parse(content string) *Command {
 		return nil
 	}
 
-	parts := strings.Fields(content)
+	parts := splitArgs(content)
+	for len(parts) > 0 && isAddressPrefix(parts[0]) {
+		parts = parts[1:]
+	}
 	if len(parts) == 0 {
 		return nil
 	}
 
+	name := strings.TrimPrefix(parts[0], "/")
+	args := parts[1:]
+
+	// A lone "/" before the command ("/ order 6")
+	if name == "" {
+		if len(args) == 0 {
+			return nil
+		}
+		name, args = args[0], args[1:]
+	}
+
 	return &Command{
-		Name: strings.ToLower(parts[0]),
-		Args: parts[1:],
+		Name: strings.ToLower(name),
+		Args: args,
+		Text: content,
+	}
+}
+
+// splitArgs splits content on whitespace, keeping single- or double-quoted
+// spans together as one argument ("Jane from the co-op"). A quote only opens
+// at the start of a token and only closes before whitespace or the end, so
+// apostrophes in words like "don't" stay literal. Inside quotes a backslash
+// escapes a quote or another backslash. If a quote is left unterminated the
+// content falls back to plain whitespace splitting.
+func splitArgs(content string) []string {
+	runes := []rune(content)
+
+	var args []string
+	var current strings.Builder
+	var quote rune   // active quote character, 0 when outside quotes
+	inToken := false // current holds a token (possibly an empty quoted one)
+
+	isSpace := func(i int) bool {
+		return i >= len(runes) || unicode.IsSpace(runes[i])
+	}
+
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case quote != 0 && r == '\\' && i+1 < len(runes) &&
+			(runes[i+1] == quote || runes[i+1] == '\\'):
+			i++
+			current.WriteRune(runes[i])
+
+		case quote != 0 && r == quote && isSpace(i+1):
+			quote = 0
+
+		case quote != 0:
+			current.WriteRune(r)
+
+		case unicode.IsSpace(r):
+			if inToken {
+				args = append(args, current.String())
+				current.Reset()
+				inToken = false
+			}
+
+		case (r == '"' || r == '\'') && !inToken:
+			quote = r
+			inToken = true
+
+		default:
+			current.WriteRune(r)
+			inToken = true
+		}
 	}
+
+	if quote != 0 {
+		return strings.Fields(content)
+	}
+	if inToken {
+		args = append(args, current.String())
+	}
+	return args
 }
 
-// stripMarkdownComments removes markdown reference-style link definitions
-// that some Nostr clients prepend to messages, e.g. "[//]: # (nip18)"
+// isAddressPrefix reports whether a token addresses the bot rather than
+// being part of the command: its name or an npub mention, optionally
+// followed by ":" or "," as in "eggbot: order 6".
+func isAddressPrefix(token string) bool {
+	token = strings.TrimRight(token, ":,")
+	if strings.EqualFold(token, botName) {
+		return true
+	}
+	return strings.HasPrefix(token, "nostr:npub1") || strings.HasPrefix(token, "@npub1")
+}
+
+// stripMarkdownComments removes the metadata some Nostr clients add around a
+// message so only the command is left:
+//   - markdown comment lines, e.g. "[//]: # (nip18)" (Amethyst)
+//   - lines of only note references, e.g. "nostr:note1..." (Primal)
+//   - "> " blockquotes of the message being replied to
+//   - a "---" line followed by a "Posted from ..." footer, and all after it
+//
+// Stripping twice gives the same result as stripping once.
 func stripMarkdownComments(content string) string {
-	lines := strings.Split(content, "\n")
 	var result []string
-	for _, line := range lines {
+	for line := range strings.SplitSeq(content, "\n") {
 		trimmed := strings.TrimSpace(line)
-		// Skip markdown comment lines: [//]: # (something) or [//]: ...
-		if strings.HasPrefix(trimmed, "[//]:") {
+		if strings.HasPrefix(trimmed, "[//]:") || strings.HasPrefix(trimmed, ">") || isNoteReferenceLine(trimmed) {
 			continue
 		}
 		result = append(result, line)
 	}
+
+	// Cut at the first footer so nothing left can form another one
+	for i := 0; i+1 < len(result); i++ {
+		if strings.TrimSpace(result[i]) == "---" && strings.HasPrefix(strings.TrimSpace(result[i+1]), "Posted from") {
+			result = result[:i]
+			break
+		}
+	}
 	return strings.Join(result, "\n")
 }
 
-// IsCustomerCommand returns true if the command is available to customers.
-func (c *Command) IsCustomerCommand() bool {
-	switch c.Name {
-	case CmdInventory, CmdOrder, CmdCancel, CmdBalance, CmdHistory, CmdHelp, CmdNotify:
-		return true
-	default:
+// isNoteReferenceLine reports whether a trimmed line holds nothing but
+// nostr: references to events, as clients add when quoting a note.
+func isNoteReferenceLine(trimmed string) bool {
+	fields := strings.Fields(trimmed)
+	if len(fields) == 0 {
 		return false
 	}
+	for _, f := range fields {
+		if !strings.HasPrefix(f, "nostr:note1") && !strings.HasPrefix(f, "nostr:nevent1") && !strings.HasPrefix(f, "nostr:naddr1") {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCustomerCommand returns true if the command is available to customers.
+func (c *Command) IsCustomerCommand() bool {
+	return slices.Contains(customerCommands, c.Name)
 }
 
 // IsAdminCommand returns true if the command requires admin privileges.
 func (c *Command) IsAdminCommand() bool {
-	switch c.Name {
-	case CmdDeliver, CmdMarkpaid, CmdAdjust, CmdOrders, CmdCustomers, CmdAddCustomer, CmdRemoveCustomer, CmdSales, CmdSell:
-		return true
-	default:
-		return false
-	}
+	return slices.Contains(adminCommands, c.Name)
 }
 
 // IsValid returns true if the command name is recognized.
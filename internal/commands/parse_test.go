@@ -77,9 +77,9 @@ func TestParse(t *testing.T) {
 			wantArgs: []string{},
 		},
 		{
-			name:     "markdown comment only returns nil",
-			input:    "[//]: # (nip18)",
-			wantNil:  true,
+			name:    "markdown comment only returns nil",
+			input:   "[//]: # (nip18)",
+			wantNil: true,
 		},
 		{
 			name:     "markdown comment with leading whitespace",
@@ -87,6 +87,225 @@ func TestParse(t *testing.T) {
 			wantName: "balance",
 			wantArgs: []string{},
 		},
+		{
+			name:     "slash command",
+			input:    "/order 6",
+			wantName: "order",
+			wantArgs: []string{"6"},
+		},
+		{
+			name:     "detached slash",
+			input:    "/ order 6",
+			wantName: "order",
+			wantArgs: []string{"6"},
+		},
+		{
+			name:    "slash only returns nil",
+			input:   "/",
+			wantNil: true,
+		},
+		{
+			name:     "bot name prefix",
+			input:    "eggbot order 6",
+			wantName: "order",
+			wantArgs: []string{"6"},
+		},
+		{
+			name:     "bot name prefix case insensitive with colon",
+			input:    "EggBot: balance",
+			wantName: "balance",
+			wantArgs: []string{},
+		},
+		{
+			name:    "bot name only returns nil",
+			input:   "eggbot",
+			wantNil: true,
+		},
+		{
+			name:     "nostr: npub mention",
+			input:    "nostr:npub1srcs6w4mmkjdkm6n4dh69smakmauv09vxtfrap73gr8ampwzcc8sdutrts order 12",
+			wantName: "order",
+			wantArgs: []string{"12"},
+		},
+		{
+			name:     "@npub mention",
+			input:    "@npub1srcs6w4mmkjdkm6n4dh69smakmauv09vxtfrap73gr8ampwzcc8sdutrts, history",
+			wantName: "history",
+			wantArgs: []string{},
+		},
+		{
+			name:     "mention then bot name then slash",
+			input:    "nostr:npub1srcs6w4mmkjdkm6n4dh69smakmauv09vxtfrap73gr8ampwzcc8sdutrts eggbot /cancel 3",
+			wantName: "cancel",
+			wantArgs: []string{"3"},
+		},
+		{
+			name:     "markdown comment then slash command",
+			input:    "[//]: # (nip18)\n/inventory add 8",
+			wantName: "inventory",
+			wantArgs: []string{"add", "8"},
+		},
+		{
+			name:     "markdown comment then mention and bot name",
+			input:    "[//]: # (nip18)\n@npub1srcs6w4mmkjdkm6n4dh69smakmauv09vxtfrap73gr8ampwzcc8sdutrts eggbot help",
+			wantName: "help",
+			wantArgs: []string{},
+		},
+		{
+			name:     "strips Primal note reference line",
+			input:    "nostr:note1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqvtn7w0\norder 6",
+			wantName: "order",
+			wantArgs: []string{"6"},
+		},
+		{
+			name:     "strips several references on one line",
+			input:    "nostr:nevent1abc nostr:note1def\nbalance",
+			wantName: "balance",
+			wantArgs: []string{},
+		},
+		{
+			name:     "reference after a command is an argument",
+			input:    "order 6 nostr:note1abc",
+			wantName: "order",
+			wantArgs: []string{"6", "nostr:note1abc"},
+		},
+		{
+			name:     "strips blockquote of the replied-to message",
+			input:    "> Order #12 is ready for pickup\n>\n> Reply with history to see it\nhistory",
+			wantName: "history",
+			wantArgs: []string{},
+		},
+		{
+			name:     "strips blockquote after the command",
+			input:    "balance\n> What is my balance?",
+			wantName: "balance",
+			wantArgs: []string{},
+		},
+		{
+			name:     "strips posted-from footer",
+			input:    "order 12\n---\nPosted from Nostur",
+			wantName: "order",
+			wantArgs: []string{"12"},
+		},
+		{
+			name:     "strips footer and anything after it",
+			input:    "order 12\n\n---\nPosted from Nostur\nhttps://nostur.com",
+			wantName: "order",
+			wantArgs: []string{"12"},
+		},
+		{
+			name:     "dashes without a footer are kept",
+			input:    "note 3 ---",
+			wantName: "note",
+			wantArgs: []string{"3", "---"},
+		},
+		{
+			name:     "all client metadata at once",
+			input:    "[//]: # (nip18)\nnostr:note1abc\n> earlier reply\ninventory add 8\n---\nPosted from Primal",
+			wantName: "inventory",
+			wantArgs: []string{"add", "8"},
+		},
+		{
+			name:    "footer only returns nil",
+			input:   "---\nPosted from Primal",
+			wantNil: true,
+		},
+		{
+			name:     "npub argument is not stripped",
+			input:    "addcustomer npub1abc123",
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123"},
+		},
+		{
+			name:     "bot name later in message is kept",
+			input:    "hello eggbot",
+			wantName: "hello",
+			wantArgs: []string{"eggbot"},
+		},
+		{
+			name:     "double-quoted argument",
+			input:    `addcustomer npub1abc123 "Jane from the co-op"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "Jane from the co-op"},
+		},
+		{
+			name:     "single-quoted argument",
+			input:    `addcustomer npub1abc123 'Jane from the co-op'`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "Jane from the co-op"},
+		},
+		{
+			name:     "escaped quotes inside quotes",
+			input:    `addcustomer npub1abc123 "Jane \"JJ\" Doe"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", `Jane "JJ" Doe`},
+		},
+		{
+			name:     "escaped backslash inside quotes",
+			input:    `addcustomer npub1abc123 "back\\slash"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", `back\slash`},
+		},
+		{
+			name:     "other quote type inside quotes is literal",
+			input:    `addcustomer npub1abc123 "Jane's farm"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "Jane's farm"},
+		},
+		{
+			name:     "double quotes nested in single quotes",
+			input:    `addcustomer npub1abc123 'Jane "JJ" Doe'`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", `Jane "JJ" Doe`},
+		},
+		{
+			name:     "single quotes nested in double quotes",
+			input:    `addcustomer npub1abc123 "the 'early' Jane"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "the 'early' Jane"},
+		},
+		{
+			name:     "unterminated single quote falls back to whitespace split",
+			input:    `addcustomer npub1abc123 'Jane Doe`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "'Jane", "Doe"},
+		},
+		{
+			name:     "mixed quoted and bare args",
+			input:    `cmd one "two three" four 'five six' seven`,
+			wantName: "cmd",
+			wantArgs: []string{"one", "two three", "four", "five six", "seven"},
+		},
+		{
+			name:     "empty quoted argument",
+			input:    `cmd "" after`,
+			wantName: "cmd",
+			wantArgs: []string{"", "after"},
+		},
+		{
+			name:     "apostrophes in bare words are literal",
+			input:    "cmd don't won't",
+			wantName: "cmd",
+			wantArgs: []string{"don't", "won't"},
+		},
+		{
+			name:     "unterminated quote falls back to whitespace split",
+			input:    `addcustomer npub1abc123 "Jane Doe`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", `"Jane`, "Doe"},
+		},
+		{
+			name:     "quoted args after prefixes",
+			input:    `eggbot /addcustomer npub1abc123 "Jane Doe"`,
+			wantName: "addcustomer",
+			wantArgs: []string{"npub1abc123", "Jane Doe"},
+		},
+		{
+			name:     "bot name as part of a word is kept",
+			input:    "eggbotorder 6",
+			wantName: "eggbotorder",
+			wantArgs: []string{"6"},
+		},
 	}
 
 	for _, tt := range tests {
@@ -121,6 +340,16 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParse_Text(t *testing.T) {
+	got := Parse("[//]: # (nip18)\n> earlier reply\n  do you have duck eggs?\nthanks \n---\nPosted from Primal")
+	if got == nil {
+		t.Fatal("Parse returned nil")
+	}
+	if want := "do you have duck eggs?\nthanks"; got.Text != want {
+		t.Errorf("Text = %q, want %q", got.Text, want)
+	}
+}
+
 func TestCommand_IsCustomerCommand(t *testing.T) {
 	customerCmds := []string{CmdInventory, CmdOrder, CmdBalance, CmdHistory, CmdHelp}
 	adminCmds := []string{CmdDeliver, CmdMarkpaid, CmdAdjust, CmdCustomers, CmdAddCustomer, CmdRemoveCustomer}
@@ -180,3 +409,22 @@ func TestCommand_IsValid(t *testing.T) {
 		}
 	}
 }
+
+func FuzzStripMarkdownComments(f *testing.F) {
+	for _, seed := range []string{
+		"",
+		"[//]: # (nip18)\ninventory add 8",
+		"nostr:note1abc\n> quoted\norder 6\n---\nPosted from Primal",
+		"---\n> x\nPosted from a\n---\nPosted from b",
+		"\n\n>\n---",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, content string) {
+		once := stripMarkdownComments(content)
+		if twice := stripMarkdownComments(once); twice != once {
+			t.Errorf("stripping %q again changed %q to %q", content, once, twice)
+		}
+		_ = Parse(content)
+	})
+}
@@ -2,32 +2,47 @@ package commands
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// Permission errors from CanExecute.
+var (
+	ErrNotRegistered = errors.New("not a registered customer")
+	ErrAdminRequired = errors.New("admin privileges required")
 )
 
-// IsAdmin checks if the given npub is in the admin list.
-func IsAdmin(npub string, admins []string) bool {
-	for _, admin := range admins {
-		if admin == npub {
-			return true
-		}
+// IsAdmin checks if the given npub is in the admins table.
+// Lookup errors are logged and treated as "not an admin".
+func IsAdmin(ctx context.Context, database *db.DB, npub string) bool {
+	isAdmin, err := database.IsAdmin(ctx, npub)
+	if err != nil {
+		slog.Error("admin check failed", "npub", npub, "error", err)
+		return false
 	}
-	return false
+	return isAdmin
 }
 
 // IsCustomer checks if the given npub exists in the customers table
-// or is an admin (admins are implicitly customers).
-func IsCustomer(ctx context.Context, db *sql.DB, npub string, admins []string) (bool, error) {
+// or is an admin (admins are implicitly customers). Blocked customers are frozen
+// and don't count.
+func IsCustomer(ctx context.Context, database *db.DB, npub string) (bool, error) {
 	// Admins are implicitly customers
-	if IsAdmin(npub, admins) {
+	isAdmin, err := database.IsAdmin(ctx, npub)
+	if err != nil {
+		return false, fmt.Errorf("checking admin: %w", err)
+	}
+	if isAdmin {
 		return true, nil
 	}
 
 	var exists bool
-	err := db.QueryRowContext(ctx,
-		"SELECT EXISTS(SELECT 1 FROM customers WHERE npub = ?)",
-		npub,
+	err = database.QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM customers WHERE npub = ?) AND NOT EXISTS(SELECT 1 FROM blocked_npubs WHERE npub = ?)",
+		npub, npub,
 	).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("checking customer: %w", err)
@@ -37,27 +52,28 @@ func IsCustomer(ctx context.Context, db *sql.DB, npub string, admins []string) (
 }
 
 // CanExecute returns an error if the sender lacks permission to run the command.
-// Admins can execute any command. Customers can only execute customer commands.
-// Unknown users get an "not a customer" error.
-func CanExecute(ctx context.Context, db *sql.DB, cmd *Command, senderNpub string, admins []string) error {
-	// Admins can do anything
-	if IsAdmin(senderNpub, admins) {
-		return nil
-	}
-
-	// Check if sender is a customer (admins are implicitly customers)
-	isCustomer, err := IsCustomer(ctx, db, senderNpub, admins)
+// Admins can execute any command. Customers can only execute customer commands,
+// and get ErrAdminRequired for the rest. Unknown and blocked senders get
+// ErrNotRegistered.
+// roles caches the sender's role between DMs; pass nil to always query the database.
+func CanExecute(ctx context.Context, database *db.DB, cmd *Command, senderNpub string, roles *RoleCache) error {
+	role, err := roles.Role(ctx, database, senderNpub)
 	if err != nil {
 		return fmt.Errorf("checking permissions: %w", err)
 	}
 
-	if !isCustomer {
-		return fmt.Errorf("you are not a registered customer")
+	// Admins can do anything
+	if role == RoleAdmin {
+		return nil
+	}
+
+	if role != RoleCustomer {
+		return ErrNotRegistered
 	}
 
 	// Customers can only run customer commands
 	if cmd.IsAdminCommand() {
-		return fmt.Errorf("admin command requires admin privileges")
+		return fmt.Errorf("%s: %w", cmd.Name, ErrAdminRequired)
 	}
 
 	return nil
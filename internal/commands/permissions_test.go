@@ -2,10 +2,10 @@ package commands
 
 import (
 	"context"
-	"database/sql"
+	"errors"
 	"testing"
 
-	_ "modernc.org/sqlite"
+	"github.com/buildtall-systems/eggbot/internal/db"
 )
 
 // Test npubs (generated for testing, not real keys)
@@ -13,10 +13,12 @@ const (
 	adminNpub    = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqshp52w2"
 	customerNpub = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqpqdangsl"
 	unknownNpub  = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqz5nl2kt"
+	blockedNpub  = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqzsfj2hcx"
 )
 
 func TestIsAdmin(t *testing.T) {
-	admins := []string{adminNpub}
+	database := setupPermissionsTestDB(t)
+	ctx := context.Background()
 
 	tests := []struct {
 		name       string
@@ -27,13 +29,13 @@ func TestIsAdmin(t *testing.T) {
 		{
 			name:       "admin npub returns true",
 			npub:       adminNpub,
-			admins:     admins,
+			admins:     []string{adminNpub},
 			wantResult: true,
 		},
 		{
 			name:       "non-admin npub returns false",
 			npub:       customerNpub,
-			admins:     admins,
+			admins:     []string{adminNpub},
 			wantResult: false,
 		},
 		{
@@ -46,48 +48,40 @@ func TestIsAdmin(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := IsAdmin(tt.npub, tt.admins)
+			if err := database.SeedAdmins(ctx, tt.admins); err != nil {
+				t.Fatalf("seeding admins: %v", err)
+			}
+			got := IsAdmin(ctx, database, tt.npub)
 			if got != tt.wantResult {
-				t.Errorf("IsAdmin(%q, %v) = %v, want %v", tt.npub, tt.admins, got, tt.wantResult)
+				t.Errorf("IsAdmin(%q) with admins %v = %v, want %v", tt.npub, tt.admins, got, tt.wantResult)
 			}
 		})
 	}
 }
 
-func setupTestDB(t *testing.T) *sql.DB {
+// setupPermissionsTestDB returns a migrated database with one registered
+// customer and one blocked (frozen) customer. Admins are seeded per test.
+func setupPermissionsTestDB(t *testing.T) *db.DB {
 	t.Helper()
 
-	db, err := sql.Open("sqlite", ":memory:")
-	if err != nil {
-		t.Fatalf("opening test db: %v", err)
-	}
-
-	// Create customers table
-	_, err = db.Exec(`
-		CREATE TABLE customers (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			npub TEXT NOT NULL UNIQUE,
-			name TEXT,
-			created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		t.Fatalf("creating customers table: %v", err)
-	}
+	database := setupCmdTestDB(t)
+	ctx := context.Background()
 
-	// Insert test customer
-	_, err = db.Exec("INSERT INTO customers (npub) VALUES (?)", customerNpub)
-	if err != nil {
+	if _, err := database.CreateCustomer(ctx, customerNpub, ""); err != nil {
 		t.Fatalf("inserting test customer: %v", err)
 	}
+	if _, err := database.CreateCustomer(ctx, blockedNpub, ""); err != nil {
+		t.Fatalf("inserting blocked customer: %v", err)
+	}
+	if err := database.BlockNpub(ctx, blockedNpub, adminNpub); err != nil {
+		t.Fatalf("blocking test customer: %v", err)
+	}
 
-	return db
+	return database
 }
 
 func TestIsCustomer(t *testing.T) {
-	db := setupTestDB(t)
-	defer func() { _ = db.Close() }()
-
+	database := setupPermissionsTestDB(t)
 	ctx := context.Background()
 	admins := []string{adminNpub}
 
@@ -109,6 +103,12 @@ func TestIsCustomer(t *testing.T) {
 			admins:     admins,
 			wantResult: false,
 		},
+		{
+			name:       "blocked customer returns false (account frozen)",
+			npub:       blockedNpub,
+			admins:     admins,
+			wantResult: false,
+		},
 		{
 			name:       "admin (not in customers table) returns true (implicit customer)",
 			npub:       adminNpub,
@@ -125,7 +125,10 @@ func TestIsCustomer(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := IsCustomer(ctx, db, tt.npub, tt.admins)
+			if err := database.SeedAdmins(ctx, tt.admins); err != nil {
+				t.Fatalf("seeding admins: %v", err)
+			}
+			got, err := IsCustomer(ctx, database, tt.npub)
 			if err != nil {
 				t.Errorf("IsCustomer(%q) error = %v, want nil", tt.npub, err)
 				return
@@ -139,68 +142,66 @@ func TestIsCustomer(t *testing.T) {
 }
 
 func TestCanExecute(t *testing.T) {
-	db := setupTestDB(t)
-	defer func() { _ = db.Close() }()
-
+	database := setupPermissionsTestDB(t)
 	ctx := context.Background()
-	admins := []string{adminNpub}
+	_ = database.SeedAdmins(ctx, []string{adminNpub})
 
 	tests := []struct {
 		name    string
 		cmd     *Command
 		npub    string
-		wantErr bool
+		wantErr error
 	}{
 		{
 			name:    "admin can execute customer command",
 			cmd:     &Command{Name: CmdInventory},
 			npub:    adminNpub,
-			wantErr: false,
+			wantErr: nil,
 		},
 		{
 			name:    "admin can execute admin command",
 			cmd:     &Command{Name: CmdDeliver},
 			npub:    adminNpub,
-			wantErr: false,
+			wantErr: nil,
 		},
 		{
 			name:    "customer can execute customer command",
 			cmd:     &Command{Name: CmdInventory},
 			npub:    customerNpub,
-			wantErr: false,
+			wantErr: nil,
 		},
 		{
 			name:    "customer cannot execute admin command",
 			cmd:     &Command{Name: CmdDeliver},
 			npub:    customerNpub,
-			wantErr: true,
+			wantErr: ErrAdminRequired,
+		},
+		{
+			name:    "blocked customer cannot execute customer command",
+			cmd:     &Command{Name: CmdInventory},
+			npub:    blockedNpub,
+			wantErr: ErrNotRegistered,
 		},
 		{
 			name:    "unknown user cannot execute customer command",
 			cmd:     &Command{Name: CmdInventory},
 			npub:    unknownNpub,
-			wantErr: true,
+			wantErr: ErrNotRegistered,
 		},
 		{
 			name:    "unknown user cannot execute admin command",
 			cmd:     &Command{Name: CmdDeliver},
 			npub:    unknownNpub,
-			wantErr: true,
+			wantErr: ErrNotRegistered,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			err := CanExecute(ctx, db, tt.cmd, tt.npub, admins)
-
-			if tt.wantErr {
-				if err == nil {
-					t.Errorf("CanExecute(%v, %q) = nil, want error", tt.cmd.Name, tt.npub)
-				}
-			} else {
-				if err != nil {
-					t.Errorf("CanExecute(%v, %q) = %v, want nil", tt.cmd.Name, tt.npub, err)
-				}
+			err := CanExecute(ctx, database, tt.cmd, tt.npub, nil)
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CanExecute(%v, %q) = %v, want %v", tt.cmd.Name, tt.npub, err, tt.wantErr)
 			}
 		})
 	}
@@ -0,0 +1,142 @@
+package commands
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+// Role is a sender's permission level.
+type Role int
+
+// Sender roles, from least to most privileged.
+const (
+	RoleUnknown Role = iota
+	RoleCustomer
+	RoleAdmin
+)
+
+// maxCachedRoles is how many senders' roles a RoleCache holds at most.
+const maxCachedRoles = 10000
+
+// roleEntry is a cached role lookup.
+type roleEntry struct {
+	role      Role
+	expiresAt time.Time
+}
+
+// RoleCache caches sender roles so permission checks don't hit the database
+// on every DM. Commands that change a role must call Invalidate for the npub;
+// Execute does this for the built-in commands. Once maxEntries roles are held,
+// expired ones are dropped, or else the oldest, so a flood of senders can't
+// grow it without bound.
+type RoleCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+	entries    map[string]roleEntry
+}
+
+// NewRoleCache creates a cache holding roles for ttl. A ttl <= 0 disables caching.
+func NewRoleCache(ttl time.Duration) *RoleCache {
+	return &RoleCache{
+		ttl:        ttl,
+		maxEntries: maxCachedRoles,
+		now:        time.Now,
+		entries:    make(map[string]roleEntry),
+	}
+}
+
+// Role returns the sender's role, from the cache if still fresh.
+// A nil cache always queries the database.
+func (c *RoleCache) Role(ctx context.Context, database *db.DB, npub string) (Role, error) {
+	if c == nil || c.ttl <= 0 {
+		return lookupRole(ctx, database, npub)
+	}
+
+	c.mu.Lock()
+	entry, ok := c.entries[npub]
+	c.mu.Unlock()
+	if ok && c.now().Before(entry.expiresAt) {
+		return entry.role, nil
+	}
+
+	role, err := lookupRole(ctx, database, npub)
+	if err != nil {
+		return RoleUnknown, err
+	}
+
+	c.mu.Lock()
+	now := c.now()
+	if _, cached := c.entries[npub]; !cached && len(c.entries) >= c.maxEntries {
+		c.evict(now)
+	}
+	c.entries[npub] = roleEntry{role: role, expiresAt: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return role, nil
+}
+
+// evict makes room for one more role by dropping the expired ones, or the
+// oldest if none have expired. c.mu must be held.
+func (c *RoleCache) evict(now time.Time) {
+	var oldest string
+	for npub, entry := range c.entries {
+		if !now.Before(entry.expiresAt) {
+			delete(c.entries, npub)
+			continue
+		}
+		if oldest == "" || entry.expiresAt.Before(c.entries[oldest].expiresAt) {
+			oldest = npub
+		}
+	}
+	if len(c.entries) >= c.maxEntries {
+		delete(c.entries, oldest)
+	}
+}
+
+// Invalidate drops the cached role for an npub.
+func (c *RoleCache) Invalidate(npub string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, npub)
+	c.mu.Unlock()
+}
+
+// Reset drops all cached roles.
+func (c *RoleCache) Reset() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.entries = make(map[string]roleEntry)
+	c.mu.Unlock()
+}
+
+// lookupRole queries the database for the sender's role.
+func lookupRole(ctx context.Context, database *db.DB, npub string) (Role, error) {
+	isAdmin, err := database.IsAdmin(ctx, npub)
+	if err != nil {
+		return RoleUnknown, err
+	}
+	if isAdmin {
+		return RoleAdmin, nil
+	}
+
+	isCustomer, err := IsCustomer(ctx, database, npub)
+	if err != nil {
+		return RoleUnknown, err
+	}
+	if isCustomer {
+		return RoleCustomer, nil
+	}
+	return RoleUnknown, nil
+}
+
+// roleChangingCommands are the commands whose first argument is an npub
+// whose role changes when the command succeeds.
+var roleChangingCommands = []string{CmdAddCustomer, CmdRemoveCustomer, CmdBlock, CmdUnblock, CmdAddAdmin, CmdRemoveAdmin}
@@ -0,0 +1,178 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestRoleCache_Role(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	roles := NewRoleCache(time.Hour)
+
+	tests := []struct {
+		npub string
+		want Role
+	}{
+		{testAdminNpub, RoleAdmin},
+		{testCustomerNpub, RoleCustomer},
+		{strangerNpub, RoleUnknown},
+	}
+	for _, tt := range tests {
+		got, err := roles.Role(ctx, database, tt.npub)
+		if err != nil {
+			t.Fatalf("Role(%s): %v", tt.npub, err)
+		}
+		if got != tt.want {
+			t.Errorf("Role(%s) = %v, want %v", tt.npub, got, tt.want)
+		}
+	}
+}
+
+func TestRoleCache_TTL(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	now := time.Unix(1700000000, 0)
+	roles := NewRoleCache(time.Minute)
+	roles.now = func() time.Time { return now }
+
+	if role, _ := roles.Role(ctx, database, strangerNpub); role != RoleUnknown {
+		t.Fatalf("expected RoleUnknown, got %v", role)
+	}
+
+	// Changed behind the cache's back: stale until the TTL passes
+	_, _ = database.CreateCustomer(ctx, strangerNpub, "")
+	if role, _ := roles.Role(ctx, database, strangerNpub); role != RoleUnknown {
+		t.Errorf("expected cached RoleUnknown within TTL, got %v", role)
+	}
+
+	now = now.Add(time.Minute)
+	if role, _ := roles.Role(ctx, database, strangerNpub); role != RoleCustomer {
+		t.Errorf("expected RoleCustomer after TTL, got %v", role)
+	}
+}
+
+func TestRoleCache_Bounded(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_, _ = database.CreateCustomer(ctx, testCustomerNpub, "")
+
+	now := time.Unix(1700000000, 0)
+	roles := NewRoleCache(time.Hour)
+	roles.now = func() time.Time { return now }
+	roles.maxEntries = 3
+
+	_, _ = roles.Role(ctx, database, testCustomerNpub)
+	for i := 0; i < 10; i++ {
+		now = now.Add(time.Second)
+		if _, err := roles.Role(ctx, database, fmt.Sprintf("npub1sender%d", i)); err != nil {
+			t.Fatalf("Role: %v", err)
+		}
+		if len(roles.entries) > roles.maxEntries {
+			t.Fatalf("%d roles cached after %d senders, want at most %d", len(roles.entries), i+2, roles.maxEntries)
+		}
+	}
+
+	// The oldest went first; the most recent are still cached
+	if _, ok := roles.entries[testCustomerNpub]; ok {
+		t.Error("the oldest role was not evicted")
+	}
+	if _, ok := roles.entries["npub1sender9"]; !ok {
+		t.Error("the newest role was evicted")
+	}
+
+	// Expired roles make room before any fresh one is dropped
+	now = now.Add(time.Hour)
+	_, _ = roles.Role(ctx, database, testCustomerNpub)
+	if len(roles.entries) != 1 {
+		t.Errorf("%d roles cached after the others expired, want 1", len(roles.entries))
+	}
+}
+
+func TestRoleCache_Invalidate(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	roles := NewRoleCache(time.Hour)
+
+	_, _ = roles.Role(ctx, database, strangerNpub)
+	_, _ = database.CreateCustomer(ctx, strangerNpub, "")
+	roles.Invalidate(strangerNpub)
+
+	if role, _ := roles.Role(ctx, database, strangerNpub); role != RoleCustomer {
+		t.Errorf("expected RoleCustomer after invalidation, got %v", role)
+	}
+}
+
+func TestRoleCache_Reset(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	roles := NewRoleCache(time.Hour)
+
+	_, _ = roles.Role(ctx, database, testAdminNpub)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	roles.Reset()
+
+	if role, _ := roles.Role(ctx, database, testAdminNpub); role != RoleAdmin {
+		t.Errorf("expected RoleAdmin after reset, got %v", role)
+	}
+}
+
+func TestRoleCache_DisabledAndNil(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+
+	for name, roles := range map[string]*RoleCache{"zero ttl": NewRoleCache(0), "nil": nil} {
+		t.Run(name, func(t *testing.T) {
+			npub := strangerNpub
+			_ = database.RemoveCustomer(ctx, npub)
+			_, _ = roles.Role(ctx, database, npub)
+			_, _ = database.CreateCustomer(ctx, npub, "")
+			if role, _ := roles.Role(ctx, database, npub); role != RoleCustomer {
+				t.Errorf("expected uncached RoleCustomer, got %v", role)
+			}
+		})
+	}
+}
+
+func TestExecute_AddCustomerThenOrderWithoutWaiting(t *testing.T) {
+	ctx := context.Background()
+	database := setupCmdTestDB(t)
+	_ = database.SeedAdmins(ctx, []string{testAdminNpub})
+	_ = database.AddEggs(ctx, 12)
+
+	roles := NewRoleCache(time.Hour)
+	cfg := ExecuteConfig{SatsPerHalfDozen: 3200, Roles: roles}
+	order := &Command{Name: CmdOrder, Args: []string{"6"}}
+
+	// Not yet a customer - and that answer is now cached
+	if err := CanExecute(ctx, database, order, testCustomerNpub, roles); err == nil {
+		t.Fatal("expected unregistered sender to be refused")
+	}
+
+	result := Execute(ctx, database, &Command{Name: CmdAddCustomer, Args: []string{testCustomerNpub}}, testAdminNpub, cfg)
+	if result.Error != nil {
+		t.Fatalf("addcustomer: %v", result.Error)
+	}
+
+	if err := CanExecute(ctx, database, order, testCustomerNpub, roles); err != nil {
+		t.Fatalf("expected new customer to be allowed immediately, got %v", err)
+	}
+	if result := Execute(ctx, database, order, testCustomerNpub, cfg); result.Error != nil {
+		t.Errorf("order: %v", result.Error)
+	}
+
+	// Blocking takes effect immediately too
+	result = Execute(ctx, database, &Command{Name: CmdBlock, Args: []string{testCustomerNpub}}, testAdminNpub, cfg)
+	if result.Error != nil {
+		t.Fatalf("block: %v", result.Error)
+	}
+	if err := CanExecute(ctx, database, order, testCustomerNpub, roles); err == nil {
+		t.Error("expected blocked customer to be refused immediately")
+	}
+}
@@ -0,0 +1,91 @@
+package commands
+
+import (
+	"fmt"
+	"time"
+)
+
+// displayTimeFormat is how times are shown in DMs, e.g. "Jul 5 14:30".
+const displayTimeFormat = "Jan 2 15:04"
+
+// FormatTime renders t in loc for display in DMs. Times are stored and read
+// back in UTC and only converted here; a nil loc shows them in UTC.
+func FormatTime(t time.Time, loc *time.Location) string {
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(displayTimeFormat)
+}
+
+// TimeDisplay holds what order listings need to say when an order was placed
+// and, while it is unpaid, when it expires.
+type TimeDisplay struct {
+	Location    *time.Location   // Zone for dates; nil means UTC
+	Now         func() time.Time // Clock for ages; nil means time.Now
+	OrderExpiry time.Duration    // How long unpaid orders are held; 0 when they don't expire
+	Layout      string           // Go time layout for dates; "" means displayTimeFormat
+}
+
+func (td TimeDisplay) now() time.Time {
+	if td.Now == nil {
+		return time.Now()
+	}
+	return td.Now()
+}
+
+// formatTime renders t in the display zone with the display layout.
+func (td TimeDisplay) formatTime(t time.Time) string {
+	if td.Layout == "" {
+		return FormatTime(t, td.Location)
+	}
+	loc := td.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	return t.In(loc).Format(td.Layout)
+}
+
+// orderWhen renders an order's date and age, e.g. "Jul 5 14:30, 2d ago", and
+// for a pending order that will expire, how long it has left.
+func (td TimeDisplay) orderWhen(createdAt time.Time, status string) string {
+	placed, age, left, expiring := td.orderTimes(createdAt, status)
+	s := fmt.Sprintf("%s, %s ago", placed, age)
+	switch {
+	case left != "":
+		s += ", expires in " + left
+	case expiring:
+		s += ", expiring"
+	}
+	return s
+}
+
+// orderTimes returns an order's date and age and, for a pending order that
+// will expire, how long it has left, or expiring once its deadline passed.
+func (td TimeDisplay) orderTimes(createdAt time.Time, status string) (placed, age, left string, expiring bool) {
+	now := td.now()
+	placed, age = td.formatTime(createdAt), formatAge(now.Sub(createdAt))
+	if status == "pending" && td.OrderExpiry > 0 {
+		// Expiry runs on the cleanup interval, so an order can outlive its deadline briefly
+		if d := createdAt.Add(td.OrderExpiry).Sub(now); d > 0 {
+			left = formatAge(d)
+		} else {
+			expiring = true
+		}
+	}
+	return placed, age, left, expiring
+}
+
+// formatAge renders a duration in its largest whole unit, e.g. "45m", "3h" or
+// "2d", keeping order lines short.
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "<1m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	default:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+}
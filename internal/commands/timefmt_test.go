@@ -0,0 +1,123 @@
+package commands
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/buildtall-systems/eggbot/internal/db"
+)
+
+func TestFormatTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading zone: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		t    time.Time
+		loc  *time.Location
+		want string
+	}{
+		{"nil is UTC", time.Date(2025, 7, 5, 14, 30, 0, 0, time.UTC), nil, "Jul 5 14:30"},
+		{"winter offset", time.Date(2025, 1, 10, 9, 0, 0, 0, time.UTC), berlin, "Jan 10 10:00"},
+		{"before spring forward", time.Date(2025, 3, 30, 0, 59, 0, 0, time.UTC), berlin, "Mar 30 01:59"},
+		{"after spring forward", time.Date(2025, 3, 30, 1, 0, 0, 0, time.UTC), berlin, "Mar 30 03:00"},
+		{"before fall back", time.Date(2025, 10, 26, 0, 59, 0, 0, time.UTC), berlin, "Oct 26 02:59"},
+		{"after fall back", time.Date(2025, 10, 26, 1, 0, 0, 0, time.UTC), berlin, "Oct 26 02:00"},
+		{"non-UTC input", time.Date(2025, 7, 5, 16, 30, 0, 0, berlin), nil, "Jul 5 14:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatTime(tt.t, tt.loc); got != tt.want {
+				t.Errorf("FormatTime = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatAge(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{-time.Minute, "<1m"},
+		{30 * time.Second, "<1m"},
+		{time.Minute, "1m"},
+		{59*time.Minute + 59*time.Second, "59m"},
+		{time.Hour, "1h"},
+		{23*time.Hour + 59*time.Minute, "23h"},
+		{24 * time.Hour, "1d"},
+		{10*24*time.Hour + 5*time.Hour, "10d"},
+	}
+	for _, tt := range tests {
+		if got := formatAge(tt.d); got != tt.want {
+			t.Errorf("formatAge(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+// TestOrderLines checks rendered order lines against a fixed clock.
+func TestOrderLines(t *testing.T) {
+	now := time.Date(2025, 7, 7, 12, 0, 0, 0, time.UTC)
+	clock := func() time.Time { return now }
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("loading zone: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		td          TimeDisplay
+		createdAt   time.Time
+		status      string
+		wantHistory string
+		wantOrders  string
+	}{
+		{
+			name:        "paid days ago",
+			td:          TimeDisplay{Now: clock, OrderExpiry: 2 * time.Hour},
+			createdAt:   now.Add(-50 * time.Hour),
+			status:      "paid",
+			wantHistory: "• #7: 6 eggs, 3200 sats (paid) - Jul 5 10:00, 2d ago",
+			wantOrders:  "• #7: npub1rm9q804...8ejt | 6 eggs | 3200 sats | paid | Jul 5 10:00, 2d ago",
+		},
+		{
+			name:        "pending with expiry",
+			td:          TimeDisplay{Location: berlin, Now: clock, OrderExpiry: 2 * time.Hour},
+			createdAt:   now.Add(-20 * time.Minute),
+			status:      "pending",
+			wantHistory: "• #7: 6 eggs, 3200 sats (pending) - Jul 7 13:40, 20m ago, expires in 1h",
+			wantOrders:  "• #7: npub1rm9q804...8ejt | 6 eggs | 3200 sats | pending | Jul 7 13:40, 20m ago, expires in 1h",
+		},
+		{
+			name:        "pending past its deadline",
+			td:          TimeDisplay{Now: clock, OrderExpiry: 2 * time.Hour},
+			createdAt:   now.Add(-3 * time.Hour),
+			status:      "pending",
+			wantHistory: "• #7: 6 eggs, 3200 sats (pending) - Jul 7 09:00, 3h ago, expiring",
+			wantOrders:  "• #7: npub1rm9q804...8ejt | 6 eggs | 3200 sats | pending | Jul 7 09:00, 3h ago, expiring",
+		},
+		{
+			name:        "pending without expiry",
+			td:          TimeDisplay{Now: clock},
+			createdAt:   now.Add(-10 * 24 * time.Hour),
+			status:      "pending",
+			wantHistory: "• #7: 6 eggs, 3200 sats (pending) - Jun 27 12:00, 10d ago",
+			wantOrders:  "• #7: npub1rm9q804...8ejt | 6 eggs | 3200 sats | pending | Jun 27 12:00, 10d ago",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := db.Order{ID: 7, Quantity: 6, TotalSats: 3200, Status: tt.status, CreatedAt: tt.createdAt}
+			if got := historyLine(order, tt.td, nil); got != tt.wantHistory {
+				t.Errorf("history line:\n got %q\nwant %q", got, tt.wantHistory)
+			}
+			withCustomer := db.OrderWithCustomer{ID: 7, CustomerNpub: testCustomerNpub, Quantity: 6, TotalSats: 3200, Status: tt.status, CreatedAt: tt.createdAt}
+			if got := ordersLine(withCustomer, "", tt.td); got != tt.wantOrders {
+				t.Errorf("orders line:\n got %q\nwant %q", got, tt.wantOrders)
+			}
+		})
+	}
+}
@@ -2,8 +2,13 @@ package config
 
 import (
 	"fmt"
-	"os"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/buildtall-systems/eggbot/internal/messages"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 	"github.com/spf13/viper"
@@ -11,12 +16,48 @@ import (
 
 // Config holds all application configuration.
 type Config struct {
-	Verbose   bool
-	Database  DatabaseConfig
-	Nostr     NostrConfig
-	Lightning LightningConfig
-	Pricing   PricingConfig
-	Admins    []string // npubs of admin users
+	Verbose     bool
+	Log         LogConfig
+	Database    DatabaseConfig
+	Nostr       NostrConfig
+	Lightning   LightningConfig
+	Pricing     PricingConfig
+	Pickup      PickupConfig
+	Orders      OrdersConfig
+	Permissions PermissionsConfig
+	Commands    CommandsConfig
+	Health      HealthConfig
+	Display     DisplayConfig
+	Profile     ProfileConfig
+	Announce    AnnounceConfig
+	Messages    MessagesConfig
+	Features    Features
+	Admins      []string // npubs of admin users
+
+	// Problems found while loading that don't stop the bot, for the caller
+	// to log once its logger is set up
+	Warnings []string
+}
+
+// LogConfig holds logging settings.
+type LogConfig struct {
+	Level  slog.Level // Minimum level logged; Verbose forces debug
+	Format string     // Handler output: "text" (default) or "json"
+}
+
+// Supported values for log.format.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// Features holds opt-in switches for experimental features. All default to off.
+type Features struct {
+	EnableWaitlist    bool // Offer a waitlist when eggs run out
+	EnableNIP44       bool // Accept and send NIP-44 payloads in kind:4 DMs
+	EnableOrderExpiry bool // Cancel unpaid orders after orders.expiry_minutes
+	EnableMentions    bool // Answer public notes that tag the bot with a command by DM
+	EnableNIP05       bool // Show customers' verified NIP-05 identifiers in admin listings
 }
 
 // DatabaseConfig holds database settings.
@@ -24,24 +65,181 @@ type DatabaseConfig struct {
 	Path string
 }
 
+// Default in-memory event dedup settings, used when not configured.
+const (
+	DefaultDedupTTL        = 10 * time.Minute
+	DefaultDedupMaxEntries = 10000
+)
+
+// DefaultSinceOverlap is how far before each high water mark the relay
+// subscription resumes, when not configured.
+const DefaultSinceOverlap = 5 * time.Minute
+
 // NostrConfig holds Nostr-related settings.
 type NostrConfig struct {
-	Relays        []string
-	BotNpub       string // Bot's public key in npub format (from config)
-	BotSecretHex  string // Bot's secret key in hex (derived from EGGBOT_NSEC env)
-	BotPubkeyHex  string // Bot's public key in hex (derived from secret)
+	Relays           []string // Every relay: primary relays, then fallback relays
+	PrimaryRelays    []string // Published to first; defaults to relays
+	FallbackRelays   []string // Published to only if no primary relay accepts an event
+	BotNpub          string   // Bot's public key in npub format (from config)
+	NsecFile         string   // File holding the bot's nsec, e.g. a systemd credential; EGGBOT_NSEC and EGGBOT_NCRYPTSEC win over it
+	BotSecretHex     string   // Bot's secret key in hex (from EGGBOT_NSEC, the decrypted EGGBOT_NCRYPTSEC or nsec_file)
+	BotPubkeyHex     string   // Bot's public key in hex (derived from secret)
+	MaxMessageBytes  int      // Plaintext byte budget per DM before it is split into parts
+	LegacyEncryption string   // Encryption for outbound kind:4 DMs: "nip04" (default) or "nip44"
+
+	DedupTTL        time.Duration // How long event IDs are remembered to drop copies from other relays; 0 disables it
+	DedupMaxEntries int           // Most event IDs remembered at once; the oldest are forgotten first
+
+	SinceOverlap time.Duration // Subscriptions resume this long before each kind's high water mark to catch late events
 }
 
+// Supported values for nostr.legacy_encryption.
+const (
+	LegacyEncryptionNIP04 = "nip04"
+	LegacyEncryptionNIP44 = "nip44"
+)
+
 // LightningConfig holds Lightning payment settings.
 type LightningConfig struct {
-	LnurlNpub        string // LNURL provider's npub (from config)
-	LnurlPubkeyHex   string // Derived hex pubkey for zap validation
-	LightningAddress string // Lightning address for payments (e.g., user@getalby.com)
+	LnurlNpub         string   // LNURL provider's npub (from config)
+	LnurlPubkeyHex    string   // Derived hex pubkey for zap validation
+	LightningAddress  string   // Lightning address for payments (e.g., user@getalby.com)
+	FallbackAddresses []string // Tried in order when the primary address can't produce an invoice
 }
 
 // PricingConfig holds egg pricing settings.
 type PricingConfig struct {
-	SatsPerHalfDozen int // Price for 6 eggs in sats
+	SatsPerHalfDozen  int   // Price for 6 eggs in sats
+	AllowedQuantities []int // Order sizes accepted by order and sell
+}
+
+// DefaultAllowedQuantities are the order sizes accepted when none are configured:
+// a half-dozen or a dozen.
+var DefaultAllowedQuantities = []int{6, 12}
+
+// PickupConfig holds order pickup settings.
+type PickupConfig struct {
+	Instructions string // Appended to order and payment confirmations; admins can override at runtime
+}
+
+// DefaultOrderExpiryMinutes is how long an unpaid order is held when not configured.
+const DefaultOrderExpiryMinutes = 120
+
+// OrdersConfig holds order handling settings.
+type OrdersConfig struct {
+	ExpiryMinutes         int // Pending orders older than this are cancelled and their eggs released
+	LowInventoryThreshold int // Admins are told when an order leaves fewer eggs than this; 0 disables it
+}
+
+// CommandsConfig holds settings for how DMs are read as commands.
+type CommandsConfig struct {
+	ForwardUnrecognized bool // Forward customers' messages that aren't commands to the admins instead of replying "Unknown command"
+}
+
+// PermissionsConfig holds permission check settings.
+type PermissionsConfig struct {
+	RoleCacheTTL time.Duration // How long a sender's customer/admin status is cached; 0 disables caching
+}
+
+// DefaultRoleCacheTTL is how long a sender's role is cached when not configured.
+const DefaultRoleCacheTTL = time.Minute
+
+// DefaultHealthMaxSilence is how long relays may go without connection or events
+// before /healthz reports them unhealthy, when not configured.
+const DefaultHealthMaxSilence = 10 * time.Minute
+
+// HealthConfig holds health endpoint settings.
+type HealthConfig struct {
+	Listen     string        // Address for the /healthz endpoint, e.g. "127.0.0.1:8080"; empty disables it
+	MaxSilence time.Duration // Relays are unhealthy if none is connected and no event arrived within this
+}
+
+// DisplayConfig holds settings for how values are shown in DMs.
+type DisplayConfig struct {
+	Timezone string         // IANA zone name for times in replies, e.g. "Europe/Berlin"; empty uses the host's zone
+	Location *time.Location // Resolved from Timezone; times are always stored in UTC
+}
+
+// MessagesConfig holds the customer-facing message texts.
+type MessagesConfig struct {
+	Path    string            // YAML file overriding built-in messages; empty uses them all
+	Locale  string            // Language of replies to customers who haven't chosen one; empty means English
+	Catalog *messages.Catalog // Loaded from Path, in Locale; the built-in messages when Path is empty
+}
+
+// ProfileConfig holds the bot's published Nostr profile (kind:0). Its lud16 is
+// lightning.address.
+type ProfileConfig struct {
+	Name           string
+	About          string
+	Picture        string // Image URL
+	PublishOnStart bool   // Publish the profile at startup when it differs from the relays' copy
+}
+
+// AnnounceConfig holds settings for public inventory announcements.
+type AnnounceConfig struct {
+	Mode          string        // "off" (default), "note" for kind:1 notes or "replaceable" for a kind:30078 event
+	MinInterval   time.Duration // Least time between two announcements
+	AutoThreshold int           // Announce after an "inventory add" of at least this many eggs; 0 only announces on request
+}
+
+// Supported values for announce.mode.
+const (
+	AnnounceOff         = "off"
+	AnnounceNote        = "note"
+	AnnounceReplaceable = "replaceable"
+)
+
+// DefaultAnnounceMinInterval is the least time between inventory
+// announcements when not configured.
+const DefaultAnnounceMinInterval = time.Hour
+
+// configKeys are the config keys eggbot reads. Any other key in the config
+// file is most likely a typo.
+var configKeys = []string{
+	"admins",
+	"announce.auto_threshold", "announce.min_interval", "announce.mode",
+	"commands.forward_unrecognized",
+	"database.path",
+	"display.timezone",
+	"features.enable_mentions", "features.enable_nip05", "features.enable_nip44",
+	"features.enable_order_expiry", "features.enable_waitlist",
+	"health.listen", "health.max_silence",
+	"lightning.address", "lightning.fallback_addresses", "lightning.lnurl_npub",
+	"log.format", "log.level",
+	"messages.locale", "messages.path",
+	"nostr.bot_npub", "nostr.dedup_max_entries", "nostr.dedup_ttl", "nostr.fallback_relays",
+	"nostr.legacy_encryption", "nostr.max_message_bytes", nsecFileKey, "nostr.primary_relays",
+	"nostr.relays", "nostr.since_overlap",
+	"orders.expiry_minutes", "orders.low_inventory_threshold",
+	"permissions.role_cache_ttl",
+	"pickup.instructions",
+	"pricing.allowed_quantities", "pricing.sats_per_half_dozen",
+	"profile.about", "profile.name", "profile.picture", "profile.publish_on_start",
+	"verbose",
+}
+
+// unknownKeys returns the keys among keys that eggbot doesn't read, sorted and
+// without duplicates. A misspelt section is reported once by its name rather
+// than once per key beneath it.
+func unknownKeys(keys []string) []string {
+	var unknown []string
+	for _, key := range keys {
+		if slices.Contains(configKeys, key) {
+			continue
+		}
+		section, _, _ := strings.Cut(key, ".")
+		if !slices.ContainsFunc(configKeys, func(known string) bool {
+			return known == section || strings.HasPrefix(known, section+".")
+		}) {
+			key = section
+		}
+		if !slices.Contains(unknown, key) {
+			unknown = append(unknown, key)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
 }
 
 // Load reads configuration from Viper and returns a Config struct.
@@ -49,62 +247,205 @@ type PricingConfig struct {
 func Load() (*Config, error) {
 	cfg := &Config{
 		Verbose: viper.GetBool("verbose"),
+		Log: LogConfig{
+			Format: viper.GetString("log.format"),
+		},
 		Database: DatabaseConfig{
 			Path: viper.GetString("database.path"),
 		},
 		Nostr: NostrConfig{
-			Relays:  viper.GetStringSlice("nostr.relays"),
-			BotNpub: viper.GetString("nostr.bot_npub"),
+			Relays:           viper.GetStringSlice("nostr.relays"),
+			PrimaryRelays:    viper.GetStringSlice("nostr.primary_relays"),
+			FallbackRelays:   viper.GetStringSlice("nostr.fallback_relays"),
+			BotNpub:          viper.GetString("nostr.bot_npub"),
+			NsecFile:         viper.GetString(nsecFileKey),
+			MaxMessageBytes:  viper.GetInt("nostr.max_message_bytes"),
+			LegacyEncryption: viper.GetString("nostr.legacy_encryption"),
+			DedupTTL:         viper.GetDuration("nostr.dedup_ttl"),
+			DedupMaxEntries:  viper.GetInt("nostr.dedup_max_entries"),
+			SinceOverlap:     viper.GetDuration("nostr.since_overlap"),
 		},
 		Lightning: LightningConfig{
-			LnurlNpub:        viper.GetString("lightning.lnurl_npub"),
-			LightningAddress: viper.GetString("lightning.address"),
+			LnurlNpub:         viper.GetString("lightning.lnurl_npub"),
+			LightningAddress:  viper.GetString("lightning.address"),
+			FallbackAddresses: viper.GetStringSlice("lightning.fallback_addresses"),
 		},
 		Pricing: PricingConfig{
-			SatsPerHalfDozen: viper.GetInt("pricing.sats_per_half_dozen"),
+			SatsPerHalfDozen:  viper.GetInt("pricing.sats_per_half_dozen"),
+			AllowedQuantities: viper.GetIntSlice("pricing.allowed_quantities"),
+		},
+		Pickup: PickupConfig{
+			Instructions: viper.GetString("pickup.instructions"),
+		},
+		Orders: OrdersConfig{
+			ExpiryMinutes:         viper.GetInt("orders.expiry_minutes"),
+			LowInventoryThreshold: viper.GetInt("orders.low_inventory_threshold"),
+		},
+		Permissions: PermissionsConfig{
+			RoleCacheTTL: viper.GetDuration("permissions.role_cache_ttl"),
+		},
+		Commands: CommandsConfig{
+			ForwardUnrecognized: viper.GetBool("commands.forward_unrecognized"),
+		},
+		Health: HealthConfig{
+			Listen:     viper.GetString("health.listen"),
+			MaxSilence: viper.GetDuration("health.max_silence"),
+		},
+		Display: DisplayConfig{
+			Timezone: viper.GetString("display.timezone"),
+		},
+		Profile: ProfileConfig{
+			Name:           viper.GetString("profile.name"),
+			About:          viper.GetString("profile.about"),
+			Picture:        viper.GetString("profile.picture"),
+			PublishOnStart: viper.GetBool("profile.publish_on_start"),
+		},
+		Announce: AnnounceConfig{
+			Mode:          viper.GetString("announce.mode"),
+			MinInterval:   viper.GetDuration("announce.min_interval"),
+			AutoThreshold: viper.GetInt("announce.auto_threshold"),
+		},
+		Messages: MessagesConfig{
+			Path:   viper.GetString("messages.path"),
+			Locale: viper.GetString("messages.locale"),
+		},
+		Features: Features{
+			EnableWaitlist:    viper.GetBool("features.enable_waitlist"),
+			EnableNIP44:       viper.GetBool("features.enable_nip44"),
+			EnableOrderExpiry: viper.GetBool("features.enable_order_expiry"),
+			EnableMentions:    viper.GetBool("features.enable_mentions"),
+			EnableNIP05:       viper.GetBool("features.enable_nip05"),
 		},
 		Admins: viper.GetStringSlice("admins"),
 	}
 
+	if unknown := unknownKeys(viper.AllKeys()); len(unknown) > 0 {
+		cfg.Warnings = append(cfg.Warnings, fmt.Sprintf("unknown config keys ignored: %s", strings.Join(unknown, ", ")))
+	}
+
 	// Apply defaults
+	if level := viper.GetString("log.level"); level != "" {
+		if err := cfg.Log.Level.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("log.level must be debug, info, warn or error, got %q", level)
+		}
+	}
+	switch cfg.Log.Format {
+	case "":
+		cfg.Log.Format = LogFormatText
+	case LogFormatText, LogFormatJSON:
+	default:
+		return nil, fmt.Errorf("log.format must be %q or %q, got %q",
+			LogFormatText, LogFormatJSON, cfg.Log.Format)
+	}
 	if cfg.Database.Path == "" {
 		cfg.Database.Path = "eggbot.db"
 	}
-	if len(cfg.Nostr.Relays) == 0 {
-		cfg.Nostr.Relays = []string{"wss://relay.damus.io"}
+	if len(cfg.Nostr.PrimaryRelays) == 0 {
+		if len(cfg.Nostr.Relays) == 0 {
+			cfg.Nostr.Relays = []string{"wss://relay.damus.io"}
+		}
+		cfg.Nostr.PrimaryRelays = cfg.Nostr.Relays
+	}
+	cfg.Nostr.Relays = slices.Clone(cfg.Nostr.PrimaryRelays)
+	for _, url := range cfg.Nostr.FallbackRelays {
+		if !slices.Contains(cfg.Nostr.Relays, url) {
+			cfg.Nostr.Relays = append(cfg.Nostr.Relays, url)
+		}
+	}
+	if cfg.Nostr.MaxMessageBytes == 0 {
+		cfg.Nostr.MaxMessageBytes = dm.DefaultMaxMessageBytes
+	}
+	switch cfg.Nostr.LegacyEncryption {
+	case "":
+		cfg.Nostr.LegacyEncryption = LegacyEncryptionNIP04
+	case LegacyEncryptionNIP04, LegacyEncryptionNIP44:
+	default:
+		return nil, fmt.Errorf("nostr.legacy_encryption must be %q or %q, got %q",
+			LegacyEncryptionNIP04, LegacyEncryptionNIP44, cfg.Nostr.LegacyEncryption)
+	}
+	switch cfg.Announce.Mode {
+	case "":
+		cfg.Announce.Mode = AnnounceOff
+	case AnnounceOff, AnnounceNote, AnnounceReplaceable:
+	default:
+		return nil, fmt.Errorf("announce.mode must be %q, %q or %q, got %q",
+			AnnounceOff, AnnounceNote, AnnounceReplaceable, cfg.Announce.Mode)
+	}
+	if !viper.IsSet("announce.min_interval") {
+		cfg.Announce.MinInterval = DefaultAnnounceMinInterval
+	}
+	if !viper.IsSet("nostr.dedup_ttl") {
+		cfg.Nostr.DedupTTL = DefaultDedupTTL
+	}
+	if !viper.IsSet("nostr.dedup_max_entries") {
+		cfg.Nostr.DedupMaxEntries = DefaultDedupMaxEntries
+	}
+	if !viper.IsSet("nostr.since_overlap") {
+		cfg.Nostr.SinceOverlap = DefaultSinceOverlap
+	}
+	if !viper.IsSet("orders.expiry_minutes") {
+		cfg.Orders.ExpiryMinutes = DefaultOrderExpiryMinutes
+	}
+	if !viper.IsSet("permissions.role_cache_ttl") {
+		cfg.Permissions.RoleCacheTTL = DefaultRoleCacheTTL
+	}
+	if !viper.IsSet("pricing.allowed_quantities") {
+		cfg.Pricing.AllowedQuantities = slices.Clone(DefaultAllowedQuantities)
+	}
+	if !viper.IsSet("health.max_silence") {
+		cfg.Health.MaxSilence = DefaultHealthMaxSilence
 	}
 	if cfg.Pricing.SatsPerHalfDozen == 0 {
 		cfg.Pricing.SatsPerHalfDozen = 3200
 	}
+	cfg.Display.Location = time.Local
+	if cfg.Display.Timezone != "" {
+		loc, err := time.LoadLocation(cfg.Display.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("display.timezone %q: %w", cfg.Display.Timezone, err)
+		}
+		cfg.Display.Location = loc
+	}
+	cfg.Messages.Catalog = messages.Default()
+	if cfg.Messages.Path != "" {
+		catalog, err := messages.Load(cfg.Messages.Path)
+		if err != nil {
+			return nil, fmt.Errorf("messages.path: %w", err)
+		}
+		cfg.Messages.Catalog = catalog
+	}
+	if cfg.Messages.Locale != "" {
+		if !messages.Supported(cfg.Messages.Locale) {
+			return nil, fmt.Errorf("messages.locale %q: must be one of %s", cfg.Messages.Locale, strings.Join(messages.Languages(), ", "))
+		}
+		cfg.Messages.Catalog = cfg.Messages.Catalog.In(cfg.Messages.Locale)
+	}
 
 	return cfg, nil
 }
 
-// LoadWithSecrets loads config and derives bot keypair from EGGBOT_NSEC env var.
-// Returns error if EGGBOT_NSEC is not set or invalid.
+// LoadWithSecrets loads config and derives bot keypair from EGGBOT_NSEC, the
+// decrypted EGGBOT_NCRYPTSEC or nostr.nsec_file.
+// Returns error if the config is invalid (all problems joined) or no key is set or it is invalid.
 func LoadWithSecrets() (*Config, error) {
 	cfg, err := Load()
 	if err != nil {
 		return nil, err
 	}
 
-	nsec := os.Getenv("EGGBOT_NSEC")
-	if nsec == "" {
-		return nil, fmt.Errorf("EGGBOT_NSEC environment variable is required")
+	normalizeAdmins(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
 	}
 
-	// Decode nsec to get hex secret key
-	prefix, value, err := nip19.Decode(nsec)
+	secretHex, secretSource, err := botSecret(cfg.Nostr.NsecFile)
 	if err != nil {
-		return nil, fmt.Errorf("invalid EGGBOT_NSEC: %w", err)
-	}
-	if prefix != "nsec" {
-		return nil, fmt.Errorf("EGGBOT_NSEC must be an nsec, got %s", prefix)
+		return nil, err
 	}
-
-	secretHex, ok := value.(string)
-	if !ok {
-		return nil, fmt.Errorf("failed to decode nsec value")
+	if secretSource == nsecFileKey {
+		if warning := nsecFileWarning(cfg.Nostr.NsecFile); warning != "" {
+			cfg.Warnings = append(cfg.Warnings, warning)
+		}
 	}
 
 	// Derive public key from secret
@@ -136,7 +477,7 @@ func LoadWithSecrets() (*Config, error) {
 			return nil, fmt.Errorf("failed to decode npub value")
 		}
 		if configPubkeyHex != pubkeyHex {
-			return nil, fmt.Errorf("EGGBOT_NSEC does not match nostr.bot_npub in config")
+			return nil, fmt.Errorf("%s does not match nostr.bot_npub in config", secretSource)
 		}
 	} else {
 		cfg.Nostr.BotNpub = derivedNpub
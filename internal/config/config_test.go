@@ -0,0 +1,336 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/buildtall-systems/eggbot/internal/messages"
+	"github.com/spf13/viper"
+)
+
+func TestLoad_LogSettings(t *testing.T) {
+	tests := []struct {
+		name       string
+		level      string
+		format     string
+		wantLevel  slog.Level
+		wantFormat string
+		wantErr    bool
+	}{
+		{name: "defaults", wantLevel: slog.LevelInfo, wantFormat: LogFormatText},
+		{name: "debug json", level: "debug", format: "json", wantLevel: slog.LevelDebug, wantFormat: LogFormatJSON},
+		{name: "warn text", level: "WARN", format: "text", wantLevel: slog.LevelWarn, wantFormat: LogFormatText},
+		{name: "bad level", level: "loud", wantErr: true},
+		{name: "bad format", format: "xml", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			if tt.level != "" {
+				viper.Set("log.level", tt.level)
+			}
+			if tt.format != "" {
+				viper.Set("log.format", tt.format)
+			}
+
+			cfg, err := Load()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Log.Level != tt.wantLevel {
+				t.Errorf("Level = %v, want %v", cfg.Log.Level, tt.wantLevel)
+			}
+			if cfg.Log.Format != tt.wantFormat {
+				t.Errorf("Format = %q, want %q", cfg.Log.Format, tt.wantFormat)
+			}
+		})
+	}
+}
+
+func TestLoad_OrderExpiryMinutes(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Orders.ExpiryMinutes != DefaultOrderExpiryMinutes {
+		t.Errorf("default ExpiryMinutes = %d, want %d", cfg.Orders.ExpiryMinutes, DefaultOrderExpiryMinutes)
+	}
+
+	viper.Set("orders.expiry_minutes", 30)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Orders.ExpiryMinutes != 30 {
+		t.Errorf("ExpiryMinutes = %d, want 30", cfg.Orders.ExpiryMinutes)
+	}
+}
+
+func TestLoad_Dedup(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Nostr.DedupTTL != DefaultDedupTTL || cfg.Nostr.DedupMaxEntries != DefaultDedupMaxEntries {
+		t.Errorf("defaults = %s, %d", cfg.Nostr.DedupTTL, cfg.Nostr.DedupMaxEntries)
+	}
+
+	// An explicit 0 disables the in-memory dedup rather than taking the default
+	viper.Set("nostr.dedup_ttl", "0s")
+	viper.Set("nostr.dedup_max_entries", 500)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Nostr.DedupTTL != 0 || cfg.Nostr.DedupMaxEntries != 500 {
+		t.Errorf("configured = %s, %d, want 0s, 500", cfg.Nostr.DedupTTL, cfg.Nostr.DedupMaxEntries)
+	}
+}
+
+func TestLoad_SinceOverlap(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Nostr.SinceOverlap != DefaultSinceOverlap {
+		t.Errorf("default = %s, want %s", cfg.Nostr.SinceOverlap, DefaultSinceOverlap)
+	}
+
+	// An explicit 0 resumes exactly after each high water mark
+	viper.Set("nostr.since_overlap", "0s")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Nostr.SinceOverlap != 0 {
+		t.Errorf("configured = %s, want 0s", cfg.Nostr.SinceOverlap)
+	}
+}
+
+func TestLoad_DisplayTimezone(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Display.Location != time.Local {
+		t.Errorf("default location = %s, want Local", cfg.Display.Location)
+	}
+
+	viper.Set("display.timezone", "Europe/Berlin")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Display.Location.String() != "Europe/Berlin" {
+		t.Errorf("location = %s, want Europe/Berlin", cfg.Display.Location)
+	}
+
+	viper.Set("display.timezone", "Mars/Olympus")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "display.timezone") {
+		t.Errorf("expected a display.timezone error, got %v", err)
+	}
+}
+
+func TestLoad_Announce(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Announce.Mode != AnnounceOff || cfg.Announce.MinInterval != DefaultAnnounceMinInterval {
+		t.Errorf("defaults = %+v, want off every %s", cfg.Announce, DefaultAnnounceMinInterval)
+	}
+
+	viper.Set("announce.mode", "replaceable")
+	viper.Set("announce.min_interval", "15m")
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Announce.Mode != AnnounceReplaceable || cfg.Announce.MinInterval != 15*time.Minute {
+		t.Errorf("announce = %+v", cfg.Announce)
+	}
+
+	viper.Set("announce.mode", "billboard")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "announce.mode") {
+		t.Errorf("expected an announce.mode error, got %v", err)
+	}
+}
+
+func TestLoad_Messages(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Messages.Catalog != messages.Default() {
+		t.Error("without messages.path the built-in messages should be used")
+	}
+
+	path := filepath.Join(t.TempDir(), "messages.yaml")
+	if err := os.WriteFile(path, []byte(`order_cancelled: "Bestellung {{.OrderID}} storniert."`), 0o600); err != nil {
+		t.Fatalf("writing messages: %v", err)
+	}
+	viper.Set("messages.path", path)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Messages.Catalog.Render(messages.OrderCancelled, messages.OrderData{OrderID: 2}); got != "Bestellung 2 storniert." {
+		t.Errorf("order_cancelled = %q", got)
+	}
+
+	// A malformed file stops startup
+	if err := os.WriteFile(path, []byte(`order_cancelled: "{{.Order}}"`), 0o600); err != nil {
+		t.Fatalf("writing messages: %v", err)
+	}
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "messages.path") || !strings.Contains(err.Error(), "order_cancelled") {
+		t.Errorf("expected a messages.path error naming the message, got %v", err)
+	}
+}
+
+func TestLoad_MessagesLocale(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	viper.Set("messages.locale", "de")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := cfg.Messages.Catalog.Render(messages.OrderCancelled, messages.OrderData{OrderID: 2}); got != "Bestellung 2 storniert." {
+		t.Errorf("order_cancelled = %q, want the German text", got)
+	}
+
+	viper.Set("messages.locale", "fr")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "messages.locale") {
+		t.Errorf("expected a messages.locale error, got %v", err)
+	}
+}
+
+func TestLoad_PrimaryAndFallbackRelays(t *testing.T) {
+	tests := []struct {
+		name         string
+		relays       []string
+		primary      []string
+		fallback     []string
+		wantPrimary  []string
+		wantFallback []string
+		wantAll      []string
+	}{
+		{
+			name:        "default relay",
+			wantPrimary: []string{"wss://relay.damus.io"},
+			wantAll:     []string{"wss://relay.damus.io"},
+		},
+		{
+			name:        "relays are primary",
+			relays:      []string{"wss://a.example.com", "wss://b.example.com"},
+			wantPrimary: []string{"wss://a.example.com", "wss://b.example.com"},
+			wantAll:     []string{"wss://a.example.com", "wss://b.example.com"},
+		},
+		{
+			name:         "relays with fallback",
+			relays:       []string{"wss://a.example.com"},
+			fallback:     []string{"wss://c.example.com", "wss://a.example.com"},
+			wantPrimary:  []string{"wss://a.example.com"},
+			wantFallback: []string{"wss://c.example.com", "wss://a.example.com"},
+			wantAll:      []string{"wss://a.example.com", "wss://c.example.com"},
+		},
+		{
+			name:         "explicit primary",
+			relays:       []string{"wss://ignored.example.com"},
+			primary:      []string{"wss://b.example.com"},
+			fallback:     []string{"wss://c.example.com"},
+			wantPrimary:  []string{"wss://b.example.com"},
+			wantFallback: []string{"wss://c.example.com"},
+			wantAll:      []string{"wss://b.example.com", "wss://c.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			if tt.relays != nil {
+				viper.Set("nostr.relays", tt.relays)
+			}
+			if tt.primary != nil {
+				viper.Set("nostr.primary_relays", tt.primary)
+			}
+			if tt.fallback != nil {
+				viper.Set("nostr.fallback_relays", tt.fallback)
+			}
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if !slices.Equal(cfg.Nostr.PrimaryRelays, tt.wantPrimary) {
+				t.Errorf("PrimaryRelays = %v, want %v", cfg.Nostr.PrimaryRelays, tt.wantPrimary)
+			}
+			if !slices.Equal(cfg.Nostr.FallbackRelays, tt.wantFallback) {
+				t.Errorf("FallbackRelays = %v, want %v", cfg.Nostr.FallbackRelays, tt.wantFallback)
+			}
+			if !slices.Equal(cfg.Nostr.Relays, tt.wantAll) {
+				t.Errorf("Relays = %v, want %v", cfg.Nostr.Relays, tt.wantAll)
+			}
+		})
+	}
+}
+
+func TestLoad_FeaturesDefaultOff(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Features != (Features{}) {
+		t.Errorf("Features = %+v, want all off", cfg.Features)
+	}
+
+	viper.Set("features.enable_nip44", true)
+	viper.Set("features.enable_order_expiry", true)
+	viper.Set("features.enable_mentions", true)
+	viper.Set("features.enable_nip05", true)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := Features{EnableNIP44: true, EnableOrderExpiry: true, EnableMentions: true, EnableNIP05: true}
+	if cfg.Features != want {
+		t.Errorf("Features = %+v, want %+v", cfg.Features, want)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// readPassphrase prompts on stderr and reads a line from stdin with echo
+// turned off.
+func readPassphrase(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", ErrNoTerminal
+	}
+
+	_, _ = fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(fd)
+	_, _ = fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("reading passphrase: %w", err)
+	}
+	return string(passphrase), nil
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+)
+
+// Environment variables the bot's secret key is read from. Either the plain
+// nsec or a NIP-49 ncryptsec with its passphrase is given.
+const (
+	nsecEnv       = "EGGBOT_NSEC"
+	ncryptsecEnv  = "EGGBOT_NCRYPTSEC"
+	passphraseEnv = "EGGBOT_PASSPHRASE"
+)
+
+// nsecFileKey is the config key naming a file that holds the plain nsec, such
+// as a systemd credential.
+const nsecFileKey = "nostr.nsec_file"
+
+// Errors decrypting an ncryptsec.
+var (
+	ErrWrongPassphrase    = errors.New("wrong passphrase")
+	ErrMalformedNcryptsec = errors.New("malformed ncryptsec")
+)
+
+// ErrNoTerminal means a passphrase was needed but there is no terminal to
+// prompt on.
+var ErrNoTerminal = errors.New("no terminal to prompt for a passphrase on")
+
+// ncryptsecLength is the byte length of a version 2 NIP-49 payload: version,
+// log N, salt, nonce, key security byte and the encrypted key with its tag.
+const ncryptsecLength = 1 + 1 + 16 + 24 + 1 + 48
+
+// botSecret returns the bot's secret key in hex from EGGBOT_NSEC, or from
+// EGGBOT_NCRYPTSEC decrypted with EGGBOT_PASSPHRASE or a passphrase typed at
+// the terminal, or else from nsecFile, and the variable or key it came from.
+// The environment wins over the file, so a key can be tried out without
+// editing the config.
+func botSecret(nsecFile string) (secretHex, source string, err error) {
+	nsec, ncryptsec := os.Getenv(nsecEnv), os.Getenv(ncryptsecEnv)
+	source = nsecEnv
+	if nsec == "" && ncryptsec == "" && nsecFile != "" {
+		if nsec, err = readNsecFile(nsecFile); err != nil {
+			return "", "", err
+		}
+		source = nsecFileKey
+	}
+	switch {
+	case nsec != "" && ncryptsec != "":
+		return "", "", fmt.Errorf("set %s or %s, not both", nsecEnv, ncryptsecEnv)
+	case ncryptsec != "":
+		passphrase, err := Passphrase("Passphrase for "+ncryptsecEnv+": ", false)
+		if errors.Is(err, ErrNoTerminal) {
+			return "", "", fmt.Errorf("%s is set but %s is not, and there is %w", ncryptsecEnv, passphraseEnv, err)
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if secretHex, err = DecryptNcryptsec(ncryptsec, passphrase); err != nil {
+			return "", "", fmt.Errorf("invalid %s: %w", ncryptsecEnv, err)
+		}
+		return secretHex, ncryptsecEnv, nil
+	case nsec == "":
+		return "", "", fmt.Errorf("%s or %s environment variable, or %s in config, is required", nsecEnv, ncryptsecEnv, nsecFileKey)
+	}
+
+	prefix, value, err := nip19.Decode(nsec)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid %s: %w", source, err)
+	}
+	if prefix != "nsec" {
+		return "", "", fmt.Errorf("%s must be an nsec, got %s", source, prefix)
+	}
+	secretHex, ok := value.(string)
+	if !ok {
+		return "", "", fmt.Errorf("failed to decode nsec value")
+	}
+	return secretHex, source, nil
+}
+
+// readNsecFile returns the contents of the nsec file without the trailing
+// newline editors and "echo" leave behind.
+func readNsecFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("%s %s does not exist", nsecFileKey, path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", nsecFileKey, err)
+	}
+	nsec := strings.TrimRightFunc(string(data), unicode.IsSpace)
+	if nsec == "" {
+		return "", fmt.Errorf("%s %s is empty", nsecFileKey, path)
+	}
+	return nsec, nil
+}
+
+// nsecFileWarning describes how the nsec file at path is open to more than
+// its owner reading and writing it, or returns "" when it isn't.
+func nsecFileWarning(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	if perm := info.Mode().Perm(); perm&^0o600 != 0 {
+		return fmt.Sprintf("%s %s has mode %04o; restrict it with chmod 600", nsecFileKey, path, perm)
+	}
+	return ""
+}
+
+// DecryptNcryptsec returns the hex secret key a NIP-49 ncryptsec holds. It
+// returns ErrMalformedNcryptsec for input that isn't a version 2 ncryptsec
+// and ErrWrongPassphrase when the passphrase doesn't open it.
+func DecryptNcryptsec(ncryptsec, passphrase string) (string, error) {
+	// nip49 reports both problems alike, so the format is checked first
+	prefix, bits5, err := bech32.DecodeNoLimit(ncryptsec)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedNcryptsec, err)
+	}
+	if prefix != "ncryptsec" {
+		return "", fmt.Errorf("%w: expected an ncryptsec, got %s", ErrMalformedNcryptsec, prefix)
+	}
+	data, err := bech32.ConvertBits(bits5, 5, 8, false)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedNcryptsec, err)
+	}
+	if len(data) != ncryptsecLength || data[0] != 0x02 {
+		return "", fmt.Errorf("%w: not a version 2 ncryptsec", ErrMalformedNcryptsec)
+	}
+
+	secretHex, err := nip49.Decrypt(ncryptsec, passphrase)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return secretHex, nil
+}
+
+// Passphrase returns EGGBOT_PASSPHRASE, or else reads a passphrase typed at
+// the terminal after prompt, without echoing it. With confirm, it is asked
+// for twice and must match. It returns ErrNoTerminal when stdin isn't a
+// terminal.
+func Passphrase(prompt string, confirm bool) (string, error) {
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := readPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases don't match")
+		}
+	}
+	if passphrase == "" {
+		return "", errors.New("passphrase must not be empty")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+	"github.com/spf13/viper"
+)
+
+// encryptTestKey returns a new secret key and its ncryptsec under
+// passphrase, at a scrypt cost low enough for tests.
+func encryptTestKey(t *testing.T, passphrase string) (secretHex, ncryptsec string) {
+	t.Helper()
+	secretHex = nostr.GeneratePrivateKey()
+	ncryptsec, err := nip49.Encrypt(secretHex, passphrase, 4, nip49.ClientDoesNotTrackThisData)
+	if err != nil {
+		t.Fatalf("encrypting key: %v", err)
+	}
+	return secretHex, ncryptsec
+}
+
+func TestDecryptNcryptsec(t *testing.T) {
+	secretHex, ncryptsec := encryptTestKey(t, "correct horse")
+
+	got, err := DecryptNcryptsec(ncryptsec, "correct horse")
+	if err != nil || got != secretHex {
+		t.Fatalf("DecryptNcryptsec = %s, %v; want %s", got, err, secretHex)
+	}
+
+	if _, err := DecryptNcryptsec(ncryptsec, "battery staple"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("wrong passphrase: err = %v, want ErrWrongPassphrase", err)
+	}
+
+	nsec, _ := nip19.EncodePrivateKey(secretHex)
+	badChecksum := ncryptsec[:len(ncryptsec)-1] + "q"
+	if strings.HasSuffix(ncryptsec, "q") {
+		badChecksum = ncryptsec[:len(ncryptsec)-1] + "p"
+	}
+	for name, input := range map[string]string{
+		"not bech32":    "ncryptsec1notreally",
+		"nsec":          nsec,
+		"bad checksum":  badChecksum,
+		"empty":         "",
+		"truncated key": ncryptsec[:len(ncryptsec)-20],
+	} {
+		if _, err := DecryptNcryptsec(input, "correct horse"); !errors.Is(err, ErrMalformedNcryptsec) {
+			t.Errorf("%s: err = %v, want ErrMalformedNcryptsec", name, err)
+		}
+	}
+}
+
+func TestLoadWithSecrets_Ncryptsec(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	secretHex, ncryptsec := encryptTestKey(t, "correct horse")
+	t.Setenv("EGGBOT_NSEC", "")
+	t.Setenv("EGGBOT_NCRYPTSEC", ncryptsec)
+	t.Setenv("EGGBOT_PASSPHRASE", "correct horse")
+
+	cfg, err := LoadWithSecrets()
+	if err != nil {
+		t.Fatalf("LoadWithSecrets: %v", err)
+	}
+	pubkeyHex, _ := nostr.GetPublicKey(secretHex)
+	if cfg.Nostr.BotSecretHex != secretHex || cfg.Nostr.BotPubkeyHex != pubkeyHex {
+		t.Errorf("secret = %s, pubkey = %s; want the encrypted key", cfg.Nostr.BotSecretHex, cfg.Nostr.BotPubkeyHex)
+	}
+
+	// A bot_npub from another key names the variable the key came from
+	otherPubkeyHex, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	otherNpub, _ := nip19.EncodePublicKey(otherPubkeyHex)
+	viper.Set("nostr.bot_npub", otherNpub)
+	if _, err := LoadWithSecrets(); err == nil || !strings.Contains(err.Error(), "EGGBOT_NCRYPTSEC does not match") {
+		t.Errorf("expected a mismatch naming EGGBOT_NCRYPTSEC, got %v", err)
+	}
+}
+
+func TestLoadWithSecrets_SecretErrors(t *testing.T) {
+	_, ncryptsec := encryptTestKey(t, "correct horse")
+	tests := []struct {
+		name       string
+		nsec       string
+		ncryptsec  string
+		passphrase string
+		wantErr    string
+	}{
+		{"neither", "", "", "", "EGGBOT_NSEC or EGGBOT_NCRYPTSEC environment variable, or nostr.nsec_file in config, is required"},
+		{"both", testNsec, ncryptsec, "correct horse", "not both"},
+		{"wrong passphrase", "", ncryptsec, "battery staple", "invalid EGGBOT_NCRYPTSEC: wrong passphrase"},
+		{"malformed", "", "ncryptsec1xyz", "correct horse", "invalid EGGBOT_NCRYPTSEC: malformed ncryptsec"},
+		// Tests don't run on a terminal, so there is nowhere to prompt
+		{"no passphrase", "", ncryptsec, "", "EGGBOT_PASSPHRASE is not"},
+		{"plain nsec still checked", "nsec1bogus", "", "", "invalid EGGBOT_NSEC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			viper.Reset()
+			t.Cleanup(viper.Reset)
+			t.Setenv("EGGBOT_NSEC", tt.nsec)
+			t.Setenv("EGGBOT_NCRYPTSEC", tt.ncryptsec)
+			t.Setenv("EGGBOT_PASSPHRASE", tt.passphrase)
+
+			_, err := LoadWithSecrets()
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadWithSecrets_NsecFile(t *testing.T) {
+	secretHex := nostr.GeneratePrivateKey()
+	nsec, _ := nip19.EncodePrivateKey(secretHex)
+	dir := t.TempDir()
+	writeFile := func(name, content string, mode os.FileMode) string {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		// WriteFile's mode is subject to the umask
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	load := func(t *testing.T, nsecFile, env string) (*Config, error) {
+		t.Helper()
+		viper.Reset()
+		t.Cleanup(viper.Reset)
+		viper.Set("nostr.nsec_file", nsecFile)
+		t.Setenv("EGGBOT_NSEC", env)
+		t.Setenv("EGGBOT_NCRYPTSEC", "")
+		return LoadWithSecrets()
+	}
+
+	t.Run("trailing whitespace trimmed", func(t *testing.T) {
+		cfg, err := load(t, writeFile("nsec", nsec+"\n \n", 0o600), "")
+		if err != nil {
+			t.Fatalf("LoadWithSecrets: %v", err)
+		}
+		if cfg.Nostr.BotSecretHex != secretHex {
+			t.Errorf("secret = %s, want the file's key", cfg.Nostr.BotSecretHex)
+		}
+		if len(cfg.Warnings) != 0 {
+			t.Errorf("unexpected warnings for a 0600 file: %v", cfg.Warnings)
+		}
+	})
+
+	t.Run("owner-only read is strict enough", func(t *testing.T) {
+		cfg, err := load(t, writeFile("nsec-0400", nsec, 0o400), "")
+		if err != nil || len(cfg.Warnings) != 0 {
+			t.Errorf("LoadWithSecrets = %v; warnings %v", err, cfg.Warnings)
+		}
+	})
+
+	t.Run("loose permissions warn", func(t *testing.T) {
+		cfg, err := load(t, writeFile("nsec-0644", nsec, 0o644), "")
+		if err != nil {
+			t.Fatalf("LoadWithSecrets: %v", err)
+		}
+		if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "mode 0644") {
+			t.Errorf("warnings = %v, want one about mode 0644", cfg.Warnings)
+		}
+	})
+
+	t.Run("environment wins", func(t *testing.T) {
+		// The file isn't read, so a missing one doesn't matter
+		cfg, err := load(t, filepath.Join(dir, "missing"), testNsec)
+		if err != nil {
+			t.Fatalf("LoadWithSecrets: %v", err)
+		}
+		if cfg.Nostr.BotSecretHex == secretHex {
+			t.Error("the file's key was used over EGGBOT_NSEC")
+		}
+
+		// A mismatch names where the key came from
+		otherPubkeyHex, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+		otherNpub, _ := nip19.EncodePublicKey(otherPubkeyHex)
+		viper.Set("nostr.bot_npub", otherNpub)
+		if _, err := LoadWithSecrets(); err == nil || !strings.Contains(err.Error(), "EGGBOT_NSEC does not match") {
+			t.Errorf("expected a mismatch naming EGGBOT_NSEC, got %v", err)
+		}
+	})
+
+	errorTests := []struct {
+		name    string
+		path    string
+		wantErr string
+	}{
+		{"missing", filepath.Join(dir, "missing"), "nostr.nsec_file " + filepath.Join(dir, "missing") + " does not exist"},
+		{"unreadable", dir, "reading nostr.nsec_file"},
+		{"empty", writeFile("empty", "\n", 0o600), "is empty"},
+		{"not an nsec", writeFile("garbage", "nsec1bogus", 0o600), "invalid nostr.nsec_file"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := load(t, tt.path, ""); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
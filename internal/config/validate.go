@@ -0,0 +1,169 @@
+package config
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// Validate checks the configuration like ValidateConfig and joins every
+// problem into one error, or returns nil if it is valid.
+func (c *Config) Validate() error {
+	return errors.Join(ValidateConfig(c)...)
+}
+
+// ValidateConfig checks the loaded configuration and returns every problem
+// found, so an operator can fix them all in one pass. Returns nil if valid.
+func ValidateConfig(cfg *Config) []error {
+	var errs []error
+
+	if len(cfg.Nostr.Relays) == 0 {
+		errs = append(errs, fmt.Errorf("nostr.relays: at least one relay URL is required"))
+	}
+	for _, relay := range cfg.Nostr.Relays {
+		if !isRelayURL(relay) {
+			errs = append(errs, fmt.Errorf("nostr.relays: %q is not a ws:// or wss:// URL", relay))
+		}
+	}
+
+	for i, admin := range cfg.Admins {
+		if _, err := normalizeAdminNpub(admin); err != nil {
+			errs = append(errs, fmt.Errorf("admins[%d]: %w", i, err))
+		}
+	}
+
+	if cfg.Lightning.LightningAddress != "" && !isLightningAddress(cfg.Lightning.LightningAddress) {
+		errs = append(errs, fmt.Errorf("lightning.address: %q is not a valid lightning address (expected user@domain)",
+			cfg.Lightning.LightningAddress))
+	}
+	for i, address := range cfg.Lightning.FallbackAddresses {
+		if !isLightningAddress(address) {
+			errs = append(errs, fmt.Errorf("lightning.fallback_addresses[%d]: %q is not a valid lightning address (expected user@domain)",
+				i, address))
+		}
+	}
+
+	if cfg.Pricing.SatsPerHalfDozen <= 0 {
+		errs = append(errs, fmt.Errorf("pricing.sats_per_half_dozen: must be greater than 0, got %d", cfg.Pricing.SatsPerHalfDozen))
+	}
+
+	if len(cfg.Pricing.AllowedQuantities) == 0 {
+		errs = append(errs, fmt.Errorf("pricing.allowed_quantities: at least one quantity is required"))
+	}
+	for i, q := range cfg.Pricing.AllowedQuantities {
+		if q <= 0 {
+			errs = append(errs, fmt.Errorf("pricing.allowed_quantities[%d]: must be greater than 0, got %d", i, q))
+		}
+	}
+
+	if cfg.Nostr.MaxMessageBytes < dm.MinMessageBytes {
+		errs = append(errs, fmt.Errorf("nostr.max_message_bytes: must be at least %d, got %d", dm.MinMessageBytes, cfg.Nostr.MaxMessageBytes))
+	}
+	if cfg.Nostr.DedupTTL < 0 {
+		errs = append(errs, fmt.Errorf("nostr.dedup_ttl: must not be negative, got %s", cfg.Nostr.DedupTTL))
+	}
+	if cfg.Nostr.DedupTTL > 0 && cfg.Nostr.DedupMaxEntries <= 0 {
+		errs = append(errs, fmt.Errorf("nostr.dedup_max_entries: must be greater than 0, got %d", cfg.Nostr.DedupMaxEntries))
+	}
+	if cfg.Nostr.SinceOverlap < 0 {
+		errs = append(errs, fmt.Errorf("nostr.since_overlap: must not be negative, got %s", cfg.Nostr.SinceOverlap))
+	}
+
+	if cfg.Orders.ExpiryMinutes <= 0 {
+		errs = append(errs, fmt.Errorf("orders.expiry_minutes: must be greater than 0, got %d", cfg.Orders.ExpiryMinutes))
+	}
+	if cfg.Orders.LowInventoryThreshold < 0 {
+		errs = append(errs, fmt.Errorf("orders.low_inventory_threshold: must not be negative, got %d", cfg.Orders.LowInventoryThreshold))
+	}
+
+	if cfg.Health.Listen != "" && cfg.Health.MaxSilence <= 0 {
+		errs = append(errs, fmt.Errorf("health.max_silence: must be greater than 0, got %s", cfg.Health.MaxSilence))
+	}
+
+	if cfg.Database.Path == "" {
+		errs = append(errs, fmt.Errorf("database.path: must not be empty"))
+	} else if err := checkDatabaseDir(cfg.Database.Path); err != nil {
+		errs = append(errs, fmt.Errorf("database.path: %w", err))
+	}
+
+	return errs
+}
+
+// normalizeAdminNpub returns an admin key in npub form. A 64-character hex
+// pubkey is encoded as an npub; a valid npub is returned unchanged.
+// Surrounding whitespace, easily left behind when pasting a key, is trimmed.
+func normalizeAdminNpub(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 64 {
+		if _, err := hex.DecodeString(s); err == nil {
+			return nip19.EncodePublicKey(strings.ToLower(s))
+		}
+	}
+	if prefix, _, err := nip19.Decode(s); err == nil && prefix == "npub" {
+		return s, nil
+	}
+	return "", fmt.Errorf("%q is not a valid npub or hex pubkey", s)
+}
+
+// normalizeAdmins rewrites hex admin keys as npubs. Invalid entries are left
+// as they are for ValidateConfig to report.
+func normalizeAdmins(cfg *Config) {
+	for i, admin := range cfg.Admins {
+		if npub, err := normalizeAdminNpub(admin); err == nil {
+			cfg.Admins[i] = npub
+		}
+	}
+}
+
+// isRelayURL reports whether s is a ws:// or wss:// URL with a host.
+func isRelayURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "ws" || u.Scheme == "wss") && u.Host != ""
+}
+
+// checkDatabaseDir returns an error unless the directory the database file
+// goes in exists, or can be created because its nearest existing ancestor is
+// a directory.
+func checkDatabaseDir(path string) error {
+	if path == ":memory:" {
+		return nil
+	}
+	dir := filepath.Dir(path)
+	for {
+		info, err := os.Stat(dir)
+		if err == nil {
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			return nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return err
+		}
+		dir = parent
+	}
+}
+
+// isLightningAddress reports whether s looks like user@domain.
+func isLightningAddress(s string) bool {
+	user, domain, ok := strings.Cut(s, "@")
+	if !ok || user == "" || domain == "" {
+		return false
+	}
+	return !strings.ContainsAny(domain, "@/ \t") && !strings.ContainsAny(user, "/ \t")
+}
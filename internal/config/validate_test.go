@@ -0,0 +1,378 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/dm"
+	"github.com/spf13/viper"
+)
+
+// testAdminBHex is the hex pubkey of testAdminB.
+const testAdminBHex = "0000000000000000000000000000000000000000000000000000000000000002"
+
+// testNsec is a valid secret key for tests that load secrets.
+const testNsec = "nsec1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsmhltgl"
+
+// validConfig returns a config that passes ValidateConfig.
+func validConfig() *Config {
+	return &Config{
+		Database: DatabaseConfig{Path: "eggbot.db"},
+		Nostr:    NostrConfig{Relays: []string{"wss://relay.example.com"}, MaxMessageBytes: dm.DefaultMaxMessageBytes},
+		Lightning: LightningConfig{
+			LightningAddress:  "eggs@getalby.com",
+			FallbackAddresses: []string{"eggs@walletofsatoshi.com"},
+		},
+		Pricing: PricingConfig{SatsPerHalfDozen: 3200, AllowedQuantities: []int{6, 12}},
+		Orders:  OrdersConfig{ExpiryMinutes: DefaultOrderExpiryMinutes},
+		Admins:  []string{testAdminA},
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	notADir := filepath.Join(t.TempDir(), "eggbot.db")
+	if err := os.WriteFile(notADir, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string // empty = valid
+	}{
+		{
+			name:   "valid config",
+			modify: func(*Config) {},
+		},
+		{
+			name:    "no relays",
+			modify:  func(c *Config) { c.Nostr.Relays = nil },
+			wantErr: "nostr.relays",
+		},
+		{
+			name:    "relay over https",
+			modify:  func(c *Config) { c.Nostr.Relays = append(c.Nostr.Relays, "https://relay.example.com") },
+			wantErr: `nostr.relays: "https://relay.example.com"`,
+		},
+		{
+			name:    "relay without scheme",
+			modify:  func(c *Config) { c.Nostr.Relays = []string{"relay.example.com"} },
+			wantErr: "not a ws:// or wss:// URL",
+		},
+		{
+			name:    "relay URL that doesn't parse",
+			modify:  func(c *Config) { c.Nostr.Relays = []string{"wss://relay example.com/%"} },
+			wantErr: "nostr.relays",
+		},
+		{
+			name:   "plain ws relay",
+			modify: func(c *Config) { c.Nostr.Relays = []string{"ws://127.0.0.1:7777"} },
+		},
+		{
+			name:    "admin not an npub",
+			modify:  func(c *Config) { c.Admins = append(c.Admins, "nsec1abc") },
+			wantErr: "admins[1]",
+		},
+		{
+			name:   "admin as hex pubkey",
+			modify: func(c *Config) { c.Admins = append(c.Admins, testAdminBHex) },
+		},
+		{
+			name:   "admin with trailing space",
+			modify: func(c *Config) { c.Admins = []string{testAdminA + " "} },
+		},
+		{
+			name:    "lightning address without domain",
+			modify:  func(c *Config) { c.Lightning.LightningAddress = "eggs@" },
+			wantErr: "lightning.address",
+		},
+		{
+			name:    "lightning address is a URL",
+			modify:  func(c *Config) { c.Lightning.LightningAddress = "https://getalby.com/eggs" },
+			wantErr: "lightning.address",
+		},
+		{
+			name:   "empty lightning address is allowed (zap-only)",
+			modify: func(c *Config) { c.Lightning.LightningAddress = "" },
+		},
+		{
+			name:    "invalid fallback address",
+			modify:  func(c *Config) { c.Lightning.FallbackAddresses = []string{"eggs"} },
+			wantErr: "lightning.fallback_addresses[0]",
+		},
+		{
+			name:    "zero price",
+			modify:  func(c *Config) { c.Pricing.SatsPerHalfDozen = 0 },
+			wantErr: "pricing.sats_per_half_dozen",
+		},
+		{
+			name:    "negative price",
+			modify:  func(c *Config) { c.Pricing.SatsPerHalfDozen = -1 },
+			wantErr: "pricing.sats_per_half_dozen",
+		},
+		{
+			name:    "no allowed quantities",
+			modify:  func(c *Config) { c.Pricing.AllowedQuantities = nil },
+			wantErr: "pricing.allowed_quantities",
+		},
+		{
+			name:    "non-positive allowed quantity",
+			modify:  func(c *Config) { c.Pricing.AllowedQuantities = []int{6, 0} },
+			wantErr: "pricing.allowed_quantities[1]",
+		},
+		{
+			name:    "zero order expiry",
+			modify:  func(c *Config) { c.Orders.ExpiryMinutes = 0 },
+			wantErr: "orders.expiry_minutes",
+		},
+		{
+			name:    "negative low inventory threshold",
+			modify:  func(c *Config) { c.Orders.LowInventoryThreshold = -1 },
+			wantErr: "orders.low_inventory_threshold",
+		},
+		{
+			name:    "health endpoint without max silence",
+			modify:  func(c *Config) { c.Health = HealthConfig{Listen: "127.0.0.1:8080"} },
+			wantErr: "health.max_silence",
+		},
+		{
+			name:   "health endpoint disabled ignores max silence",
+			modify: func(c *Config) { c.Health = HealthConfig{} },
+		},
+		{
+			name:    "message budget too small for a part marker",
+			modify:  func(c *Config) { c.Nostr.MaxMessageBytes = 10 },
+			wantErr: "nostr.max_message_bytes: must be at least 14, got 10",
+		},
+		{
+			name:    "negative message budget",
+			modify:  func(c *Config) { c.Nostr.MaxMessageBytes = -1 },
+			wantErr: "nostr.max_message_bytes",
+		},
+		{
+			name:   "smallest message budget",
+			modify: func(c *Config) { c.Nostr.MaxMessageBytes = dm.MinMessageBytes },
+		},
+		{
+			name:    "negative dedup TTL",
+			modify:  func(c *Config) { c.Nostr.DedupTTL = -time.Minute },
+			wantErr: "nostr.dedup_ttl",
+		},
+		{
+			name:    "dedup without max entries",
+			modify:  func(c *Config) { c.Nostr.DedupTTL, c.Nostr.DedupMaxEntries = time.Minute, 0 },
+			wantErr: "nostr.dedup_max_entries",
+		},
+		{
+			name:   "dedup disabled ignores max entries",
+			modify: func(c *Config) { c.Nostr.DedupTTL, c.Nostr.DedupMaxEntries = 0, 0 },
+		},
+		{
+			name:    "negative since overlap",
+			modify:  func(c *Config) { c.Nostr.SinceOverlap = -time.Minute },
+			wantErr: "nostr.since_overlap",
+		},
+		{
+			name:    "empty database path",
+			modify:  func(c *Config) { c.Database.Path = "" },
+			wantErr: "database.path",
+		},
+		{
+			name:   "database directory can be created",
+			modify: func(c *Config) { c.Database.Path = filepath.Join(t.TempDir(), "var", "eggbot.db") },
+		},
+		{
+			name:    "database directory is a file",
+			modify:  func(c *Config) { c.Database.Path = filepath.Join(notADir, "eggbot.db") },
+			wantErr: "is not a directory",
+		},
+		{
+			name:    "database directory under a file",
+			modify:  func(c *Config) { c.Database.Path = filepath.Join(notADir, "var", "eggbot.db") },
+			wantErr: "database.path",
+		},
+		{
+			name:   "in-memory database",
+			modify: func(c *Config) { c.Database.Path = ":memory:" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			errs := ValidateConfig(cfg)
+
+			if tt.wantErr == "" {
+				if len(errs) != 0 {
+					t.Errorf("expected valid config, got %v", errs)
+				}
+				return
+			}
+			if len(errs) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+			}
+			if !strings.Contains(errs[0].Error(), tt.wantErr) {
+				t.Errorf("error = %q, want containing %q", errs[0], tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNormalizeAdminNpub(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{name: "npub passes through", input: testAdminA, want: testAdminA},
+		{name: "hex converted", input: testAdminBHex, want: testAdminB},
+		{name: "uppercase hex converted", input: strings.ToUpper(testAdminBHex), want: testAdminB},
+		{name: "whitespace trimmed", input: " " + testAdminA + "\n", want: testAdminA},
+		{name: "hex whitespace trimmed", input: testAdminBHex + "\t", want: testAdminB},
+		{name: "nsec rejected", input: testNsec, wantErr: true},
+		{name: "short hex rejected", input: testAdminBHex[:62], wantErr: true},
+		{name: "non-hex 64 chars rejected", input: strings.Repeat("g", 64), wantErr: true},
+		{name: "npub with bad checksum rejected", input: testAdminA[:len(testAdminA)-1] + "q", wantErr: true},
+		{name: "empty rejected", input: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeAdminNpub(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("normalizeAdminNpub(%q) = %q, want error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("normalizeAdminNpub(%q): %v", tt.input, err)
+			}
+			if got != tt.want {
+				t.Errorf("normalizeAdminNpub(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadWithSecrets_NormalizesHexAdmins(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("admins", []string{testAdminA + " ", testAdminBHex})
+	t.Setenv("EGGBOT_NSEC", testNsec)
+
+	cfg, err := LoadWithSecrets()
+	if err != nil {
+		t.Fatalf("LoadWithSecrets: %v", err)
+	}
+	if len(cfg.Admins) != 2 || cfg.Admins[0] != testAdminA || cfg.Admins[1] != testAdminB {
+		t.Errorf("Admins = %v, want [%s %s]", cfg.Admins, testAdminA, testAdminB)
+	}
+}
+
+func TestValidateConfig_ReportsAllErrors(t *testing.T) {
+	cfg := validConfig()
+	cfg.Nostr.Relays = nil
+	cfg.Admins = []string{"bogus"}
+	cfg.Pricing.SatsPerHalfDozen = 0
+	cfg.Database.Path = ""
+
+	if errs := ValidateConfig(cfg); len(errs) != 4 {
+		t.Errorf("expected 4 errors, got %d: %v", len(errs), errs)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Errorf("Validate on a valid config: %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.Nostr.Relays = []string{"https://relay.example.com"}
+	cfg.Pricing.SatsPerHalfDozen = -5
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{"nostr.relays", "pricing.sats_per_half_dozen"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
+
+func TestUnknownKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []string
+	}{
+		{name: "none", keys: nil},
+		{name: "all known", keys: []string{"verbose", "admins", "pricing.sats_per_half_dozen", "nostr.relays"}},
+		{
+			name: "typos reported once each, sorted",
+			keys: []string{"pricng.sats_per_half_dozen", "admin", "pricng.allowed_quantities", "nostr.relays"},
+			want: []string{"admin", "pricng"},
+		},
+		{
+			name: "typos inside known sections",
+			keys: []string{"pricing.sats_per_halfdozen", "nostr.relays", "nostr.relay"},
+			want: []string{"nostr.relay", "pricing.sats_per_halfdozen"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unknownKeys(tt.keys); !slices.Equal(got, tt.want) {
+				t.Errorf("unknownKeys = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_WarnsOfUnknownKeys(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Warnings) != 0 {
+		t.Errorf("unexpected warnings: %v", cfg.Warnings)
+	}
+
+	viper.Set("admin", []string{testAdminA})
+	viper.Set("prcing.sats_per_half_dozen", 4000)
+	viper.Set("pricing.sats_per_halfdozen", 4000)
+	cfg, err = Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(cfg.Warnings) != 1 || !strings.Contains(cfg.Warnings[0], "unknown config keys ignored: admin, prcing, pricing.sats_per_halfdozen") {
+		t.Errorf("warnings = %v, want one naming admin, prcing and pricing.sats_per_halfdozen", cfg.Warnings)
+	}
+}
+
+func TestLoadWithSecrets_JoinsValidationErrors(t *testing.T) {
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	viper.Set("admins", []string{"bogus1", "bogus2"})
+	viper.Set("lightning.address", "not-an-address")
+	t.Setenv("EGGBOT_NSEC", "")
+	_ = os.Unsetenv("EGGBOT_NSEC")
+
+	_, err := LoadWithSecrets()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, want := range []string{"admins[0]", "admins[1]", "lightning.address"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q missing %q", err, want)
+		}
+	}
+}
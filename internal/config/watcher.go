@@ -0,0 +1,174 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// ConfigWatcher holds the running configuration and reloads its non-secret
+// settings (relays, admins, pricing) when signalled, typically with SIGHUP.
+// Each reload publishes a new *Config; a *Config returned by Config is never
+// modified afterwards, so readers always see a consistent snapshot.
+type ConfigWatcher struct {
+	mu       sync.RWMutex
+	cfg      *Config
+	load     func() (*Config, error)
+	onReload []func(oldCfg, newCfg *Config)
+}
+
+// NewConfigWatcher creates a watcher for cfg that reloads from the config file.
+func NewConfigWatcher(cfg *Config) *ConfigWatcher {
+	return &ConfigWatcher{
+		cfg:  cfg,
+		load: loadFromFile,
+	}
+}
+
+// loadFromFile re-reads and validates the config file.
+func loadFromFile() (*Config, error) {
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	cfg, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	normalizeAdmins(cfg)
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config:\n%w", err)
+	}
+	return cfg, nil
+}
+
+// Config returns the current configuration snapshot.
+func (w *ConfigWatcher) Config() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.cfg
+}
+
+// OnReload registers fn to run after each successful reload with the previous
+// and new configuration.
+func (w *ConfigWatcher) OnReload(fn func(oldCfg, newCfg *Config)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.onReload = append(w.onReload, fn)
+}
+
+// Reload re-reads the config and applies relays, admins and pricing.
+// Everything else, including secrets, keeps its running value; a config
+// that would change the bot's key is logged as an error and the running key
+// kept. On error the current config stays in effect.
+func (w *ConfigWatcher) Reload() error {
+	fresh, err := w.load()
+	if err != nil {
+		return fmt.Errorf("reloading config: %w", err)
+	}
+	if err := identityChange(w.Config(), fresh); err != nil {
+		slog.Error("config reload can't change the bot's key, keeping the running one", "error", err)
+	}
+
+	w.mu.Lock()
+	old := w.cfg
+	next := *old
+	next.Nostr.Relays = fresh.Nostr.Relays
+	next.Nostr.PrimaryRelays = fresh.Nostr.PrimaryRelays
+	next.Nostr.FallbackRelays = fresh.Nostr.FallbackRelays
+	next.Admins = fresh.Admins
+	next.Pricing = fresh.Pricing
+	w.cfg = &next
+	hooks := slices.Clone(w.onReload)
+	w.mu.Unlock()
+
+	if changes := reloadChanges(old, &next); len(changes) > 0 {
+		slog.Info("config reloaded", "changes", strings.Join(changes, "; "))
+	} else {
+		slog.Info("config reloaded, nothing changed")
+	}
+	for _, fn := range hooks {
+		fn(old, &next)
+	}
+	return nil
+}
+
+// identityChange returns an error when fresh names a different bot key than
+// running holds: another nostr.bot_npub, or another key in nostr.nsec_file.
+// An ncryptsec isn't decrypted again, since that may need the passphrase
+// typed in, and the environment can't change under a running process.
+func identityChange(running, fresh *Config) error {
+	if running.Nostr.BotPubkeyHex == "" {
+		return nil // Running without secrets, as one-off commands do
+	}
+	if fresh.Nostr.BotNpub != "" && fresh.Nostr.BotNpub != running.Nostr.BotNpub {
+		return fmt.Errorf("nostr.bot_npub changed to %s", fresh.Nostr.BotNpub)
+	}
+	if os.Getenv(ncryptsecEnv) != "" {
+		return nil
+	}
+	secretHex, source, err := botSecret(fresh.Nostr.NsecFile)
+	if err != nil {
+		return fmt.Errorf("re-reading the bot key: %w", err)
+	}
+	if secretHex != running.Nostr.BotSecretHex {
+		return fmt.Errorf("%s now holds a different key", source)
+	}
+	return nil
+}
+
+// reloadChanges describes what a reload changed, for the log.
+func reloadChanges(old, next *Config) []string {
+	var changes []string
+	if added, removed := diffStrings(old.Nostr.Relays, next.Nostr.Relays); len(added)+len(removed) > 0 {
+		changes = append(changes, fmt.Sprintf("relays +%v -%v", added, removed))
+	} else if !slices.Equal(old.Nostr.PrimaryRelays, next.Nostr.PrimaryRelays) {
+		changes = append(changes, fmt.Sprintf("primary relays now %v", next.Nostr.PrimaryRelays))
+	}
+	if added, removed := diffStrings(old.Admins, next.Admins); len(added)+len(removed) > 0 {
+		changes = append(changes, fmt.Sprintf("admins +%d -%d", len(added), len(removed)))
+	}
+	if old.Pricing.SatsPerHalfDozen != next.Pricing.SatsPerHalfDozen {
+		changes = append(changes, fmt.Sprintf("price %d -> %d sats per half dozen", old.Pricing.SatsPerHalfDozen, next.Pricing.SatsPerHalfDozen))
+	}
+	if !slices.Equal(old.Pricing.AllowedQuantities, next.Pricing.AllowedQuantities) {
+		changes = append(changes, fmt.Sprintf("allowed quantities %v -> %v", old.Pricing.AllowedQuantities, next.Pricing.AllowedQuantities))
+	}
+	return changes
+}
+
+// diffStrings returns the values in next but not old, and in old but not next.
+func diffStrings(old, next []string) (added, removed []string) {
+	for _, s := range next {
+		if !slices.Contains(old, s) {
+			added = append(added, s)
+		}
+	}
+	for _, s := range old {
+		if !slices.Contains(next, s) {
+			removed = append(removed, s)
+		}
+	}
+	return added, removed
+}
+
+// Watch reloads the config each time a signal arrives until ctx is done.
+// Reload errors are logged and the current config is kept.
+func (w *ConfigWatcher) Watch(ctx context.Context, signals <-chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			slog.Info("reloading config", "signal", sig)
+			if err := w.Reload(); err != nil {
+				slog.Error("config reload failed, keeping current config", "error", err)
+			}
+		}
+	}
+}
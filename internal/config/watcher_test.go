@@ -0,0 +1,273 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/spf13/viper"
+)
+
+const (
+	testAdminA = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqshp52w2"
+	testAdminB = "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqpqdangsl"
+)
+
+// writeConfig writes a YAML config file with the given admins and price.
+func writeConfig(t *testing.T, path string, admins []string, price int) {
+	t.Helper()
+	content := "nostr:\n  relays:\n    - wss://relay.example.com\n"
+	content += "pricing:\n  sats_per_half_dozen: " + strconv.Itoa(price) + "\n"
+	content += "admins:\n"
+	for _, a := range admins {
+		content += "  - " + a + "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing config: %v", err)
+	}
+}
+
+// loadTestConfig points viper at a fresh config file and loads it.
+func loadTestConfig(t *testing.T, admins []string, price int) (string, *Config) {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+
+	path := filepath.Join(t.TempDir(), "eggbot.yaml")
+	writeConfig(t, path, admins, price)
+	viper.SetConfigFile(path)
+	if err := viper.ReadInConfig(); err != nil {
+		t.Fatalf("reading config: %v", err)
+	}
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	return path, cfg
+}
+
+func TestConfigWatcher_ReloadOnSignal(t *testing.T) {
+	path, cfg := loadTestConfig(t, []string{testAdminA}, 3200)
+	cfg.Nostr.BotSecretHex = "secret" // Secrets are never reloaded
+
+	w := NewConfigWatcher(cfg)
+	reloaded := make(chan *Config, 1)
+	w.OnReload(func(oldCfg, newCfg *Config) { reloaded <- newCfg })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	signals := make(chan os.Signal, 1)
+	go w.Watch(ctx, signals)
+
+	writeConfig(t, path, []string{testAdminA, testAdminB}, 4000)
+	signals <- syscall.SIGHUP
+
+	select {
+	case <-reloaded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for reload")
+	}
+
+	got := w.Config()
+	if !slices.Equal(got.Admins, []string{testAdminA, testAdminB}) {
+		t.Errorf("Admins = %v, want both admins", got.Admins)
+	}
+	if got.Pricing.SatsPerHalfDozen != 4000 {
+		t.Errorf("SatsPerHalfDozen = %d, want 4000", got.Pricing.SatsPerHalfDozen)
+	}
+	if got.Nostr.BotSecretHex != "secret" {
+		t.Error("expected secrets to be kept across reload")
+	}
+
+	// The previous snapshot is untouched
+	if !slices.Equal(cfg.Admins, []string{testAdminA}) || cfg.Pricing.SatsPerHalfDozen != 3200 {
+		t.Errorf("old snapshot modified: admins %v, price %d", cfg.Admins, cfg.Pricing.SatsPerHalfDozen)
+	}
+}
+
+func TestConfigWatcher_ReloadErrorKeepsConfig(t *testing.T) {
+	_, cfg := loadTestConfig(t, []string{testAdminA}, 3200)
+
+	w := NewConfigWatcher(cfg)
+	w.load = func() (*Config, error) { return nil, errors.New("bad yaml") }
+
+	if err := w.Reload(); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if w.Config() != cfg {
+		t.Error("expected current config kept after failed reload")
+	}
+}
+
+func TestConfigWatcher_AtomicSwap(t *testing.T) {
+	_, cfg := loadTestConfig(t, []string{testAdminA}, 3200)
+
+	w := NewConfigWatcher(cfg)
+	one := []string{testAdminA}
+	two := []string{testAdminA, testAdminB}
+	var n int
+	w.load = func() (*Config, error) {
+		n++
+		fresh := *cfg
+		if n%2 == 0 {
+			fresh.Admins, fresh.Pricing.SatsPerHalfDozen = one, 3200
+		} else {
+			fresh.Admins, fresh.Pricing.SatsPerHalfDozen = two, 6400
+		}
+		return &fresh, nil
+	}
+
+	// Readers must always see admins and pricing from the same reload
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				c := w.Config()
+				if (len(c.Admins) == 1) != (c.Pricing.SatsPerHalfDozen == 3200) {
+					t.Errorf("torn config: admins %v with price %d", c.Admins, c.Pricing.SatsPerHalfDozen)
+					return
+				}
+			}
+		}()
+	}
+
+	for range 200 {
+		if err := w.Reload(); err != nil {
+			t.Fatalf("Reload: %v", err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+func TestIdentityChange(t *testing.T) {
+	t.Setenv("EGGBOT_NSEC", "")
+	t.Setenv("EGGBOT_NCRYPTSEC", "")
+	secretHex := nostr.GeneratePrivateKey()
+	pubkeyHex, _ := nostr.GetPublicKey(secretHex)
+	npub, _ := nip19.EncodePublicKey(pubkeyHex)
+	nsec, _ := nip19.EncodePrivateKey(secretHex)
+	otherNsec, _ := nip19.EncodePrivateKey(nostr.GeneratePrivateKey())
+	otherPubkeyHex, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	otherNpub, _ := nip19.EncodePublicKey(otherPubkeyHex)
+
+	dir := t.TempDir()
+	writeNsec := func(name, nsec string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(nsec+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	running := &Config{Nostr: NostrConfig{BotNpub: npub, BotSecretHex: secretHex, BotPubkeyHex: pubkeyHex, NsecFile: writeNsec("nsec", nsec)}}
+
+	tests := []struct {
+		name    string
+		fresh   NostrConfig
+		wantErr string
+	}{
+		{"same file", NostrConfig{NsecFile: running.Nostr.NsecFile}, ""},
+		{"same npub", NostrConfig{BotNpub: npub, NsecFile: running.Nostr.NsecFile}, ""},
+		{"other npub", NostrConfig{BotNpub: otherNpub, NsecFile: running.Nostr.NsecFile}, "nostr.bot_npub changed"},
+		{"other key in the file", NostrConfig{NsecFile: writeNsec("other", otherNsec)}, "nostr.nsec_file now holds a different key"},
+		{"file gone", NostrConfig{NsecFile: filepath.Join(dir, "missing")}, "re-reading the bot key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := identityChange(running, &Config{Nostr: tt.fresh})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	// The environment wins over the file, as at startup
+	t.Setenv("EGGBOT_NSEC", nsec)
+	if err := identityChange(running, &Config{Nostr: NostrConfig{NsecFile: writeNsec("ignored", otherNsec)}}); err != nil {
+		t.Errorf("unexpected error with EGGBOT_NSEC set: %v", err)
+	}
+}
+
+func TestConfigWatcher_ReloadKeepsKey(t *testing.T) {
+	path, cfg := loadTestConfig(t, []string{testAdminA}, 3200)
+	secretHex := nostr.GeneratePrivateKey()
+	cfg.Nostr.BotSecretHex = secretHex
+	cfg.Nostr.BotPubkeyHex, _ = nostr.GetPublicKey(secretHex)
+	cfg.Nostr.BotNpub, _ = nip19.EncodePublicKey(cfg.Nostr.BotPubkeyHex)
+	nsec, _ := nip19.EncodePrivateKey(secretHex)
+	t.Setenv("EGGBOT_NSEC", nsec)
+
+	// A config naming another bot still applies its other settings
+	otherPubkeyHex, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	otherNpub, _ := nip19.EncodePublicKey(otherPubkeyHex)
+	writeConfig(t, path, []string{testAdminB}, 4000)
+	viper.Set("nostr.bot_npub", otherNpub)
+
+	w := NewConfigWatcher(cfg)
+	if err := w.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	got := w.Config()
+	if got.Nostr.BotSecretHex != secretHex || got.Nostr.BotNpub != cfg.Nostr.BotNpub {
+		t.Error("the bot's key changed on reload")
+	}
+	if got.Pricing.SatsPerHalfDozen != 4000 || !slices.Equal(got.Admins, []string{testAdminB}) {
+		t.Errorf("pricing %d, admins %v; want the reloaded ones", got.Pricing.SatsPerHalfDozen, got.Admins)
+	}
+}
+
+func TestReloadChanges(t *testing.T) {
+	old := &Config{
+		Nostr:   NostrConfig{Relays: []string{"wss://a", "wss://b"}, PrimaryRelays: []string{"wss://a", "wss://b"}},
+		Admins:  []string{testAdminA},
+		Pricing: PricingConfig{SatsPerHalfDozen: 3200, AllowedQuantities: []int{6, 12}},
+	}
+	if changes := reloadChanges(old, old); changes != nil {
+		t.Errorf("changes = %v, want none", changes)
+	}
+
+	next := *old
+	next.Nostr = NostrConfig{Relays: []string{"wss://a", "wss://c"}, PrimaryRelays: []string{"wss://a", "wss://c"}}
+	next.Admins = []string{testAdminA, testAdminB}
+	next.Pricing = PricingConfig{SatsPerHalfDozen: 4000, AllowedQuantities: []int{6, 12}}
+	want := []string{
+		"relays +[wss://c] -[wss://b]",
+		"admins +1 -0",
+		"price 3200 -> 4000 sats per half dozen",
+	}
+	if changes := reloadChanges(old, &next); !slices.Equal(changes, want) {
+		t.Errorf("changes = %q, want %q", changes, want)
+	}
+
+	// Moving a relay between primary and fallback is a change too
+	next = *old
+	next.Nostr = NostrConfig{Relays: []string{"wss://a", "wss://b"}, PrimaryRelays: []string{"wss://a"}, FallbackRelays: []string{"wss://b"}}
+	if changes := reloadChanges(old, &next); len(changes) != 1 || !strings.HasPrefix(changes[0], "primary relays now") {
+		t.Errorf("changes = %v, want the new primary relays", changes)
+	}
+}
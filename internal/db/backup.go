@@ -0,0 +1,265 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/pressly/goose/v3"
+	"modernc.org/sqlite"
+)
+
+// backupStepPages is how many pages OnlineBackup copies per step.
+const backupStepPages = 256
+
+// ErrBackupExists is returned when a backup would overwrite an existing file.
+var ErrBackupExists = errors.New("backup file already exists")
+
+// ErrInvalidSnapshot is returned when a file is not a usable eggbot database.
+var ErrInvalidSnapshot = errors.New("invalid database snapshot")
+
+// Backup writes a consistent snapshot of the database to path with VACUUM INTO,
+// which is safe while the bot is running. Missing parent directories are
+// created. An existing file is only replaced if overwrite is set.
+func (db *DB) Backup(ctx context.Context, path string, overwrite bool) error {
+	if err := prepareBackupPath(path, overwrite); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, path); err != nil {
+		return fmt.Errorf("writing backup: %w", err)
+	}
+	return nil
+}
+
+// OnlineBackup copies the database to path with SQLite's online backup API
+// and returns the number of pages in the copy. Like Backup it is safe while
+// the bot is running, but it copies pages as they are rather than rebuilding
+// the file.
+func (db *DB) OnlineBackup(ctx context.Context, path string, overwrite bool) (int, error) {
+	if err := prepareBackupPath(path, overwrite); err != nil {
+		return 0, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting connection: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	err = conn.Raw(func(driverConn any) error {
+		backuper, ok := driverConn.(interface {
+			NewBackup(dstURI string) (*sqlite.Backup, error)
+		})
+		if !ok {
+			return errors.New("sqlite driver does not support online backup")
+		}
+		backup, err := backuper.NewBackup(path)
+		if err != nil {
+			return err
+		}
+		// Copy in steps so the source is not locked for the whole backup
+		for {
+			more, err := backup.Step(backupStepPages)
+			if err != nil {
+				_ = backup.Finish()
+				return err
+			}
+			if !more {
+				break
+			}
+		}
+		return backup.Finish()
+	})
+	if err != nil {
+		return 0, fmt.Errorf("writing backup: %w", err)
+	}
+
+	backupDB, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, fmt.Errorf("opening backup: %w", err)
+	}
+	defer func() { _ = backupDB.Close() }()
+	var pages int
+	if err := backupDB.QueryRowContext(ctx, `PRAGMA page_count`).Scan(&pages); err != nil {
+		return 0, fmt.Errorf("counting backup pages: %w", err)
+	}
+	return pages, nil
+}
+
+// VerifyIntegrity runs PRAGMA integrity_check on the database file at path.
+func VerifyIntegrity(ctx context.Context, path string) error {
+	return checkFile(ctx, path, "integrity_check")
+}
+
+// QuickCheck runs PRAGMA quick_check, a faster integrity check that skips
+// index consistency, on the database file at path.
+func QuickCheck(ctx context.Context, path string) error {
+	return checkFile(ctx, path, "quick_check")
+}
+
+// checkFile opens path read-only and runs an integrity pragma against it.
+func checkFile(ctx context.Context, path, pragma string) error {
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", path, err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+
+	var result string
+	if err := sqlDB.QueryRowContext(ctx, `PRAGMA `+pragma).Scan(&result); err != nil {
+		return fmt.Errorf("running %s: %w", pragma, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%s failed: %s", pragma, result)
+	}
+	return nil
+}
+
+// prepareBackupPath creates the parent directories of path and removes an
+// existing file there if overwrite is set.
+func prepareBackupPath(path string, overwrite bool) error {
+	if _, err := os.Stat(path); err == nil {
+		if !overwrite {
+			return fmt.Errorf("%w: %s", ErrBackupExists, path)
+		}
+		// VACUUM INTO refuses to write over a non-empty file
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("removing old backup: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating backup directory: %w", err)
+	}
+	return nil
+}
+
+// ValidateSnapshot checks that path holds an eggbot database with every
+// migration applied and the inventory row present.
+func ValidateSnapshot(ctx context.Context, path string) error {
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+
+	// Read-only, so checking a snapshot never changes it
+	sqlDB, err := sql.Open("sqlite", "file:"+path+"?mode=ro")
+	if err != nil {
+		return fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer func() { _ = sqlDB.Close() }()
+
+	// Checked first: goose retries for seconds when it can't create the table
+	var versionTables int
+	err = sqlDB.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'goose_db_version'`).Scan(&versionTables)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidSnapshot, err)
+	}
+	if versionTables == 0 {
+		return fmt.Errorf("%w: no migration history", ErrInvalidSnapshot)
+	}
+
+	provider, err := newMigrationProvider(sqlDB)
+	if err != nil {
+		return err
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: reading migration status: %w", ErrInvalidSnapshot, err)
+	}
+	for _, status := range statuses {
+		if status.State != goose.StateApplied {
+			return fmt.Errorf("%w: migration %d (%s) not applied",
+				ErrInvalidSnapshot, status.Source.Version, filepath.Base(status.Source.Path))
+		}
+	}
+
+	var count int
+	if err := sqlDB.QueryRowContext(ctx, `SELECT COUNT(*) FROM inventory WHERE id = 1`).Scan(&count); err != nil {
+		return fmt.Errorf("%w: reading inventory: %w", ErrInvalidSnapshot, err)
+	}
+	if count != 1 {
+		return fmt.Errorf("%w: inventory row missing", ErrInvalidSnapshot)
+	}
+	return nil
+}
+
+// Restore replaces the database at dbPath with a validated snapshot. The
+// current database is kept as dbPath+".bak". The bot must not be running.
+func Restore(ctx context.Context, snapshotPath, dbPath string) error {
+	if err := ValidateSnapshot(ctx, snapshotPath); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(dbPath); err == nil {
+		// Fold any WAL content into the main file before moving it aside
+		if err := checkpoint(ctx, dbPath); err != nil {
+			return err
+		}
+		if err := os.Rename(dbPath, dbPath+".bak"); err != nil {
+			return fmt.Errorf("keeping old database: %w", err)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("checking %s: %w", dbPath, err)
+	}
+	for _, suffix := range []string{"-wal", "-shm"} {
+		if err := os.Remove(dbPath + suffix); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing stale %s file: %w", suffix, err)
+		}
+	}
+
+	// Copy next to the target and rename, so dbPath is never half-written
+	tmpPath := dbPath + ".restore"
+	if err := copyFile(snapshotPath, tmpPath); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("moving snapshot into place: %w", err)
+	}
+	return nil
+}
+
+// checkpoint writes the WAL of the database at path back into the main file.
+func checkpoint(ctx context.Context, path string) error {
+	database, err := Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = database.Close() }()
+	if _, err := database.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return fmt.Errorf("checkpointing database: %w", err)
+	}
+	return nil
+}
+
+// copyFile copies src to dst with owner-only permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return fmt.Errorf("opening snapshot: %w", err)
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dst, err)
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("copying snapshot: %w", err)
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		_ = os.Remove(dst)
+		return fmt.Errorf("copying snapshot: %w", err)
+	}
+	return out.Close()
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openFileDB opens and migrates a database file, closing it at test end.
+func openFileDB(t *testing.T, path string) *DB {
+	t.Helper()
+	database, err := Open(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating %s: %v", path, err)
+	}
+	return database
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "eggbot.db")
+	backupPath := filepath.Join(dir, "backups", "nightly", "eggbot.db")
+
+	database := openFileDB(t, dbPath)
+	_ = database.AddEggs(ctx, 24)
+	customer, _ := database.CreateCustomer(ctx, "npub1backup", "")
+	if _, err := database.CreateOrder(ctx, customer.ID, 6, 3200); err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+
+	if err := database.Backup(ctx, backupPath, false); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+	if err := database.Backup(ctx, backupPath, false); !errors.Is(err, ErrBackupExists) {
+		t.Errorf("second Backup error = %v, want ErrBackupExists", err)
+	}
+	if err := database.Backup(ctx, backupPath, true); err != nil {
+		t.Fatalf("Backup with overwrite: %v", err)
+	}
+
+	// Mutate the live database after the snapshot
+	_ = database.AddEggs(ctx, 100)
+	if _, err := database.CreateCustomer(ctx, "npub1afterbackup", ""); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("closing live database: %v", err)
+	}
+
+	if err := Restore(ctx, backupPath, dbPath); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	restored := openFileDB(t, dbPath)
+	if eggs, _ := restored.GetInventory(ctx); eggs != 18 {
+		t.Errorf("restored inventory = %d, want 18", eggs)
+	}
+	if _, err := restored.GetCustomerByNpub(ctx, "npub1afterbackup"); !errors.Is(err, ErrCustomerNotFound) {
+		t.Errorf("customer added after backup should be gone, got %v", err)
+	}
+	if orders, _ := restored.GetCustomerOrdersByOffset(ctx, customer.ID, 10, 0); len(orders) != 1 {
+		t.Errorf("restored orders = %d, want 1", len(orders))
+	}
+
+	// The replaced database is kept with its latest changes
+	old := openFileDB(t, dbPath+".bak")
+	if eggs, _ := old.GetInventory(ctx); eggs != 118 {
+		t.Errorf(".bak inventory = %d, want 118", eggs)
+	}
+}
+
+func TestValidateSnapshot_Rejects(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	notSQLite := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notSQLite, []byte("not a database"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	unmigratedPath := filepath.Join(dir, "empty.db")
+	unmigrated, err := Open(unmigratedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := unmigrated.Exec(`CREATE TABLE unrelated (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	_ = unmigrated.Close()
+
+	noInventoryPath := filepath.Join(dir, "noinventory.db")
+	noInventory := openFileDB(t, noInventoryPath)
+	if _, err := noInventory.Exec(`DELETE FROM inventory`); err != nil {
+		t.Fatal(err)
+	}
+	_ = noInventory.Close()
+
+	for name, path := range map[string]string{
+		"missing":      filepath.Join(dir, "missing.db"),
+		"not sqlite":   notSQLite,
+		"unmigrated":   unmigratedPath,
+		"no inventory": noInventoryPath,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if err := ValidateSnapshot(ctx, path); !errors.Is(err, ErrInvalidSnapshot) {
+				t.Errorf("ValidateSnapshot() = %v, want ErrInvalidSnapshot", err)
+			}
+		})
+	}
+
+	target := filepath.Join(dir, "target.db")
+	if err := Restore(ctx, unmigratedPath, target); err == nil {
+		t.Error("Restore should refuse an invalid snapshot")
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Error("invalid snapshot must not be put in place")
+	}
+}
+
+func TestOnlineBackup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	backupPath := filepath.Join(dir, "backup.db")
+
+	database := openFileDB(t, filepath.Join(dir, "eggbot.db"))
+	_ = database.AddEggs(ctx, 30)
+	for _, npub := range []string{"npub1a", "npub1b", "npub1c"} {
+		if _, err := database.CreateCustomer(ctx, npub, ""); err != nil {
+			t.Fatalf("CreateCustomer: %v", err)
+		}
+	}
+
+	pages, err := database.OnlineBackup(ctx, backupPath, false)
+	if err != nil {
+		t.Fatalf("OnlineBackup: %v", err)
+	}
+	if pages <= 0 {
+		t.Errorf("pages = %d, want > 0", pages)
+	}
+	if _, err := database.OnlineBackup(ctx, backupPath, false); !errors.Is(err, ErrBackupExists) {
+		t.Errorf("second OnlineBackup error = %v, want ErrBackupExists", err)
+	}
+	if err := VerifyIntegrity(ctx, backupPath); err != nil {
+		t.Errorf("VerifyIntegrity: %v", err)
+	}
+
+	backup := openFileDB(t, backupPath)
+	var want, got int
+	_ = database.QueryRow(`SELECT COUNT(*) FROM customers`).Scan(&want)
+	if err := backup.QueryRow(`SELECT COUNT(*) FROM customers`).Scan(&got); err != nil {
+		t.Fatalf("counting backup customers: %v", err)
+	}
+	if got != want || got != 3 {
+		t.Errorf("backup has %d customers, want %d", got, want)
+	}
+	if eggs, _ := backup.GetInventory(ctx); eggs != 30 {
+		t.Errorf("backup inventory = %d, want 30", eggs)
+	}
+}
@@ -1,11 +1,14 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"embed"
 	"fmt"
+	"net/url"
+	"sync"
+	"time"
 
-	"github.com/pressly/goose/v3"
 	_ "modernc.org/sqlite"
 )
 
@@ -14,62 +17,112 @@ var embedMigrations embed.FS
 
 type DB struct {
 	*sql.DB
+
+	adminMu    sync.Mutex
+	adminCache map[string]bool // Admin npubs, loaded on first use and reset on changes
+}
+
+// maxOpenConns bounds the connection pool. In WAL mode readers run alongside
+// the single writer; other writers wait up to busyTimeout for the lock.
+const maxOpenConns = 4
+
+// busyTimeout is how long a connection waits for a lock before SQLITE_BUSY.
+const busyTimeout = 5 * time.Second
+
+// connParams are applied by the driver to every new connection. Transactions
+// begin IMMEDIATE so read-modify-write transactions take the write lock up
+// front instead of failing to upgrade a stale read. With WAL, synchronous
+// NORMAL only syncs at checkpoints: a power loss can drop the last commits but
+// never corrupts the database. cache_size is negative to mean KiB, so each
+// connection caches about 2 MB of pages.
+var connParams = url.Values{
+	"_pragma": {
+		fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()),
+		"foreign_keys(1)",
+		"journal_mode(WAL)",
+		"synchronous(NORMAL)",
+		"cache_size(-2000)",
+	},
+	"_txlock": {"immediate"},
 }
 
 func Open(dbPath string) (*DB, error) {
-	sqlDB, err := sql.Open("sqlite", dbPath)
+	sqlDB, err := sql.Open("sqlite", dbPath+"?"+connParams.Encode())
 	if err != nil {
 		return nil, fmt.Errorf("opening database: %w", err)
 	}
 
-	sqlDB.SetMaxOpenConns(1)
-
-	if _, err := sqlDB.Exec(`PRAGMA foreign_keys = ON;`); err != nil {
-		_ = sqlDB.Close()
-		return nil, fmt.Errorf("enabling foreign keys: %w", err)
+	// Each connection to :memory: is a separate database
+	if dbPath == ":memory:" {
+		sqlDB.SetMaxOpenConns(1)
+	} else {
+		sqlDB.SetMaxOpenConns(maxOpenConns)
 	}
 
-	if _, err := sqlDB.Exec(`PRAGMA journal_mode = WAL;`); err != nil {
+	// Connect now so a bad path or pragma fails here rather than on first use
+	if err := sqlDB.Ping(); err != nil {
 		_ = sqlDB.Close()
-		return nil, fmt.Errorf("setting WAL mode: %w", err)
+		return nil, fmt.Errorf("connecting to database: %w", err)
 	}
 
 	return &DB{DB: sqlDB}, nil
 }
 
+// Migrate applies all pending migrations. Use MigrateUp to learn which ran.
 func (db *DB) Migrate() error {
-	goose.SetBaseFS(embedMigrations)
-
-	if err := goose.SetDialect("sqlite3"); err != nil {
-		return fmt.Errorf("setting dialect: %w", err)
-	}
+	_, err := db.MigrateUp(context.Background())
+	return err
+}
 
-	if err := goose.Up(db.DB, "migrations"); err != nil {
-		return fmt.Errorf("running migrations: %w", err)
+// HealthCheck runs a cheap query against a real table so a locked or
+// unreachable database is reported.
+func (db *DB) HealthCheck(ctx context.Context) error {
+	var count int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM inventory`).Scan(&count); err != nil {
+		return fmt.Errorf("querying database: %w", err)
 	}
-
 	return nil
 }
 
-// GetHighWaterMark returns the Unix timestamp of the most recently processed event.
-// Returns 0 if no events have been processed yet.
-func (db *DB) GetHighWaterMark() (int64, error) {
-	var ts int64
-	err := db.QueryRow(`SELECT last_event_at FROM high_water_mark WHERE id = 1`).Scan(&ts)
+// GetHighWaterMarks returns the Unix timestamp of the most recently processed
+// event of each kind. Kinds with no processed events are absent.
+func (db *DB) GetHighWaterMarks() (map[int]int64, error) {
+	rows, err := db.Query(`SELECT kind, last_event_at FROM high_water_marks`)
 	if err != nil {
-		return 0, fmt.Errorf("getting high water mark: %w", err)
+		return nil, fmt.Errorf("getting high water marks: %w", err)
 	}
-	return ts, nil
+	defer func() { _ = rows.Close() }()
+
+	marks := make(map[int]int64)
+	for rows.Next() {
+		var kind int
+		var ts int64
+		if err := rows.Scan(&kind, &ts); err != nil {
+			return nil, fmt.Errorf("scanning high water mark: %w", err)
+		}
+		marks[kind] = ts
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating high water marks: %w", err)
+	}
+	return marks, nil
+}
+
+// SetHighWaterMark updates the high water mark for kind if the given timestamp
+// is greater than its current value. This ensures each kind only moves forward
+// in time.
+func (db *DB) SetHighWaterMark(kind int, ts int64) error {
+	return setHighWaterMark(context.Background(), db, kind, ts)
 }
 
-// SetHighWaterMark updates the high water mark if the given timestamp is greater
-// than the current value. This ensures we only move forward in time.
-func (db *DB) SetHighWaterMark(ts int64) error {
-	_, err := db.Exec(`
-		UPDATE high_water_mark
-		SET last_event_at = ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = 1 AND last_event_at < ?
-	`, ts, ts)
+// setHighWaterMark moves kind's high water mark forward to ts with ex.
+func setHighWaterMark(ctx context.Context, ex execer, kind int, ts int64) error {
+	_, err := ex.ExecContext(ctx, `
+		INSERT INTO high_water_marks (kind, last_event_at) VALUES (?, ?)
+		ON CONFLICT (kind) DO UPDATE
+		SET last_event_at = excluded.last_event_at, updated_at = CURRENT_TIMESTAMP
+		WHERE excluded.last_event_at > high_water_marks.last_event_at
+	`, kind, ts)
 	if err != nil {
 		return fmt.Errorf("setting high water mark: %w", err)
 	}
@@ -81,7 +134,36 @@ func (db *DB) SetHighWaterMark(ts int64) error {
 // Returns false if the event was already processed (caller should skip it).
 // Uses INSERT OR IGNORE for atomic deduplication.
 func (db *DB) TryProcess(eventID string, kind int, createdAt int64) (bool, error) {
-	result, err := db.Exec(`
+	return tryProcess(context.Background(), db, eventID, kind, createdAt)
+}
+
+// TryProcessAndAdvanceHWM records an event as processed like TryProcess and,
+// if it is new, moves its kind's high water mark up to createdAt in the same
+// transaction, so neither is recorded without the other.
+func (db *DB) TryProcessAndAdvanceHWM(ctx context.Context, eventID string, kind int, createdAt int64) (bool, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	isNew, err := tryProcess(ctx, tx, eventID, kind, createdAt)
+	if err != nil || !isNew {
+		return false, err
+	}
+	if err := setHighWaterMark(ctx, tx, kind, createdAt); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("committing transaction: %w", err)
+	}
+	return true, nil
+}
+
+// tryProcess records an event as processed with ex, for TryProcess.
+func tryProcess(ctx context.Context, ex execer, eventID string, kind int, createdAt int64) (bool, error) {
+	result, err := ex.ExecContext(ctx, `
 		INSERT OR IGNORE INTO processed_events (event_id, kind, created_at)
 		VALUES (?, ?, ?)
 	`, eventID, kind, createdAt)
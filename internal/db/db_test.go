@@ -1,8 +1,12 @@
 package db
 
 import (
+	"context"
+	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 )
 
@@ -24,52 +28,68 @@ func TestHighWaterMark(t *testing.T) {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
-	// Initial value should be 0
-	hwm, err := db.GetHighWaterMark()
+	// Initially no kind has a mark
+	marks, err := db.GetHighWaterMarks()
 	if err != nil {
-		t.Fatalf("GetHighWaterMark() error: %v", err)
+		t.Fatalf("GetHighWaterMarks() error: %v", err)
 	}
-	if hwm != 0 {
-		t.Errorf("initial high water mark = %d, want 0", hwm)
+	if len(marks) != 0 {
+		t.Errorf("initial high water marks = %v, want none", marks)
 	}
 
-	// Set to 100
-	if err := db.SetHighWaterMark(100); err != nil {
-		t.Fatalf("SetHighWaterMark(100) error: %v", err)
+	steps := []struct {
+		kind int
+		ts   int64
+		want map[int]int64
+	}{
+		{4, 100, map[int]int64{4: 100}},
+		{4, 200, map[int]int64{4: 200}},               // higher - should update
+		{4, 150, map[int]int64{4: 200}},               // lower - should NOT update
+		{9735, 120, map[int]int64{4: 200, 9735: 120}}, // kinds are independent
+		{1059, 300, map[int]int64{4: 200, 1059: 300, 9735: 120}},
+		{9735, 110, map[int]int64{4: 200, 1059: 300, 9735: 120}}, // lower than its own mark
 	}
+	for _, step := range steps {
+		if err := db.SetHighWaterMark(step.kind, step.ts); err != nil {
+			t.Fatalf("SetHighWaterMark(%d, %d) error: %v", step.kind, step.ts, err)
+		}
+		marks, err := db.GetHighWaterMarks()
+		if err != nil {
+			t.Fatalf("GetHighWaterMarks() error: %v", err)
+		}
+		if !maps.Equal(marks, step.want) {
+			t.Errorf("after SetHighWaterMark(%d, %d) marks = %v, want %v", step.kind, step.ts, marks, step.want)
+		}
+	}
+}
 
-	hwm, err = db.GetHighWaterMark()
+func TestHighWaterMark_MigratesGlobalMark(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(":memory:")
 	if err != nil {
-		t.Fatalf("GetHighWaterMark() error: %v", err)
-	}
-	if hwm != 100 {
-		t.Errorf("high water mark = %d, want 100", hwm)
+		t.Fatalf("failed to open db: %v", err)
 	}
+	defer func() { _ = db.Close() }()
 
-	// Set to 200 (higher) - should update
-	if err := db.SetHighWaterMark(200); err != nil {
-		t.Fatalf("SetHighWaterMark(200) error: %v", err)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
 	}
-
-	hwm, err = db.GetHighWaterMark()
-	if err != nil {
-		t.Fatalf("GetHighWaterMark() error: %v", err)
+	if _, err := db.MigrateDownTo(ctx, 9); err != nil {
+		t.Fatalf("MigrateDownTo(9): %v", err)
 	}
-	if hwm != 200 {
-		t.Errorf("high water mark = %d, want 200", hwm)
+	if _, err := db.Exec(`UPDATE high_water_mark SET last_event_at = 500 WHERE id = 1`); err != nil {
+		t.Fatalf("setting global mark: %v", err)
 	}
-
-	// Set to 150 (lower) - should NOT update
-	if err := db.SetHighWaterMark(150); err != nil {
-		t.Fatalf("SetHighWaterMark(150) error: %v", err)
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
 	}
 
-	hwm, err = db.GetHighWaterMark()
+	marks, err := db.GetHighWaterMarks()
 	if err != nil {
-		t.Fatalf("GetHighWaterMark() error: %v", err)
+		t.Fatalf("GetHighWaterMarks() error: %v", err)
 	}
-	if hwm != 200 {
-		t.Errorf("high water mark = %d, want 200 (should not decrease)", hwm)
+	if want := map[int]int64{4: 500, 1059: 500, 9735: 500}; !maps.Equal(marks, want) {
+		t.Errorf("migrated marks = %v, want %v", marks, want)
 	}
 }
 
@@ -118,3 +138,180 @@ func TestTryProcess(t *testing.T) {
 		t.Error("TryProcess(different_event) = false, want true")
 	}
 }
+
+func TestHealthCheck(t *testing.T) {
+	db := setupTestDB(t)
+
+	if err := db.HealthCheck(context.Background()); err != nil {
+		t.Errorf("HealthCheck() on open db: %v", err)
+	}
+
+	_ = db.Close()
+	if err := db.HealthCheck(context.Background()); err == nil {
+		t.Error("HealthCheck() on closed db: expected error")
+	}
+}
+
+func TestOpen_MissingDirectory(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "var", "lib", "eggbot", "eggbot.db")
+
+	db, err := Open(dbPath)
+	if err == nil {
+		_ = db.Close()
+		t.Fatal("Open: expected error for a missing directory")
+	}
+	if _, err := os.Stat(filepath.Dir(dbPath)); !os.IsNotExist(err) {
+		t.Errorf("Open created the database directory: %v", err)
+	}
+}
+
+func TestTryProcessAndAdvanceHWM(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	isNew, err := db.TryProcessAndAdvanceHWM(ctx, "event1", 4, 1000)
+	if err != nil || !isNew {
+		t.Fatalf("first TryProcessAndAdvanceHWM = %v, %v; want true", isNew, err)
+	}
+	isNew, err = db.TryProcessAndAdvanceHWM(ctx, "event1", 4, 1000)
+	if err != nil || isNew {
+		t.Fatalf("duplicate TryProcessAndAdvanceHWM = %v, %v; want false", isNew, err)
+	}
+	// An older event is new but doesn't move the mark back
+	if isNew, err = db.TryProcessAndAdvanceHWM(ctx, "event0", 4, 900); err != nil || !isNew {
+		t.Fatalf("older TryProcessAndAdvanceHWM = %v, %v; want true", isNew, err)
+	}
+
+	marks, err := db.GetHighWaterMarks()
+	if err != nil {
+		t.Fatalf("GetHighWaterMarks: %v", err)
+	}
+	if want := map[int]int64{4: 1000}; !maps.Equal(marks, want) {
+		t.Errorf("marks = %v, want %v", marks, want)
+	}
+}
+
+func TestTryProcessAndAdvanceHWM_RollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	// Make every high water mark write fail, after the event is recorded
+	for _, stmt := range []string{
+		`CREATE TRIGGER fail_hwm_insert BEFORE INSERT ON high_water_marks BEGIN SELECT RAISE(ABORT, 'injected failure'); END`,
+		`CREATE TRIGGER fail_hwm_update BEFORE UPDATE ON high_water_marks BEGIN SELECT RAISE(ABORT, 'injected failure'); END`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("creating trigger: %v", err)
+		}
+	}
+
+	if _, err := db.TryProcessAndAdvanceHWM(ctx, "event1", 4, 1000); err == nil {
+		t.Fatal("expected the injected failure")
+	}
+	var processed int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM processed_events WHERE event_id = 'event1'`).Scan(&processed); err != nil {
+		t.Fatalf("counting processed events: %v", err)
+	}
+	if processed != 0 {
+		t.Error("event recorded as processed though its high water mark wasn't")
+	}
+
+	// Once writes work again the event is still new
+	for _, stmt := range []string{`DROP TRIGGER fail_hwm_insert`, `DROP TRIGGER fail_hwm_update`} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("dropping trigger: %v", err)
+		}
+	}
+	if isNew, err := db.TryProcessAndAdvanceHWM(ctx, "event1", 4, 1000); err != nil || !isNew {
+		t.Fatalf("TryProcessAndAdvanceHWM after failure = %v, %v; want true", isNew, err)
+	}
+	marks, _ := db.GetHighWaterMarks()
+	if marks[4] != 1000 {
+		t.Errorf("mark for kind 4 = %d, want 1000", marks[4])
+	}
+}
+
+func TestOpen_Pragmas(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "eggbot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	for pragma, want := range map[string]string{
+		"busy_timeout": "5000",
+		"cache_size":   "-2000",
+		"foreign_keys": "1",
+		"journal_mode": "wal",
+		"synchronous":  "1", // NORMAL
+	} {
+		var got string
+		if err := db.QueryRow("PRAGMA " + pragma).Scan(&got); err != nil {
+			t.Fatalf("PRAGMA %s: %v", pragma, err)
+		}
+		if got != want {
+			t.Errorf("PRAGMA %s = %s, want %s", pragma, got, want)
+		}
+	}
+}
+
+func TestOpen_ConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(filepath.Join(t.TempDir(), "eggbot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	// Each writer holds the write lock across a read and a write, so the
+	// other has to wait on busy_timeout rather than fail with SQLITE_BUSY
+	const writers, writes = 2, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range writes {
+				tx, err := db.BeginTx(ctx, nil)
+				if err != nil {
+					errs <- err
+					return
+				}
+				var n int
+				if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM processed_events").Scan(&n); err != nil {
+					_ = tx.Rollback()
+					errs <- err
+					return
+				}
+				if _, err := tx.ExecContext(ctx,
+					"INSERT INTO processed_events (event_id, kind, created_at) VALUES (?, ?, ?)",
+					fmt.Sprintf("writer-%d-%d", w, i), 4, i); err != nil {
+					_ = tx.Rollback()
+					errs <- err
+					return
+				}
+				if err := tx.Commit(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf("concurrent write: %v", err)
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM processed_events").Scan(&count); err != nil {
+		t.Fatalf("counting events: %v", err)
+	}
+	if count != writers*writes {
+		t.Errorf("processed_events has %d rows, want %d", count, writers*writes)
+	}
+}
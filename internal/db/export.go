@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// OrderExport is an order with its customer, as written by exports.
+type OrderExport struct {
+	ID           int64
+	CustomerNpub string
+	CustomerName sql.NullString
+	Quantity     int
+	TotalSats    int64
+	Status       string
+	CreatedAt    time.Time
+	PaidAt       sql.NullTime // Unknown for orders fulfilled before paid times were recorded
+	FulfilledAt  sql.NullTime
+}
+
+// ExportOrders calls fn for each order matching every set field of f,
+// archived orders included, oldest first. Rows are read one at a time, so fn
+// must not use the database.
+func (db *DB) ExportOrders(ctx context.Context, f OrderFilter, fn func(OrderExport) error) error {
+	where := f.where()
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.id, c.npub, c.name, o.quantity, o.total_sats, o.status, o.created_at, o.paid_at, o.fulfilled_at
+		FROM `+allOrdersSQL+` o
+		JOIN customers c ON o.customer_id = c.id
+		`+where.clause()+`
+		ORDER BY o.id
+	`, where.args...)
+	if err != nil {
+		return fmt.Errorf("querying orders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var o OrderExport
+		if err := rows.Scan(&o.ID, &o.CustomerNpub, &o.CustomerName, &o.Quantity, &o.TotalSats, &o.Status,
+			&o.CreatedAt, &o.PaidAt, &o.FulfilledAt); err != nil {
+			return fmt.Errorf("scanning order: %w", err)
+		}
+		if err := fn(o); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating orders: %w", err)
+	}
+	return nil
+}
+
+// ExportTransactions calls fn for each transaction created at or after from
+// and before to (either may be nil), oldest first. Rows are read one at a
+// time, so fn must not use the database.
+func (db *DB) ExportTransactions(ctx context.Context, from, to *time.Time, fn func(Transaction) error) error {
+	var where whereBuilder
+	if from != nil {
+		where.add("created_at >= ?", from.UTC().Format(sqliteTimeFormat))
+	}
+	if to != nil {
+		where.add("created_at < ?", to.UTC().Format(sqliteTimeFormat))
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, order_id, zap_event_id, amount_sats, sender_npub, created_at
+		FROM transactions
+		`+where.clause()+`
+		ORDER BY id
+	`, where.args...)
+	if err != nil {
+		return fmt.Errorf("querying transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var t Transaction
+		if err := rows.Scan(&t.ID, &t.OrderID, &t.ZapEventID, &t.AmountSats, &t.SenderNpub, &t.CreatedAt); err != nil {
+			return fmt.Errorf("scanning transaction: %w", err)
+		}
+		if err := fn(t); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("iterating transactions: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pressly/goose/v3"
+)
+
+// Migration describes one embedded schema migration.
+type Migration struct {
+	Version   int64
+	Name      string    // File name, e.g. 009_admins.sql
+	Applied   bool      // Only set by MigrationStatus
+	AppliedAt time.Time // Zero if pending
+}
+
+// newMigrationProvider returns a goose provider for the embedded migrations.
+func newMigrationProvider(sqlDB *sql.DB) (*goose.Provider, error) {
+	migrations, err := fs.Sub(embedMigrations, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	provider, err := goose.NewProvider(goose.DialectSQLite3, sqlDB, migrations)
+	if err != nil {
+		return nil, fmt.Errorf("loading migrations: %w", err)
+	}
+	return provider, nil
+}
+
+// MigrationStatus lists every embedded migration and whether it is applied.
+func (db *DB) MigrationStatus(ctx context.Context) ([]Migration, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	statuses, err := provider.Status(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("reading migration status: %w", err)
+	}
+
+	migrations := make([]Migration, 0, len(statuses))
+	for _, status := range statuses {
+		migrations = append(migrations, Migration{
+			Version:   status.Source.Version,
+			Name:      filepath.Base(status.Source.Path),
+			Applied:   status.State == goose.StateApplied,
+			AppliedAt: status.AppliedAt,
+		})
+	}
+	return migrations, nil
+}
+
+// MigrateUp applies all pending migrations and returns the ones it applied.
+func (db *DB) MigrateUp(ctx context.Context) ([]Migration, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	results, err := provider.Up(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("running migrations: %w", err)
+	}
+	return migrationsFromResults(results), nil
+}
+
+// MigrateDownTo rolls back migrations newer than version, newest first, and
+// returns the ones it rolled back. Version 0 rolls back everything.
+func (db *DB) MigrateDownTo(ctx context.Context, version int64) ([]Migration, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	results, err := provider.DownTo(ctx, version)
+	if err != nil {
+		return nil, fmt.Errorf("rolling back migrations: %w", err)
+	}
+	return migrationsFromResults(results), nil
+}
+
+// MigrateUpByOne applies the next pending migration and returns it, or nil if
+// none is pending.
+func (db *DB) MigrateUpByOne(ctx context.Context) (*Migration, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	result, err := provider.UpByOne(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("running migration: %w", err)
+	}
+	return &migrationsFromResults([]*goose.MigrationResult{result})[0], nil
+}
+
+// MigrateDown rolls back the most recently applied migration and returns it,
+// or nil if none is applied.
+func (db *DB) MigrateDown(ctx context.Context) (*Migration, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return nil, err
+	}
+	result, err := provider.Down(ctx)
+	if errors.Is(err, goose.ErrNoNextVersion) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("rolling back migration: %w", err)
+	}
+	return &migrationsFromResults([]*goose.MigrationResult{result})[0], nil
+}
+
+// MigrationVersion returns the version of the newest applied migration, or 0
+// if none is applied.
+func (db *DB) MigrationVersion(ctx context.Context) (int64, error) {
+	provider, err := newMigrationProvider(db.DB)
+	if err != nil {
+		return 0, err
+	}
+	version, err := provider.GetDBVersion(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("reading migration version: %w", err)
+	}
+	return version, nil
+}
+
+// MigrationSQL returns the up or down section of the named embedded migration,
+// without the goose annotations.
+func MigrationSQL(name string, up bool) (string, error) {
+	data, err := embedMigrations.ReadFile("migrations/" + name)
+	if err != nil {
+		return "", fmt.Errorf("reading migration %s: %w", name, err)
+	}
+
+	want := "-- +goose Down"
+	if up {
+		want = "-- +goose Up"
+	}
+	var section []string
+	inSection := false
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "-- +goose Up" || trimmed == "-- +goose Down" {
+			inSection = trimmed == want
+			continue
+		}
+		if inSection && !strings.HasPrefix(trimmed, "-- +goose") {
+			section = append(section, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(section, "\n")), nil
+}
+
+func migrationsFromResults(results []*goose.MigrationResult) []Migration {
+	migrations := make([]Migration, 0, len(results))
+	for _, result := range results {
+		migrations = append(migrations, Migration{
+			Version: result.Source.Version,
+			Name:    filepath.Base(result.Source.Path),
+		})
+	}
+	return migrations
+}
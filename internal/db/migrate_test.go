@@ -0,0 +1,138 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMigrationStatusUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	database, err := Open(filepath.Join(t.TempDir(), "eggbot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	status, err := database.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	if len(status) == 0 {
+		t.Fatal("expected embedded migrations")
+	}
+	for _, m := range status {
+		if m.Applied {
+			t.Errorf("migration %s applied on a fresh database", m.Name)
+		}
+	}
+	latest := status[len(status)-1].Version
+
+	applied, err := database.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(applied) != len(status) || applied[0].Name != "001_initial.sql" {
+		t.Errorf("MigrateUp applied %+v, want all %d migrations", applied, len(status))
+	}
+	if again, err := database.MigrateUp(ctx); err != nil || len(again) != 0 {
+		t.Errorf("second MigrateUp = %+v, %v; want nothing to apply", again, err)
+	}
+
+	rolledBack, err := database.MigrateDownTo(ctx, latest-2)
+	if err != nil {
+		t.Fatalf("MigrateDownTo: %v", err)
+	}
+	if len(rolledBack) != 2 || rolledBack[0].Version != latest || rolledBack[1].Version != latest-1 {
+		t.Errorf("MigrateDownTo rolled back %+v, want the two newest, newest first", rolledBack)
+	}
+
+	status, err = database.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatalf("MigrationStatus: %v", err)
+	}
+	for _, m := range status {
+		if want := m.Version <= latest-2; m.Applied != want {
+			t.Errorf("migration %s applied = %v, want %v", m.Name, m.Applied, want)
+		}
+		if m.Applied && m.AppliedAt.IsZero() {
+			t.Errorf("migration %s has no applied time", m.Name)
+		}
+	}
+
+	// Up re-applies only what was rolled back
+	applied, err = database.MigrateUp(ctx)
+	if err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if len(applied) != 2 {
+		t.Errorf("MigrateUp applied %d migrations, want 2", len(applied))
+	}
+}
+
+func TestMigrateUpByOneAndDown(t *testing.T) {
+	ctx := context.Background()
+	database, err := Open(filepath.Join(t.TempDir(), "eggbot.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = database.Close() })
+
+	if down, err := database.MigrateDown(ctx); err != nil || down != nil {
+		t.Errorf("MigrateDown on a fresh database = %+v, %v; want nothing", down, err)
+	}
+
+	for want := int64(1); want <= 2; want++ {
+		m, err := database.MigrateUpByOne(ctx)
+		if err != nil {
+			t.Fatalf("MigrateUpByOne: %v", err)
+		}
+		if m == nil || m.Version != want {
+			t.Fatalf("MigrateUpByOne applied %+v, want version %d", m, want)
+		}
+	}
+	if version, err := database.MigrationVersion(ctx); err != nil || version != 2 {
+		t.Errorf("MigrationVersion = %d, %v; want 2", version, err)
+	}
+
+	m, err := database.MigrateDown(ctx)
+	if err != nil {
+		t.Fatalf("MigrateDown: %v", err)
+	}
+	if m == nil || m.Name != "002_eggbot_tables.sql" {
+		t.Errorf("MigrateDown rolled back %+v, want 002", m)
+	}
+	if version, err := database.MigrationVersion(ctx); err != nil || version != 1 {
+		t.Errorf("MigrationVersion = %d, %v; want 1", version, err)
+	}
+
+	if _, err := database.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if m, err := database.MigrateUpByOne(ctx); err != nil || m != nil {
+		t.Errorf("MigrateUpByOne when up to date = %+v, %v; want nothing", m, err)
+	}
+}
+
+func TestMigrationSQL(t *testing.T) {
+	up, err := MigrationSQL("009_admins.sql", true)
+	if err != nil {
+		t.Fatalf("MigrationSQL: %v", err)
+	}
+	if !strings.HasPrefix(up, "-- Admins:") || !strings.Contains(up, "CREATE TABLE IF NOT EXISTS admins") || strings.Contains(up, "+goose") {
+		t.Errorf("up SQL = %q", up)
+	}
+
+	down, err := MigrationSQL("009_admins.sql", false)
+	if err != nil {
+		t.Fatalf("MigrationSQL: %v", err)
+	}
+	if down != "DROP TABLE IF EXISTS admins;" {
+		t.Errorf("down SQL = %q", down)
+	}
+
+	if _, err := MigrationSQL("999_missing.sql", true); err == nil {
+		t.Error("expected an error for a missing migration")
+	}
+}
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Blocked npubs: DMs and zaps from these pubkeys are dropped without processing
+CREATE TABLE IF NOT EXISTS blocked_npubs (
+    npub TEXT PRIMARY KEY,
+    blocked_by TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS blocked_npubs;
+-- +goose StatementEnd
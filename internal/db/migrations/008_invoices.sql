@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Invoices: BOLT11 invoices generated for orders
+CREATE TABLE IF NOT EXISTS invoices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    order_id INTEGER NOT NULL REFERENCES orders(id),
+    bolt11 TEXT NOT NULL,
+    payment_hash TEXT NOT NULL UNIQUE,  -- hex, from the invoice's "p" field
+    amount_sats INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'requested',  -- requested, paid, expired
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_invoices_order_id ON invoices(order_id);
+CREATE INDEX idx_invoices_status_expires_at ON invoices(status, expires_at);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS invoices;
+-- +goose StatementEnd
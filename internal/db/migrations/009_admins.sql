@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Admins: npubs with admin privileges. Config-listed admins are seeded on
+-- startup with from_config = 1 and can only be removed by editing the config.
+CREATE TABLE IF NOT EXISTS admins (
+    npub TEXT PRIMARY KEY,
+    from_config INTEGER NOT NULL DEFAULT 0,
+    added_by TEXT,  -- admin npub that added this one via DM; NULL for config admins
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS admins;
+-- +goose StatementEnd
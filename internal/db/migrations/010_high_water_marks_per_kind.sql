@@ -0,0 +1,35 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- High water marks per event kind, so a fresh DM does not move the mark past
+-- an older zap receipt that a slow relay has yet to deliver
+CREATE TABLE IF NOT EXISTS high_water_marks (
+    kind INTEGER PRIMARY KEY,
+    last_event_at INTEGER NOT NULL DEFAULT 0,  -- Unix timestamp of most recent processed event of this kind
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Every subscribed kind resumes from the old global mark
+INSERT INTO high_water_marks (kind, last_event_at)
+SELECT kinds.kind, h.last_event_at
+FROM high_water_mark h, (SELECT 4 AS kind UNION ALL SELECT 1059 UNION ALL SELECT 9735) kinds
+WHERE h.id = 1 AND h.last_event_at > 0;
+
+DROP TABLE high_water_mark;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS high_water_mark (
+    id INTEGER PRIMARY KEY,
+    last_event_at INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The oldest per-kind mark, so no kind skips events it has not seen
+INSERT INTO high_water_mark (id, last_event_at)
+SELECT 1, COALESCE(MIN(last_event_at), 0) FROM high_water_marks;
+
+DROP TABLE high_water_marks;
+-- +goose StatementEnd
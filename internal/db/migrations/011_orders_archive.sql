@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Orders archive: old fulfilled orders moved out of orders by
+-- ArchiveOldFulfilledOrders. Same columns as orders, keeping their IDs.
+CREATE TABLE IF NOT EXISTS orders_archive (
+    id INTEGER PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES customers(id),
+    quantity INTEGER NOT NULL,  -- number of eggs
+    total_sats INTEGER NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',  -- always fulfilled once archived
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_orders_archive_customer_id ON orders_archive(customer_id);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+INSERT INTO orders SELECT * FROM orders_archive;
+DROP TABLE IF EXISTS orders_archive;
+-- +goose StatementEnd
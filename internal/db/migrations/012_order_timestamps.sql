@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- When an order was paid and fulfilled. Orders from before this migration only
+-- know the time of their last status change, which is when a paid order was
+-- paid and when a fulfilled order was fulfilled.
+ALTER TABLE orders ADD COLUMN paid_at TIMESTAMP;
+ALTER TABLE orders ADD COLUMN fulfilled_at TIMESTAMP;
+ALTER TABLE orders_archive ADD COLUMN paid_at TIMESTAMP;
+ALTER TABLE orders_archive ADD COLUMN fulfilled_at TIMESTAMP;
+
+UPDATE orders SET paid_at = updated_at WHERE status = 'paid';
+UPDATE orders SET fulfilled_at = updated_at WHERE status = 'fulfilled';
+UPDATE orders_archive SET fulfilled_at = updated_at WHERE status = 'fulfilled';
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE orders_archive DROP COLUMN fulfilled_at;
+ALTER TABLE orders_archive DROP COLUMN paid_at;
+ALTER TABLE orders DROP COLUMN fulfilled_at;
+ALTER TABLE orders DROP COLUMN paid_at;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Language a customer chose for replies; NULL uses the configured locale.
+ALTER TABLE customers ADD COLUMN lang TEXT;
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE customers DROP COLUMN lang;
+-- +goose StatementEnd
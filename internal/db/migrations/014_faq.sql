@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Answers to common questions, kept by admins and shown by the info command
+CREATE TABLE IF NOT EXISTS faq (
+    topic TEXT PRIMARY KEY,
+    answer TEXT NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS faq;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Customers' kind:0 profiles as last checked, so admin listings can show a
+-- verified NIP-05 identifier without waiting on relays
+CREATE TABLE IF NOT EXISTS profiles (
+    npub TEXT PRIMARY KEY,
+    nip05 TEXT NOT NULL DEFAULT '', -- Verified identifier; empty when there is none
+    checked_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS profiles;
+-- +goose StatementEnd
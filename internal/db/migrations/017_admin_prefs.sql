@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Notifications each admin wants, set with the adminprefs command. Admins
+-- without a row get everything.
+CREATE TABLE IF NOT EXISTS admin_prefs (
+    npub TEXT PRIMARY KEY REFERENCES admins(npub) ON DELETE CASCADE,
+    orders INTEGER NOT NULL DEFAULT 1,
+    payments INTEGER NOT NULL DEFAULT 1,
+    low_inventory INTEGER NOT NULL DEFAULT 1,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS admin_prefs;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Where each ledger entry came from: 'zap', 'adjustment' or 'synthetic'.
+-- Existing rows are classified by the ID format they were recorded with.
+ALTER TABLE transactions ADD COLUMN source TEXT NOT NULL DEFAULT 'zap';
+UPDATE transactions SET source = 'adjustment' WHERE zap_event_id LIKE 'adjust-%';
+UPDATE transactions SET source = 'synthetic'
+WHERE source = 'zap' AND (length(zap_event_id) != 64 OR zap_event_id GLOB '*[^0-9a-fA-F]*');
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE transactions DROP COLUMN source;
+-- +goose StatementEnd
@@ -0,0 +1,42 @@
+-- +goose Up
+-- +goose StatementBegin
+
+-- Order sizes are configurable, so a notification threshold can be any of
+-- them rather than only 6 or 12. SQLite can't alter a CHECK constraint, so
+-- the table is rebuilt.
+CREATE TABLE inventory_notifications_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL UNIQUE REFERENCES customers(id) ON DELETE CASCADE,
+    threshold_eggs INTEGER NOT NULL CHECK (threshold_eggs > 0),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+INSERT INTO inventory_notifications_new (id, customer_id, threshold_eggs, created_at, updated_at)
+SELECT id, customer_id, threshold_eggs, created_at, updated_at FROM inventory_notifications;
+
+DROP TABLE inventory_notifications;
+ALTER TABLE inventory_notifications_new RENAME TO inventory_notifications;
+CREATE INDEX IF NOT EXISTS idx_inventory_notifications_threshold ON inventory_notifications(threshold_eggs);
+
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE TABLE inventory_notifications_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER NOT NULL UNIQUE REFERENCES customers(id) ON DELETE CASCADE,
+    threshold_eggs INTEGER NOT NULL CHECK (threshold_eggs IN (6, 12)),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Notifications for other sizes can't be kept
+INSERT INTO inventory_notifications_old (id, customer_id, threshold_eggs, created_at, updated_at)
+SELECT id, customer_id, threshold_eggs, created_at, updated_at FROM inventory_notifications
+WHERE threshold_eggs IN (6, 12);
+
+DROP TABLE inventory_notifications;
+ALTER TABLE inventory_notifications_old RENAME TO inventory_notifications;
+CREATE INDEX IF NOT EXISTS idx_inventory_notifications_threshold ON inventory_notifications(threshold_eggs);
+-- +goose StatementEnd
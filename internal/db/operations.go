@@ -5,9 +5,13 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/buildtall-systems/eggbot/internal/fsm"
+	"github.com/buildtall-systems/eggbot/internal/lightning"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var orderSM = fsm.NewOrderStateMachine()
@@ -30,6 +34,45 @@ var ErrOrderNotPending = errors.New("order is not pending")
 // ErrInvalidStateTransition indicates an invalid order state transition was attempted.
 var ErrInvalidStateTransition = errors.New("invalid order state transition")
 
+// ErrInvoiceNotFound indicates no invoice matches the payment hash.
+var ErrInvoiceNotFound = errors.New("invoice not found")
+
+// ErrAdminExists indicates the npub is already an admin.
+var ErrAdminExists = errors.New("admin already exists")
+
+// ErrAdminNotFound indicates the npub is not an admin.
+var ErrAdminNotFound = errors.New("admin not found")
+
+// ErrConfigAdmin indicates the admin is listed in the config file and can only be removed there.
+var ErrConfigAdmin = errors.New("admin is set in config")
+
+// ErrLastAdmin indicates removing the admin would leave no admins.
+var ErrLastAdmin = errors.New("cannot remove the last admin")
+
+// ErrUnknownAdminPref indicates a notification preference that doesn't exist.
+var ErrUnknownAdminPref = errors.New("unknown notification preference")
+
+// ErrAlreadyBlocked indicates the npub is already on the blocklist.
+var ErrAlreadyBlocked = errors.New("npub already blocked")
+
+// ErrNotBlocked indicates the npub is not on the blocklist.
+var ErrNotBlocked = errors.New("npub not blocked")
+
+// ErrFAQNotFound indicates no FAQ entry exists for the topic.
+var ErrFAQNotFound = errors.New("faq topic not found")
+
+// Invoice statuses.
+const (
+	InvoiceStatusRequested = "requested"
+	InvoiceStatusPaid      = "paid"
+	InvoiceStatusExpired   = "expired"
+)
+
+// Setting keys stored in the settings table.
+const (
+	SettingPickupInstructions = "pickup_instructions"
+)
+
 // Customer represents a registered customer.
 type Customer struct {
 	ID        int64
@@ -39,6 +82,15 @@ type Customer struct {
 	UpdatedAt time.Time
 }
 
+// CustomerStats summarizes a customer's orders.
+type CustomerStats struct {
+	Orders        int   // Orders in any status
+	Pending       int   // Orders awaiting payment
+	Fulfilled     int   // Orders delivered
+	EggsDelivered int   // Eggs in fulfilled orders
+	SatsSpent     int64 // Sats for fulfilled orders
+}
+
 // Order represents an egg order.
 type Order struct {
 	ID         int64
@@ -65,11 +117,38 @@ type Transaction struct {
 	ID         int64
 	OrderID    sql.NullInt64
 	ZapEventID string
+	Source     string // One of the TransactionSource constants
 	AmountSats int64
 	SenderNpub string
 	CreatedAt  time.Time
 }
 
+// Transaction sources, telling real zaps from entries recorded by the bot.
+const (
+	TransactionSourceZap        = "zap"        // A validated NIP-57 zap receipt
+	TransactionSourceAdjustment = "adjustment" // An admin "adjust" of a customer's balance
+	TransactionSourceSynthetic  = "synthetic"  // Any other entry, e.g. from "eggbot simulate"
+)
+
+// LedgerEntry is a transaction with the order it paid for, if any.
+type LedgerEntry struct {
+	Transaction
+	OrderQuantity sql.NullInt64
+	OrderStatus   sql.NullString
+}
+
+// InvoiceRecord represents a BOLT11 invoice generated for an order.
+type InvoiceRecord struct {
+	ID          int64
+	OrderID     int64
+	Bolt11      string
+	PaymentHash string
+	AmountSats  int64
+	Status      string
+	ExpiresAt   time.Time
+	CreatedAt   time.Time
+}
+
 // InventoryNotification represents a customer's notification subscription.
 type InventoryNotification struct {
 	ID            int64
@@ -85,6 +164,80 @@ type InventoryNotificationWithCustomer struct {
 	CustomerNpub string
 }
 
+// Admin represents an npub with admin privileges.
+type Admin struct {
+	Npub       string
+	FromConfig bool           // Listed in the config file; not removable via DM
+	AddedBy    sql.NullString // Admin that added this one via DM
+	CreatedAt  time.Time
+}
+
+// Notification preferences an admin can turn off, as named in the
+// adminprefs command.
+const (
+	PrefOrders    = "orders"
+	PrefPayments  = "payments"
+	PrefInventory = "inventory"
+)
+
+// AdminPrefNames lists the notification preferences in display order.
+var AdminPrefNames = []string{PrefOrders, PrefPayments, PrefInventory}
+
+// adminPrefColumns maps each preference to its admin_prefs column.
+var adminPrefColumns = map[string]string{
+	PrefOrders:    "orders",
+	PrefPayments:  "payments",
+	PrefInventory: "low_inventory",
+}
+
+// AdminPrefs are the notifications an admin wants. Admins who never changed
+// theirs get DefaultAdminPrefs.
+type AdminPrefs struct {
+	Orders       bool // New order notices
+	Payments     bool // Payment notices
+	LowInventory bool // Low inventory alerts
+}
+
+// DefaultAdminPrefs turns every notification on.
+var DefaultAdminPrefs = AdminPrefs{Orders: true, Payments: true, LowInventory: true}
+
+// Wants reports whether the preference named pref is on. Unknown names are
+// treated as on, so nothing is silently dropped.
+func (p AdminPrefs) Wants(pref string) bool {
+	switch pref {
+	case PrefOrders:
+		return p.Orders
+	case PrefPayments:
+		return p.Payments
+	case PrefInventory:
+		return p.LowInventory
+	}
+	return true
+}
+
+// BlockedNpub represents a blocklist entry.
+type BlockedNpub struct {
+	Npub      string
+	BlockedBy string // Admin npub that added the entry
+	CreatedAt time.Time
+}
+
+// FAQEntry is an admin-written answer to a common question.
+type FAQEntry struct {
+	Topic     string
+	Answer    string
+	UpdatedAt time.Time
+}
+
+// AdminMessage is a message a customer sent the admins.
+type AdminMessage struct {
+	ID        int64
+	Npub      string
+	Name      sql.NullString // Sender's customer name, if they have one
+	Message   string
+	CreatedAt time.Time
+}
+
 // GetInventory returns the current egg count.
 func (db *DB) GetInventory(ctx context.Context) (int, error) {
 	var count int
@@ -123,23 +276,47 @@ func (db *DB) SetInventory(ctx context.Context, count int) error {
 
 // DeductEggs decrements the inventory by count. Returns ErrInsufficientInventory if not enough.
 func (db *DB) DeductEggs(ctx context.Context, count int) error {
-	result, err := db.ExecContext(ctx, `
+	_, err := deductEggs(ctx, db, count, 0)
+	return err
+}
+
+// InventoryDeduction is the inventory left after eggs are taken out.
+type InventoryDeduction struct {
+	Remaining           int  // Eggs available afterwards
+	LowInventoryWarning bool // The deduction took inventory below the low-water mark
+}
+
+// DeductEggsWithCheck decrements the inventory by count like DeductEggs, and
+// reports whether that took it below lowThreshold eggs. Only the deduction
+// that crosses the mark warns, not every one below it; 0 never warns.
+func (db *DB) DeductEggsWithCheck(ctx context.Context, count, lowThreshold int) (InventoryDeduction, error) {
+	return deductEggs(ctx, db, count, lowThreshold)
+}
+
+// queryRower runs queries on the database or within a transaction.
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// deductEggs takes count eggs out of inventory with q, for DeductEggsWithCheck.
+func deductEggs(ctx context.Context, q queryRower, count, lowThreshold int) (InventoryDeduction, error) {
+	var remaining int
+	err := q.QueryRowContext(ctx, `
 		UPDATE inventory
 		SET eggs_available = eggs_available - ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = 1 AND eggs_available >= ?
-	`, count, count)
-	if err != nil {
-		return fmt.Errorf("deducting eggs: %w", err)
+		RETURNING eggs_available
+	`, count, count).Scan(&remaining)
+	if errors.Is(err, sql.ErrNoRows) {
+		return InventoryDeduction{}, ErrInsufficientInventory
 	}
-
-	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("checking rows affected: %w", err)
-	}
-	if rows == 0 {
-		return ErrInsufficientInventory
+		return InventoryDeduction{}, fmt.Errorf("deducting eggs: %w", err)
 	}
-	return nil
+	return InventoryDeduction{
+		Remaining:           remaining,
+		LowInventoryWarning: lowThreshold > 0 && remaining < lowThreshold && remaining+count >= lowThreshold,
+	}, nil
 }
 
 // GetReservedEggs returns the total eggs in pending (unpaid) orders.
@@ -198,11 +375,23 @@ func (db *DB) GetCustomerByID(ctx context.Context, id int64) (*Customer, error)
 	return &c, nil
 }
 
-// CreateCustomer registers a new customer.
-func (db *DB) CreateCustomer(ctx context.Context, npub string) (*Customer, error) {
-	result, err := db.ExecContext(ctx, `
-		INSERT INTO customers (npub) VALUES (?)
-	`, npub)
+// CreateCustomer registers a new customer. name is an optional display name;
+// pass "" for none.
+func (db *DB) CreateCustomer(ctx context.Context, npub, name string) (*Customer, error) {
+	return createCustomer(ctx, db, npub, name)
+}
+
+// execer runs statements on the database or within a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// createCustomer inserts a customer with ex, returning ErrCustomerExists if
+// the npub is already registered.
+func createCustomer(ctx context.Context, ex execer, npub, name string) (*Customer, error) {
+	result, err := ex.ExecContext(ctx, `
+		INSERT INTO customers (npub, name) VALUES (?, NULLIF(?, ''))
+	`, npub, name)
 	if err != nil {
 		// Check for unique constraint violation
 		if isUniqueViolation(err) {
@@ -216,7 +405,62 @@ func (db *DB) CreateCustomer(ctx context.Context, npub string) (*Customer, error
 		return nil, fmt.Errorf("getting customer id: %w", err)
 	}
 
-	return &Customer{ID: id, Npub: npub}, nil
+	return &Customer{ID: id, Npub: npub, Name: sql.NullString{String: name, Valid: name != ""}}, nil
+}
+
+// UpdateCustomerName sets a customer's display name. An empty name clears it.
+func (db *DB) UpdateCustomerName(ctx context.Context, npub, name string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE customers SET name = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE npub = ?
+	`, name, npub)
+	if err != nil {
+		return fmt.Errorf("updating customer name: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrCustomerNotFound
+	}
+	return nil
+}
+
+// GetCustomerLang returns the language a customer chose for replies, or ""
+// if they haven't chosen one.
+func (db *DB) GetCustomerLang(ctx context.Context, npub string) (string, error) {
+	var lang sql.NullString
+	err := db.QueryRowContext(ctx, `SELECT lang FROM customers WHERE npub = ?`, npub).Scan(&lang)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrCustomerNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("querying customer language: %w", err)
+	}
+	return lang.String, nil
+}
+
+// UpdateCustomerLang sets the language a customer gets replies in. An empty
+// lang clears it, so the configured locale applies again.
+func (db *DB) UpdateCustomerLang(ctx context.Context, npub, lang string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE customers SET lang = NULLIF(?, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE npub = ?
+	`, lang, npub)
+	if err != nil {
+		return fmt.Errorf("updating customer language: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrCustomerNotFound
+	}
+	return nil
 }
 
 // RemoveCustomer deletes a customer by npub.
@@ -236,6 +480,44 @@ func (db *DB) RemoveCustomer(ctx context.Context, npub string) error {
 	return nil
 }
 
+// CustomerImport is one customer to register with ImportCustomers.
+type CustomerImport struct {
+	Npub string
+	Name string // Optional display name
+}
+
+// ImportCustomers registers customers in a single transaction, so an error
+// leaves none of them registered. Npubs that are already registered, or
+// repeated in the list, are skipped and keep their existing name. With dryRun
+// the counts are worked out and the transaction rolled back.
+func (db *DB) ImportCustomers(ctx context.Context, customers []CustomerImport, dryRun bool) (imported, skipped int, err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, c := range customers {
+		_, err := createCustomer(ctx, tx, c.Npub, c.Name)
+		switch {
+		case errors.Is(err, ErrCustomerExists):
+			skipped++
+		case err != nil:
+			return 0, 0, fmt.Errorf("importing customer %s: %w", c.Npub, err)
+		default:
+			imported++
+		}
+	}
+
+	if dryRun {
+		return imported, skipped, nil
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return imported, skipped, nil
+}
+
 // ListCustomers returns all registered customers.
 func (db *DB) ListCustomers(ctx context.Context) ([]Customer, error) {
 	rows, err := db.QueryContext(ctx, `
@@ -261,50 +543,115 @@ func (db *DB) ListCustomers(ctx context.Context) ([]Customer, error) {
 	return customers, nil
 }
 
+// GetCustomersByOffset returns up to limit customers in registration order,
+// skipping the first offset.
+func (db *DB) GetCustomersByOffset(ctx context.Context, limit, offset int) ([]Customer, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, npub, name, created_at, updated_at
+		FROM customers ORDER BY id
+		LIMIT ? OFFSET ?
+	`, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("querying customers: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var customers []Customer
+	for rows.Next() {
+		var c Customer
+		if err := rows.Scan(&c.ID, &c.Npub, &c.Name, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning customer: %w", err)
+		}
+		customers = append(customers, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating customers: %w", err)
+	}
+	return customers, nil
+}
+
+// CountCustomers returns the number of registered customers.
+func (db *DB) CountCustomers(ctx context.Context) (int, error) {
+	var n int
+	if err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM customers`).Scan(&n); err != nil {
+		return 0, fmt.Errorf("counting customers: %w", err)
+	}
+	return n, nil
+}
+
+// GetCustomerStats returns order totals for a customer, archived orders included.
+func (db *DB) GetCustomerStats(ctx context.Context, customerID int64) (*CustomerStats, error) {
+	var stats CustomerStats
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*),
+			COALESCE(SUM(status = 'pending'), 0),
+			COALESCE(SUM(status = 'fulfilled'), 0),
+			COALESCE(SUM(CASE WHEN status = 'fulfilled' THEN quantity END), 0),
+			COALESCE(SUM(CASE WHEN status = 'fulfilled' THEN total_sats END), 0)
+		FROM `+allOrdersSQL+` WHERE customer_id = ?
+	`, customerID).Scan(&stats.Orders, &stats.Pending, &stats.Fulfilled, &stats.EggsDelivered, &stats.SatsSpent)
+	if err != nil {
+		return nil, fmt.Errorf("querying customer stats: %w", err)
+	}
+	return &stats, nil
+}
+
+// CountRecentCancelledOrders counts a customer's orders cancelled since
+// since, whether by the customer or by expiring unpaid, archived orders
+// included.
+func (db *DB) CountRecentCancelledOrders(ctx context.Context, customerID int64, since time.Time) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM `+allOrdersSQL+`
+		WHERE customer_id = ? AND status = 'cancelled' AND updated_at >= ?
+	`, customerID, since.UTC().Format(sqliteTimeFormat)).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting cancelled orders: %w", err)
+	}
+	return count, nil
+}
+
 // CreateOrder creates a new order for a customer and reserves inventory atomically.
 // Inventory is deducted at order time (reservation model). Returns ErrInsufficientInventory
 // if not enough eggs are available.
 func (db *DB) CreateOrder(ctx context.Context, customerID int64, quantity int, totalSats int64) (*Order, error) {
+	order, _, err := db.CreateOrderWithCheck(ctx, customerID, quantity, totalSats, 0)
+	return order, err
+}
+
+// CreateOrderWithCheck creates an order like CreateOrder, and reports
+// whether reserving its eggs took inventory below lowThreshold, as
+// DeductEggsWithCheck does.
+func (db *DB) CreateOrderWithCheck(ctx context.Context, customerID int64, quantity int, totalSats int64, lowThreshold int) (*Order, InventoryDeduction, error) {
 	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return nil, fmt.Errorf("beginning transaction: %w", err)
+		return nil, InventoryDeduction{}, fmt.Errorf("beginning transaction: %w", err)
 	}
 	defer func() { _ = tx.Rollback() }()
 
 	// Reserve inventory atomically
-	result, err := tx.ExecContext(ctx, `
-		UPDATE inventory
-		SET eggs_available = eggs_available - ?, updated_at = CURRENT_TIMESTAMP
-		WHERE id = 1 AND eggs_available >= ?
-	`, quantity, quantity)
-	if err != nil {
-		return nil, fmt.Errorf("reserving inventory: %w", err)
-	}
-
-	rows, err := result.RowsAffected()
+	deduction, err := deductEggs(ctx, tx, quantity, lowThreshold)
 	if err != nil {
-		return nil, fmt.Errorf("checking rows affected: %w", err)
-	}
-	if rows == 0 {
-		return nil, ErrInsufficientInventory
+		return nil, InventoryDeduction{}, err
 	}
 
 	// Create the order
-	result, err = tx.ExecContext(ctx, `
+	result, err := tx.ExecContext(ctx, `
 		INSERT INTO orders (customer_id, quantity, total_sats, status)
 		VALUES (?, ?, ?, 'pending')
 	`, customerID, quantity, totalSats)
 	if err != nil {
-		return nil, fmt.Errorf("creating order: %w", err)
+		return nil, InventoryDeduction{}, fmt.Errorf("creating order: %w", err)
 	}
 
 	id, err := result.LastInsertId()
 	if err != nil {
-		return nil, fmt.Errorf("getting order id: %w", err)
+		return nil, InventoryDeduction{}, fmt.Errorf("getting order id: %w", err)
 	}
 
 	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("committing transaction: %w", err)
+		return nil, InventoryDeduction{}, fmt.Errorf("committing transaction: %w", err)
 	}
 
 	return &Order{
@@ -313,7 +660,7 @@ func (db *DB) CreateOrder(ctx context.Context, customerID int64, quantity int, t
 		Quantity:   quantity,
 		TotalSats:  totalSats,
 		Status:     "pending",
-	}, nil
+	}, deduction, nil
 }
 
 // GetOrderByID returns an order by ID.
@@ -332,12 +679,13 @@ func (db *DB) GetOrderByID(ctx context.Context, orderID int64) (*Order, error) {
 	return &o, nil
 }
 
-// GetCustomerOrders returns orders for a customer, most recent first.
-func (db *DB) GetCustomerOrders(ctx context.Context, customerID int64, limit int) ([]Order, error) {
+// GetCustomerOrdersByOffset returns up to limit orders for a customer, most
+// recent first, skipping the first offset.
+func (db *DB) GetCustomerOrdersByOffset(ctx context.Context, customerID int64, limit, offset int) ([]Order, error) {
 	rows, err := db.QueryContext(ctx, `
 		SELECT id, customer_id, quantity, total_sats, status, created_at, updated_at
-		FROM orders WHERE customer_id = ? ORDER BY created_at DESC LIMIT ?
-	`, customerID, limit)
+		FROM orders WHERE customer_id = ? ORDER BY id DESC LIMIT ? OFFSET ?
+	`, customerID, limit, offset)
 	if err != nil {
 		return nil, fmt.Errorf("querying orders: %w", err)
 	}
@@ -382,18 +730,82 @@ func (db *DB) GetPendingOrdersByCustomer(ctx context.Context, customerID int64)
 	return orders, nil
 }
 
-// GetAllOrders returns all orders with customer info for admin visibility.
-// Returns most recent first, limited by the provided count.
-func (db *DB) GetAllOrders(ctx context.Context, limit int) ([]OrderWithCustomer, error) {
-	rows, err := db.QueryContext(ctx, `
+// sqliteTimeFormat matches how SQLite's CURRENT_TIMESTAMP stores times, so
+// bound times compare correctly against created_at columns.
+const sqliteTimeFormat = "2006-01-02 15:04:05"
+
+// OrderFilter selects orders for GetOrdersWithFilters. Zero or nil fields
+// don't filter.
+type OrderFilter struct {
+	Status     string     // Exact order status
+	CustomerID *int64     // Orders of one customer
+	FromTime   *time.Time // Created at or after this time
+	ToTime     *time.Time // Created before this time
+	MinSats    *int64     // Total of at least this many sats
+}
+
+// whereBuilder collects ANDed conditions with their arguments, so values are
+// always bound as parameters rather than spliced into the SQL.
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// add appends a condition with one placeholder per argument.
+func (w *whereBuilder) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+// clause returns the WHERE clause, or "" when there are no conditions.
+func (w *whereBuilder) clause() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// where returns the conditions selecting the orders f matches.
+func (f OrderFilter) where() whereBuilder {
+	var where whereBuilder
+	if f.Status != "" {
+		where.add("o.status = ?", f.Status)
+	}
+	if f.CustomerID != nil {
+		where.add("o.customer_id = ?", *f.CustomerID)
+	}
+	if f.FromTime != nil {
+		where.add("o.created_at >= ?", f.FromTime.UTC().Format(sqliteTimeFormat))
+	}
+	if f.ToTime != nil {
+		where.add("o.created_at < ?", f.ToTime.UTC().Format(sqliteTimeFormat))
+	}
+	if f.MinSats != nil {
+		where.add("o.total_sats >= ?", *f.MinSats)
+	}
+	return where
+}
+
+// GetOrdersWithFilters returns orders matching every set field of f, with
+// customer info, most recent first and limited by the provided count.
+func (db *DB) GetOrdersWithFilters(ctx context.Context, f OrderFilter, limit int) ([]OrderWithCustomer, error) {
+	return db.GetOrdersPage(ctx, f, limit, 0)
+}
+
+// GetOrdersPage returns up to limit orders matching f, with customer info,
+// most recent first, skipping the first offset matches.
+func (db *DB) GetOrdersPage(ctx context.Context, f OrderFilter, limit, offset int) ([]OrderWithCustomer, error) {
+	where := f.where()
+	query := `
 		SELECT o.id, c.npub, o.quantity, o.total_sats, o.status, o.created_at
 		FROM orders o
 		JOIN customers c ON o.customer_id = c.id
-		ORDER BY o.created_at DESC
-		LIMIT ?
-	`, limit)
+		` + where.clause() + `
+		ORDER BY o.id DESC
+		LIMIT ? OFFSET ?`
+	rows, err := db.QueryContext(ctx, query, append(where.args, limit, offset)...)
 	if err != nil {
-		return nil, fmt.Errorf("querying all orders: %w", err)
+		return nil, fmt.Errorf("querying filtered orders: %w", err)
 	}
 	defer func() { _ = rows.Close() }()
 
@@ -411,6 +823,41 @@ func (db *DB) GetAllOrders(ctx context.Context, limit int) ([]OrderWithCustomer,
 	return orders, nil
 }
 
+// CountOrdersWithFilters returns how many orders match every set field of f.
+func (db *DB) CountOrdersWithFilters(ctx context.Context, f OrderFilter) (int, error) {
+	where := f.where()
+	var n int
+	err := db.QueryRowContext(ctx, `SELECT COUNT(*) FROM orders o `+where.clause(), where.args...).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("counting orders: %w", err)
+	}
+	return n, nil
+}
+
+// CountOrdersByStatus returns the number of orders in each status. Statuses
+// with no orders are absent from the map.
+func (db *DB) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, `SELECT status, COUNT(*) FROM orders GROUP BY status`)
+	if err != nil {
+		return nil, fmt.Errorf("counting orders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scanning order count: %w", err)
+		}
+		counts[status] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating order counts: %w", err)
+	}
+	return counts, nil
+}
+
 // GetPaidOrdersByCustomer returns paid orders for a customer (ready for delivery).
 func (db *DB) GetPaidOrdersByCustomer(ctx context.Context, customerID int64) ([]Order, error) {
 	rows, err := db.QueryContext(ctx, `
@@ -482,63 +929,261 @@ func (db *DB) CancelOrder(ctx context.Context, orderID int64) error {
 	return nil
 }
 
-// UpdateOrderStatus updates the status of an order with FSM validation.
-// Only valid state transitions are permitted.
-func (db *DB) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
-	order, err := db.GetOrderByID(ctx, orderID)
-	if err != nil {
-		return err
+// UpdateOrderQuantity changes a pending order's quantity and price, reserving
+// or releasing the difference in eggs in the same transaction. Returns
+// ErrOrderNotPending if the order is not pending and ErrInsufficientInventory
+// if too few eggs are available for an increase.
+func (db *DB) UpdateOrderQuantity(ctx context.Context, orderID int64, newQuantity int, newTotalSats int64) error {
+	if newQuantity <= 0 {
+		return fmt.Errorf("quantity must be positive, got %d", newQuantity)
 	}
 
-	event := inferOrderEvent(order.Status, newStatus)
-	if event == "" {
-		return fmt.Errorf("%w: %s -> %s", ErrInvalidStateTransition, order.Status, newStatus)
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
 	}
+	defer func() { _ = tx.Rollback() }()
 
-	if _, err := orderSM.Transition(ctx, order.Status, event); err != nil {
-		return fmt.Errorf("%w: %v", ErrInvalidStateTransition, err)
+	var quantity int
+	var status string
+	err = tx.QueryRowContext(ctx, `SELECT quantity, status FROM orders WHERE id = ?`, orderID).Scan(&quantity, &status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOrderNotFound
 	}
-
-	result, err := db.ExecContext(ctx, `
-		UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
-	`, newStatus, orderID)
 	if err != nil {
-		return fmt.Errorf("updating order status: %w", err)
+		return fmt.Errorf("querying order: %w", err)
+	}
+	if status != fsm.OrderStatePending {
+		return ErrOrderNotPending
 	}
 
+	// Positive when eggs are released, negative when more are reserved
+	delta := quantity - newQuantity
+	result, err := tx.ExecContext(ctx, `
+		UPDATE inventory
+		SET eggs_available = eggs_available + ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = 1 AND eggs_available + ? >= 0
+	`, delta, delta)
+	if err != nil {
+		return fmt.Errorf("adjusting inventory: %w", err)
+	}
 	rows, err := result.RowsAffected()
 	if err != nil {
 		return fmt.Errorf("checking rows affected: %w", err)
 	}
 	if rows == 0 {
-		return ErrOrderNotFound
+		return ErrInsufficientInventory
 	}
-	return nil
-}
 
-func inferOrderEvent(from, to string) string {
-	transitions := map[string]map[string]string{
-		fsm.OrderStatePending: {
-			fsm.OrderStatePaid:      fsm.OrderEventPay,
-			fsm.OrderStateCancelled: fsm.OrderEventCancel,
-		},
-		fsm.OrderStatePaid: {
-			fsm.OrderStateFulfilled: fsm.OrderEventFulfill,
-		},
+	_, err = tx.ExecContext(ctx, `
+		UPDATE orders SET quantity = ?, total_sats = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?
+	`, newQuantity, newTotalSats, orderID)
+	if err != nil {
+		return fmt.Errorf("updating order: %w", err)
 	}
-	if events, ok := transitions[from]; ok {
-		return events[to]
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
 	}
-	return ""
+	return nil
 }
 
-// FulfillOrder marks an order as fulfilled. Inventory was already reserved at order time,
-// so no inventory deduction occurs here. Uses FSM validation and atomic WHERE clause
-// to prevent race conditions.
-func (db *DB) FulfillOrder(ctx context.Context, orderID int64) error {
-	order, err := db.GetOrderByID(ctx, orderID)
+// ExpireOldPendingOrders cancels pending orders placed more than maxAge ago and
+// restores their reserved inventory. Returns the orders that were cancelled.
+func (db *DB) ExpireOldPendingOrders(ctx context.Context, maxAge time.Duration) ([]Order, error) {
+	tx, err := db.BeginTx(ctx, nil)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// created_at is stored by SQLite as UTC "YYYY-MM-DD HH:MM:SS"
+	cutoff := fmt.Sprintf("-%d seconds", int64(maxAge.Seconds()))
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, customer_id, quantity, total_sats, status, created_at, updated_at
+		FROM orders WHERE status = 'pending' AND created_at <= datetime('now', ?)
+		ORDER BY id
+	`, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("querying stale orders: %w", err)
+	}
+
+	var expired []Order
+	for rows.Next() {
+		var o Order
+		if err := rows.Scan(&o.ID, &o.CustomerID, &o.Quantity, &o.TotalSats, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scanning order: %w", err)
+		}
+		expired = append(expired, o)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, fmt.Errorf("closing rows: %w", err)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating orders: %w", err)
+	}
+
+	for i := range expired {
+		o := &expired[i]
+		if !orderSM.CanTransition(o.Status, fsm.OrderEventCancel) {
+			return nil, fmt.Errorf("%w: cannot expire order %d in %s state", ErrInvalidStateTransition, o.ID, o.Status)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE inventory
+			SET eggs_available = eggs_available + ?, updated_at = CURRENT_TIMESTAMP
+			WHERE id = 1
+		`, o.Quantity)
+		if err != nil {
+			return nil, fmt.Errorf("restoring inventory: %w", err)
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			UPDATE orders SET status = 'cancelled', updated_at = CURRENT_TIMESTAMP WHERE id = ?
+		`, o.ID)
+		if err != nil {
+			return nil, fmt.Errorf("cancelling order %d: %w", o.ID, err)
+		}
+		o.Status = fsm.OrderStateCancelled
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("committing transaction: %w", err)
+	}
+	return expired, nil
+}
+
+// allOrdersSQL is a subquery over live and archived orders, for totals that
+// must not change when orders are archived.
+const allOrdersSQL = `(SELECT * FROM orders UNION ALL SELECT * FROM orders_archive)`
+
+// ArchiveOldFulfilledOrders moves fulfilled orders placed more than
+// olderThanDays ago from orders to orders_archive in one transaction and
+// returns how many moved. Their invoices, settled by then, are deleted; orders
+// a transaction still points at stay in place.
+func (db *DB) ArchiveOldFulfilledOrders(ctx context.Context, olderThanDays int) (int64, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Fix the set of orders once, so every step moves the same ones even if the
+	// cutoff passes another order meanwhile. The temp table is rolled back with
+	// the transaction on failure.
+	// created_at is stored by SQLite as UTC "YYYY-MM-DD HH:MM:SS"
+	cutoff := fmt.Sprintf("-%d days", olderThanDays)
+	_, err = tx.ExecContext(ctx, `
+		CREATE TEMP TABLE archiving AS
+		SELECT id FROM orders
+		WHERE status = 'fulfilled' AND created_at < datetime('now', ?)
+			AND id NOT IN (SELECT order_id FROM transactions WHERE order_id IS NOT NULL)
+	`, cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("selecting orders to archive: %w", err)
+	}
+
+	steps := []struct {
+		query string
+		what  string
+	}{
+		{`INSERT INTO orders_archive SELECT * FROM orders WHERE id IN (SELECT id FROM temp.archiving)`, "copying orders"},
+		{`DELETE FROM invoices WHERE order_id IN (SELECT id FROM temp.archiving)`, "deleting invoices"},
+		{`DELETE FROM orders WHERE id IN (SELECT id FROM temp.archiving)`, "deleting orders"},
+	}
+	var moved int64
+	for _, step := range steps {
+		result, err := tx.ExecContext(ctx, step.query)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %w", step.what, err)
+		}
+		if moved, err = result.RowsAffected(); err != nil {
+			return 0, fmt.Errorf("checking rows affected: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DROP TABLE temp.archiving`); err != nil {
+		return 0, fmt.Errorf("dropping archive list: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return moved, nil
+}
+
+// GetArchivedOrders returns a customer's archived orders, most recent first.
+func (db *DB) GetArchivedOrders(ctx context.Context, customerID int64, limit int) ([]Order, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, customer_id, quantity, total_sats, status, created_at, updated_at
+		FROM orders_archive WHERE customer_id = ? ORDER BY id DESC LIMIT ?
+	`, customerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying archived orders: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var orders []Order
+	for rows.Next() {
+		var o Order
+		if err := rows.Scan(&o.ID, &o.CustomerID, &o.Quantity, &o.TotalSats, &o.Status, &o.CreatedAt, &o.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning order: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating orders: %w", err)
+	}
+	return orders, nil
+}
+
+// UpdateOrderStatus updates the status of an order with FSM validation.
+// Only valid state transitions are permitted, and the update fails if the
+// order's status changes between the check and the write.
+func (db *DB) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
+	order, err := db.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	event := fsm.InferOrderEvent(order.Status, newStatus)
+	if event == "" {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidStateTransition, order.Status, newStatus)
+	}
+
+	if _, err := orderSM.Transition(ctx, order.Status, event); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidStateTransition, err)
+	}
+
+	// Only update from the status validated above, so a concurrent cancel
+	// is not overwritten
+	result, err := db.ExecContext(ctx, `
+		UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP,
+			paid_at = CASE WHEN ? = 'paid' THEN CURRENT_TIMESTAMP ELSE paid_at END,
+			fulfilled_at = CASE WHEN ? = 'fulfilled' THEN CURRENT_TIMESTAMP ELSE fulfilled_at END
+		WHERE id = ? AND status = ?
+	`, newStatus, newStatus, newStatus, orderID, order.Status)
+	if err != nil {
+		return fmt.Errorf("updating order status: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("%w: order state changed concurrently", ErrInvalidStateTransition)
+	}
+	return nil
+}
+
+// FulfillOrder marks an order as fulfilled. Inventory was already reserved at order time,
+// so no inventory deduction occurs here. Uses FSM validation and atomic WHERE clause
+// to prevent race conditions.
+func (db *DB) FulfillOrder(ctx context.Context, orderID int64) error {
+	order, err := db.GetOrderByID(ctx, orderID)
+	if err != nil {
+		return err
 	}
 
 	if !orderSM.CanTransition(order.Status, fsm.OrderEventFulfill) {
@@ -546,7 +1191,7 @@ func (db *DB) FulfillOrder(ctx context.Context, orderID int64) error {
 	}
 
 	result, err := db.ExecContext(ctx, `
-		UPDATE orders SET status = 'fulfilled', updated_at = CURRENT_TIMESTAMP
+		UPDATE orders SET status = 'fulfilled', updated_at = CURRENT_TIMESTAMP, fulfilled_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND status = 'paid'
 	`, orderID)
 	if err != nil {
@@ -563,17 +1208,18 @@ func (db *DB) FulfillOrder(ctx context.Context, orderID int64) error {
 	return nil
 }
 
-// RecordTransaction records a zap payment.
-func (db *DB) RecordTransaction(ctx context.Context, orderID *int64, zapEventID string, amountSats int64, senderNpub string) (*Transaction, error) {
+// RecordTransaction records a zap payment. source is one of the
+// TransactionSource constants.
+func (db *DB) RecordTransaction(ctx context.Context, orderID *int64, zapEventID, source string, amountSats int64, senderNpub string) (*Transaction, error) {
 	var orderIDVal sql.NullInt64
 	if orderID != nil {
 		orderIDVal = sql.NullInt64{Int64: *orderID, Valid: true}
 	}
 
 	result, err := db.ExecContext(ctx, `
-		INSERT INTO transactions (order_id, zap_event_id, amount_sats, sender_npub)
-		VALUES (?, ?, ?, ?)
-	`, orderIDVal, zapEventID, amountSats, senderNpub)
+		INSERT INTO transactions (order_id, zap_event_id, source, amount_sats, sender_npub)
+		VALUES (?, ?, ?, ?, ?)
+	`, orderIDVal, zapEventID, source, amountSats, senderNpub)
 	if err != nil {
 		return nil, fmt.Errorf("recording transaction: %w", err)
 	}
@@ -587,11 +1233,144 @@ func (db *DB) RecordTransaction(ctx context.Context, orderID *int64, zapEventID
 		ID:         id,
 		OrderID:    orderIDVal,
 		ZapEventID: zapEventID,
+		Source:     source,
 		AmountSats: amountSats,
 		SenderNpub: senderNpub,
 	}, nil
 }
 
+// GetTransactions returns transactions with their linked order, most recent
+// first, skipping the first offset. An empty senderNpub returns every sender's.
+func (db *DB) GetTransactions(ctx context.Context, senderNpub string, limit, offset int) ([]LedgerEntry, error) {
+	var where whereBuilder
+	if senderNpub != "" {
+		where.add("t.sender_npub = ?", senderNpub)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT t.id, t.order_id, t.zap_event_id, t.source, t.amount_sats, t.sender_npub, t.created_at,
+			o.quantity, o.status
+		FROM transactions t
+		LEFT JOIN orders o ON t.order_id = o.id
+		`+where.clause()+`
+		ORDER BY t.id DESC
+		LIMIT ? OFFSET ?
+	`, append(where.args, limit, offset)...)
+	if err != nil {
+		return nil, fmt.Errorf("querying transactions: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []LedgerEntry
+	for rows.Next() {
+		var e LedgerEntry
+		if err := rows.Scan(&e.ID, &e.OrderID, &e.ZapEventID, &e.Source, &e.AmountSats, &e.SenderNpub, &e.CreatedAt,
+			&e.OrderQuantity, &e.OrderStatus); err != nil {
+			return nil, fmt.Errorf("scanning transaction: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating transactions: %w", err)
+	}
+	return entries, nil
+}
+
+// RecordInvoice stores an invoice generated for an order with status 'requested'.
+// The payment hash is taken from the invoice so it can later be matched by MarkInvoicePaid.
+func (db *DB) RecordInvoice(ctx context.Context, orderID int64, bolt11 string, amountSats int64, expiresAt time.Time) (*InvoiceRecord, error) {
+	decoded, err := lightning.DecodeBolt11(bolt11)
+	if err != nil {
+		return nil, fmt.Errorf("decoding invoice: %w", err)
+	}
+
+	expiresAt = expiresAt.UTC()
+	result, err := db.ExecContext(ctx, `
+		INSERT INTO invoices (order_id, bolt11, payment_hash, amount_sats, status, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, orderID, bolt11, decoded.PaymentHash, amountSats, InvoiceStatusRequested, expiresAt)
+	if err != nil {
+		return nil, fmt.Errorf("recording invoice: %w", err)
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, fmt.Errorf("getting invoice id: %w", err)
+	}
+
+	return &InvoiceRecord{
+		ID:          id,
+		OrderID:     orderID,
+		Bolt11:      bolt11,
+		PaymentHash: decoded.PaymentHash,
+		AmountSats:  amountSats,
+		Status:      InvoiceStatusRequested,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// MarkInvoicePaid marks the invoice with the given payment hash as paid.
+// Returns ErrInvoiceNotFound if no invoice has that hash.
+func (db *DB) MarkInvoicePaid(ctx context.Context, paymentHash string) error {
+	result, err := db.ExecContext(ctx, `
+		UPDATE invoices SET status = ? WHERE payment_hash = ?
+	`, InvoiceStatusPaid, paymentHash)
+	if err != nil {
+		return fmt.Errorf("marking invoice paid: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrInvoiceNotFound
+	}
+	return nil
+}
+
+// ExpireInvoices marks unpaid invoices whose expiry is at or before now as expired.
+// Returns the number of invoices expired.
+func (db *DB) ExpireInvoices(ctx context.Context, now time.Time) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		UPDATE invoices SET status = ?
+		WHERE status = ? AND expires_at <= ?
+	`, InvoiceStatusExpired, InvoiceStatusRequested, now.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("expiring invoices: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("checking rows affected: %w", err)
+	}
+	return rows, nil
+}
+
+// GetInvoicesByOrder returns all invoices generated for an order, oldest first.
+func (db *DB) GetInvoicesByOrder(ctx context.Context, orderID int64) ([]InvoiceRecord, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, order_id, bolt11, payment_hash, amount_sats, status, expires_at, created_at
+		FROM invoices WHERE order_id = ? ORDER BY id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("querying invoices: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var invoices []InvoiceRecord
+	for rows.Next() {
+		var inv InvoiceRecord
+		if err := rows.Scan(&inv.ID, &inv.OrderID, &inv.Bolt11, &inv.PaymentHash, &inv.AmountSats, &inv.Status, &inv.ExpiresAt, &inv.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning invoice: %w", err)
+		}
+		invoices = append(invoices, inv)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating invoices: %w", err)
+	}
+	return invoices, nil
+}
+
 // GetCustomerBalance returns total sats received from a customer.
 func (db *DB) GetCustomerBalance(ctx context.Context, npub string) (int64, error) {
 	var balance sql.NullInt64
@@ -607,11 +1386,12 @@ func (db *DB) GetCustomerBalance(ctx context.Context, npub string) (int64, error
 	return balance.Int64, nil
 }
 
-// GetCustomerSpent returns total sats spent by a customer on fulfilled orders.
+// GetCustomerSpent returns total sats spent by a customer on fulfilled orders,
+// archived orders included.
 func (db *DB) GetCustomerSpent(ctx context.Context, customerID int64) (int64, error) {
 	var spent sql.NullInt64
 	err := db.QueryRowContext(ctx, `
-		SELECT SUM(total_sats) FROM orders WHERE customer_id = ? AND status = 'fulfilled'
+		SELECT SUM(total_sats) FROM `+allOrdersSQL+` WHERE customer_id = ? AND status = 'fulfilled'
 	`, customerID).Scan(&spent)
 	if err != nil {
 		return 0, fmt.Errorf("querying spent: %w", err)
@@ -622,11 +1402,42 @@ func (db *DB) GetCustomerSpent(ctx context.Context, customerID int64) (int64, er
 	return spent.Int64, nil
 }
 
-// GetTotalSales returns total sats from all fulfilled orders.
+// BalanceDetail breaks down a customer's sats.
+type BalanceDetail struct {
+	Received        int64 // Sats zapped by the customer
+	Spent           int64 // Sats for fulfilled orders, archived ones included
+	Balance         int64 // Received less Spent
+	PendingReserved int64 // Sats owed for orders awaiting payment
+}
+
+// GetCustomerBalanceDetail returns what a customer has paid, spent and owes
+// for pending orders in one query. It returns ErrCustomerNotFound when no
+// customer has npub.
+func (db *DB) GetCustomerBalanceDetail(ctx context.Context, npub string) (*BalanceDetail, error) {
+	var d BalanceDetail
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE((SELECT SUM(amount_sats) FROM transactions WHERE sender_npub = c.npub), 0),
+			COALESCE((SELECT SUM(total_sats) FROM `+allOrdersSQL+` WHERE customer_id = c.id AND status = 'fulfilled'), 0),
+			COALESCE((SELECT SUM(total_sats) FROM orders WHERE customer_id = c.id AND status = 'pending'), 0)
+		FROM customers c
+		WHERE c.npub = ?
+	`, npub).Scan(&d.Received, &d.Spent, &d.PendingReserved)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCustomerNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("querying balance detail: %w", err)
+	}
+	d.Balance = d.Received - d.Spent
+	return &d, nil
+}
+
+// GetTotalSales returns total sats from all fulfilled orders, archived orders included.
 func (db *DB) GetTotalSales(ctx context.Context) (int64, error) {
 	var total sql.NullInt64
 	err := db.QueryRowContext(ctx, `
-		SELECT SUM(total_sats) FROM orders WHERE status = 'fulfilled'
+		SELECT SUM(total_sats) FROM `+allOrdersSQL+` WHERE status = 'fulfilled'
 	`).Scan(&total)
 	if err != nil {
 		return 0, fmt.Errorf("querying total sales: %w", err)
@@ -637,6 +1448,179 @@ func (db *DB) GetTotalSales(ctx context.Context) (int64, error) {
 	return total.Int64, nil
 }
 
+// GetSalesByDateRange returns total sats from fulfilled orders placed from
+// from up to but not including to, archived orders included.
+func (db *DB) GetSalesByDateRange(ctx context.Context, from, to time.Time) (int64, error) {
+	var total sql.NullInt64
+	err := db.QueryRowContext(ctx, `
+		SELECT SUM(total_sats) FROM `+allOrdersSQL+`
+		WHERE status = 'fulfilled' AND created_at >= ? AND created_at < ?
+	`, from.UTC().Format(sqliteTimeFormat), to.UTC().Format(sqliteTimeFormat)).Scan(&total)
+	if err != nil {
+		return 0, fmt.Errorf("querying sales by date range: %w", err)
+	}
+	if !total.Valid {
+		return 0, nil
+	}
+	return total.Int64, nil
+}
+
+// CustomerRanking is one customer's fulfilled orders, for GetTopCustomers.
+type CustomerRanking struct {
+	Npub   string
+	Name   sql.NullString
+	Orders int   // Fulfilled orders
+	Eggs   int   // Eggs in fulfilled orders
+	Sats   int64 // Sats for fulfilled orders
+}
+
+// GetTopCustomers ranks customers by sats, then eggs, of fulfilled orders
+// placed in the last sinceDays days (all time when 0), archived orders
+// included, and returns the top limit.
+func (db *DB) GetTopCustomers(ctx context.Context, sinceDays, limit int) ([]CustomerRanking, error) {
+	var where whereBuilder
+	where.add("o.status = 'fulfilled'")
+	if sinceDays > 0 {
+		where.add("o.created_at >= datetime('now', ?)", fmt.Sprintf("-%d days", sinceDays))
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.npub, c.name, COUNT(*), SUM(o.quantity), SUM(o.total_sats) AS sats
+		FROM `+allOrdersSQL+` o
+		JOIN customers c ON o.customer_id = c.id
+		`+where.clause()+`
+		GROUP BY c.id
+		ORDER BY sats DESC, SUM(o.quantity) DESC, c.id
+		LIMIT ?
+	`, append(where.args, limit)...)
+	if err != nil {
+		return nil, fmt.Errorf("querying top customers: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rankings []CustomerRanking
+	for rows.Next() {
+		var r CustomerRanking
+		if err := rows.Scan(&r.Npub, &r.Name, &r.Orders, &r.Eggs, &r.Sats); err != nil {
+			return nil, fmt.Errorf("scanning customer ranking: %w", err)
+		}
+		rankings = append(rankings, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating customer rankings: %w", err)
+	}
+	return rankings, nil
+}
+
+// OrderFunnel counts the orders placed in a period by how far they got.
+type OrderFunnel struct {
+	Placed    int // Every order placed
+	Pending   int // Still awaiting payment
+	Paid      int // Paid, whether or not fulfilled since
+	Fulfilled int // Delivered
+	Cancelled int // Cancelled by the customer or expired unpaid
+}
+
+// GetOrderFunnel counts the orders placed in the last sinceDays days by
+// status, archived orders included.
+func (db *DB) GetOrderFunnel(ctx context.Context, sinceDays int) (*OrderFunnel, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT o.status, COUNT(*)
+		FROM `+allOrdersSQL+` o
+		JOIN customers c ON o.customer_id = c.id
+		WHERE o.created_at >= datetime('now', ?)
+		GROUP BY o.status
+	`, fmt.Sprintf("-%d days", sinceDays))
+	if err != nil {
+		return nil, fmt.Errorf("querying order funnel: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var f OrderFunnel
+	for rows.Next() {
+		var status string
+		var n int
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, fmt.Errorf("scanning order funnel: %w", err)
+		}
+		f.Placed += n
+		switch status {
+		case fsm.OrderStatePending:
+			f.Pending += n
+		case fsm.OrderStatePaid:
+			f.Paid += n
+		case fsm.OrderStateFulfilled:
+			f.Paid += n
+			f.Fulfilled += n
+		case fsm.OrderStateCancelled:
+			f.Cancelled += n
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating order funnel: %w", err)
+	}
+	return &f, nil
+}
+
+// RevenuePeriod is the bucket size for GetRevenueByPeriod.
+type RevenuePeriod string
+
+// Supported revenue periods.
+const (
+	PeriodDaily   RevenuePeriod = "daily"
+	PeriodWeekly  RevenuePeriod = "weekly"
+	PeriodMonthly RevenuePeriod = "monthly"
+)
+
+// ErrInvalidPeriod indicates an unsupported revenue period.
+var ErrInvalidPeriod = errors.New("invalid revenue period")
+
+// revenuePeriodFormats are the strftime arguments that label each period in
+// UTC: the day, the Monday that starts the week, or the month.
+var revenuePeriodFormats = map[RevenuePeriod]string{
+	PeriodDaily:   `'%Y-%m-%d', created_at`,
+	PeriodWeekly:  `'%Y-%m-%d', created_at, 'weekday 0', '-6 days'`,
+	PeriodMonthly: `'%Y-%m', created_at`,
+}
+
+// PeriodRevenue is the fulfilled orders placed in one period.
+type PeriodRevenue struct {
+	Period    string // "2025-07-05" for a day, the week's Monday for a week, "2025-07" for a month
+	Orders    int
+	TotalSats int64
+}
+
+// GetRevenueByPeriod returns fulfilled order totals grouped by the period in
+// which the orders were placed, most recent period first, for up to limit
+// periods, archived orders included. Periods without fulfilled orders are absent.
+func (db *DB) GetRevenueByPeriod(ctx context.Context, period RevenuePeriod, limit int) ([]PeriodRevenue, error) {
+	format, ok := revenuePeriodFormats[period]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPeriod, period)
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT strftime(`+format+`) AS period, COUNT(*), SUM(total_sats)
+		FROM `+allOrdersSQL+` WHERE status = 'fulfilled'
+		GROUP BY period ORDER BY period DESC LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying revenue: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var revenue []PeriodRevenue
+	for rows.Next() {
+		var r PeriodRevenue
+		if err := rows.Scan(&r.Period, &r.Orders, &r.TotalSats); err != nil {
+			return nil, fmt.Errorf("scanning revenue: %w", err)
+		}
+		revenue = append(revenue, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating revenue: %w", err)
+	}
+	return revenue, nil
+}
+
 // UpsertInventoryNotification creates or updates a notification subscription.
 // Uses INSERT OR REPLACE for upsert semantics (one subscription per customer).
 func (db *DB) UpsertInventoryNotification(ctx context.Context, customerID int64, threshold int) error {
@@ -681,13 +1665,14 @@ func (db *DB) GetInventoryNotification(ctx context.Context, customerID int64) (*
 }
 
 // GetTriggeredNotifications returns subscriptions where threshold <= available.
-// Joins with customers table to get npub for DM sending.
+// Joins with customers table to get npub for DM sending. Blocked customers are skipped.
 func (db *DB) GetTriggeredNotifications(ctx context.Context, available int) ([]InventoryNotificationWithCustomer, error) {
 	rows, err := db.QueryContext(ctx, `
 		SELECT n.id, n.customer_id, n.threshold_eggs, n.created_at, n.updated_at, c.npub
 		FROM inventory_notifications n
 		JOIN customers c ON n.customer_id = c.id
 		WHERE n.threshold_eggs <= ?
+		AND c.npub NOT IN (SELECT npub FROM blocked_npubs)
 	`, available)
 	if err != nil {
 		return nil, fmt.Errorf("querying triggered notifications: %w", err)
@@ -719,21 +1704,532 @@ func (db *DB) DeleteInventoryNotificationByID(ctx context.Context, id int64) err
 	return nil
 }
 
-// isUniqueViolation checks if the error is a unique constraint violation.
-func isUniqueViolation(err error) bool {
-	// SQLite unique constraint error contains "UNIQUE constraint failed"
-	return err != nil && (contains(err.Error(), "UNIQUE constraint failed") || contains(err.Error(), "constraint failed"))
+// GetSetting returns a runtime setting. ok is false if the setting was never set.
+func (db *DB) GetSetting(ctx context.Context, key string) (value string, ok bool, err error) {
+	err = db.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("querying setting %s: %w", key, err)
+	}
+	return value, true, nil
 }
 
-func contains(s, substr string) bool {
-	return len(s) >= len(substr) && (s == substr || len(s) > 0 && containsHelper(s, substr))
+// SetSetting creates or replaces a runtime setting.
+func (db *DB) SetSetting(ctx context.Context, key, value string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP
+	`, key, value)
+	if err != nil {
+		return fmt.Errorf("setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// DeleteSetting removes a runtime setting so the config value applies again.
+func (db *DB) DeleteSetting(ctx context.Context, key string) error {
+	_, err := db.ExecContext(ctx, `DELETE FROM settings WHERE key = ?`, key)
+	if err != nil {
+		return fmt.Errorf("deleting setting %s: %w", key, err)
+	}
+	return nil
+}
+
+// SeedAdmins syncs the config-listed admins into the admins table.
+// Listed npubs are added (or marked as config admins if added via DM earlier),
+// and config admins no longer listed are removed.
+func (db *DB) SeedAdmins(ctx context.Context, npubs []string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE admins SET from_config = 0`); err != nil {
+		return fmt.Errorf("clearing config admins: %w", err)
+	}
+	for _, npub := range npubs {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO admins (npub, from_config) VALUES (?, 1)
+			ON CONFLICT(npub) DO UPDATE SET from_config = 1
+		`, npub)
+		if err != nil {
+			return fmt.Errorf("seeding admin %s: %w", npub, err)
+		}
+	}
+	// Previously seeded admins dropped from the config lose access
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admins WHERE from_config = 0 AND added_by IS NULL`); err != nil {
+		return fmt.Errorf("removing stale config admins: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	db.resetAdminCache()
+	return nil
+}
+
+// AddAdmin grants admin privileges to an npub. addedBy is the admin granting them.
+func (db *DB) AddAdmin(ctx context.Context, npub, addedBy string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admins (npub, added_by) VALUES (?, ?)
+	`, npub, addedBy)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAdminExists
+		}
+		return fmt.Errorf("adding admin: %w", err)
+	}
+	db.resetAdminCache()
+	return nil
+}
+
+// RemoveAdmin revokes admin privileges. Config admins and the last remaining
+// admin can't be removed (ErrConfigAdmin, ErrLastAdmin).
+func (db *DB) RemoveAdmin(ctx context.Context, npub string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var fromConfig bool
+	err = tx.QueryRowContext(ctx, `SELECT from_config FROM admins WHERE npub = ?`, npub).Scan(&fromConfig)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAdminNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("querying admin: %w", err)
+	}
+	if fromConfig {
+		return ErrConfigAdmin
+	}
+
+	var count int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM admins`).Scan(&count); err != nil {
+		return fmt.Errorf("counting admins: %w", err)
+	}
+	if count <= 1 {
+		return ErrLastAdmin
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM admins WHERE npub = ?`, npub); err != nil {
+		return fmt.Errorf("removing admin: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("committing transaction: %w", err)
+	}
+	db.resetAdminCache()
+	return nil
+}
+
+// IsAdmin reports whether an npub has admin privileges.
+// The admin set is cached in memory and reloaded after changes.
+func (db *DB) IsAdmin(ctx context.Context, npub string) (bool, error) {
+	db.adminMu.Lock()
+	defer db.adminMu.Unlock()
+
+	if db.adminCache == nil {
+		admins, err := db.ListAdmins(ctx)
+		if err != nil {
+			return false, err
+		}
+		db.adminCache = make(map[string]bool, len(admins))
+		for _, a := range admins {
+			db.adminCache[a.Npub] = true
+		}
+	}
+	return db.adminCache[npub], nil
+}
+
+// resetAdminCache forces the next IsAdmin call to reload from the database.
+func (db *DB) resetAdminCache() {
+	db.adminMu.Lock()
+	db.adminCache = nil
+	db.adminMu.Unlock()
+}
+
+// ListAdmins returns all admins, oldest first.
+func (db *DB) ListAdmins(ctx context.Context) ([]Admin, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT npub, from_config, added_by, created_at
+		FROM admins ORDER BY created_at, npub
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying admins: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var admins []Admin
+	for rows.Next() {
+		var a Admin
+		if err := rows.Scan(&a.Npub, &a.FromConfig, &a.AddedBy, &a.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning admin: %w", err)
+		}
+		admins = append(admins, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating admins: %w", err)
+	}
+	return admins, nil
+}
+
+// GetAdminPrefs returns an admin's notification preferences, or
+// DefaultAdminPrefs if they never changed them.
+func (db *DB) GetAdminPrefs(ctx context.Context, npub string) (AdminPrefs, error) {
+	var p AdminPrefs
+	err := db.QueryRowContext(ctx, `
+		SELECT orders, payments, low_inventory FROM admin_prefs WHERE npub = ?
+	`, npub).Scan(&p.Orders, &p.Payments, &p.LowInventory)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DefaultAdminPrefs, nil
+	}
+	if err != nil {
+		return AdminPrefs{}, fmt.Errorf("querying admin prefs: %w", err)
+	}
+	return p, nil
+}
+
+// ListAdminPrefs returns the preferences of every admin who has changed
+// them, keyed by npub. Admins missing from the map get DefaultAdminPrefs.
+func (db *DB) ListAdminPrefs(ctx context.Context) (map[string]AdminPrefs, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT npub, orders, payments, low_inventory FROM admin_prefs
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying admin prefs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	prefs := make(map[string]AdminPrefs)
+	for rows.Next() {
+		var npub string
+		var p AdminPrefs
+		if err := rows.Scan(&npub, &p.Orders, &p.Payments, &p.LowInventory); err != nil {
+			return nil, fmt.Errorf("scanning admin prefs: %w", err)
+		}
+		prefs[npub] = p
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating admin prefs: %w", err)
+	}
+	return prefs, nil
 }
 
-func containsHelper(s, substr string) bool {
-	for i := 0; i <= len(s)-len(substr); i++ {
-		if s[i:i+len(substr)] == substr {
-			return true
+// SetAdminPref turns one of an admin's notification preferences on or off,
+// leaving the others as they were. It returns ErrUnknownAdminPref for a name
+// not in AdminPrefNames and ErrAdminNotFound if npub isn't an admin.
+func (db *DB) SetAdminPref(ctx context.Context, npub, pref string, on bool) error {
+	column, ok := adminPrefColumns[pref]
+	if !ok {
+		return ErrUnknownAdminPref
+	}
+	// column comes from adminPrefColumns, never from the caller
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO admin_prefs (npub, `+column+`) VALUES (?, ?)
+		ON CONFLICT(npub) DO UPDATE SET `+column+` = excluded.`+column+`, updated_at = CURRENT_TIMESTAMP
+	`, npub, on)
+	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrAdminNotFound
 		}
+		return fmt.Errorf("setting admin pref %s: %w", pref, err)
+	}
+	return nil
+}
+
+// BlockNpub adds an npub to the blocklist. blockedBy is the admin who blocked it.
+func (db *DB) BlockNpub(ctx context.Context, npub, blockedBy string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO blocked_npubs (npub, blocked_by) VALUES (?, ?)
+	`, npub, blockedBy)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return ErrAlreadyBlocked
+		}
+		return fmt.Errorf("blocking npub: %w", err)
+	}
+	return nil
+}
+
+// UnblockNpub removes an npub from the blocklist.
+func (db *DB) UnblockNpub(ctx context.Context, npub string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM blocked_npubs WHERE npub = ?`, npub)
+	if err != nil {
+		return fmt.Errorf("unblocking npub: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrNotBlocked
+	}
+	return nil
+}
+
+// IsBlocked reports whether an npub is on the blocklist.
+func (db *DB) IsBlocked(ctx context.Context, npub string) (bool, error) {
+	var blocked bool
+	err := db.QueryRowContext(ctx,
+		`SELECT EXISTS(SELECT 1 FROM blocked_npubs WHERE npub = ?)`, npub,
+	).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("checking blocklist: %w", err)
+	}
+	return blocked, nil
+}
+
+// ListBlocked returns all blocklist entries, most recent first.
+func (db *DB) ListBlocked(ctx context.Context) ([]BlockedNpub, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT npub, blocked_by, created_at
+		FROM blocked_npubs ORDER BY created_at DESC, npub
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("querying blocklist: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var blocked []BlockedNpub
+	for rows.Next() {
+		var b BlockedNpub
+		if err := rows.Scan(&b.Npub, &b.BlockedBy, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning blocklist entry: %w", err)
+		}
+		blocked = append(blocked, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating blocklist: %w", err)
+	}
+	return blocked, nil
+}
+
+// ListFAQ returns every FAQ entry, sorted by topic.
+func (db *DB) ListFAQ(ctx context.Context) ([]FAQEntry, error) {
+	rows, err := db.QueryContext(ctx, `SELECT topic, answer, updated_at FROM faq ORDER BY topic`)
+	if err != nil {
+		return nil, fmt.Errorf("querying faq: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var entries []FAQEntry
+	for rows.Next() {
+		var e FAQEntry
+		if err := rows.Scan(&e.Topic, &e.Answer, &e.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scanning faq entry: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating faq: %w", err)
+	}
+	return entries, nil
+}
+
+// GetFAQ returns the answer for a topic. ok is false if there is none.
+func (db *DB) GetFAQ(ctx context.Context, topic string) (answer string, ok bool, err error) {
+	err = db.QueryRowContext(ctx, `SELECT answer FROM faq WHERE topic = ?`, topic).Scan(&answer)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("querying faq topic %s: %w", topic, err)
+	}
+	return answer, true, nil
+}
+
+// SetFAQ creates or replaces the answer for a topic.
+func (db *DB) SetFAQ(ctx context.Context, topic, answer string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO faq (topic, answer) VALUES (?, ?)
+		ON CONFLICT(topic) DO UPDATE SET answer = excluded.answer, updated_at = CURRENT_TIMESTAMP
+	`, topic, answer)
+	if err != nil {
+		return fmt.Errorf("setting faq topic %s: %w", topic, err)
+	}
+	return nil
+}
+
+// DeleteFAQ removes a topic, returning ErrFAQNotFound if it doesn't exist.
+func (db *DB) DeleteFAQ(ctx context.Context, topic string) error {
+	result, err := db.ExecContext(ctx, `DELETE FROM faq WHERE topic = ?`, topic)
+	if err != nil {
+		return fmt.Errorf("deleting faq topic %s: %w", topic, err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("checking rows affected: %w", err)
+	}
+	if rows == 0 {
+		return ErrFAQNotFound
+	}
+	return nil
+}
+
+// AddAdminMessage stores a message from npub to the admins.
+func (db *DB) AddAdminMessage(ctx context.Context, npub, message string) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO messages_to_admin (npub, message) VALUES (?, ?)`, npub, message)
+	if err != nil {
+		return fmt.Errorf("storing admin message: %w", err)
+	}
+	return nil
+}
+
+// CountRecentAdminMessages returns how many messages npub sent the admins
+// within window of now.
+func (db *DB) CountRecentAdminMessages(ctx context.Context, npub string, window time.Duration) (int, error) {
+	var count int
+	err := db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM messages_to_admin
+		WHERE npub = ? AND created_at >= datetime('now', ?)
+	`, npub, fmt.Sprintf("-%d seconds", int(window.Seconds()))).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("counting admin messages: %w", err)
+	}
+	return count, nil
+}
+
+// ListAdminMessages returns the most recent limit messages to the admins,
+// newest first.
+func (db *DB) ListAdminMessages(ctx context.Context, limit int) ([]AdminMessage, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT m.id, m.npub, c.name, m.message, m.created_at
+		FROM messages_to_admin m
+		LEFT JOIN customers c ON c.npub = m.npub
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying admin messages: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var messages []AdminMessage
+	for rows.Next() {
+		var m AdminMessage
+		if err := rows.Scan(&m.ID, &m.Npub, &m.Name, &m.Message, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning admin message: %w", err)
+		}
+		messages = append(messages, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating admin messages: %w", err)
+	}
+	return messages, nil
+}
+
+// NIP05s returns the verified NIP-05 identifiers cached for the given
+// npubs, keyed by npub. Npubs without one are left out.
+func (db *DB) NIP05s(ctx context.Context, npubs []string) (map[string]string, error) {
+	identifiers := make(map[string]string)
+	if len(npubs) == 0 {
+		return identifiers, nil
+	}
+	args := make([]any, len(npubs))
+	for i, npub := range npubs {
+		args[i] = npub
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT npub, nip05 FROM profiles
+		WHERE nip05 != '' AND npub IN (?`+strings.Repeat(", ?", len(npubs)-1)+`)
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("querying profiles: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	for rows.Next() {
+		var npub, nip05 string
+		if err := rows.Scan(&npub, &nip05); err != nil {
+			return nil, fmt.Errorf("scanning profile: %w", err)
+		}
+		identifiers[npub] = nip05
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating profiles: %w", err)
+	}
+	return identifiers, nil
+}
+
+// StaleProfiles returns up to limit customer npubs whose profile was never
+// checked or was last checked more than maxAge ago, least recently checked
+// first.
+func (db *DB) StaleProfiles(ctx context.Context, maxAge time.Duration, limit int) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.npub FROM customers c
+		LEFT JOIN profiles p ON p.npub = c.npub
+		WHERE p.checked_at IS NULL OR p.checked_at < datetime('now', ?)
+		ORDER BY p.checked_at IS NOT NULL, p.checked_at, c.id
+		LIMIT ?
+	`, fmt.Sprintf("-%d seconds", int(maxAge.Seconds())), limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying stale profiles: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var npubs []string
+	for rows.Next() {
+		var npub string
+		if err := rows.Scan(&npub); err != nil {
+			return nil, fmt.Errorf("scanning stale profile: %w", err)
+		}
+		npubs = append(npubs, npub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterating stale profiles: %w", err)
+	}
+	return npubs, nil
+}
+
+// SaveProfile records npub's verified NIP-05 identifier, empty for none, as
+// checked now.
+func (db *DB) SaveProfile(ctx context.Context, npub, nip05 string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO profiles (npub, nip05, checked_at) VALUES (?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT(npub) DO UPDATE SET nip05 = excluded.nip05, checked_at = excluded.checked_at
+	`, npub, nip05)
+	if err != nil {
+		return fmt.Errorf("saving profile: %w", err)
+	}
+	return nil
+}
+
+// TouchProfile marks npub's profile as checked now without changing its
+// cached identifier, for when the check couldn't tell.
+func (db *DB) TouchProfile(ctx context.Context, npub string) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO profiles (npub, checked_at) VALUES (?, CURRENT_TIMESTAMP)
+		ON CONFLICT(npub) DO UPDATE SET checked_at = excluded.checked_at
+	`, npub)
+	if err != nil {
+		return fmt.Errorf("touching profile: %w", err)
+	}
+	return nil
+}
+
+// isUniqueViolation reports whether err is a UNIQUE constraint failure. A
+// duplicate TEXT PRIMARY KEY, as in admins and blocked_npubs, counts too.
+// Other constraint and trigger failures are real errors.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return true
 	}
 	return false
 }
+
+// isForeignKeyViolation reports whether err is a FOREIGN KEY constraint
+// failure, such as a row referring to an admin that doesn't exist.
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY
+}
@@ -3,8 +3,14 @@ package db
 import (
 	"context"
 	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil/bech32"
 	_ "modernc.org/sqlite"
 )
 
@@ -75,6 +81,72 @@ func TestInventory(t *testing.T) {
 	}
 }
 
+func TestDeductEggsWithCheck(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	_ = db.AddEggs(ctx, 20)
+
+	// Each step deducts from what the previous ones left, with a mark of 6
+	tests := []struct {
+		name          string
+		count         int
+		wantRemaining int
+		wantWarning   bool
+	}{
+		{"stays above the mark", 6, 14, false},
+		{"lands on the mark", 8, 6, false},
+		{"drops below the mark", 1, 5, true},
+		{"already below", 3, 2, false},
+		{"runs out", 2, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := db.DeductEggsWithCheck(ctx, tt.count, 6)
+		if err != nil {
+			t.Fatalf("%s: DeductEggsWithCheck: %v", tt.name, err)
+		}
+		if got.Remaining != tt.wantRemaining || got.LowInventoryWarning != tt.wantWarning {
+			t.Errorf("%s: got %+v, want %d remaining, warning %v", tt.name, got, tt.wantRemaining, tt.wantWarning)
+		}
+	}
+
+	if _, err := db.DeductEggsWithCheck(ctx, 1, 6); !errors.Is(err, ErrInsufficientInventory) {
+		t.Errorf("expected ErrInsufficientInventory, got %v", err)
+	}
+
+	// A restock and one deduction straight past the mark warns again; 0 never warns
+	_ = db.AddEggs(ctx, 12)
+	if got, _ := db.DeductEggsWithCheck(ctx, 12, 0); got.LowInventoryWarning {
+		t.Errorf("threshold 0 warned: %+v", got)
+	}
+	_ = db.AddEggs(ctx, 12)
+	if got, _ := db.DeductEggsWithCheck(ctx, 12, 6); !got.LowInventoryWarning || got.Remaining != 0 {
+		t.Errorf("got %+v, want a warning with 0 remaining", got)
+	}
+}
+
+func TestCreateOrderWithCheck(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 12)
+
+	order, deduction, err := db.CreateOrderWithCheck(ctx, c.ID, 6, 3200, 6)
+	if err != nil {
+		t.Fatalf("CreateOrderWithCheck: %v", err)
+	}
+	if order.Quantity != 6 || deduction.Remaining != 6 || deduction.LowInventoryWarning {
+		t.Errorf("order %+v, deduction %+v; want 6 left without a warning", order, deduction)
+	}
+	if _, deduction, _ = db.CreateOrderWithCheck(ctx, c.ID, 6, 3200, 6); !deduction.LowInventoryWarning {
+		t.Errorf("deduction %+v; want a warning", deduction)
+	}
+
+	// A failed order reserves nothing and doesn't warn
+	if _, deduction, err = db.CreateOrderWithCheck(ctx, c.ID, 6, 3200, 6); !errors.Is(err, ErrInsufficientInventory) || deduction.LowInventoryWarning {
+		t.Errorf("got %+v, %v; want ErrInsufficientInventory", deduction, err)
+	}
+}
+
 func TestGetReservedEggs(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
@@ -89,7 +161,7 @@ func TestGetReservedEggs(t *testing.T) {
 	}
 
 	// Create customer and inventory
-	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5")
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
 	_ = db.AddEggs(ctx, 30)
 
 	// Create pending order - should be counted as reserved
@@ -121,6 +193,252 @@ func TestGetReservedEggs(t *testing.T) {
 	}
 }
 
+func TestCountOrdersByStatus(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	counts, err := db.CountOrdersByStatus(ctx)
+	if err != nil {
+		t.Fatalf("CountOrdersByStatus: %v", err)
+	}
+	if len(counts) != 0 {
+		t.Errorf("expected no counts, got %v", counts)
+	}
+
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
+	_ = db.AddEggs(ctx, 30)
+	for range 3 {
+		if _, err := db.CreateOrder(ctx, c.ID, 6, 3200); err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+	}
+	if err := db.UpdateOrderStatus(ctx, 1, "paid"); err != nil {
+		t.Fatalf("UpdateOrderStatus: %v", err)
+	}
+
+	counts, err = db.CountOrdersByStatus(ctx)
+	if err != nil {
+		t.Fatalf("CountOrdersByStatus: %v", err)
+	}
+	if counts["pending"] != 2 || counts["paid"] != 1 || len(counts) != 2 {
+		t.Errorf("expected 2 pending and 1 paid, got %v", counts)
+	}
+}
+
+func TestGetCustomersByOffset(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	npubs := []string{
+		"npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5",
+		"npub1zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygsc4k3ss",
+		"npub1yfzv5z3ffqjmtl6sxwc6rlffvyhyfhf3sk3tpahmh2jnevj9ckusqhsr4f",
+	}
+	for _, npub := range npubs {
+		if _, err := db.CreateCustomer(ctx, npub, ""); err != nil {
+			t.Fatalf("CreateCustomer: %v", err)
+		}
+	}
+
+	page, err := db.GetCustomersByOffset(ctx, 2, 0)
+	if err != nil {
+		t.Fatalf("GetCustomersByOffset: %v", err)
+	}
+	if len(page) != 2 || page[0].Npub != npubs[0] || page[1].Npub != npubs[1] {
+		t.Errorf("first page = %v, want the first two customers", page)
+	}
+
+	page, err = db.GetCustomersByOffset(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("GetCustomersByOffset: %v", err)
+	}
+	if len(page) != 1 || page[0].Npub != npubs[2] {
+		t.Errorf("second page = %v, want the third customer", page)
+	}
+
+	page, err = db.GetCustomersByOffset(ctx, 2, 4)
+	if err != nil {
+		t.Fatalf("GetCustomersByOffset: %v", err)
+	}
+	if len(page) != 0 {
+		t.Errorf("page past the end = %v, want none", page)
+	}
+
+	if n, err := db.CountCustomers(ctx); err != nil || n != 3 {
+		t.Errorf("CountCustomers = %d, %v, want 3", n, err)
+	}
+}
+
+func TestGetCustomerStats(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
+
+	stats, err := db.GetCustomerStats(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("GetCustomerStats: %v", err)
+	}
+	if *stats != (CustomerStats{}) {
+		t.Errorf("expected empty stats, got %+v", stats)
+	}
+
+	_ = db.AddEggs(ctx, 30)
+	delivered, _ := db.CreateOrder(ctx, c.ID, 12, 6400)
+	_, _ = db.CreateOrder(ctx, c.ID, 6, 3200)
+	for _, status := range []string{"paid", "fulfilled"} {
+		if err := db.UpdateOrderStatus(ctx, delivered.ID, status); err != nil {
+			t.Fatalf("UpdateOrderStatus(%s): %v", status, err)
+		}
+	}
+
+	stats, err = db.GetCustomerStats(ctx, c.ID)
+	if err != nil {
+		t.Fatalf("GetCustomerStats: %v", err)
+	}
+	want := CustomerStats{Orders: 2, Pending: 1, Fulfilled: 1, EggsDelivered: 12, SatsSpent: 6400}
+	if *stats != want {
+		t.Errorf("stats = %+v, want %+v", *stats, want)
+	}
+}
+
+func TestCountRecentCancelledOrders(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
+	other, _ := db.CreateCustomer(ctx, "npub1other", "")
+	_ = db.AddEggs(ctx, 60)
+
+	var ids []int64
+	for _, customerID := range []int64{c.ID, c.ID, c.ID, c.ID, other.ID} {
+		order, err := db.CreateOrder(ctx, customerID, 6, 3200)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		ids = append(ids, order.ID)
+	}
+	// Cancelled recently, cancelled long ago, still pending, and someone else's
+	for _, id := range []int64{ids[0], ids[1], ids[4]} {
+		if err := db.CancelOrder(ctx, id); err != nil {
+			t.Fatalf("CancelOrder(%d): %v", id, err)
+		}
+	}
+	longAgo := time.Now().AddDate(0, -2, 0).UTC().Format(sqliteTimeFormat)
+	if _, err := db.Exec(`UPDATE orders SET updated_at = ? WHERE id = ?`, longAgo, ids[1]); err != nil {
+		t.Fatalf("backdating order: %v", err)
+	}
+	// Expired unpaid orders are cancelled too
+	if _, err := db.Exec(`UPDATE orders SET created_at = ? WHERE id = ?`, longAgo, ids[2]); err != nil {
+		t.Fatalf("backdating order: %v", err)
+	}
+	if _, err := db.ExpireOldPendingOrders(ctx, time.Hour); err != nil {
+		t.Fatalf("ExpireOldPendingOrders: %v", err)
+	}
+
+	got, err := db.CountRecentCancelledOrders(ctx, c.ID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		t.Fatalf("CountRecentCancelledOrders: %v", err)
+	}
+	if got != 2 {
+		t.Errorf("CountRecentCancelledOrders = %d, want 2", got)
+	}
+}
+
+func TestGetOrdersWithFilters(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	alice, _ := db.CreateCustomer(ctx, "npub1alice", "")
+	bob, _ := db.CreateCustomer(ctx, "npub1bob", "")
+	_ = db.AddEggs(ctx, 100)
+
+	day := func(d int) time.Time { return time.Date(2025, 7, d, 12, 0, 0, 0, time.UTC) }
+	seed := []struct {
+		customerID int64
+		quantity   int
+		sats       int64
+		status     string
+		createdAt  time.Time
+	}{
+		{alice.ID, 6, 3200, "pending", day(1)},
+		{alice.ID, 12, 6400, "paid", day(2)},
+		{bob.ID, 6, 3200, "paid", day(3)},
+		{bob.ID, 12, 6400, "pending", day(4)},
+		{alice.ID, 12, 6400, "paid", day(5)},
+	}
+	ids := make([]int64, len(seed))
+	for i, o := range seed {
+		order, err := db.CreateOrder(ctx, o.customerID, o.quantity, o.sats)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if o.status != "pending" {
+			if err := db.UpdateOrderStatus(ctx, order.ID, o.status); err != nil {
+				t.Fatalf("UpdateOrderStatus: %v", err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE orders SET created_at = ? WHERE id = ?`,
+			o.createdAt.Format("2006-01-02 15:04:05"), order.ID); err != nil {
+			t.Fatalf("backdating order: %v", err)
+		}
+		ids[i] = order.ID
+	}
+
+	ptr := func(v int64) *int64 { return &v }
+	from, to := day(2), day(5)
+	injection := "paid' OR '1'='1"
+
+	tests := []struct {
+		name   string
+		filter OrderFilter
+		want   []int64 // Most recent first
+	}{
+		{"no filter", OrderFilter{}, []int64{ids[4], ids[3], ids[2], ids[1], ids[0]}},
+		{"status only", OrderFilter{Status: "paid"}, []int64{ids[4], ids[2], ids[1]}},
+		{"customer and status", OrderFilter{Status: "paid", CustomerID: &alice.ID}, []int64{ids[4], ids[1]}},
+		{"date range, end exclusive", OrderFilter{FromTime: &from, ToTime: &to}, []int64{ids[3], ids[2], ids[1]}},
+		{"minimum sats", OrderFilter{MinSats: ptr(6400)}, []int64{ids[4], ids[3], ids[1]}},
+		{"combined", OrderFilter{Status: "pending", CustomerID: &bob.ID, FromTime: &from, MinSats: ptr(6400)}, []int64{ids[3]}},
+		{"nothing matches", OrderFilter{Status: "fulfilled"}, nil},
+		{"values are bound, not spliced", OrderFilter{Status: injection}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orders, err := db.GetOrdersWithFilters(ctx, tt.filter, 50)
+			if err != nil {
+				t.Fatalf("GetOrdersWithFilters: %v", err)
+			}
+			var got []int64
+			for _, o := range orders {
+				got = append(got, o.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got orders %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The limit applies after filtering
+	orders, _ := db.GetOrdersWithFilters(ctx, OrderFilter{Status: "paid"}, 2)
+	if len(orders) != 2 || orders[0].ID != ids[4] || orders[0].CustomerNpub != "npub1alice" {
+		t.Errorf("limited result = %+v", orders)
+	}
+
+	// Counts and offsets use the same filter
+	if n, err := db.CountOrdersWithFilters(ctx, OrderFilter{Status: "paid"}); err != nil || n != 3 {
+		t.Errorf("CountOrdersWithFilters(paid) = %d, %v, want 3", n, err)
+	}
+	if n, _ := db.CountOrdersWithFilters(ctx, OrderFilter{CustomerID: &bob.ID, MinSats: ptr(6400)}); n != 1 {
+		t.Errorf("CountOrdersWithFilters(bob, 6400+) = %d, want 1", n)
+	}
+	page, err := db.GetOrdersPage(ctx, OrderFilter{Status: "paid"}, 2, 2)
+	if err != nil {
+		t.Fatalf("GetOrdersPage: %v", err)
+	}
+	if len(page) != 1 || page[0].ID != ids[1] {
+		t.Errorf("second page of paid orders = %+v, want order %d", page, ids[1])
+	}
+}
+
 func TestGetSoldEggs(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
@@ -135,7 +453,7 @@ func TestGetSoldEggs(t *testing.T) {
 	}
 
 	// Create customer and inventory
-	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5")
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
 	_ = db.AddEggs(ctx, 30)
 
 	// Create and pay order - should be counted as sold
@@ -188,7 +506,7 @@ func TestCustomerCRUD(t *testing.T) {
 	}
 
 	// Create customer
-	c, err := db.CreateCustomer(ctx, npub)
+	c, err := db.CreateCustomer(ctx, npub, "")
 	if err != nil {
 		t.Fatalf("CreateCustomer: %v", err)
 	}
@@ -197,7 +515,7 @@ func TestCustomerCRUD(t *testing.T) {
 	}
 
 	// Create duplicate should fail
-	_, err = db.CreateCustomer(ctx, npub)
+	_, err = db.CreateCustomer(ctx, npub, "")
 	if err != ErrCustomerExists {
 		t.Errorf("expected ErrCustomerExists, got %v", err)
 	}
@@ -220,6 +538,39 @@ func TestCustomerCRUD(t *testing.T) {
 		t.Errorf("expected 1 customer, got %d", len(customers))
 	}
 
+	// Set and clear name
+	if err := db.UpdateCustomerName(ctx, npub, "Jane from the co-op"); err != nil {
+		t.Fatalf("UpdateCustomerName: %v", err)
+	}
+	c, _ = db.GetCustomerByNpub(ctx, npub)
+	if !c.Name.Valid || c.Name.String != "Jane from the co-op" {
+		t.Errorf("expected name %q, got %+v", "Jane from the co-op", c.Name)
+	}
+	if err := db.UpdateCustomerName(ctx, npub, ""); err != nil {
+		t.Fatalf("UpdateCustomerName clear: %v", err)
+	}
+	c, _ = db.GetCustomerByNpub(ctx, npub)
+	if c.Name.Valid {
+		t.Errorf("expected name cleared, got %q", c.Name.String)
+	}
+
+	// Set and clear language
+	if lang, err := db.GetCustomerLang(ctx, npub); err != nil || lang != "" {
+		t.Errorf("GetCustomerLang = %q, %v; want no language", lang, err)
+	}
+	if err := db.UpdateCustomerLang(ctx, npub, "de"); err != nil {
+		t.Fatalf("UpdateCustomerLang: %v", err)
+	}
+	if lang, _ := db.GetCustomerLang(ctx, npub); lang != "de" {
+		t.Errorf("expected language de, got %q", lang)
+	}
+	if err := db.UpdateCustomerLang(ctx, npub, ""); err != nil {
+		t.Fatalf("UpdateCustomerLang clear: %v", err)
+	}
+	if lang, _ := db.GetCustomerLang(ctx, npub); lang != "" {
+		t.Errorf("expected language cleared, got %q", lang)
+	}
+
 	// Remove customer
 	if err := db.RemoveCustomer(ctx, npub); err != nil {
 		t.Fatalf("RemoveCustomer: %v", err)
@@ -230,6 +581,18 @@ func TestCustomerCRUD(t *testing.T) {
 	if err != ErrCustomerNotFound {
 		t.Errorf("expected ErrCustomerNotFound, got %v", err)
 	}
+
+	// Naming a non-existent customer should fail
+	err = db.UpdateCustomerName(ctx, npub, "Ghost")
+	if err != ErrCustomerNotFound {
+		t.Errorf("expected ErrCustomerNotFound, got %v", err)
+	}
+	if err := db.UpdateCustomerLang(ctx, npub, "es"); err != ErrCustomerNotFound {
+		t.Errorf("expected ErrCustomerNotFound, got %v", err)
+	}
+	if _, err := db.GetCustomerLang(ctx, npub); err != ErrCustomerNotFound {
+		t.Errorf("expected ErrCustomerNotFound, got %v", err)
+	}
 }
 
 func TestOrderOperations(t *testing.T) {
@@ -238,7 +601,7 @@ func TestOrderOperations(t *testing.T) {
 
 	// Create customer
 	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
-	c, err := db.CreateCustomer(ctx, npub)
+	c, err := db.CreateCustomer(ctx, npub, "")
 	if err != nil {
 		t.Fatalf("CreateCustomer: %v", err)
 	}
@@ -263,11 +626,15 @@ func TestOrderOperations(t *testing.T) {
 	if err != nil {
 		t.Fatalf("GetOrderByID: %v", err)
 	}
+	// Timestamps are stored and read back in UTC; display code converts them
+	if order.CreatedAt.Location() != time.UTC {
+		t.Errorf("CreatedAt location = %s, want UTC", order.CreatedAt.Location())
+	}
 
 	// Get customer orders
-	orders, err := db.GetCustomerOrders(ctx, c.ID, 10)
+	orders, err := db.GetCustomerOrdersByOffset(ctx, c.ID, 10, 0)
 	if err != nil {
-		t.Fatalf("GetCustomerOrders: %v", err)
+		t.Fatalf("GetCustomerOrdersByOffset: %v", err)
 	}
 	if len(orders) != 1 {
 		t.Errorf("expected 1 order, got %d", len(orders))
@@ -293,12 +660,65 @@ func TestOrderOperations(t *testing.T) {
 	}
 }
 
+func TestGetCustomerOrdersByOffset(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5", "")
+	other, _ := db.CreateCustomer(ctx, "npub1other", "")
+	_ = db.AddEggs(ctx, 100)
+	var ids []int64
+	for range 7 {
+		o, err := db.CreateOrder(ctx, c.ID, 6, 3200)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		ids = append(ids, o.ID)
+		// Interleave another customer's orders, which must never show up
+		_, _ = db.CreateOrder(ctx, other.ID, 6, 3200)
+	}
+
+	// Walk pages of 3 from the newest: 3, 3, 1, then nothing
+	seen := map[int64]bool{}
+	var sizes []int
+	var prev int64
+	for offset := 0; ; offset += 3 {
+		page, err := db.GetCustomerOrdersByOffset(ctx, c.ID, 3, offset)
+		if err != nil {
+			t.Fatalf("GetCustomerOrdersByOffset: %v", err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		sizes = append(sizes, len(page))
+		for _, o := range page {
+			if o.CustomerID != c.ID {
+				t.Errorf("order %d belongs to customer %d", o.ID, o.CustomerID)
+			}
+			if seen[o.ID] {
+				t.Errorf("order %d returned on more than one page", o.ID)
+			}
+			seen[o.ID] = true
+			if prev != 0 && o.ID >= prev {
+				t.Errorf("pages not newest first: %d after %d", o.ID, prev)
+			}
+			prev = o.ID
+		}
+	}
+	if len(sizes) != 3 || sizes[0] != 3 || sizes[1] != 3 || sizes[2] != 1 {
+		t.Errorf("page sizes = %v, want [3 3 1]", sizes)
+	}
+	if len(seen) != len(ids) {
+		t.Errorf("saw %d orders across pages, want %d", len(seen), len(ids))
+	}
+}
+
 func TestFulfillOrder(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
 
 	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
-	c, _ := db.CreateCustomer(ctx, npub)
+	c, _ := db.CreateCustomer(ctx, npub, "")
 
 	_ = db.AddEggs(ctx, 10)
 
@@ -335,6 +755,14 @@ func TestFulfillOrder(t *testing.T) {
 		t.Errorf("expected status fulfilled, got %s", order.Status)
 	}
 
+	var paidAt, fulfilledAt sql.NullTime
+	if err := db.QueryRowContext(ctx, `SELECT paid_at, fulfilled_at FROM orders WHERE id = ?`, order.ID).Scan(&paidAt, &fulfilledAt); err != nil {
+		t.Fatalf("reading order times: %v", err)
+	}
+	if !paidAt.Valid || !fulfilledAt.Valid {
+		t.Errorf("paid_at %v and fulfilled_at %v should both be set", paidAt, fulfilledAt)
+	}
+
 	// Fulfill again should fail
 	err = db.FulfillOrder(ctx, order.ID)
 	if err == nil {
@@ -347,7 +775,7 @@ func TestCreateOrder_ReservesInventory(t *testing.T) {
 	db := setupTestDB(t)
 
 	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
-	c, _ := db.CreateCustomer(ctx, npub)
+	c, _ := db.CreateCustomer(ctx, npub, "")
 
 	// No inventory - order should fail
 	_, err := db.CreateOrder(ctx, c.ID, 6, 3200)
@@ -386,7 +814,7 @@ func TestTransactionsAndBalance(t *testing.T) {
 	db := setupTestDB(t)
 
 	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
-	c, _ := db.CreateCustomer(ctx, npub)
+	c, _ := db.CreateCustomer(ctx, npub, "")
 
 	// Initial balance should be 0
 	balance, err := db.GetCustomerBalance(ctx, npub)
@@ -398,7 +826,7 @@ func TestTransactionsAndBalance(t *testing.T) {
 	}
 
 	// Record transaction
-	tx, err := db.RecordTransaction(ctx, nil, "zap1", 5000, npub)
+	tx, err := db.RecordTransaction(ctx, nil, "zap1", TransactionSourceZap, 5000, npub)
 	if err != nil {
 		t.Fatalf("RecordTransaction: %v", err)
 	}
@@ -429,6 +857,119 @@ func TestTransactionsAndBalance(t *testing.T) {
 	}
 }
 
+func TestGetCustomerBalanceDetail(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
+	if _, err := db.GetCustomerBalanceDetail(ctx, npub); !errors.Is(err, ErrCustomerNotFound) {
+		t.Fatalf("unknown customer: err = %v, want ErrCustomerNotFound", err)
+	}
+
+	c, _ := db.CreateCustomer(ctx, npub, "")
+	other, _ := db.CreateCustomer(ctx, "npub1other", "")
+	_ = db.AddEggs(ctx, 30)
+
+	_, _ = db.RecordTransaction(ctx, nil, "zap1", TransactionSourceZap, 5000, npub)
+	_, _ = db.RecordTransaction(ctx, nil, "zap2", TransactionSourceZap, 9000, "npub1other")
+	fulfilled, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	_ = db.UpdateOrderStatus(ctx, fulfilled.ID, "paid")
+	_ = db.FulfillOrder(ctx, fulfilled.ID)
+	_, _ = db.CreateOrder(ctx, c.ID, 6, 3200)
+	_, _ = db.CreateOrder(ctx, c.ID, 2, 1100)
+	cancelled, _ := db.CreateOrder(ctx, c.ID, 2, 1100)
+	_ = db.UpdateOrderStatus(ctx, cancelled.ID, "cancelled")
+	_, _ = db.CreateOrder(ctx, other.ID, 6, 3200)
+
+	got, err := db.GetCustomerBalanceDetail(ctx, npub)
+	if err != nil {
+		t.Fatalf("GetCustomerBalanceDetail: %v", err)
+	}
+	want := BalanceDetail{Received: 5000, Spent: 3200, Balance: 1800, PendingReserved: 4300}
+	if *got != want {
+		t.Errorf("GetCustomerBalanceDetail = %+v, want %+v", *got, want)
+	}
+}
+
+func TestTransactionSource_Migrated(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	if _, err := db.MigrateDownTo(ctx, 17); err != nil {
+		t.Fatalf("MigrateDownTo(17): %v", err)
+	}
+	zapID := "A1B2C3D4E5F60718293A4B5C6D7E8F90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	for _, id := range []string{zapID, "adjust-500", "simulated-1-1"} {
+		if _, err := db.Exec(`INSERT INTO transactions (zap_event_id, amount_sats, sender_npub) VALUES (?, 100, 'npub1alice')`, id); err != nil {
+			t.Fatalf("inserting %s: %v", id, err)
+		}
+	}
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+
+	all, err := db.GetTransactions(ctx, "", 10, 0)
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if got := []string{all[0].Source, all[1].Source, all[2].Source}; !slices.Equal(got, []string{
+		TransactionSourceSynthetic, TransactionSourceAdjustment, TransactionSourceZap,
+	}) {
+		t.Errorf("migrated sources = %v", got)
+	}
+}
+
+func TestGetTransactions(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1alice", "")
+	_ = db.AddEggs(ctx, 12)
+	order, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+
+	zapID := "a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90"
+	_, _ = db.RecordTransaction(ctx, &order.ID, zapID, TransactionSourceZap, 3200, "npub1alice")
+	_, _ = db.RecordTransaction(ctx, nil, "adjust-500", TransactionSourceAdjustment, 500, "npub1alice")
+	_, _ = db.RecordTransaction(ctx, nil, "simulated-1-1", TransactionSourceSynthetic, 1000, "npub1bob")
+
+	all, err := db.GetTransactions(ctx, "", 10, 0)
+	if err != nil {
+		t.Fatalf("GetTransactions: %v", err)
+	}
+	if len(all) != 3 || all[0].ZapEventID != "simulated-1-1" || all[2].ZapEventID != zapID {
+		t.Fatalf("expected all three, most recent first, got %+v", all)
+	}
+	if got := []string{all[0].Source, all[1].Source, all[2].Source}; !slices.Equal(got, []string{
+		TransactionSourceSynthetic, TransactionSourceAdjustment, TransactionSourceZap,
+	}) {
+		t.Errorf("sources = %v", got)
+	}
+
+	// The linked order is joined in
+	if !all[2].OrderID.Valid || all[2].OrderID.Int64 != order.ID || all[2].OrderQuantity.Int64 != 6 || all[2].OrderStatus.String != "pending" {
+		t.Errorf("zap entry order = %+v", all[2])
+	}
+	if all[1].OrderID.Valid || all[1].OrderStatus.Valid {
+		t.Errorf("adjustment should have no order: %+v", all[1])
+	}
+
+	alice, _ := db.GetTransactions(ctx, "npub1alice", 10, 0)
+	if len(alice) != 2 {
+		t.Errorf("expected 2 transactions from alice, got %d", len(alice))
+	}
+	page, _ := db.GetTransactions(ctx, "", 1, 1)
+	if len(page) != 1 || page[0].ZapEventID != "adjust-500" {
+		t.Errorf("offset page = %+v", page)
+	}
+}
+
 func TestOrderNotFound(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
@@ -449,13 +990,70 @@ func TestOrderNotFound(t *testing.T) {
 	}
 }
 
+func TestUpdateOrderQuantity(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		newQuantity   int
+		newSats       int64
+		wantErr       error
+		wantQuantity  int
+		wantSats      int64
+		wantAvailable int
+	}{
+		// 20 eggs in stock, 6 reserved by the order
+		{"increase", 12, 6400, nil, 12, 6400, 8},
+		{"increase using every egg", 20, 10600, nil, 20, 10600, 0},
+		{"decrease", 3, 1600, nil, 3, 1600, 17},
+		{"insufficient inventory", 21, 11200, ErrInsufficientInventory, 6, 3200, 14},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := setupTestDB(t)
+			c, _ := db.CreateCustomer(ctx, "npub1test", "")
+			_ = db.AddEggs(ctx, 20)
+			order, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+
+			err := db.UpdateOrderQuantity(ctx, order.ID, tt.newQuantity, tt.newSats)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateOrderQuantity = %v, want %v", err, tt.wantErr)
+			}
+			got, _ := db.GetOrderByID(ctx, order.ID)
+			if got.Quantity != tt.wantQuantity || got.TotalSats != tt.wantSats {
+				t.Errorf("order = %d eggs for %d sats, want %d for %d", got.Quantity, got.TotalSats, tt.wantQuantity, tt.wantSats)
+			}
+			if available, _ := db.GetInventory(ctx); available != tt.wantAvailable {
+				t.Errorf("available = %d, want %d", available, tt.wantAvailable)
+			}
+		})
+	}
+
+	t.Run("not pending", func(t *testing.T) {
+		db := setupTestDB(t)
+		c, _ := db.CreateCustomer(ctx, "npub1test", "")
+		_ = db.AddEggs(ctx, 20)
+		order, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+		_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		if err := db.UpdateOrderQuantity(ctx, order.ID, 12, 6400); !errors.Is(err, ErrOrderNotPending) {
+			t.Errorf("expected ErrOrderNotPending, got %v", err)
+		}
+		if err := db.UpdateOrderQuantity(ctx, 999, 12, 6400); !errors.Is(err, ErrOrderNotFound) {
+			t.Errorf("expected ErrOrderNotFound, got %v", err)
+		}
+		if err := db.UpdateOrderQuantity(ctx, order.ID, 0, 0); err == nil {
+			t.Error("expected an error for a zero quantity")
+		}
+	})
+}
+
 func TestCancelOrder(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
 
 	// Create customer
 	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
-	c, _ := db.CreateCustomer(ctx, npub)
+	c, _ := db.CreateCustomer(ctx, npub, "")
 
 	// Add inventory (required for reservation model)
 	_ = db.AddEggs(ctx, 30)
@@ -517,59 +1115,1063 @@ func TestCancelOrder(t *testing.T) {
 	}
 }
 
-func TestGetTotalSales(t *testing.T) {
+func TestExpireOldPendingOrders(t *testing.T) {
 	ctx := context.Background()
 	db := setupTestDB(t)
 
-	// No orders - should return 0
-	total, err := db.GetTotalSales(ctx)
+	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
+	c, _ := db.CreateCustomer(ctx, npub, "")
+	_ = db.AddEggs(ctx, 30)
+
+	stale, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	fresh, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	stalePaid, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	_ = db.UpdateOrderStatus(ctx, stalePaid.ID, "paid")
+
+	// Backdate the stale orders past the expiry window
+	_, err := db.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', '-3 hours') WHERE id IN (?, ?)`,
+		stale.ID, stalePaid.ID)
 	if err != nil {
-		t.Fatalf("GetTotalSales: %v", err)
+		t.Fatalf("backdating orders: %v", err)
 	}
-	if total != 0 {
-		t.Errorf("expected 0 with no orders, got %d", total)
+
+	expired, err := db.ExpireOldPendingOrders(ctx, 120*time.Minute)
+	if err != nil {
+		t.Fatalf("ExpireOldPendingOrders: %v", err)
+	}
+	if len(expired) != 1 || expired[0].ID != stale.ID {
+		t.Fatalf("expected only order %d expired, got %+v", stale.ID, expired)
 	}
 
-	// Create customer and inventory
-	c, _ := db.CreateCustomer(ctx, "npub1test")
+	got, _ := db.GetOrderByID(ctx, stale.ID)
+	if got.Status != "cancelled" {
+		t.Errorf("stale order status = %s, want cancelled", got.Status)
+	}
+	got, _ = db.GetOrderByID(ctx, fresh.ID)
+	if got.Status != "pending" {
+		t.Errorf("fresh order status = %s, want pending", got.Status)
+	}
+	got, _ = db.GetOrderByID(ctx, stalePaid.ID)
+	if got.Status != "paid" {
+		t.Errorf("paid order status = %s, want paid", got.Status)
+	}
+
+	// Stale order's 6 eggs are back: 30 - 18 + 6
+	count, _ := db.GetInventory(ctx)
+	if count != 18 {
+		t.Errorf("expected 18 eggs after expiry, got %d", count)
+	}
+
+	// Nothing left to expire
+	expired, err = db.ExpireOldPendingOrders(ctx, 120*time.Minute)
+	if err != nil {
+		t.Fatalf("ExpireOldPendingOrders (second run): %v", err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("expected no orders expired on second run, got %d", len(expired))
+	}
+}
+
+func TestGetRevenueByPeriod(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
 	_ = db.AddEggs(ctx, 100)
+	placeAt := func(createdAt, status string, sats int64) {
+		t.Helper()
+		order, err := db.CreateOrder(ctx, c.ID, 6, sats)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		switch status {
+		case "paid":
+			_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		case "fulfilled":
+			_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+			if err := db.FulfillOrder(ctx, order.ID); err != nil {
+				t.Fatalf("FulfillOrder: %v", err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE orders SET created_at = ? WHERE id = ?`, createdAt, order.ID); err != nil {
+			t.Fatalf("backdating order: %v", err)
+		}
+	}
+
+	// Sunday night, Monday morning and the last moments of June around week and month boundaries
+	placeAt("2025-06-29 23:59:59", "fulfilled", 100)
+	placeAt("2025-06-30 00:00:00", "fulfilled", 200)
+	placeAt("2025-06-30 23:59:59", "fulfilled", 400)
+	placeAt("2025-07-01 00:00:00", "fulfilled", 800)
+	// Orders that aren't fulfilled don't count
+	placeAt("2025-07-01 12:00:00", "pending", 1000)
+	placeAt("2025-07-01 12:00:00", "paid", 2000)
+
+	tests := []struct {
+		period RevenuePeriod
+		want   []PeriodRevenue
+	}{
+		{PeriodDaily, []PeriodRevenue{
+			{"2025-07-01", 1, 800},
+			{"2025-06-30", 2, 600},
+			{"2025-06-29", 1, 100},
+		}},
+		{PeriodWeekly, []PeriodRevenue{
+			{"2025-06-30", 3, 1400},
+			{"2025-06-23", 1, 100},
+		}},
+		{PeriodMonthly, []PeriodRevenue{
+			{"2025-07", 1, 800},
+			{"2025-06", 3, 700},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.period), func(t *testing.T) {
+			got, err := db.GetRevenueByPeriod(ctx, tt.period, 10)
+			if err != nil {
+				t.Fatalf("GetRevenueByPeriod: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	// The limit keeps the most recent periods
+	got, _ := db.GetRevenueByPeriod(ctx, PeriodDaily, 1)
+	if len(got) != 1 || got[0].Period != "2025-07-01" {
+		t.Errorf("limited result = %+v", got)
+	}
+
+	if _, err := db.GetRevenueByPeriod(ctx, "yearly", 10); !errors.Is(err, ErrInvalidPeriod) {
+		t.Errorf("expected ErrInvalidPeriod, got %v", err)
+	}
+}
 
-	// Create pending order - should not count
-	_, _ = db.CreateOrder(ctx, c.ID, 6, 3200)
-	total, _ = db.GetTotalSales(ctx)
-	if total != 0 {
-		t.Errorf("expected 0 with pending order only, got %d", total)
+func TestGetSalesByDateRange(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 100)
+	placeAt := func(createdAt, status string, sats int64) {
+		t.Helper()
+		order, err := db.CreateOrder(ctx, c.ID, 6, sats)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		if status == "fulfilled" {
+			if err := db.FulfillOrder(ctx, order.ID); err != nil {
+				t.Fatalf("FulfillOrder: %v", err)
+			}
+		}
+		if _, err := db.ExecContext(ctx, `UPDATE orders SET created_at = ? WHERE id = ?`, createdAt, order.ID); err != nil {
+			t.Fatalf("backdating order: %v", err)
+		}
+	}
+
+	placeAt("2025-06-30 23:59:59", "fulfilled", 100)
+	placeAt("2025-07-01 00:00:00", "fulfilled", 200)
+	placeAt("2025-07-01 23:59:59", "fulfilled", 400)
+	placeAt("2025-07-02 00:00:00", "fulfilled", 800)
+	placeAt("2025-07-01 12:00:00", "paid", 1000)
+
+	date := func(day int) time.Time { return time.Date(2025, 7, day, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     int64
+	}{
+		{"one day, start included and end excluded", date(1), date(2), 600},
+		{"several days", date(1), date(3), 1400},
+		{"no orders", date(3), date(10), 0},
+		// Ranges in other zones compare in UTC, as orders are stored
+		{"zoned", time.Date(2025, 7, 2, 0, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), date(3), 800 + 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := db.GetSalesByDateRange(ctx, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("GetSalesByDateRange: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
 	}
+}
 
-	// Create paid order - should not count
-	order2, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
-	_ = db.UpdateOrderStatus(ctx, order2.ID, "paid")
-	total, _ = db.GetTotalSales(ctx)
-	if total != 0 {
-		t.Errorf("expected 0 with paid order only, got %d", total)
+func TestArchiveOldFulfilledOrders(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 100)
+	place := func(status string, daysAgo int) int64 {
+		t.Helper()
+		order, err := db.CreateOrder(ctx, c.ID, 6, 3200)
+		if err != nil {
+			t.Fatalf("CreateOrder: %v", err)
+		}
+		if status != "pending" {
+			_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		}
+		if status == "fulfilled" {
+			_ = db.FulfillOrder(ctx, order.ID)
+		}
+		_, err = db.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', ?) WHERE id = ?`,
+			fmt.Sprintf("-%d days", daysAgo), order.ID)
+		if err != nil {
+			t.Fatalf("backdating order: %v", err)
+		}
+		return order.ID
+	}
+
+	old := place("fulfilled", 100)
+	bolt11, _ := testBolt11(t, 1)
+	if _, err := db.RecordInvoice(ctx, old, bolt11, 3200, time.Now()); err != nil {
+		t.Fatalf("RecordInvoice: %v", err)
+	}
+	linked := place("fulfilled", 100)
+	_, _ = db.RecordTransaction(ctx, &linked, "adjust-3200", TransactionSourceAdjustment, 3200, "npub1test")
+	recent := place("fulfilled", 10)
+	unfulfilled := place("paid", 100)
+
+	spentBefore, _ := db.GetCustomerSpent(ctx, c.ID)
+	salesBefore, _ := db.GetTotalSales(ctx)
+
+	moved, err := db.ArchiveOldFulfilledOrders(ctx, 90)
+	if err != nil {
+		t.Fatalf("ArchiveOldFulfilledOrders: %v", err)
+	}
+	if moved != 1 {
+		t.Fatalf("moved %d orders, want 1", moved)
 	}
 
-	// Fulfill the paid order - now it should count
-	_ = db.FulfillOrder(ctx, order2.ID)
-	total, _ = db.GetTotalSales(ctx)
-	if total != 3200 {
-		t.Errorf("expected 3200 after fulfillment, got %d", total)
+	if _, err := db.GetOrderByID(ctx, old); !errors.Is(err, ErrOrderNotFound) {
+		t.Errorf("archived order still in orders: %v", err)
+	}
+	for _, id := range []int64{linked, recent, unfulfilled} {
+		if _, err := db.GetOrderByID(ctx, id); err != nil {
+			t.Errorf("order %d should stay: %v", id, err)
+		}
+	}
+	archived, err := db.GetArchivedOrders(ctx, c.ID, 10)
+	if err != nil {
+		t.Fatalf("GetArchivedOrders: %v", err)
+	}
+	if len(archived) != 1 || archived[0].ID != old || archived[0].Status != "fulfilled" || archived[0].TotalSats != 3200 {
+		t.Errorf("archived = %+v", archived)
+	}
+	var invoices int
+	_ = db.QueryRowContext(ctx, `SELECT COUNT(*) FROM invoices WHERE order_id = ?`, old).Scan(&invoices)
+	if invoices != 0 {
+		t.Errorf("archived order's invoice not deleted")
 	}
 
-	// Add another fulfilled order
-	order3, _ := db.CreateOrder(ctx, c.ID, 12, 6400)
-	_ = db.UpdateOrderStatus(ctx, order3.ID, "paid")
-	_ = db.FulfillOrder(ctx, order3.ID)
-	total, _ = db.GetTotalSales(ctx)
-	if total != 9600 {
-		t.Errorf("expected 9600 (3200+6400), got %d", total)
+	// Totals still count archived orders
+	if spent, _ := db.GetCustomerSpent(ctx, c.ID); spent != spentBefore {
+		t.Errorf("spent = %d after archiving, want %d", spent, spentBefore)
+	}
+	if sales, _ := db.GetTotalSales(ctx); sales != salesBefore {
+		t.Errorf("sales = %d after archiving, want %d", sales, salesBefore)
+	}
+	if stats, _ := db.GetCustomerStats(ctx, c.ID); stats.Fulfilled != 3 || stats.Orders != 4 {
+		t.Errorf("stats = %+v, want 4 orders, 3 fulfilled", stats)
 	}
 
-	// Cancelled orders should not count
-	order4, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
-	_ = db.CancelOrder(ctx, order4.ID)
+	if moved, err := db.ArchiveOldFulfilledOrders(ctx, 90); err != nil || moved != 0 {
+		t.Errorf("second run moved %d, %v; want 0", moved, err)
+	}
+}
+
+func TestArchiveOldFulfilledOrders_RollsBack(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 12)
+	var ids []int64
+	for range 2 {
+		order, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+		_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		_ = db.FulfillOrder(ctx, order.ID)
+		ids = append(ids, order.ID)
+	}
+	_, _ = db.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', '-100 days')`)
+
+	// An archive row with the second order's ID makes the copy fail partway
+	if _, err := db.ExecContext(ctx, `INSERT INTO orders_archive (id, customer_id, quantity, total_sats, status) VALUES (?, ?, 1, 1, 'fulfilled')`,
+		ids[1], c.ID); err != nil {
+		t.Fatalf("seeding archive: %v", err)
+	}
+	if _, err := db.ArchiveOldFulfilledOrders(ctx, 90); err == nil {
+		t.Fatal("expected the move to fail")
+	}
+	for _, id := range ids {
+		if _, err := db.GetOrderByID(ctx, id); err != nil {
+			t.Errorf("order %d lost after a failed move: %v", id, err)
+		}
+	}
+	if archived, _ := db.GetArchivedOrders(ctx, c.ID, 10); len(archived) != 1 {
+		t.Errorf("archive has %d rows after a failed move, want the 1 seeded", len(archived))
+	}
+
+	// Nothing is left behind to break the next run
+	_, _ = db.ExecContext(ctx, `DELETE FROM orders_archive`)
+	if moved, err := db.ArchiveOldFulfilledOrders(ctx, 90); err != nil || moved != 2 {
+		t.Errorf("retry moved %d, %v; want 2", moved, err)
+	}
+}
+
+// placeOrderFor creates an order, moves it to status and backdates it.
+func placeOrderFor(t *testing.T, db *DB, customerID int64, quantity int, sats int64, status string, daysAgo int) int64 {
+	t.Helper()
+	ctx := context.Background()
+	order, err := db.CreateOrder(ctx, customerID, quantity, sats)
+	if err != nil {
+		t.Fatalf("CreateOrder: %v", err)
+	}
+	switch status {
+	case "paid":
+		_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+	case "fulfilled":
+		_ = db.UpdateOrderStatus(ctx, order.ID, "paid")
+		_ = db.FulfillOrder(ctx, order.ID)
+	case "cancelled":
+		_ = db.CancelOrder(ctx, order.ID)
+	}
+	if _, err := db.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', ?) WHERE id = ?`,
+		fmt.Sprintf("-%d days", daysAgo), order.ID); err != nil {
+		t.Fatalf("backdating order: %v", err)
+	}
+	return order.ID
+}
+
+func TestGetTopCustomers(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	alice, _ := db.CreateCustomer(ctx, "npub1alice", "")
+	bob, _ := db.CreateCustomer(ctx, "npub1bob", "")
+	carol, _ := db.CreateCustomer(ctx, "npub1carol", "")
+	_ = db.UpdateCustomerName(ctx, "npub1bob", "Bob")
+	_ = db.AddEggs(ctx, 100)
+
+	placeOrderFor(t, db, alice.ID, 6, 3200, "fulfilled", 5)
+	placeOrderFor(t, db, alice.ID, 6, 3200, "fulfilled", 60)
+	placeOrderFor(t, db, bob.ID, 12, 6400, "fulfilled", 2)
+	placeOrderFor(t, db, bob.ID, 12, 6400, "paid", 2) // Not fulfilled, not counted
+	placeOrderFor(t, db, carol.ID, 6, 3200, "cancelled", 1)
+
+	all, err := db.GetTopCustomers(ctx, 0, 10)
+	if err != nil {
+		t.Fatalf("GetTopCustomers: %v", err)
+	}
+	// Alice and Bob tie on sats and eggs, so the earlier customer ranks first
+	want := []CustomerRanking{
+		{Npub: "npub1alice", Orders: 2, Eggs: 12, Sats: 6400},
+		{Npub: "npub1bob", Name: sql.NullString{String: "Bob", Valid: true}, Orders: 1, Eggs: 12, Sats: 6400},
+	}
+	if !slices.Equal(all, want) {
+		t.Errorf("all time = %+v, want %+v", all, want)
+	}
+
+	recent, _ := db.GetTopCustomers(ctx, 30, 10)
+	if len(recent) != 2 || recent[0].Npub != "npub1bob" || recent[1].Sats != 3200 {
+		t.Errorf("last 30 days = %+v, want bob then alice with 3200", recent)
+	}
+	if top, _ := db.GetTopCustomers(ctx, 0, 1); len(top) != 1 {
+		t.Errorf("limit 1 returned %d", len(top))
+	}
+}
+
+func TestGetOrderFunnel(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 100)
+	placeOrderFor(t, db, c.ID, 6, 3200, "pending", 1)
+	placeOrderFor(t, db, c.ID, 6, 3200, "paid", 2)
+	placeOrderFor(t, db, c.ID, 6, 3200, "fulfilled", 3)
+	placeOrderFor(t, db, c.ID, 6, 3200, "fulfilled", 4)
+	placeOrderFor(t, db, c.ID, 6, 3200, "cancelled", 5)
+	placeOrderFor(t, db, c.ID, 6, 3200, "fulfilled", 45) // Outside the period
+
+	f, err := db.GetOrderFunnel(ctx, 30)
+	if err != nil {
+		t.Fatalf("GetOrderFunnel: %v", err)
+	}
+	want := OrderFunnel{Placed: 5, Pending: 1, Paid: 3, Fulfilled: 2, Cancelled: 1}
+	if *f != want {
+		t.Errorf("funnel = %+v, want %+v", *f, want)
+	}
+}
+
+func TestGetTotalSales(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	// No orders - should return 0
+	total, err := db.GetTotalSales(ctx)
+	if err != nil {
+		t.Fatalf("GetTotalSales: %v", err)
+	}
+	if total != 0 {
+		t.Errorf("expected 0 with no orders, got %d", total)
+	}
+
+	// Create customer and inventory
+	c, _ := db.CreateCustomer(ctx, "npub1test", "")
+	_ = db.AddEggs(ctx, 100)
+
+	// Create pending order - should not count
+	_, _ = db.CreateOrder(ctx, c.ID, 6, 3200)
+	total, _ = db.GetTotalSales(ctx)
+	if total != 0 {
+		t.Errorf("expected 0 with pending order only, got %d", total)
+	}
+
+	// Create paid order - should not count
+	order2, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	_ = db.UpdateOrderStatus(ctx, order2.ID, "paid")
+	total, _ = db.GetTotalSales(ctx)
+	if total != 0 {
+		t.Errorf("expected 0 with paid order only, got %d", total)
+	}
+
+	// Fulfill the paid order - now it should count
+	_ = db.FulfillOrder(ctx, order2.ID)
+	total, _ = db.GetTotalSales(ctx)
+	if total != 3200 {
+		t.Errorf("expected 3200 after fulfillment, got %d", total)
+	}
+
+	// Add another fulfilled order
+	order3, _ := db.CreateOrder(ctx, c.ID, 12, 6400)
+	_ = db.UpdateOrderStatus(ctx, order3.ID, "paid")
+	_ = db.FulfillOrder(ctx, order3.ID)
+	total, _ = db.GetTotalSales(ctx)
+	if total != 9600 {
+		t.Errorf("expected 9600 (3200+6400), got %d", total)
+	}
+
+	// Cancelled orders should not count
+	order4, _ := db.CreateOrder(ctx, c.ID, 6, 3200)
+	_ = db.CancelOrder(ctx, order4.ID)
 	total, _ = db.GetTotalSales(ctx)
 	if total != 9600 {
 		t.Errorf("expected 9600 (cancelled order not counted), got %d", total)
 	}
 }
+
+func TestSettings(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	// Unset setting
+	_, ok, err := db.GetSetting(ctx, SettingPickupInstructions)
+	if err != nil {
+		t.Fatalf("GetSetting: %v", err)
+	}
+	if ok {
+		t.Error("expected unset setting")
+	}
+
+	// Set and overwrite
+	if err := db.SetSetting(ctx, SettingPickupInstructions, "Blue cooler"); err != nil {
+		t.Fatalf("SetSetting: %v", err)
+	}
+	if err := db.SetSetting(ctx, SettingPickupInstructions, "Red cooler"); err != nil {
+		t.Fatalf("SetSetting overwrite: %v", err)
+	}
+	value, ok, _ := db.GetSetting(ctx, SettingPickupInstructions)
+	if !ok || value != "Red cooler" {
+		t.Errorf("expected %q, got %q (ok=%v)", "Red cooler", value, ok)
+	}
+
+	// Empty value is still a set value
+	_ = db.SetSetting(ctx, SettingPickupInstructions, "")
+	value, ok, _ = db.GetSetting(ctx, SettingPickupInstructions)
+	if !ok || value != "" {
+		t.Errorf("expected empty set value, got %q (ok=%v)", value, ok)
+	}
+
+	// Delete
+	if err := db.DeleteSetting(ctx, SettingPickupInstructions); err != nil {
+		t.Fatalf("DeleteSetting: %v", err)
+	}
+	if _, ok, _ := db.GetSetting(ctx, SettingPickupInstructions); ok {
+		t.Error("expected setting removed")
+	}
+}
+
+func TestFAQ(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	entries, err := db.ListFAQ(ctx)
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("ListFAQ = %v, %v; want none", entries, err)
+	}
+	if _, ok, err := db.GetFAQ(ctx, "washed"); err != nil || ok {
+		t.Errorf("GetFAQ unset = ok %v, %v", ok, err)
+	}
+
+	// Set and overwrite
+	if err := db.SetFAQ(ctx, "washed", "Yes."); err != nil {
+		t.Fatalf("SetFAQ: %v", err)
+	}
+	if err := db.SetFAQ(ctx, "washed", "No, they keep their bloom."); err != nil {
+		t.Fatalf("SetFAQ overwrite: %v", err)
+	}
+	if err := db.SetFAQ(ctx, "pickup", "Blue cooler by the gate."); err != nil {
+		t.Fatalf("SetFAQ: %v", err)
+	}
+	answer, ok, _ := db.GetFAQ(ctx, "washed")
+	if !ok || answer != "No, they keep their bloom." {
+		t.Errorf("expected the overwritten answer, got %q (ok=%v)", answer, ok)
+	}
+
+	entries, _ = db.ListFAQ(ctx)
+	if len(entries) != 2 || entries[0].Topic != "pickup" || entries[1].Topic != "washed" {
+		t.Errorf("expected topics sorted, got %+v", entries)
+	}
+
+	// Delete
+	if err := db.DeleteFAQ(ctx, "washed"); err != nil {
+		t.Fatalf("DeleteFAQ: %v", err)
+	}
+	if _, ok, _ := db.GetFAQ(ctx, "washed"); ok {
+		t.Error("expected topic removed")
+	}
+	if err := db.DeleteFAQ(ctx, "washed"); err != ErrFAQNotFound {
+		t.Errorf("expected ErrFAQNotFound, got %v", err)
+	}
+}
+
+func TestAdminMessages(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	_, _ = db.CreateCustomer(ctx, "npub1alice", "Alice")
+
+	if err := db.AddAdminMessage(ctx, "npub1alice", "Late on Saturday"); err != nil {
+		t.Fatalf("AddAdminMessage: %v", err)
+	}
+	if err := db.AddAdminMessage(ctx, "npub1alice", "Actually Sunday"); err != nil {
+		t.Fatalf("AddAdminMessage: %v", err)
+	}
+	if err := db.AddAdminMessage(ctx, "npub1bob", "Hello"); err != nil {
+		t.Fatalf("AddAdminMessage: %v", err)
+	}
+	// An old message doesn't count towards the recent ones
+	_, _ = db.ExecContext(ctx, `UPDATE messages_to_admin SET created_at = datetime('now', '-2 hours') WHERE message = 'Late on Saturday'`)
+
+	if n, err := db.CountRecentAdminMessages(ctx, "npub1alice", time.Hour); err != nil || n != 1 {
+		t.Errorf("CountRecentAdminMessages = %d, %v; want 1", n, err)
+	}
+	if n, _ := db.CountRecentAdminMessages(ctx, "npub1alice", 3*time.Hour); n != 2 {
+		t.Errorf("CountRecentAdminMessages over 3h = %d, want 2", n)
+	}
+
+	messages, err := db.ListAdminMessages(ctx, 2)
+	if err != nil {
+		t.Fatalf("ListAdminMessages: %v", err)
+	}
+	if len(messages) != 2 || messages[0].Message != "Hello" || messages[1].Message != "Actually Sunday" {
+		t.Fatalf("expected the two newest messages, newest first, got %+v", messages)
+	}
+	if messages[0].Name.Valid || messages[1].Name.String != "Alice" {
+		t.Errorf("expected names only for customers who have one, got %+v", messages)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+	for _, npub := range []string{"npub1alice", "npub1bob", "npub1carol"} {
+		_, _ = db.CreateCustomer(ctx, npub, "")
+	}
+
+	stale, err := db.StaleProfiles(ctx, time.Hour, 10)
+	if err != nil || len(stale) != 3 {
+		t.Fatalf("StaleProfiles = %v, %v; want every customer before any check", stale, err)
+	}
+
+	if err := db.SaveProfile(ctx, "npub1alice", "alice@example.com"); err != nil {
+		t.Fatalf("SaveProfile: %v", err)
+	}
+	if err := db.SaveProfile(ctx, "npub1bob", ""); err != nil {
+		t.Fatalf("SaveProfile: %v", err)
+	}
+	nip05s, err := db.NIP05s(ctx, []string{"npub1alice", "npub1bob", "npub1carol"})
+	if err != nil {
+		t.Fatalf("NIP05s: %v", err)
+	}
+	if len(nip05s) != 1 || nip05s["npub1alice"] != "alice@example.com" {
+		t.Errorf("NIP05s = %v, want only alice's", nip05s)
+	}
+	if nip05s, err := db.NIP05s(ctx, nil); err != nil || len(nip05s) != 0 {
+		t.Errorf("NIP05s(nil) = %v, %v", nip05s, err)
+	}
+
+	// Touching keeps the identifier but counts as a check
+	_, _ = db.ExecContext(ctx, `UPDATE profiles SET checked_at = datetime('now', '-2 hours')`)
+	if err := db.TouchProfile(ctx, "npub1alice"); err != nil {
+		t.Fatalf("TouchProfile: %v", err)
+	}
+	if nip05s, _ := db.NIP05s(ctx, []string{"npub1alice"}); nip05s["npub1alice"] != "alice@example.com" {
+		t.Errorf("touch changed the identifier: %v", nip05s)
+	}
+	stale, _ = db.StaleProfiles(ctx, time.Hour, 10)
+	if len(stale) != 2 || stale[0] != "npub1carol" || stale[1] != "npub1bob" {
+		t.Errorf("StaleProfiles = %v, want never-checked carol, then bob", stale)
+	}
+	if stale, _ := db.StaleProfiles(ctx, time.Hour, 1); len(stale) != 1 {
+		t.Errorf("StaleProfiles limit 1 = %v", stale)
+	}
+}
+
+func TestBlocklist(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	npub := "npub1blocked"
+	admin := "npub1admin"
+
+	blocked, err := db.IsBlocked(ctx, npub)
+	if err != nil {
+		t.Fatalf("IsBlocked: %v", err)
+	}
+	if blocked {
+		t.Error("expected npub not blocked initially")
+	}
+
+	if err := db.BlockNpub(ctx, npub, admin); err != nil {
+		t.Fatalf("BlockNpub: %v", err)
+	}
+	if err := db.BlockNpub(ctx, npub, admin); err != ErrAlreadyBlocked {
+		t.Errorf("expected ErrAlreadyBlocked, got %v", err)
+	}
+
+	blocked, _ = db.IsBlocked(ctx, npub)
+	if !blocked {
+		t.Error("expected npub blocked")
+	}
+
+	entries, err := db.ListBlocked(ctx)
+	if err != nil {
+		t.Fatalf("ListBlocked: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Npub != npub || entries[0].BlockedBy != admin {
+		t.Errorf("unexpected blocklist: %+v", entries)
+	}
+	if entries[0].CreatedAt.IsZero() {
+		t.Error("expected created_at to be set")
+	}
+
+	if err := db.UnblockNpub(ctx, npub); err != nil {
+		t.Fatalf("UnblockNpub: %v", err)
+	}
+	if err := db.UnblockNpub(ctx, npub); err != ErrNotBlocked {
+		t.Errorf("expected ErrNotBlocked, got %v", err)
+	}
+	blocked, _ = db.IsBlocked(ctx, npub)
+	if blocked {
+		t.Error("expected npub unblocked")
+	}
+}
+
+func TestGetTriggeredNotifications_SkipsBlocked(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	active, _ := db.CreateCustomer(ctx, "npub1active", "")
+	frozen, _ := db.CreateCustomer(ctx, "npub1frozen", "")
+	_ = db.UpsertInventoryNotification(ctx, active.ID, 6)
+	_ = db.UpsertInventoryNotification(ctx, frozen.ID, 6)
+	_ = db.BlockNpub(ctx, frozen.Npub, "npub1admin")
+
+	notifications, err := db.GetTriggeredNotifications(ctx, 12)
+	if err != nil {
+		t.Fatalf("GetTriggeredNotifications: %v", err)
+	}
+	if len(notifications) != 1 || notifications[0].CustomerNpub != active.Npub {
+		t.Errorf("expected only active customer notified, got %+v", notifications)
+	}
+}
+
+func TestInventoryNotification_AnyQuantity(t *testing.T) {
+	ctx := context.Background()
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	defer func() { _ = db.Close() }()
+
+	if _, err := db.MigrateUp(ctx); err != nil {
+		t.Fatalf("MigrateUp: %v", err)
+	}
+	alice, _ := db.CreateCustomer(ctx, "npub1alice", "")
+	bob, _ := db.CreateCustomer(ctx, "npub1bob", "")
+	if err := db.UpsertInventoryNotification(ctx, alice.ID, 5); err != nil {
+		t.Fatalf("UpsertInventoryNotification(5): %v", err)
+	}
+	_ = db.UpsertInventoryNotification(ctx, bob.ID, 12)
+	if err := db.UpsertInventoryNotification(ctx, bob.ID, 0); err == nil {
+		t.Error("UpsertInventoryNotification(0): expected error")
+	}
+
+	// Rolling back keeps only the sizes the old constraint allows
+	if _, err := db.MigrateDownTo(ctx, 18); err != nil {
+		t.Fatalf("MigrateDownTo(18): %v", err)
+	}
+	if n, err := db.GetInventoryNotification(ctx, alice.ID); err != nil || n != nil {
+		t.Errorf("alice's notification = %+v, %v; want none", n, err)
+	}
+	if n, err := db.GetInventoryNotification(ctx, bob.ID); err != nil || n == nil || n.ThresholdEggs != 12 {
+		t.Errorf("bob's notification = %+v, %v; want 12", n, err)
+	}
+}
+
+// testBolt11 builds a checksum-valid 1000 sat invoice whose payment hash is
+// 32 copies of hashByte. The signature is zeroed.
+func testBolt11(t *testing.T, hashByte byte) (invoice, paymentHash string) {
+	t.Helper()
+
+	hash := make([]byte, 32)
+	for i := range hash {
+		hash[i] = hashByte
+	}
+	hashWords, err := bech32.ConvertBits(hash, 8, 5, true)
+	if err != nil {
+		t.Fatalf("converting payment hash: %v", err)
+	}
+
+	words := make([]byte, 0, 7+3+52+104)
+	ts := time.Now().Unix()
+	for i := 6; i >= 0; i-- {
+		words = append(words, byte(ts>>(5*i))&31)
+	}
+	words = append(words, 1, 1, 20) // p field, 52 words
+	words = append(words, hashWords...)
+	words = append(words, make([]byte, 104)...)
+
+	invoice, err = bech32.Encode("lnbc10u", words)
+	if err != nil {
+		t.Fatalf("encoding invoice: %v", err)
+	}
+	return invoice, hex.EncodeToString(hash)
+}
+
+func TestRecordInvoice(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	_ = db.AddEggs(ctx, 12)
+	customer, _ := db.CreateCustomer(ctx, "npub1test", "")
+	order, _ := db.CreateOrder(ctx, customer.ID, 6, 1000)
+
+	bolt11, hash := testBolt11(t, 0x01)
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	inv, err := db.RecordInvoice(ctx, order.ID, bolt11, 1000, expiresAt)
+	if err != nil {
+		t.Fatalf("RecordInvoice: %v", err)
+	}
+	if inv.PaymentHash != hash {
+		t.Errorf("expected payment hash %s, got %s", hash, inv.PaymentHash)
+	}
+	if inv.Status != InvoiceStatusRequested {
+		t.Errorf("expected status %s, got %s", InvoiceStatusRequested, inv.Status)
+	}
+
+	invoices, err := db.GetInvoicesByOrder(ctx, order.ID)
+	if err != nil {
+		t.Fatalf("GetInvoicesByOrder: %v", err)
+	}
+	if len(invoices) != 1 {
+		t.Fatalf("expected 1 invoice, got %d", len(invoices))
+	}
+	got := invoices[0]
+	if got.Bolt11 != bolt11 || got.AmountSats != 1000 || got.OrderID != order.ID {
+		t.Errorf("unexpected invoice: %+v", got)
+	}
+	if !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("expected expires_at %v, got %v", expiresAt, got.ExpiresAt)
+	}
+
+	// Undecodable invoices are rejected
+	if _, err := db.RecordInvoice(ctx, order.ID, "lnbcgarbage", 1000, expiresAt); err == nil {
+		t.Error("expected error for invalid bolt11")
+	}
+}
+
+func TestMarkInvoicePaid(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	_ = db.AddEggs(ctx, 12)
+	customer, _ := db.CreateCustomer(ctx, "npub1test", "")
+	order, _ := db.CreateOrder(ctx, customer.ID, 6, 1000)
+
+	bolt11, hash := testBolt11(t, 0x02)
+	if _, err := db.RecordInvoice(ctx, order.ID, bolt11, 1000, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RecordInvoice: %v", err)
+	}
+
+	if err := db.MarkInvoicePaid(ctx, hash); err != nil {
+		t.Fatalf("MarkInvoicePaid: %v", err)
+	}
+
+	invoices, _ := db.GetInvoicesByOrder(ctx, order.ID)
+	if len(invoices) != 1 || invoices[0].Status != InvoiceStatusPaid {
+		t.Errorf("expected paid invoice, got %+v", invoices)
+	}
+
+	if err := db.MarkInvoicePaid(ctx, "deadbeef"); err != ErrInvoiceNotFound {
+		t.Errorf("expected ErrInvoiceNotFound, got %v", err)
+	}
+}
+
+func TestExpireInvoices(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	_ = db.AddEggs(ctx, 12)
+	customer, _ := db.CreateCustomer(ctx, "npub1test", "")
+	order, _ := db.CreateOrder(ctx, customer.ID, 6, 1000)
+
+	now := time.Now()
+	stale, _ := testBolt11(t, 0x03)
+	fresh, _ := testBolt11(t, 0x04)
+	paid, paidHash := testBolt11(t, 0x05)
+
+	_, _ = db.RecordInvoice(ctx, order.ID, stale, 1000, now.Add(-time.Minute))
+	_, _ = db.RecordInvoice(ctx, order.ID, fresh, 1000, now.Add(time.Hour))
+	_, _ = db.RecordInvoice(ctx, order.ID, paid, 1000, now.Add(-time.Minute))
+	_ = db.MarkInvoicePaid(ctx, paidHash)
+
+	expired, err := db.ExpireInvoices(ctx, now)
+	if err != nil {
+		t.Fatalf("ExpireInvoices: %v", err)
+	}
+	if expired != 1 {
+		t.Errorf("expected 1 expired invoice, got %d", expired)
+	}
+
+	want := map[string]string{
+		stale: InvoiceStatusExpired,
+		fresh: InvoiceStatusRequested,
+		paid:  InvoiceStatusPaid,
+	}
+	invoices, _ := db.GetInvoicesByOrder(ctx, order.ID)
+	for _, inv := range invoices {
+		if inv.Status != want[inv.Bolt11] {
+			t.Errorf("invoice %d: expected status %s, got %s", inv.ID, want[inv.Bolt11], inv.Status)
+		}
+	}
+
+	// Already-expired invoices aren't counted again
+	expired, _ = db.ExpireInvoices(ctx, now)
+	if expired != 0 {
+		t.Errorf("expected 0 on second pass, got %d", expired)
+	}
+}
+
+func TestSeedAdmins(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	if err := db.SeedAdmins(ctx, []string{"npub1alice", "npub1bob"}); err != nil {
+		t.Fatalf("SeedAdmins: %v", err)
+	}
+	_ = db.AddAdmin(ctx, "npub1carol", "npub1alice")
+
+	// Reseeding is idempotent and drops config admins no longer listed,
+	// but keeps admins added via DM
+	if err := db.SeedAdmins(ctx, []string{"npub1alice"}); err != nil {
+		t.Fatalf("SeedAdmins reseed: %v", err)
+	}
+
+	admins, err := db.ListAdmins(ctx)
+	if err != nil {
+		t.Fatalf("ListAdmins: %v", err)
+	}
+	got := map[string]bool{}
+	for _, a := range admins {
+		got[a.Npub] = a.FromConfig
+	}
+	want := map[string]bool{"npub1alice": true, "npub1carol": false}
+	if len(got) != len(want) {
+		t.Fatalf("expected admins %v, got %v", want, got)
+	}
+	for npub, fromConfig := range want {
+		if gotFromConfig, ok := got[npub]; !ok || gotFromConfig != fromConfig {
+			t.Errorf("admin %s: expected from_config=%v, got %v (present=%v)", npub, fromConfig, gotFromConfig, ok)
+		}
+	}
+
+	if isAdmin, _ := db.IsAdmin(ctx, "npub1bob"); isAdmin {
+		t.Error("expected npub1bob to lose admin after removal from config")
+	}
+}
+
+func TestAddRemoveAdmin(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	_ = db.SeedAdmins(ctx, []string{"npub1alice"})
+
+	// Prime the cache before changes
+	if isAdmin, _ := db.IsAdmin(ctx, "npub1bob"); isAdmin {
+		t.Fatal("expected npub1bob not admin initially")
+	}
+
+	if err := db.AddAdmin(ctx, "npub1bob", "npub1alice"); err != nil {
+		t.Fatalf("AddAdmin: %v", err)
+	}
+	if err := db.AddAdmin(ctx, "npub1bob", "npub1alice"); err != ErrAdminExists {
+		t.Errorf("expected ErrAdminExists, got %v", err)
+	}
+	if isAdmin, _ := db.IsAdmin(ctx, "npub1bob"); !isAdmin {
+		t.Error("expected cache invalidated after AddAdmin")
+	}
+
+	if err := db.RemoveAdmin(ctx, "npub1alice"); err != ErrConfigAdmin {
+		t.Errorf("expected ErrConfigAdmin, got %v", err)
+	}
+	if err := db.RemoveAdmin(ctx, "npub1nobody"); err != ErrAdminNotFound {
+		t.Errorf("expected ErrAdminNotFound, got %v", err)
+	}
+
+	if err := db.RemoveAdmin(ctx, "npub1bob"); err != nil {
+		t.Fatalf("RemoveAdmin: %v", err)
+	}
+	if isAdmin, _ := db.IsAdmin(ctx, "npub1bob"); isAdmin {
+		t.Error("expected cache invalidated after RemoveAdmin")
+	}
+}
+
+func TestRemoveAdmin_RefusesLast(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	// No config admins: a DM-added admin is the only one left
+	_ = db.AddAdmin(ctx, "npub1bob", "npub1alice")
+
+	if err := db.RemoveAdmin(ctx, "npub1bob"); err != ErrLastAdmin {
+		t.Errorf("expected ErrLastAdmin, got %v", err)
+	}
+	if isAdmin, _ := db.IsAdmin(ctx, "npub1bob"); !isAdmin {
+		t.Error("expected last admin kept")
+	}
+}
+
+func TestAdminPrefs(t *testing.T) {
+	ctx := context.Background()
+	// Open turns on the foreign keys that tie preferences to admins
+	db, err := Open(":memory:")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	if err := db.SeedAdmins(ctx, []string{"npub1alice", "npub1bob"}); err != nil {
+		t.Fatalf("SeedAdmins: %v", err)
+	}
+
+	// Nothing set yet: everything on
+	if prefs, err := db.GetAdminPrefs(ctx, "npub1alice"); err != nil || prefs != DefaultAdminPrefs {
+		t.Fatalf("GetAdminPrefs = %+v, %v; want defaults", prefs, err)
+	}
+
+	if err := db.SetAdminPref(ctx, "npub1alice", PrefOrders, false); err != nil {
+		t.Fatalf("SetAdminPref: %v", err)
+	}
+	if err := db.SetAdminPref(ctx, "npub1alice", PrefInventory, false); err != nil {
+		t.Fatalf("SetAdminPref: %v", err)
+	}
+	want := AdminPrefs{Payments: true}
+	if prefs, _ := db.GetAdminPrefs(ctx, "npub1alice"); prefs != want {
+		t.Errorf("after turning orders and inventory off: %+v, want %+v", prefs, want)
+	}
+	if err := db.SetAdminPref(ctx, "npub1alice", PrefInventory, true); err != nil {
+		t.Fatalf("SetAdminPref: %v", err)
+	}
+	want.LowInventory = true
+	if prefs, _ := db.GetAdminPrefs(ctx, "npub1alice"); prefs != want {
+		t.Errorf("after turning inventory back on: %+v, want %+v", prefs, want)
+	}
+
+	all, err := db.ListAdminPrefs(ctx)
+	if err != nil {
+		t.Fatalf("ListAdminPrefs: %v", err)
+	}
+	if len(all) != 1 || all["npub1alice"] != want {
+		t.Errorf("ListAdminPrefs = %+v, want only npub1alice's", all)
+	}
+
+	if err := db.SetAdminPref(ctx, "npub1alice", "sms", false); !errors.Is(err, ErrUnknownAdminPref) {
+		t.Errorf("unknown pref: err = %v, want ErrUnknownAdminPref", err)
+	}
+	if err := db.SetAdminPref(ctx, "npub1carol", PrefOrders, false); !errors.Is(err, ErrAdminNotFound) {
+		t.Errorf("non-admin: err = %v, want ErrAdminNotFound", err)
+	}
+
+	// Dropping the admin drops their preferences
+	if err := db.SeedAdmins(ctx, []string{"npub1bob"}); err != nil {
+		t.Fatalf("SeedAdmins: %v", err)
+	}
+	if all, _ := db.ListAdminPrefs(ctx); len(all) != 0 {
+		t.Errorf("prefs kept for a removed admin: %+v", all)
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	ctx := context.Background()
+	db := setupTestDB(t)
+
+	npub := "npub1qqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqqsutj2c5"
+	if _, err := db.CreateCustomer(ctx, npub, ""); err != nil {
+		t.Fatalf("CreateCustomer: %v", err)
+	}
+	// CreateCustomer maps the violation to ErrCustomerExists
+	if _, err := db.CreateCustomer(ctx, npub, ""); !errors.Is(err, ErrCustomerExists) {
+		t.Fatalf("duplicate CreateCustomer: err = %v, want ErrCustomerExists", err)
+	}
+
+	execErr := func(query string, args ...any) error {
+		t.Helper()
+		_, err := db.ExecContext(ctx, query, args...)
+		if err == nil {
+			t.Fatalf("%s: expected an error", query)
+		}
+		return err
+	}
+	_ = db.AddAdmin(ctx, npub, "")
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"other error", errors.New("UNIQUE constraint failed: customers.npub"), false},
+		{"unique column", execErr(`INSERT INTO customers (npub) VALUES (?)`, npub), true},
+		{"text primary key", execErr(`INSERT INTO admins (npub) VALUES (?)`, npub), true},
+		{"wrapped", fmt.Errorf("creating customer: %w", execErr(`INSERT INTO customers (npub) VALUES (?)`, npub)), true},
+		{"not null", execErr(`INSERT INTO customers (npub) VALUES (NULL)`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isUniqueViolation(tt.err); got != tt.want {
+				t.Errorf("isUniqueViolation(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package dm
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultMaxMessageBytes is the default plaintext budget per DM. Encryption
+// (and double encryption for gift wraps) roughly doubles the size on the wire,
+// so this leaves headroom under the strictest common relay event limits.
+const DefaultMaxMessageBytes = 8 * 1024
+
+// partMarkerReserve is the space reserved for the "(i/n) " part marker.
+const partMarkerReserve = len("(999/999) ")
+
+// MinMessageBytes is the smallest maxBytes SplitMessage splits with: room for
+// the part marker and one character of any size.
+const MinMessageBytes = partMarkerReserve + utf8.UTFMax
+
+// SplitMessage breaks a message into parts of at most maxBytes each, preferring
+// line boundaries. When more than one part is produced each is prefixed with a
+// "(i/n) " marker so the recipient can read them in order. Lines longer than the
+// budget are split at rune boundaries. A maxBytes below MinMessageBytes leaves
+// no room for text after the marker, so the message is returned whole.
+func SplitMessage(message string, maxBytes int) []string {
+	if maxBytes < MinMessageBytes || len(message) <= maxBytes {
+		return []string{message}
+	}
+	budget := maxBytes - partMarkerReserve
+
+	var parts []string
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+		parts = append(parts, strings.TrimRight(current.String(), "\n"))
+		current.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(message, "\n") {
+		if current.Len()+len(line) <= budget {
+			current.WriteString(line)
+			continue
+		}
+		flush()
+		for len(line) > budget {
+			cut := budget
+			for cut > 0 && !utf8.RuneStart(line[cut]) {
+				cut--
+			}
+			parts = append(parts, line[:cut])
+			line = line[cut:]
+		}
+		current.WriteString(line)
+	}
+	flush()
+
+	if len(parts) <= 1 {
+		return parts
+	}
+	for i := range parts {
+		parts[i] = fmt.Sprintf("(%d/%d) %s", i+1, len(parts), parts[i])
+	}
+	return parts
+}
@@ -0,0 +1,159 @@
+package dm
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/nbd-wtf/go-nostr/keyer"
+	"github.com/nbd-wtf/go-nostr/nip04"
+	"github.com/nbd-wtf/go-nostr/nip59"
+)
+
+// longOrderListing builds a synthetic admin "orders" output with n lines.
+func longOrderListing(n int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d orders (most recent first):\n", n)
+	for i := n; i > 0; i-- {
+		fmt.Fprintf(&b, "• #%d: npub1rm9q804...8ejt | 12 eggs | 6400 sats | pending\n", i)
+	}
+	return b.String()
+}
+
+func TestSplitMessage_ShortMessageUnchanged(t *testing.T) {
+	msg := "Order 1: 6 eggs reserved for 3200 sats."
+	parts := SplitMessage(msg, DefaultMaxMessageBytes)
+	if len(parts) != 1 || parts[0] != msg {
+		t.Errorf("SplitMessage() = %q, want single unchanged part", parts)
+	}
+}
+
+func TestSplitMessage_DisabledBudget(t *testing.T) {
+	msg := longOrderListing(500)
+	for _, maxBytes := range []int{0, MinMessageBytes - 1} {
+		if parts := SplitMessage(msg, maxBytes); len(parts) != 1 || parts[0] != msg {
+			t.Errorf("SplitMessage() with budget %d returned %d parts, want 1", maxBytes, len(parts))
+		}
+	}
+	// The smallest budget still makes progress through multi-byte runes
+	if parts := SplitMessage(strings.Repeat("🥚", 5), MinMessageBytes); len(parts) != 5 || parts[0] != "(1/5) 🥚" {
+		t.Errorf("SplitMessage() with budget %d = %q, want one egg per part", MinMessageBytes, parts)
+	}
+}
+
+func TestSplitMessage_LineBoundaries(t *testing.T) {
+	msg := longOrderListing(500)
+	maxBytes := 2048
+
+	parts := SplitMessage(msg, maxBytes)
+	if len(parts) < 2 {
+		t.Fatalf("expected multiple parts, got %d", len(parts))
+	}
+
+	var rebuilt []string
+	for i, part := range parts {
+		if len(part) > maxBytes {
+			t.Errorf("part %d is %d bytes, want <= %d", i+1, len(part), maxBytes)
+		}
+
+		marker := fmt.Sprintf("(%d/%d) ", i+1, len(parts))
+		if !strings.HasPrefix(part, marker) {
+			t.Fatalf("part %d missing marker %q: %q", i+1, marker, part[:20])
+		}
+		rebuilt = append(rebuilt, strings.TrimPrefix(part, marker))
+	}
+
+	// Every line survives intact and in order
+	want := strings.TrimRight(msg, "\n")
+	if got := strings.Join(rebuilt, "\n"); got != want {
+		t.Error("rejoined parts do not match the original message")
+	}
+}
+
+func TestSplitMessage_OversizedLine(t *testing.T) {
+	// A single line with multi-byte runes longer than the budget
+	msg := strings.Repeat("🥚", 1000)
+	maxBytes := 512
+
+	parts := SplitMessage(msg, maxBytes)
+	if len(parts) < 2 {
+		t.Fatalf("expected multiple parts, got %d", len(parts))
+	}
+
+	var rebuilt strings.Builder
+	for i, part := range parts {
+		if len(part) > maxBytes {
+			t.Errorf("part %d is %d bytes, want <= %d", i+1, len(part), maxBytes)
+		}
+		if !utf8.ValidString(part) {
+			t.Errorf("part %d split a rune", i+1)
+		}
+		rebuilt.WriteString(strings.TrimPrefix(part, fmt.Sprintf("(%d/%d) ", i+1, len(parts))))
+	}
+
+	if rebuilt.String() != msg {
+		t.Error("rejoined parts do not match the original message")
+	}
+}
+
+func TestSplitMessage_WrapsForBothProtocols(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	sharedSecret, err := nip04.ComputeSharedSecret(botPubkeyHex, recipientSecretHex)
+	if err != nil {
+		t.Fatalf("computing shared secret: %v", err)
+	}
+
+	parts := SplitMessage(longOrderListing(400), DefaultMaxMessageBytes)
+	if len(parts) < 2 {
+		t.Fatalf("expected multiple parts, got %d", len(parts))
+	}
+
+	// Relays commonly cap events at 64KB; the encrypted parts must stay well below
+	const relayLimit = 32 * 1024
+
+	for i, part := range parts {
+		legacy, err := WrapLegacyResponse(ctx, botKr, botSecretHex, botPubkeyHex, recipientPubkeyHex, part, "")
+		if err != nil {
+			t.Fatalf("WrapLegacyResponse() part %d error = %v", i+1, err)
+		}
+		if size := len(legacy.String()); size > relayLimit {
+			t.Errorf("NIP-04 part %d is %d bytes on the wire", i+1, size)
+		}
+		decrypted, err := nip04.Decrypt(legacy.Content, sharedSecret)
+		if err != nil {
+			t.Fatalf("decrypting NIP-04 part %d: %v", i+1, err)
+		}
+		if decrypted != part {
+			t.Errorf("NIP-04 part %d content mismatch", i+1)
+		}
+
+		wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, part, "", "")
+		if err != nil {
+			t.Fatalf("WrapResponse() part %d error = %v", i+1, err)
+		}
+		if size := len(wrapped.String()); size > relayLimit {
+			t.Errorf("NIP-17 part %d is %d bytes on the wire", i+1, size)
+		}
+		rumor, err := nip59.GiftUnwrap(*wrapped, func(pubkey, ciphertext string) (string, error) {
+			return recipientKr.Decrypt(ctx, ciphertext, pubkey)
+		})
+		if err != nil {
+			t.Fatalf("GiftUnwrap() part %d error = %v", i+1, err)
+		}
+		if rumor.Content != part {
+			t.Errorf("NIP-17 part %d content mismatch", i+1)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package dm
 import (
 	"context"
 	"fmt"
+	"math/rand/v2"
 
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip04"
@@ -15,21 +16,46 @@ type DMProtocol int
 const (
 	ProtocolNIP04 DMProtocol = DMProtocol(nostr.KindEncryptedDirectMessage) // Legacy encrypted DM (kind:4)
 	ProtocolNIP17 DMProtocol = DMProtocol(nostr.KindGiftWrap)               // Gift-wrapped DM (kind:1059)
+	ProtocolNIP44 DMProtocol = 44                                           // NIP-44 encrypted legacy DM (kind:4)
 )
 
+// maxWrapSkewSeconds is how far into the past gift wrap timestamps may be
+// shifted. NIP-59 recommends up to two days to defeat timing correlation.
+const maxWrapSkewSeconds = 2 * 24 * 60 * 60
+
+// randomizeWrapTimestamp shifts a gift wrap's created_at 1s to 2 days into the past.
+// Only the outer wrap is touched; the rumor keeps its accurate timestamp.
+func randomizeWrapTimestamp(wrap *nostr.Event) {
+	wrap.CreatedAt = nostr.Now() - nostr.Timestamp(1+rand.Int64N(maxWrapSkewSeconds))
+}
+
+// replyTags builds the tags for a DM addressed to recipientPubkeyHex.
+// If replyToID is non-empty, an "e" tag is added so clients thread the reply
+// under the event that triggered it.
+func replyTags(recipientPubkeyHex, replyToID string) nostr.Tags {
+	tags := nostr.Tags{nostr.Tag{"p", recipientPubkeyHex}}
+	if replyToID != "" {
+		tags = append(tags, nostr.Tag{"e", replyToID})
+	}
+	return tags
+}
+
 // WrapResponse creates a NIP-17 gift-wrapped DM response.
 // recipientPubkeyHex is the hex pubkey of the recipient.
+// replyToID is the optional ID of the rumor being answered (empty for none).
+// subject is the optional conversation title clients show for the thread (empty for none).
 // Returns a ready-to-publish kind:1059 gift-wrapped event.
-func WrapResponse(ctx context.Context, kr nostr.Keyer, botPubkeyHex string, recipientPubkeyHex string, message string) (*nostr.Event, error) {
+func WrapResponse(ctx context.Context, kr nostr.Keyer, botPubkeyHex, recipientPubkeyHex, message, replyToID, subject string) (*nostr.Event, error) {
 	// Create the rumor (kind:14 direct message)
 	rumor := nostr.Event{
 		PubKey:    botPubkeyHex,
 		CreatedAt: nostr.Now(),
 		Kind:      nostr.KindDirectMessage, // kind:14
-		Tags: nostr.Tags{
-			nostr.Tag{"p", recipientPubkeyHex},
-		},
-		Content: message,
+		Tags:      replyTags(recipientPubkeyHex, replyToID),
+		Content:   message,
+	}
+	if subject != "" {
+		rumor.Tags = append(rumor.Tags, nostr.Tag{"subject", subject})
 	}
 
 	// Gift wrap the rumor using NIP-59
@@ -45,8 +71,9 @@ func WrapResponse(ctx context.Context, kr nostr.Keyer, botPubkeyHex string, reci
 		func(event *nostr.Event) error {
 			return kr.SignEvent(ctx, event)
 		},
-		// No modification function needed
-		nil,
+		// Randomize the wrap timestamp so relays can't correlate activity
+		// (nip59.GiftWrap already backdates the seal on its own)
+		randomizeWrapTimestamp,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("gift wrapping response: %w", err)
@@ -57,8 +84,9 @@ func WrapResponse(ctx context.Context, kr nostr.Keyer, botPubkeyHex string, reci
 
 // WrapLegacyResponse creates a NIP-04 encrypted DM response (kind:4).
 // botSecretHex is the hex-encoded secret key of the bot.
+// replyToID is the optional ID of the kind:4 event being answered (empty for none).
 // Returns a ready-to-publish kind:4 encrypted direct message event.
-func WrapLegacyResponse(ctx context.Context, kr nostr.Keyer, botSecretHex, botPubkeyHex, recipientPubkeyHex, message string) (*nostr.Event, error) {
+func WrapLegacyResponse(ctx context.Context, kr nostr.Keyer, botSecretHex, botPubkeyHex, recipientPubkeyHex, message, replyToID string) (*nostr.Event, error) {
 	// 1. Compute shared secret
 	sharedSecret, err := nip04.ComputeSharedSecret(recipientPubkeyHex, botSecretHex)
 	if err != nil {
@@ -76,7 +104,7 @@ func WrapLegacyResponse(ctx context.Context, kr nostr.Keyer, botSecretHex, botPu
 		PubKey:    botPubkeyHex,
 		CreatedAt: nostr.Now(),
 		Kind:      nostr.KindEncryptedDirectMessage,
-		Tags:      nostr.Tags{nostr.Tag{"p", recipientPubkeyHex}},
+		Tags:      replyTags(recipientPubkeyHex, replyToID),
 		Content:   ciphertext,
 	}
 
@@ -87,3 +115,54 @@ func WrapLegacyResponse(ctx context.Context, kr nostr.Keyer, botSecretHex, botPu
 
 	return event, nil
 }
+
+// WrapNIP44Response creates a kind:4 DM whose content is NIP-44 encrypted
+// with the keyer instead of NIP-04. Used for legacy-kind senders whose clients
+// understand NIP-44 payloads.
+// replyToID is the optional ID of the kind:4 event being answered (empty for none).
+func WrapNIP44Response(ctx context.Context, kr nostr.Keyer, botPubkeyHex, recipientPubkeyHex, message, replyToID string) (*nostr.Event, error) {
+	ciphertext, err := kr.Encrypt(ctx, message, recipientPubkeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("encrypting message: %w", err)
+	}
+
+	event := &nostr.Event{
+		PubKey:    botPubkeyHex,
+		CreatedAt: nostr.Now(),
+		Kind:      nostr.KindEncryptedDirectMessage,
+		Tags:      replyTags(recipientPubkeyHex, replyToID),
+		Content:   ciphertext,
+	}
+
+	if err := kr.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("signing event: %w", err)
+	}
+
+	return event, nil
+}
+
+// DecryptLegacy decrypts the content of a kind:4 DM. NIP-04 is tried first;
+// if allowNIP44 is set, content that doesn't decrypt as NIP-04 is retried as
+// NIP-44. The returned protocol reflects which scheme succeeded so the reply
+// can match it.
+func DecryptLegacy(ctx context.Context, kr nostr.Keyer, botSecretHex string, event *nostr.Event, allowNIP44 bool) (string, DMProtocol, error) {
+	sharedSecret, err := nip04.ComputeSharedSecret(event.PubKey, botSecretHex)
+	if err != nil {
+		return "", 0, fmt.Errorf("computing shared secret: %w", err)
+	}
+
+	plaintext, nip04Err := nip04.Decrypt(event.Content, sharedSecret)
+	if nip04Err == nil {
+		return plaintext, ProtocolNIP04, nil
+	}
+	if !allowNIP44 {
+		return "", 0, fmt.Errorf("decrypting as NIP-04: %w", nip04Err)
+	}
+
+	plaintext, nip44Err := kr.Decrypt(ctx, event.Content, event.PubKey)
+	if nip44Err != nil {
+		return "", 0, fmt.Errorf("decrypting as NIP-04 (%v) or NIP-44: %w", nip04Err, nip44Err)
+	}
+
+	return plaintext, ProtocolNIP44, nil
+}
@@ -36,7 +36,7 @@ func TestWrapResponse(t *testing.T) {
 	message := "Hello, this is a test response!"
 
 	// Wrap the response
-	wrapped, err := WrapResponse(ctx, kr, botPubkeyHex, recipientPubkeyHex, message)
+	wrapped, err := WrapResponse(ctx, kr, botPubkeyHex, recipientPubkeyHex, message, "", "")
 	if err != nil {
 		t.Fatalf("WrapResponse() error = %v", err)
 	}
@@ -79,7 +79,7 @@ func TestWrapResponse_CanBeUnwrapped(t *testing.T) {
 	message := "This message should be decryptable by the recipient"
 
 	// Wrap the response
-	wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, message)
+	wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, message, "", "")
 	if err != nil {
 		t.Fatalf("WrapResponse() error = %v", err)
 	}
@@ -138,7 +138,7 @@ func TestWrapResponse_DifferentMessages(t *testing.T) {
 
 	for _, msg := range messages {
 		t.Run(msg[:min(len(msg), 20)], func(t *testing.T) {
-			wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, msg)
+			wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, msg, "", "")
 			if err != nil {
 				t.Fatalf("WrapResponse() error = %v", err)
 			}
@@ -157,6 +157,58 @@ func TestWrapResponse_DifferentMessages(t *testing.T) {
 	}
 }
 
+func TestWrapResponse_RandomizedTimestamp(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	message := "timestamps should not leak"
+
+	for i := 0; i < 20; i++ {
+		before := nostr.Now()
+		wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, message, "", "")
+		if err != nil {
+			t.Fatalf("WrapResponse() error = %v", err)
+		}
+		after := nostr.Now()
+
+		// Wrap must be backdated, but no further than the NIP-59 window
+		if wrapped.CreatedAt >= after {
+			t.Errorf("wrap CreatedAt = %d, want before %d", wrapped.CreatedAt, after)
+		}
+		if wrapped.CreatedAt < after-maxWrapSkewSeconds {
+			t.Errorf("wrap CreatedAt = %d, more than 2 days before %d", wrapped.CreatedAt, after)
+		}
+
+		// Signature must still be valid after the modification
+		if ok, err := wrapped.CheckSignature(); err != nil || !ok {
+			t.Errorf("wrapped event has invalid signature: %v", err)
+		}
+
+		rumor, err := nip59.GiftUnwrap(*wrapped, func(pubkey, ciphertext string) (string, error) {
+			return recipientKr.Decrypt(ctx, ciphertext, pubkey)
+		})
+		if err != nil {
+			t.Fatalf("GiftUnwrap() error = %v", err)
+		}
+		if rumor.Content != message {
+			t.Errorf("rumor.Content = %q, want %q", rumor.Content, message)
+		}
+
+		// Rumor keeps the accurate timestamp
+		if rumor.CreatedAt < before || rumor.CreatedAt > after {
+			t.Errorf("rumor CreatedAt = %d, want between %d and %d", rumor.CreatedAt, before, after)
+		}
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
@@ -176,7 +228,7 @@ func TestWrapLegacyResponse(t *testing.T) {
 	message := "Hello from legacy NIP-04!"
 
 	// Wrap the response
-	wrapped, err := WrapLegacyResponse(ctx, kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, message)
+	wrapped, err := WrapLegacyResponse(ctx, kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, message, "")
 	if err != nil {
 		t.Fatalf("WrapLegacyResponse() error = %v", err)
 	}
@@ -218,7 +270,7 @@ func TestWrapLegacyResponse_CanBeDecrypted(t *testing.T) {
 	message := "This is a legacy NIP-04 encrypted message"
 
 	// Wrap the response
-	wrapped, err := WrapLegacyResponse(ctx, botKr, botSecretHex, botPubkeyHex, recipientPubkeyHex, message)
+	wrapped, err := WrapLegacyResponse(ctx, botKr, botSecretHex, botPubkeyHex, recipientPubkeyHex, message, "")
 	if err != nil {
 		t.Fatalf("WrapLegacyResponse() error = %v", err)
 	}
@@ -251,3 +303,284 @@ func TestWrapLegacyResponse_CanBeDecrypted(t *testing.T) {
 		t.Errorf("p tag = %s, want %s", pTag[1], recipientPubkeyHex)
 	}
 }
+
+func TestWrapResponse_SubjectTag(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+	unwrap := func(wrapped *nostr.Event) nostr.Event {
+		rumor, err := nip59.GiftUnwrap(*wrapped, func(pubkey, ciphertext string) (string, error) {
+			return recipientKr.Decrypt(ctx, ciphertext, pubkey)
+		})
+		if err != nil {
+			t.Fatalf("GiftUnwrap() error = %v", err)
+		}
+		return rumor
+	}
+
+	wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, "Order 7: 6 eggs reserved", "", "Order #7")
+	if err != nil {
+		t.Fatalf("WrapResponse() error = %v", err)
+	}
+	if tag := wrapped.Tags.Find("subject"); tag != nil {
+		t.Errorf("gift wrap should not carry the subject, got %v", tag)
+	}
+	if tag := unwrap(wrapped).Tags.Find("subject"); len(tag) < 2 || tag[1] != "Order #7" {
+		t.Errorf("rumor subject tag = %v, want Order #7", tag)
+	}
+
+	wrapped, err = WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, "no subject", "", "")
+	if err != nil {
+		t.Fatalf("WrapResponse() error = %v", err)
+	}
+	if tag := unwrap(wrapped).Tags.Find("subject"); tag != nil {
+		t.Errorf("rumor should not have a subject tag, got %v", tag)
+	}
+}
+
+func TestWrapResponse_ReplyTag(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	replyToID := "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"
+
+	tests := []struct {
+		name      string
+		replyToID string
+		wantETag  bool
+	}{
+		{"with reply-to", replyToID, true},
+		{"without reply-to", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wrapped, err := WrapResponse(ctx, botKr, botPubkeyHex, recipientPubkeyHex, "threaded", tt.replyToID, "")
+			if err != nil {
+				t.Fatalf("WrapResponse() error = %v", err)
+			}
+
+			// The outer wrap must never reveal the reply target
+			if eTag := wrapped.Tags.Find("e"); eTag != nil {
+				t.Errorf("gift wrap should not carry e tag, got %v", eTag)
+			}
+
+			rumor, err := nip59.GiftUnwrap(*wrapped, func(pubkey, ciphertext string) (string, error) {
+				return recipientKr.Decrypt(ctx, ciphertext, pubkey)
+			})
+			if err != nil {
+				t.Fatalf("GiftUnwrap() error = %v", err)
+			}
+
+			eTag := rumor.Tags.Find("e")
+			if !tt.wantETag {
+				if eTag != nil {
+					t.Errorf("rumor should not have e tag, got %v", eTag)
+				}
+				return
+			}
+			if len(eTag) < 2 || eTag[1] != tt.replyToID {
+				t.Errorf("rumor e tag = %v, want %s", eTag, tt.replyToID)
+			}
+		})
+	}
+}
+
+func TestWrapLegacyResponse_ReplyTag(t *testing.T) {
+	ctx := context.Background()
+
+	kr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating keyer: %v", err)
+	}
+
+	replyToID := "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"
+
+	wrapped, err := WrapLegacyResponse(ctx, kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, "threaded", replyToID)
+	if err != nil {
+		t.Fatalf("WrapLegacyResponse() error = %v", err)
+	}
+
+	eTag := wrapped.Tags.Find("e")
+	if len(eTag) < 2 || eTag[1] != replyToID {
+		t.Errorf("e tag = %v, want %s", eTag, replyToID)
+	}
+
+	ok, err := wrapped.CheckSignature()
+	if err != nil || !ok {
+		t.Errorf("wrapped event has invalid signature: %v", err)
+	}
+
+	unthreaded, err := WrapLegacyResponse(ctx, kr, botSecretHex, botPubkeyHex, recipientPubkeyHex, "standalone", "")
+	if err != nil {
+		t.Fatalf("WrapLegacyResponse() error = %v", err)
+	}
+	if eTag := unthreaded.Tags.Find("e"); eTag != nil {
+		t.Errorf("unthreaded event should not have e tag, got %v", eTag)
+	}
+}
+
+func TestWrapNIP44Response_CanBeDecrypted(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	message := "NIP-44 payload in a kind:4 envelope"
+
+	wrapped, err := WrapNIP44Response(ctx, botKr, botPubkeyHex, recipientPubkeyHex, message, "")
+	if err != nil {
+		t.Fatalf("WrapNIP44Response() error = %v", err)
+	}
+
+	if wrapped.Kind != nostr.KindEncryptedDirectMessage {
+		t.Errorf("wrapped.Kind = %d, want %d", wrapped.Kind, nostr.KindEncryptedDirectMessage)
+	}
+
+	ok, err := wrapped.CheckSignature()
+	if err != nil || !ok {
+		t.Errorf("wrapped event has invalid signature: %v", err)
+	}
+
+	decrypted, err := recipientKr.Decrypt(ctx, wrapped.Content, botPubkeyHex)
+	if err != nil {
+		t.Fatalf("decrypting NIP-44 content: %v", err)
+	}
+	if decrypted != message {
+		t.Errorf("decrypted = %q, want %q", decrypted, message)
+	}
+
+	// NIP-04 clients must not be able to read it as NIP-04
+	sharedSecret, err := nip04.ComputeSharedSecret(botPubkeyHex, recipientSecretHex)
+	if err != nil {
+		t.Fatalf("computing shared secret: %v", err)
+	}
+	if plaintext, err := nip04.Decrypt(wrapped.Content, sharedSecret); err == nil && plaintext == message {
+		t.Error("NIP-44 content unexpectedly decrypted as NIP-04")
+	}
+}
+
+func TestDecryptLegacy_BothSchemes(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		wrap func(message string) (*nostr.Event, error)
+		want DMProtocol
+	}{
+		{
+			name: "NIP-04",
+			wrap: func(message string) (*nostr.Event, error) {
+				return WrapLegacyResponse(ctx, recipientKr, recipientSecretHex, recipientPubkeyHex, botPubkeyHex, message, "")
+			},
+			want: ProtocolNIP04,
+		},
+		{
+			name: "NIP-44",
+			wrap: func(message string) (*nostr.Event, error) {
+				return WrapNIP44Response(ctx, recipientKr, recipientPubkeyHex, botPubkeyHex, message, "")
+			},
+			want: ProtocolNIP44,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Recipient sends a kind:4 DM to the bot
+			message := "order 6"
+			event, err := tt.wrap(message)
+			if err != nil {
+				t.Fatalf("wrapping message: %v", err)
+			}
+
+			plaintext, protocol, err := DecryptLegacy(ctx, botKr, botSecretHex, event, true)
+			if err != nil {
+				t.Fatalf("DecryptLegacy() error = %v", err)
+			}
+			if plaintext != message {
+				t.Errorf("plaintext = %q, want %q", plaintext, message)
+			}
+			if protocol != tt.want {
+				t.Errorf("protocol = %d, want %d", protocol, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecryptLegacy_Garbage(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+
+	event := &nostr.Event{
+		PubKey:  recipientPubkeyHex,
+		Kind:    nostr.KindEncryptedDirectMessage,
+		Content: "not encrypted at all",
+	}
+
+	if _, _, err := DecryptLegacy(ctx, botKr, botSecretHex, event, true); err == nil {
+		t.Error("expected error decrypting garbage content")
+	}
+}
+
+func TestDecryptLegacy_NIP44Disabled(t *testing.T) {
+	ctx := context.Background()
+
+	botKr, err := keyer.NewPlainKeySigner(botSecretHex)
+	if err != nil {
+		t.Fatalf("creating bot keyer: %v", err)
+	}
+	recipientKr, err := keyer.NewPlainKeySigner(recipientSecretHex)
+	if err != nil {
+		t.Fatalf("creating recipient keyer: %v", err)
+	}
+
+	event, err := WrapNIP44Response(ctx, recipientKr, recipientPubkeyHex, botPubkeyHex, "order 6", "")
+	if err != nil {
+		t.Fatalf("wrapping message: %v", err)
+	}
+	if _, _, err := DecryptLegacy(ctx, botKr, botSecretHex, event, false); err == nil {
+		t.Error("NIP-44 content must not decrypt when NIP-44 is disabled")
+	}
+
+	event, err = WrapLegacyResponse(ctx, recipientKr, recipientSecretHex, recipientPubkeyHex, botPubkeyHex, "order 6", "")
+	if err != nil {
+		t.Fatalf("wrapping message: %v", err)
+	}
+	if _, protocol, err := DecryptLegacy(ctx, botKr, botSecretHex, event, false); err != nil || protocol != ProtocolNIP04 {
+		t.Errorf("NIP-04 should still decrypt, got protocol %d, error %v", protocol, err)
+	}
+}
@@ -2,11 +2,31 @@ package fsm
 
 import (
 	"context"
+	"slices"
 	"sync"
 
 	"github.com/looplab/fsm"
 )
 
+// orderEvents are the order transitions, shared by the state machine and
+// InferOrderEvent so the two can't disagree.
+var orderEvents = fsm.Events{
+	{Name: OrderEventPay, Src: []string{OrderStatePending}, Dst: OrderStatePaid},
+	{Name: OrderEventCancel, Src: []string{OrderStatePending}, Dst: OrderStateCancelled},
+	{Name: OrderEventFulfill, Src: []string{OrderStatePaid}, Dst: OrderStateFulfilled},
+}
+
+// InferOrderEvent returns the event that moves an order from one status to
+// another, or "" if no event does.
+func InferOrderEvent(from, to string) string {
+	for _, e := range orderEvents {
+		if e.Dst == to && slices.Contains(e.Src, from) {
+			return e.Name
+		}
+	}
+	return ""
+}
+
 type OrderStateMachine struct {
 	fsm *fsm.FSM
 	mu  sync.Mutex
@@ -16,11 +36,7 @@ func NewOrderStateMachine() *OrderStateMachine {
 	osm := &OrderStateMachine{}
 	osm.fsm = fsm.NewFSM(
 		OrderStatePending,
-		fsm.Events{
-			{Name: OrderEventPay, Src: []string{OrderStatePending}, Dst: OrderStatePaid},
-			{Name: OrderEventCancel, Src: []string{OrderStatePending}, Dst: OrderStateCancelled},
-			{Name: OrderEventFulfill, Src: []string{OrderStatePaid}, Dst: OrderStateFulfilled},
-		},
+		orderEvents,
 		fsm.Callbacks{},
 	)
 	return osm
@@ -228,3 +228,38 @@ func TestOrderStateMachine_UnknownEvent(t *testing.T) {
 		t.Errorf("expected UnknownEventError, got %T: %v", err, err)
 	}
 }
+
+func TestInferOrderEvent(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     string
+	}{
+		{OrderStatePending, OrderStatePaid, OrderEventPay},
+		{OrderStatePending, OrderStateCancelled, OrderEventCancel},
+		{OrderStatePaid, OrderStateFulfilled, OrderEventFulfill},
+		{OrderStatePending, OrderStateFulfilled, ""},
+		{OrderStatePending, OrderStatePending, ""},
+		{OrderStatePaid, OrderStatePending, ""},
+		{OrderStatePaid, OrderStateCancelled, ""},
+		{OrderStateFulfilled, OrderStatePaid, ""},
+		{OrderStateCancelled, OrderStatePending, ""},
+		{"unknown", OrderStatePaid, ""},
+		{OrderStatePending, "unknown", ""},
+	}
+
+	osm := NewOrderStateMachine()
+	for _, tt := range tests {
+		t.Run(tt.from+"_"+tt.to, func(t *testing.T) {
+			got := InferOrderEvent(tt.from, tt.to)
+			if got != tt.want {
+				t.Fatalf("InferOrderEvent(%s, %s) = %q, want %q", tt.from, tt.to, got, tt.want)
+			}
+			// The inferred event is one the state machine takes to the same status
+			if got != "" {
+				if state, err := osm.Transition(context.Background(), tt.from, got); err != nil || state != tt.to {
+					t.Errorf("Transition(%s, %s) = %s, %v; want %s", tt.from, got, state, err, tt.to)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+// Package health serves the /healthz endpoint used by uptime monitors.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/httpserver"
+)
+
+// checkTimeout bounds how long a single health request may spend on the database.
+const checkTimeout = 2 * time.Second
+
+// Probes report the state of the bot's dependencies.
+type Probes struct {
+	ConnectedRelays func() int                      // Relays with an open, ping-answering connection
+	LastRelayEvent  func() time.Time                // When any relay last delivered an event
+	DuplicateEvents func() int64                    // Copies of events dropped because another relay delivered them first
+	LastProcessed   func() time.Time                // When the run loop last processed a new event
+	Database        func(ctx context.Context) error // Cheap query; nil means reachable
+}
+
+// Report is the JSON body returned by /healthz.
+type Report struct {
+	Status   string         `json:"status"` // "ok" or "unhealthy"
+	Relays   RelayReport    `json:"relays"`
+	Database DatabaseReport `json:"database"`
+	// Seconds since the last processed event; omitted if none yet
+	LastProcessedSecondsAgo *int64 `json:"last_processed_seconds_ago,omitempty"`
+}
+
+// RelayReport describes relay connectivity.
+type RelayReport struct {
+	OK                  bool   `json:"ok"`
+	Connected           int    `json:"connected"`
+	LastEventSecondsAgo *int64 `json:"last_event_seconds_ago,omitempty"`
+	MaxSilenceSeconds   int64  `json:"max_silence_seconds"`
+	DuplicateEvents     int64  `json:"duplicate_events"`
+	Error               string `json:"error,omitempty"`
+}
+
+// DatabaseReport describes database reachability.
+type DatabaseReport struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// Handler answers health checks. Relays are healthy if at least one is
+// connected or an event arrived within maxSilence.
+type Handler struct {
+	probes     Probes
+	maxSilence time.Duration
+	now        func() time.Time
+}
+
+// NewHandler creates a health check handler.
+func NewHandler(probes Probes, maxSilence time.Duration) *Handler {
+	return &Handler{
+		probes:     probes,
+		maxSilence: maxSilence,
+		now:        time.Now,
+	}
+}
+
+// Check runs every probe and returns the combined report.
+func (h *Handler) Check(ctx context.Context) Report {
+	now := h.now()
+	report := Report{
+		Relays: RelayReport{
+			Connected:         h.probes.ConnectedRelays(),
+			MaxSilenceSeconds: int64(h.maxSilence.Seconds()),
+			DuplicateEvents:   h.probes.DuplicateEvents(),
+		},
+	}
+
+	lastEvent := h.probes.LastRelayEvent()
+	if !lastEvent.IsZero() {
+		report.Relays.LastEventSecondsAgo = secondsSince(now, lastEvent)
+	}
+	recentEvent := !lastEvent.IsZero() && now.Sub(lastEvent) <= h.maxSilence
+	report.Relays.OK = report.Relays.Connected > 0 || recentEvent
+	if !report.Relays.OK {
+		report.Relays.Error = fmt.Sprintf("no relay connected and no event for %s", h.maxSilence)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	if err := h.probes.Database(ctx); err != nil {
+		report.Database.Error = err.Error()
+	} else {
+		report.Database.OK = true
+	}
+
+	if last := h.probes.LastProcessed(); !last.IsZero() {
+		report.LastProcessedSecondsAgo = secondsSince(now, last)
+	}
+
+	report.Status = "ok"
+	if !report.Relays.OK || !report.Database.OK {
+		report.Status = "unhealthy"
+	}
+	return report
+}
+
+// ServeHTTP writes the report as JSON with 200 when healthy and 503 otherwise.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	report := h.Check(r.Context())
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// secondsSince returns the whole seconds between t and now.
+func secondsSince(now, t time.Time) *int64 {
+	secs := int64(now.Sub(t).Seconds())
+	return &secs
+}
+
+// Start listens on addr and serves /healthz in the background.
+// Listen errors are returned so a bad address fails startup.
+func Start(addr string, handler http.Handler) (*httpserver.Server, error) {
+	return httpserver.Start("health", addr, "GET /healthz", handler)
+}
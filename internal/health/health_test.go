@@ -0,0 +1,182 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// healthyProbes returns probes for a bot with one connected relay and a working database.
+func healthyProbes() Probes {
+	return Probes{
+		ConnectedRelays: func() int { return 1 },
+		LastRelayEvent:  func() time.Time { return testNow.Add(-30 * time.Second) },
+		DuplicateEvents: func() int64 { return 7 },
+		LastProcessed:   func() time.Time { return testNow.Add(-45 * time.Second) },
+		Database:        func(context.Context) error { return nil },
+	}
+}
+
+func serve(t *testing.T, probes Probes) (*http.Response, Report) {
+	t.Helper()
+	h := NewHandler(probes, 10*time.Minute)
+	h.now = func() time.Time { return testNow }
+
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var report Report
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		t.Fatalf("decoding body: %v", err)
+	}
+	return resp, report
+}
+
+func TestHandler_Healthy(t *testing.T) {
+	resp, report := serve(t, healthyProbes())
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	if report.Status != "ok" || !report.Relays.OK || !report.Database.OK {
+		t.Errorf("report = %+v, want all ok", report)
+	}
+	if report.Relays.Connected != 1 {
+		t.Errorf("connected = %d, want 1", report.Relays.Connected)
+	}
+	if report.Relays.DuplicateEvents != 7 {
+		t.Errorf("duplicate_events = %d, want 7", report.Relays.DuplicateEvents)
+	}
+	if report.LastProcessedSecondsAgo == nil || *report.LastProcessedSecondsAgo != 45 {
+		t.Errorf("last_processed_seconds_ago = %v, want 45", report.LastProcessedSecondsAgo)
+	}
+}
+
+func TestHandler_Unhealthy(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(*Probes)
+		wantRelays bool
+		wantDB     bool
+	}{
+		{
+			name: "relays disconnected but recent event",
+			modify: func(p *Probes) {
+				p.ConnectedRelays = func() int { return 0 }
+			},
+			wantRelays: true,
+			wantDB:     true,
+		},
+		{
+			name: "relays disconnected and silent",
+			modify: func(p *Probes) {
+				p.ConnectedRelays = func() int { return 0 }
+				p.LastRelayEvent = func() time.Time { return testNow.Add(-time.Hour) }
+			},
+			wantRelays: false,
+			wantDB:     true,
+		},
+		{
+			name: "relays disconnected and never received an event",
+			modify: func(p *Probes) {
+				p.ConnectedRelays = func() int { return 0 }
+				p.LastRelayEvent = func() time.Time { return time.Time{} }
+			},
+			wantRelays: false,
+			wantDB:     true,
+		},
+		{
+			name: "database locked",
+			modify: func(p *Probes) {
+				p.Database = func(context.Context) error { return errors.New("database is locked") }
+			},
+			wantRelays: true,
+			wantDB:     false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probes := healthyProbes()
+			tt.modify(&probes)
+			resp, report := serve(t, probes)
+
+			wantStatus := http.StatusOK
+			if !tt.wantRelays || !tt.wantDB {
+				wantStatus = http.StatusServiceUnavailable
+			}
+			if resp.StatusCode != wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, wantStatus)
+			}
+			if report.Relays.OK != tt.wantRelays {
+				t.Errorf("relays ok = %v, want %v (%+v)", report.Relays.OK, tt.wantRelays, report.Relays)
+			}
+			if report.Database.OK != tt.wantDB {
+				t.Errorf("database ok = %v, want %v (%+v)", report.Database.OK, tt.wantDB, report.Database)
+			}
+			if !tt.wantDB && report.Database.Error != "database is locked" {
+				t.Errorf("database error = %q, want the probe error", report.Database.Error)
+			}
+		})
+	}
+}
+
+func TestHandler_NoEventsYet(t *testing.T) {
+	probes := healthyProbes()
+	probes.LastProcessed = func() time.Time { return time.Time{} }
+	resp, report := serve(t, probes)
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200 (a quiet bot is not unhealthy)", resp.StatusCode)
+	}
+	if report.LastProcessedSecondsAgo != nil {
+		t.Errorf("last_processed_seconds_ago = %d, want omitted", *report.LastProcessedSecondsAgo)
+	}
+}
+
+func TestServer_StartAndClose(t *testing.T) {
+	srv, err := Start("127.0.0.1:0", NewHandler(healthyProbes(), 10*time.Minute))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + srv.Addr() + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+
+	resp, err = http.Get("http://" + srv.Addr() + "/other")
+	if err != nil {
+		t.Fatalf("GET /other: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status for /other = %d, want 404", resp.StatusCode)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := http.Get("http://" + srv.Addr() + "/healthz"); err == nil {
+		t.Error("expected request to fail after Close")
+	}
+}
@@ -0,0 +1,59 @@
+// Package httpserver runs the bot's small HTTP endpoints, such as /healthz
+// and /metrics, in the background.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// shutdownTimeout bounds how long Close waits for in-flight requests.
+const shutdownTimeout = 5 * time.Second
+
+// Server serves one endpoint until closed.
+type Server struct {
+	srv      *http.Server
+	listener net.Listener
+}
+
+// Start listens on addr and serves handler at pattern, e.g. "GET /healthz",
+// in the background. name labels the server in logs. Listen errors are
+// returned so a bad address fails startup.
+func Start(name, addr, pattern string, handler http.Handler) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s: %w", addr, err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+	s := &Server{
+		srv:      &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second},
+		listener: listener,
+	}
+
+	go func() {
+		if err := s.srv.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(name+" server stopped", "error", err)
+		}
+	}()
+	slog.Info(name+" endpoint listening", "addr", listener.Addr().String())
+	return s, nil
+}
+
+// Addr returns the address the server is listening on.
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close shuts the server down, waiting briefly for in-flight requests.
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.srv.Shutdown(ctx)
+}
@@ -0,0 +1,169 @@
+package lightning
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// defaultInvoiceExpiry applies when an invoice carries no expiry ("x") field.
+const defaultInvoiceExpiry = time.Hour
+
+// BOLT11 tagged field types (5-bit values).
+const (
+	bolt11TagPaymentHash = 1  // p
+	bolt11TagExpiry      = 6  // x
+	bolt11TagDescription = 13 // d
+)
+
+// bolt11SignatureWords is the length of the trailing signature in 5-bit words
+// (64-byte signature plus recovery ID = 520 bits).
+const bolt11SignatureWords = 104
+
+// bolt11TimestampWords is the length of the leading timestamp in 5-bit words.
+const bolt11TimestampWords = 7
+
+// Bolt11Invoice holds the fields of a decoded BOLT11 payment request.
+type Bolt11Invoice struct {
+	AmountMsats int64         // 0 for "any amount" invoices
+	Description string        // Empty when only a description hash is present
+	PaymentHash string        // Hex-encoded
+	Expiry      time.Duration // Defaults to one hour when unset
+	CreatedAt   time.Time
+}
+
+// ExpiresAt returns when the invoice stops being payable.
+func (inv *Bolt11Invoice) ExpiresAt() time.Time {
+	return inv.CreatedAt.Add(inv.Expiry)
+}
+
+// DecodeBolt11 parses a BOLT11 invoice. The bech32 checksum is verified but
+// the node signature is not; callers trust the source the invoice came from
+// (a signed zap receipt or our own LNURL provider).
+func DecodeBolt11(invoice string) (*Bolt11Invoice, error) {
+	hrp, data, err := bech32.DecodeNoLimit(strings.TrimPrefix(strings.ToLower(invoice), "lightning:"))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBolt11, err)
+	}
+
+	amountMsats, err := parseBolt11Amount(hrp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBolt11, err)
+	}
+
+	if len(data) < bolt11TimestampWords+bolt11SignatureWords {
+		return nil, fmt.Errorf("%w: data too short", ErrInvalidBolt11)
+	}
+	fields := data[:len(data)-bolt11SignatureWords]
+
+	inv := &Bolt11Invoice{
+		AmountMsats: amountMsats,
+		Expiry:      defaultInvoiceExpiry,
+		CreatedAt:   time.Unix(int64(wordsToUint(fields[:bolt11TimestampWords])), 0),
+	}
+
+	fields = fields[bolt11TimestampWords:]
+	for len(fields) > 0 {
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("%w: truncated tagged field", ErrInvalidBolt11)
+		}
+		tag := fields[0]
+		length := int(wordsToUint(fields[1:3]))
+		if len(fields) < 3+length {
+			return nil, fmt.Errorf("%w: tagged field %d overruns data", ErrInvalidBolt11, tag)
+		}
+		value := fields[3 : 3+length]
+		fields = fields[3+length:]
+
+		switch tag {
+		case bolt11TagPaymentHash:
+			// Readers must skip p fields of the wrong length
+			if length != 52 {
+				continue
+			}
+			hash, err := bech32.ConvertBits(value, 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("%w: payment hash: %v", ErrInvalidBolt11, err)
+			}
+			inv.PaymentHash = hex.EncodeToString(hash)
+		case bolt11TagDescription:
+			desc, err := bech32.ConvertBits(value, 5, 8, false)
+			if err != nil {
+				return nil, fmt.Errorf("%w: description: %v", ErrInvalidBolt11, err)
+			}
+			inv.Description = string(desc)
+		case bolt11TagExpiry:
+			inv.Expiry = time.Duration(wordsToUint(value)) * time.Second
+		}
+	}
+
+	if inv.PaymentHash == "" {
+		return nil, fmt.Errorf("%w: missing payment hash", ErrInvalidBolt11)
+	}
+
+	return inv, nil
+}
+
+// parseBolt11Amount extracts the amount in millisats from the invoice's
+// human-readable part: ln<network>[<amount><multiplier>].
+// Multipliers: m = milli (10^-3), u = micro (10^-6), n = nano (10^-9), p = pico (10^-12)
+func parseBolt11Amount(hrp string) (int64, error) {
+	var amountPart string
+	switch {
+	case strings.HasPrefix(hrp, "lnbcrt"):
+		amountPart = hrp[6:]
+	case strings.HasPrefix(hrp, "lntbs"): // signet
+		amountPart = hrp[5:]
+	case strings.HasPrefix(hrp, "lnbc"), strings.HasPrefix(hrp, "lntb"):
+		amountPart = hrp[4:]
+	default:
+		return 0, fmt.Errorf("unrecognized invoice prefix")
+	}
+
+	// Amountless invoice
+	if amountPart == "" {
+		return 0, nil
+	}
+
+	// No multiplier means the amount is in whole BTC
+	numStr := amountPart
+	var msatsPerUnit, divisor int64 = 100_000_000_000, 1
+	if last := amountPart[len(amountPart)-1]; last < '0' || last > '9' {
+		numStr = amountPart[:len(amountPart)-1]
+		switch last {
+		case 'm': // milli-BTC = 100,000 sats
+			msatsPerUnit = 100_000_000
+		case 'u': // micro-BTC = 100 sats
+			msatsPerUnit = 100_000
+		case 'n': // nano-BTC = 0.1 sat
+			msatsPerUnit = 100
+		case 'p': // pico-BTC = 0.1 msat
+			msatsPerUnit, divisor = 1, 10
+		default:
+			return 0, fmt.Errorf("unknown multiplier: %c", last)
+		}
+	}
+
+	amount, err := strconv.ParseInt(numStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount number: %v", err)
+	}
+	if amount%divisor != 0 {
+		return 0, fmt.Errorf("pico amount %dp is not a whole millisat", amount)
+	}
+
+	return amount * msatsPerUnit / divisor, nil
+}
+
+// wordsToUint interprets big-endian 5-bit words as an unsigned integer.
+func wordsToUint(words []byte) uint64 {
+	var n uint64
+	for _, w := range words {
+		n = n<<5 | uint64(w)
+	}
+	return n
+}
@@ -0,0 +1,163 @@
+package lightning
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/btcsuite/btcd/btcutil/bech32"
+)
+
+// BOLT11 specification test vectors (payment hash 0001020304...0102,
+// timestamp 1496314658).
+const (
+	specInvoiceCoffee   = "lnbc2500u1pvjluezsp5zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zyg3zygspp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdq5xysxxatsyp3k7enxv4jsxqzpu9qrsgquk0rl77nj30yxdy8j9vdx85fkpmdla2087ne0xh8nhedh8w27kyke0lp53ut353s06fv3qfegext0eh0ymjpf39tuven09sam30g4vgpfna3rh"
+	specInvoiceDonation = "lnbc1pvjluezpp5qqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqqqsyqcyq5rqwzqfqypqdpl2pkx2ctnv5sxxmmwwd5kgetjypeh2ursdae8g6twvus8g6rfwvs8qun0dfjkxaq8rkx3yf5tcsyz3d73gafnh3cax9rn449d9p5uxz9ezhhypd0elx87sjle52x86fux2ypatgddc6k63n7erqz25le42c4u4ecky03ylcqca784w"
+	specPaymentHash     = "0001020304050607080900010203040506070809000102030405060708090102"
+)
+
+// encodeTestInvoice builds a checksum-valid invoice with the given HRP and a
+// zeroed signature. Only the fields DecodeBolt11 reads are populated.
+func encodeTestInvoice(t *testing.T, hrp string, createdAt int64) string {
+	t.Helper()
+
+	words := make([]byte, 0, bolt11TimestampWords+55+bolt11SignatureWords)
+	for i := bolt11TimestampWords - 1; i >= 0; i-- {
+		words = append(words, byte(createdAt>>(5*i))&31)
+	}
+
+	hash := make([]byte, 32)
+	hashWords, err := bech32.ConvertBits(hash, 8, 5, true)
+	if err != nil {
+		t.Fatalf("converting payment hash: %v", err)
+	}
+	words = append(words, bolt11TagPaymentHash, byte(len(hashWords)>>5), byte(len(hashWords)&31))
+	words = append(words, hashWords...)
+	words = append(words, make([]byte, bolt11SignatureWords)...)
+
+	invoice, err := bech32.Encode(hrp, words)
+	if err != nil {
+		t.Fatalf("encoding invoice: %v", err)
+	}
+	return invoice
+}
+
+func TestDecodeBolt11_SpecVectors(t *testing.T) {
+	tests := []struct {
+		name            string
+		invoice         string
+		wantMsats       int64
+		wantDescription string
+		wantExpiry      time.Duration
+	}{
+		{
+			name:            "coffee with expiry",
+			invoice:         specInvoiceCoffee,
+			wantMsats:       250_000_000, // 2500u = 250,000 sats
+			wantDescription: "1 cup coffee",
+			wantExpiry:      time.Minute,
+		},
+		{
+			name:            "amountless donation",
+			invoice:         specInvoiceDonation,
+			wantMsats:       0,
+			wantDescription: "Please consider supporting this project",
+			wantExpiry:      time.Hour,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := DecodeBolt11(tt.invoice)
+			if err != nil {
+				t.Fatalf("DecodeBolt11() error = %v", err)
+			}
+			if inv.AmountMsats != tt.wantMsats {
+				t.Errorf("AmountMsats = %d, want %d", inv.AmountMsats, tt.wantMsats)
+			}
+			if inv.Description != tt.wantDescription {
+				t.Errorf("Description = %q, want %q", inv.Description, tt.wantDescription)
+			}
+			if inv.PaymentHash != specPaymentHash {
+				t.Errorf("PaymentHash = %s, want %s", inv.PaymentHash, specPaymentHash)
+			}
+			if inv.Expiry != tt.wantExpiry {
+				t.Errorf("Expiry = %v, want %v", inv.Expiry, tt.wantExpiry)
+			}
+			if inv.CreatedAt.Unix() != 1496314658 {
+				t.Errorf("CreatedAt = %d, want 1496314658", inv.CreatedAt.Unix())
+			}
+			if want := inv.CreatedAt.Add(tt.wantExpiry); !inv.ExpiresAt().Equal(want) {
+				t.Errorf("ExpiresAt() = %v, want %v", inv.ExpiresAt(), want)
+			}
+		})
+	}
+}
+
+func TestDecodeBolt11_Amounts(t *testing.T) {
+	// Same amounts the zap validation tests rely on
+	tests := []struct {
+		name      string
+		hrp       string
+		wantMsats int64
+	}{
+		{"100 sats (100u)", "lnbc100u", 10_000_000},
+		{"1 milli-BTC (1m) = 100,000 sats", "lnbc1m", 100_000_000},
+		{"21 sats (21000n)", "lnbc21000n", 2_100_000},
+		{"10000 sats (10000u)", "lnbc10000u", 1_000_000_000},
+		{"1000 sats (10u)", "lnbc10u", 1_000_000},
+		{"testnet invoice", "lntb500u", 50_000_000},
+		{"signet invoice", "lntbs500u", 50_000_000},
+		{"regtest invoice", "lnbcrt2500u", 250_000_000},
+		{"pico amount", "lnbc10p", 1},
+		{"whole BTC", "lnbc2", 200_000_000_000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inv, err := DecodeBolt11(encodeTestInvoice(t, tt.hrp, 1700000000))
+			if err != nil {
+				t.Fatalf("DecodeBolt11() error = %v", err)
+			}
+			if inv.AmountMsats != tt.wantMsats {
+				t.Errorf("AmountMsats = %d, want %d", inv.AmountMsats, tt.wantMsats)
+			}
+			if inv.CreatedAt.Unix() != 1700000000 {
+				t.Errorf("CreatedAt = %d, want 1700000000", inv.CreatedAt.Unix())
+			}
+		})
+	}
+}
+
+func TestDecodeBolt11_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		invoice string
+	}{
+		{"empty", ""},
+		{"bad checksum", "lnbc100u1pnxyzabc"},
+		{"invalid prefix", encodeTestInvoice(t, "lnxx100u", 1700000000)},
+		{"unknown multiplier", encodeTestInvoice(t, "lnbc100x", 1700000000)},
+		{"fractional pico", encodeTestInvoice(t, "lnbc15p", 1700000000)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := DecodeBolt11(tt.invoice)
+			if !errors.Is(err, ErrInvalidBolt11) {
+				t.Errorf("DecodeBolt11() error = %v, want ErrInvalidBolt11", err)
+			}
+		})
+	}
+}
+
+func TestDecodeBolt11_CaseAndScheme(t *testing.T) {
+	upper := "LIGHTNING:" + specInvoiceCoffee
+	inv, err := DecodeBolt11(upper)
+	if err != nil {
+		t.Fatalf("DecodeBolt11() error = %v", err)
+	}
+	if inv.AmountMsats != 250_000_000 {
+		t.Errorf("AmountMsats = %d, want 250000000", inv.AmountMsats)
+	}
+}
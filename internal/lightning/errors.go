@@ -1,6 +1,11 @@
 package lightning
 
-import "errors"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
 
 // ErrLNURLMetadataFetch indicates failure to fetch LNURL-pay metadata.
 var ErrLNURLMetadataFetch = errors.New("failed to fetch LNURL metadata")
@@ -13,3 +18,36 @@ var ErrInvoiceAmountOutOfRange = errors.New("amount outside LNURL pay range")
 
 // ErrInvalidLightningAddress indicates the lightning address format is invalid.
 var ErrInvalidLightningAddress = errors.New("invalid lightning address format")
+
+// ErrInvalidBolt11 indicates a BOLT11 invoice could not be decoded.
+var ErrInvalidBolt11 = errors.New("invalid bolt11 invoice")
+
+// ErrInvoiceAmountMismatch indicates the provider returned an invoice for a different amount than requested.
+var ErrInvoiceAmountMismatch = errors.New("invoice amount does not match request")
+
+// LNURLError is the error body LNURL services return, e.g. {"status":"ERROR","reason":"..."}.
+// It is wrapped in ErrLNURLMetadataFetch or ErrLNURLInvoiceRequest; use errors.As to get the reason.
+type LNURLError struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+func (e *LNURLError) Error() string {
+	return fmt.Sprintf("LNURL service error: %s", e.Reason)
+}
+
+// maxLNURLErrorBody caps how much of an error response is read.
+const maxLNURLErrorBody = 64 * 1024
+
+// parseLNURLError decodes an LNURL error body. Returns nil if the body is not
+// a JSON error with a reason.
+func parseLNURLError(body io.Reader) *LNURLError {
+	var lnErr LNURLError
+	if err := json.NewDecoder(io.LimitReader(body, maxLNURLErrorBody)).Decode(&lnErr); err != nil {
+		return nil
+	}
+	if lnErr.Reason == "" {
+		return nil
+	}
+	return &lnErr
+}
@@ -3,29 +3,77 @@ package lightning
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultMetadataCacheTTL is how long LNURL-pay metadata is reused before refetching.
+const DefaultMetadataCacheTTL = 5 * time.Minute
+
 // Client handles LNURL-pay operations for generating bolt11 invoices.
 type Client struct {
 	httpClient *http.Client
+
+	// Metadata cache keyed by lightning address
+	metaCache sync.Map // string -> cachedMeta
+	cacheTTL  time.Duration
+	now       func() time.Time
+
+	// Reject invoices whose amount differs from the one requested
+	verifyInvoiceAmount bool
 }
 
-// NewClient creates a new LNURL-pay client with reasonable defaults.
-func NewClient() *Client {
-	return &Client{
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
+// cachedMeta is a metadata cache entry.
+type cachedMeta struct {
+	meta      *LNURLPayMetadata
+	expiresAt time.Time
+}
+
+// ClientOption configures a Client.
+type ClientOption func(*Client)
+
+// WithCacheTTL sets how long fetched metadata is cached. Zero disables caching.
+func WithCacheTTL(d time.Duration) ClientOption {
+	return func(c *Client) {
+		c.cacheTTL = d
+	}
+}
+
+// WithInvoiceAmountVerification controls whether invoices returned by the
+// LNURL callback are decoded and checked against the requested amount.
+// Enabled by default so a rogue provider can't bill the wrong amount.
+func WithInvoiceAmountVerification(verify bool) ClientOption {
+	return func(c *Client) {
+		c.verifyInvoiceAmount = verify
 	}
 }
 
+// NewClient creates a new LNURL-pay client with reasonable defaults.
+func NewClient(opts ...ClientOption) *Client {
+	return NewClientWithHTTP(&http.Client{
+		Timeout: 10 * time.Second,
+	}, opts...)
+}
+
 // NewClientWithHTTP creates a client with a custom http.Client (for testing).
-func NewClientWithHTTP(c *http.Client) *Client {
-	return &Client{httpClient: c}
+func NewClientWithHTTP(c *http.Client, opts ...ClientOption) *Client {
+	client := &Client{
+		httpClient: c,
+		cacheTTL:   DefaultMetadataCacheTTL,
+		now:        time.Now,
+
+		verifyInvoiceAmount: true,
+	}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
 }
 
 // LNURLPayMetadata contains response from LNURL-pay well-known endpoint.
@@ -45,7 +93,17 @@ type InvoiceResponse struct {
 
 // FetchMetadata retrieves LNURL-pay metadata for a lightning address.
 // lightningAddress format: "user@domain.com"
+// Successful lookups are cached for the client's cache TTL.
 func (c *Client) FetchMetadata(ctx context.Context, lightningAddress string) (*LNURLPayMetadata, error) {
+	if entry, ok := c.metaCache.Load(lightningAddress); ok {
+		cached := entry.(cachedMeta)
+		if c.now().Before(cached.expiresAt) {
+			meta := *cached.meta
+			return &meta, nil
+		}
+		c.metaCache.Delete(lightningAddress)
+	}
+
 	// Parse lightning address: user@domain -> https://domain/.well-known/lnurlp/user
 	parts := strings.SplitN(lightningAddress, "@", 2)
 	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
@@ -67,6 +125,9 @@ func (c *Client) FetchMetadata(ctx context.Context, lightningAddress string) (*L
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
+		if lnErr := parseLNURLError(resp.Body); lnErr != nil {
+			return nil, fmt.Errorf("%w: HTTP %d: %w", ErrLNURLMetadataFetch, resp.StatusCode, lnErr)
+		}
 		return nil, fmt.Errorf("%w: HTTP %d", ErrLNURLMetadataFetch, resp.StatusCode)
 	}
 
@@ -79,13 +140,23 @@ func (c *Client) FetchMetadata(ctx context.Context, lightningAddress string) (*L
 		return nil, fmt.Errorf("%w: missing callback URL", ErrLNURLMetadataFetch)
 	}
 
+	if c.cacheTTL > 0 {
+		cached := meta
+		c.metaCache.Store(lightningAddress, cachedMeta{
+			meta:      &cached,
+			expiresAt: c.now().Add(c.cacheTTL),
+		})
+	}
+
 	return &meta, nil
 }
 
 // RequestInvoice requests a bolt11 invoice for the given amount.
 // amountSats is the invoice amount in satoshis.
+// comment is attached to the payment (LUD-12) if the payee allows comments;
+// it is truncated to the allowed length and omitted otherwise.
 // Returns the bolt11 invoice string (e.g., "lnbc32000n1...").
-func (c *Client) RequestInvoice(ctx context.Context, lightningAddress string, amountSats int64) (string, error) {
+func (c *Client) RequestInvoice(ctx context.Context, lightningAddress string, amountSats int64, comment string) (string, error) {
 	meta, err := c.FetchMetadata(ctx, lightningAddress)
 	if err != nil {
 		return "", err
@@ -108,12 +179,7 @@ func (c *Client) RequestInvoice(ctx context.Context, lightningAddress string, am
 	}
 
 	// Request invoice from callback URL
-	// Callback URL may already have query params, so we need to handle that
-	separator := "?"
-	if strings.Contains(meta.Callback, "?") {
-		separator = "&"
-	}
-	callbackURL := fmt.Sprintf("%s%samount=%d", meta.Callback, separator, amountMsats)
+	callbackURL := buildCallbackURL(meta.Callback, amountMsats, comment, meta.CommentAllowed)
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, callbackURL, nil)
 	if err != nil {
@@ -127,6 +193,9 @@ func (c *Client) RequestInvoice(ctx context.Context, lightningAddress string, am
 	defer func() { _ = resp.Body.Close() }()
 
 	if resp.StatusCode != http.StatusOK {
+		if lnErr := parseLNURLError(resp.Body); lnErr != nil {
+			return "", fmt.Errorf("%w: HTTP %d: %w", ErrLNURLInvoiceRequest, resp.StatusCode, lnErr)
+		}
 		return "", fmt.Errorf("%w: HTTP %d", ErrLNURLInvoiceRequest, resp.StatusCode)
 	}
 
@@ -139,5 +208,62 @@ func (c *Client) RequestInvoice(ctx context.Context, lightningAddress string, am
 		return "", fmt.Errorf("%w: empty invoice returned", ErrLNURLInvoiceRequest)
 	}
 
+	if c.verifyInvoiceAmount {
+		invoice, err := DecodeBolt11(invoiceResp.PR)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrLNURLInvoiceRequest, err)
+		}
+		if invoice.AmountMsats != amountMsats {
+			return "", fmt.Errorf("%w: requested %d msats, invoice is for %d msats",
+				ErrInvoiceAmountMismatch, amountMsats, invoice.AmountMsats)
+		}
+	}
+
 	return invoiceResp.PR, nil
 }
+
+// buildCallbackURL appends the amount (and comment, when allowed) to an
+// LNURL-pay callback. The callback may already carry query params.
+func buildCallbackURL(callback string, amountMsats int64, comment string, commentAllowed int) string {
+	separator := "?"
+	if strings.Contains(callback, "?") {
+		separator = "&"
+	}
+	callbackURL := fmt.Sprintf("%s%samount=%d", callback, separator, amountMsats)
+
+	if commentAllowed > 0 && comment != "" {
+		if runes := []rune(comment); len(runes) > commentAllowed {
+			comment = string(runes[:commentAllowed])
+		}
+		callbackURL += "&comment=" + url.QueryEscape(comment)
+	}
+
+	return callbackURL
+}
+
+// RequestInvoiceWithFallback requests an invoice from each lightning address in
+// order, returning the first that succeeds. Failures are logged and the next
+// address is tried; if every address fails the individual errors are joined.
+func (c *Client) RequestInvoiceWithFallback(ctx context.Context, addresses []string, amountSats int64, comment string) (string, error) {
+	if len(addresses) == 0 {
+		return "", fmt.Errorf("%w: no lightning addresses configured", ErrInvalidLightningAddress)
+	}
+
+	var errs []error
+	for i, address := range addresses {
+		invoice, err := c.RequestInvoice(ctx, address, amountSats, comment)
+		if err != nil {
+			slog.Warn("invoice request failed", "address", address, "error", err)
+			errs = append(errs, fmt.Errorf("%s: %w", address, err))
+			continue
+		}
+		if i > 0 {
+			slog.Info("invoice generated via fallback address", "address", address)
+		} else {
+			slog.Info("invoice generated", "address", address)
+		}
+		return invoice, nil
+	}
+
+	return "", errors.Join(errs...)
+}
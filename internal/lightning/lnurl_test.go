@@ -7,7 +7,9 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestFetchMetadata_Success(t *testing.T) {
@@ -158,6 +160,82 @@ func TestRequestInvoice_HTTPError(t *testing.T) {
 	})
 }
 
+func TestFetchMetadata_LNURLErrorBody(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"status":"ERROR","reason":"Unable to find user eggs"}`))
+	}))
+	defer server.Close()
+
+	client := NewClientWithHTTP(server.Client())
+	address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := client.FetchMetadata(context.Background(), address)
+	if !errors.Is(err, ErrLNURLMetadataFetch) {
+		t.Fatalf("expected ErrLNURLMetadataFetch, got %v", err)
+	}
+
+	var lnErr *LNURLError
+	if !errors.As(err, &lnErr) {
+		t.Fatalf("expected *LNURLError in chain, got %v", err)
+	}
+	if lnErr.Status != "ERROR" || lnErr.Reason != "Unable to find user eggs" {
+		t.Errorf("LNURLError = %+v", lnErr)
+	}
+	if !strings.Contains(err.Error(), "HTTP 400") {
+		t.Errorf("error %q should include the status code", err)
+	}
+}
+
+func TestFetchMetadata_NonJSONErrorBody(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+		_, _ = w.Write([]byte("<html>Bad Gateway</html>"))
+	}))
+	defer server.Close()
+
+	client := NewClientWithHTTP(server.Client())
+	address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := client.FetchMetadata(context.Background(), address)
+	if !errors.Is(err, ErrLNURLMetadataFetch) {
+		t.Fatalf("expected ErrLNURLMetadataFetch, got %v", err)
+	}
+	var lnErr *LNURLError
+	if errors.As(err, &lnErr) {
+		t.Errorf("expected no LNURLError for non-JSON body, got %+v", lnErr)
+	}
+}
+
+func TestRequestInvoice_LNURLErrorBody(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, ".well-known/lnurlp") {
+			_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+				Callback:    "https://" + r.Host + "/callback",
+				MinSendable: 1000,
+				MaxSendable: 100000000000,
+			})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(LNURLError{Status: "ERROR", Reason: "Amount is below minimum"})
+	}))
+	defer server.Close()
+
+	client := NewClientWithHTTP(server.Client())
+	address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := client.RequestInvoice(context.Background(), address, 3200, "")
+	if !errors.Is(err, ErrLNURLInvoiceRequest) {
+		t.Fatalf("expected ErrLNURLInvoiceRequest, got %v", err)
+	}
+	var lnErr *LNURLError
+	if !errors.As(err, &lnErr) || lnErr.Reason != "Amount is below minimum" {
+		t.Errorf("expected LNURLError reason, got %v", err)
+	}
+}
+
 func TestRequestInvoice_EmptyInvoice(t *testing.T) {
 	// Verify we handle empty invoice responses
 	resp := InvoiceResponse{PR: ""}
@@ -169,32 +247,54 @@ func TestRequestInvoice_EmptyInvoice(t *testing.T) {
 
 func TestCallbackURLConstruction(t *testing.T) {
 	tests := []struct {
-		name       string
-		callback   string
-		amount     int64
-		expected   string
+		name           string
+		callback       string
+		comment        string
+		commentAllowed int
+		expected       string
 	}{
 		{
 			name:     "no existing params",
 			callback: "https://example.com/callback",
-			amount:   1000000,
 			expected: "https://example.com/callback?amount=1000000",
 		},
 		{
 			name:     "existing params",
 			callback: "https://example.com/callback?foo=bar",
-			amount:   1000000,
 			expected: "https://example.com/callback?foo=bar&amount=1000000",
 		},
+		{
+			name:           "comment url-encoded",
+			callback:       "https://example.com/callback",
+			comment:        "Order #42 & more",
+			commentAllowed: 140,
+			expected:       "https://example.com/callback?amount=1000000&comment=Order+%2342+%26+more",
+		},
+		{
+			name:           "comment omitted when not allowed",
+			callback:       "https://example.com/callback",
+			comment:        "Order #42",
+			commentAllowed: 0,
+			expected:       "https://example.com/callback?amount=1000000",
+		},
+		{
+			name:           "comment truncated to allowed length",
+			callback:       "https://example.com/callback",
+			comment:        "Order #42",
+			commentAllowed: 5,
+			expected:       "https://example.com/callback?amount=1000000&comment=Order",
+		},
+		{
+			name:           "empty comment omitted",
+			callback:       "https://example.com/callback",
+			commentAllowed: 140,
+			expected:       "https://example.com/callback?amount=1000000",
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			separator := "?"
-			if strings.Contains(tt.callback, "?") {
-				separator = "&"
-			}
-			result := tt.callback + separator + "amount=" + "1000000"
+			result := buildCallbackURL(tt.callback, 1000000, tt.comment, tt.commentAllowed)
 			if result != tt.expected {
 				t.Errorf("expected %s, got %s", tt.expected, result)
 			}
@@ -202,6 +302,58 @@ func TestCallbackURLConstruction(t *testing.T) {
 	}
 }
 
+func TestRequestInvoice_Comment(t *testing.T) {
+	tests := []struct {
+		name           string
+		commentAllowed int
+		wantComment    string
+	}{
+		{"comment allowed", 140, "Order #42"},
+		{"comment not allowed", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotComment string
+			var gotRawQuery string
+			invoice := encodeTestInvoice(t, "lnbc32u", time.Now().Unix())
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.Contains(r.URL.Path, ".well-known/lnurlp"):
+					_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+						Callback:       "https://" + r.Host + "/callback",
+						MinSendable:    1000,
+						MaxSendable:    100000000000,
+						CommentAllowed: tt.commentAllowed,
+					})
+				case strings.Contains(r.URL.Path, "callback"):
+					gotComment = r.URL.Query().Get("comment")
+					gotRawQuery = r.URL.RawQuery
+					_ = json.NewEncoder(w).Encode(InvoiceResponse{PR: invoice})
+				}
+			}))
+			defer server.Close()
+
+			client := NewClientWithHTTP(server.Client())
+			address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+			if _, err := client.RequestInvoice(context.Background(), address, 3200, "Order #42"); err != nil {
+				t.Fatalf("RequestInvoice() error = %v", err)
+			}
+
+			if gotComment != tt.wantComment {
+				t.Errorf("comment = %q, want %q", gotComment, tt.wantComment)
+			}
+			if tt.wantComment == "" && strings.Contains(gotRawQuery, "comment=") {
+				t.Errorf("comment param should be omitted, got query %q", gotRawQuery)
+			}
+			if tt.wantComment != "" && !strings.Contains(gotRawQuery, "Order+%2342") {
+				t.Errorf("comment not url-encoded in query %q", gotRawQuery)
+			}
+		})
+	}
+}
+
 func TestNewClient(t *testing.T) {
 	client := NewClient()
 	if client == nil {
@@ -222,3 +374,228 @@ func TestNewClientWithHTTP(t *testing.T) {
 		t.Error("custom client not used")
 	}
 }
+
+func TestNewClient_DefaultCacheTTL(t *testing.T) {
+	client := NewClient()
+	if client.cacheTTL != DefaultMetadataCacheTTL {
+		t.Errorf("expected default cache TTL %v, got %v", DefaultMetadataCacheTTL, client.cacheTTL)
+	}
+
+	client = NewClient(WithCacheTTL(time.Minute))
+	if client.cacheTTL != time.Minute {
+		t.Errorf("expected cache TTL 1m, got %v", client.cacheTTL)
+	}
+}
+
+// newMetadataServer starts a TLS server serving LNURL-pay metadata and
+// returns a lightning address pointing at it plus a request counter.
+func newMetadataServer(t *testing.T) (*httptest.Server, string, *atomic.Int32) {
+	t.Helper()
+	var hits atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+			Callback:    "https://" + r.Host + "/callback",
+			MinSendable: 1000,
+			MaxSendable: 100000000000,
+			Tag:         "payRequest",
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	address := "testuser@" + strings.TrimPrefix(server.URL, "https://")
+	return server, address, &hits
+}
+
+func TestFetchMetadata_CachedWithinTTL(t *testing.T) {
+	server, address, hits := newMetadataServer(t)
+	client := NewClientWithHTTP(server.Client())
+	ctx := context.Background()
+
+	first, err := client.FetchMetadata(ctx, address)
+	if err != nil {
+		t.Fatalf("FetchMetadata() error = %v", err)
+	}
+	second, err := client.FetchMetadata(ctx, address)
+	if err != nil {
+		t.Fatalf("FetchMetadata() second call error = %v", err)
+	}
+
+	if got := hits.Load(); got != 1 {
+		t.Errorf("expected 1 HTTP request, got %d", got)
+	}
+	if second.Callback != first.Callback {
+		t.Errorf("cached callback = %s, want %s", second.Callback, first.Callback)
+	}
+}
+
+func TestFetchMetadata_RefetchAfterTTL(t *testing.T) {
+	server, address, hits := newMetadataServer(t)
+	client := NewClientWithHTTP(server.Client(), WithCacheTTL(time.Minute))
+	ctx := context.Background()
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	if _, err := client.FetchMetadata(ctx, address); err != nil {
+		t.Fatalf("FetchMetadata() error = %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := client.FetchMetadata(ctx, address); err != nil {
+		t.Fatalf("FetchMetadata() after expiry error = %v", err)
+	}
+
+	if got := hits.Load(); got != 2 {
+		t.Errorf("expected 2 HTTP requests after TTL expiry, got %d", got)
+	}
+}
+
+func TestFetchMetadata_CacheDisabled(t *testing.T) {
+	server, address, hits := newMetadataServer(t)
+	client := NewClientWithHTTP(server.Client(), WithCacheTTL(0))
+	ctx := context.Background()
+
+	for range 2 {
+		if _, err := client.FetchMetadata(ctx, address); err != nil {
+			t.Fatalf("FetchMetadata() error = %v", err)
+		}
+	}
+
+	if got := hits.Load(); got != 2 {
+		t.Errorf("expected 2 HTTP requests with caching disabled, got %d", got)
+	}
+}
+
+func TestRequestInvoiceWithFallback(t *testing.T) {
+	down := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	expectedInvoice := encodeTestInvoice(t, "lnbc32u", time.Now().Unix()) // 3200 sats
+	up := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, ".well-known/lnurlp"):
+			_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+				Callback:    "https://" + r.Host + "/callback",
+				MinSendable: 1000,
+				MaxSendable: 100000000000,
+			})
+		case strings.Contains(r.URL.Path, "callback"):
+			_ = json.NewEncoder(w).Encode(InvoiceResponse{PR: expectedInvoice})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer up.Close()
+
+	// Both servers share the same test CA, so one client trusts both
+	client := NewClientWithHTTP(up.Client())
+	ctx := context.Background()
+
+	downAddress := "eggs@" + strings.TrimPrefix(down.URL, "https://")
+	upAddress := "eggs@" + strings.TrimPrefix(up.URL, "https://")
+
+	t.Run("falls back to second address", func(t *testing.T) {
+		invoice, err := client.RequestInvoiceWithFallback(ctx, []string{downAddress, upAddress}, 3200, "")
+		if err != nil {
+			t.Fatalf("RequestInvoiceWithFallback() error = %v", err)
+		}
+		if invoice != expectedInvoice {
+			t.Errorf("invoice = %s, want %s", invoice, expectedInvoice)
+		}
+	})
+
+	t.Run("all addresses fail", func(t *testing.T) {
+		_, err := client.RequestInvoiceWithFallback(ctx, []string{downAddress, "not-an-address"}, 3200, "")
+		if !errors.Is(err, ErrLNURLMetadataFetch) {
+			t.Errorf("expected ErrLNURLMetadataFetch in joined error, got: %v", err)
+		}
+		if !errors.Is(err, ErrInvalidLightningAddress) {
+			t.Errorf("expected ErrInvalidLightningAddress in joined error, got: %v", err)
+		}
+	})
+
+	t.Run("no addresses", func(t *testing.T) {
+		_, err := client.RequestInvoiceWithFallback(ctx, nil, 3200, "")
+		if !errors.Is(err, ErrInvalidLightningAddress) {
+			t.Errorf("expected ErrInvalidLightningAddress, got: %v", err)
+		}
+	})
+}
+
+func TestRequestInvoice_VerifyAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		hrp     string // invoice returned by the provider
+		verify  bool
+		wantErr error
+	}{
+		{"matching amount", "lnbc32u", true, nil},
+		{"under amount", "lnbc31u", true, ErrInvoiceAmountMismatch},
+		{"over amount", "lnbc320u", true, ErrInvoiceAmountMismatch},
+		{"amountless invoice", "lnbc", true, ErrInvoiceAmountMismatch},
+		{"mismatch ignored when disabled", "lnbc31u", false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			invoice := encodeTestInvoice(t, tt.hrp, time.Now().Unix())
+			server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case strings.Contains(r.URL.Path, ".well-known/lnurlp"):
+					_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+						Callback:    "https://" + r.Host + "/callback",
+						MinSendable: 1000,
+						MaxSendable: 100000000000,
+					})
+				case strings.Contains(r.URL.Path, "callback"):
+					_ = json.NewEncoder(w).Encode(InvoiceResponse{PR: invoice})
+				}
+			}))
+			defer server.Close()
+
+			client := NewClientWithHTTP(server.Client(), WithInvoiceAmountVerification(tt.verify))
+			address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+			got, err := client.RequestInvoice(context.Background(), address, 3200, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("RequestInvoice() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RequestInvoice() error = %v", err)
+			}
+			if got != invoice {
+				t.Errorf("invoice = %s, want %s", got, invoice)
+			}
+		})
+	}
+}
+
+func TestRequestInvoice_UndecodableInvoice(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, ".well-known/lnurlp"):
+			_ = json.NewEncoder(w).Encode(LNURLPayMetadata{
+				Callback:    "https://" + r.Host + "/callback",
+				MinSendable: 1000,
+				MaxSendable: 100000000000,
+			})
+		case strings.Contains(r.URL.Path, "callback"):
+			_ = json.NewEncoder(w).Encode(InvoiceResponse{PR: "lnbc32u1pjnotaninvoice"})
+		}
+	}))
+	defer server.Close()
+
+	client := NewClientWithHTTP(server.Client())
+	address := "eggs@" + strings.TrimPrefix(server.URL, "https://")
+
+	_, err := client.RequestInvoice(context.Background(), address, 3200, "")
+	if !errors.Is(err, ErrLNURLInvoiceRequest) {
+		t.Errorf("expected ErrLNURLInvoiceRequest, got: %v", err)
+	}
+}
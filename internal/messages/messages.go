@@ -0,0 +1,570 @@
+// Package messages renders the texts customers see from named templates.
+// Every message has built-in English text, translations for the other
+// supported languages, and can be overridden from a YAML file to change the
+// tone or add the farm's name.
+package messages
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Message names, as used for keys in the overrides file.
+const (
+	InventoryAvailable    = "inventory_available"    // InventoryData
+	OrderCreated          = "order_created"          // OrderCreatedData
+	OrderSubject          = "order_subject"          // OrderData; title of the order's NIP-17 conversation
+	InsufficientInventory = "insufficient_inventory" // InsufficientInventoryData
+	WaitlistOffer         = "waitlist_offer"         // WaitlistData; added below insufficient_inventory
+	OrderCancelled        = "order_cancelled"        // OrderData
+	OrderPaid             = "order_paid"             // OrderPaidData
+	InventoryAlert        = "inventory_alert"        // InventoryData
+	NotifySubscribed      = "notify_subscribed"      // NotifyData
+	NotifyStatus          = "notify_status"          // NotifyData; "notify" with a subscription in place
+	NotifyCancelled       = "notify_cancelled"       // no data
+	Balance               = "balance"                // BalanceData
+	OrderHistory          = "order_history"          // HistoryData
+	HistoryOrder          = "history_order"          // HistoryOrderData; one line of order_history
+	HistoryEmpty          = "history_empty"          // no data
+	PageOutOfRange        = "page_out_of_range"      // PageData
+	Usage                 = "usage"                  // UsageData
+	ErrorReply            = "error_reply"            // ErrorData; any command that fails
+	MentionAck            = "mention_ack"            // no data
+	ContactSent           = "contact_sent"           // no data
+	UnrecognizedForwarded = "unrecognized_forwarded" // no data; commands.forward_unrecognized
+	BroadcastFooter       = "broadcast_footer"       // BroadcastData; empty adds no footer
+	LanguageSet           = "language_set"           // LanguageData
+	LanguageStatus        = "language_status"        // LanguageStatusData; "lang" without arguments
+	LanguageUnknown       = "language_unknown"       // LanguageUnknownData
+	ZapCredited           = "zap_credited"           // ZapCreditedData; a zap that didn't pay an order
+	ZapNotCredited        = "zap_not_credited"       // ZapData; a zap from someone who isn't a customer
+	UnknownCommand        = "unknown_command"        // CommandData
+	NotRegistered         = "not_registered"         // no data; any command from someone who isn't a customer
+	AdminRequired         = "admin_required"         // no data; an admin command sent by a customer
+	AdminAccessRequired   = "admin_access_required"  // no data; an admin subcommand, e.g. "inventory add" (shown in error_reply)
+	PermissionsUnchecked  = "permissions_unchecked"  // no data
+	UnpaidOrderPending    = "unpaid_order_pending"   // PendingOrdersData (shown in error_reply)
+	InvalidOrderID        = "invalid_order_id"       // no data (shown in error_reply)
+	OrderNotFound         = "order_not_found"        // OrderData (shown in error_reply)
+	NotYourOrder          = "not_your_order"         // no data (shown in error_reply)
+	CannotCancel          = "cannot_cancel"          // OrderStatusData (shown in error_reply)
+	ContactTooLong        = "contact_too_long"       // ContactLimitData (shown in error_reply)
+	ContactRateLimited    = "contact_rate_limited"   // ContactLimitData (shown in error_reply)
+	InfoTopics            = "info_topics"            // InfoTopicsData; "info" without arguments
+	InfoUnknownTopic      = "info_unknown_topic"     // InfoTopicData (shown in error_reply)
+	HelpOverview          = "help_overview"          // HelpData; "help" without arguments
+	HelpPrices            = "help_prices"            // PricesData; the order sizes in "help order"
+	HelpUnknownTopic      = "help_unknown_topic"     // CommandData (shown in error_reply)
+)
+
+// InventoryData is the egg count shown to customers.
+type InventoryData struct {
+	Available int
+}
+
+// OrderCreatedData describes a newly placed order and how to pay for it.
+// Invoice and BotNpub are empty when that way to pay isn't offered.
+type OrderCreatedData struct {
+	OrderID   int64
+	Quantity  int
+	TotalSats int64
+	Invoice   string
+	BotNpub   string
+}
+
+// InsufficientInventoryData is an order larger than the eggs available.
+type InsufficientInventoryData struct {
+	Available int
+	Requested int
+}
+
+// WaitlistData is the order size a customer can join the waitlist for and
+// the eggs in stock now.
+type WaitlistData struct {
+	Quantity  int
+	Available int
+}
+
+// OrderData identifies an order.
+type OrderData struct {
+	OrderID int64
+}
+
+// OrderPaidData is a payment that settled an order.
+type OrderPaidData struct {
+	AmountSats int64
+	OrderID    int64
+}
+
+// NotifyData is the egg count a customer asked to be told about.
+type NotifyData struct {
+	Threshold int
+}
+
+// BalanceData is what a customer has paid, spent and owes.
+type BalanceData struct {
+	Received        int64
+	Spent           int64
+	Balance         int64
+	PendingReserved int64 // Owed for orders awaiting payment
+}
+
+// HistoryData is one page of a customer's orders, most recent first. Lines
+// are the orders rendered with history_order.
+type HistoryData struct {
+	Page     int
+	Pages    int
+	Total    int
+	NextPage int
+	Lines    []string
+}
+
+// HistoryOrderData is one order in a customer's history. Placed is the
+// date and Age how long ago that was, e.g. "2d". For a pending order that
+// will expire, ExpiresIn is how long it has left, or Expiring is set once
+// its deadline has passed.
+type HistoryOrderData struct {
+	OrderID   int64
+	Quantity  int
+	TotalSats int64
+	Status    string
+	Placed    string
+	Age       string
+	ExpiresIn string
+	Expiring  bool
+}
+
+// PageData is a page number past the last page of total orders.
+type PageData struct {
+	Page  int
+	Pages int
+	Total int
+}
+
+// UsageData is how a command is used, e.g. "cancel <order_id>".
+type UsageData struct {
+	Usage string
+}
+
+// ErrorData is why a command failed.
+type ErrorData struct {
+	Error string
+}
+
+// BroadcastData is available to the footer added to admin broadcasts.
+type BroadcastData struct {
+	BotNpub string
+}
+
+// LanguageData is the language a customer chose for replies.
+type LanguageData struct {
+	Code string
+	Name string
+}
+
+// LanguageStatusData is the language a customer gets replies in and the
+// ones they can choose from, e.g. "de (Deutsch), en (English)". Default is
+// set when they haven't chosen one.
+type LanguageStatusData struct {
+	Code      string
+	Name      string
+	Default   bool
+	Available string
+}
+
+// LanguageUnknownData is a language code that isn't supported.
+type LanguageUnknownData struct {
+	Requested string
+	Available string
+}
+
+// ZapCreditedData is a zap credited to a customer's balance without paying
+// an order. PendingOrders is how many orders await payment; when the
+// oldest isn't covered yet, Balance and NeededSats are the customer's
+// balance and its price. Unchecked is set when the orders couldn't be
+// looked up.
+type ZapCreditedData struct {
+	AmountSats    int64
+	PendingOrders int
+	Balance       int64
+	NeededSats    int64
+	Unchecked     bool
+}
+
+// ZapData is a zap that wasn't credited and who sent it.
+type ZapData struct {
+	SenderNpub string
+	AmountSats int64
+}
+
+// CommandData names the command a customer sent.
+type CommandData struct {
+	Name string
+}
+
+// PendingOrdersData is how many of a customer's orders await payment.
+type PendingOrdersData struct {
+	Count int
+}
+
+// OrderStatusData is an order and its status, e.g. "paid".
+type OrderStatusData struct {
+	OrderID int64
+	Status  string
+}
+
+// ContactLimitData is a message of Length characters and the limit it
+// broke: characters per message, or messages an hour.
+type ContactLimitData struct {
+	Length int
+	Limit  int
+}
+
+// InfoTopicsData is the FAQ topics customers can ask about, e.g.
+// "pickup, washed"; empty when there are none.
+type InfoTopicsData struct {
+	Topics string
+}
+
+// InfoTopicData is a topic that isn't in the FAQ and the ones that are.
+type InfoTopicData struct {
+	Topic  string
+	Topics string
+}
+
+// HelpData is the "help" overview. Commands and AdminCommands are the
+// summaries of the commands the sender can use, e.g. "cancel <order_id> -
+// Cancel a pending order"; AdminCommands is empty for customers. Available
+// is the egg count, shown when InventoryKnown.
+type HelpData struct {
+	Commands       []string
+	AdminCommands  []string
+	Available      int
+	InventoryKnown bool
+}
+
+// PricesData is what each order size costs.
+type PricesData struct {
+	Prices []PriceData
+}
+
+// PriceData is the price of one order size. Last is set on the final size,
+// which is joined to the others with "or".
+type PriceData struct {
+	Quantity  int
+	TotalSats int64
+	Last      bool
+}
+
+// samples holds zero data of the type each message is rendered with, so
+// templates can be checked when they are loaded.
+var samples = map[string]any{
+	InventoryAvailable:    InventoryData{},
+	OrderCreated:          OrderCreatedData{},
+	OrderSubject:          OrderData{},
+	InsufficientInventory: InsufficientInventoryData{},
+	WaitlistOffer:         WaitlistData{},
+	OrderCancelled:        OrderData{},
+	OrderPaid:             OrderPaidData{},
+	InventoryAlert:        InventoryData{},
+	NotifySubscribed:      NotifyData{},
+	NotifyStatus:          NotifyData{},
+	NotifyCancelled:       nil,
+	Balance:               BalanceData{},
+	OrderHistory:          HistoryData{},
+	HistoryOrder:          HistoryOrderData{},
+	HistoryEmpty:          nil,
+	PageOutOfRange:        PageData{},
+	Usage:                 UsageData{},
+	ErrorReply:            ErrorData{},
+	MentionAck:            nil,
+	ContactSent:           nil,
+	UnrecognizedForwarded: nil,
+	BroadcastFooter:       BroadcastData{},
+	LanguageSet:           LanguageData{},
+	LanguageStatus:        LanguageStatusData{},
+	LanguageUnknown:       LanguageUnknownData{},
+	ZapCredited:           ZapCreditedData{},
+	ZapNotCredited:        ZapData{},
+	UnknownCommand:        CommandData{},
+	NotRegistered:         nil,
+	AdminRequired:         nil,
+	AdminAccessRequired:   nil,
+	PermissionsUnchecked:  nil,
+	UnpaidOrderPending:    PendingOrdersData{},
+	InvalidOrderID:        nil,
+	OrderNotFound:         OrderData{},
+	NotYourOrder:          nil,
+	CannotCancel:          OrderStatusData{},
+	ContactTooLong:        ContactLimitData{},
+	ContactRateLimited:    ContactLimitData{},
+	InfoTopics:            InfoTopicsData{},
+	InfoUnknownTopic:      InfoTopicData{},
+	HelpOverview:          HelpData{},
+	HelpPrices:            PricesData{},
+	HelpUnknownTopic:      CommandData{},
+}
+
+// DefaultLanguage is the language of the built-in messages, which every
+// other language falls back to for messages it doesn't translate.
+const DefaultLanguage = "en"
+
+// locale is how a language is named and writes dates and amounts.
+type locale struct {
+	name       string // The language's own name for itself
+	dateFormat string // Go time layout for dates in DMs
+	thousands  string // Digit group separator for amounts; empty leaves them ungrouped
+}
+
+// locales holds every supported language by its ISO 639-1 code.
+var locales = map[string]locale{
+	"en": {name: "English", dateFormat: "Jan 2 15:04"},
+	"de": {name: "Deutsch", dateFormat: "2.1. 15:04", thousands: "."},
+	"es": {name: "Español", dateFormat: "2/1 15:04", thousands: "."},
+}
+
+// Languages returns the codes of the supported languages, sorted.
+func Languages() []string {
+	return slices.Sorted(maps.Keys(locales))
+}
+
+// Supported reports whether lang is the code of a supported language.
+func Supported(lang string) bool {
+	_, ok := locales[lang]
+	return ok
+}
+
+// LanguageName returns the supported language's name for itself, e.g.
+// "Deutsch" for "de".
+func LanguageName(lang string) string {
+	return locales[lang].name
+}
+
+// English is in defaults.yaml, other languages in locales/<code>.yaml.
+//
+//go:embed defaults.yaml locales/*.yaml
+var builtin embed.FS
+
+// defaults is the built-in catalog every other catalog starts from.
+var defaults = mustParseBuiltin()
+
+func mustParseBuiltin() *Catalog {
+	c := &Catalog{lang: DefaultLanguage, languages: make(map[string]map[string]*template.Template, len(locales))}
+	for lang := range locales {
+		file := "locales/" + lang + ".yaml"
+		if lang == DefaultLanguage {
+			file = "defaults.yaml"
+		}
+		data, err := builtin.ReadFile(file)
+		if err != nil {
+			panic(fmt.Sprintf("messages: built-in %s messages: %v", lang, err))
+		}
+		var texts map[string]string
+		if err := yaml.Unmarshal(data, &texts); err != nil {
+			panic(fmt.Sprintf("messages: built-in %s messages: %v", lang, err))
+		}
+		if c.languages[lang], err = parse(lang, texts); err != nil {
+			panic(fmt.Sprintf("messages: built-in %s messages: %v", lang, err))
+		}
+	}
+	return c
+}
+
+// Catalog holds the templates for every message in every supported language
+// and renders them in one of those languages.
+type Catalog struct {
+	lang      string                                   // Language messages are rendered in
+	languages map[string]map[string]*template.Template // Templates by language and message name
+}
+
+// Default returns the catalog of built-in messages, in English.
+func Default() *Catalog {
+	return defaults
+}
+
+// overrides are the templates in an overrides file by language and message
+// name. Top-level messages are English; other languages' messages are
+// nested under their code.
+type overrides map[string]map[string]string
+
+func (o *overrides) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: expected message names", node.Line)
+	}
+	*o = overrides{DefaultLanguage: {}}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i].Value, node.Content[i+1]
+		if Supported(key) {
+			var texts map[string]string
+			if err := value.Decode(&texts); err != nil {
+				return err
+			}
+			if (*o)[key] == nil {
+				(*o)[key] = texts
+			} else {
+				maps.Copy((*o)[key], texts)
+			}
+			continue
+		}
+		var text string
+		if err := value.Decode(&text); err != nil {
+			return err
+		}
+		(*o)[DefaultLanguage][key] = text
+	}
+	return nil
+}
+
+// Load returns the built-in messages with those in the YAML file at path
+// replacing them. The file maps message names to templates, with
+// translations nested under a language code, e.g. "de:"; unknown names,
+// templates that don't parse and templates that use fields their data
+// doesn't have are rejected.
+func Load(path string) (*Catalog, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading messages: %w", err)
+	}
+	var texts overrides
+	if err := yaml.Unmarshal(data, &texts); err != nil {
+		return nil, fmt.Errorf("%s: parsing YAML: %w", path, err)
+	}
+	c := &Catalog{lang: DefaultLanguage, languages: maps.Clone(defaults.languages)}
+	for _, lang := range slices.Sorted(maps.Keys(texts)) {
+		templates, err := parse(lang, texts[lang])
+		if err != nil {
+			if lang != DefaultLanguage {
+				err = fmt.Errorf("%s: %w", lang, err)
+			}
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		merged := maps.Clone(c.languages[lang])
+		maps.Copy(merged, templates)
+		c.languages[lang] = merged
+	}
+	return c, nil
+}
+
+// parse compiles a language's templates and checks each against its
+// message's data.
+func parse(lang string, texts map[string]string) (map[string]*template.Template, error) {
+	funcs := template.FuncMap{"num": formatNumber(locales[lang].thousands)}
+	templates := make(map[string]*template.Template, len(texts))
+	for _, name := range slices.Sorted(maps.Keys(texts)) {
+		sample, ok := samples[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown message %q", name)
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Option("missingkey=error").Parse(strings.TrimRight(texts[name], "\n"))
+		if err != nil {
+			return nil, fmt.Errorf("message %s: %w", name, err)
+		}
+		if err := tmpl.Execute(io.Discard, sample); err != nil {
+			return nil, fmt.Errorf("message %s: %w", name, err)
+		}
+		templates[name] = tmpl
+	}
+	return templates, nil
+}
+
+// formatNumber returns the "num" template function, which writes a whole
+// number with its digits grouped in threes by sep, e.g. "3.200".
+func formatNumber(sep string) func(n any) (string, error) {
+	return func(n any) (string, error) {
+		var s string
+		switch n := n.(type) {
+		case int:
+			s = strconv.Itoa(n)
+		case int64:
+			s = strconv.FormatInt(n, 10)
+		default:
+			return "", fmt.Errorf("num: %T is not a whole number", n)
+		}
+		if sep == "" {
+			return s, nil
+		}
+		digits := strings.TrimPrefix(s, "-")
+		var b strings.Builder
+		b.WriteString(s[:len(s)-len(digits)])
+		for i, d := range digits {
+			if i > 0 && (len(digits)-i)%3 == 0 {
+				b.WriteString(sep)
+			}
+			b.WriteRune(d)
+		}
+		return b.String(), nil
+	}
+}
+
+// In returns the catalog rendering messages in lang. An unsupported lang
+// leaves the language unchanged.
+func (c *Catalog) In(lang string) *Catalog {
+	if c == nil {
+		c = defaults
+	}
+	if lang == c.lang || !Supported(lang) {
+		return c
+	}
+	in := *c
+	in.lang = lang
+	return &in
+}
+
+// Language returns the code of the language messages are rendered in.
+func (c *Catalog) Language() string {
+	if c == nil {
+		return DefaultLanguage
+	}
+	return c.lang
+}
+
+// DateFormat returns the Go time layout dates are shown with in the
+// catalog's language.
+func (c *Catalog) DateFormat() string {
+	return locales[c.Language()].dateFormat
+}
+
+// template returns the named message's template in the catalog's language,
+// or in English if the language has none.
+func (c *Catalog) template(name string) (*template.Template, bool) {
+	if tmpl, ok := c.languages[c.lang][name]; ok {
+		return tmpl, true
+	}
+	tmpl, ok := c.languages[DefaultLanguage][name]
+	return tmpl, ok
+}
+
+// Render returns the named message for data in the catalog's language,
+// falling back to English if the language has no text for it. A nil
+// catalog renders the built-in English messages. A template that fails is
+// logged and the built-in message is used instead.
+func (c *Catalog) Render(name string, data any) string {
+	if c == nil {
+		c = defaults
+	}
+	tmpl, ok := c.template(name)
+	if !ok {
+		slog.Error("unknown message", "message", name)
+		return ""
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		slog.Error("rendering message failed", "message", name, "language", c.lang, "error", err)
+		builtin := defaults.In(c.lang)
+		if fallback, _ := builtin.template(name); fallback != tmpl {
+			return builtin.Render(name, data)
+		}
+		return ""
+	}
+	return b.String()
+}
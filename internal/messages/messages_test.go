@@ -0,0 +1,354 @@
+package messages
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+// messageConsts maps the message name constants declared in messages.go's
+// "Message names" block to their values, so a constant added without a
+// default template fails here.
+func messageConsts(t *testing.T) map[string]string {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "messages.go", nil, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("parsing messages.go: %v", err)
+	}
+	consts := make(map[string]string)
+	for _, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.CONST || !strings.HasPrefix(gen.Doc.Text(), "Message names") {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			spec := spec.(*ast.ValueSpec)
+			for i, value := range spec.Values {
+				if lit, ok := value.(*ast.BasicLit); ok && lit.Kind == token.STRING {
+					name, _ := strconv.Unquote(lit.Value)
+					consts[spec.Names[i].Name] = name
+				}
+			}
+		}
+	}
+	if len(consts) == 0 {
+		t.Fatal("no message names found in messages.go")
+	}
+	return consts
+}
+
+// messageNames returns the names of every message.
+func messageNames(t *testing.T) []string {
+	t.Helper()
+	return slices.Sorted(maps.Values(messageConsts(t)))
+}
+
+// renderedMessages returns the names of the messages rendered by the code
+// outside this package. A Render call not passed one of the message name
+// constants fails the test, since its name couldn't be checked.
+func renderedMessages(t *testing.T) map[string]bool {
+	t.Helper()
+	consts := messageConsts(t)
+	rendered := make(map[string]bool)
+	err := filepath.WalkDir("..", func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == "messages" {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if fn, ok := call.Fun.(*ast.SelectorExpr); !ok || fn.Sel.Name != "Render" || len(call.Args) == 0 {
+				return true
+			}
+			name, ok := "", false
+			if arg, isSel := call.Args[0].(*ast.SelectorExpr); isSel {
+				if pkg, isIdent := arg.X.(*ast.Ident); isIdent && pkg.Name == "messages" {
+					name, ok = consts[arg.Sel.Name]
+				}
+			}
+			if !ok {
+				t.Errorf("%s: Render is not passed a message name constant", fset.Position(call.Pos()))
+				return true
+			}
+			rendered[name] = true
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("reading the source tree: %v", err)
+	}
+	return rendered
+}
+
+func TestDefaults_CoverEveryMessage(t *testing.T) {
+	names := messageNames(t)
+	english := Default().languages[DefaultLanguage]
+	for _, name := range names {
+		if _, ok := english[name]; !ok {
+			t.Errorf("message %q has no default template", name)
+		}
+		if _, ok := samples[name]; !ok {
+			t.Errorf("message %q has no sample data", name)
+		}
+	}
+	if len(english) != len(names) || len(samples) != len(names) {
+		t.Errorf("%d defaults and %d samples for %d message names", len(english), len(samples), len(names))
+	}
+}
+
+func TestDefaults_EveryMessageRendered(t *testing.T) {
+	rendered := renderedMessages(t)
+	for _, name := range messageNames(t) {
+		if !rendered[name] {
+			t.Errorf("message %q is never rendered", name)
+		}
+	}
+}
+
+func TestTranslations_CoverEveryMessage(t *testing.T) {
+	for _, lang := range Languages() {
+		if LanguageName(lang) == "" || Default().In(lang).DateFormat() == "" {
+			t.Errorf("language %s has no name or date format", lang)
+		}
+		for _, name := range messageNames(t) {
+			// The footer is the operator's to write, in whatever language they like
+			if _, ok := Default().languages[lang][name]; !ok && name != BroadcastFooter {
+				t.Errorf("message %q has no %s translation", name, lang)
+			}
+		}
+	}
+}
+
+func TestRender_Translated(t *testing.T) {
+	de := Default().In("de")
+	if got, want := de.Render(OrderCreated, OrderCreatedData{OrderID: 3, Quantity: 12, TotalSats: 6400, BotNpub: "npub1bot"}),
+		"Bestellung 3: 12 Eier für 6.400 Sats reserviert.\n\nZum Bezahlen dieses Profil zappen:\nnostr:npub1bot"; got != want {
+		t.Errorf("German order confirmation = %q, want %q", got, want)
+	}
+	if got := Default().In("es").Render(OrderPaid, OrderPaidData{AmountSats: 3200, OrderID: 3}); got != "3.200 sats acreditados - ¡pedido #3 marcado como pagado!" {
+		t.Errorf("Spanish payment confirmation = %q", got)
+	}
+	if got := de.Render(BroadcastFooter, BroadcastData{}); got != "" {
+		t.Errorf("untranslated footer = %q, want the English default", got)
+	}
+	if de.Language() != "de" || Default().Language() != DefaultLanguage {
+		t.Errorf("languages = %s, %s", de.Language(), Default().Language())
+	}
+	if Default().In("fr") != Default() || Default().In(DefaultLanguage) != Default() {
+		t.Error("an unsupported or unchanged language should return the same catalog")
+	}
+}
+
+func TestRender_FallsBackToEnglish(t *testing.T) {
+	c := &Catalog{lang: "de", languages: map[string]map[string]*template.Template{
+		DefaultLanguage: Default().languages[DefaultLanguage],
+		"de":            {},
+	}}
+	if got := c.Render(OrderCancelled, OrderData{OrderID: 7}); got != "Order 7 cancelled." {
+		t.Errorf("missing translation rendered %q, want the English text", got)
+	}
+}
+
+func TestFormatNumber(t *testing.T) {
+	tests := []struct {
+		sep  string
+		n    any
+		want string
+	}{
+		{"", int64(1234567), "1234567"},
+		{".", int64(1234567), "1.234.567"},
+		{".", 999, "999"},
+		{".", 1000, "1.000"},
+		{".", int64(-3200), "-3.200"},
+		{",", 0, "0"},
+	}
+	for _, tt := range tests {
+		if got, err := formatNumber(tt.sep)(tt.n); err != nil || got != tt.want {
+			t.Errorf("num(%v) with %q = %q, %v; want %q", tt.n, tt.sep, got, err, tt.want)
+		}
+	}
+	if _, err := formatNumber(".")("12"); err == nil {
+		t.Error("expected an error for a string")
+	}
+}
+
+func TestRender_Defaults(t *testing.T) {
+	tests := []struct {
+		name string
+		data any
+		want string
+	}{
+		{InventoryAvailable, InventoryData{Available: 0}, "No eggs available. Check back later!"},
+		{InventoryAvailable, InventoryData{Available: 1}, "1 egg available."},
+		{InventoryAvailable, InventoryData{Available: 18}, "18 eggs available."},
+		{OrderCreated, OrderCreatedData{OrderID: 3, Quantity: 6, TotalSats: 3200},
+			"Order 3: 6 eggs reserved for 3200 sats."},
+		{OrderCreated, OrderCreatedData{OrderID: 3, Quantity: 6, TotalSats: 3200, BotNpub: "npub1bot"},
+			"Order 3: 6 eggs reserved for 3200 sats.\n\nZap this profile to pay:\nnostr:npub1bot"},
+		{OrderCreated, OrderCreatedData{OrderID: 3, Quantity: 6, TotalSats: 3200, Invoice: "lnbc1", BotNpub: "npub1bot"},
+			"Order 3: 6 eggs reserved for 3200 sats.\n\nPay invoice:\nlnbc1\n\nOr zap this profile:\nnostr:npub1bot"},
+		{InsufficientInventory, InsufficientInventoryData{Available: 4, Requested: 6}, "only 4 eggs available, cannot order 6"},
+		{OrderPaid, OrderPaidData{AmountSats: 3200, OrderID: 3}, "Credited 3200 sats - order #3 marked as paid!"},
+		{NotifyCancelled, nil, "Notification cancelled."},
+		{NotifyStatus, NotifyData{Threshold: 6}, "You will be notified when 6 eggs are available.\nUse 'notify off' to cancel."},
+		{Balance, BalanceData{}, "No payments received yet."},
+		{Balance, BalanceData{Received: 5000, Spent: 3200, Balance: 1800, PendingReserved: 1100},
+			"Received: 5000 sats | Spent: 3200 sats | Balance: 1800 sats\nReserved: 1100 sats in pending orders"},
+		{OrderHistory, HistoryData{Page: 1, Pages: 1, Total: 1, Lines: []string{"• #1"}}, "Recent orders:\n• #1\n"},
+		{OrderHistory, HistoryData{Page: 1, Pages: 2, Total: 30, NextPage: 2, Lines: []string{"• #30"}},
+			"Page 1 of 2 (30 orders):\n• #30\nSend \"history 2\" for older orders."},
+		{PageOutOfRange, PageData{Page: 2, Pages: 1, Total: 3}, "Page 2 is out of range: there is only 1 page (3 orders)."},
+		{Usage, UsageData{Usage: "cancel <order_id>"}, "usage: cancel <order_id>"},
+		{ErrorReply, ErrorData{Error: "order not found"}, "Error: order not found"},
+		{BroadcastFooter, BroadcastData{BotNpub: "npub1bot"}, ""},
+		{ZapCredited, ZapCreditedData{AmountSats: 1000}, "Credited 1000 sats"},
+		{ZapCredited, ZapCreditedData{AmountSats: 1000, Unchecked: true}, "Credited 1000 sats (warning: could not check pending orders)"},
+		{ZapCredited, ZapCreditedData{AmountSats: 1000, PendingOrders: 1}, "Credited 1000 sats (has 1 pending order(s))"},
+		{ZapCredited, ZapCreditedData{AmountSats: 1000, PendingOrders: 1, Balance: 1000, NeededSats: 3200},
+			"Credited 1000 sats (balance: 1000, order needs 3200)"},
+		{ZapNotCredited, ZapData{SenderNpub: "npub1x", AmountSats: 21}, "Zap received from unknown sender npub1x (21 sats) - not credited"},
+		{UnknownCommand, CommandData{Name: "hello"}, "Unknown command: hello. Send 'help' for available commands."},
+		{UnpaidOrderPending, PendingOrdersData{Count: 1}, "you have 1 unpaid order(s) - please pay or cancel before ordering more"},
+		{OrderNotFound, OrderData{OrderID: 9}, "order 9 not found"},
+		{CannotCancel, OrderStatusData{OrderID: 3, Status: "paid"}, "order 3 cannot be cancelled (status: paid)"},
+		{ContactTooLong, ContactLimitData{Length: 501, Limit: 500}, "message is 501 characters, the limit is 500"},
+		{ContactRateLimited, ContactLimitData{Limit: 3}, "you can send 3 messages an hour, please try again later"},
+		{InfoTopics, InfoTopicsData{}, "No info topics yet."},
+		{InfoTopics, InfoTopicsData{Topics: "pickup, washed"}, "Info topics: pickup, washed\nSend \"info <topic>\" for the answer."},
+		{InfoUnknownTopic, InfoTopicData{Topic: "organic"}, `unknown topic "organic", there are no info topics yet`},
+		{InfoUnknownTopic, InfoTopicData{Topic: "organic", Topics: "pickup"}, `unknown topic "organic", available: pickup`},
+		{HelpOverview, HelpData{Commands: []string{"balance - Check your payment balance"}},
+			"Available commands:\n• balance - Check your payment balance\n\nSend \"help <command>\" for details."},
+		{HelpOverview, HelpData{Commands: []string{"a"}, AdminCommands: []string{"b"}, Available: 6, InventoryKnown: true},
+			"Available commands:\n• a\n\nCurrent inventory: 6 eggs available.\n\nAdmin commands:\n• b\n\nSend \"help <command>\" for details."},
+		{HelpPrices, PricesData{Prices: []PriceData{{Quantity: 6, TotalSats: 3200, Last: true}}}, "6 eggs for 3200 sats"},
+		{HelpPrices, PricesData{Prices: []PriceData{{Quantity: 1, TotalSats: 500}, {Quantity: 5, TotalSats: 2500}, {Quantity: 10, TotalSats: 5000, Last: true}}},
+			"1 egg for 500 sats, 5 eggs for 2500 sats or 10 eggs for 5000 sats"},
+	}
+	for _, tt := range tests {
+		if got := Default().Render(tt.name, tt.data); got != tt.want {
+			t.Errorf("Render(%s, %+v) = %q, want %q", tt.name, tt.data, got, tt.want)
+		}
+	}
+}
+
+func TestRender_NilCatalogUsesDefaults(t *testing.T) {
+	var c *Catalog
+	if got := c.Render(OrderCancelled, OrderData{OrderID: 7}); got != "Order 7 cancelled." {
+		t.Errorf("Render = %q", got)
+	}
+}
+
+func writeOverrides(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "messages.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("writing overrides: %v", err)
+	}
+	return path
+}
+
+func TestLoad_Overrides(t *testing.T) {
+	path := writeOverrides(t, `
+order_cancelled: "Bestellung {{.OrderID}} storniert."
+broadcast_footer: |
+  -- Hof Sonnenschein
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got := c.Render(OrderCancelled, OrderData{OrderID: 7}); got != "Bestellung 7 storniert." {
+		t.Errorf("overridden message = %q", got)
+	}
+	if got := c.Render(BroadcastFooter, BroadcastData{}); got != "-- Hof Sonnenschein" {
+		t.Errorf("footer = %q, want the trailing newline trimmed", got)
+	}
+	if got := c.Render(NotifyCancelled, nil); got != "Notification cancelled." {
+		t.Errorf("messages not overridden should keep the default, got %q", got)
+	}
+	// Overrides never leak into the defaults
+	if got := Default().Render(OrderCancelled, OrderData{OrderID: 7}); got != "Order 7 cancelled." {
+		t.Errorf("default changed to %q", got)
+	}
+}
+
+func TestLoad_Translations(t *testing.T) {
+	path := writeOverrides(t, `
+broadcast_footer: "-- Sunny Farm"
+de:
+  order_cancelled: "Bestellung {{.OrderID}} ist storniert, schade!"
+`)
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	de := c.In("de")
+	if got := de.Render(OrderCancelled, OrderData{OrderID: 7}); got != "Bestellung 7 ist storniert, schade!" {
+		t.Errorf("overridden translation = %q", got)
+	}
+	if got := de.Render(NotifyCancelled, nil); got != "Benachrichtigung abbestellt." {
+		t.Errorf("translation not overridden = %q, want the built-in one", got)
+	}
+	if got := de.Render(BroadcastFooter, BroadcastData{}); got != "-- Sunny Farm" {
+		t.Errorf("footer = %q, want the English override", got)
+	}
+	if got := c.Render(OrderCancelled, OrderData{OrderID: 7}); got != "Order 7 cancelled." {
+		t.Errorf("English = %q, want the default", got)
+	}
+	if got := Default().In("de").Render(OrderCancelled, OrderData{OrderID: 7}); got != "Bestellung 7 storniert." {
+		t.Errorf("built-in translation changed to %q", got)
+	}
+}
+
+func TestLoad_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{"not YAML", "order_paid: [", "parsing YAML"},
+		{"not a string", "order_paid:\n  text: hi", "parsing YAML"},
+		{"unknown message", "order_shipped: hi", `unknown message "order_shipped"`},
+		{"bad template", "order_paid: '{{.AmountSats'", "message order_paid"},
+		{"unknown field", "order_paid: '{{.Amount}} sats'", "message order_paid"},
+		{"field on message without data", "mention_ack: '{{.Name}}'", "message mention_ack"},
+		{"language not a mapping", "de: hallo", "parsing YAML"},
+		{"bad translation", "de:\n  order_paid: '{{.Amount}} Sats'", "de: message order_paid"},
+		{"unknown translated message", "es:\n  order_shipped: hola", `es: unknown message "order_shipped"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeOverrides(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
@@ -0,0 +1,109 @@
+// Package metrics serves Prometheus metrics at /metrics.
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync/atomic"
+	"time"
+
+	"github.com/buildtall-systems/eggbot/internal/httpserver"
+)
+
+// Event types counted by EventProcessed.
+const (
+	EventTypeDM      = "dm"
+	EventTypeZap     = "zap"
+	EventTypeMention = "mention"
+)
+
+// orderStatuses are always reported, as zero when no order has that status.
+var orderStatuses = []string{"pending", "paid", "fulfilled", "cancelled"}
+
+// scrapeTimeout bounds how long a single scrape may spend on the database.
+const scrapeTimeout = 2 * time.Second
+
+// Probes read the order and inventory gauges at scrape time.
+type Probes struct {
+	OrdersByStatus     func(ctx context.Context) (map[string]int, error)
+	InventoryAvailable func(ctx context.Context) (int, error)
+}
+
+// Metrics counts processed events and reports them, with the order and
+// inventory gauges, in the Prometheus text exposition format.
+type Metrics struct {
+	probes        Probes
+	dmEvents      atomic.Int64
+	zapEvents     atomic.Int64
+	mentionEvents atomic.Int64
+}
+
+// New creates metrics that read gauges from probes.
+func New(probes Probes) *Metrics {
+	return &Metrics{probes: probes}
+}
+
+// EventProcessed counts a handled relay event of the given type.
+func (m *Metrics) EventProcessed(eventType string) {
+	switch eventType {
+	case EventTypeDM:
+		m.dmEvents.Add(1)
+	case EventTypeZap:
+		m.zapEvents.Add(1)
+	case EventTypeMention:
+		m.mentionEvents.Add(1)
+	}
+}
+
+// ServeHTTP writes every metric. A gauge whose probe fails is left out so the
+// event counters are still scraped.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), scrapeTimeout)
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.write(ctx, w)
+}
+
+func (m *Metrics) write(ctx context.Context, w io.Writer) {
+	writeHeader(w, "eggbot_events_processed_total", "counter", "Relay events handled by the bot, by type.")
+	_, _ = fmt.Fprintf(w, "eggbot_events_processed_total{type=%q} %d\n", EventTypeDM, m.dmEvents.Load())
+	_, _ = fmt.Fprintf(w, "eggbot_events_processed_total{type=%q} %d\n", EventTypeZap, m.zapEvents.Load())
+	_, _ = fmt.Fprintf(w, "eggbot_events_processed_total{type=%q} %d\n", EventTypeMention, m.mentionEvents.Load())
+
+	if counts, err := m.probes.OrdersByStatus(ctx); err != nil {
+		slog.Warn("metrics: counting orders failed", "error", err)
+	} else {
+		writeHeader(w, "eggbot_orders", "gauge", "Orders in the database, by status.")
+		statuses := slices.Clone(orderStatuses)
+		for status := range counts {
+			if !slices.Contains(statuses, status) {
+				statuses = append(statuses, status)
+			}
+		}
+		for _, status := range statuses {
+			_, _ = fmt.Fprintf(w, "eggbot_orders{status=%q} %d\n", status, counts[status])
+		}
+	}
+
+	if available, err := m.probes.InventoryAvailable(ctx); err != nil {
+		slog.Warn("metrics: reading inventory failed", "error", err)
+	} else {
+		writeHeader(w, "eggbot_inventory_eggs_available", "gauge", "Eggs available to order.")
+		_, _ = fmt.Fprintf(w, "eggbot_inventory_eggs_available %d\n", available)
+	}
+}
+
+func writeHeader(w io.Writer, name, kind, help string) {
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// Start listens on addr and serves /metrics in the background.
+// Listen errors are returned so a bad address fails startup.
+func Start(addr string, handler http.Handler) (*httpserver.Server, error) {
+	return httpserver.Start("metrics", addr, "GET /metrics", handler)
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func testProbes() Probes {
+	return Probes{
+		OrdersByStatus: func(context.Context) (map[string]int, error) {
+			return map[string]int{"pending": 2, "paid": 1}, nil
+		},
+		InventoryAvailable: func(context.Context) (int, error) { return 12, nil },
+	}
+}
+
+// scrape starts a server for m and returns the /metrics response and body.
+func scrape(t *testing.T, m *Metrics) (*http.Response, string) {
+	t.Helper()
+	srv, err := Start("127.0.0.1:0", m)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { _ = srv.Close() })
+
+	resp, err := http.Get("http://" + srv.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("reading body: %v", err)
+	}
+	return resp, string(body)
+}
+
+func TestMetrics_Scrape(t *testing.T) {
+	m := New(testProbes())
+	m.EventProcessed(EventTypeDM)
+	m.EventProcessed(EventTypeDM)
+	m.EventProcessed(EventTypeZap)
+	m.EventProcessed(EventTypeMention)
+
+	resp, body := scrape(t, m)
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+
+	for _, want := range []string{
+		"# TYPE eggbot_events_processed_total counter",
+		`eggbot_events_processed_total{type="dm"} 2`,
+		`eggbot_events_processed_total{type="zap"} 1`,
+		`eggbot_events_processed_total{type="mention"} 1`,
+		`eggbot_orders{status="pending"} 2`,
+		`eggbot_orders{status="paid"} 1`,
+		`eggbot_orders{status="fulfilled"} 0`,
+		`eggbot_orders{status="cancelled"} 0`,
+		"eggbot_inventory_eggs_available 12",
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("body missing %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestMetrics_FailedProbeOmitsGauge(t *testing.T) {
+	probes := testProbes()
+	probes.InventoryAvailable = func(context.Context) (int, error) { return 0, errors.New("database is locked") }
+
+	resp, body := scrape(t, New(probes))
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want 200", resp.StatusCode)
+	}
+	if strings.Contains(body, "eggbot_inventory_eggs_available") {
+		t.Errorf("failed gauge should be omitted:\n%s", body)
+	}
+	if !strings.Contains(body, "eggbot_events_processed_total") || !strings.Contains(body, "eggbot_orders") {
+		t.Errorf("other metrics should still be reported:\n%s", body)
+	}
+}
+
+func TestServer_OnlyServesMetrics(t *testing.T) {
+	srv, err := Start("127.0.0.1:0", New(testProbes()))
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	resp, err := http.Get("http://" + srv.Addr() + "/healthz")
+	if err != nil {
+		t.Fatalf("GET /healthz: %v", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status for /healthz = %d, want 404", resp.StatusCode)
+	}
+
+	if err := srv.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if _, err := http.Get("http://" + srv.Addr() + "/metrics"); err == nil {
+		t.Error("expected request to fail after Close")
+	}
+}
@@ -0,0 +1,190 @@
+package nostr
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// EventDeduplicator remembers recently seen event IDs so an event delivered
+// by several relays is only passed on once. IDs are forgotten after the TTL,
+// and the oldest are evicted first once maxEntries are held, so memory stays
+// bounded however many distinct events arrive.
+type EventDeduplicator struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu    sync.Mutex
+	seen  map[string]*list.Element
+	order *list.List // seenEntry values, oldest first
+
+	hits atomic.Int64
+}
+
+// seenEntry is an event ID and when it was first seen.
+type seenEntry struct {
+	id     string
+	seenAt time.Time
+}
+
+// NewEventDeduplicator returns a deduplicator that remembers IDs for ttl,
+// holding at most maxEntries of them.
+func NewEventDeduplicator(ttl time.Duration, maxEntries int) *EventDeduplicator {
+	return &EventDeduplicator{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		seen:       make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// IsDuplicate reports whether id was marked seen within the TTL.
+func (d *EventDeduplicator) IsDuplicate(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.expire(d.now())
+	if _, ok := d.seen[id]; ok {
+		d.hits.Add(1)
+		return true
+	}
+	return false
+}
+
+// MarkSeen records id so later copies are reported as duplicates. Call it
+// once the event has been passed on, so a copy of a dropped event still gets
+// through.
+func (d *EventDeduplicator) MarkSeen(id string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	d.expire(now)
+	if _, ok := d.seen[id]; ok {
+		return
+	}
+	d.seen[id] = d.order.PushBack(seenEntry{id: id, seenAt: now})
+	for d.order.Len() > d.maxEntries {
+		d.remove(d.order.Front())
+	}
+}
+
+// Cleanup forgets IDs older than the TTL.
+func (d *EventDeduplicator) Cleanup() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.expire(d.now())
+}
+
+// StartCleanupLoop runs Cleanup every interval until ctx is done, so IDs are
+// forgotten even while no new events arrive.
+func (d *EventDeduplicator) StartCleanupLoop(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.Cleanup()
+			}
+		}
+	}()
+}
+
+// Len returns how many IDs are remembered.
+func (d *EventDeduplicator) Len() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.order.Len()
+}
+
+// Hits returns how many duplicates have been reported.
+func (d *EventDeduplicator) Hits() int64 {
+	return d.hits.Load()
+}
+
+// expire removes entries seen before now minus the TTL. Entries are in
+// insertion order, so it stops at the first one still fresh.
+func (d *EventDeduplicator) expire(now time.Time) {
+	cutoff := now.Add(-d.ttl)
+	for e := d.order.Front(); e != nil && !e.Value.(seenEntry).seenAt.After(cutoff); e = d.order.Front() {
+		d.remove(e)
+	}
+}
+
+func (d *EventDeduplicator) remove(e *list.Element) {
+	d.order.Remove(e)
+	delete(d.seen, e.Value.(seenEntry).id)
+}
+
+// PriorityEventMultiplexer merges a low and a high priority event stream into
+// a single output channel. When both sources have events ready, high priority
+// events are forwarded first. RelayManager uses it so events from primary
+// relays outrank those from fallback relays, and zaps outrank DMs.
+type PriorityEventMultiplexer struct {
+	low  <-chan *nostr.Event
+	high <-chan *nostr.Event
+	out  chan *nostr.Event
+}
+
+// NewPriorityEventMultiplexer starts merging low and high into an output
+// channel buffered to bufSize. The output closes once both sources close.
+func NewPriorityEventMultiplexer(low, high <-chan *nostr.Event, bufSize int) *PriorityEventMultiplexer {
+	m := &PriorityEventMultiplexer{
+		low:  low,
+		high: high,
+		out:  make(chan *nostr.Event, bufSize),
+	}
+	go m.run()
+	return m
+}
+
+// Events returns the merged, high-priority-first event channel.
+func (m *PriorityEventMultiplexer) Events() <-chan *nostr.Event {
+	return m.out
+}
+
+func (m *PriorityEventMultiplexer) run() {
+	defer close(m.out)
+
+	low, high := m.low, m.high
+	for low != nil || high != nil {
+		// Drain any ready high priority event first. A nil channel never
+		// becomes ready, so a closed high source falls through to the default.
+		select {
+		case event, ok := <-high:
+			if !ok {
+				high = nil
+				continue
+			}
+			m.out <- event
+			continue
+		default:
+		}
+
+		// Nothing pending at high priority: block on whichever source delivers next
+		select {
+		case event, ok := <-high:
+			if !ok {
+				high = nil
+				continue
+			}
+			m.out <- event
+		case event, ok := <-low:
+			if !ok {
+				low = nil
+				continue
+			}
+			m.out <- event
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+func collectEvents(t *testing.T, ch <-chan *nostr.Event) []*nostr.Event {
+	t.Helper()
+	var events []*nostr.Event
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event, ok := <-ch:
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		case <-timeout:
+			t.Fatalf("timed out waiting for output to close after %d events", len(events))
+		}
+	}
+}
+
+func TestPriorityEventMultiplexer_ZapsFirst(t *testing.T) {
+	dmSource := make(chan *nostr.Event, 3)
+	zapSource := make(chan *nostr.Event, 3)
+
+	for _, id := range []string{"dm1", "dm2", "dm3"} {
+		dmSource <- &nostr.Event{ID: id, Kind: nostr.KindGiftWrap}
+	}
+	for _, id := range []string{"zap1", "zap2", "zap3"} {
+		zapSource <- &nostr.Event{ID: id, Kind: nostr.KindZap}
+	}
+	close(dmSource)
+	close(zapSource)
+
+	mux := NewPriorityEventMultiplexer(dmSource, zapSource, 0)
+	events := collectEvents(t, mux.Events())
+
+	want := []string{"zap1", "zap2", "zap3", "dm1", "dm2", "dm3"}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d", len(events), len(want))
+	}
+	for i, event := range events {
+		if event.ID != want[i] {
+			t.Errorf("event %d = %s, want %s", i, event.ID, want[i])
+		}
+	}
+}
+
+func TestPriorityEventMultiplexer_ZapJumpsQueuedDMs(t *testing.T) {
+	dmSource := make(chan *nostr.Event, 3)
+	zapSource := make(chan *nostr.Event, 1)
+
+	for _, id := range []string{"dm1", "dm2", "dm3"} {
+		dmSource <- &nostr.Event{ID: id, Kind: nostr.KindGiftWrap}
+	}
+
+	mux := NewPriorityEventMultiplexer(dmSource, zapSource, 0)
+
+	// The first DM is already in flight; a zap arriving now must be
+	// delivered before the DMs still waiting in the source
+	first := <-mux.Events()
+	zapSource <- &nostr.Event{ID: "zap1", Kind: nostr.KindZap}
+	close(zapSource)
+	close(dmSource)
+
+	events := append([]*nostr.Event{first}, collectEvents(t, mux.Events())...)
+
+	var got []string
+	for _, event := range events {
+		got = append(got, event.ID)
+	}
+
+	// dm1 may have been selected before the zap arrived, and dm2 may
+	// already be blocked on the unbuffered output, but dm3 must follow the zap
+	zapIdx, dm3Idx := -1, -1
+	for i, id := range got {
+		switch id {
+		case "zap1":
+			zapIdx = i
+		case "dm3":
+			dm3Idx = i
+		}
+	}
+	if len(got) != 4 || zapIdx == -1 || dm3Idx == -1 {
+		t.Fatalf("got events %v, want all four", got)
+	}
+	if zapIdx > dm3Idx {
+		t.Errorf("zap delivered after queued DM: %v", got)
+	}
+}
+
+func TestPriorityEventMultiplexer_ClosesWhenSourcesClose(t *testing.T) {
+	dmSource := make(chan *nostr.Event)
+	zapSource := make(chan *nostr.Event)
+
+	mux := NewPriorityEventMultiplexer(dmSource, zapSource, 1)
+
+	close(zapSource)
+	dmSource <- &nostr.Event{ID: "dm1"}
+	close(dmSource)
+
+	events := collectEvents(t, mux.Events())
+	if len(events) != 1 || events[0].ID != "dm1" {
+		t.Errorf("got %v, want only dm1", events)
+	}
+}
+
+func newTestDeduplicator(now *time.Time, ttl time.Duration, maxEntries int) *EventDeduplicator {
+	d := NewEventDeduplicator(ttl, maxEntries)
+	d.now = func() time.Time { return *now }
+	return d
+}
+
+func TestEventDeduplicator_DropsRepeatsWithinTTL(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := newTestDeduplicator(&now, time.Minute, 100)
+
+	if d.IsDuplicate("a") {
+		t.Fatal("first sighting reported as duplicate")
+	}
+	// Not marked seen yet, e.g. because the first copy was dropped
+	if d.IsDuplicate("a") {
+		t.Fatal("unmarked ID reported as duplicate")
+	}
+	d.MarkSeen("a")
+	if !d.IsDuplicate("a") {
+		t.Fatal("repeat within TTL not reported as duplicate")
+	}
+	if d.IsDuplicate("b") {
+		t.Fatal("distinct ID reported as duplicate")
+	}
+
+	now = now.Add(time.Minute + time.Second)
+	if d.IsDuplicate("a") {
+		t.Error("ID should be forgotten after the TTL")
+	}
+	if d.Hits() != 1 {
+		t.Errorf("Hits() = %d, want 1", d.Hits())
+	}
+}
+
+func TestEventDeduplicator_EvictsOldestAtCapacity(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := newTestDeduplicator(&now, time.Hour, 2)
+
+	d.MarkSeen("a")
+	d.MarkSeen("b")
+	d.MarkSeen("c")
+
+	if d.Len() != 2 {
+		t.Errorf("Len() = %d, want 2", d.Len())
+	}
+	if !d.IsDuplicate("c") {
+		t.Error("newest ID should still be remembered")
+	}
+	if d.IsDuplicate("a") {
+		t.Error("oldest ID should have been evicted")
+	}
+}
+
+func TestEventDeduplicator_Cleanup(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := newTestDeduplicator(&now, time.Minute, 100)
+	d.MarkSeen("a")
+	now = now.Add(30 * time.Second)
+	d.MarkSeen("b")
+
+	now = now.Add(45 * time.Second)
+	d.Cleanup()
+	if d.Len() != 1 {
+		t.Errorf("Len() = %d after cleanup, want 1", d.Len())
+	}
+}
+
+func TestEventDeduplicator_BurstStaysWithinCap(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	const maxEntries = 50
+	d := newTestDeduplicator(&now, time.Hour, maxEntries)
+
+	for i := 0; i < 10*maxEntries; i++ {
+		if d.IsDuplicate(fmt.Sprintf("event-%d", i)) {
+			t.Fatalf("event-%d reported as duplicate on first sighting", i)
+		}
+		d.MarkSeen(fmt.Sprintf("event-%d", i))
+		if d.Len() > maxEntries {
+			t.Fatalf("Len() = %d after %d events, want at most %d", d.Len(), i+1, maxEntries)
+		}
+	}
+
+	// The most recent IDs survive eviction and are still deduplicated
+	for i := 10*maxEntries - maxEntries; i < 10*maxEntries; i++ {
+		if !d.IsDuplicate(fmt.Sprintf("event-%d", i)) {
+			t.Errorf("recent event-%d not reported as duplicate", i)
+		}
+	}
+	if d.Len() != maxEntries {
+		t.Errorf("Len() = %d, want %d", d.Len(), maxEntries)
+	}
+}
+
+func TestEventDeduplicator_StartCleanupLoop(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	d := newTestDeduplicator(&now, time.Minute, 100)
+	d.MarkSeen("a")
+	now = now.Add(2 * time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d.StartCleanupLoop(ctx, time.Millisecond)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for d.Len() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("cleanup loop did not forget the expired ID")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
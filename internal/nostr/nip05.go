@@ -0,0 +1,143 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip05"
+)
+
+// nip05Timeout bounds each well-known lookup.
+const nip05Timeout = 5 * time.Second
+
+// maxWellKnownBytes caps the nostr.json read from a domain.
+const maxWellKnownBytes = 64 << 10
+
+// NIP05Verifier checks NIP-05 identifiers against the nostr.json their
+// domain serves.
+type NIP05Verifier struct {
+	httpClient *http.Client
+
+	// wellKnownURL returns the lookup URL for a name at a domain; replaced in tests
+	wellKnownURL func(domain, name string) string
+}
+
+// NewNIP05Verifier creates a verifier whose lookups time out after 5
+// seconds. Redirects aren't followed, as NIP-05 requires.
+func NewNIP05Verifier() *NIP05Verifier {
+	return &NIP05Verifier{
+		httpClient: &http.Client{
+			Timeout: nip05Timeout,
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		wellKnownURL: func(domain, name string) string {
+			return fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, name)
+		},
+	}
+}
+
+// Verify reports whether identifier ("alice@example.com") belongs to
+// pubkeyHex. An error means the domain couldn't be asked, not that the
+// identifier is wrong.
+func (v *NIP05Verifier) Verify(ctx context.Context, identifier, pubkeyHex string) (bool, error) {
+	name, domain, err := nip05.ParseIdentifier(strings.ToLower(identifier))
+	if err != nil {
+		return false, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.wellKnownURL(domain, name), nil)
+	if err != nil {
+		return false, fmt.Errorf("creating request: %w", err)
+	}
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("fetching %s: %w", domain, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("fetching %s: status %d", domain, resp.StatusCode)
+	}
+
+	var wellKnown nip05.WellKnownResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxWellKnownBytes)).Decode(&wellKnown); err != nil {
+		return false, fmt.Errorf("decoding %s nostr.json: %w", domain, err)
+	}
+	return strings.EqualFold(wellKnown.Names[name], pubkeyHex), nil
+}
+
+// FetchProfiles returns the latest kind:0 event of each of the given
+// pubkeys that relays have one for, by pubkey. Relays that don't answer
+// within 5 seconds are skipped.
+func (rm *RelayManager) FetchProfiles(ctx context.Context, pubkeys []string) map[string]*nostr.Event {
+	ctx, cancel := context.WithTimeout(ctx, profileFetchTimeout)
+	defer cancel()
+
+	wanted := make(map[string]bool, len(pubkeys))
+	for _, pk := range pubkeys {
+		wanted[pk] = true
+	}
+	filter := nostr.Filter{
+		Kinds:   []int{nostr.KindProfileMetadata},
+		Authors: pubkeys,
+	}
+	latest := make(map[string]*nostr.Event, len(pubkeys))
+	for ie := range rm.fetchMany(ctx, rm.allRelays(), filter) {
+		if ie.Event == nil || !wanted[ie.PubKey] || ie.Kind != nostr.KindProfileMetadata {
+			continue
+		}
+		if current := latest[ie.PubKey]; current == nil || ie.CreatedAt > current.CreatedAt {
+			latest[ie.PubKey] = ie.Event
+		}
+	}
+	return latest
+}
+
+// ProfileFetcher looks up customers' verified NIP-05 identifiers from their
+// kind:0 profiles.
+type ProfileFetcher struct {
+	relays   *RelayManager
+	verifier *NIP05Verifier
+}
+
+// NewProfileFetcher creates a fetcher that queries profiles through relays.
+func NewProfileFetcher(relays *RelayManager, verifier *NIP05Verifier) *ProfileFetcher {
+	return &ProfileFetcher{relays: relays, verifier: verifier}
+}
+
+// NIP05s returns the verified NIP-05 identifier of each of the given
+// pubkeys, by pubkey, or "" for a profile without one that verifies.
+// Pubkeys whose profile wasn't found or whose domain couldn't be asked are
+// left out, since their identifier is unknown rather than absent.
+func (f *ProfileFetcher) NIP05s(ctx context.Context, pubkeys []string) map[string]string {
+	identifiers := make(map[string]string, len(pubkeys))
+	for pk, event := range f.relays.FetchProfiles(ctx, pubkeys) {
+		var metadata struct {
+			NIP05 string `json:"nip05"`
+		}
+		if json.Unmarshal([]byte(event.Content), &metadata) != nil || metadata.NIP05 == "" {
+			identifiers[pk] = ""
+			continue
+		}
+
+		ok, err := f.verifier.Verify(ctx, metadata.NIP05, pk)
+		if err != nil {
+			slog.Debug("NIP-05 lookup failed", "pubkey", pk, "nip05", metadata.NIP05, "error", err)
+			continue
+		}
+		if !ok {
+			identifiers[pk] = ""
+			continue
+		}
+		identifiers[pk] = nip05.NormalizeIdentifier(strings.ToLower(metadata.NIP05))
+	}
+	return identifiers
+}
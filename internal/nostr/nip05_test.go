@@ -0,0 +1,115 @@
+package nostr
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testVerifier returns a verifier that asks server for every domain.
+func testVerifier(t *testing.T, handler http.HandlerFunc) *NIP05Verifier {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	v := NewNIP05Verifier()
+	v.wellKnownURL = func(domain, name string) string {
+		return server.URL + "/" + domain + "/.well-known/nostr.json?name=" + name
+	}
+	return v
+}
+
+func TestNIP05Verifier_Verify(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	bob, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	v := testVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/example.com/.well-known/nostr.json":
+			_, _ = w.Write([]byte(`{"names":{"alice":"` + alice + `","_":"` + bob + `"}}`))
+		case "/moved.example/.well-known/nostr.json":
+			http.Redirect(w, r, "/example.com/.well-known/nostr.json", http.StatusFound)
+		case "/broken.example/.well-known/nostr.json":
+			_, _ = w.Write([]byte("<html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		identifier string
+		pubkey     string
+		want       bool
+		wantErr    bool
+	}{
+		{"matches", "alice@example.com", alice, true, false},
+		{"case-insensitive", "Alice@Example.com", alice, true, false},
+		{"root identifier", "example.com", bob, true, false},
+		{"someone else's name", "alice@example.com", bob, false, false},
+		{"unknown name", "carol@example.com", alice, false, false},
+		{"not an identifier", "alice", alice, false, false},
+		{"redirect not followed", "alice@moved.example", alice, false, true},
+		{"not JSON", "alice@broken.example", alice, false, true},
+		{"no nostr.json", "alice@missing.example", alice, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(ctx, tt.identifier, tt.pubkey)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProfileFetcher_NIP05s(t *testing.T) {
+	ctx := context.Background()
+	alice, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	bob, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	carol, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	dave, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	erin, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	stranger, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	v := testVerifier(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/example.com/.well-known/nostr.json" {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"names":{"alice":"` + alice + `","bob":"` + alice + `"}}`))
+	})
+	profile := func(pubkey string, createdAt nostr.Timestamp, content string) *nostr.Event {
+		return &nostr.Event{PubKey: pubkey, Kind: nostr.KindProfileMetadata, CreatedAt: createdAt, Content: content}
+	}
+	rm := NewRelayManager([]string{testRelayA}, nil, "bot", nil)
+	profileRelay(rm,
+		profile(alice, 100, `{"nip05":"old@example.com"}`),
+		profile(alice, 200, `{"name":"Alice","nip05":"alice@example.com"}`),
+		profile(bob, 100, `{"nip05":"bob@example.com"}`),
+		profile(carol, 100, `{"name":"Carol"}`),
+		profile(dave, 100, `{"nip05":"dave@down.example"}`),
+		profile(stranger, 100, `{"nip05":"alice@example.com"}`),
+	)
+
+	got := NewProfileFetcher(rm, v).NIP05s(ctx, []string{alice, bob, carol, dave, erin})
+	want := map[string]string{
+		alice: "alice@example.com", // The newest profile counts
+		bob:   "",                  // Claims a name that belongs to someone else
+		carol: "",                  // No identifier
+	}
+	if len(got) != len(want) {
+		t.Errorf("NIP05s = %v, want %v; unreachable domains and missing profiles are unknown", got, want)
+	}
+	for pk, nip05 := range want {
+		if identifier, ok := got[pk]; !ok || identifier != nip05 {
+			t.Errorf("NIP05s[%s] = %q, %v; want %q", pk[:8], identifier, ok, nip05)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// profileFetchTimeout bounds the wait for relays to return the current profile.
+const profileFetchTimeout = 5 * time.Second
+
+// ErrNoRelayAnswered means no relay finished answering a query, so whether
+// the bot has a profile is unknown.
+var ErrNoRelayAnswered = errors.New("no relay answered")
+
+// Profile is the bot's kind:0 metadata as configured. Empty fields are left
+// as they are in the published profile.
+type Profile struct {
+	Name    string
+	About   string
+	Picture string // Image URL
+	Lud16   string // Lightning address that NIP-57 zaps to the profile resolve through
+}
+
+// fields returns the profile's set fields by their kind:0 JSON key.
+func (p Profile) fields() map[string]string {
+	fields := make(map[string]string, 4)
+	for key, value := range map[string]string{"name": p.Name, "about": p.About, "picture": p.Picture, "lud16": p.Lud16} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// MergeProfile returns kind:0 content with p's fields set on top of current,
+// the latest published profile (nil if there is none). Fields eggbot doesn't
+// manage, such as a banner or nip05, are kept. changed reports whether the
+// content differs from current.
+func MergeProfile(current *nostr.Event, p Profile) (content string, changed bool, err error) {
+	metadata := map[string]any{}
+	if current == nil || json.Unmarshal([]byte(current.Content), &metadata) != nil {
+		// Nothing usable published: start over
+		metadata = map[string]any{}
+		changed = true
+	}
+	for key, value := range p.fields() {
+		if metadata[key] != value {
+			metadata[key] = value
+			changed = true
+		}
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return "", false, fmt.Errorf("encoding profile: %w", err)
+	}
+	return string(data), changed, nil
+}
+
+// FetchProfile returns the bot's latest kind:0 event from the relays, or nil
+// if none has one. Relays that don't answer within 5 seconds are skipped; if
+// none answers it returns ErrNoRelayAnswered, since a profile may well exist.
+func (rm *RelayManager) FetchProfile(ctx context.Context) (*nostr.Event, error) {
+	ctx, cancel := context.WithTimeout(ctx, profileFetchTimeout)
+	defer cancel()
+
+	filter := nostr.Filter{
+		Kinds:   []int{nostr.KindProfileMetadata},
+		Authors: []string{rm.botPubkeyHex},
+		Limit:   1,
+	}
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		latest   *nostr.Event
+		answered bool
+	)
+	for _, url := range rm.allRelays() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			events, err := rm.queryRelay(ctx, url, filter)
+			if err != nil {
+				slog.Debug("profile query failed", "relay", url, "error", err)
+				return
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			answered = true
+			for _, event := range events {
+				if event.PubKey != rm.botPubkeyHex || event.Kind != nostr.KindProfileMetadata {
+					continue
+				}
+				if latest == nil || event.CreatedAt > latest.CreatedAt {
+					latest = event
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if !answered {
+		return nil, ErrNoRelayAnswered
+	}
+	return latest, nil
+}
+
+// PublishProfile signs and publishes the bot's profile merged onto the one
+// already on the relays, and returns the published event. It returns nil
+// without publishing when the relays already have an identical profile, and
+// refuses to publish when no relay answered, since publishing over a profile
+// it couldn't fetch would drop the fields eggbot doesn't manage.
+func (rm *RelayManager) PublishProfile(ctx context.Context, kr nostr.Keyer, p Profile) (*nostr.Event, error) {
+	current, err := rm.FetchProfile(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching current profile: %w", err)
+	}
+	content, changed, err := MergeProfile(current, p)
+	if err != nil {
+		return nil, err
+	}
+	if !changed {
+		slog.Debug("profile unchanged", "event_id", current.ID)
+		return nil, nil
+	}
+
+	event := &nostr.Event{
+		Kind:      nostr.KindProfileMetadata,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{},
+		Content:   content,
+	}
+	// A replaceable event only replaces an older one
+	if current != nil && event.CreatedAt <= current.CreatedAt {
+		event.CreatedAt = current.CreatedAt + 1
+	}
+	if err := kr.SignEvent(ctx, event); err != nil {
+		return nil, fmt.Errorf("signing profile: %w", err)
+	}
+	if err := rm.Publish(ctx, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
@@ -0,0 +1,186 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/keyer"
+)
+
+func TestMergeProfile(t *testing.T) {
+	p := Profile{Name: "eggbot", About: "Fresh eggs. DM me: help", Lud16: "eggbot@getalby.com"}
+	published := func(content string) *nostr.Event {
+		return &nostr.Event{Kind: nostr.KindProfileMetadata, Content: content}
+	}
+
+	tests := []struct {
+		name        string
+		current     *nostr.Event
+		wantChanged bool
+		want        map[string]any
+	}{
+		{
+			name:        "no profile yet",
+			wantChanged: true,
+			want:        map[string]any{"name": "eggbot", "about": "Fresh eggs. DM me: help", "lud16": "eggbot@getalby.com"},
+		},
+		{
+			name:    "already up to date",
+			current: published(`{"lud16":"eggbot@getalby.com","name":"eggbot","about":"Fresh eggs. DM me: help"}`),
+			want:    map[string]any{"name": "eggbot", "about": "Fresh eggs. DM me: help", "lud16": "eggbot@getalby.com"},
+		},
+		{
+			name:        "lightning address drifted",
+			current:     published(`{"name":"eggbot","about":"Fresh eggs. DM me: help","lud16":"old@walletofsatoshi.com"}`),
+			wantChanged: true,
+			want:        map[string]any{"name": "eggbot", "about": "Fresh eggs. DM me: help", "lud16": "eggbot@getalby.com"},
+		},
+		{
+			name:    "other fields and unset fields are kept",
+			current: published(`{"name":"eggbot","about":"Fresh eggs. DM me: help","lud16":"eggbot@getalby.com","picture":"https://example.com/hen.png","banner":"https://example.com/coop.png"}`),
+			want: map[string]any{"name": "eggbot", "about": "Fresh eggs. DM me: help", "lud16": "eggbot@getalby.com",
+				"picture": "https://example.com/hen.png", "banner": "https://example.com/coop.png"},
+		},
+		{
+			name:        "unreadable profile is replaced",
+			current:     published("not json"),
+			wantChanged: true,
+			want:        map[string]any{"name": "eggbot", "about": "Fresh eggs. DM me: help", "lud16": "eggbot@getalby.com"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, changed, err := MergeProfile(tt.current, p)
+			if err != nil {
+				t.Fatalf("MergeProfile: %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			var got map[string]any
+			if err := json.Unmarshal([]byte(content), &got); err != nil {
+				t.Fatalf("content %q is not JSON: %v", content, err)
+			}
+			if len(got) != len(tt.want) {
+				t.Errorf("content = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("%s = %v, want %v", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+// profileRelay answers profile queries with events and records publishes.
+func profileRelay(rm *RelayManager, events ...*nostr.Event) *fakePublisher {
+	rm.fetchMany = func(_ context.Context, urls []string, _ nostr.Filter) chan nostr.RelayEvent {
+		ch := make(chan nostr.RelayEvent, len(events))
+		for _, event := range events {
+			ch <- nostr.RelayEvent{Event: event}
+		}
+		close(ch)
+		return ch
+	}
+	rm.queryRelay = func(context.Context, string, nostr.Filter) ([]*nostr.Event, error) {
+		return events, nil
+	}
+	pub := &fakePublisher{fail: map[string]bool{}}
+	rm.publishMany = pub.publishMany
+	return pub
+}
+
+func TestPublishProfile(t *testing.T) {
+	ctx := context.Background()
+	secretHex := nostr.GeneratePrivateKey()
+	pubkeyHex, _ := nostr.GetPublicKey(secretHex)
+	kr, err := keyer.NewPlainKeySigner(secretHex)
+	if err != nil {
+		t.Fatalf("creating keyer: %v", err)
+	}
+	p := Profile{Name: "eggbot", Lud16: "eggbot@getalby.com"}
+
+	t.Run("publishes over an outdated profile", func(t *testing.T) {
+		rm := NewRelayManager([]string{testRelayA}, nil, pubkeyHex, nil)
+		older := &nostr.Event{PubKey: pubkeyHex, Kind: nostr.KindProfileMetadata, CreatedAt: 100, Content: `{"name":"eggbot"}`}
+		// The newest profile wins even when relays return it later
+		newest := &nostr.Event{PubKey: pubkeyHex, Kind: nostr.KindProfileMetadata, CreatedAt: nostr.Now() + 60, Content: `{"name":"eggbot","nip05":"eggs@example.com"}`}
+		pub := profileRelay(rm, older, newest)
+
+		event, err := rm.PublishProfile(ctx, kr, p)
+		if err != nil {
+			t.Fatalf("PublishProfile: %v", err)
+		}
+		if event == nil || len(pub.calls) != 1 {
+			t.Fatalf("published %v with %d calls, want one publish", event, len(pub.calls))
+		}
+		if ok, _ := event.CheckSignature(); !ok || event.PubKey != pubkeyHex || event.Kind != nostr.KindProfileMetadata {
+			t.Errorf("event not a signed kind 0 from the bot: %+v", event)
+		}
+		if event.CreatedAt <= newest.CreatedAt {
+			t.Errorf("created_at %d must be after the current profile's %d to replace it", event.CreatedAt, newest.CreatedAt)
+		}
+		var content map[string]string
+		_ = json.Unmarshal([]byte(event.Content), &content)
+		if content["lud16"] != "eggbot@getalby.com" || content["nip05"] != "eggs@example.com" {
+			t.Errorf("content = %s", event.Content)
+		}
+	})
+
+	t.Run("skips an unchanged profile", func(t *testing.T) {
+		rm := NewRelayManager([]string{testRelayA}, nil, pubkeyHex, nil)
+		current := &nostr.Event{PubKey: pubkeyHex, Kind: nostr.KindProfileMetadata, CreatedAt: 100, Content: `{"name":"eggbot","lud16":"eggbot@getalby.com"}`}
+		pub := profileRelay(rm, current)
+
+		event, err := rm.PublishProfile(ctx, kr, p)
+		if err != nil {
+			t.Fatalf("PublishProfile: %v", err)
+		}
+		if event != nil || len(pub.calls) != 0 {
+			t.Errorf("published %v, want nothing", event)
+		}
+	})
+
+	t.Run("refuses to publish when no relay answers", func(t *testing.T) {
+		rm := NewRelayManager([]string{testRelayA, testRelayB}, nil, pubkeyHex, nil)
+		pub := profileRelay(rm)
+		rm.queryRelay = func(context.Context, string, nostr.Filter) ([]*nostr.Event, error) {
+			return nil, context.DeadlineExceeded
+		}
+
+		event, err := rm.PublishProfile(ctx, kr, p)
+		if !errors.Is(err, ErrNoRelayAnswered) || event != nil || len(pub.calls) != 0 {
+			t.Errorf("PublishProfile = %v, %v with %d publishes; want ErrNoRelayAnswered and nothing published", event, err, len(pub.calls))
+		}
+	})
+
+	t.Run("one answering relay is enough", func(t *testing.T) {
+		rm := NewRelayManager([]string{testRelayA, testRelayB}, nil, pubkeyHex, nil)
+		current := &nostr.Event{PubKey: pubkeyHex, Kind: nostr.KindProfileMetadata, CreatedAt: 100, Content: `{"name":"eggbot","lud16":"eggbot@getalby.com"}`}
+		pub := profileRelay(rm)
+		rm.queryRelay = func(_ context.Context, url string, _ nostr.Filter) ([]*nostr.Event, error) {
+			if url == testRelayA {
+				return nil, context.DeadlineExceeded
+			}
+			return []*nostr.Event{current}, nil
+		}
+
+		if event, err := rm.PublishProfile(ctx, kr, p); err != nil || event != nil || len(pub.calls) != 0 {
+			t.Errorf("PublishProfile = %v, %v; want the unchanged profile found", event, err)
+		}
+	})
+
+	t.Run("ignores profiles from other keys", func(t *testing.T) {
+		rm := NewRelayManager([]string{testRelayA}, nil, pubkeyHex, nil)
+		impostor := &nostr.Event{PubKey: "other", Kind: nostr.KindProfileMetadata, CreatedAt: 100, Content: `{"name":"eggbot","lud16":"eggbot@getalby.com"}`}
+		pub := profileRelay(rm, impostor)
+
+		if event, err := rm.PublishProfile(ctx, kr, p); err != nil || event == nil || len(pub.calls) != 1 {
+			t.Errorf("PublishProfile = %v, %v; want a publish", event, err)
+		}
+	})
+}
@@ -3,119 +3,608 @@ package nostr
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/nbd-wtf/go-nostr"
 )
 
+// Circuit breaker settings: a relay that fails this many publishes in a row
+// is skipped for circuitOpenDuration before being tried again.
+const (
+	circuitFailureThreshold = 3
+	circuitOpenDuration     = 60 * time.Second
+)
+
+// circuitState tracks consecutive publish failures for a single relay.
+type circuitState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// Publisher sends signed events to relays.
+type Publisher interface {
+	Publish(ctx context.Context, event *nostr.Event) error
+}
+
+var _ Publisher = (*RelayManager)(nil)
+
 // RelayManager handles connections to multiple Nostr relays and manages subscriptions.
+// Events are published to primary relays first; fallback relays are only
+// used when no primary relay accepts an event.
 type RelayManager struct {
-	pool         *nostr.SimplePool
-	relayURLs    []string
+	pool *nostr.SimplePool
+
+	// Guards the relay lists and subscriptions, which SetRelays changes
+	// while running
+	relaysMu     sync.RWMutex
+	relayURLs    []string // Primary relays first, then fallback relays
+	primaryURLs  []string
+	fallbackURLs []string
+	primarySet   map[string]bool // Normalized primary relay URLs
+
 	botPubkeyHex string
+	kinds        []int // Event kinds subscribed to
+
+	// Subscriptions started by Connect: each relay's are cancelled by
+	// normalized URL, and all of them feed received until Close
+	subCtx   context.Context
+	filters  nostr.Filters
+	subs     map[string]context.CancelFunc
+	received chan nostr.RelayEvent
+	subsWG   sync.WaitGroup
+	closed   bool // No more subscriptions once Close was called
+
+	// Routed events by source: primary relay events are delivered first
+	primaryDMs   chan *nostr.Event
+	fallbackDMs  chan *nostr.Event
+	primaryZaps  chan *nostr.Event
+	fallbackZaps chan *nostr.Event
+
+	primaryMentions  chan *nostr.Event
+	fallbackMentions chan *nostr.Event
+
+	// Every routed event, zaps first, for consumers
+	events <-chan *nostr.Event
+
+	// Drops copies of an event delivered by more than one relay; nil disables it
+	dedup *EventDeduplicator
+
+	// Unix time of the last event received from any relay, for health checks
+	lastEventAt atomic.Int64
+
+	// Per-relay circuit breaker for publishing
+	circuitMu      sync.Mutex
+	circuitBreaker map[string]*circuitState
+	now            func() time.Time
+
+	// publishMany sends an event to relays; replaced in tests
+	publishMany func(ctx context.Context, urls []string, event nostr.Event) chan nostr.PublishResult
+
+	// fetchMany queries relays until each sends EOSE; replaced in tests
+	fetchMany func(ctx context.Context, urls []string, filter nostr.Filter) chan nostr.RelayEvent
 
-	// Event channels for consumers
-	dmEvents  chan *nostr.Event // kind:1059 gift-wrapped DMs
-	zapEvents chan *nostr.Event // kind:9735 zap receipts
+	// queryRelay returns one relay's stored events, failing without EOSE;
+	// replaced in tests
+	queryRelay func(ctx context.Context, url string, filter nostr.Filter) ([]*nostr.Event, error)
+
+	// subscribeMany streams matching events from relays; replaced in tests
+	subscribeMany func(ctx context.Context, urls []string, filter nostr.Filter) chan nostr.RelayEvent
 
 	cancel context.CancelFunc
 }
 
-// NewRelayManager creates a new relay manager for the given relay URLs.
-func NewRelayManager(relayURLs []string, botPubkeyHex string) *RelayManager {
-	return &RelayManager{
-		relayURLs:    relayURLs,
-		botPubkeyHex: botPubkeyHex,
-		dmEvents:     make(chan *nostr.Event, 100),
-		zapEvents:    make(chan *nostr.Event, 100),
+// NewRelayManager creates a new relay manager for the given primary and
+// fallback relay URLs. Events already seen by dedup are dropped before they
+// reach the event channels; pass nil to pass every event on.
+func NewRelayManager(primaryURLs, fallbackURLs []string, botPubkeyHex string, dedup *EventDeduplicator) *RelayManager {
+	rm := &RelayManager{
+		botPubkeyHex:   botPubkeyHex,
+		kinds:          slices.Clone(subscribedKinds),
+		dedup:          dedup,
+		primaryDMs:     make(chan *nostr.Event, 100),
+		fallbackDMs:    make(chan *nostr.Event, 100),
+		primaryZaps:    make(chan *nostr.Event, 100),
+		fallbackZaps:   make(chan *nostr.Event, 100),
+		circuitBreaker: make(map[string]*circuitState),
+		now:            time.Now,
+
+		primaryMentions:  make(chan *nostr.Event, 100),
+		fallbackMentions: make(chan *nostr.Event, 100),
+	}
+	rm.setRelays(primaryURLs, fallbackURLs)
+	rm.publishMany = rm.poolPublishMany
+	rm.fetchMany = rm.poolFetchMany
+	rm.queryRelay = rm.poolQueryRelay
+	rm.subscribeMany = rm.poolSubscribeMany
+
+	// Unbuffered outputs keep events queued in the sources, where the
+	// multiplexers can still let primary relay events and zaps jump ahead
+	dms := NewPriorityEventMultiplexer(rm.fallbackDMs, rm.primaryDMs, 0).Events()
+	zaps := NewPriorityEventMultiplexer(rm.fallbackZaps, rm.primaryZaps, 0).Events()
+	mentions := NewPriorityEventMultiplexer(rm.fallbackMentions, rm.primaryMentions, 0).Events()
+	rm.events = NewPriorityEventMultiplexer(NewPriorityEventMultiplexer(mentions, dms, 0).Events(), zaps, 0).Events()
+	return rm
+}
+
+// setRelays replaces the relay lists. Fallback relays that are also primary
+// relays are dropped. relaysMu must be held once the manager is shared.
+func (rm *RelayManager) setRelays(primaryURLs, fallbackURLs []string) {
+	rm.primaryURLs = slices.Clone(primaryURLs)
+	rm.primarySet = make(map[string]bool, len(primaryURLs))
+	for _, url := range primaryURLs {
+		rm.primarySet[nostr.NormalizeURL(url)] = true
+	}
+	rm.fallbackURLs = nil
+	for _, url := range fallbackURLs {
+		if !rm.primarySet[nostr.NormalizeURL(url)] {
+			rm.fallbackURLs = append(rm.fallbackURLs, url)
+		}
+	}
+	rm.relayURLs = append(slices.Clone(primaryURLs), rm.fallbackURLs...)
+}
+
+// relays returns the primary and fallback relays.
+func (rm *RelayManager) relays() (primaryURLs, fallbackURLs []string) {
+	rm.relaysMu.RLock()
+	defer rm.relaysMu.RUnlock()
+	return rm.primaryURLs, rm.fallbackURLs
+}
+
+// allRelays returns every relay, primary relays first.
+func (rm *RelayManager) allRelays() []string {
+	rm.relaysMu.RLock()
+	defer rm.relaysMu.RUnlock()
+	return rm.relayURLs
+}
+
+// isPrimary reports whether url is a primary relay.
+func (rm *RelayManager) isPrimary(url string) bool {
+	rm.relaysMu.RLock()
+	defer rm.relaysMu.RUnlock()
+	return rm.primarySet[nostr.NormalizeURL(url)]
+}
+
+// SetRelays replaces the primary and fallback relays while running, and
+// returns the relays added and removed. Once connected, removed relays are
+// unsubscribed and disconnected, and added ones are subscribed to from the
+// high water marks Connect was given; the database dedup drops the events
+// they repeat.
+func (rm *RelayManager) SetRelays(primaryURLs, fallbackURLs []string) (added, removed []string) {
+	rm.relaysMu.Lock()
+	defer rm.relaysMu.Unlock()
+
+	old := rm.relayURLs
+	rm.setRelays(primaryURLs, fallbackURLs)
+	added = relaysMissing(rm.relayURLs, old)
+	removed = relaysMissing(old, rm.relayURLs)
+
+	if rm.subs == nil || rm.closed {
+		return added, removed
+	}
+	for _, url := range removed {
+		rm.unsubscribe(url)
+	}
+	for _, url := range added {
+		rm.subscribe(url)
+	}
+	return added, removed
+}
+
+// relaysMissing returns the relays in urls that aren't in others.
+func relaysMissing(urls, others []string) []string {
+	var missing []string
+	for _, url := range urls {
+		if !slices.ContainsFunc(others, func(other string) bool {
+			return nostr.NormalizeURL(other) == nostr.NormalizeURL(url)
+		}) {
+			missing = append(missing, url)
+		}
+	}
+	return missing
+}
+
+// subscribedKinds are the event kinds the bot subscribes to:
+// kind:4 = NIP-04 legacy DMs (deprecated but widely used)
+// kind:1059 = NIP-17 gift-wrapped DMs
+// kind:9735 = zap receipts
+var subscribedKinds = []int{nostr.KindEncryptedDirectMessage, nostr.KindGiftWrap, nostr.KindZap}
+
+// SubscribeMentions adds public notes that tag the bot (kind:1 with a p tag
+// for it) to the subscription. Call it before Connect.
+func (rm *RelayManager) SubscribeMentions() {
+	if !slices.Contains(rm.kinds, nostr.KindTextNote) {
+		rm.kinds = append(rm.kinds, nostr.KindTextNote)
 	}
 }
 
 // Connect establishes connections to all configured relays and starts subscriptions.
-// The since parameter filters events to only those with created_at > since.
-// Pass 0 to receive all historical events.
-func (rm *RelayManager) Connect(ctx context.Context, since int64) error {
+// marks holds the high water mark of each kind; each kind only receives events
+// from overlap before its mark onward, and kinds without a mark receive all
+// historical events.
+func (rm *RelayManager) Connect(ctx context.Context, marks map[int]int64, overlap time.Duration) error {
+	ctx = rm.open(ctx)
+
+	// Subscribe to DMs and zap receipts addressed to the bot, one subscription
+	// per relay and distinct starting point
+	filters := sinceFilters(rm.botPubkeyHex, rm.kinds, marks, overlap)
+	for _, filter := range filters {
+		if filter.Since != nil {
+			slog.Info("filtering events", "kinds", filter.Kinds, "since", filter.Since.Time().Format("2006/01/02 15:04:05"))
+		}
+	}
+
+	rm.relaysMu.Lock()
+	rm.subCtx, rm.filters = ctx, filters
+	rm.subs = make(map[string]context.CancelFunc)
+	rm.received = make(chan nostr.RelayEvent)
+	for _, url := range rm.relayURLs {
+		rm.subscribe(url)
+	}
+	primary, fallback := len(rm.primaryURLs), len(rm.fallbackURLs)
+	rm.relaysMu.Unlock()
+
+	go rm.route(rm.received)
+	go rm.closeReceivedWhenDone(ctx)
+
+	slog.Info("subscribed to relays", "primary", primary, "fallback", fallback)
+	return nil
+}
+
+// subscribe starts url's subscriptions, which feed rm.received until
+// unsubscribe or Close. relaysMu must be held.
+func (rm *RelayManager) subscribe(url string) {
+	ctx, cancel := context.WithCancel(rm.subCtx)
+	rm.subs[nostr.NormalizeURL(url)] = cancel
+	for _, filter := range rm.filters {
+		events := rm.subscribeMany(ctx, []string{url}, filter)
+		rm.subsWG.Add(1)
+		go func() {
+			defer rm.subsWG.Done()
+			for re := range events {
+				rm.received <- re
+			}
+		}()
+	}
+}
+
+// unsubscribe ends url's subscriptions and closes its connection, which
+// nothing else uses once it is no longer listed. relaysMu must be held.
+func (rm *RelayManager) unsubscribe(url string) {
+	normalized := nostr.NormalizeURL(url)
+	if cancel, ok := rm.subs[normalized]; ok {
+		cancel()
+		delete(rm.subs, normalized)
+	}
+	if rm.pool == nil {
+		return
+	}
+	if relay, ok := rm.pool.Relays.LoadAndDelete(normalized); ok {
+		_ = relay.Close()
+	}
+}
+
+// closeReceivedWhenDone closes rm.received once ctx is done and every
+// subscription has ended, which in turn ends route.
+func (rm *RelayManager) closeReceivedWhenDone(ctx context.Context) {
+	<-ctx.Done()
+	rm.relaysMu.Lock()
+	rm.closed = true
+	rm.relaysMu.Unlock()
+	rm.subsWG.Wait()
+	close(rm.received)
+}
+
+// Open creates the relay pool for publishing and queries without subscribing
+// to anything, for one-off commands. Connect opens the pool itself.
+func (rm *RelayManager) Open(ctx context.Context) {
+	rm.open(ctx)
+}
+
+// open creates the relay pool and returns the context that Close cancels.
+func (rm *RelayManager) open(ctx context.Context) context.Context {
 	ctx, rm.cancel = context.WithCancel(ctx)
 
 	// Create pool with penalty box for exponential backoff on failures
 	rm.pool = nostr.NewSimplePool(ctx, nostr.WithPenaltyBox())
+	return ctx
+}
+
+// sinceFilters builds the subscription filters for events of the given kinds
+// addressed to the bot. Kinds resuming from the same timestamp share a filter.
+// Events already processed within the overlap are dropped downstream by the
+// database dedup.
+func sinceFilters(botPubkeyHex string, kinds []int, marks map[int]int64, overlap time.Duration) nostr.Filters {
+	var filters nostr.Filters
+	for _, kind := range kinds {
+		var since int64
+		if mark := marks[kind]; mark > 0 {
+			// NIP-01: since is inclusive (>=), so add 1 to exclude the mark itself
+			since = max(mark+1-int64(overlap.Seconds()), 0)
+		}
+
+		i := slices.IndexFunc(filters, func(f nostr.Filter) bool {
+			return (f.Since == nil && since == 0) || (f.Since != nil && int64(*f.Since) == since)
+		})
+		if i >= 0 {
+			filters[i].Kinds = append(filters[i].Kinds, kind)
+			continue
+		}
+		filter := nostr.Filter{
+			Kinds: []int{kind},
+			Tags:  nostr.TagMap{"p": []string{botPubkeyHex}},
+		}
+		if since > 0 {
+			sinceTs := nostr.Timestamp(since)
+			filter.Since = &sinceTs
+		}
+		filters = append(filters, filter)
+	}
+	return filters
+}
 
-	// Subscribe to DMs and zap receipts addressed to the bot
-	// kind:4 = NIP-04 legacy DMs (deprecated but widely used)
-	// kind:1059 = NIP-17 gift-wrapped DMs
-	// kind:9735 = zap receipts
-	filter := nostr.Filter{
-		Kinds: []int{nostr.KindEncryptedDirectMessage, nostr.KindGiftWrap, nostr.KindZap},
-		Tags:  nostr.TagMap{"p": []string{rm.botPubkeyHex}},
-	}
-
-	// Apply since filter if we have a high water mark
-	// NIP-01: since is inclusive (>=), so add 1 to exclude already-processed events
-	if since > 0 {
-		sinceTs := nostr.Timestamp(since + 1)
-		filter.Since = &sinceTs
-		log.Printf("filtering events after %s", time.Unix(since, 0).Format("2006/01/02 15:04:05"))
-	}
-
-	events := rm.pool.SubscribeMany(ctx, rm.relayURLs, filter)
-
-	// Router goroutine: dispatch events by kind to separate channels
-	go func() {
-		for re := range events {
-			switch re.Kind {
-			case nostr.KindEncryptedDirectMessage, nostr.KindGiftWrap: // DMs: kind:4 (NIP-04) or kind:1059 (NIP-17 gift-wrapped)
-				select {
-				case rm.dmEvents <- re.Event:
-				default:
-					log.Printf("DM event channel full, dropping event %s", re.ID)
-				}
-			case nostr.KindZap: // Zap receipt
-				select {
-				case rm.zapEvents <- re.Event:
-				default:
-					log.Printf("zap event channel full, dropping event %s", re.ID)
-				}
+// route dispatches events by kind, and by whether they came from a primary
+// relay, until the subscription ends. It then closes the source channels.
+func (rm *RelayManager) route(events <-chan nostr.RelayEvent) {
+	for re := range events {
+		rm.lastEventAt.Store(rm.now().Unix())
+		// The database check still runs downstream; this only spares it the
+		// copies every additional relay delivers
+		if rm.dedup != nil && rm.dedup.IsDuplicate(re.ID) {
+			slog.Debug("event already received from another relay", "event_id", re.ID)
+			continue
+		}
+		primary := re.Relay == nil || rm.isPrimary(re.Relay.URL)
+		var ch chan *nostr.Event
+		var name string
+		switch re.Kind {
+		case nostr.KindEncryptedDirectMessage, nostr.KindGiftWrap: // DMs: kind:4 (NIP-04) or kind:1059 (NIP-17 gift-wrapped)
+			ch, name = rm.fallbackDMs, "DM"
+			if primary {
+				ch = rm.primaryDMs
+			}
+		case nostr.KindZap: // Zap receipt
+			ch, name = rm.fallbackZaps, "zap"
+			if primary {
+				ch = rm.primaryZaps
+			}
+		case nostr.KindTextNote: // Public note tagging the bot
+			ch, name = rm.fallbackMentions, "mention"
+			if primary {
+				ch = rm.primaryMentions
 			}
+		default:
+			continue
+		}
+		select {
+		case ch <- re.Event:
+			// Only now is the event sure to be handled; a copy of a dropped
+			// one from another relay must still get through
+			if rm.dedup != nil {
+				rm.dedup.MarkSeen(re.ID)
+			}
+		default:
+			slog.Warn(name+" event channel full, dropping event", "event_id", re.ID)
 		}
-		close(rm.dmEvents)
-		close(rm.zapEvents)
-	}()
+	}
+	close(rm.primaryDMs)
+	close(rm.fallbackDMs)
+	close(rm.primaryZaps)
+	close(rm.fallbackZaps)
+	close(rm.primaryMentions)
+	close(rm.fallbackMentions)
+}
 
-	log.Printf("subscribed to %d relays", len(rm.relayURLs))
-	return nil
+// ConnectedRelays returns how many relays currently have an open connection.
+// The pool pings connected relays and drops those that stop answering.
+func (rm *RelayManager) ConnectedRelays() int {
+	if rm.pool == nil {
+		return 0
+	}
+	connected := 0
+	rm.pool.Relays.Range(func(_ string, relay *nostr.Relay) bool {
+		if relay.IsConnected() {
+			connected++
+		}
+		return true
+	})
+	return connected
 }
 
-// DMEvents returns a channel of gift-wrapped DM events (kind:1059).
-func (rm *RelayManager) DMEvents() <-chan *nostr.Event {
-	return rm.dmEvents
+// RelayStatus is the state of one of the manager's relays.
+type RelayStatus struct {
+	URL         string
+	Fallback    bool // Only published to when no primary relay accepts an event
+	Connected   bool
+	Quarantined bool // Skipped for publishing after repeated failures
 }
 
-// ZapEvents returns a channel of zap receipt events (kind:9735).
-func (rm *RelayManager) ZapEvents() <-chan *nostr.Event {
-	return rm.zapEvents
+// RelayStatuses returns the state of every relay, primary relays first.
+func (rm *RelayManager) RelayStatuses() []RelayStatus {
+	var statuses []RelayStatus
+	for _, url := range rm.allRelays() {
+		status := RelayStatus{URL: url, Fallback: !rm.isPrimary(url), Quarantined: rm.isCircuitOpen(url)}
+		if rm.pool != nil {
+			relay, ok := rm.pool.Relays.Load(nostr.NormalizeURL(url))
+			status.Connected = ok && relay != nil && relay.IsConnected()
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// LastEventAt returns when an event was last received from any relay,
+// or the zero time if none has arrived yet.
+func (rm *RelayManager) LastEventAt() time.Time {
+	ts := rm.lastEventAt.Load()
+	if ts == 0 {
+		return time.Time{}
+	}
+	return time.Unix(ts, 0)
 }
 
-// Publish sends an event to all connected relays.
+// DuplicateEvents returns how many events were dropped as copies of one
+// already received from another relay.
+func (rm *RelayManager) DuplicateEvents() int64 {
+	if rm.dedup == nil {
+		return 0
+	}
+	return rm.dedup.Hits()
+}
+
+// Events returns a channel of every event for the bot: DMs (kind:4 and
+// kind:1059), zap receipts (kind:9735) and public notes tagging it (kind:1),
+// which only arrive if SubscribeMentions was called before Connect. When
+// several are waiting, zaps come first, since a zap may mark paid an order
+// that a queued DM command then acts on. The channel closes once Close has
+// ended every subscription.
+func (rm *RelayManager) Events() <-chan *nostr.Event {
+	return rm.events
+}
+
+// Publish sends an event to the primary relays whose circuit is closed.
+// Fallback relays are only tried if no primary relay accepts the event.
 func (rm *RelayManager) Publish(ctx context.Context, event *nostr.Event) error {
+	primaryURLs, fallbackURLs := rm.relays()
+	published, err := rm.publishTo(ctx, primaryURLs, event)
+	if published == 0 && len(fallbackURLs) > 0 {
+		slog.Warn("no primary relay accepted event, trying fallback relays", "event_id", event.ID, "error", err)
+		published, err = rm.publishTo(ctx, fallbackURLs, event)
+	}
+	if published == 0 {
+		return err
+	}
+
+	slog.Debug("published event", "event_id", event.ID, "relays", published)
+	return nil
+}
+
+// publishTo sends an event to the given relays whose circuit is closed and
+// returns how many accepted it. The error is set when none did.
+func (rm *RelayManager) publishTo(ctx context.Context, urls []string, event *nostr.Event) (int, error) {
 	var lastErr error
 	var published int
 
-	for result := range rm.pool.PublishMany(ctx, rm.relayURLs, *event) {
+	targets := rm.publishTargets(urls)
+	if len(targets) == 0 {
+		return 0, fmt.Errorf("failed to publish: all %d relays quarantined", len(urls))
+	}
+
+	for result := range rm.publishMany(ctx, targets, *event) {
 		if result.Error != nil {
 			lastErr = result.Error
-			log.Printf("publish to %s failed: %v", result.RelayURL, result.Error)
+			rm.recordFailure(result.RelayURL)
+			slog.Warn("publish failed", "relay", result.RelayURL, "error", result.Error)
 			continue
 		}
+		rm.recordSuccess(result.RelayURL)
 		published++
 	}
 
 	if published == 0 {
-		return fmt.Errorf("failed to publish to any relay: %w", lastErr)
+		return 0, fmt.Errorf("failed to publish to any relay: %w", lastErr)
 	}
+	return published, nil
+}
 
-	log.Printf("published event %s to %d relays", event.ID, published)
-	return nil
+// poolPublishMany publishes through the relay pool opened by Connect.
+func (rm *RelayManager) poolPublishMany(ctx context.Context, urls []string, event nostr.Event) chan nostr.PublishResult {
+	return rm.pool.PublishMany(ctx, urls, event)
+}
+
+// poolSubscribeMany subscribes through the relay pool opened by Connect.
+func (rm *RelayManager) poolSubscribeMany(ctx context.Context, urls []string, filter nostr.Filter) chan nostr.RelayEvent {
+	return rm.pool.SubscribeMany(ctx, urls, filter)
+}
+
+// poolFetchMany queries through the relay pool opened by Connect or Open.
+func (rm *RelayManager) poolFetchMany(ctx context.Context, urls []string, filter nostr.Filter) chan nostr.RelayEvent {
+	return rm.pool.FetchMany(ctx, urls, filter)
+}
+
+// poolQueryRelay returns the events url has stored that match filter. It
+// fails if the relay can't be reached, closes the subscription or doesn't
+// send EOSE before ctx is done, so an empty result means the relay has none.
+func (rm *RelayManager) poolQueryRelay(ctx context.Context, url string, filter nostr.Filter) ([]*nostr.Event, error) {
+	relay, err := rm.pool.EnsureRelay(url)
+	if err != nil {
+		return nil, err
+	}
+	sub, err := relay.Subscribe(ctx, nostr.Filters{filter})
+	if err != nil {
+		return nil, err
+	}
+	defer sub.Unsub()
+
+	var events []*nostr.Event
+	for {
+		select {
+		case event := <-sub.Events:
+			events = append(events, event)
+		case <-sub.EndOfStoredEvents:
+			return events, nil
+		case reason := <-sub.ClosedReason:
+			return nil, fmt.Errorf("subscription closed: %s", reason)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// publishTargets returns the given relays whose circuit is not open.
+func (rm *RelayManager) publishTargets(urls []string) []string {
+	targets := make([]string, 0, len(urls))
+	for _, url := range urls {
+		if rm.isCircuitOpen(url) {
+			slog.Debug("skipping quarantined relay", "relay", url)
+			continue
+		}
+		targets = append(targets, url)
+	}
+	return targets
+}
+
+// isCircuitOpen reports whether the relay is currently quarantined.
+func (rm *RelayManager) isCircuitOpen(url string) bool {
+	rm.circuitMu.Lock()
+	defer rm.circuitMu.Unlock()
+	state, ok := rm.circuitBreaker[url]
+	if !ok {
+		return false
+	}
+	return rm.now().Before(state.openUntil)
+}
+
+// recordFailure counts a publish failure and opens the circuit once the
+// threshold is reached. A failure after the quarantine expires re-opens it.
+func (rm *RelayManager) recordFailure(url string) {
+	rm.circuitMu.Lock()
+	defer rm.circuitMu.Unlock()
+	state, ok := rm.circuitBreaker[url]
+	if !ok {
+		state = &circuitState{}
+		rm.circuitBreaker[url] = state
+	}
+	state.failures++
+	if state.failures >= circuitFailureThreshold {
+		state.openUntil = rm.now().Add(circuitOpenDuration)
+		slog.Warn("relay quarantined", "relay", url, "failures", state.failures, "duration", circuitOpenDuration)
+	}
+}
+
+// recordSuccess closes the circuit and resets the failure count.
+func (rm *RelayManager) recordSuccess(url string) {
+	rm.circuitMu.Lock()
+	defer rm.circuitMu.Unlock()
+	delete(rm.circuitBreaker, url)
+}
+
+// ResetCircuitBreaker clears the failure state for a relay.
+func (rm *RelayManager) ResetCircuitBreaker(url string) {
+	rm.recordSuccess(url)
 }
 
 // Close gracefully shuts down all relay connections.
@@ -126,5 +615,5 @@ func (rm *RelayManager) Close() {
 	if rm.pool != nil {
 		rm.pool.Close("relay manager closed")
 	}
-	log.Printf("relay manager closed")
+	slog.Info("relay manager closed")
 }
@@ -0,0 +1,545 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const (
+	testRelayA = "wss://relay-a.example.com"
+	testRelayB = "wss://relay-b.example.com"
+)
+
+// newTestRelayManager returns a manager with a controllable clock.
+func newTestRelayManager(now *time.Time) *RelayManager {
+	rm := NewRelayManager([]string{testRelayA, testRelayB}, nil, "", nil)
+	rm.now = func() time.Time { return *now }
+	return rm
+}
+
+func TestCircuitBreaker_OpensAfterThreshold(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rm := newTestRelayManager(&now)
+
+	for i := 1; i < circuitFailureThreshold; i++ {
+		rm.recordFailure(testRelayA)
+		if rm.isCircuitOpen(testRelayA) {
+			t.Fatalf("circuit open after %d failures, want closed until %d", i, circuitFailureThreshold)
+		}
+	}
+
+	rm.recordFailure(testRelayA)
+	if !rm.isCircuitOpen(testRelayA) {
+		t.Fatal("circuit should be open after reaching failure threshold")
+	}
+
+	targets := rm.publishTargets(rm.relayURLs)
+	if len(targets) != 1 || targets[0] != testRelayB {
+		t.Errorf("publishTargets() = %v, want [%s]", targets, testRelayB)
+	}
+}
+
+func TestCircuitBreaker_RecoversAfterQuarantine(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rm := newTestRelayManager(&now)
+
+	for i := 0; i < circuitFailureThreshold; i++ {
+		rm.recordFailure(testRelayA)
+	}
+
+	now = now.Add(circuitOpenDuration - time.Second)
+	if !rm.isCircuitOpen(testRelayA) {
+		t.Fatal("circuit should still be open before quarantine expires")
+	}
+
+	now = now.Add(time.Second)
+	if rm.isCircuitOpen(testRelayA) {
+		t.Fatal("circuit should allow a retry once quarantine expires")
+	}
+
+	// A failed retry re-opens immediately
+	rm.recordFailure(testRelayA)
+	if !rm.isCircuitOpen(testRelayA) {
+		t.Fatal("failed retry should re-open the circuit")
+	}
+
+	// A successful retry resets the counter
+	now = now.Add(circuitOpenDuration)
+	rm.recordSuccess(testRelayA)
+	rm.recordFailure(testRelayA)
+	if rm.isCircuitOpen(testRelayA) {
+		t.Error("single failure after success should not open the circuit")
+	}
+}
+
+func TestCircuitBreaker_SuccessResetsCount(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rm := newTestRelayManager(&now)
+
+	rm.recordFailure(testRelayA)
+	rm.recordFailure(testRelayA)
+	rm.recordSuccess(testRelayA)
+	rm.recordFailure(testRelayA)
+	rm.recordFailure(testRelayA)
+
+	if rm.isCircuitOpen(testRelayA) {
+		t.Error("failures must be consecutive to open the circuit")
+	}
+}
+
+func TestResetCircuitBreaker(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rm := newTestRelayManager(&now)
+
+	for i := 0; i < circuitFailureThreshold; i++ {
+		rm.recordFailure(testRelayA)
+		rm.recordFailure(testRelayB)
+	}
+	if len(rm.publishTargets(rm.relayURLs)) != 0 {
+		t.Fatal("all relays should be quarantined")
+	}
+
+	rm.ResetCircuitBreaker(testRelayA)
+	targets := rm.publishTargets(rm.relayURLs)
+	if len(targets) != 1 || targets[0] != testRelayA {
+		t.Errorf("publishTargets() = %v, want [%s]", targets, testRelayA)
+	}
+}
+
+// fakePublisher stands in for relays, failing publishes to the relays in fail.
+type fakePublisher struct {
+	fail  map[string]bool
+	calls [][]string
+}
+
+func (p *fakePublisher) publishMany(_ context.Context, urls []string, _ nostr.Event) chan nostr.PublishResult {
+	p.calls = append(p.calls, urls)
+	results := make(chan nostr.PublishResult, len(urls))
+	for _, url := range urls {
+		result := nostr.PublishResult{RelayURL: url}
+		if p.fail[url] {
+			result.Error = errors.New("connection refused")
+		}
+		results <- result
+	}
+	close(results)
+	return results
+}
+
+const (
+	testPrimaryA  = "wss://primary-a.example.com"
+	testPrimaryB  = "wss://primary-b.example.com"
+	testFallbackB = "wss://fallback-b.example.com"
+	testFallbackA = "wss://fallback-a.example.com"
+)
+
+func newFallbackTestManager(fail ...string) (*RelayManager, *fakePublisher) {
+	rm := NewRelayManager([]string{testPrimaryA, testPrimaryB}, []string{testFallbackA}, "", nil)
+	pub := &fakePublisher{fail: make(map[string]bool)}
+	for _, url := range fail {
+		pub.fail[url] = true
+	}
+	rm.publishMany = pub.publishMany
+	return rm, pub
+}
+
+func TestPublish_PrimarySucceedsSkipsFallback(t *testing.T) {
+	rm, pub := newFallbackTestManager(testPrimaryA)
+
+	if err := rm.Publish(context.Background(), &nostr.Event{ID: "e1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(pub.calls) != 1 || !slices.Equal(pub.calls[0], []string{testPrimaryA, testPrimaryB}) {
+		t.Errorf("publish calls = %v, want only the primaries", pub.calls)
+	}
+}
+
+func TestPublish_FallsBackWhenPrimariesFail(t *testing.T) {
+	rm, pub := newFallbackTestManager(testPrimaryA, testPrimaryB)
+
+	if err := rm.Publish(context.Background(), &nostr.Event{ID: "e1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(pub.calls) != 2 || !slices.Equal(pub.calls[1], []string{testFallbackA}) {
+		t.Errorf("publish calls = %v, want primaries then fallback", pub.calls)
+	}
+}
+
+func TestPublish_FallsBackWhenPrimariesQuarantined(t *testing.T) {
+	rm, pub := newFallbackTestManager()
+	for i := 0; i < circuitFailureThreshold; i++ {
+		rm.recordFailure(testPrimaryA)
+		rm.recordFailure(testPrimaryB)
+	}
+
+	if err := rm.Publish(context.Background(), &nostr.Event{ID: "e1"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	if len(pub.calls) != 1 || !slices.Equal(pub.calls[0], []string{testFallbackA}) {
+		t.Errorf("publish calls = %v, want only the fallback", pub.calls)
+	}
+}
+
+func TestPublish_AllRelaysFail(t *testing.T) {
+	rm, _ := newFallbackTestManager(testPrimaryA, testPrimaryB, testFallbackA)
+
+	err := rm.Publish(context.Background(), &nostr.Event{ID: "e1"})
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("expected publish error, got %v", err)
+	}
+}
+
+func TestNewRelayManager_DropsDuplicateFallback(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA}, []string{testPrimaryA + "/", testFallbackA}, "", nil)
+	if !slices.Equal(rm.fallbackURLs, []string{testFallbackA}) {
+		t.Errorf("fallbackURLs = %v, want [%s]", rm.fallbackURLs, testFallbackA)
+	}
+	if !slices.Equal(rm.relayURLs, []string{testPrimaryA, testFallbackA}) {
+		t.Errorf("relayURLs = %v", rm.relayURLs)
+	}
+}
+
+func TestRelayStatuses(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	rm := NewRelayManager([]string{testPrimaryA, testPrimaryB}, []string{testFallbackA}, "", nil)
+	rm.now = func() time.Time { return now }
+	for i := 0; i < circuitFailureThreshold; i++ {
+		rm.recordFailure(testPrimaryB)
+	}
+
+	// Nothing is connected before Connect
+	want := []RelayStatus{
+		{URL: testPrimaryA},
+		{URL: testPrimaryB, Quarantined: true},
+		{URL: testFallbackA, Fallback: true},
+	}
+	if got := rm.RelayStatuses(); !slices.Equal(got, want) {
+		t.Errorf("RelayStatuses() = %+v, want %+v", got, want)
+	}
+
+	rm.SetRelays([]string{testFallbackA}, nil)
+	want = []RelayStatus{{URL: testFallbackA}}
+	if got := rm.RelayStatuses(); !slices.Equal(got, want) {
+		t.Errorf("after SetRelays, RelayStatuses() = %+v, want %+v", got, want)
+	}
+}
+
+func TestSubscribeMentions(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA}, nil, "", nil)
+	if slices.Contains(rm.kinds, nostr.KindTextNote) {
+		t.Fatalf("kinds = %v, mentions should be off by default", rm.kinds)
+	}
+	rm.SubscribeMentions()
+	rm.SubscribeMentions()
+	if want := append(slices.Clone(subscribedKinds), nostr.KindTextNote); !slices.Equal(rm.kinds, want) {
+		t.Errorf("kinds = %v, want %v", rm.kinds, want)
+	}
+}
+
+func TestRoute_SeparatesPrimaryEvents(t *testing.T) {
+	rm, _ := newFallbackTestManager()
+	// Replace the multiplexer inputs so routed events can be inspected
+	rm.primaryDMs = make(chan *nostr.Event, 10)
+	rm.fallbackDMs = make(chan *nostr.Event, 10)
+	rm.primaryZaps = make(chan *nostr.Event, 10)
+	rm.fallbackZaps = make(chan *nostr.Event, 10)
+	rm.primaryMentions = make(chan *nostr.Event, 10)
+	rm.fallbackMentions = make(chan *nostr.Event, 10)
+
+	primary := &nostr.Relay{URL: nostr.NormalizeURL(testPrimaryB)}
+	fallback := &nostr.Relay{URL: nostr.NormalizeURL(testFallbackA)}
+	events := make(chan nostr.RelayEvent, 6)
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "dm-fallback", Kind: nostr.KindGiftWrap}, Relay: fallback}
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "dm-primary", Kind: nostr.KindEncryptedDirectMessage}, Relay: primary}
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "zap-fallback", Kind: nostr.KindZap}, Relay: fallback}
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "zap-primary", Kind: nostr.KindZap}, Relay: primary}
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "mention-fallback", Kind: nostr.KindTextNote}, Relay: fallback}
+	events <- nostr.RelayEvent{Event: &nostr.Event{ID: "mention-primary", Kind: nostr.KindTextNote}, Relay: primary}
+	close(events)
+
+	rm.route(events)
+
+	for name, tc := range map[string]struct {
+		ch   chan *nostr.Event
+		want string
+	}{
+		"primary DMs":   {rm.primaryDMs, "dm-primary"},
+		"fallback DMs":  {rm.fallbackDMs, "dm-fallback"},
+		"primary zaps":  {rm.primaryZaps, "zap-primary"},
+		"fallback zaps": {rm.fallbackZaps, "zap-fallback"},
+
+		"primary mentions":  {rm.primaryMentions, "mention-primary"},
+		"fallback mentions": {rm.fallbackMentions, "mention-fallback"},
+	} {
+		var got []string
+		for event := range tc.ch {
+			got = append(got, event.ID)
+		}
+		if len(got) != 1 || got[0] != tc.want {
+			t.Errorf("%s = %v, want [%s]", name, got, tc.want)
+		}
+	}
+	if rm.LastEventAt().IsZero() {
+		t.Error("routing should record the last event time")
+	}
+}
+
+func TestRoute_DropsCopiesFromOtherRelays(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA, testPrimaryB}, nil, "", NewEventDeduplicator(time.Minute, 100))
+	rm.primaryDMs = make(chan *nostr.Event, 10)
+	rm.primaryZaps = make(chan *nostr.Event, 10)
+
+	relayA := &nostr.Relay{URL: nostr.NormalizeURL(testPrimaryA)}
+	relayB := &nostr.Relay{URL: nostr.NormalizeURL(testPrimaryB)}
+	dm := &nostr.Event{ID: "dm", Kind: nostr.KindGiftWrap}
+	zap := &nostr.Event{ID: "zap", Kind: nostr.KindZap}
+	events := make(chan nostr.RelayEvent, 4)
+	events <- nostr.RelayEvent{Event: dm, Relay: relayA}
+	events <- nostr.RelayEvent{Event: zap, Relay: relayB}
+	events <- nostr.RelayEvent{Event: dm, Relay: relayB}
+	events <- nostr.RelayEvent{Event: zap, Relay: relayA}
+	close(events)
+
+	rm.route(events)
+
+	for name, ch := range map[string]chan *nostr.Event{"DMs": rm.primaryDMs, "zaps": rm.primaryZaps} {
+		if n := len(collectEvents(t, ch)); n != 1 {
+			t.Errorf("%s delivered %d times, want once", name, n)
+		}
+	}
+	if got := rm.DuplicateEvents(); got != 2 {
+		t.Errorf("DuplicateEvents() = %d, want 2", got)
+	}
+}
+
+func TestRoute_DroppedEventNotMarkedSeen(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA}, []string{testFallbackA}, "", NewEventDeduplicator(time.Minute, 100))
+	rm.primaryDMs = make(chan *nostr.Event, 1)
+	rm.primaryDMs <- &nostr.Event{ID: "backlog", Kind: nostr.KindGiftWrap}
+	rm.fallbackDMs = make(chan *nostr.Event, 10)
+
+	dm := &nostr.Event{ID: "dm", Kind: nostr.KindGiftWrap}
+	events := make(chan nostr.RelayEvent, 2)
+	events <- nostr.RelayEvent{Event: dm, Relay: &nostr.Relay{URL: nostr.NormalizeURL(testPrimaryA)}}
+	events <- nostr.RelayEvent{Event: dm, Relay: &nostr.Relay{URL: nostr.NormalizeURL(testFallbackA)}}
+	close(events)
+
+	rm.route(events)
+
+	// The primary copy is dropped on the full channel; the fallback copy
+	// still gets through
+	if got := collectEvents(t, rm.fallbackDMs); len(got) != 1 || got[0].ID != "dm" {
+		t.Errorf("fallback DMs = %v, want the dropped DM", got)
+	}
+	if got := rm.DuplicateEvents(); got != 0 {
+		t.Errorf("DuplicateEvents() = %d, want 0", got)
+	}
+}
+
+func TestSinceFilters(t *testing.T) {
+	const bot = "79be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	type want struct {
+		kinds []int
+		since int64 // 0 means no since filter
+	}
+	tests := []struct {
+		name    string
+		kinds   []int
+		marks   map[int]int64
+		overlap time.Duration
+		want    []want
+	}{
+		{
+			name: "no marks",
+			want: []want{{[]int{4, 1059, 9735}, 0}},
+		},
+		{
+			name:  "shared mark",
+			marks: map[int]int64{4: 1000, 1059: 1000, 9735: 1000},
+			want:  []want{{[]int{4, 1059, 9735}, 1001}},
+		},
+		{
+			name:    "zaps behind DMs",
+			marks:   map[int]int64{4: 1000, 1059: 1000, 9735: 900},
+			overlap: time.Minute,
+			want:    []want{{[]int{4, 1059}, 941}, {[]int{9735}, 841}},
+		},
+		{
+			name:    "overlap reaches back past the start",
+			marks:   map[int]int64{4: 1000, 9735: 30},
+			overlap: time.Minute,
+			want:    []want{{[]int{4}, 941}, {[]int{1059, 9735}, 0}},
+		},
+		{
+			name:  "mentions without a mark",
+			kinds: []int{4, 1059, 9735, 1},
+			marks: map[int]int64{4: 1000, 1059: 1000, 9735: 1000},
+			want:  []want{{[]int{4, 1059, 9735}, 1001}, {[]int{1}, 0}},
+		},
+		{
+			name:  "mentions seeded when enabled",
+			kinds: []int{4, 1059, 9735, 1},
+			marks: map[int]int64{1: 2000},
+			want:  []want{{[]int{4, 1059, 9735}, 0}, {[]int{1}, 2001}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kinds := tt.kinds
+			if kinds == nil {
+				kinds = subscribedKinds
+			}
+			filters := sinceFilters(bot, kinds, tt.marks, tt.overlap)
+			if len(filters) != len(tt.want) {
+				t.Fatalf("got %d filters %v, want %d", len(filters), filters, len(tt.want))
+			}
+			for i, f := range filters {
+				if !slices.Equal(f.Kinds, tt.want[i].kinds) {
+					t.Errorf("filter %d kinds = %v, want %v", i, f.Kinds, tt.want[i].kinds)
+				}
+				var since int64
+				if f.Since != nil {
+					since = int64(*f.Since)
+				}
+				if since != tt.want[i].since {
+					t.Errorf("filter %d since = %d, want %d", i, since, tt.want[i].since)
+				}
+				if p := f.Tags["p"]; len(p) != 1 || p[0] != bot {
+					t.Errorf("filter %d p tags = %v, want the bot", i, p)
+				}
+			}
+		})
+	}
+}
+
+// fakeSubscriptions stands in for relay subscriptions, feeding each relay's
+// events until its subscription is cancelled.
+type fakeSubscriptions struct {
+	mu        sync.Mutex
+	feeds     map[string]chan *nostr.Event
+	cancelled map[string]bool
+}
+
+func (f *fakeSubscriptions) subscribeMany(ctx context.Context, urls []string, _ nostr.Filter) chan nostr.RelayEvent {
+	url := urls[0]
+	feed := make(chan *nostr.Event, 1)
+	f.mu.Lock()
+	f.feeds[url] = feed
+	f.mu.Unlock()
+
+	out := make(chan nostr.RelayEvent)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case <-ctx.Done():
+				f.mu.Lock()
+				f.cancelled[url] = true
+				f.mu.Unlock()
+				return
+			case event := <-feed:
+				out <- nostr.RelayEvent{Event: event, Relay: &nostr.Relay{URL: nostr.NormalizeURL(url)}}
+			}
+		}
+	}()
+	return out
+}
+
+func (f *fakeSubscriptions) feed(t *testing.T, url string) chan *nostr.Event {
+	t.Helper()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	feed, ok := f.feeds[url]
+	if !ok {
+		t.Fatalf("no subscription for %s", url)
+	}
+	return feed
+}
+
+func TestSetRelays(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA}, []string{testFallbackA}, "", nil)
+
+	// Before Connect only the lists change
+	added, removed := rm.SetRelays([]string{testPrimaryA}, []string{testFallbackA, testFallbackB})
+	if !slices.Equal(added, []string{testFallbackB}) || removed != nil {
+		t.Errorf("added %v, removed %v; want [%s] added", added, removed, testFallbackB)
+	}
+
+	subs := &fakeSubscriptions{feeds: map[string]chan *nostr.Event{}, cancelled: map[string]bool{}}
+	rm.subscribeMany = subs.subscribeMany
+	if err := rm.Connect(context.Background(), nil, 0); err != nil {
+		t.Fatalf("Connect: %v", err)
+	}
+
+	// Fallback B turns primary, fallback A goes and primary B arrives
+	added, removed = rm.SetRelays([]string{testPrimaryA, testPrimaryB, testFallbackB}, nil)
+	if !slices.Equal(added, []string{testPrimaryB}) || !slices.Equal(removed, []string{testFallbackA}) {
+		t.Errorf("added %v, removed %v; want [%s] added, [%s] removed", added, removed, testPrimaryB, testFallbackA)
+	}
+	primaryURLs, fallbackURLs := rm.relays()
+	if !slices.Equal(primaryURLs, []string{testPrimaryA, testPrimaryB, testFallbackB}) || fallbackURLs != nil {
+		t.Errorf("relays = %v, %v", primaryURLs, fallbackURLs)
+	}
+
+	// The new relay's events arrive, as primary relay events
+	subs.feed(t, testPrimaryB) <- &nostr.Event{ID: "from-b", Kind: nostr.KindGiftWrap}
+	select {
+	case event := <-rm.Events():
+		if event.ID != "from-b" {
+			t.Errorf("got event %s, want from-b", event.ID)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the new relay's event")
+	}
+	if !rm.isPrimary(testFallbackB) {
+		t.Errorf("%s should now be primary", testFallbackB)
+	}
+
+	rm.Close()
+	// Every subscription ends, which closes the event channels
+	for range rm.Events() {
+	}
+	subs.mu.Lock()
+	defer subs.mu.Unlock()
+	for _, url := range []string{testPrimaryA, testPrimaryB, testFallbackA, testFallbackB} {
+		if !subs.cancelled[url] {
+			t.Errorf("subscription to %s still running", url)
+		}
+	}
+}
+
+func TestRelayManager_EventsZapsFirst(t *testing.T) {
+	rm := NewRelayManager([]string{testPrimaryA}, nil, "", nil)
+
+	for _, id := range []string{"dm1", "dm2", "dm3"} {
+		rm.primaryDMs <- &nostr.Event{ID: id, Kind: nostr.KindGiftWrap}
+	}
+	rm.fallbackMentions <- &nostr.Event{ID: "mention", Kind: nostr.KindTextNote}
+	rm.fallbackZaps <- &nostr.Event{ID: "zap", Kind: nostr.KindZap}
+
+	var got []string
+	for range 5 {
+		select {
+		case event := <-rm.Events():
+			got = append(got, event.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out after events %v", got)
+		}
+	}
+
+	// DMs already taken from the queue may go first, but the zap jumps the rest
+	if i := slices.Index(got, "zap"); i == -1 || i > slices.Index(got, "dm3") {
+		t.Errorf("got events %v, want the zap before dm3", got)
+	}
+	if !slices.Contains(got, "mention") {
+		t.Errorf("got events %v, want the mention too", got)
+	}
+}
@@ -4,8 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
 
 	"github.com/buildtall-systems/eggbot/internal/db"
+	"github.com/buildtall-systems/eggbot/internal/messages"
 )
 
 // ProcessResult contains the outcome of processing a zap.
@@ -13,22 +17,35 @@ type ProcessResult struct {
 	CustomerFound bool   // Whether the sender is a registered customer
 	AmountSats    int64  // Amount credited
 	Message       string // Human-readable result message
+	PaidOrderID   int64  // Order the zap paid for; 0 if none
+	PendingOrders int    // The customer's orders still awaiting payment afterwards
+	Balance       int64  // The customer's balance, when it fell short of an order
+	NeededSats    int64  // Price of the order the balance fell short of; 0 if none
+	Reason        string // Why a credited zap paid no order, for the admins
 }
 
 // ErrDuplicateZap indicates the zap has already been processed.
 var ErrDuplicateZap = errors.New("duplicate zap event")
 
+// orderCommentPattern finds the order number in a comment like "Order #42",
+// which the order command attaches to the invoices it requests.
+var orderCommentPattern = regexp.MustCompile(`(?i)\border\s*#(\d+)`)
+
 // ProcessZap records a validated zap payment for a customer.
 // Only credits known customers (whitelist check).
+// A zap whose comment names one of the customer's pending orders pays that
+// order; otherwise the oldest pending order is paid once the balance covers it.
+// pickupInstructions, if non-empty, is appended when the zap pays an order.
+// msgs renders the result's message; nil uses the built-in text.
 // Returns ProcessResult with CustomerFound=false if sender is not a customer.
-func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap) (*ProcessResult, error) {
+func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap, pickupInstructions string, msgs *messages.Catalog) (*ProcessResult, error) {
 	// Check if customer exists (whitelist check)
 	customer, err := database.GetCustomerByNpub(ctx, zap.SenderNpub)
 	if errors.Is(err, db.ErrCustomerNotFound) {
 		return &ProcessResult{
 			CustomerFound: false,
 			AmountSats:    zap.AmountSats,
-			Message:       fmt.Sprintf("Zap received from unknown sender %s (%d sats) - not credited", zap.SenderNpub, zap.AmountSats),
+			Message:       msgs.Render(messages.ZapNotCredited, messages.ZapData{SenderNpub: zap.SenderNpub, AmountSats: zap.AmountSats}),
 		}, nil
 	}
 	if err != nil {
@@ -36,7 +53,11 @@ func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap) (*Proce
 	}
 
 	// Record the transaction
-	_, err = database.RecordTransaction(ctx, nil, zap.ZapEventID, zap.AmountSats, zap.SenderNpub)
+	source := db.TransactionSourceZap
+	if zap.Simulated {
+		source = db.TransactionSourceSynthetic
+	}
+	_, err = database.RecordTransaction(ctx, nil, zap.ZapEventID, source, zap.AmountSats, zap.SenderNpub)
 	if err != nil {
 		// Check for duplicate (unique constraint on zap_event_id)
 		if isDuplicateZap(err) {
@@ -45,6 +66,9 @@ func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap) (*Proce
 		return nil, fmt.Errorf("recording transaction: %w", err)
 	}
 
+	// A zap paying an invoice generated for an order settles that invoice
+	markInvoicePaid(ctx, database, zap)
+
 	// Check for pending orders and attempt to mark as paid
 	pendingOrders, err := database.GetPendingOrdersByCustomer(ctx, customer.ID)
 	if err != nil {
@@ -52,7 +76,8 @@ func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap) (*Proce
 		return &ProcessResult{
 			CustomerFound: true,
 			AmountSats:    zap.AmountSats,
-			Message:       fmt.Sprintf("Credited %d sats (warning: could not check pending orders)", zap.AmountSats),
+			Message:       msgs.Render(messages.ZapCredited, messages.ZapCreditedData{AmountSats: zap.AmountSats, Unchecked: true}),
+			Reason:        "could not check pending orders",
 		}, nil
 	}
 
@@ -63,37 +88,101 @@ func ProcessZap(ctx context.Context, database *db.DB, zap *ValidatedZap) (*Proce
 			return &ProcessResult{
 				CustomerFound: true,
 				AmountSats:    zap.AmountSats,
-				Message:       fmt.Sprintf("Credited %d sats (has %d pending order(s))", zap.AmountSats, len(pendingOrders)),
+				Message:       msgs.Render(messages.ZapCredited, messages.ZapCreditedData{AmountSats: zap.AmountSats, PendingOrders: len(pendingOrders)}),
+				PendingOrders: len(pendingOrders),
+				Reason:        "could not check balance",
 			}, nil
 		}
 
-		// Check if balance covers oldest pending order
-		oldestOrder := pendingOrders[len(pendingOrders)-1] // Orders are DESC, so last is oldest
-		if balance >= oldestOrder.TotalSats {
+		// Check if balance covers the named order, or else the oldest one
+		order := pendingOrders[len(pendingOrders)-1] // Orders are DESC, so last is oldest
+		if id, ok := commentOrderID(zap.Comment); ok {
+			for _, o := range pendingOrders {
+				if o.ID == id {
+					order = o
+				}
+			}
+		}
+		if balance >= order.TotalSats {
 			// Mark order as paid
-			if err := database.UpdateOrderStatus(ctx, oldestOrder.ID, "paid"); err == nil {
+			err := database.UpdateOrderStatus(ctx, order.ID, "paid")
+			if err == nil {
 				return &ProcessResult{
 					CustomerFound: true,
 					AmountSats:    zap.AmountSats,
-					Message:       fmt.Sprintf("Credited %d sats - order #%d marked as paid!", zap.AmountSats, oldestOrder.ID),
+					Message:       paidMessage(msgs, zap.AmountSats, order.ID, pickupInstructions),
+					PaidOrderID:   order.ID,
+					PendingOrders: len(pendingOrders) - 1,
 				}, nil
 			}
+			slog.Error("marking order paid failed", "order_id", order.ID, "error", err)
+			return &ProcessResult{
+				CustomerFound: true,
+				AmountSats:    zap.AmountSats,
+				Message:       msgs.Render(messages.ZapCredited, messages.ZapCreditedData{AmountSats: zap.AmountSats, PendingOrders: len(pendingOrders)}),
+				PendingOrders: len(pendingOrders),
+				Reason:        fmt.Sprintf("could not mark order #%d paid", order.ID),
+			}, nil
 		}
 
 		return &ProcessResult{
 			CustomerFound: true,
 			AmountSats:    zap.AmountSats,
-			Message:       fmt.Sprintf("Credited %d sats (balance: %d, order needs %d)", zap.AmountSats, balance, pendingOrders[len(pendingOrders)-1].TotalSats),
+			Message: msgs.Render(messages.ZapCredited, messages.ZapCreditedData{
+				AmountSats: zap.AmountSats, PendingOrders: len(pendingOrders), Balance: balance, NeededSats: order.TotalSats,
+			}),
+			PendingOrders: len(pendingOrders),
+			Balance:       balance,
+			NeededSats:    order.TotalSats,
+			Reason:        fmt.Sprintf("not enough to pay order #%d", order.ID),
 		}, nil
 	}
 
 	return &ProcessResult{
 		CustomerFound: true,
 		AmountSats:    zap.AmountSats,
-		Message:       fmt.Sprintf("Credited %d sats", zap.AmountSats),
+		Message:       msgs.Render(messages.ZapCredited, messages.ZapCreditedData{AmountSats: zap.AmountSats}),
+		Reason:        "no pending orders",
 	}, nil
 }
 
+// commentOrderID returns the order number named in a zap comment.
+func commentOrderID(comment string) (int64, bool) {
+	m := orderCommentPattern.FindStringSubmatch(comment)
+	if m == nil {
+		return 0, false
+	}
+	id, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+// markInvoicePaid marks the invoice a zap paid as paid, if the bot generated
+// it. Most zaps pay invoices the bot never saw, so only failures are logged.
+func markInvoicePaid(ctx context.Context, database *db.DB, zap *ValidatedZap) {
+	if zap.PaymentHash == "" {
+		return
+	}
+	err := database.MarkInvoicePaid(ctx, zap.PaymentHash)
+	switch {
+	case err == nil:
+		slog.Info("zap paid a generated invoice", "zap_event_id", zap.ZapEventID, "payment_hash", zap.PaymentHash)
+	case !errors.Is(err, db.ErrInvoiceNotFound):
+		slog.Warn("marking invoice paid failed", "zap_event_id", zap.ZapEventID, "error", err)
+	}
+}
+
+// paidMessage is the confirmation for a zap that paid an order.
+func paidMessage(msgs *messages.Catalog, amountSats, orderID int64, pickupInstructions string) string {
+	msg := msgs.Render(messages.OrderPaid, messages.OrderPaidData{AmountSats: amountSats, OrderID: orderID})
+	if pickupInstructions != "" {
+		msg += "\n\n" + pickupInstructions
+	}
+	return msg
+}
+
 // isDuplicateZap checks if the error indicates a duplicate zap event ID.
 func isDuplicateZap(err error) bool {
 	if err == nil {
@@ -2,8 +2,13 @@ package zaps
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/buildtall-systems/eggbot/internal/db"
 )
@@ -37,7 +42,7 @@ func TestProcessZap_UnknownSender(t *testing.T) {
 		ZapEventID: "test-zap-event-1",
 	}
 
-	result, err := ProcessZap(ctx, database, zap)
+	result, err := ProcessZap(ctx, database, zap, "", nil)
 	if err != nil {
 		t.Fatalf("ProcessZap() error = %v", err)
 	}
@@ -63,7 +68,7 @@ func TestProcessZap_KnownCustomer(t *testing.T) {
 	ctx := context.Background()
 
 	// Register customer first
-	_, err := database.CreateCustomer(ctx, testSenderNpub)
+	_, err := database.CreateCustomer(ctx, testSenderNpub, "")
 	if err != nil {
 		t.Fatalf("creating customer: %v", err)
 	}
@@ -74,7 +79,7 @@ func TestProcessZap_KnownCustomer(t *testing.T) {
 		ZapEventID: "test-zap-event-2",
 	}
 
-	result, err := ProcessZap(ctx, database, zap)
+	result, err := ProcessZap(ctx, database, zap, "", nil)
 	if err != nil {
 		t.Fatalf("ProcessZap() error = %v", err)
 	}
@@ -105,7 +110,7 @@ func TestProcessZap_DuplicateZap(t *testing.T) {
 	ctx := context.Background()
 
 	// Register customer
-	_, err := database.CreateCustomer(ctx, testSenderNpub)
+	_, err := database.CreateCustomer(ctx, testSenderNpub, "")
 	if err != nil {
 		t.Fatalf("creating customer: %v", err)
 	}
@@ -117,13 +122,13 @@ func TestProcessZap_DuplicateZap(t *testing.T) {
 	}
 
 	// First zap should succeed
-	_, err = ProcessZap(ctx, database, zap)
+	_, err = ProcessZap(ctx, database, zap, "", nil)
 	if err != nil {
 		t.Fatalf("first ProcessZap() error = %v", err)
 	}
 
 	// Second zap with same ID should fail
-	_, err = ProcessZap(ctx, database, zap)
+	_, err = ProcessZap(ctx, database, zap, "", nil)
 	if err != ErrDuplicateZap {
 		t.Errorf("expected ErrDuplicateZap, got %v", err)
 	}
@@ -146,7 +151,7 @@ func TestProcessZap_AutoMarkPaid(t *testing.T) {
 	ctx := context.Background()
 
 	// Register customer
-	customer, err := database.CreateCustomer(ctx, testSenderNpub)
+	customer, err := database.CreateCustomer(ctx, testSenderNpub, "")
 	if err != nil {
 		t.Fatalf("creating customer: %v", err)
 	}
@@ -167,7 +172,7 @@ func TestProcessZap_AutoMarkPaid(t *testing.T) {
 		ZapEventID: "auto-pay-zap",
 	}
 
-	result, err := ProcessZap(ctx, database, zap)
+	result, err := ProcessZap(ctx, database, zap, "", nil)
 	if err != nil {
 		t.Fatalf("ProcessZap() error = %v", err)
 	}
@@ -185,6 +190,93 @@ func TestProcessZap_AutoMarkPaid(t *testing.T) {
 	if updatedOrder.Status != "paid" {
 		t.Errorf("order status = %s, want 'paid'", updatedOrder.Status)
 	}
+	if result.PaidOrderID != order.ID || result.PendingOrders != 0 {
+		t.Errorf("PaidOrderID = %d, PendingOrders = %d; want %d, 0", result.PaidOrderID, result.PendingOrders, order.ID)
+	}
+}
+
+func TestProcessZap_PaysOrderNamedInComment(t *testing.T) {
+	database := setupProcessorTestDB(t)
+	defer func() { _ = database.Close() }()
+
+	ctx := context.Background()
+	customer, _ := database.CreateCustomer(ctx, testSenderNpub, "")
+	_ = database.AddEggs(ctx, 24)
+	oldest, _ := database.CreateOrder(ctx, customer.ID, 6, 3200)
+	named, _ := database.CreateOrder(ctx, customer.ID, 12, 6400)
+	_, _ = database.CreateOrder(ctx, customer.ID, 6, 3200)
+	if _, err := database.ExecContext(ctx, `UPDATE orders SET created_at = datetime('now', '-1 hour') WHERE id = ?`, oldest.ID); err != nil {
+		t.Fatalf("backdating order: %v", err)
+	}
+
+	zap := &ValidatedZap{SenderNpub: testSenderNpub, AmountSats: 6400, ZapEventID: "comment-zap", Comment: fmt.Sprintf("Order #%d", named.ID)}
+	result, err := ProcessZap(ctx, database, zap, "", nil)
+	if err != nil {
+		t.Fatalf("ProcessZap() error = %v", err)
+	}
+	if result.PaidOrderID != named.ID || result.PendingOrders != 2 {
+		t.Errorf("PaidOrderID = %d, PendingOrders = %d; want %d, 2", result.PaidOrderID, result.PendingOrders, named.ID)
+	}
+	if o, _ := database.GetOrderByID(ctx, oldest.ID); o.Status != "pending" {
+		t.Errorf("oldest order status = %s, want it still pending", o.Status)
+	}
+
+	// A comment naming no pending order falls back to the oldest
+	zap = &ValidatedZap{SenderNpub: testSenderNpub, AmountSats: 3200, ZapEventID: "stale-comment-zap", Comment: "order #999"}
+	if result, err := ProcessZap(ctx, database, zap, "", nil); err != nil || result.PaidOrderID != oldest.ID {
+		t.Errorf("got %+v, %v; want order %d paid", result, err, oldest.ID)
+	}
+}
+
+func TestCommentOrderID(t *testing.T) {
+	tests := []struct {
+		comment string
+		want    int64
+		wantOK  bool
+	}{
+		{"Order #42", 42, true},
+		{"thanks! order #7 for pickup", 7, true},
+		{"ORDER#3", 3, true},
+		{"", 0, false},
+		{"#42", 0, false},
+		{"reorder #42", 0, false},
+	}
+	for _, tt := range tests {
+		id, ok := commentOrderID(tt.comment)
+		if id != tt.want || ok != tt.wantOK {
+			t.Errorf("commentOrderID(%q) = %d, %v; want %d, %v", tt.comment, id, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestProcessZap_MarksInvoicePaid(t *testing.T) {
+	database := setupProcessorTestDB(t)
+	defer func() { _ = database.Close() }()
+
+	ctx := context.Background()
+	customer, _ := database.CreateCustomer(ctx, testSenderNpub, "")
+	_ = database.AddEggs(ctx, 6)
+	order, _ := database.CreateOrder(ctx, customer.ID, 6, 1000)
+	invoice, err := database.RecordInvoice(ctx, order.ID, testInvoice1000Sats(t, time.Now()), 1000, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("RecordInvoice: %v", err)
+	}
+
+	// A zap paying some other invoice leaves it alone
+	if _, err := ProcessZap(ctx, database, &ValidatedZap{SenderNpub: testSenderNpub, AmountSats: 10, ZapEventID: "other-zap", PaymentHash: "ff"}, "", nil); err != nil {
+		t.Fatalf("ProcessZap() error = %v", err)
+	}
+	if invoices, _ := database.GetInvoicesByOrder(ctx, order.ID); invoices[0].Status != db.InvoiceStatusRequested {
+		t.Errorf("invoice status = %s, want it still requested", invoices[0].Status)
+	}
+
+	zap := &ValidatedZap{SenderNpub: testSenderNpub, AmountSats: 1000, ZapEventID: "invoice-zap", PaymentHash: invoice.PaymentHash}
+	if _, err := ProcessZap(ctx, database, zap, "", nil); err != nil {
+		t.Fatalf("ProcessZap() error = %v", err)
+	}
+	if invoices, _ := database.GetInvoicesByOrder(ctx, order.ID); invoices[0].Status != db.InvoiceStatusPaid {
+		t.Errorf("invoice status = %s, want paid", invoices[0].Status)
+	}
 }
 
 func TestProcessZap_InsufficientForOrder(t *testing.T) {
@@ -194,7 +286,7 @@ func TestProcessZap_InsufficientForOrder(t *testing.T) {
 	ctx := context.Background()
 
 	// Register customer
-	customer, err := database.CreateCustomer(ctx, testSenderNpub)
+	customer, err := database.CreateCustomer(ctx, testSenderNpub, "")
 	if err != nil {
 		t.Fatalf("creating customer: %v", err)
 	}
@@ -215,7 +307,7 @@ func TestProcessZap_InsufficientForOrder(t *testing.T) {
 		ZapEventID: "partial-zap",
 	}
 
-	result, err := ProcessZap(ctx, database, zap)
+	result, err := ProcessZap(ctx, database, zap, "", nil)
 	if err != nil {
 		t.Fatalf("ProcessZap() error = %v", err)
 	}
@@ -233,6 +325,12 @@ func TestProcessZap_InsufficientForOrder(t *testing.T) {
 	if updatedOrder.Status != "pending" {
 		t.Errorf("order status = %s, want 'pending' (insufficient funds)", updatedOrder.Status)
 	}
+	if result.PaidOrderID != 0 || result.PendingOrders != 1 {
+		t.Errorf("PaidOrderID = %d, PendingOrders = %d; want 0, 1", result.PaidOrderID, result.PendingOrders)
+	}
+	if result.Balance != 1000 || result.NeededSats != 3200 || result.Reason != fmt.Sprintf("not enough to pay order #%d", order.ID) {
+		t.Errorf("Balance = %d, NeededSats = %d, Reason = %q; want 1000, 3200 and the order", result.Balance, result.NeededSats, result.Reason)
+	}
 
 	// Verify balance was credited though
 	balance, err := database.GetCustomerBalance(ctx, testSenderNpub)
@@ -244,3 +342,139 @@ func TestProcessZap_InsufficientForOrder(t *testing.T) {
 		t.Errorf("balance = %d, want 1000", balance)
 	}
 }
+
+func TestProcessZap_PickupInstructions(t *testing.T) {
+	database := setupProcessorTestDB(t)
+	defer func() { _ = database.Close() }()
+
+	ctx := context.Background()
+	pickup := "Pickup: blue cooler at the end of the driveway, Sat 9-12"
+
+	customer, err := database.CreateCustomer(ctx, testSenderNpub, "")
+	if err != nil {
+		t.Fatalf("creating customer: %v", err)
+	}
+	_ = database.AddEggs(ctx, 10)
+	if _, err := database.CreateOrder(ctx, customer.ID, 6, 3200); err != nil {
+		t.Fatalf("creating order: %v", err)
+	}
+
+	// Partial payment: no pickup text yet
+	result, err := ProcessZap(ctx, database, &ValidatedZap{
+		SenderNpub: testSenderNpub,
+		AmountSats: 1000,
+		ZapEventID: "partial-zap",
+	}, pickup, nil)
+	if err != nil {
+		t.Fatalf("ProcessZap() error = %v", err)
+	}
+	if strings.Contains(result.Message, pickup) {
+		t.Errorf("partial payment should not include pickup instructions: %q", result.Message)
+	}
+
+	// Payment that completes the order includes pickup text
+	result, err = ProcessZap(ctx, database, &ValidatedZap{
+		SenderNpub: testSenderNpub,
+		AmountSats: 2200,
+		ZapEventID: "completing-zap",
+	}, pickup, nil)
+	if err != nil {
+		t.Fatalf("ProcessZap() error = %v", err)
+	}
+	if !strings.Contains(result.Message, "marked as paid") || !strings.HasSuffix(result.Message, "\n\n"+pickup) {
+		t.Errorf("expected paid message ending with pickup instructions, got %q", result.Message)
+	}
+}
+
+// TestProcessZap_ConcurrentWithOrders runs orders, cancellations and zaps for
+// several customers at once against a file database with a connection pool.
+// No operation may fail with a lock error and no eggs may be lost or created.
+func TestProcessZap_ConcurrentWithOrders(t *testing.T) {
+	ctx := context.Background()
+	database, err := db.Open(filepath.Join(t.TempDir(), "eggbot.db"))
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	defer func() { _ = database.Close() }()
+	if err := database.Migrate(); err != nil {
+		t.Fatalf("migrating database: %v", err)
+	}
+
+	const (
+		customers  = 4
+		rounds     = 15
+		startStock = 240
+	)
+	if err := database.SetInventory(ctx, startStock); err != nil {
+		t.Fatalf("SetInventory: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, customers*rounds*3)
+	for c := range customers {
+		npub := fmt.Sprintf("npub1stresscustomer%d", c)
+		customer, err := database.CreateCustomer(ctx, npub, "")
+		if err != nil {
+			t.Fatalf("CreateCustomer: %v", err)
+		}
+
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				if _, err := database.CreateOrder(ctx, customer.ID, 6, 1000); err != nil && !errors.Is(err, db.ErrInsufficientInventory) {
+					errs <- fmt.Errorf("CreateOrder: %w", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				pending, err := database.GetPendingOrdersByCustomer(ctx, customer.ID)
+				if err != nil {
+					errs <- fmt.Errorf("GetPendingOrdersByCustomer: %w", err)
+					continue
+				}
+				if len(pending) == 0 {
+					continue
+				}
+				if err := database.CancelOrder(ctx, pending[0].ID); err != nil && !errors.Is(err, db.ErrOrderNotPending) {
+					errs <- fmt.Errorf("CancelOrder: %w", err)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := range rounds {
+				zap := &ValidatedZap{SenderNpub: npub, AmountSats: 1000, ZapEventID: fmt.Sprintf("zap-%d-%d", c, i)}
+				if _, err := ProcessZap(ctx, database, zap, "", nil); err != nil {
+					errs <- fmt.Errorf("ProcessZap: %w", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// Every egg is either available or held by a live order
+	available, _ := database.GetInventory(ctx)
+	reserved, _ := database.GetReservedEggs(ctx)
+	sold, _ := database.GetSoldEggs(ctx)
+	if available+reserved+sold != startStock {
+		t.Errorf("available %d + reserved %d + sold %d != %d", available, reserved, sold, startStock)
+	}
+
+	// Every zap was recorded
+	for c := range customers {
+		balance, err := database.GetCustomerBalance(ctx, fmt.Sprintf("npub1stresscustomer%d", c))
+		if err != nil {
+			t.Fatalf("GetCustomerBalance: %v", err)
+		}
+		if balance != rounds*1000 {
+			t.Errorf("customer %d balance = %d, want %d", c, balance, rounds*1000)
+		}
+	}
+}
@@ -4,18 +4,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"strconv"
-	"strings"
+	"time"
 
+	"github.com/buildtall-systems/eggbot/internal/lightning"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
 // ValidatedZap contains extracted information from a valid zap receipt.
 type ValidatedZap struct {
-	SenderNpub string // Npub of the zapper
-	AmountSats int64  // Amount in sats (from bolt11)
-	ZapEventID string // Event ID of the zap receipt
+	SenderNpub  string // Npub of the zapper
+	AmountSats  int64  // Amount in sats (from bolt11)
+	ZapEventID  string // Event ID of the zap receipt
+	ZappedNote  string // Event ID of the zapped note ("e" tag), empty for profile zaps
+	PaymentHash string // Hex payment hash of the paid invoice (bolt11)
+	Comment     string // Zap request content, the zapper's comment; may name an order
+	Simulated   bool   // Made up by "eggbot simulate" rather than received from a relay
 }
 
 // ErrInvalidZapReceipt indicates the zap receipt is malformed or invalid.
@@ -24,6 +28,9 @@ var ErrInvalidZapReceipt = errors.New("invalid zap receipt")
 // ErrUnauthorizedZapProvider indicates the zap was signed by an unexpected key.
 var ErrUnauthorizedZapProvider = errors.New("unauthorized zap provider")
 
+// ErrExpiredInvoice indicates the zap receipt's invoice had expired when it was paid.
+var ErrExpiredInvoice = errors.New("invoice expired")
+
 // ValidateZapReceipt validates a NIP-57 zap receipt and extracts payment info.
 // lnurlPubkeyHex is the expected LNURL provider's pubkey that should sign zap receipts.
 // If lnurlPubkeyHex is empty, the provider check is skipped.
@@ -78,13 +85,27 @@ func ValidateZapReceipt(event *nostr.Event, lnurlPubkeyHex string) (*ValidatedZa
 	}
 	bolt11 := bolt11Tag[1]
 
-	amountMsats, err := extractAmountFromBolt11(bolt11)
+	invoice, err := lightning.DecodeBolt11(bolt11)
 	if err != nil {
 		return nil, fmt.Errorf("%w: %v", ErrInvalidZapReceipt, err)
 	}
 
+	// An amountless invoice doesn't say what was paid, so there is nothing to credit
+	if invoice.AmountMsats == 0 {
+		return nil, fmt.Errorf("%w: bolt11 invoice has no amount", ErrInvalidZapReceipt)
+	}
+
+	// Judge expiry against the receipt's timestamp (when the provider saw the
+	// payment settle) rather than the wall clock, so receipts replayed from
+	// relays after downtime aren't rejected for invoices paid on time.
+	paidAt := event.CreatedAt.Time()
+	if expiresAt := invoice.ExpiresAt(); paidAt.After(expiresAt) {
+		return nil, fmt.Errorf("%w: expired at %s, receipt issued %s", ErrExpiredInvoice,
+			expiresAt.UTC().Format(time.RFC3339), paidAt.UTC().Format(time.RFC3339))
+	}
+
 	// Convert millisats to sats (integer division, round down)
-	amountSats := amountMsats / 1000
+	amountSats := invoice.AmountMsats / 1000
 
 	// Encode sender pubkey as npub
 	senderNpub, err := nip19.EncodePublicKey(senderPubkeyHex)
@@ -92,73 +113,18 @@ func ValidateZapReceipt(event *nostr.Event, lnurlPubkeyHex string) (*ValidatedZa
 		return nil, fmt.Errorf("%w: failed to encode sender npub: %v", ErrInvalidZapReceipt, err)
 	}
 
-	return &ValidatedZap{
-		SenderNpub: senderNpub,
-		AmountSats: amountSats,
-		ZapEventID: event.ID,
-	}, nil
-}
-
-// extractAmountFromBolt11 extracts the amount in millisats from a BOLT11 invoice.
-// BOLT11 format: lnbc<amount><multiplier>1<data>
-// Multipliers: m = milli (10^-3), u = micro (10^-6), n = nano (10^-9), p = pico (10^-12)
-func extractAmountFromBolt11(invoice string) (int64, error) {
-	invoice = strings.ToLower(invoice)
-
-	// Find the prefix (lnbc, lntb, lnbcrt, etc.)
-	var amountStart int
-	if strings.HasPrefix(invoice, "lnbcrt") {
-		amountStart = 6
-	} else if strings.HasPrefix(invoice, "lnbc") {
-		amountStart = 4
-	} else if strings.HasPrefix(invoice, "lntb") {
-		amountStart = 4
-	} else {
-		return 0, fmt.Errorf("unrecognized invoice prefix")
-	}
-
-	// The separator '1' divides HRP from data. The data part uses bech32 which
-	// doesn't include '1', so we find the last '1' to get the separator.
-	// Format: ln<network><amount>[multiplier]1<timestamp><tagged_fields><signature>
-	sepIndex := strings.LastIndex(invoice, "1")
-	if sepIndex == -1 || sepIndex <= amountStart {
-		return 0, fmt.Errorf("invalid invoice format: no separator found")
-	}
-
-	amountPart := invoice[amountStart:sepIndex]
-	if amountPart == "" {
-		return 0, fmt.Errorf("no amount in invoice")
-	}
-
-	// Find the multiplier (last char if it's a letter)
-	var multiplier int64
-	var numStr string
-
-	lastChar := amountPart[len(amountPart)-1]
-	if lastChar >= '0' && lastChar <= '9' {
-		// No multiplier, amount is in BTC
-		numStr = amountPart
-		multiplier = 100_000_000_000 // BTC to msats
-	} else {
-		numStr = amountPart[:len(amountPart)-1]
-		switch lastChar {
-		case 'm': // milli-BTC = 100,000 sats = 100,000,000 msats
-			multiplier = 100_000_000
-		case 'u': // micro-BTC = 100 sats = 100,000 msats
-			multiplier = 100_000
-		case 'n': // nano-BTC = 0.1 sat = 100 msats
-			multiplier = 100
-		case 'p': // pico-BTC = 0.0001 sat = 0.1 msats (round to 0)
-			multiplier = 0 // Will round to 0 msats
-		default:
-			return 0, fmt.Errorf("unknown multiplier: %c", lastChar)
-		}
+	// Zapped note is optional (absent when zapping a profile)
+	var zappedNote string
+	if eTag := event.Tags.Find("e"); len(eTag) >= 2 {
+		zappedNote = eTag[1]
 	}
 
-	amount, err := strconv.ParseInt(numStr, 10, 64)
-	if err != nil {
-		return 0, fmt.Errorf("invalid amount number: %v", err)
-	}
-
-	return amount * multiplier, nil
+	return &ValidatedZap{
+		SenderNpub:  senderNpub,
+		AmountSats:  amountSats,
+		ZapEventID:  event.ID,
+		ZappedNote:  zappedNote,
+		PaymentHash: invoice.PaymentHash,
+		Comment:     zapRequest.Content,
+	}, nil
 }
@@ -2,79 +2,42 @@ package zaps
 
 import (
 	"encoding/json"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/btcsuite/btcd/btcutil/bech32"
 	"github.com/nbd-wtf/go-nostr"
 	"github.com/nbd-wtf/go-nostr/nip19"
 )
 
-func TestExtractAmountFromBolt11(t *testing.T) {
-	tests := []struct {
-		name     string
-		invoice  string
-		wantMsat int64
-		wantErr  bool
-	}{
-		{
-			name:     "100 sats (100u)",
-			invoice:  "lnbc100u1pnxyzabc",
-			wantMsat: 10_000_000, // 100 * 100,000 (micro-BTC to msats)
-			wantErr:  false,
-		},
-		{
-			name:     "1 milli-BTC (1m) = 100,000 sats",
-			invoice:  "lnbc1m1pnxyzabc",
-			wantMsat: 100_000_000, // 1 mBTC = 100,000 sats = 100,000,000 msats
-			wantErr:  false,
-		},
-		{
-			name:     "21 sats (21000n)",
-			invoice:  "lnbc21000n1pnxyzabc",
-			wantMsat: 2_100_000, // 21000 * 100 msats
-			wantErr:  false,
-		},
-		{
-			name:     "10000 sats (10000u)",
-			invoice:  "lnbc10000u1pnxyzabc",
-			wantMsat: 1_000_000_000, // 10000 * 100,000 msats
-			wantErr:  false,
-		},
-		{
-			name:     "testnet invoice",
-			invoice:  "lntb500u1pnxyzabc",
-			wantMsat: 50_000_000, // 500 * 100,000
-			wantErr:  false,
-		},
-		{
-			name:     "regtest invoice",
-			invoice:  "lnbcrt2500u1pnxyzabc",
-			wantMsat: 250_000_000, // 2500 * 100,000
-			wantErr:  false,
-		},
-		{
-			name:    "invalid prefix",
-			invoice: "invalid1pnxyzabc",
-			wantErr: true,
-		},
-		{
-			name:    "no separator (no 1)",
-			invoice: "lnbc500upnxyzabc",
-			wantErr: true,
-		},
+// testInvoice1000Sats builds a checksum-valid lnbc10u (10 micro-BTC = 1000 sats)
+// invoice created at the given time with the default one hour expiry.
+// The payment hash and signature are zeroed.
+func testInvoice1000Sats(t *testing.T, createdAt time.Time) string {
+	t.Helper()
+	return testInvoice(t, "lnbc10u", createdAt)
+}
+
+// testInvoice builds a checksum-valid invoice with the human-readable part
+// hrp, e.g. "lnbc" for one without an amount, created at the given time.
+func testInvoice(t *testing.T, hrp string, createdAt time.Time) string {
+	t.Helper()
+
+	words := make([]byte, 0, 7+3+52+104)
+	ts := createdAt.Unix()
+	for i := 6; i >= 0; i-- {
+		words = append(words, byte(ts>>(5*i))&31)
 	}
+	words = append(words, 1, 1, 20) // p field, 52 words
+	words = append(words, make([]byte, 52+104)...)
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got, err := extractAmountFromBolt11(tt.invoice)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("extractAmountFromBolt11() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if !tt.wantErr && got != tt.wantMsat {
-				t.Errorf("extractAmountFromBolt11() = %d msats, want %d msats", got, tt.wantMsat)
-			}
-		})
+	invoice, err := bech32.Encode(hrp, words)
+	if err != nil {
+		t.Fatalf("encoding invoice: %v", err)
 	}
+	return invoice
 }
 
 func TestValidateZapReceipt_InvalidKind(t *testing.T) {
@@ -195,7 +158,7 @@ func TestValidateZapReceipt_Valid(t *testing.T) {
 		Kind:      nostr.KindZapRequest,
 		PubKey:    senderPubkey,
 		CreatedAt: nostr.Now(),
-		Content:   "",
+		Content:   "Order #42",
 		Tags: nostr.Tags{
 			{"p", "80f10d3abbdda4db6f53ab6fa2c37db6fbc63cac32d23e87d140cfdd85c2c60f"}, // bot pubkey
 		},
@@ -214,7 +177,7 @@ func TestValidateZapReceipt_Valid(t *testing.T) {
 		Content:   "",
 		Tags: nostr.Tags{
 			{"description", string(zapRequestJSON)},
-			{"bolt11", "lnbc10u1pnxyzabcdef"}, // 10 micro-BTC = 1000 sats
+			{"bolt11", testInvoice1000Sats(t, time.Now())}, // 10 micro-BTC = 1000 sats
 			{"p", "80f10d3abbdda4db6f53ab6fa2c37db6fbc63cac32d23e87d140cfdd85c2c60f"},
 		},
 	}
@@ -240,6 +203,18 @@ func TestValidateZapReceipt_Valid(t *testing.T) {
 		t.Errorf("ZapEventID = %s, want %s", result.ZapEventID, event.ID)
 	}
 
+	if result.ZappedNote != "" {
+		t.Errorf("ZappedNote = %s, want empty for profile zap", result.ZappedNote)
+	}
+
+	if want := strings.Repeat("0", 64); result.PaymentHash != want {
+		t.Errorf("PaymentHash = %s, want %s", result.PaymentHash, want)
+	}
+
+	if result.Comment != "Order #42" {
+		t.Errorf("Comment = %q, want the zap request content", result.Comment)
+	}
+
 	// Validate - provider check disabled (empty string)
 	result2, err := ValidateZapReceipt(event, "")
 	if err != nil {
@@ -250,3 +225,138 @@ func TestValidateZapReceipt_Valid(t *testing.T) {
 		t.Errorf("AmountSats = %d, want 1000", result2.AmountSats)
 	}
 }
+
+func TestValidateZapReceipt_ZappedNote(t *testing.T) {
+	senderPubkey := "dcfafaaebf643e0c8517e49e13ad25c60ee4a57a0b5f5fc401adbcb9d151f5f5"
+	zappedNoteID := "5c83da77af1dec6d7289834998ad7aafbd9e2191396d75ec3cc27f5a77226f36"
+
+	zapRequest := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		PubKey:    senderPubkey,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"p", "80f10d3abbdda4db6f53ab6fa2c37db6fbc63cac32d23e87d140cfdd85c2c60f"},
+			{"e", zappedNoteID},
+		},
+	}
+	zapRequestJSON, _ := json.Marshal(zapRequest)
+
+	event := &nostr.Event{
+		Kind:      nostr.KindZap,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"description", string(zapRequestJSON)},
+			{"bolt11", testInvoice1000Sats(t, time.Now())},
+			{"p", "80f10d3abbdda4db6f53ab6fa2c37db6fbc63cac32d23e87d140cfdd85c2c60f"},
+			{"e", zappedNoteID},
+		},
+	}
+	_ = event.Sign("234702910939c3394838131938e8da0dcfec369df3e51990263eae626aa73f87")
+
+	result, err := ValidateZapReceipt(event, "")
+	if err != nil {
+		t.Fatalf("ValidateZapReceipt() error = %v", err)
+	}
+
+	if result.ZappedNote != zappedNoteID {
+		t.Errorf("ZappedNote = %s, want %s", result.ZappedNote, zappedNoteID)
+	}
+}
+
+func TestValidateZapReceipt_InvalidBolt11(t *testing.T) {
+	zapRequest := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		PubKey:    "dcfafaaebf643e0c8517e49e13ad25c60ee4a57a0b5f5fc401adbcb9d151f5f5",
+		CreatedAt: nostr.Now(),
+	}
+	zapRequestJSON, _ := json.Marshal(zapRequest)
+
+	event := &nostr.Event{
+		Kind:      nostr.KindZap,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"description", string(zapRequestJSON)},
+			{"bolt11", "lnbc10u1pnxyzabcdef"}, // amount prefix looks right, checksum does not
+		},
+	}
+	_ = event.Sign("234702910939c3394838131938e8da0dcfec369df3e51990263eae626aa73f87")
+
+	_, err := ValidateZapReceipt(event, "")
+	if !errors.Is(err, ErrInvalidZapReceipt) {
+		t.Errorf("ValidateZapReceipt() error = %v, want ErrInvalidZapReceipt", err)
+	}
+}
+
+func TestValidateZapReceipt_AmountlessInvoice(t *testing.T) {
+	zapRequest := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		PubKey:    "dcfafaaebf643e0c8517e49e13ad25c60ee4a57a0b5f5fc401adbcb9d151f5f5",
+		CreatedAt: nostr.Now(),
+	}
+	zapRequestJSON, _ := json.Marshal(zapRequest)
+
+	event := &nostr.Event{
+		Kind:      nostr.KindZap,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"description", string(zapRequestJSON)},
+			{"bolt11", testInvoice(t, "lnbc", time.Now())},
+		},
+	}
+	_ = event.Sign("234702910939c3394838131938e8da0dcfec369df3e51990263eae626aa73f87")
+
+	_, err := ValidateZapReceipt(event, "")
+	if !errors.Is(err, ErrInvalidZapReceipt) || !strings.Contains(err.Error(), "no amount") {
+		t.Errorf("ValidateZapReceipt() error = %v, want ErrInvalidZapReceipt for the missing amount", err)
+	}
+}
+
+func TestValidateZapReceipt_InvoiceExpiry(t *testing.T) {
+	zapRequest := nostr.Event{
+		Kind:      nostr.KindZapRequest,
+		PubKey:    "dcfafaaebf643e0c8517e49e13ad25c60ee4a57a0b5f5fc401adbcb9d151f5f5",
+		CreatedAt: nostr.Now(),
+	}
+	zapRequestJSON, _ := json.Marshal(zapRequest)
+
+	// Expiry is judged against when the provider issued the receipt
+	receiptTime := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name             string
+		invoiceCreatedAt time.Time
+		wantErr          error
+	}{
+		{"fresh", receiptTime.Add(-5 * time.Minute), nil},
+		{"at boundary", receiptTime.Add(-time.Hour), nil},
+		{"expired", receiptTime.Add(-time.Hour - time.Second), ErrExpiredInvoice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event := &nostr.Event{
+				Kind:      nostr.KindZap,
+				CreatedAt: nostr.Timestamp(receiptTime.Unix()),
+				Tags: nostr.Tags{
+					{"description", string(zapRequestJSON)},
+					{"bolt11", testInvoice1000Sats(t, tt.invoiceCreatedAt)},
+				},
+			}
+			_ = event.Sign("234702910939c3394838131938e8da0dcfec369df3e51990263eae626aa73f87")
+
+			result, err := ValidateZapReceipt(event, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateZapReceipt() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateZapReceipt() error = %v", err)
+			}
+			if result.AmountSats != 1000 {
+				t.Errorf("AmountSats = %d, want 1000", result.AmountSats)
+			}
+		})
+	}
+}